@@ -0,0 +1,201 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/agent"
+	"github.com/kylereynolds/peep/internal/ingestion"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var agentCmd = &cobra.Command{
+	Use:   "agent --forward URL [file...]",
+	Short: "Tail local logs and forward them to a central Peep",
+	Long: `Run a lightweight agent that tails log files, or reads stdin, parses each
+line with this machine's parser patterns and filters, and sends the parsed logs
+to a central Peep's ingestion API in gzipped batches (POST /api/ingest/bulk).
+The central Peep lists its agents, and when each was last seen, on its
+Settings page; agents check in every minute even when there's nothing to send.
+
+Logs are stored in the local database (--db) before they're sent, so when the
+central Peep can't be reached they wait there and are retried with backoff,
+including after the agent restarts. The retention flags cap how much is kept
+while the network is down. The agent starts with logs stored after it first
+runs; older ones in the database aren't sent.
+
+Files are followed like tail -F: from their end, across rotation and
+truncation, and waiting for files that don't exist yet. Without files, the
+agent reads stdin and exits once it's all been sent.
+
+The central Peep needs its web server running. If it requires authentication,
+create a token there with: peep tokens create my-agent --scope ingest
+
+Examples:
+  peep agent --forward https://peep.internal:8080 --token peep_... /var/log/app.log
+  peep agent --forward http://10.0.0.5:8080 /var/log/nginx/access.log /var/log/nginx/error.log
+  journalctl -f -o cat | peep agent --forward http://10.0.0.5:8080
+  PEEP_AGENT_TOKEN=peep_... peep agent --forward https://peep.internal:8080 --exclude-levels debug app.log`,
+	RunE: runAgent,
+}
+
+func init() {
+	agentCmd.Flags().String("forward", "", "Base URL of the central Peep to send logs to (required)")
+	agentCmd.Flags().String("token", "", "API token for the central Peep (or PEEP_AGENT_TOKEN)")
+	agentCmd.Flags().String("name", "", "Name the central Peep shows for this agent (default the hostname)")
+	agentCmd.Flags().Bool("from-start", false, "Send files' existing lines too, not just new ones")
+	agentCmd.Flags().Int("batch-size", 500, "Most logs sent in one request")
+	agentCmd.Flags().StringSliceVar(&excludeLevels, "exclude-levels", []string{}, "Skip logs with these levels (comma-separated)")
+	agentCmd.Flags().StringSliceVar(&includeLevels, "include-levels", []string{}, "Only send logs with these levels (comma-separated)")
+	agentCmd.Flags().StringSliceVar(&excludePatterns, "exclude-patterns", []string{}, "Skip logs matching these regex patterns (comma-separated)")
+	agentCmd.Flags().StringSliceVar(&includePatterns, "include-patterns", []string{}, "Only send logs matching these regex patterns (comma-separated)")
+	addRetentionFlags(agentCmd)
+	agentCmd.MarkFlagRequired("forward")
+}
+
+func runAgent(cmd *cobra.Command, args []string) error {
+	forward, _ := cmd.Flags().GetString("forward")
+	token, _ := cmd.Flags().GetString("token")
+	fromStart, _ := cmd.Flags().GetBool("from-start")
+	batchSize, _ := cmd.Flags().GetInt("batch-size")
+	name, _ := cmd.Flags().GetString("name")
+
+	if u, err := url.Parse(forward); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return withHint(fmt.Errorf("invalid --forward URL %q", forward),
+			"💡 Use the central Peep's web address, e.g. --forward https://peep.internal:8080")
+	}
+	if batchSize < 1 {
+		return fmt.Errorf("--batch-size must be at least 1")
+	}
+	if token == "" {
+		token = os.Getenv("PEEP_AGENT_TOKEN")
+	}
+	if name == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			hostname = "peep-agent"
+		}
+		name = hostname
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+	if err := enableWriteBuffer(store); err != nil {
+		return err
+	}
+	store.EnableAutoRetention(retentionConfigFromFlags(cmd, store))
+
+	parser, err := ingestion.NewLogParser(cfg.Parser.Patterns)
+	if err != nil {
+		return err
+	}
+	applyFilterConfig()
+
+	forwarder, err := agent.NewForwarder(store, forward, token, name)
+	if err != nil {
+		return fmt.Errorf("failed to start forwarding: %w", err)
+	}
+	forwarder.BatchSize = batchSize
+	if pending, err := forwarder.Pending(); err == nil && pending > 0 {
+		fmt.Fprintf(stdout, "📦 %d logs buffered from an earlier run\n", pending)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Lines from every source; closed when stdin runs out
+	lines := make(chan string, 1000)
+	if len(args) == 0 {
+		fmt.Fprintf(stdout, "📡 Forwarding stdin to %s\n", forward)
+		go func() {
+			defer close(lines)
+			scanner := bufio.NewScanner(os.Stdin)
+			scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+			for scanner.Scan() {
+				select {
+				case lines <- scanner.Text():
+				case <-ctx.Done():
+					return
+				}
+			}
+			if err := scanner.Err(); err != nil {
+				slog.Error("failed to read stdin", "error", err)
+			}
+		}()
+	} else {
+		fmt.Fprintf(stdout, "📡 Forwarding %d file(s) to %s\n", len(args), forward)
+		for _, path := range args {
+			path := path
+			go func() {
+				if err := agent.Tail(ctx, path, fromStart, lines); err != nil {
+					slog.Error("stopped tailing file", "file", path, "error", err)
+				}
+			}()
+		}
+	}
+	fmt.Fprintf(stdout, "   Buffer: %s\n", cfg.DBPath)
+
+	forwarding, stopForwarding := context.WithCancel(ctx)
+	done := make(chan struct{})
+	go func() {
+		forwarder.Run(forwarding)
+		close(done)
+	}()
+
+	failed := 0
+read:
+	for {
+		var line string
+		select {
+		case <-ctx.Done():
+			break read
+		case next, ok := <-lines:
+			if !ok {
+				break read
+			}
+			line = next
+		}
+
+		entry := parser.ParseLine(line)
+		if shouldSkipLog(entry, line) {
+			continue
+		}
+		if err := store.InsertLog(entry); err != nil {
+			slog.Error("failed to buffer log", "error", err)
+			failed++
+			continue
+		}
+		forwarder.Notify()
+	}
+
+	// Tailing runs until interrupted; stdin ends when it's read, so send what's left
+	stopForwarding()
+	<-done
+	if ctx.Err() == nil {
+		flushCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := forwarder.Flush(flushCtx); err != nil {
+			slog.Warn("failed to send the last logs", "error", err)
+		}
+	}
+
+	fmt.Fprintf(stdout, "✅ Forwarded %d logs\n", forwarder.Forwarded())
+	if pending, err := forwarder.Pending(); err == nil && pending > 0 {
+		fmt.Fprintf(stdout, "📦 %d logs are buffered in %s and will be sent when peep agent next runs\n", pending, cfg.DBPath)
+	}
+	if failed > 0 {
+		return fmt.Errorf("failed to buffer %d log lines", failed)
+	}
+	return nil
+}
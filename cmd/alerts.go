@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"encoding/json"
 	"fmt"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/notifications"
 	"github.com/kylereynolds/peep/internal/storage"
 	"github.com/spf13/cobra"
 )
@@ -12,10 +18,17 @@ var alertsCmd = &cobra.Command{
 	Use:   "alerts",
 	Short: "Manage alert rules and notifications",
 	Long: `Create, list, and manage SQL-based alert rules that monitor your logs.
+
+A rule's query sees only the logs (and pattern_anomalies) within its window, so
+it can use WHERE, GROUP BY, joins, and subqueries freely.
 	
 Examples:
   peep alerts list                           # List all alert rules
+  peep alerts stats                          # Show the noisiest rules
+  peep alerts history --since 7d --unresolved  # Fired alerts that are still open
+  peep alerts check                          # Evaluate every rule once (add --notify for cron)
   peep alerts add "High Errors" "SELECT COUNT(*) FROM logs WHERE level='error'" --threshold 5 --window 5m
+  peep alerts add "Slow API" --metric api.latency.p95 --above 250 --window 5m
   peep alerts channels list                  # List notification channels
   peep alerts channels add desktop "Desktop Notifications"
   peep alerts channels add email "Team Alerts" --smtp-host smtp.gmail.com --username user@gmail.com --password app-password --from user@gmail.com --to team@company.com`,
@@ -24,48 +37,351 @@ Examples:
 var alertsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List all alert rules",
-	Run: func(cmd *cobra.Command, args []string) {
-		store, err := storage.NewStorage("logs.db")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
 
 		rules := engine.GetRules()
 		if len(rules) == 0 {
-			fmt.Println("📭 No alert rules configured.")
-			fmt.Println("💡 Add one with: peep alerts add \"Rule Name\" \"SELECT COUNT(*) FROM logs WHERE level='error'\"")
-			return
+			fmt.Fprintln(stdout, "📭 No alert rules configured.")
+			fmt.Fprintln(stdout, "💡 Add one with: peep alerts add \"Rule Name\" \"SELECT COUNT(*) FROM logs WHERE level='error'\"")
+			return nil
+		}
+
+		selector, _ := cmd.Flags().GetString("label")
+		rules = alerts.FilterRules(rules, selector)
+		if len(rules) == 0 {
+			fmt.Fprintf(stdout, "📭 No alert rules match labels: %s\n", selector)
+			return nil
 		}
 
-		fmt.Printf("🚨 Alert Rules (%d):\n\n", len(rules))
+		fmt.Fprintf(stdout, "🚨 Alert Rules (%d):\n\n", len(rules))
 		for _, rule := range rules {
 			status := "🔴 Disabled"
 			if rule.Enabled {
 				status = "🟢 Enabled"
 			}
 
-			fmt.Printf("%s %s\n", status, rule.Name)
-			fmt.Printf("   Query: %s\n", rule.Query)
-			fmt.Printf("   Threshold: %d in %s\n", rule.Threshold, rule.Window)
+			fmt.Fprintf(stdout, "%s %s\n", status, rule.Name)
+			if rule.Metric != nil {
+				fmt.Fprintf(stdout, "   Metric: %s over %s\n", rule.Metric, rule.Window)
+			} else {
+				fmt.Fprintf(stdout, "   Query: %s\n", rule.Query)
+				fmt.Fprintf(stdout, "   Threshold: %d in %s\n", rule.Threshold, rule.Window)
+			}
+			if len(rule.Labels) > 0 {
+				fmt.Fprintf(stdout, "   Labels: %s\n", alerts.FormatLabels(rule.Labels))
+			}
 			if !rule.LastCheck.IsZero() {
-				fmt.Printf("   Last Check: %s\n", rule.LastCheck.Format("2006-01-02 15:04:05"))
+				fmt.Fprintf(stdout, "   Last Check: %s\n", rule.LastCheck.Format("2006-01-02 15:04:05"))
 			}
 			if !rule.LastAlert.IsZero() {
-				fmt.Printf("   Last Alert: %s\n", rule.LastAlert.Format("2006-01-02 15:04:05"))
+				fmt.Fprintf(stdout, "   Last Alert: %s\n", rule.LastAlert.Format("2006-01-02 15:04:05"))
 			}
-			fmt.Println()
+			fmt.Fprintln(stdout)
 		}
+
+		return nil
 	},
 }
 
+var alertsStatsCmd = &cobra.Command{
+	Use:   "stats",
+	Short: "Show per-rule alert metrics, noisiest rules first",
+	Long: `Show how often each rule fires, how many notifications were sent or failed,
+and the mean time between fires, so noisy rules are easy to find.
+
+Examples:
+  peep alerts stats                 # All history
+  peep alerts stats --period 24h    # Only alerts fired in the last day`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		periodStr, _ := cmd.Flags().GetString("period")
+
+		var period time.Duration
+		if periodStr != "" {
+			parsed, err := time.ParseDuration(periodStr)
+			if err != nil {
+				return fmt.Errorf("invalid period: %w", err)
+			}
+			period = parsed
+		}
+
+		store, err := storage.NewStorage(cfg.DBPath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		defer store.Close()
+
+		engine, err := alerts.NewEngine(store)
+		if err != nil {
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
+		}
+
+		stats, err := engine.GetRuleStats(period)
+		if err != nil {
+			return fmt.Errorf("failed to load alert stats: %w", err)
+		}
+
+		if len(stats) == 0 {
+			fmt.Fprintln(stdout, "📭 No alert rules configured.")
+			return nil
+		}
+
+		if period > 0 {
+			fmt.Fprintf(stdout, "📊 Alert Stats (last %s):\n\n", period)
+		} else {
+			fmt.Fprintln(stdout, "📊 Alert Stats (all time):")
+			fmt.Fprintln(stdout)
+		}
+
+		for _, s := range stats {
+			fmt.Fprintf(stdout, "%s\n", s.RuleName)
+			fmt.Fprintf(stdout, "   Fires: %d\n", s.Fires)
+			fmt.Fprintf(stdout, "   Notifications: %d sent, %d failed", s.NotificationsSent, s.NotificationFailures)
+			if s.NotificationFailures > 0 {
+				fmt.Fprintf(stdout, " (%.0f%% failure rate)", s.FailureRate()*100)
+			}
+			fmt.Fprintln(stdout)
+			if s.MeanTimeBetweenFires > 0 {
+				fmt.Fprintf(stdout, "   Mean Time Between Fires: %s\n", s.MeanTimeBetweenFires.Round(time.Second))
+			}
+			if !s.LastFired.IsZero() {
+				fmt.Fprintf(stdout, "   Last Fired: %s\n", s.LastFired.Format("2006-01-02 15:04:05"))
+			}
+			fmt.Fprintln(stdout)
+		}
+
+		return nil
+	},
+}
+
+var alertsHistoryCmd = &cobra.Command{
+	Use:   "history",
+	Short: "List fired alerts, newest first",
+	Long: `List the alerts that have fired, with when they fired, the count that tripped
+the threshold, and whether they've been acknowledged or resolved.
+
+Examples:
+  peep alerts history                                # The last 50 alerts
+  peep alerts history --rule "High Errors" --since 7d
+  peep alerts history --unresolved                   # Still open or acknowledged
+  peep alerts history --since 24h --format json | jq '.[].rule_name'`,
+	RunE: runAlertsHistory,
+}
+
+// alertHistoryJSON is a fired alert with its state spelled out for scripts
+type alertHistoryJSON struct {
+	*alerts.AlertInstance
+	State string `json:"state"`
+}
+
+func runAlertsHistory(cmd *cobra.Command, args []string) error {
+	filter := alerts.InstanceFilter{}
+	filter.RuleName, _ = cmd.Flags().GetString("rule")
+	filter.Unresolved, _ = cmd.Flags().GetBool("unresolved")
+	filter.Limit, _ = cmd.Flags().GetInt("limit")
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseTimeFlag(since, time.Now())
+		if err != nil {
+			return fmt.Errorf("invalid --since %q: %w", since, err)
+		}
+		filter.Since = t
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format %q (use table or json)", format)
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+
+	instances, err := engine.ListAlertInstances(filter)
+	if err != nil {
+		return fmt.Errorf("failed to load alert history: %w", err)
+	}
+
+	if format == "json" {
+		out := make([]alertHistoryJSON, len(instances))
+		for i, instance := range instances {
+			out[i] = alertHistoryJSON{AlertInstance: instance, State: instance.State()}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(out)
+	}
+
+	if len(instances) == 0 {
+		fmt.Fprintln(stderr, "No alerts have fired that match")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tFIRED\tRULE\tCOUNT\tTHRESHOLD\tSTATE\tRESOLVED")
+	for _, instance := range instances {
+		resolved := "-"
+		if !instance.ResolvedAt.IsZero() {
+			resolved = instance.ResolvedAt.Local().Format("2006-01-02 15:04:05")
+		}
+		fmt.Fprintf(w, "%d\t%s\t%s\t%d\t%d\t%s\t%s\n",
+			instance.ID,
+			instance.FiredAt.Local().Format("2006-01-02 15:04:05"),
+			instance.RuleName,
+			instance.Count,
+			instance.Threshold,
+			instance.State(),
+			resolved,
+		)
+	}
+	return w.Flush()
+}
+
+var alertsCheckCmd = &cobra.Command{
+	Use:   "check",
+	Short: "Evaluate every enabled rule once and report which would fire",
+	Long: `Run each enabled rule's query over its window once and print the count, the
+threshold, and whether it would fire. Nothing is recorded unless --notify is
+given, which fires and resolves alerts exactly as the monitoring loop does.
+
+Exits non-zero when a rule's query fails, so CI can catch broken rules, and
+with --fail-on-fire also when any rule would fire.
+
+Examples:
+  peep alerts check                          # Dry run
+  peep alerts check --notify                 # From cron, instead of peep alerts start
+  peep alerts check --label team=payments --fail-on-fire
+  peep alerts check --format json`,
+	RunE: runAlertsCheck,
+}
+
+// ruleCheckJSON is one rule's result in peep alerts check --format json
+type ruleCheckJSON struct {
+	Rule      string   `json:"rule"`
+	Count     int      `json:"count"`
+	Threshold int      `json:"threshold"`
+	Metric    string   `json:"metric,omitempty"` // A metric rule's condition
+	Value     *float64 `json:"value,omitempty"`  // Its aggregated value
+	Window    string   `json:"window"`
+	Firing    bool     `json:"firing"`
+	Error     string   `json:"error,omitempty"`
+}
+
+func runAlertsCheck(cmd *cobra.Command, args []string) error {
+	notify, _ := cmd.Flags().GetBool("notify")
+	failOnFire, _ := cmd.Flags().GetBool("fail-on-fire")
+	selector, _ := cmd.Flags().GetString("label")
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" {
+		return fmt.Errorf("unsupported format %q (use table or json)", format)
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+
+	var results []alerts.RuleResult
+	for _, result := range engine.CheckRules(cmd.Context(), notify) {
+		if alerts.MatchLabels(result.Rule.Labels, selector) {
+			results = append(results, result)
+		}
+	}
+
+	failed, firing := 0, 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+		} else if result.Firing {
+			firing++
+		}
+	}
+
+	if format == "json" {
+		out := make([]ruleCheckJSON, len(results))
+		for i, result := range results {
+			out[i] = ruleCheckJSON{
+				Rule:      result.Rule.Name,
+				Count:     result.Count,
+				Threshold: result.Rule.Threshold,
+				Window:    result.Rule.Window,
+				Firing:    result.Firing,
+			}
+			if result.Rule.Metric != nil {
+				value := result.Value
+				out[i].Metric, out[i].Value = result.Rule.Metric.String(), &value
+			}
+			if result.Err != nil {
+				out[i].Error = result.Err.Error()
+			}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(out); err != nil {
+			return err
+		}
+	} else {
+		if len(results) == 0 {
+			fmt.Fprintln(stdout, "📭 No enabled alert rules to check.")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "RULE\tCOUNT\tTHRESHOLD\tWINDOW\tRESULT")
+		for _, result := range results {
+			status := "ok"
+			switch {
+			case result.Err != nil:
+				status = "error: " + result.Err.Error()
+			case result.Firing:
+				status = "FIRING"
+			}
+			count, threshold := strconv.Itoa(result.Count), strconv.Itoa(result.Rule.Threshold)
+			if metric := result.Rule.Metric; metric != nil {
+				count, threshold = alerts.FormatMetricValue(result.Value), metric.String()
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n",
+				result.Rule.Name, count, threshold, result.Rule.Window, status)
+		}
+		w.Flush()
+
+		fmt.Fprintf(stdout, "\n%d checked, %d firing, %d failed", len(results), firing, failed)
+		if notify {
+			fmt.Fprint(stdout, " (notifications sent for firing rules outside their cooldown)")
+		}
+		fmt.Fprintln(stdout)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d rule(s) failed to evaluate", failed)
+	}
+	if failOnFire && firing > 0 {
+		return fmt.Errorf("%d rule(s) firing", firing)
+	}
+	return nil
+}
+
 var alertsAddCmd = &cobra.Command{
 	Use:   "add [name] [query]",
 	Short: "Add a new alert rule",
@@ -73,29 +389,78 @@ var alertsAddCmd = &cobra.Command{
 
 The query should return a count that will be compared against the threshold.
 
+With --metric instead of a query, the rule watches a series in the metrics
+table, such as one from the StatsD listener or Peep's own telemetry. The
+series' samples in the window are aggregated (--aggregate: avg, min, max, sum,
+last, or count) and the rule fires when that is above --above or below
+--below. --metric-labels narrows the series by label, with the same selector
+syntax as channels' --match. A series with no samples in the window doesn't
+fire; use --aggregate count --below 1 to alert when one goes quiet.
+
+With --slo, the rule watches an SLO's error budget (see peep slo) and fires
+when it burns faster than --above over the window: 1 spends the budget
+exactly over the SLO's window, 14.4 spends 2% of a 30-day budget in an hour.
+Pair a fast rule (--above 14.4 --window 1h) with a slow one (--above 6
+--window 6h) to catch both sudden and steady burns.
+
+To be told about failures no rule anticipated, count pattern_anomalies: a
+row is added when a warning or error message pattern shows up for the first
+time, or again after alerts.dormant_days (default 7) without it (see peep
+patterns).
+
+Metric and SLO rules notify the same channels, with the same label matching,
+quiet hours, digests, and email templates, as rules on logs.
+
 Examples:
   peep alerts add "High Errors" "SELECT COUNT(*) FROM logs WHERE level='error'"
-  peep alerts add "DB Issues" "SELECT COUNT(*) FROM logs WHERE service='db' AND level='error'"`,
-	Args: cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+  peep alerts add "DB Issues" "SELECT COUNT(*) FROM logs WHERE service='db' AND level='error'"
+  peep alerts add "Payment Errors" "SELECT COUNT(*) FROM logs WHERE service='payments'" --label team=payments --label priority=high
+  peep alerts add "Slow API" --metric api.latency.p95 --above 250 --window 5m
+  peep alerts add "Queue Backlog" --metric queue.depth --aggregate max --above 1000 --metric-labels env=prod
+  peep alerts add "Ingest Stopped" --metric peep.ingest.eps --aggregate max --below 0.1 --window 15m
+  peep alerts add "Checkout Budget Burn" --slo checkout --above 14.4 --window 1h
+  peep alerts add "New Error Patterns" "SELECT COUNT(*) FROM pattern_anomalies WHERE level = 'error'"`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
 		name := args[0]
-		query := args[1]
 
 		threshold, _ := cmd.Flags().GetInt("threshold")
 		window, _ := cmd.Flags().GetString("window")
 		description, _ := cmd.Flags().GetString("description")
+		labelPairs, _ := cmd.Flags().GetStringSlice("label")
+
+		labels, err := alerts.ParseLabels(strings.Join(labelPairs, ","))
+		if err != nil {
+			return fmt.Errorf("invalid labels: %w", err)
+		}
+
+		metric, err := metricConditionFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+		var query string
+		switch {
+		case metric == nil && len(args) < 2:
+			return withHint(fmt.Errorf("a query, --metric, or --slo is required"),
+				"💡 e.g. peep alerts add \""+name+"\" \"SELECT COUNT(*) FROM logs WHERE level='error'\"")
+		case metric != nil && len(args) == 2:
+			return fmt.Errorf("give a query or --metric/--slo, not both")
+		case metric == nil:
+			query = args[1]
+		}
+		if _, err := time.ParseDuration(window); err != nil {
+			return fmt.Errorf("invalid --window %q: %w", window, err)
+		}
 
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
 
 		rule := &alerts.AlertRule{
@@ -105,19 +470,72 @@ Examples:
 			Threshold:   threshold,
 			Window:      window,
 			Enabled:     true,
+			Labels:      labels,
+			Metric:      metric,
 		}
 
 		if err := engine.AddRule(rule); err != nil {
-			fmt.Printf("❌ Error adding alert rule: %v\n", err)
-			return
+			return fmt.Errorf("failed to add alert rule: %w", err)
 		}
 
-		fmt.Printf("✅ Alert rule '%s' added successfully!\n", name)
-		fmt.Printf("   Query: %s\n", query)
-		fmt.Printf("   Threshold: %d events in %s\n", threshold, window)
+		fmt.Fprintf(stdout, "✅ Alert rule '%s' added successfully!\n", name)
+		if metric != nil {
+			fmt.Fprintf(stdout, "   Metric: %s over %s\n", metric, window)
+		} else {
+			fmt.Fprintf(stdout, "   Query: %s\n", query)
+			fmt.Fprintf(stdout, "   Threshold: %d events in %s\n", threshold, window)
+		}
+		if len(labels) > 0 {
+			fmt.Fprintf(stdout, "   Labels: %s\n", alerts.FormatLabels(labels))
+		}
+
+		return nil
 	},
 }
 
+// metricConditionFromFlags builds a metric rule's condition from peep alerts
+// add's flags, or returns nil when --metric isn't given
+func metricConditionFromFlags(cmd *cobra.Command) (*alerts.MetricCondition, error) {
+	name, _ := cmd.Flags().GetString("metric")
+	slo, _ := cmd.Flags().GetString("slo")
+	above, below := cmd.Flags().Changed("above"), cmd.Flags().Changed("below")
+	switch {
+	case name != "" && slo != "":
+		return nil, fmt.Errorf("give --metric or --slo, not both")
+	case slo != "":
+		if cmd.Flags().Changed("aggregate") || cmd.Flags().Changed("metric-labels") {
+			return nil, fmt.Errorf("--aggregate and --metric-labels don't apply to --slo")
+		}
+		if above == below {
+			return nil, withHint(fmt.Errorf("an SLO rule needs one of --above or --below"),
+				"💡 e.g. --slo "+slo+" --above 14.4 --window 1h, to page when 2% of a 30-day budget burns in an hour")
+		}
+	case name == "":
+		if above || below || cmd.Flags().Changed("aggregate") || cmd.Flags().Changed("metric-labels") {
+			return nil, fmt.Errorf("--above, --below, --aggregate, and --metric-labels need --metric or --slo")
+		}
+		return nil, nil
+	case above == below:
+		return nil, withHint(fmt.Errorf("a metric rule needs one of --above or --below"),
+			"💡 e.g. --metric "+name+" --above 250")
+	}
+
+	metric := &alerts.MetricCondition{Name: name, SLO: slo, Below: below}
+	if slo == "" {
+		metric.Aggregate, _ = cmd.Flags().GetString("aggregate")
+		metric.Labels, _ = cmd.Flags().GetString("metric-labels")
+	}
+	if above {
+		metric.Threshold, _ = cmd.Flags().GetFloat64("above")
+	} else {
+		metric.Threshold, _ = cmd.Flags().GetFloat64("below")
+	}
+	if err := metric.Validate(); err != nil {
+		return nil, err
+	}
+	return metric, nil
+}
+
 var alertsChannelsCmd = &cobra.Command{
 	Use:   "channels",
 	Short: "Manage notification channels",
@@ -126,28 +544,26 @@ var alertsChannelsCmd = &cobra.Command{
 var alertsChannelsListCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List notification channels",
-	Run: func(cmd *cobra.Command, args []string) {
-		store, err := storage.NewStorage("logs.db")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
 
 		channels := engine.GetChannels()
 		if len(channels) == 0 {
-			fmt.Println("� No notification channels configured.")
-			fmt.Println("� Add one with: peep alerts channels add slack \"Team Alerts\" --webhook https://hooks.slack.com/...")
-			return
+			fmt.Fprintln(stdout, "� No notification channels configured.")
+			fmt.Fprintln(stdout, "� Add one with: peep alerts channels add slack \"Team Alerts\" --webhook https://hooks.slack.com/...")
+			return nil
 		}
 
-		fmt.Printf("📢 Notification Channels (%d):\n\n", len(channels))
+		fmt.Fprintf(stdout, "📢 Notification Channels (%d):\n\n", len(channels))
 		for _, channel := range channels {
 			status := "🔴 Disabled"
 			if channel.Enabled {
@@ -155,18 +571,32 @@ var alertsChannelsListCmd = &cobra.Command{
 			}
 
 			icon := getChannelIcon(channel.Type)
-			fmt.Printf("%s %s %s (%s)\n", status, icon, channel.Name, channel.Type)
+			fmt.Fprintf(stdout, "%s %s %s (%s)\n", status, icon, channel.Name, channel.Type)
 
 			// Show relevant config (without sensitive data)
 			if channel.Type == "slack" {
 				if webhookURL, exists := channel.Config["webhook_url"]; exists && webhookURL != "" {
 					// Mask webhook URL for security
 					maskedURL := maskWebhookURL(webhookURL)
-					fmt.Printf("   Webhook: %s\n", maskedURL)
+					fmt.Fprintf(stdout, "   Webhook: %s\n", maskedURL)
+				}
+				if channel.Config["bot_token"] != "" {
+					fmt.Fprintln(stdout, "   Bot token: configured (threaded follow-ups)")
 				}
+				if slackChannel := channel.Config["channel"]; slackChannel != "" {
+					fmt.Fprintf(stdout, "   Channel: %s\n", slackChannel)
+				}
+			}
+			if selector := channel.Config["label_selector"]; selector != "" {
+				fmt.Fprintf(stdout, "   Routes: %s\n", selector)
+			}
+			if channel.Config["active_hours"] != "" || channel.Config["active_days"] != "" {
+				fmt.Fprintf(stdout, "   Active: %s %s (%s outside)\n", channel.Config["active_hours"], channel.Config["active_days"], channel.Config["quiet_action"])
 			}
-			fmt.Println()
+			fmt.Fprintln(stdout)
 		}
+
+		return nil
 	},
 }
 
@@ -177,30 +607,30 @@ var alertsChannelsAddCmd = &cobra.Command{
 
 Supported types:
   desktop - Desktop notifications
-  slack   - Slack webhook (requires --webhook flag)
+  slack   - Slack webhook or bot (requires --webhook, or --bot-token and --channel)
   email   - Email notifications (requires SMTP config)
   shell   - Execute shell script (requires script path)
 
 Examples:
   peep alerts channels add slack "Team Alerts" --webhook https://hooks.slack.com/services/...
   peep alerts channels add desktop "Local Notifications"
-  peep alerts channels add shell "Custom Handler" --script ./alert-handler.sh`,
+  peep alerts channels add shell "Custom Handler" --script ./alert-handler.sh
+  peep alerts channels add slack "Payments Team" --webhook https://hooks.slack.com/... --match team=payments
+  peep alerts channels add desktop "Work Hours" --active-hours 09:00-18:00 --active-days mon-fri --quiet defer`,
 	Args: cobra.ExactArgs(2),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		channelType := args[0]
 		name := args[1]
 
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
 
 		config := make(map[string]string)
@@ -209,12 +639,32 @@ Examples:
 		switch channelType {
 		case "slack":
 			webhook, _ := cmd.Flags().GetString("webhook")
-			if webhook == "" {
-				fmt.Println("❌ Slack channels require a webhook URL")
-				fmt.Println("💡 Use: --webhook https://hooks.slack.com/services/...")
-				return
+			botToken, _ := cmd.Flags().GetString("bot-token")
+			slackChannel, _ := cmd.Flags().GetString("channel")
+			peepURL, _ := cmd.Flags().GetString("peep-url")
+
+			if webhook == "" && botToken == "" {
+				return withHint(fmt.Errorf("slack channels require a webhook URL or bot token"),
+					"💡 Use: --webhook https://hooks.slack.com/services/...",
+					"💡 Or:  --bot-token xoxb-... --channel #alerts (enables threaded follow-ups)",
+				)
+			}
+			if botToken != "" && slackChannel == "" {
+				return withHint(fmt.Errorf("slack bot tokens require a channel"), "💡 Use: --channel #alerts")
+			}
+
+			if webhook != "" {
+				config["webhook_url"] = webhook
+			}
+			if botToken != "" {
+				config["bot_token"] = botToken
+			}
+			if slackChannel != "" {
+				config["channel"] = slackChannel
+			}
+			if peepURL != "" {
+				config["peep_url"] = peepURL
 			}
-			config["webhook_url"] = webhook
 
 		case "desktop":
 			// Desktop notifications don't need additional config
@@ -229,15 +679,40 @@ Examples:
 			fromName, _ := cmd.Flags().GetString("from-name")
 			toEmails, _ := cmd.Flags().GetString("to")
 
-			if smtpHost == "" || username == "" || password == "" || fromEmail == "" || toEmails == "" {
-				fmt.Println("❌ Email channels require SMTP configuration")
-				fmt.Println("💡 Required flags: --smtp-host, --username, --password, --from, --to")
-				fmt.Println("💡 Example: peep alerts channels add email \"Team Alerts\" \\")
-				fmt.Println("    --smtp-host smtp.gmail.com --smtp-port 587 \\")
-				fmt.Println("    --username your-email@gmail.com --password your-app-password \\")
-				fmt.Println("    --from your-email@gmail.com --from-name \"Peep Alerts\" \\")
-				fmt.Println("    --to team@company.com,admin@company.com")
-				return
+			security, _ := cmd.Flags().GetString("security")
+			auth, _ := cmd.Flags().GetString("auth")
+			caFile, _ := cmd.Flags().GetString("ca-file")
+			skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+
+			if smtpHost == "" || fromEmail == "" || toEmails == "" {
+				return withHint(fmt.Errorf("email channels require SMTP configuration"),
+					"💡 Required flags: --smtp-host, --from, --to (plus --username/--password unless the relay needs no auth)",
+					"💡 Example: peep alerts channels add email \"Team Alerts\" \\",
+					"    --smtp-host smtp.gmail.com --smtp-port 587 \\",
+					"    --username your-email@gmail.com --password your-app-password \\",
+					"    --from your-email@gmail.com --from-name \"Peep Alerts\" \\",
+					"    --to team@company.com,admin@company.com",
+				)
+			}
+
+			port, err := strconv.Atoi(smtpPort)
+			if err != nil || port <= 0 {
+				return fmt.Errorf("invalid SMTP port: %s", smtpPort)
+			}
+
+			// Validate security and auth settings up front rather than on the first alert
+			probe := notifications.NewEmailNotification(notifications.EmailConfig{
+				SMTPHost:   smtpHost,
+				SMTPPort:   port,
+				Username:   username,
+				Password:   password,
+				FromEmail:  fromEmail,
+				ToEmails:   strings.Split(toEmails, ","),
+				Security:   security,
+				AuthMethod: auth,
+			})
+			if err := probe.ValidateConfig(); err != nil {
+				return fmt.Errorf("invalid email configuration: %w", err)
 			}
 
 			config["smtp_host"] = smtpHost
@@ -247,6 +722,44 @@ Examples:
 			config["from_email"] = fromEmail
 			config["from_name"] = fromName
 			config["to_emails"] = toEmails
+			if security != "" {
+				config["security"] = security
+			}
+			if auth != "" {
+				config["auth"] = auth
+			}
+			if caFile != "" {
+				config["ca_file"] = caFile
+			}
+			if skipVerify {
+				config["skip_verify"] = "true"
+			}
+
+			// Optional templating and digest settings
+			htmlTemplate, _ := cmd.Flags().GetString("html-template")
+			textTemplate, _ := cmd.Flags().GetString("text-template")
+			subjectTemplate, _ := cmd.Flags().GetString("subject-template")
+			digest, _ := cmd.Flags().GetString("digest")
+			peepURL, _ := cmd.Flags().GetString("peep-url")
+
+			if digest != "" {
+				if _, err := time.ParseDuration(digest); err != nil {
+					return fmt.Errorf("invalid digest interval: %w", err)
+				}
+				config["digest_interval"] = digest
+			}
+			if htmlTemplate != "" {
+				config["html_template"] = htmlTemplate
+			}
+			if textTemplate != "" {
+				config["text_template"] = textTemplate
+			}
+			if subjectTemplate != "" {
+				config["subject_template"] = subjectTemplate
+			}
+			if peepURL != "" {
+				config["peep_url"] = peepURL
+			}
 
 		case "shell":
 			// Get shell script configuration from flags
@@ -257,11 +770,11 @@ Examples:
 			environment, _ := cmd.Flags().GetString("env")
 
 			if scriptPath == "" {
-				fmt.Println("❌ Shell channels require a script path")
-				fmt.Println("💡 Required flags: --script")
-				fmt.Println("💡 Example: peep alerts channels add shell \"Custom Webhook\" \\")
-				fmt.Println("    --script ./alert-handler.sh --timeout 30s")
-				return
+				return withHint(fmt.Errorf("shell channels require a script path"),
+					"💡 Required flags: --script",
+					"💡 Example: peep alerts channels add shell \"Custom Webhook\" \\",
+					"    --script ./alert-handler.sh --timeout 30s",
+				)
 			}
 
 			config["script_path"] = scriptPath
@@ -279,9 +792,32 @@ Examples:
 			}
 
 		default:
-			fmt.Printf("❌ Unknown channel type: %s\n", channelType)
-			fmt.Println("💡 Supported types: slack, desktop, email, shell")
-			return
+			return withHint(fmt.Errorf("unknown channel type: %s", channelType), "💡 Supported types: slack, desktop, email, shell")
+		}
+
+		// Only route alerts from rules whose labels match the selector
+		if match, _ := cmd.Flags().GetString("match"); match != "" {
+			config["label_selector"] = match
+		}
+
+		// Quiet hours: only deliver inside the active window
+		activeHours, _ := cmd.Flags().GetString("active-hours")
+		activeDays, _ := cmd.Flags().GetString("active-days")
+		timezone, _ := cmd.Flags().GetString("timezone")
+		quiet, _ := cmd.Flags().GetString("quiet")
+		if activeHours != "" || activeDays != "" {
+			if _, err := alerts.ParseSchedule(activeHours, activeDays, timezone); err != nil {
+				return fmt.Errorf("invalid quiet hours: %w", err)
+			}
+			if quiet != alerts.QuietSuppress && quiet != alerts.QuietDefer {
+				return fmt.Errorf("invalid --quiet value: %s (use suppress or defer)", quiet)
+			}
+			config["active_hours"] = activeHours
+			config["active_days"] = activeDays
+			config["quiet_action"] = quiet
+			if timezone != "" {
+				config["timezone"] = timezone
+			}
 		}
 
 		channel := &alerts.NotificationChannel{
@@ -292,16 +828,17 @@ Examples:
 		}
 
 		if err := engine.AddNotificationChannel(channel); err != nil {
-			fmt.Printf("❌ Error adding notification channel: %v\n", err)
-			return
+			return fmt.Errorf("failed to add notification channel: %w", err)
 		}
 
 		icon := getChannelIcon(channelType)
-		fmt.Printf("✅ %s %s channel '%s' added successfully!\n", icon, channelType, name)
+		fmt.Fprintf(stdout, "✅ %s %s channel '%s' added successfully!\n", icon, channelType, name)
 
 		if channelType == "slack" {
-			fmt.Println("� Test it with: peep alerts start")
+			fmt.Fprintln(stdout, "� Test it with: peep alerts start")
 		}
+
+		return nil
 	},
 }
 
@@ -341,18 +878,16 @@ var alertsStartCmd = &cobra.Command{
 	Long: `Start monitoring your logs for alert conditions in the background.
 	
 This will continuously check your alert rules and send notifications when thresholds are exceeded.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		store, err := storage.NewStorage("logs.db")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
 
 		rules := engine.GetRules()
@@ -364,16 +899,22 @@ This will continuously check your alert rules and send notifications when thresh
 		}
 
 		if enabledRules == 0 {
-			fmt.Println("⚠️  No enabled alert rules found!")
-			fmt.Println("💡 Add some rules first:")
-			fmt.Println("   peep alerts add \"High Errors\" \"SELECT COUNT(*) FROM logs WHERE level='error'\"")
-			return
+			return withHint(fmt.Errorf("no enabled alert rules found"),
+				"💡 Add some rules first:",
+				"   peep alerts add \"High Errors\" \"SELECT COUNT(*) FROM logs WHERE level='error'\"",
+			)
 		}
 
-		fmt.Printf("🚨 Starting alert monitoring with %d enabled rules...\n", enabledRules)
-		fmt.Println("📊 Checking every 30 seconds")
-		fmt.Println("Press Ctrl+C to stop")
+		fmt.Fprintf(stdout, "🚨 Starting alert monitoring with %d enabled rules...\n", enabledRules)
+		interval := cfg.Alerts.CheckInterval
+		if interval == 0 {
+			interval = alerts.DefaultCheckInterval
+		}
+		fmt.Fprintf(stdout, "📊 Checking every %s\n", interval)
+		fmt.Fprintln(stdout, "Press Ctrl+C to stop")
 
+		engine.SetCheckInterval(interval)
+		engine.SetPatternDormancy(cfg.Alerts.PatternDormancy())
 		engine.Start()
 		defer engine.Stop()
 
@@ -387,18 +928,53 @@ func init() {
 	alertsAddCmd.Flags().IntP("threshold", "t", 1, "Alert threshold (number of matching events)")
 	alertsAddCmd.Flags().StringP("window", "w", "5m", "Time window (e.g., 5m, 1h, 30s)")
 	alertsAddCmd.Flags().StringP("description", "d", "", "Alert rule description")
+	alertsAddCmd.Flags().StringSliceP("label", "l", []string{}, "Rule labels as key=value (repeatable or comma-separated)")
+	alertsAddCmd.Flags().String("metric", "", "Watch this series in the metrics table instead of running a query (e.g., api.latency.p95)")
+	alertsAddCmd.Flags().String("aggregate", "avg", "How the metric's samples in the window are combined: avg, min, max, sum, last, or count")
+	alertsAddCmd.Flags().Float64("above", 0, "Fire when the aggregated metric is above this")
+	alertsAddCmd.Flags().Float64("below", 0, "Fire when the aggregated metric is below this")
+	alertsAddCmd.Flags().String("metric-labels", "", "Only the metric's samples matching this label selector (e.g., service=api,env!=dev)")
+	alertsAddCmd.Flags().String("slo", "", "Watch this SLO's error budget burn rate over the window, with --above (e.g., --slo checkout --above 14.4 --window 1h)")
+
+	alertsListCmd.Flags().StringP("label", "l", "", "Only show rules matching a label selector (e.g., team=payments,priority!=low)")
+	alertsStatsCmd.Flags().StringP("period", "p", "", "Only count alerts fired within this period (e.g., 24h, 168h)")
+	alertsCheckCmd.Flags().Bool("notify", false, "Fire and resolve alerts and send notifications, as the monitoring loop does")
+	alertsCheckCmd.Flags().Bool("fail-on-fire", false, "Exit non-zero when any rule would fire")
+	alertsCheckCmd.Flags().StringP("label", "l", "", "Only check rules matching a label selector (e.g., team=payments)")
+	alertsCheckCmd.Flags().StringP("format", "f", "table", "Output format: table or json")
+	alertsHistoryCmd.Flags().StringP("rule", "r", "", "Only alerts from the rule with this name")
+	alertsHistoryCmd.Flags().String("since", "", "Only alerts fired after this time (e.g. 24h, 7d, or a timestamp)")
+	alertsHistoryCmd.Flags().Bool("unresolved", false, "Only alerts that haven't been resolved")
+	alertsHistoryCmd.Flags().IntP("limit", "l", 50, "Maximum number of alerts to show")
+	alertsHistoryCmd.Flags().StringP("format", "f", "table", "Output format: table or json")
 
 	// Add flags to the channels add command
-	alertsChannelsAddCmd.Flags().StringP("webhook", "", "", "Slack webhook URL (required for slack channels)")
+	alertsChannelsAddCmd.Flags().StringP("webhook", "", "", "Slack webhook URL (required for slack channels unless --bot-token is set)")
+	alertsChannelsAddCmd.Flags().StringP("bot-token", "", "", "Slack bot token; posts via chat.postMessage so follow-ups are threaded")
+	alertsChannelsAddCmd.Flags().StringP("channel", "", "", "Slack channel override (required with --bot-token)")
+	alertsChannelsAddCmd.Flags().StringP("peep-url", "", "", "Base URL of the Peep web UI linked from Slack and email alerts (default: http://localhost:8080)")
+	alertsChannelsAddCmd.Flags().StringP("match", "", "", "Only receive alerts from rules matching this label selector (e.g., team=payments)")
+	alertsChannelsAddCmd.Flags().StringP("active-hours", "", "", "Only deliver between these hours (e.g., 09:00-18:00)")
+	alertsChannelsAddCmd.Flags().StringP("active-days", "", "", "Only deliver on these days (e.g., mon-fri or sat,sun)")
+	alertsChannelsAddCmd.Flags().StringP("timezone", "", "", "Timezone for active hours (e.g., Europe/Berlin; default: local)")
+	alertsChannelsAddCmd.Flags().StringP("quiet", "", "suppress", "What to do with alerts outside active hours: suppress or defer (send as a digest later)")
 
 	// Email notification flags
 	alertsChannelsAddCmd.Flags().StringP("smtp-host", "", "", "SMTP server hostname (e.g., smtp.gmail.com)")
 	alertsChannelsAddCmd.Flags().StringP("smtp-port", "", "587", "SMTP server port (default: 587)")
 	alertsChannelsAddCmd.Flags().StringP("username", "", "", "SMTP username/email")
 	alertsChannelsAddCmd.Flags().StringP("password", "", "", "SMTP password (use app password for Gmail)")
+	alertsChannelsAddCmd.Flags().StringP("security", "", "", "Connection security: tls, starttls, or none (default: implicit TLS on 465, STARTTLS when offered)")
+	alertsChannelsAddCmd.Flags().StringP("auth", "", "", "SMTP auth mechanism: plain, login, cram-md5, or none (default: plain when --username is set)")
+	alertsChannelsAddCmd.Flags().StringP("ca-file", "", "", "PEM CA bundle used to verify the SMTP server")
+	alertsChannelsAddCmd.Flags().Bool("skip-verify", false, "Skip SMTP server certificate verification")
 	alertsChannelsAddCmd.Flags().StringP("from", "", "", "From email address")
 	alertsChannelsAddCmd.Flags().StringP("from-name", "", "Peep Alerts", "From display name")
 	alertsChannelsAddCmd.Flags().StringP("to", "", "", "Recipient email addresses (comma-separated)")
+	alertsChannelsAddCmd.Flags().StringP("html-template", "", "", "Path to a custom HTML email template")
+	alertsChannelsAddCmd.Flags().StringP("text-template", "", "", "Path to a custom plaintext email template")
+	alertsChannelsAddCmd.Flags().StringP("subject-template", "", "", "Custom email subject template (e.g., \"[{{.Severity}}] {{.Title}}\")")
+	alertsChannelsAddCmd.Flags().StringP("digest", "", "", "Batch alerts into one email per interval (e.g., 15m, 1h)")
 
 	// Shell script notification flags
 	alertsChannelsAddCmd.Flags().StringP("script", "", "", "Path to shell script (required for shell channels)")
@@ -411,6 +987,9 @@ func init() {
 
 	alertsCmd.AddCommand(alertsListCmd)
 	alertsCmd.AddCommand(alertsAddCmd)
+	alertsCmd.AddCommand(alertsStatsCmd)
+	alertsCmd.AddCommand(alertsHistoryCmd)
+	alertsCmd.AddCommand(alertsCheckCmd)
 	alertsCmd.AddCommand(alertsChannelsCmd)
 	alertsCmd.AddCommand(alertsStartCmd)
 }
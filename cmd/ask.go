@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/assistant"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var askCmd = &cobra.Command{
+	Use:   "ask QUESTION",
+	Short: "Ask a question in plain English and get the answer from a SQL query",
+	Long: `Translate a question into SQL with a language model, show the query, and run
+it read-only, like peep query.
+
+The model is any OpenAI-compatible chat completions API: OpenAI itself, or a
+local server such as Ollama, llama.cpp, or vLLM, so logs can stay on the
+machine. Only the question and the database schema are sent to the model,
+never log contents. Configure it in the config file:
+
+  ask:
+    endpoint: http://localhost:11434/v1   # Ollama
+    model: llama3.1
+
+or with PEEP_ASK_ENDPOINT, PEEP_ASK_MODEL, and PEEP_ASK_API_KEY.
+
+On a terminal, peep ask waits for you to confirm the query; --yes skips that.
+A query the database rejects is sent back to the model once to be fixed.
+
+Examples:
+  peep ask "show error spikes for the api service yesterday"
+  peep ask "which services logged the most warnings this week" --yes
+  peep ask --dry-run "slowest endpoints in the last hour"   # Just print the SQL
+  peep ask -f csv "errors per hour today" > errors.csv`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: runAsk,
+}
+
+func init() {
+	askCmd.Flags().StringP("format", "f", "table", "Output format: table, csv, or json")
+	askCmd.Flags().Duration("timeout", 30*time.Second, "Stop the query after this long")
+	askCmd.Flags().Bool("dry-run", false, "Print the SQL without running it")
+	askCmd.Flags().BoolP("yes", "y", false, "Run the query without asking first")
+}
+
+func runAsk(cmd *cobra.Command, args []string) error {
+	format, _ := cmd.Flags().GetString("format")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	dryRun, _ := cmd.Flags().GetBool("dry-run")
+	yes, _ := cmd.Flags().GetBool("yes")
+	question := strings.Join(args, " ")
+
+	out, err := newQueryWriter(format)
+	if err != nil {
+		return err
+	}
+
+	client, err := assistant.NewClient(assistant.Config{
+		Endpoint: cfg.Ask.Endpoint,
+		Model:    cfg.Ask.Model,
+		APIKey:   cfg.Ask.APIKey,
+		Timeout:  cfg.Ask.Timeout,
+	})
+	if err != nil {
+		return withHint(err,
+			"💡 Set ask.endpoint and ask.model in the config file, or e.g.\n"+
+				"   PEEP_ASK_ENDPOINT=http://localhost:11434/v1 PEEP_ASK_MODEL=llama3.1 peep ask ...")
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	schema, err := assistant.Schema(store.GetDB())
+	if err != nil {
+		return fmt.Errorf("failed to read the schema: %w", err)
+	}
+
+	fmt.Fprintln(stderr, "🤔 Asking the model...")
+	query, err := client.ToSQL(context.Background(), question, schema, nil)
+	if err != nil {
+		return err
+	}
+	// Preparing catches unknown columns and syntax errors without running anything
+	if prepareErr := checkQuery(store, query); prepareErr != nil {
+		fmt.Fprintf(stderr, "⚠️  The query failed (%v), asking the model to fix it...\n", prepareErr)
+		query, err = client.ToSQL(context.Background(), question, schema, &assistant.Attempt{SQL: query, Error: prepareErr.Error()})
+		if err != nil {
+			return err
+		}
+		if err := checkQuery(store, query); err != nil {
+			return fmt.Errorf("the model's query doesn't work: %w\n\n%s", err, query)
+		}
+	}
+
+	fmt.Fprintf(stderr, "\n%s\n\n", query)
+	if dryRun {
+		return nil
+	}
+	if !yes && isTerminal(os.Stdin) && isTerminal(os.Stderr) {
+		fmt.Fprint(stderr, "Run this query? [Y/n] ")
+		answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+		if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "" && answer != "y" && answer != "yes" {
+			return nil
+		}
+	}
+
+	return runRecordedQuery(store, query, out, timeout)
+}
+
+// checkQuery prepares a query to find errors the database would report
+func checkQuery(store *storage.Storage, query string) error {
+	stmt, err := store.GetDB().Prepare(query)
+	if err != nil {
+		return err
+	}
+	return stmt.Close()
+}
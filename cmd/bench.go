@@ -0,0 +1,385 @@
+package cmd
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"sync"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	benchLogs      int
+	benchSingle    int
+	benchBatchSize int
+	benchSources   int
+	benchRuns      int
+	benchDir       string
+	benchKeep      bool
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure ingest and query performance on this machine",
+	Long: `Fill a scratch database with generated logs and time what peep does most:
+
+  • Inserts: one log per commit (as peep ingest stores lines), batched, and
+    from several sources at once through the write buffer (--single x 10 logs)
+  • Dashboard and log viewer queries: stats, recent logs, filters, top errors
+  • Text search: substring, regular expression, and context field lookups
+
+Peep has no full-text index, so text searches read every message; their
+times grow with the number of logs, which is why they're worth measuring
+at the size you expect to keep.
+
+The scratch database goes in a temporary directory and is deleted afterwards;
+your own database is never touched. Use --dir to benchmark the disk your
+database lives on, since sync speed dominates single inserts.
+
+Examples:
+  peep bench                       # 100,000 logs
+  peep bench --logs 1000000        # Closer to a busy month
+  peep bench --dir ~/.peep --keep  # On the database's disk, keeping the result`,
+	RunE: runBench,
+}
+
+func init() {
+	benchCmd.Flags().IntVar(&benchLogs, "logs", 100000, "Logs to insert in batches, and to query")
+	benchCmd.Flags().IntVar(&benchSingle, "single", 2000, "Logs to insert one at a time")
+	benchCmd.Flags().IntVar(&benchBatchSize, "batch-size", 1000, "Logs per batched insert")
+	benchCmd.Flags().IntVar(&benchSources, "sources", 8, "Concurrent sources inserting through the write buffer")
+	benchCmd.Flags().IntVar(&benchRuns, "runs", 20, "Times to run each query")
+	benchCmd.Flags().StringVar(&benchDir, "dir", "", "Directory for the scratch database (default: the system temp directory)")
+	benchCmd.Flags().BoolVar(&benchKeep, "keep", false, "Keep the scratch database afterwards")
+}
+
+// benchQuery is one query timed by peep bench
+type benchQuery struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+func runBench(cmd *cobra.Command, args []string) error {
+	if benchLogs < 1 || benchSingle < 0 || benchBatchSize < 1 || benchSources < 1 || benchRuns < 1 {
+		return fmt.Errorf("--logs, --batch-size, --sources and --runs must be at least 1, and --single can't be negative")
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	dir, err := os.MkdirTemp(benchDir, "peep-bench-")
+	if err != nil {
+		return fmt.Errorf("failed to create scratch directory: %w", err)
+	}
+	dbPath := filepath.Join(dir, "bench.db")
+	if benchKeep {
+		defer fmt.Fprintf(stdout, "💾 Kept the scratch database at %s\n", dbPath)
+	} else {
+		defer os.RemoveAll(dir)
+	}
+
+	store, err := storage.NewStorage(dbPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	fmt.Fprintf(stdout, "⏱️  Benchmarking peep on %s/%s, %d CPUs\n", runtime.GOOS, runtime.GOARCH, runtime.NumCPU())
+	fmt.Fprintf(stdout, "   Scratch database: %s\n\n", dbPath)
+
+	gen := &demoGenerator{
+		rng:      rand.New(rand.NewSource(1)), // The same logs every run, so runs compare
+		services: []string{"api", "auth", "db", "worker", "payments"},
+	}
+	// Spread the logs over the last day, oldest first as they'd arrive
+	now := time.Now()
+	start := now.Add(-24 * time.Hour)
+	buffered := benchSingle * 10
+	step := 24 * time.Hour / time.Duration(benchLogs+benchSingle+buffered+1)
+	logs := 0
+	next := func() storage.LogEntry {
+		entry := gen.entry(start.Add(time.Duration(logs) * step))
+		logs++
+		return entry
+	}
+
+	out := tabwriter.NewWriter(stdout, 0, 0, 3, ' ', 0)
+	fmt.Fprintln(out, "Inserts\tlogs/sec\tp50\tp99\t")
+
+	if benchSingle > 0 {
+		fmt.Fprintf(stdout, "📥 Inserting %d logs one at a time...\n", benchSingle)
+		latencies := make([]time.Duration, 0, benchSingle)
+		began := time.Now()
+		for i := 0; i < benchSingle; i++ {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			entry := next()
+			t := time.Now()
+			if err := store.InsertLog(entry); err != nil {
+				return fmt.Errorf("failed to insert log: %w", err)
+			}
+			latencies = append(latencies, time.Since(t))
+		}
+		elapsed := time.Since(began)
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+		fmt.Fprintf(out, "  single, a commit per log\t%.0f\t%s\t%s\t\n",
+			float64(benchSingle)/elapsed.Seconds(),
+			formatLatency(percentile(latencies, 0.50)),
+			formatLatency(percentile(latencies, 0.99)))
+	}
+
+	fmt.Fprintf(stdout, "📥 Inserting %d logs in batches of %d...\n", benchLogs, benchBatchSize)
+	batch := make([]storage.LogEntry, 0, benchBatchSize)
+	latencies := make([]time.Duration, 0, benchLogs/benchBatchSize+1)
+	began := time.Now()
+	for inserted := 0; inserted < benchLogs; inserted += len(batch) {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		batch = batch[:0]
+		for len(batch) < benchBatchSize && inserted+len(batch) < benchLogs {
+			batch = append(batch, next())
+		}
+		t := time.Now()
+		if err := store.InsertLogs(batch); err != nil {
+			return fmt.Errorf("failed to insert logs: %w", err)
+		}
+		latencies = append(latencies, time.Since(t))
+	}
+	elapsed := time.Since(began)
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	fmt.Fprintf(out, "  batched, %d logs per commit\t%.0f\t%s\t%s\t\n",
+		benchBatchSize,
+		float64(benchLogs)/elapsed.Seconds(),
+		formatLatency(percentile(latencies, 0.50)),
+		formatLatency(percentile(latencies, 0.99)))
+
+	if buffered > 0 {
+		fmt.Fprintf(stdout, "📥 Inserting %d logs one at a time from %d sources through the write buffer...\n", buffered, benchSources)
+		if err := store.EnableWriteBuffer(storage.WriteBufferConfig{}); err != nil {
+			return err
+		}
+		entries := make([]storage.LogEntry, buffered)
+		for i := range entries {
+			entries[i] = next()
+		}
+		elapsed, latencies, err := insertConcurrently(ctx, store, entries, benchSources)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(out, "  write buffer, %d sources\t%.0f\t%s\t%s\t\n", benchSources,
+			float64(buffered)/elapsed.Seconds(),
+			formatLatency(percentile(latencies, 0.50)),
+			formatLatency(percentile(latencies, 0.99)))
+	}
+
+	// Let the planner see the data as it would after peep optimize
+	if err := store.Analyze(ctx); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	total := benchSingle + benchLogs + buffered
+	fmt.Fprintf(stdout, "🔍 Running each query %d times over %d logs...\n\n", benchRuns, total)
+	sections := []struct {
+		title   string
+		queries []benchQuery
+	}{
+		{"Dashboard and log viewer", benchViewerQueries(store, now)},
+		{"Text search (no full-text index: these read every log)", benchSearchQueries(store)},
+	}
+	for _, section := range sections {
+		fmt.Fprintf(out, "\t\t\t\t\n%s\tp50\tp95\tmax\t\n", section.title)
+		for _, query := range section.queries {
+			latencies, err := timeQuery(ctx, query, benchRuns)
+			if err != nil {
+				return fmt.Errorf("%s failed: %w", query.name, err)
+			}
+			fmt.Fprintf(out, "  %s\t%s\t%s\t%s\t\n", query.name,
+				formatLatency(percentile(latencies, 0.50)),
+				formatLatency(percentile(latencies, 0.95)),
+				formatLatency(latencies[len(latencies)-1]))
+		}
+	}
+	out.Flush()
+
+	// Measured in pages, as recent writes may still be in the write-ahead log
+	if stats, err := store.GetStats(ctx); err == nil {
+		fmt.Fprintf(stdout, "\n💽 Database size: %.1f MB for %d logs\n", float64(stats.SizeBytes)/(1024*1024), total)
+	}
+	return nil
+}
+
+// benchViewerQueries are the queries behind the web dashboard, the TUI and peep list
+func benchViewerQueries(store *storage.Storage, now time.Time) []benchQuery {
+	return []benchQuery{
+		{"dashboard stats", func(ctx context.Context) error {
+			_, err := store.GetStats(ctx)
+			return err
+		}},
+		{"newest 100 logs", func(ctx context.Context) error {
+			_, err := store.GetFilteredLogs(ctx, storage.LogFilter{}, 100)
+			return err
+		}},
+		{"newest 100 errors from one service", func(ctx context.Context) error {
+			_, err := store.GetFilteredLogs(ctx, storage.LogFilter{Levels: []string{"error"}, Service: "api"}, 100)
+			return err
+		}},
+		{"last hour's logs", func(ctx context.Context) error {
+			_, err := store.GetFilteredLogs(ctx, storage.LogFilter{Since: now.Add(-time.Hour)}, 1000)
+			return err
+		}},
+		{"services list", func(ctx context.Context) error {
+			_, err := store.GetServices(ctx)
+			return err
+		}},
+		{"top error messages, last 24h", func(ctx context.Context) error {
+			_, err := store.TopMessages(ctx, "error", now.Add(-24*time.Hour), 10)
+			return err
+		}},
+		{"logs per minute by level, last hour", func(ctx context.Context) error {
+			return store.EachRow(ctx, `
+				SELECT strftime('%Y-%m-%d %H:%M', timestamp) AS minute, level, COUNT(*)
+				FROM logs WHERE timestamp >= ?
+				GROUP BY minute, level`,
+				[]interface{}{now.Add(-time.Hour)}, drainRow)
+		}},
+	}
+}
+
+// benchSearchQueries are the text searches of peep search, the log viewer's
+// search box and SQL console queries on context fields
+func benchSearchQueries(store *storage.Storage) []benchQuery {
+	return []benchQuery{
+		{"substring, newest 100", func(ctx context.Context) error {
+			_, err := store.GetFilteredLogs(ctx, storage.LogFilter{Search: "timeout"}, 100)
+			return err
+		}},
+		{"substring with no match", func(ctx context.Context) error {
+			_, err := store.GetFilteredLogs(ctx, storage.LogFilter{Search: "no such message"}, 100)
+			return err
+		}},
+		{"regular expression, newest 100", func(ctx context.Context) error {
+			_, err := store.QueryLogs(ctx,
+				"SELECT * FROM logs WHERE message REGEXP ? ORDER BY timestamp DESC LIMIT 100",
+				`(?i)(timeout|refused)`)
+			return err
+		}},
+		{"context field, newest 100", func(ctx context.Context) error {
+			_, err := store.QueryLogs(ctx,
+				"SELECT * FROM logs WHERE json_extract(context, '$.user_id') = ? ORDER BY timestamp DESC LIMIT 100",
+				1234)
+			return err
+		}},
+		{"count by context field", func(ctx context.Context) error {
+			return store.EachRow(ctx, `
+				SELECT json_extract(context, '$.status') AS status, COUNT(*)
+				FROM logs GROUP BY status`,
+				nil, drainRow)
+		}},
+	}
+}
+
+// insertConcurrently inserts entries one at a time from the given number of
+// goroutines, as separate sources would, and returns how long that took and
+// each insert's latency, fastest first
+func insertConcurrently(ctx context.Context, store *storage.Storage, entries []storage.LogEntry, sources int) (time.Duration, []time.Duration, error) {
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		latencies = make([]time.Duration, 0, len(entries))
+		firstErr  error
+	)
+	began := time.Now()
+	for n := 0; n < sources; n++ {
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			own := make([]time.Duration, 0, len(entries)/sources+1)
+			var err error
+			for i := n; i < len(entries) && err == nil && ctx.Err() == nil; i += sources {
+				t := time.Now()
+				err = store.InsertLog(entries[i])
+				own = append(own, time.Since(t))
+			}
+			mu.Lock()
+			defer mu.Unlock()
+			latencies = append(latencies, own...)
+			if err != nil && firstErr == nil {
+				firstErr = fmt.Errorf("failed to insert log: %w", err)
+			}
+		}(n)
+	}
+	wg.Wait()
+	elapsed := time.Since(began)
+
+	if firstErr != nil {
+		return 0, nil, firstErr
+	}
+	if ctx.Err() != nil {
+		return 0, nil, ctx.Err()
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return elapsed, latencies, nil
+}
+
+// timeQuery runs query the given number of times and returns how long each
+// run took, fastest first
+func timeQuery(ctx context.Context, query benchQuery, runs int) ([]time.Duration, error) {
+	latencies := make([]time.Duration, 0, runs)
+	for i := 0; i < runs; i++ {
+		start := time.Now()
+		if err := query.run(ctx); err != nil {
+			return nil, err
+		}
+		latencies = append(latencies, time.Since(start))
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies, nil
+}
+
+// drainRow reads a row without keeping it, so a query is timed through its last row
+func drainRow(rows *sql.Rows) error {
+	return nil
+}
+
+// percentile returns the p-th percentile (0 to 1) of sorted latencies
+func percentile(sorted []time.Duration, p float64) time.Duration {
+	if len(sorted) == 0 {
+		return 0
+	}
+	i := int(p*float64(len(sorted))+0.5) - 1
+	if i < 0 {
+		i = 0
+	}
+	if i >= len(sorted) {
+		i = len(sorted) - 1
+	}
+	return sorted[i]
+}
+
+// formatLatency shows a duration to about three significant figures, e.g.
+// 84µs, 1.52ms, 230ms, 1.4s
+func formatLatency(d time.Duration) string {
+	switch {
+	case d < time.Millisecond:
+		return fmt.Sprintf("%dµs", d.Microseconds())
+	case d < 10*time.Millisecond:
+		return fmt.Sprintf("%.2fms", float64(d)/float64(time.Millisecond))
+	case d < time.Second:
+		return fmt.Sprintf("%.0fms", float64(d)/float64(time.Millisecond))
+	default:
+		return fmt.Sprintf("%.2fs", d.Seconds())
+	}
+}
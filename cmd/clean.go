@@ -1,12 +1,17 @@
 package cmd
 
 import (
-	"database/sql"
+	"context"
+	"errors"
 	"fmt"
+	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
+	"github.com/kylereynolds/peep/internal/schedule"
 	"github.com/kylereynolds/peep/internal/storage"
 	"github.com/spf13/cobra"
 )
@@ -17,6 +22,9 @@ var (
 	cleanLevels []string
 	cleanAll    bool
 	dryRun      bool
+	cleanVacuum bool
+
+	cleanSchedule string
 )
 
 var cleanCmd = &cobra.Command{
@@ -29,7 +37,25 @@ Examples:
   peep clean --keep-last 1000          # Keep only the 1000 most recent logs
   peep clean --levels info,debug       # Delete logs with specific levels
   peep clean --all                     # Delete all logs (with confirmation)
-  peep clean --older-than 30d --dry-run  # Show what would be deleted`,
+  peep clean --older-than 30d --dry-run  # Show what would be deleted
+  peep clean --older-than 30d --vacuum   # Also shrink the database file
+
+Logs are deleted 10,000 at a time, so ingestion and the web UI keep working
+during a large cleanup. Ctrl+C stops between batches, keeping what's deleted.
+
+SQLite reuses the space deleted logs leave behind, so the database file stops
+growing but doesn't shrink. --vacuum rebuilds it to give the space back; that
+rewrites the whole file and blocks ingestion until it's done.
+
+Scheduled cleanup:
+  With --schedule, peep clean keeps running and repeats the cleanup whenever
+  the cron expression matches, so no external cron job is needed:
+
+  peep clean --older-than 7d --schedule "0 3 * * *"     # Every day at 03:00
+  peep clean --levels debug --schedule @hourly
+  peep clean --keep-last 100000 --schedule "*/30 * * * *"
+
+  Fields are minute, hour, day of month, month, and day of week, in local time.`,
 	RunE: runClean,
 }
 
@@ -39,78 +65,140 @@ func init() {
 	cleanCmd.Flags().StringSliceVar(&cleanLevels, "levels", []string{}, "Delete logs with specific levels (comma-separated)")
 	cleanCmd.Flags().BoolVar(&cleanAll, "all", false, "Delete all logs (requires confirmation)")
 	cleanCmd.Flags().BoolVar(&dryRun, "dry-run", false, "Show what would be deleted without actually deleting")
+	cleanCmd.Flags().BoolVar(&cleanVacuum, "vacuum", false, "Rebuild the database afterwards to shrink the file (blocks writes while it runs)")
+	cleanCmd.Flags().StringVar(&cleanSchedule, "schedule", "", "Keep running and clean up on this cron schedule (e.g. \"0 3 * * *\" or @daily)")
 }
 
 func runClean(cmd *cobra.Command, args []string) error {
-	store, err := storage.NewStorage("logs.db")
+	if !cleanAll && olderThan == "" && keepLast <= 0 && len(cleanLevels) == 0 {
+		return fmt.Errorf("please specify a cleanup mode: --older-than, --keep-last, --levels, or --all")
+	}
+
+	var cron *schedule.Cron
+	if cleanSchedule != "" {
+		if cleanAll {
+			return fmt.Errorf("--all can't be scheduled; it asks for confirmation every time")
+		}
+		parsed, err := schedule.ParseCron(cleanSchedule)
+		if err != nil {
+			return err
+		}
+		cron = parsed
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
 
-	// Get the database handle (we'll need to add a method for this)
+	// Ctrl+C stops a cleanup between batches, keeping what's already deleted
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if cron == nil {
+		return cleanOnce(ctx, store)
+	}
+	return cleanOnSchedule(ctx, store, cron)
+}
+
+// cleanOnSchedule runs the cleanup each time the cron expression matches,
+// until interrupted. A failed run is reported and the next one still happens.
+func cleanOnSchedule(ctx context.Context, store *storage.Storage, cron *schedule.Cron) error {
+	fmt.Fprintf(stdout, "⏰ Scheduled cleanup: %s (Ctrl+C to stop)\n", cleanSchedule)
+	for {
+		next := cron.Next(time.Now())
+		if next.IsZero() {
+			return fmt.Errorf("schedule %q never runs", cleanSchedule)
+		}
+		fmt.Fprintf(stdout, "💤 Next cleanup at %s\n", next.Format("2006-01-02 15:04"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			fmt.Fprintln(stdout, "👋 Stopped scheduled cleanup")
+			return nil
+		case <-timer.C:
+		}
+
+		fmt.Fprintf(stdout, "🧹 Running cleanup at %s\n", time.Now().Format("2006-01-02 15:04:05"))
+		if err := cleanOnce(ctx, store); err != nil {
+			fmt.Fprintf(stderr, "❌ Cleanup failed: %v\n", err)
+		}
+	}
+}
+
+// cleanOnce deletes the logs selected by the cleanup mode flags
+func cleanOnce(ctx context.Context, store *storage.Storage) error {
 	db := store.GetDB()
 
 	// Count total logs before cleanup
 	var totalBefore int
-	err = db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalBefore)
+	err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalBefore)
 	if err != nil {
 		return fmt.Errorf("failed to count logs: %w", err)
 	}
 
 	if totalBefore == 0 {
-		fmt.Println("📭 No logs found in database")
+		fmt.Fprintln(stdout, "📭 No logs found in database")
 		return nil
 	}
 
-	fmt.Printf("📊 Found %d logs in database\n", totalBefore)
+	fmt.Fprintf(stdout, "📊 Found %d logs in database\n", totalBefore)
 
 	var deleted int
+	progress := &cleanProgress{live: isTerminal(os.Stdout)}
 
 	// Handle different cleanup modes
 	if cleanAll {
-		deleted, err = cleanAllLogs(db)
+		deleted, err = cleanAllLogs(ctx, store, progress)
 	} else if olderThan != "" {
-		deleted, err = cleanOlderThan(db, olderThan)
+		deleted, err = cleanOlderThan(ctx, store, olderThan, progress)
 	} else if keepLast > 0 {
-		deleted, err = cleanKeepLast(db, keepLast)
-	} else if len(cleanLevels) > 0 {
-		deleted, err = cleanByLevels(db, cleanLevels)
+		deleted, err = cleanKeepLast(ctx, store, keepLast, progress)
 	} else {
-		return fmt.Errorf("please specify a cleanup mode: --older-than, --keep-last, --levels, or --all")
+		deleted, err = cleanByLevels(ctx, store, cleanLevels, progress)
 	}
+	progress.done()
 
+	if errors.Is(err, context.Canceled) {
+		fmt.Fprintf(stdout, "⏹️  Stopped after deleting %d logs\n", deleted)
+		return nil
+	}
 	if err != nil {
 		return err
 	}
 
 	if dryRun {
-		fmt.Printf("🔍 [DRY RUN] Would delete %d logs\n", deleted)
-		fmt.Printf("📊 Would keep %d logs\n", totalBefore-deleted)
+		fmt.Fprintf(stdout, "🔍 [DRY RUN] Would delete %d logs\n", deleted)
+		fmt.Fprintf(stdout, "📊 Would keep %d logs\n", totalBefore-deleted)
 	} else {
-		fmt.Printf("🗑️  Deleted %d logs\n", deleted)
-		fmt.Printf("📊 %d logs remaining\n", totalBefore-deleted)
+		fmt.Fprintf(stdout, "🗑️  Deleted %d logs\n", deleted)
+		fmt.Fprintf(stdout, "📊 %d logs remaining\n", totalBefore-deleted)
+	}
 
-		// Vacuum the database to reclaim space
-		fmt.Println("🧹 Optimizing database...")
+	if cleanVacuum && !dryRun {
+		fmt.Fprintln(stdout, "🧹 Optimizing database...")
 		_, err = db.Exec("VACUUM")
 		if err != nil {
-			fmt.Printf("⚠️  Warning: Failed to vacuum database: %v\n", err)
+			fmt.Fprintf(stderr, "⚠️  Warning: Failed to vacuum database: %v\n", err)
 		} else {
-			fmt.Println("✅ Database optimized")
+			fmt.Fprintln(stdout, "✅ Database optimized")
 		}
 	}
 
 	return nil
 }
 
-func cleanAllLogs(db *sql.DB) (int, error) {
+func cleanAllLogs(ctx context.Context, store *storage.Storage, progress *cleanProgress) (int, error) {
+	db := store.GetDB()
 	if !dryRun {
-		fmt.Print("⚠️  This will delete ALL logs. Are you sure? (y/N): ")
+		fmt.Fprint(stdout, "⚠️  This will delete ALL logs. Are you sure? (y/N): ")
 		var response string
 		fmt.Scanln(&response)
 		if strings.ToLower(response) != "y" && strings.ToLower(response) != "yes" {
-			fmt.Println("❌ Cancelled")
+			fmt.Fprintln(stdout, "❌ Cancelled")
 			return 0, nil
 		}
 	}
@@ -121,20 +209,16 @@ func cleanAllLogs(db *sql.DB) (int, error) {
 		return count, err
 	}
 
-	result, err := db.Exec("DELETE FROM logs")
+	deleted, err := store.DeleteLogs(ctx, "1", nil, progress.report)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete logs: %w", err)
+		return int(deleted), fmt.Errorf("failed to delete logs: %w", err)
 	}
-
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
-	}
-
-	return int(rowsAffected), nil
+	return int(deleted), nil
 }
 
-func cleanOlderThan(db *sql.DB, duration string) (int, error) {
+func cleanOlderThan(ctx context.Context, store *storage.Storage, duration string, progress *cleanProgress) (int, error) {
+	db := store.GetDB()
+
 	// Parse duration
 	dur, err := parseDuration(duration)
 	if err != nil {
@@ -150,20 +234,15 @@ func cleanOlderThan(db *sql.DB, duration string) (int, error) {
 		return count, err
 	}
 
-	result, err := db.Exec("DELETE FROM logs WHERE timestamp < ?", cutoffStr)
-	if err != nil {
-		return 0, fmt.Errorf("failed to delete old logs: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	deleted, err := store.DeleteLogs(ctx, "timestamp < ?", []interface{}{cutoffStr}, progress.report)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return int(deleted), fmt.Errorf("failed to delete old logs: %w", err)
 	}
-
-	return int(rowsAffected), nil
+	return int(deleted), nil
 }
 
-func cleanKeepLast(db *sql.DB, keep int) (int, error) {
+func cleanKeepLast(ctx context.Context, store *storage.Storage, keep int, progress *cleanProgress) (int, error) {
+	db := store.GetDB()
 	if dryRun {
 		var total int
 		err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&total)
@@ -176,26 +255,16 @@ func cleanKeepLast(db *sql.DB, keep int) (int, error) {
 		return total - keep, nil
 	}
 
-	result, err := db.Exec(`
-		DELETE FROM logs 
-		WHERE id NOT IN (
-			SELECT id FROM logs 
-			ORDER BY timestamp DESC 
-			LIMIT ?
-		)`, keep)
-	if err != nil {
-		return 0, fmt.Errorf("failed to delete old logs: %w", err)
-	}
-
-	rowsAffected, err := result.RowsAffected()
+	deleted, err := store.DeleteOldestLogs(ctx, keep, progress.report)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return int(deleted), fmt.Errorf("failed to delete old logs: %w", err)
 	}
-
-	return int(rowsAffected), nil
+	return int(deleted), nil
 }
 
-func cleanByLevels(db *sql.DB, levels []string) (int, error) {
+func cleanByLevels(ctx context.Context, store *storage.Storage, levels []string, progress *cleanProgress) (int, error) {
+	db := store.GetDB()
+
 	// Build the WHERE clause for levels
 	placeholders := make([]string, len(levels))
 	args := make([]interface{}, len(levels))
@@ -212,18 +281,32 @@ func cleanByLevels(db *sql.DB, levels []string) (int, error) {
 		return count, err
 	}
 
-	query := fmt.Sprintf("DELETE FROM logs WHERE %s", whereClause)
-	result, err := db.Exec(query, args...)
+	deleted, err := store.DeleteLogs(ctx, whereClause, args, progress.report)
 	if err != nil {
-		return 0, fmt.Errorf("failed to delete logs by level: %w", err)
+		return int(deleted), fmt.Errorf("failed to delete logs by level: %w", err)
 	}
+	return int(deleted), nil
+}
 
-	rowsAffected, err := result.RowsAffected()
-	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+// cleanProgress shows the running total while logs are deleted in batches,
+// rewriting one line on a terminal. Piped output only gets the summary.
+type cleanProgress struct {
+	live    bool
+	printed bool
+}
+
+func (p *cleanProgress) report(deleted int64) {
+	if p.live {
+		p.printed = true
+		fmt.Fprintf(stdout, "\r🗑️  Deleting... %d logs so far", deleted)
 	}
+}
 
-	return int(rowsAffected), nil
+// done ends the progress line so the summary starts on its own
+func (p *cleanProgress) done() {
+	if p.printed {
+		fmt.Fprintln(stdout)
+	}
 }
 
 func parseDuration(s string) (time.Duration, error) {
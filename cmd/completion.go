@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+	"github.com/spf13/cobra/doc"
+)
+
+var completionCmd = &cobra.Command{
+	Use:   "completion [bash|zsh|fish|powershell]",
+	Short: "Generate a shell completion script",
+	Long: `Print a completion script for your shell. Besides commands and flags, it
+completes service names, alert rule names, and channel names from the
+database (honoring --db and --config).
+
+Bash:
+  source <(peep completion bash)
+  # Every session, on Linux:
+  peep completion bash > /etc/bash_completion.d/peep
+
+Zsh:
+  peep completion zsh > "${fpath[1]}/_peep"
+
+Fish:
+  peep completion fish > ~/.config/fish/completions/peep.fish
+
+PowerShell:
+  peep completion powershell | Out-String | Invoke-Expression`,
+	Args:                  cobra.ExactArgs(1),
+	ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+	DisableFlagsInUseLine: true,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		switch args[0] {
+		case "bash":
+			return rootCmd.GenBashCompletionV2(stdout, true)
+		case "zsh":
+			return rootCmd.GenZshCompletion(stdout)
+		case "fish":
+			return rootCmd.GenFishCompletion(stdout, true)
+		case "powershell":
+			return rootCmd.GenPowerShellCompletionWithDesc(stdout)
+		default:
+			return fmt.Errorf("unsupported shell %q (use bash, zsh, fish, or powershell)", args[0])
+		}
+	},
+}
+
+var manCmd = &cobra.Command{
+	Use:   "man [dir]",
+	Short: "Generate man pages",
+	Long: `Write a man page for every command into dir (default ./man), e.g.
+
+  peep man /usr/local/share/man/man1`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "man"
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+
+		header := &doc.GenManHeader{Title: "PEEP", Section: "1", Source: "Peep"}
+		if err := doc.GenManTree(rootCmd, header, dir); err != nil {
+			return fmt.Errorf("failed to generate man pages: %w", err)
+		}
+		fmt.Fprintf(stdout, "✅ Man pages written to %s\n", dir)
+		return nil
+	},
+}
+
+// logLevels are offered when completing level flags
+var logLevels = []string{"error", "warn", "info", "debug"}
+
+// completionNames runs a single-column query against the database for shell
+// completion, returning the values starting with toComplete. Completion must
+// never create a database, so a missing file completes nothing.
+func completionNames(cmd *cobra.Command, query, toComplete string) ([]string, cobra.ShellCompDirective) {
+	// Completion skips the PreRun hooks, so --db and --config haven't been read yet
+	if err := loadConfig(cmd, nil); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	if _, err := os.Stat(cfg.DBPath); err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	defer store.Close()
+
+	rows, err := store.GetDB().Query(query)
+	if err != nil {
+		return nil, cobra.ShellCompDirectiveNoFileComp // The alert tables may not exist yet
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if rows.Scan(&name) == nil && strings.HasPrefix(name, toComplete) {
+			names = append(names, name)
+		}
+	}
+	return names, cobra.ShellCompDirectiveNoFileComp
+}
+
+// completeServices completes service names that have logged
+func completeServices(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completionNames(cmd, "SELECT DISTINCT service FROM logs WHERE service IS NOT NULL AND service != '' ORDER BY service", toComplete)
+}
+
+// completeRuleNames completes alert rule names
+func completeRuleNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completionNames(cmd, "SELECT name FROM alert_rules ORDER BY name", toComplete)
+}
+
+// completeChannelNames completes notification channel names
+func completeChannelNames(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return completionNames(cmd, "SELECT name FROM notification_channels ORDER BY name", toComplete)
+}
+
+// completeLevels completes a comma-separated list of log levels
+func completeLevels(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+
+	var levels []string
+	for _, level := range logLevels {
+		if strings.HasPrefix(level, toComplete) {
+			levels = append(levels, prefix+level)
+		}
+	}
+	return levels, cobra.ShellCompDirectiveNoFileComp | cobra.ShellCompDirectiveNoSpace
+}
+
+// completeFixed completes one of a fixed set of values
+func completeFixed(values ...string) func(*cobra.Command, []string, string) ([]string, cobra.ShellCompDirective) {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return values, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// registerCompletions attaches the dynamic completions. It runs from Execute,
+// once every command's init has added its flags.
+func registerCompletions() {
+	rootCmd.RegisterFlagCompletionFunc("db", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"db", "sqlite", "sqlite3"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+	rootCmd.RegisterFlagCompletionFunc("config", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return []string{"yaml", "yml"}, cobra.ShellCompDirectiveFilterFileExt
+	})
+	rootCmd.RegisterFlagCompletionFunc("log-format", completeFixed("text", "json"))
+
+	for _, c := range []*cobra.Command{listCmd, searchCmd} {
+		c.RegisterFlagCompletionFunc("service", completeServices)
+		c.RegisterFlagCompletionFunc("level", completeLevels)
+	}
+	alertsHistoryCmd.RegisterFlagCompletionFunc("rule", completeRuleNames)
+	testChannelCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) > 0 {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		return completeChannelNames(cmd, args, toComplete)
+	}
+	alertsHistoryCmd.RegisterFlagCompletionFunc("format", completeFixed("table", "json"))
+	alertsCheckCmd.RegisterFlagCompletionFunc("format", completeFixed("table", "json"))
+	cleanCmd.RegisterFlagCompletionFunc("levels", completeLevels)
+	ingestCmd.RegisterFlagCompletionFunc("include-levels", completeLevels)
+	ingestCmd.RegisterFlagCompletionFunc("exclude-levels", completeLevels)
+
+	listCmd.RegisterFlagCompletionFunc("format", completeFixed("text", "json", "csv"))
+	searchCmd.RegisterFlagCompletionFunc("format", completeFixed("table", "json", "csv"))
+	queryCmd.RegisterFlagCompletionFunc("format", completeFixed("table", "csv", "json"))
+	tokensCreateCmd.RegisterFlagCompletionFunc("scope", completeFixed("ingest", "read", "admin"))
+	alertsChannelsAddCmd.RegisterFlagCompletionFunc("quiet", completeFixed("suppress", "defer"))
+	alertsChannelsAddCmd.ValidArgsFunction = func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		if len(args) == 0 {
+			return []string{"desktop", "slack", "email", "shell"}, cobra.ShellCompDirectiveNoFileComp
+		}
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+}
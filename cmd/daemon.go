@@ -3,98 +3,329 @@ package cmd
 import (
 	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"os"
 	"os/signal"
+	"strconv"
 	"syscall"
 	"time"
 
+	"github.com/kylereynolds/peep/internal/alerts"
 	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/kylereynolds/peep/internal/telemetry"
+	"github.com/kylereynolds/peep/internal/web"
 	"github.com/spf13/cobra"
 )
 
-var (
-	maxLogs     int
-	maxAgeDays  int
-	maxSizeMB   float64
-	checkMins   int
-	disableAuto bool
-)
-
 var daemonCmd = &cobra.Command{
 	Use:   "daemon",
 	Short: "Run Peep in daemon mode with automatic maintenance",
 	Long: `Run Peep as a background daemon with automatic log retention,
-alert monitoring, and health checks. Designed for production deployment.
+alert monitoring, the web UI, and health checks, all sharing one database
+handle. Designed for production deployment.
+
+The web UI and the alert engine are on by default; turn either off with
+--web=false or --alerts=false. The web flags (--port, --username, ...) are
+the same as for peep web.
+
+Logs can be pushed to the daemon over the web server's POST /api/ingest, or
+over listeners opened with --tcp (newline-delimited lines), --syslog (RFC 5424
+and RFC 3164 over UDP and TCP), and --http (POSTs, without the web UI). These
+can also be set in the ingest section of the config file. The TCP and syslog
+listeners have no authentication, so bind them to localhost or a trusted
+network. The HTTP listener binds localhost unless --http names a host, and
+with --http-require-token only takes POSTs carrying an ingest API token.
+
+With --statsd, the daemon also accepts StatsD metrics over UDP (DogStatsD tags
+included) and stores them in the metrics table every 10 seconds: counters as
+the interval's total and a per-second .rate, gauges as their latest value,
+timers as .count, .mean, .min, .max, .p50, .p90, .p95, and .p99, and sets as
+their number of distinct values. Chart them on a dashboard, or query them:
+  peep query "SELECT timestamp, value FROM metrics WHERE name = 'api.latency.p95'"
+
+With --otlp, it accepts OpenTelemetry traces over OTLP/HTTP (protobuf or
+JSON, at /v1/traces, the same as the web server's) into the spans table.
+Logs that carry the trace's ID in a trace_id context field are tied to it:
+  peep trace 4bf92f3577b34da6a3ce929d0e0e4736
 
 Examples:
   peep daemon                                    # Run with default settings
+  peep daemon --port 9090 --username admin --password secret
+  peep daemon --web=false                        # Retention and alerts only
+  peep daemon --web=false --alerts=false         # Retention and health checks only
+  peep daemon --syslog :5514 --tcp 127.0.0.1:5170
+  peep daemon --statsd :8125                     # Metrics from apps that already speak StatsD
+  peep daemon --otlp :4318                       # Traces from OpenTelemetry SDKs and collectors
+  peep daemon --web=false --http :5171           # Collect pushed logs without the UI
+  peep daemon --http 0.0.0.0:5171 --http-require-token  # From other hosts, with a token
   peep daemon --max-logs 50000                  # Keep max 50k logs
   peep daemon --max-age-days 7                  # Delete logs older than 7 days
   peep daemon --max-size-mb 100                 # Cleanup when DB > 100MB
   peep daemon --check-mins 5                    # Check every 5 minutes
-  peep daemon --disable-auto                    # Disable auto-cleanup`,
+  peep daemon --disable-auto                    # Disable auto-cleanup
+  peep daemon --detach                          # Run in the background
+  peep daemon status                            # Is it running?
+  peep daemon stop
+
+The daemon records its PID in a file next to the database (logs.db.pid, or
+--pid-file), which status, stop, and restart use to find it. With --detach it
+logs to logs.db.log, or --log-file.
+
+On Windows, peep install-service sets the daemon up as a native service: it
+starts and stops with the service (sc.exe start/stop, or services.msc) and
+logs to the Windows event log, under the service's name.
+
+Retention settings saved from the web UI's Settings page are used at startup
+and picked up while running; the retention section of the config file (or
+PEEP_RETENTION_* variables) and flags given on the command line override them.
+
+The daemon reloads its config file when it changes, or on SIGHUP: retention
+settings, parser patterns, ingest filters, derive rules, webhooks, exports,
+listener addresses, and the alert check interval take effect without a restart. Listeners whose address didn't
+change keep their connections. An invalid config is logged and ignored; the
+database path and web settings need a restart.
+
+The daemon records its own health every minute (--telemetry-interval) into the
+metrics table: ingest rate, insert latency, cleanups, notifications sent and
+failed, and memory. The "Peep health" dashboard in the web UI charts them, and
+they can be queried like logs, e.g.
+  peep query "SELECT timestamp, value FROM metrics WHERE name = 'peep.ingest.eps'"
+
+With --watchdog, the daemon notices when the alert engine has gone that long
+without checking its rules, or no new logs have arrived for that long. It
+sends a notice through --watchdog-channel (a notification channel's name) and
+restarts the stalled part: the alert engine's loop, or the ingestion
+listeners. It notifies again once the part recovers.
+  peep daemon --watchdog 15m --watchdog-channel ops-slack`,
 	RunE: runDaemon,
 }
 
 func init() {
-	daemonCmd.Flags().IntVar(&maxLogs, "max-logs", 100000, "Maximum number of logs to keep (0 = unlimited)")
-	daemonCmd.Flags().IntVar(&maxAgeDays, "max-age-days", 30, "Delete logs older than N days (0 = unlimited)")
-	daemonCmd.Flags().Float64Var(&maxSizeMB, "max-size-mb", 500, "Trigger cleanup when database exceeds size (0 = unlimited)")
-	daemonCmd.Flags().IntVar(&checkMins, "check-mins", 10, "Minutes between retention checks")
-	daemonCmd.Flags().BoolVar(&disableAuto, "disable-auto", false, "Disable automatic retention cleanup")
+	addDaemonFlags(daemonCmd)
+	daemonCmd.Flags().Bool("detach", false, "Run in the background, logging to --log-file")
+}
+
+// addDaemonFlags adds the flags shared by peep daemon and peep daemon restart
+func addDaemonFlags(cmd *cobra.Command) {
+	addRetentionFlags(cmd)
+	addWebFlags(cmd)
+	cmd.Flags().Bool("web", true, "Serve the web UI and API")
+	cmd.Flags().Bool("alerts", true, "Run the alert engine")
+	addListenerFlags(cmd)
+	cmd.Flags().Duration("watchdog", 0, "Notify and restart the alert engine or ingestion after this long without checks or new logs (0 = off)")
+	cmd.Flags().String("watchdog-channel", "", "Notification channel, by name, that the watchdog notifies (default log only)")
+	cmd.Flags().Duration("telemetry-interval", time.Minute, "How often to record Peep's own metrics (0 = off)")
+	cmd.Flags().String("log-file", "", "Where a background daemon logs (default the database path plus .log)")
+}
+
+// addRetentionFlags adds the auto-retention flags read by retentionConfigFromFlags
+func addRetentionFlags(cmd *cobra.Command) {
+	cmd.Flags().Int("max-logs", 100000, "Maximum number of logs to keep (0 = unlimited)")
+	cmd.Flags().Int("max-age-days", 30, "Delete logs older than N days (0 = unlimited)")
+	cmd.Flags().Float64("max-size-mb", 500, "Trigger cleanup when database exceeds size (0 = unlimited)")
+	cmd.Flags().Int("check-mins", 10, "Minutes between retention checks")
+	cmd.Flags().Bool("disable-auto", false, "Disable automatic retention cleanup")
+}
+
+// retentionConfigFromFlags layers the retention settings: saved settings first,
+// then the config file and environment, then any flags given explicitly
+func retentionConfigFromFlags(cmd *cobra.Command, store *storage.Storage) storage.RetentionConfig {
+	config, _, err := store.LoadRetentionConfig()
+	if err != nil {
+		slog.Warn("failed to load retention settings, using defaults", "error", err)
+		config = storage.DefaultRetentionConfig()
+	}
+	config = cfg.Retention.Apply(config)
+
+	flags := cmd.Flags()
+	if flags.Changed("max-logs") {
+		config.MaxLogs, _ = flags.GetInt("max-logs")
+	}
+	if flags.Changed("max-age-days") {
+		days, _ := flags.GetInt("max-age-days")
+		config.MaxAge = time.Duration(days) * 24 * time.Hour
+	}
+	if flags.Changed("max-size-mb") {
+		config.MaxSizeMB, _ = flags.GetFloat64("max-size-mb")
+	}
+	if flags.Changed("check-mins") {
+		mins, _ := flags.GetInt("check-mins")
+		config.CheckInterval = time.Duration(mins) * time.Minute
+	}
+	if disable, _ := flags.GetBool("disable-auto"); disable {
+		config.Enabled = false
+	}
+
+	if config.Enabled {
+		slog.Info("auto-retention configured", "max_logs", config.MaxLogs, "max_age", config.MaxAge.String(),
+			"max_size_mb", config.MaxSizeMB, "check_interval", config.CheckInterval.String())
+	} else {
+		slog.Warn("auto-retention disabled")
+	}
+	return config
 }
 
 func runDaemon(cmd *cobra.Command, args []string) error {
-	log.Println("🚀 Starting Peep daemon...")
+	if detach, _ := cmd.Flags().GetBool("detach"); detach {
+		return startDetached(cmd)
+	}
+
+	// Under the Windows service manager, stopping the service ends the daemon
+	if isWindowsService() {
+		return runWindowsService(cmd, func(ctx context.Context) error {
+			return serveDaemon(ctx, cmd)
+		})
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-sigChan
+		slog.Info("shutting down", "signal", sig.String())
+		cancel()
+	}()
+	return serveDaemon(ctx, cmd)
+}
+
+// serveDaemon runs the daemon until ctx is cancelled
+func serveDaemon(ctx context.Context, cmd *cobra.Command) error {
+	webEnabled, _ := cmd.Flags().GetBool("web")
+	alertsEnabled, _ := cmd.Flags().GetBool("alerts")
+	watchdogAfter, _ := cmd.Flags().GetDuration("watchdog")
+	watchdogChannel, _ := cmd.Flags().GetString("watchdog-channel")
+	if watchdogAfter != 0 && watchdogAfter < 10*time.Second {
+		return fmt.Errorf("--watchdog must be at least 10s")
+	}
+
+	pidPath := pidFilePath(cmd)
+	if err := checkNotRunning(pidPath); err != nil {
+		return err
+	}
+
+	slog.Info("daemon starting", "db", cfg.DBPath, "web", webEnabled, "alerts", alertsEnabled)
 
 	// Initialize storage
-	store, err := storage.NewStorage("logs.db")
+	store, err := storage.NewStorage(cfg.DBPath)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
 	defer store.Close()
+	store.SetQueryTimeout(cfg.QueryTimeout)
+	if err := enableWriteBuffer(store); err != nil {
+		return err
+	}
 
-	// Configure auto-retention if enabled
-	if !disableAuto {
-		config := storage.RetentionConfig{
-			MaxLogs:       maxLogs,
-			MaxAge:        time.Duration(maxAgeDays) * 24 * time.Hour,
-			MaxSizeMB:     maxSizeMB,
-			CheckInterval: time.Duration(checkMins) * time.Minute,
-			Enabled:       true,
+	config := retentionConfigFromFlags(cmd, store)
+
+	// The manager runs either way, so it can pick up settings changed from the web UI
+	store.EnableAutoRetention(config)
+
+	// The web UI manages rules and channels through the engine, and the
+	// watchdog notifies through it, even when it isn't running
+	var engine *alerts.Engine
+	if webEnabled || alertsEnabled || watchdogChannel != "" {
+		if engine, err = alerts.NewEngine(store); err != nil {
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
 		}
+	}
+	if watchdogChannel != "" {
+		if _, ok := engine.FindChannel(watchdogChannel); !ok {
+			return withHint(fmt.Errorf("notification channel %q not found", watchdogChannel),
+				"💡 List channels with: peep alerts channels list")
+		}
+	}
 
-		log.Printf("🧹 Configuring auto-retention:")
-		log.Printf("   Max logs: %d", config.MaxLogs)
-		log.Printf("   Max age: %v", config.MaxAge)
-		log.Printf("   Max size: %.1f MB", config.MaxSizeMB)
-		log.Printf("   Check interval: %v", config.CheckInterval)
+	var server *web.Server
+	var bind string
+	var port int
+	if webEnabled {
+		if server, bind, port, err = newWebServer(cmd, store, engine); err != nil {
+			return err
+		}
+	}
 
-		store.EnableAutoRetention(config)
-	} else {
-		log.Println("⚠️  Auto-retention disabled")
+	if alertsEnabled {
+		slog.Info("alert engine starting", "rules", len(engine.GetRules()))
+		engine.SetCheckInterval(cfg.Alerts.CheckInterval)
+		engine.SetPatternDormancy(cfg.Alerts.PatternDormancy())
+		engine.Start()
+		defer engine.Stop()
 	}
 
-	// Set up signal handling for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	listeners, err := startListeners(ctx, cmd, store)
+	if err != nil {
+		return err
+	}
+	exporters, err := startExporters(ctx, store)
+	if err != nil {
+		return err
+	}
+
+	// Written once startup can't fail on a taken port, which --detach waits for
+	if err := os.WriteFile(pidPath, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write PID file: %w", err)
+	}
+	defer os.Remove(pidPath)
 
 	// Start health monitoring
 	go healthMonitor(ctx, store)
 
-	// Wait for shutdown signal
-	sig := <-sigChan
-	log.Printf("📡 Received signal: %v", sig)
-	log.Println("🛑 Shutting down gracefully...")
+	if interval, _ := cmd.Flags().GetDuration("telemetry-interval"); interval > 0 {
+		if err := telemetry.EnsureDashboard(store); err != nil {
+			slog.Warn("failed to create the health dashboard", "error", err)
+		}
+		recorder := &telemetry.Recorder{Store: store, Interval: interval}
+		if alertsEnabled {
+			recorder.Engine = engine
+		}
+		go recorder.Run(ctx)
+	}
+
+	if watchdogAfter > 0 {
+		go newWatchdog(watchdogAfter, watchdogChannel, store, engine, alertsEnabled, listeners).Run(ctx, watchdogAfter)
+	}
+
+	go watchConfig(ctx, cmd, func() {
+		if err := reloadConfig(cmd); err != nil {
+			slog.Error("config reload failed, keeping the current settings", "error", err)
+			return
+		}
+		store.EnableAutoRetention(retentionConfigFromFlags(cmd, store))
+		store.SetQueryTimeout(cfg.QueryTimeout)
+		reloadFilterConfig()
+		if err := listeners.reload(cmd); err != nil {
+			slog.Error("failed to apply ingestion settings", "error", err)
+		}
+		if err := exporters.update(cfg.Export); err != nil {
+			slog.Error("failed to apply export settings", "error", err)
+		}
+		if server != nil {
+			if err := server.SetWebhooks(cfg.Hooks); err != nil {
+				slog.Error("failed to apply webhooks", "error", err)
+			}
+		}
+		if alertsEnabled {
+			engine.SetCheckInterval(cfg.Alerts.CheckInterval)
+			engine.SetPatternDormancy(cfg.Alerts.PatternDormancy())
+		}
+		slog.Info("config reloaded")
+	})
 
-	cancel()
+	if server != nil {
+		// Returns once the context is cancelled and in-flight requests finish
+		if err := server.Start(ctx, bind, port); err != nil {
+			return fmt.Errorf("web server error: %w", err)
+		}
+	} else {
+		<-ctx.Done()
+	}
 
 	// Give some time for cleanup
 	time.Sleep(2 * time.Second)
 
-	log.Println("✅ Daemon stopped")
+	slog.Info("daemon stopped")
 	return nil
 }
 
@@ -102,12 +333,12 @@ func healthMonitor(ctx context.Context, store *storage.Storage) {
 	ticker := time.NewTicker(1 * time.Minute)
 	defer ticker.Stop()
 
-	log.Println("💓 Starting health monitor...")
+	slog.Debug("health monitor started")
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("💓 Health monitor stopping...")
+			slog.Debug("health monitor stopping")
 			return
 		case <-ticker.C:
 			checkHealth(store)
@@ -120,7 +351,7 @@ func checkHealth(store *storage.Storage) {
 
 	// Check database connectivity
 	if err := db.Ping(); err != nil {
-		log.Printf("❌ Database health check failed: %v", err)
+		slog.Error("database health check failed", "error", err)
 		return
 	}
 
@@ -128,7 +359,7 @@ func checkHealth(store *storage.Storage) {
 	var logCount int
 	err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&logCount)
 	if err != nil {
-		log.Printf("⚠️  Failed to count logs: %v", err)
+		slog.Warn("failed to count logs", "error", err)
 		return
 	}
 
@@ -136,7 +367,7 @@ func checkHealth(store *storage.Storage) {
 	var recentCount int
 	err = db.QueryRow("SELECT COUNT(*) FROM logs WHERE timestamp > datetime('now', '-1 hour')").Scan(&recentCount)
 	if err != nil {
-		log.Printf("⚠️  Failed to count recent logs: %v", err)
+		slog.Warn("failed to count recent logs", "error", err)
 		return
 	}
 
@@ -148,8 +379,7 @@ func checkHealth(store *storage.Storage) {
 		alertCount = 0
 	}
 
-	log.Printf("💓 Health: %d total logs, %d in last hour, %d active alerts",
-		logCount, recentCount, alertCount)
+	slog.Info("health", "total_logs", logCount, "last_hour", recentCount, "active_rules", alertCount)
 
 	// Trigger retention check if needed
 	store.TriggerRetentionCheck()
@@ -0,0 +1,240 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+var daemonStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show whether the daemon is running",
+	Long: `Show whether a daemon is running for this database, from its PID file.
+Exits non-zero when it isn't, so scripts can check it.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonStatus,
+}
+
+var daemonStopCmd = &cobra.Command{
+	Use:   "stop",
+	Short: "Stop the running daemon",
+	Long: `Ask the daemon for this database to shut down, and wait for it to finish
+writing and exit.`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonStop,
+}
+
+var daemonRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "Stop the daemon and start it again in the background",
+	Long: `Stop the daemon for this database if it's running, then start it again in
+the background, as peep daemon --detach would.
+
+The new daemon gets the flags given to restart, not the old daemon's, so keep
+settings that should survive a restart in the config file.
+
+Examples:
+  peep daemon restart
+  peep daemon restart --db /var/lib/peep/logs.db --syslog :5514`,
+	Args: cobra.NoArgs,
+	RunE: runDaemonRestart,
+}
+
+func init() {
+	daemonCmd.PersistentFlags().String("pid-file", "", "PID file that status, stop, and restart find the daemon by (default the database path plus .pid)")
+	daemonStopCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for the daemon to exit")
+	addDaemonFlags(daemonRestartCmd)
+	daemonRestartCmd.Flags().Duration("timeout", 30*time.Second, "How long to wait for the old daemon to exit")
+
+	daemonCmd.AddCommand(daemonStatusCmd, daemonStopCmd, daemonRestartCmd)
+}
+
+// pidFilePath is where the daemon for the current database records its PID
+func pidFilePath(cmd *cobra.Command) string {
+	if path, _ := cmd.Flags().GetString("pid-file"); path != "" {
+		return path
+	}
+	return cfg.DBPath + ".pid"
+}
+
+// readPIDFile returns the PID recorded in path, or 0 if there's no file or
+// the process it names has exited
+func readPIDFile(path string) (int, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid PID file %s: %w", path, err)
+	}
+	if !processAlive(pid) {
+		return 0, nil
+	}
+	return pid, nil
+}
+
+// checkNotRunning fails if a daemon already has the PID file
+func checkNotRunning(path string) error {
+	pid, err := readPIDFile(path)
+	if err != nil {
+		return err
+	}
+	if pid != 0 {
+		return withHint(fmt.Errorf("peep daemon is already running (PID %d, from %s)", pid, path),
+			"💡 Stop it with: peep daemon stop, or restart it with: peep daemon restart")
+	}
+	return nil
+}
+
+func runDaemonStatus(cmd *cobra.Command, args []string) error {
+	path := pidFilePath(cmd)
+	pid, err := readPIDFile(path)
+	if err != nil {
+		return err
+	}
+	if pid == 0 {
+		return fmt.Errorf("peep daemon isn't running for %s", cfg.DBPath)
+	}
+
+	fmt.Fprintf(stdout, "✅ peep daemon is running (PID %d)\n", pid)
+	if info, err := os.Stat(path); err == nil {
+		fmt.Fprintf(stdout, "   Started:  %s (%s ago)\n", info.ModTime().Format("2006-01-02 15:04:05"),
+			time.Since(info.ModTime()).Round(time.Second))
+	}
+	fmt.Fprintf(stdout, "   Database: %s\n", cfg.DBPath)
+	fmt.Fprintf(stdout, "   PID file: %s\n", path)
+	return nil
+}
+
+func runDaemonStop(cmd *cobra.Command, args []string) error {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	stopped, err := stopDaemon(pidFilePath(cmd), timeout)
+	if err != nil {
+		return err
+	}
+	if !stopped {
+		fmt.Fprintf(stdout, "💤 peep daemon isn't running for %s\n", cfg.DBPath)
+	}
+	return nil
+}
+
+func runDaemonRestart(cmd *cobra.Command, args []string) error {
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	if _, err := stopDaemon(pidFilePath(cmd), timeout); err != nil {
+		return err
+	}
+	return startDetached(cmd)
+}
+
+// stopDaemon signals the daemon in the PID file and waits for it to exit. It
+// reports false if no daemon was running.
+func stopDaemon(path string, timeout time.Duration) (bool, error) {
+	pid, err := readPIDFile(path)
+	if err != nil || pid == 0 {
+		return false, err
+	}
+
+	if err := stopProcess(pid); err != nil {
+		return false, fmt.Errorf("failed to stop peep daemon (PID %d): %w", pid, err)
+	}
+	fmt.Fprintf(stdout, "⏳ Stopping peep daemon (PID %d)...\n", pid)
+
+	deadline := time.Now().Add(timeout)
+	for processAlive(pid) {
+		if time.Now().After(deadline) {
+			return true, withHint(fmt.Errorf("peep daemon (PID %d) didn't exit within %s", pid, timeout),
+				fmt.Sprintf("💡 Wait longer with --timeout, or force it with: kill -9 %d", pid))
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// The daemon removes it itself, unless it was killed
+	os.Remove(path)
+	fmt.Fprintln(stdout, "✅ peep daemon stopped")
+	return true, nil
+}
+
+// startDetached runs peep daemon again in the background with the same flags,
+// minus --detach, and waits for it to come up so startup errors aren't lost
+func startDetached(cmd *cobra.Command) error {
+	pidPath := pidFilePath(cmd)
+	if err := checkNotRunning(pidPath); err != nil {
+		return err
+	}
+
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to find the peep binary: %w", err)
+	}
+	args := []string{"daemon"}
+	cmd.Flags().Visit(func(f *pflag.Flag) {
+		// --detach and restart's --timeout aren't for the new daemon
+		if f.Name != "detach" && f.Name != "timeout" {
+			args = append(args, "--"+f.Name+"="+f.Value.String())
+		}
+	})
+
+	logPath, _ := cmd.Flags().GetString("log-file")
+	if logPath == "" {
+		logPath = cfg.DBPath + ".log"
+	}
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	defer logFile.Close()
+
+	child := exec.Command(exe, args...)
+	child.Stdout = logFile
+	child.Stderr = logFile
+	child.SysProcAttr = detachAttr()
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start peep daemon: %w", err)
+	}
+
+	exited := make(chan error, 1)
+	go func() { exited <- child.Wait() }()
+
+	// The daemon writes its PID once its listeners are bound; give the web
+	// server a moment more to bind its port too
+	ticker := time.NewTicker(100 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+	var settle <-chan time.Time
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return withHint(fmt.Errorf("peep daemon failed to start: %w", err),
+				fmt.Sprintf("💡 See its log in %s", logPath))
+		case <-ticker.C:
+			if settle == nil {
+				if pid, _ := readPIDFile(pidPath); pid == child.Process.Pid {
+					settle = time.After(time.Second)
+				}
+			}
+		case <-timeout:
+			return withHint(fmt.Errorf("peep daemon (PID %d) didn't start within 10s", child.Process.Pid),
+				fmt.Sprintf("💡 See its log in %s", logPath))
+		case <-settle:
+			fmt.Fprintf(stdout, "✅ peep daemon started (PID %d)\n", child.Process.Pid)
+			fmt.Fprintf(stdout, "   Log:      %s\n", logPath)
+			fmt.Fprintf(stdout, "   PID file: %s\n", pidPath)
+			fmt.Fprintln(stdout, "   Stop it with: peep daemon stop")
+			return nil
+		}
+	}
+}
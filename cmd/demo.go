@@ -0,0 +1,229 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var demoCmd = &cobra.Command{
+	Use:   "demo",
+	Short: "Generate realistic sample logs to explore Peep with",
+	Long: `Write fake but realistic logs into the database: a mix of levels, JSON
+context (request IDs, latencies, status codes, users), and an occasional error
+burst on one service, so the TUI, web UI, and alert rules have something to
+show before real sources are wired up.
+
+Examples:
+  peep demo                                         # 20 logs/sec until Ctrl-C
+  peep demo --rate 50 --services api,db,worker --duration 10m
+  peep demo --db demo.db --duration 2m & peep tui --db demo.db`,
+	RunE: runDemo,
+}
+
+func init() {
+	demoCmd.Flags().Int("rate", 20, "Logs per second")
+	demoCmd.Flags().StringSlice("services", []string{"api", "db", "worker"}, "Services to generate logs for (comma-separated)")
+	demoCmd.Flags().Duration("duration", 0, "Stop after this long (default: until interrupted)")
+	demoCmd.Flags().Float64("burst-chance", 0.02, "Chance per second that an error burst starts (0 disables bursts)")
+}
+
+// demoMessages are message templates per level. Services with their own set
+// use it; the rest use the "default" set.
+var demoMessages = map[string]map[string][]string{
+	"api": {
+		"info":  {"GET /api/users 200", "POST /api/orders 201", "GET /api/products 200", "GET /healthz 200", "PUT /api/users/profile 200"},
+		"debug": {"cache hit for session", "request headers parsed", "rate limiter allowed request"},
+		"warn":  {"slow request: GET /api/search", "rate limit approaching for client", "deprecated endpoint called: /api/v1/items"},
+		"error": {"POST /api/checkout 500", "upstream timeout calling payments", "connection refused to db:5432", "panic recovered in handler"},
+	},
+	"db": {
+		"info":  {"checkpoint complete", "connection opened", "autovacuum finished on orders"},
+		"debug": {"query plan cached", "prepared statement reused"},
+		"warn":  {"slow query took 1.2s", "connection pool 80% used", "replication lag above 5s"},
+		"error": {"deadlock detected", "too many connections", "disk write failed: no space left on device"},
+	},
+	"worker": {
+		"info":  {"job completed: send_email", "job completed: resize_image", "job enqueued: sync_inventory", "processed batch of 100 events"},
+		"debug": {"polling queue", "heartbeat sent"},
+		"warn":  {"job retrying: charge_card (attempt 2)", "queue depth above 1000"},
+		"error": {"job failed: charge_card", "job failed: send_email (smtp timeout)", "worker crashed, restarting"},
+	},
+	"default": {
+		"info":  {"request handled", "task finished", "configuration reloaded"},
+		"debug": {"tick", "state snapshot taken"},
+		"warn":  {"operation took longer than expected", "retrying after transient failure"},
+		"error": {"operation failed", "unexpected response from dependency"},
+	},
+}
+
+// demoGenerator makes fake log entries
+type demoGenerator struct {
+	rng      *rand.Rand
+	services []string
+
+	// While an error burst is on, one service logs mostly errors
+	burstService string
+	burstUntil   time.Time
+}
+
+// level picks a level: mostly info, some debug and warnings, a few errors, or
+// mostly errors for a service in a burst
+func (g *demoGenerator) level(service string, now time.Time) string {
+	if service == g.burstService && now.Before(g.burstUntil) && g.rng.Float64() < 0.6 {
+		return "error"
+	}
+	switch r := g.rng.Float64(); {
+	case r < 0.03:
+		return "error"
+	case r < 0.12:
+		return "warn"
+	case r < 0.27:
+		return "debug"
+	default:
+		return "info"
+	}
+}
+
+// maybeBurst starts an error burst on a random service with the given chance
+func (g *demoGenerator) maybeBurst(now time.Time, chance float64) {
+	if now.Before(g.burstUntil) || g.rng.Float64() >= chance {
+		return
+	}
+	g.burstService = g.services[g.rng.Intn(len(g.services))]
+	g.burstUntil = now.Add(time.Duration(5+g.rng.Intn(16)) * time.Second)
+	fmt.Fprintf(stdout, "💥 Error burst on %s until %s\n", g.burstService, g.burstUntil.Format("15:04:05"))
+}
+
+// entry makes one log entry as if a JSON line had been ingested
+func (g *demoGenerator) entry(now time.Time) storage.LogEntry {
+	service := g.services[g.rng.Intn(len(g.services))]
+	level := g.level(service, now)
+
+	messages, ok := demoMessages[service]
+	if !ok {
+		messages = demoMessages["default"]
+	}
+	choices := messages[level]
+	message := choices[g.rng.Intn(len(choices))]
+
+	durationMS := 2 + g.rng.Intn(250)
+	if level == "error" || level == "warn" {
+		durationMS = 500 + g.rng.Intn(4500)
+	}
+
+	fields := map[string]interface{}{
+		"timestamp":   now.Format(time.RFC3339Nano),
+		"level":       level,
+		"service":     service,
+		"message":     message,
+		"request_id":  fmt.Sprintf("req-%08x", g.rng.Uint32()),
+		"host":        fmt.Sprintf("%s-%d", service, 1+g.rng.Intn(3)),
+		"duration_ms": durationMS,
+	}
+	if strings.HasPrefix(message, "GET ") || strings.HasPrefix(message, "POST ") || strings.HasPrefix(message, "PUT ") {
+		parts := strings.Fields(message)
+		fields["method"], fields["path"], fields["status"] = parts[0], parts[1], parts[2]
+		fields["user_id"] = 1000 + g.rng.Intn(500)
+	}
+	if level == "error" {
+		fields["error"] = message
+	}
+
+	raw, _ := json.Marshal(fields)
+	return storage.LogEntry{
+		Timestamp: now,
+		Level:     level,
+		Message:   message,
+		Service:   service,
+		Context:   string(raw),
+		RawLog:    string(raw),
+	}
+}
+
+func runDemo(cmd *cobra.Command, args []string) error {
+	rate, _ := cmd.Flags().GetInt("rate")
+	services, _ := cmd.Flags().GetStringSlice("services")
+	duration, _ := cmd.Flags().GetDuration("duration")
+	burstChance, _ := cmd.Flags().GetFloat64("burst-chance")
+
+	if rate <= 0 {
+		return fmt.Errorf("--rate must be positive")
+	}
+	var names []string
+	for _, service := range services {
+		if service = strings.TrimSpace(service); service != "" {
+			names = append(names, service)
+		}
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("--services needs at least one service")
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+	if err := enableWriteBuffer(store); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if duration > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, duration)
+		defer cancel()
+	}
+
+	gen := &demoGenerator{rng: rand.New(rand.NewSource(time.Now().UnixNano())), services: names}
+
+	fmt.Fprintf(stdout, "🎪 Generating %d logs/sec for %s into %s", rate, strings.Join(names, ", "), cfg.DBPath)
+	if duration > 0 {
+		fmt.Fprintf(stdout, " for %s", duration)
+	}
+	fmt.Fprintln(stdout)
+	fmt.Fprintln(stdout, "💡 Explore with: peep tui, or peep web. Press Ctrl+C to stop")
+
+	// Write in batches ten times a second, so high rates don't need a tick per log
+	const ticksPerSecond = 10
+	ticker := time.NewTicker(time.Second / ticksPerSecond)
+	defer ticker.Stop()
+
+	written, tick := 0, 0
+	started := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Fprintf(stdout, "✅ Generated %d logs in %s\n", written, time.Since(started).Round(time.Second))
+			return nil
+		case now := <-ticker.C:
+			if tick%ticksPerSecond == 0 {
+				gen.maybeBurst(now, burstChance)
+			}
+			// Spread the remainder so the rate is exact over each second
+			n := rate / ticksPerSecond
+			if tick%ticksPerSecond < rate%ticksPerSecond {
+				n++
+			}
+			tick++
+
+			for i := 0; i < n; i++ {
+				if err := store.InsertLog(gen.entry(now)); err != nil {
+					return fmt.Errorf("failed to store log: %w", err)
+				}
+				written++
+			}
+		}
+	}
+}
@@ -3,9 +3,11 @@ package cmd
 import (
 	"bufio"
 	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/kylereynolds/peep/internal/ingestion"
 	"github.com/kylereynolds/peep/internal/storage"
@@ -17,6 +19,9 @@ var (
 	includeLevels   []string
 	excludePatterns []string
 	includePatterns []string
+
+	// filtersMu guards the filters against a config reload while listeners use them
+	filtersMu sync.RWMutex
 )
 
 var ingestCmd = &cobra.Command{
@@ -30,95 +35,139 @@ Examples:
   tail -f app.log | peep                           # Real-time ingestion
   docker logs myapp | peep --exclude-levels info,debug  # Skip noisy logs
   kubectl logs pod | peep --exclude-patterns "health.*check"`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Initialize storage
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
+		if err := enableWriteBuffer(store); err != nil {
+			return err
+		}
 
-		parser := &ingestion.LogParser{}
+		parser, err := ingestion.NewLogParser(cfg.Parser.Patterns)
+		if err != nil {
+			return err
+		}
 
-		if len(args) == 0 {
-			// Read from stdin
-			fmt.Println("📥 Reading logs from stdin...")
-			scanner := bufio.NewScanner(os.Stdin)
-			lineCount := 0
-			filteredCount := 0
-			for scanner.Scan() {
-				line := scanner.Text()
-				entry := parser.ParseLine(line)
-
-				// Apply filtering
-				if shouldSkipLog(entry, line) {
-					filteredCount++
-					continue
-				}
-
-				if err := store.InsertLog(entry); err != nil {
-					fmt.Printf("❌ Error storing log: %v\n", err)
-					continue
-				}
-
-				fmt.Printf("📝 [%d] %s | %s | %s\n", lineCount, entry.Level, entry.Service, entry.Message)
-				lineCount++
-			}
-			fmt.Printf("✅ Processed %d log lines", lineCount)
-			if filteredCount > 0 {
-				fmt.Printf(" (filtered %d)", filteredCount)
-			}
-			fmt.Println()
+		applyFilterConfig()
+
+		deriver, err := newDeriver(store)
+		if err != nil {
+			return err
+		}
 
-			// Trigger retention check after ingestion
-			store.TriggerRetentionCheck()
+		// Read from stdin, or from the file given
+		input, source := io.Reader(os.Stdin), ""
+		if len(args) == 0 {
+			fmt.Fprintln(stdout, "📥 Reading logs from stdin...")
 		} else {
-			// Read from file
-			filename := args[0]
-			fmt.Printf("📥 Ingesting logs from %s...\n", filename)
+			source = args[0]
+			fmt.Fprintf(stdout, "📥 Ingesting logs from %s...\n", source)
 
-			file, err := os.Open(filename)
+			file, err := os.Open(source)
 			if err != nil {
-				fmt.Printf("❌ Error opening file: %v\n", err)
-				return
+				return fmt.Errorf("failed to open file: %w", err)
 			}
 			defer file.Close()
+			input = file
+		}
+
+		scanner := bufio.NewScanner(input)
+		lineCount := 0
+		filteredCount := 0
+		failedCount := 0
+		for scanner.Scan() {
+			line := scanner.Text()
+			entry := parser.ParseLine(line)
+
+			// Apply filtering
+			if shouldSkipLog(entry, line) {
+				filteredCount++
+				continue
+			}
 
-			scanner := bufio.NewScanner(file)
-			lineCount := 0
-			filteredCount := 0
-			for scanner.Scan() {
-				line := scanner.Text()
-				entry := parser.ParseLine(line)
-
-				// Apply filtering
-				if shouldSkipLog(entry, line) {
-					filteredCount++
-					continue
-				}
-
-				if err := store.InsertLog(entry); err != nil {
-					fmt.Printf("❌ Error storing log: %v\n", err)
-					continue
-				}
-
-				fmt.Printf("📝 [%d] %s | %s | %s\n", lineCount, entry.Level, entry.Service, entry.Message)
-				lineCount++
+			// Keep going past a bad line, but report it and fail at the end
+			if err := store.InsertLog(entry); err != nil {
+				fmt.Fprintf(stderr, "❌ Error storing log: %v\n", err)
+				failedCount++
+				continue
 			}
-			fmt.Printf("✅ Processed %d log lines from %s", lineCount, filename)
-			if filteredCount > 0 {
-				fmt.Printf(" (filtered %d)", filteredCount)
+			if err := deriver.Observe(entry); err != nil {
+				fmt.Fprintf(stderr, "❌ Error storing derived metrics: %v\n", err)
 			}
-			fmt.Println()
 
-			// Trigger retention check after ingestion
-			store.TriggerRetentionCheck()
+			fmt.Fprintf(stdout, "📝 [%d] %s | %s | %s\n", lineCount, entry.Level, entry.Service, entry.Message)
+			lineCount++
 		}
+		fmt.Fprintf(stdout, "✅ Processed %d log lines", lineCount)
+		if source != "" {
+			fmt.Fprintf(stdout, " from %s", source)
+		}
+		if filteredCount > 0 {
+			fmt.Fprintf(stdout, " (filtered %d)", filteredCount)
+		}
+		fmt.Fprintln(stdout)
+
+		// Trigger retention check after ingestion
+		store.TriggerRetentionCheck()
+
+		if err := scanner.Err(); err != nil {
+			return fmt.Errorf("failed to read logs: %w", err)
+		}
+		if failedCount > 0 {
+			return fmt.Errorf("failed to store %d log lines", failedCount)
+		}
+
+		return nil
 	},
 }
 
+// applyFilterConfig fills in the ingest filters from the config file, unless given as flags
+func applyFilterConfig() {
+	if len(excludeLevels) == 0 {
+		excludeLevels = cfg.Filters.ExcludeLevels
+	}
+	if len(includeLevels) == 0 {
+		includeLevels = cfg.Filters.IncludeLevels
+	}
+	if len(excludePatterns) == 0 {
+		excludePatterns = cfg.Filters.ExcludePatterns
+	}
+	if len(includePatterns) == 0 {
+		includePatterns = cfg.Filters.IncludePatterns
+	}
+}
+
+// reloadFilterConfig replaces the filters with the config file's, for a
+// long-running process that has no filter flags
+func reloadFilterConfig() {
+	filtersMu.Lock()
+	defer filtersMu.Unlock()
+	excludeLevels = cfg.Filters.ExcludeLevels
+	includeLevels = cfg.Filters.IncludeLevels
+	excludePatterns = cfg.Filters.ExcludePatterns
+	includePatterns = cfg.Filters.IncludePatterns
+}
+
+// enableWriteBuffer turns on store's write buffer if the config asks for it
+func enableWriteBuffer(store *storage.Storage) error {
+	if !cfg.WriteBuffer.Enabled {
+		return nil
+	}
+	return store.EnableWriteBuffer(cfg.WriteBuffer.Storage())
+}
+
+// newDeriver builds the config's derive rules, storing what they extract in store
+func newDeriver(store *storage.Storage) (*ingestion.Deriver, error) {
+	return ingestion.NewDeriver(cfg.Derive, store.InsertMetrics)
+}
+
 func shouldSkipLog(entry storage.LogEntry, rawLine string) bool {
+	filtersMu.RLock()
+	defer filtersMu.RUnlock()
+
 	// Check exclude levels
 	if len(excludeLevels) > 0 {
 		for _, level := range excludeLevels {
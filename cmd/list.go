@@ -1,8 +1,15 @@
 package cmd
 
 import (
+	"bufio"
+	"context"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/kylereynolds/peep/internal/storage"
 	"github.com/spf13/cobra"
@@ -11,44 +18,87 @@ import (
 var listCmd = &cobra.Command{
 	Use:   "list",
 	Short: "List recent logs from the database",
-	Long:  `Display the most recent logs stored in the SQLite database.`,
-	Run: func(cmd *cobra.Command, args []string) {
+	Long: `Display the most recent logs stored in the SQLite database.
+
+Filter with --level, --service, --since, and --until. Times are either a
+duration ago (30m, 2h, 7d) or a timestamp (2024-01-02T15:04:05Z,
+"2024-01-02 15:04", 2024-01-02):
+
+  peep list --level error --service api --since 1h
+  peep list --since 2024-01-02 --until 2024-01-03 --limit 500
+
+Formats: text (default), json, csv. The json and csv formats print every
+field of each log, including its context and raw line, for scripts:
+
+  peep list --format json | jq '.[] | select(.service == "api")'
+  peep list --format csv --limit 1000 > recent.csv
+  peep list --format csv --limit 0 --since 7d > week.csv  # Every match, streamed`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		format, _ := cmd.Flags().GetString("format")
+		if format != "text" && format != "json" && format != "csv" {
+			return fmt.Errorf("unsupported format %q (use text, json, or csv)", format)
+		}
+
 		// Initialize storage
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
+		filter, err := logFilterFromFlags(cmd)
+		if err != nil {
+			return err
+		}
+
 		limit, _ := cmd.Flags().GetInt("limit")
-		logs, err := store.GetLogs(limit)
+		if format != "text" {
+			return writeLogs(cmd.Context(), store, filter, limit, format)
+		}
+		if limit <= 0 {
+			return fmt.Errorf("--limit 0 is only for the json and csv formats")
+		}
+
+		logs, err := store.GetFilteredLogs(cmd.Context(), filter, limit)
 		if err != nil {
-			fmt.Printf("❌ Error retrieving logs: %v\n", err)
-			return
+			return fmt.Errorf("failed to retrieve logs: %w", err)
 		}
 
+		if len(logs) == 0 && (filter.Service != "" || len(filter.Levels) > 0 || !filter.Since.IsZero() || !filter.Before.IsZero()) {
+			fmt.Fprintln(stdout, "📭 No logs match those filters.")
+			return nil
+		}
 		if len(logs) == 0 {
-			fmt.Println("📭 No logs found. Try ingesting some logs first!")
-			fmt.Println("Example: echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
-			return
+			fmt.Fprintln(stdout, "📭 No logs found. Try ingesting some logs first!")
+			fmt.Fprintln(stdout, "Example: echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
+			return nil
 		}
 
-		fmt.Printf("📋 Recent logs (showing %d):\n\n", len(logs))
+		fmt.Fprintf(stdout, "📋 Recent logs (showing %d):\n\n", len(logs))
 
 		for _, log := range logs {
 			levelIcon := getLevelIcon(log.Level)
-			fmt.Printf("%s %s [%s] %s\n",
+			fmt.Fprintf(stdout, "%s %s [%s] %s\n",
 				levelIcon,
 				log.Timestamp.Format("15:04:05"),
 				log.Service,
 				log.Message,
 			)
 		}
+
+		return nil
 	},
 }
 
 func getLevelIcon(level string) string {
+	// Without emoji, spell the level out so it isn't lost
+	if plainOutput {
+		if level == "" {
+			level = "-"
+		}
+		return fmt.Sprintf("%-5s", strings.ToUpper(level))
+	}
+
 	switch strings.ToLower(level) {
 	case "error", "err":
 		return "🔴"
@@ -63,6 +113,166 @@ func getLevelIcon(level string) string {
 	}
 }
 
+// addLogFilterFlags adds the --level, --service, --since, and --until flags
+// read by logFilterFromFlags
+func addLogFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringP("level", "L", "", "Only these levels (comma-separated, e.g. error,warn)")
+	cmd.Flags().StringP("service", "s", "", "Only logs from this service")
+	cmd.Flags().String("since", "", "Only logs at or after this time (e.g. 30m, 2h, 7d, or a timestamp)")
+	cmd.Flags().String("until", "", "Only logs before this time (e.g. 1h, or a timestamp)")
+}
+
+// logFilterFromFlags builds a log filter from the flags added by addLogFilterFlags
+func logFilterFromFlags(cmd *cobra.Command) (storage.LogFilter, error) {
+	var filter storage.LogFilter
+	filter.Service, _ = cmd.Flags().GetString("service")
+	if levels, _ := cmd.Flags().GetString("level"); levels != "" {
+		for _, level := range strings.Split(levels, ",") {
+			if level = strings.ToLower(strings.TrimSpace(level)); level != "" {
+				filter.Levels = append(filter.Levels, level)
+			}
+		}
+	}
+
+	now := time.Now()
+	if since, _ := cmd.Flags().GetString("since"); since != "" {
+		t, err := parseTimeFlag(since, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --since %q: %w", since, err)
+		}
+		filter.Since = t
+	}
+	if until, _ := cmd.Flags().GetString("until"); until != "" {
+		t, err := parseTimeFlag(until, now)
+		if err != nil {
+			return filter, fmt.Errorf("invalid --until %q: %w", until, err)
+		}
+		filter.Before = t
+	}
+	if !filter.Since.IsZero() && !filter.Before.IsZero() && !filter.Since.Before(filter.Before) {
+		return filter, fmt.Errorf("--since must be earlier than --until")
+	}
+	return filter, nil
+}
+
+// timeFlagLayouts are the absolute time forms accepted by parseTimeFlag, in local time
+// unless they carry a zone
+var timeFlagLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// parseTimeFlag reads a time given either as a duration before now or as a timestamp
+func parseTimeFlag(value string, now time.Time) (time.Time, error) {
+	if d, err := parseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	for _, layout := range timeFlagLayouts {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("want a duration like 2h or 7d, or a time like 2006-01-02 15:04")
+}
+
+// writeLogs streams the logs matching filter to stdout in the json or csv
+// format, writing each as it's read so exports of any size fit in memory
+func writeLogs(ctx context.Context, store *storage.Storage, filter storage.LogFilter, limit int, format string) error {
+	out := newLogWriter(stdout, format)
+	if err := store.EachFilteredLog(ctx, filter, limit, out.Write); err != nil {
+		return fmt.Errorf("failed to retrieve logs: %w", err)
+	}
+	return out.Close()
+}
+
+// logWriter writes full log entries one at a time
+type logWriter interface {
+	Write(log storage.LogEntry) error
+	Close() error
+}
+
+// newLogWriter returns the writer for the json or csv format
+func newLogWriter(w io.Writer, format string) logWriter {
+	if format == "json" {
+		return &logJSONWriter{w: bufio.NewWriter(w)}
+	}
+	return &logCSVWriter{w: csv.NewWriter(w)}
+}
+
+// logJSONWriter writes an indented JSON array of full log entries
+type logJSONWriter struct {
+	w    *bufio.Writer
+	logs int
+}
+
+func (j *logJSONWriter) Write(log storage.LogEntry) error {
+	data, err := json.MarshalIndent(log, "  ", "  ")
+	if err != nil {
+		return err
+	}
+
+	separator := "[\n  "
+	if j.logs > 0 {
+		separator = ",\n  "
+	}
+	j.logs++
+	j.w.WriteString(separator)
+	_, err = j.w.Write(data)
+	return err
+}
+
+func (j *logJSONWriter) Close() error {
+	if j.logs == 0 {
+		j.w.WriteString("[]\n") // An empty array, not null
+	} else {
+		j.w.WriteString("\n]\n")
+	}
+	return j.w.Flush()
+}
+
+// logCSVHeader names the columns written by logCSVWriter, matching the JSON keys
+var logCSVHeader = []string{"id", "timestamp", "level", "message", "service", "context", "raw_log", "created_at"}
+
+// logCSVWriter writes logs as CSV with a header row
+type logCSVWriter struct {
+	w       *csv.Writer
+	started bool
+}
+
+func (c *logCSVWriter) Write(log storage.LogEntry) error {
+	if !c.started {
+		c.started = true
+		if err := c.w.Write(logCSVHeader); err != nil {
+			return err
+		}
+	}
+	return c.w.Write([]string{
+		strconv.FormatInt(log.ID, 10),
+		log.Timestamp.Format(time.RFC3339Nano),
+		log.Level,
+		log.Message,
+		log.Service,
+		log.Context,
+		log.RawLog,
+		log.CreatedAt.Format(time.RFC3339Nano),
+	})
+}
+
+func (c *logCSVWriter) Close() error {
+	if !c.started {
+		c.started = true
+		c.w.Write(logCSVHeader)
+	}
+	c.w.Flush()
+	return c.w.Error()
+}
+
 func init() {
-	listCmd.Flags().IntP("limit", "l", 20, "Number of recent logs to display")
+	listCmd.Flags().IntP("limit", "l", 20, "Number of recent logs to display (0 for all, with json or csv)")
+	listCmd.Flags().StringP("format", "f", "text", "Output format: text, json, or csv")
+	addLogFilterFlags(listCmd)
 }
@@ -0,0 +1,48 @@
+package cmd
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/spf13/cobra"
+)
+
+// logLevel is the level set by --quiet and --verbose, for handlers set up
+// later, like the Windows event log's
+var logLevel = slog.LevelInfo
+
+// setupLogging points the default slog logger, which Peep's packages log
+// through, at stderr with the level and format from the global flags. Command
+// output meant for people and scripts (tables, JSON results) stays on stdout.
+func setupLogging(cmd *cobra.Command) error {
+	quiet, _ := cmd.Flags().GetBool("quiet")
+	verbose, _ := cmd.Flags().GetBool("verbose")
+	format, _ := cmd.Flags().GetString("log-format")
+
+	if quiet && verbose {
+		return fmt.Errorf("--quiet and --verbose can't be used together")
+	}
+
+	level := slog.LevelInfo
+	switch {
+	case quiet:
+		level = slog.LevelWarn
+	case verbose:
+		level = slog.LevelDebug
+	}
+
+	logLevel = level
+	options := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler
+	switch format {
+	case "text":
+		handler = slog.NewTextHandler(stderr, options)
+	case "json":
+		handler = slog.NewJSONHandler(stderr, options)
+	default:
+		return fmt.Errorf("unsupported log format %q (use text or json)", format)
+	}
+
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
@@ -0,0 +1,147 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var optimizeApply bool
+
+var optimizeCmd = &cobra.Command{
+	Use:   "optimize",
+	Short: "Refresh query statistics and suggest indexes for your queries",
+	Long: `Run ANALYZE so SQLite's query planner has current statistics, then check the
+queries you actually run for tables they read in full:
+
+  • SQL console and peep query history (the queries that succeeded)
+  • Alert rule queries
+  • Dashboard panel queries
+
+For each full scan peep suggests an index on the columns the query filters on,
+including json_extract(context, '$.field') expressions, and keeps only the
+suggestions SQLite's planner would use. It also lists indexes none of those
+queries use; peep's own idx_ indexes are never listed, since peep relies on them.
+
+Examples:
+  peep optimize          # Report only
+  peep optimize --apply  # Also create the suggested indexes`,
+	RunE: runOptimize,
+}
+
+func init() {
+	optimizeCmd.Flags().BoolVar(&optimizeApply, "apply", false, "Create the suggested indexes")
+}
+
+func runOptimize(cmd *cobra.Command, args []string) error {
+	ctx := cmd.Context()
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	fmt.Fprintln(stdout, "📊 Updating query planner statistics...")
+	if err := store.Analyze(ctx); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+
+	history, err := store.HistoryQueries(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to read query history: %w", err)
+	}
+	ruleQueries, panelQueries, err := savedQueries(store)
+	if err != nil {
+		return err
+	}
+	workload := append(append(history, ruleQueries...), panelQueries...)
+	if len(workload) == 0 {
+		fmt.Fprintln(stdout, "📭 No queries to check yet. Run some in peep query or the SQL console, or add alert rules.")
+		return nil
+	}
+
+	report, err := store.AdviseIndexes(ctx, workload)
+	if err != nil {
+		return fmt.Errorf("failed to check indexes: %w", err)
+	}
+	fmt.Fprintf(stdout, "🔍 Checked %d queries (%d from history, %d alert rules, %d dashboard panels)\n",
+		report.Queries, len(history), len(ruleQueries), len(panelQueries))
+	if report.Skipped > 0 {
+		fmt.Fprintf(stdout, "   %d couldn't be planned and were skipped\n", report.Skipped)
+	}
+	fmt.Fprintln(stdout)
+
+	if len(report.Missing) == 0 {
+		fmt.Fprintln(stdout, "✅ No missing indexes: every checked query reads through an index or needs the whole table")
+		fmt.Fprintln(stdout)
+	} else {
+		fmt.Fprintf(stdout, "💡 Suggested indexes (%d):\n\n", len(report.Missing))
+		for _, index := range report.Missing {
+			fmt.Fprintf(stdout, "   %s;\n", index.SQL())
+			fmt.Fprintf(stdout, "   Used by %d of the queries, e.g. %s\n\n", len(index.Queries), truncate(index.Queries[0], 100))
+		}
+	}
+
+	if len(report.Unused) > 0 {
+		fmt.Fprintf(stdout, "🗑️  Indexes none of these queries use (%d); they still cost space and slow ingestion:\n\n", len(report.Unused))
+		for _, name := range report.Unused {
+			fmt.Fprintf(stdout, "   DROP INDEX %s;\n", name)
+		}
+		fmt.Fprintln(stdout)
+	}
+
+	if len(report.Missing) == 0 {
+		return nil
+	}
+	if !optimizeApply {
+		fmt.Fprintln(stdout, "💡 Run peep optimize --apply to create the suggested indexes")
+		return nil
+	}
+
+	for _, index := range report.Missing {
+		fmt.Fprintf(stdout, "🔨 Creating %s...\n", index.Name)
+		if err := store.CreateIndex(ctx, index); err != nil {
+			return fmt.Errorf("failed to create %s: %w", index.Name, err)
+		}
+	}
+	// The new indexes need statistics too
+	if err := store.Analyze(ctx); err != nil {
+		return fmt.Errorf("failed to analyze database: %w", err)
+	}
+	fmt.Fprintf(stdout, "✅ Created %d suggested indexes\n", len(report.Missing))
+	return nil
+}
+
+// savedQueries returns the SQL of the alert rules and dashboard panels, which
+// run over and over without appearing in the query history
+func savedQueries(store *storage.Storage) (rules, panels []string, err error) {
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+	for _, rule := range engine.GetRules() {
+		if rule.Metric == nil { // Metric and SLO rules have no SQL
+			rules = append(rules, rule.Query)
+		}
+	}
+
+	dashboards, err := store.ListDashboards()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to list dashboards: %w", err)
+	}
+	for _, d := range dashboards {
+		dashboard, err := store.GetDashboard(d.Name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read dashboard %s: %w", d.Name, err)
+		}
+		for _, panel := range dashboard.Panels {
+			if panel.Type != storage.PanelSLO {
+				panels = append(panels, panel.Query)
+			}
+		}
+	}
+	return rules, panels, nil
+}
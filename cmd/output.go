@@ -0,0 +1,179 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/spf13/cobra"
+)
+
+// plainOutput is set when stdout should carry no emoji or terminal styling:
+// NO_COLOR is set, TERM is dumb, or stdout isn't a terminal (a file, a pipe,
+// or a CI log). Commands whose emoji carry meaning print words instead.
+var plainOutput bool
+
+// stdout and stderr are where commands print. setupOutput wraps the real
+// files in a plainWriter when their output should be plain, so every
+// command's output is clean in files and CI logs without each print having
+// to check. os.Stdout and os.Stderr themselves are left alone.
+var (
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
+)
+
+// isTerminal reports whether f is a terminal rather than a file or pipe
+func isTerminal(f *os.File) bool {
+	stat, err := f.Stat()
+	return err == nil && stat.Mode()&os.ModeCharDevice != 0
+}
+
+// wantsPlain reports whether output to f should be plain text
+func wantsPlain(f *os.File) bool {
+	return os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !isTerminal(f)
+}
+
+// setupOutput points stdout and stderr at filters that drop emoji and
+// terminal escapes, for the ones that should be plain
+func setupOutput(cmd *cobra.Command) {
+	// The TUI draws the whole screen itself and already honors NO_COLOR
+	if cmd == tuiCmd {
+		return
+	}
+
+	plainOutput = wantsPlain(os.Stdout)
+	if plainOutput && !dataOutput(cmd) {
+		stdout = newPlainWriter(os.Stdout)
+	}
+	if wantsPlain(os.Stderr) {
+		stderr = newPlainWriter(os.Stderr)
+	}
+}
+
+// dataOutput reports whether the command's stdout is data for scripts (JSON,
+// CSV, or query results), which passes through untouched: emoji in a log
+// message are part of the log.
+func dataOutput(cmd *cobra.Command) bool {
+	if cmd == queryCmd || cmd == askCmd || cmd == searchCmd {
+		return true
+	}
+	if format := cmd.Flags().Lookup("format"); format != nil {
+		if value := format.Value.String(); value != "text" && value != "table" {
+			return true
+		}
+	}
+	if jsonFlag := cmd.Flags().Lookup("json"); jsonFlag != nil && jsonFlag.Value.String() == "true" {
+		return true
+	}
+	return false
+}
+
+// plainWriter removes emoji, and the spaces after them, along with terminal
+// escape sequences
+type plainWriter struct {
+	mu          sync.Mutex // Commands with progress output print from more than one goroutine
+	w           io.Writer
+	keepControl bool   // Keep escapes other than colors, e.g. clearing the screen
+	pending     []byte // An incomplete rune or escape sequence from the last write
+	afterEmoji  bool   // Drop the spaces that separated a removed emoji from the text
+}
+
+// newPlainWriter makes output to f plain. On a terminal with NO_COLOR, only
+// colors go: clearing the screen still works.
+func newPlainWriter(f *os.File) *plainWriter {
+	return &plainWriter{w: f, keepControl: isTerminal(f)}
+}
+
+func (p *plainWriter) Write(b []byte) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	data := append(p.pending, b...)
+	p.pending = nil
+
+	out := make([]byte, 0, len(data))
+	for i := 0; i < len(data); {
+		if data[i] == 0x1b {
+			end := escapeEnd(data[i:])
+			if end < 0 {
+				p.pending = append([]byte(nil), data[i:]...)
+				break
+			}
+			if seq := data[i : i+end]; p.keepControl && seq[len(seq)-1] != 'm' {
+				out = append(out, seq...)
+			}
+			i += end
+			continue
+		}
+
+		if !utf8.FullRune(data[i:]) {
+			p.pending = append([]byte(nil), data[i:]...)
+			break
+		}
+		r, size := utf8.DecodeRune(data[i:])
+		switch {
+		case isEmoji(r):
+			p.afterEmoji = true
+		case p.afterEmoji && r == ' ':
+		default:
+			p.afterEmoji = false
+			out = append(out, data[i:i+size]...)
+		}
+		i += size
+	}
+
+	if _, err := p.w.Write(out); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+// escapeEnd returns the length of the escape sequence at the start of b, or
+// -1 if it's cut off
+func escapeEnd(b []byte) int {
+	if len(b) < 2 {
+		return -1
+	}
+	switch b[1] {
+	case '[': // CSI: parameters, intermediates, then a final byte
+		for i := 2; i < len(b); i++ {
+			if b[i] >= 0x40 && b[i] <= 0x7e {
+				return i + 1
+			}
+		}
+		return -1
+	case ']': // OSC: ends with BEL or ESC \
+		for i := 2; i < len(b); i++ {
+			if b[i] == 0x07 {
+				return i + 1
+			}
+			if b[i] == 0x1b && i+1 < len(b) && b[i+1] == '\\' {
+				return i + 2
+			}
+		}
+		return -1
+	default:
+		return 2
+	}
+}
+
+// isEmoji reports whether r is an emoji or pictograph, or one of the
+// invisible characters that join and style them
+func isEmoji(r rune) bool {
+	switch {
+	case r >= 0x1f000 && r <= 0x1faff: // Pictographs, emoticons, transport, flags
+		return true
+	case r >= 0x2600 && r <= 0x27bf: // Miscellaneous symbols and dingbats (✅ ❌ ⚠)
+		return true
+	case r >= 0x2300 && r <= 0x23ff: // Technical symbols (⏰ ⏱ ⌛)
+		return true
+	case r >= 0x2b00 && r <= 0x2bff: // Arrows and stars (⭐ ⬆)
+		return true
+	case r == 0xfe0f || r == 0xfe0e || r == 0x200d || r == 0x20e3:
+		return true
+	case r >= 0xe0020 && r <= 0xe007f: // Tag characters in flag sequences
+		return true
+	}
+	return false
+}
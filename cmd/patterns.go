@@ -0,0 +1,178 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var patternsCmd = &cobra.Command{
+	Use:   "patterns",
+	Short: "Cluster warnings and errors into patterns and flag new ones",
+	Long: `Group warning and error messages into patterns by masking their variable
+parts (numbers, IDs, IPs, quoted strings), so "timeout after 30s" and
+"timeout after 12s" count as one, and list them, most frequent first.
+
+The alert engine (peep daemon, peep serve, or peep alerts start) clusters new
+logs on every check. A pattern seen for the first time, or again after
+alerts.dormant_days (default 7) without it, is recorded in the
+pattern_anomalies table: see them with peep patterns new, and alert on them
+with a rule that counts that table. The first scan of a database learns the
+patterns already in it without flagging them.
+
+Examples:
+  peep patterns                          # Most frequent patterns
+  peep patterns --service api
+  peep patterns new --since 24h          # Patterns new or back from dormancy
+  peep patterns scan                     # Cluster logs now, e.g. from cron
+  peep alerts add "New Error Patterns" "SELECT COUNT(*) FROM pattern_anomalies WHERE level = 'error'"`,
+	Args: cobra.NoArgs,
+	RunE: runPatterns,
+}
+
+var patternsNewCmd = &cobra.Command{
+	Use:   "new",
+	Short: "List patterns that appeared for the first time, or came back",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		since, _ := cmd.Flags().GetDuration("since")
+		limit, _ := cmd.Flags().GetInt("limit")
+
+		store, err := storage.NewStorage(cfg.DBPath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		defer store.Close()
+
+		anomalies, err := store.GetPatternAnomalies(cmd.Context(), time.Now().Add(-since), limit)
+		if err != nil {
+			return fmt.Errorf("failed to load pattern anomalies: %w", err)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if anomalies == nil {
+				anomalies = []storage.PatternAnomaly{}
+			}
+			encoder := json.NewEncoder(stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(anomalies)
+		}
+
+		if len(anomalies) == 0 {
+			fmt.Fprintf(stdout, "✅ No new patterns since %s\n", time.Now().Add(-since).Format("Jan 2 15:04"))
+			return nil
+		}
+		printPatternAnomalies(anomalies)
+		return nil
+	},
+}
+
+var patternsScanCmd = &cobra.Command{
+	Use:   "scan",
+	Short: "Cluster the warnings and errors stored since the last scan",
+	Long: `Cluster the warnings and errors stored since the last scan and print the
+patterns that are new or back from dormancy. The alert engine does this on
+every check; run it yourself when no engine is running, e.g. from cron.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		store, err := storage.NewStorage(cfg.DBPath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		defer store.Close()
+
+		dormancy := cfg.Alerts.PatternDormancy()
+		if dormancy == 0 {
+			dormancy = alerts.DefaultPatternDormancy
+		}
+		anomalies, err := store.DetectPatterns(cmd.Context(), dormancy)
+		if err != nil {
+			return fmt.Errorf("failed to scan patterns: %w", err)
+		}
+		if len(anomalies) == 0 {
+			fmt.Fprintln(stdout, "✅ No new patterns")
+			return nil
+		}
+		printPatternAnomalies(anomalies)
+		return nil
+	},
+}
+
+func init() {
+	patternsCmd.Flags().String("service", "", "Only this service's patterns")
+	patternsCmd.Flags().IntP("limit", "l", 20, "Maximum number of patterns to show")
+	patternsCmd.Flags().Bool("json", false, "Print the patterns as JSON")
+
+	patternsNewCmd.Flags().Duration("since", 24*time.Hour, "How far back to look")
+	patternsNewCmd.Flags().IntP("limit", "l", 50, "Maximum number of patterns to show")
+	patternsNewCmd.Flags().Bool("json", false, "Print the patterns as JSON")
+
+	patternsCmd.AddCommand(patternsNewCmd)
+	patternsCmd.AddCommand(patternsScanCmd)
+}
+
+func runPatterns(cmd *cobra.Command, args []string) error {
+	service, _ := cmd.Flags().GetString("service")
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	patterns, err := store.GetPatterns(cmd.Context(), service, limit)
+	if err != nil {
+		return fmt.Errorf("failed to load patterns: %w", err)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		if patterns == nil {
+			patterns = []storage.LogPattern{}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(patterns)
+	}
+
+	if len(patterns) == 0 {
+		return withHint(fmt.Errorf("no patterns found"),
+			"💡 Patterns are learned from warnings and errors by the alert engine, or by running: peep patterns scan")
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "COUNT\tSERVICE\tLEVEL\tLAST SEEN\tPATTERN")
+	for _, p := range patterns {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%s\n", p.Count, p.Service, p.Level,
+			p.LastSeen.Local().Format("2006-01-02 15:04"), truncate(p.Pattern, 100))
+	}
+	return w.Flush()
+}
+
+// printPatternAnomalies lists anomalies with when and how each was detected
+func printPatternAnomalies(anomalies []storage.PatternAnomaly) {
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "DETECTED\tKIND\tSERVICE\tLEVEL\tPATTERN")
+	for _, a := range anomalies {
+		kind := "🆕 new"
+		if a.Kind == storage.PatternDormant {
+			kind = "🔁 back after " + formatDormancy(a.Timestamp.Sub(a.PreviousSeen))
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", a.Timestamp.Local().Format("2006-01-02 15:04"), kind,
+			a.Service, a.Level, truncate(a.Pattern, 100))
+	}
+	w.Flush()
+}
+
+// formatDormancy rounds how long a pattern was gone to days, or hours under a day
+func formatDormancy(d time.Duration) string {
+	if d < 24*time.Hour {
+		return fmt.Sprintf("%dh", int(d.Hours()))
+	}
+	return fmt.Sprintf("%dd", int(d.Hours()/24))
+}
@@ -0,0 +1,24 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processAlive reports whether a process with this PID exists
+func processAlive(pid int) bool {
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
+
+// stopProcess asks the process to shut down gracefully
+func stopProcess(pid int) error {
+	return syscall.Kill(pid, syscall.SIGTERM)
+}
+
+// detachAttr starts a process in its own session, so it outlives the terminal
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
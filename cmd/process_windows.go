@@ -0,0 +1,39 @@
+//go:build windows
+
+package cmd
+
+import (
+	"os"
+	"syscall"
+)
+
+// stillActive is the exit code GetExitCodeProcess reports for a running process
+const stillActive = 259
+
+// processAlive reports whether a process with this PID exists
+func processAlive(pid int) bool {
+	handle, err := syscall.OpenProcess(syscall.PROCESS_QUERY_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return false
+	}
+	defer syscall.CloseHandle(handle)
+
+	var code uint32
+	return syscall.GetExitCodeProcess(handle, &code) == nil && code == stillActive
+}
+
+// stopProcess ends the process. Windows has no SIGTERM to send a console-less
+// process, so it can't shut down gracefully.
+func stopProcess(pid int) error {
+	process, err := os.FindProcess(pid)
+	if err != nil {
+		return err
+	}
+	return process.Kill()
+}
+
+// detachAttr starts a process without a console, so it outlives the terminal
+func detachAttr() *syscall.SysProcAttr {
+	const detachedProcess = 0x00000008
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
@@ -0,0 +1,267 @@
+package cmd
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var (
+	queryFormat  string
+	queryTimeout time.Duration
+)
+
+var queryCmd = &cobra.Command{
+	Use:   "query [SQL]",
+	Short: "Run a read-only SQL query against the log database",
+	Long: `Run a single SELECT against the log database and print the results, with the
+same read-only guard as the web SQL console. The query can also be piped in.
+
+Examples:
+  peep query "SELECT level, COUNT(*) FROM logs GROUP BY level"
+  peep query --format csv "SELECT * FROM logs WHERE level = 'error'" > errors.csv
+  peep query --format json "SELECT service, COUNT(*) AS n FROM logs GROUP BY service" | jq .
+  peep query < report.sql
+
+Formats: table (default), csv, json`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runQuery,
+}
+
+func init() {
+	queryCmd.Flags().StringVarP(&queryFormat, "format", "f", "table", "Output format: table, csv, or json")
+	queryCmd.Flags().DurationVar(&queryTimeout, "timeout", 30*time.Second, "Stop the query after this long")
+}
+
+// queryWriter prints result rows in one of the output formats
+type queryWriter interface {
+	WriteHeader(columns []string) error
+	WriteRow(values []interface{}) error
+	Close() error
+}
+
+func runQuery(cmd *cobra.Command, args []string) error {
+	var query string
+	if len(args) == 1 {
+		query = args[0]
+	} else {
+		stat, _ := os.Stdin.Stat()
+		if (stat.Mode() & os.ModeCharDevice) != 0 {
+			return fmt.Errorf("give a query as an argument or pipe one in")
+		}
+		data, err := io.ReadAll(os.Stdin)
+		if err != nil {
+			return fmt.Errorf("failed to read query: %w", err)
+		}
+		query = string(data)
+	}
+
+	out, err := newQueryWriter(queryFormat)
+	if err != nil {
+		return err
+	}
+
+	query, err = storage.ReadStatement(query)
+	if err != nil {
+		return err
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	return runRecordedQuery(store, query, out, queryTimeout)
+}
+
+// newQueryWriter returns the writer for an output format, printing to stdout
+func newQueryWriter(format string) (queryWriter, error) {
+	switch format {
+	case "table":
+		return &tableWriter{w: tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)}, nil
+	case "csv":
+		return &csvWriter{w: csv.NewWriter(stdout)}, nil
+	case "json":
+		return &jsonWriter{w: stdout}, nil
+	default:
+		return nil, fmt.Errorf("unsupported format %q (use table, csv, or json)", format)
+	}
+}
+
+// runRecordedQuery streams a read-only query's results and records it in the
+// query history
+func runRecordedQuery(store *storage.Storage, query string, out queryWriter, timeout time.Duration) error {
+	started := time.Now()
+	count, err := streamQuery(store, query, out, timeout)
+	entry := storage.QueryHistoryEntry{Query: query, Duration: time.Since(started), RowCount: count, User: "cli"}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	store.RecordQuery(entry)
+	return err
+}
+
+// streamQuery runs a read-only query, writing each row as it's read so large
+// results don't pile up in memory. It returns how many rows were written.
+func streamQuery(store *storage.Storage, query string, out queryWriter, timeout time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	rows, release, err := store.QueryReadOnly(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer release()
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return 0, err
+	}
+	if err := out.WriteHeader(columns); err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for rows.Next() {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return count, err
+		}
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+		if err := out.WriteRow(values); err != nil {
+			return count, err
+		}
+		count++
+	}
+	if err := rows.Err(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return count, fmt.Errorf("query timed out after %s", timeout)
+		}
+		return count, err
+	}
+	return count, out.Close()
+}
+
+// formatValue prints a result value for the table and CSV formats
+func formatValue(value interface{}, null string) string {
+	switch v := value.(type) {
+	case nil:
+		return null
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// tableWriter aligns the results in columns under a header
+type tableWriter struct {
+	w *tabwriter.Writer
+}
+
+func (t *tableWriter) WriteHeader(columns []string) error {
+	rule := make([]string, len(columns))
+	for i, column := range columns {
+		rule[i] = strings.Repeat("-", len(column))
+	}
+	fmt.Fprintln(t.w, strings.Join(columns, "\t"))
+	_, err := fmt.Fprintln(t.w, strings.Join(rule, "\t"))
+	return err
+}
+
+func (t *tableWriter) WriteRow(values []interface{}) error {
+	cells := make([]string, len(values))
+	for i, value := range values {
+		// Keep each row on one line
+		cells[i] = strings.Join(strings.Fields(formatValue(value, "NULL")), " ")
+	}
+	_, err := fmt.Fprintln(t.w, strings.Join(cells, "\t"))
+	return err
+}
+
+func (t *tableWriter) Close() error {
+	return t.w.Flush()
+}
+
+// csvWriter writes a header row and data rows, with NULLs as empty fields
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func (c *csvWriter) WriteHeader(columns []string) error {
+	return c.w.Write(columns)
+}
+
+func (c *csvWriter) WriteRow(values []interface{}) error {
+	record := make([]string, len(values))
+	for i, value := range values {
+		record[i] = formatValue(value, "")
+	}
+	return c.w.Write(record)
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// jsonWriter writes an array of objects keyed by column name, one per line
+type jsonWriter struct {
+	w       io.Writer
+	columns []string
+	rows    int
+}
+
+func (j *jsonWriter) WriteHeader(columns []string) error {
+	j.columns = columns
+	_, err := io.WriteString(j.w, "[")
+	return err
+}
+
+func (j *jsonWriter) WriteRow(values []interface{}) error {
+	record := make(map[string]interface{}, len(values))
+	for i, value := range values {
+		record[j.columns[i]] = value
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	separator := "\n  "
+	if j.rows > 0 {
+		separator = ",\n  "
+	}
+	j.rows++
+	_, err = fmt.Fprintf(j.w, "%s%s", separator, data)
+	return err
+}
+
+func (j *jsonWriter) Close() error {
+	if j.rows == 0 {
+		_, err := io.WriteString(j.w, "]\n")
+		return err
+	}
+	_, err := io.WriteString(j.w, "\n]\n")
+	return err
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// configPollInterval is how often a long-running command checks whether its
+// config file has changed
+const configPollInterval = 2 * time.Second
+
+// cfgMu guards cfg against a config reload. The reload callback runs on the
+// watcher goroutine and reads cfg directly; other goroutines running beside
+// it use currentConfig.
+var cfgMu sync.RWMutex
+
+// currentConfig returns the configuration as last loaded or reloaded
+func currentConfig() config.Config {
+	cfgMu.RLock()
+	defer cfgMu.RUnlock()
+	return cfg
+}
+
+// watchConfig calls reload on SIGHUP or when the config file changes, until ctx
+// is cancelled. A file that doesn't exist yet is picked up once it's created.
+func watchConfig(ctx context.Context, cmd *cobra.Command, reload func()) {
+	path := configPath(cmd)
+	if path == "" {
+		path = config.DefaultPath()
+	}
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configPollInterval)
+	defer ticker.Stop()
+
+	last := modTime(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			slog.Info("reloading config", "reason", "SIGHUP", "path", path)
+			last = modTime(path)
+			reload()
+		case <-ticker.C:
+			if changed := modTime(path); !changed.Equal(last) {
+				last = changed
+				slog.Info("reloading config", "reason", "file changed", "path", path)
+				reload()
+			}
+		}
+	}
+}
+
+// modTime is when the file was last written, or zero if it doesn't exist
+func modTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// reloadConfig reads the config file and environment again into cfg, keeping
+// the current settings if they're invalid. The database can't change while
+// running, so a new db_path is ignored until a restart.
+func reloadConfig(cmd *cobra.Command) error {
+	loaded, err := config.Load(configPath(cmd))
+	if err != nil {
+		return err
+	}
+	if cmd.Flags().Changed("db") {
+		loaded.DBPath, _ = cmd.Flags().GetString("db")
+	}
+	if loaded.DBPath != cfg.DBPath {
+		slog.Warn("db_path changed; restart to use the new database", "db", cfg.DBPath, "new_db", loaded.DBPath)
+		loaded.DBPath = cfg.DBPath
+	}
+	if loaded.Web != cfg.Web {
+		slog.Warn("web settings changed; restart to apply them")
+	}
+	cfgMu.Lock()
+	cfg = loaded
+	cfgMu.Unlock()
+	return nil
+}
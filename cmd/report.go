@@ -0,0 +1,184 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/spf13/cobra"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Send scheduled summaries of the logs to a notification channel",
+	Long: `Schedule digests for people who won't open the dashboard.
+
+Each report covers the time since its previous run (a day for @daily, a week
+for @weekly) and compares it with the period before: log volume, errors, the
+most frequent error messages, and the alerts that fired. With --dashboard, the
+stat, table, and SLO panels of a saved dashboard are included too.
+
+Reports go out through an alert notification channel (email, Slack, desktop,
+or shell) while peep daemon, peep serve, or peep alerts start is running. A
+channel whose --match selects no rules, e.g. --match report=weekly, receives
+reports but no alerts.
+
+Examples:
+  peep alerts channels add email "Managers" --smtp-host smtp.company.com --from peep@company.com --to boss@company.com --match report=weekly
+  peep report add weekly-ops --schedule "0 9 * * mon" --channel Managers --dashboard ops
+  peep report add daily --schedule @daily --channel "Team Alerts"
+  peep report send weekly-ops --dry-run
+  peep report list`,
+}
+
+var reportAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add a scheduled report",
+	Long: `Add a report sent on a cron schedule: five fields (minute hour day month
+weekday), or a macro such as @daily or @weekly, in local time.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		report := &alerts.Report{Name: args[0]}
+		report.Schedule, _ = cmd.Flags().GetString("schedule")
+		report.Channel, _ = cmd.Flags().GetString("channel")
+		report.Dashboard, _ = cmd.Flags().GetString("dashboard")
+		report.Description, _ = cmd.Flags().GetString("description")
+		if err := report.Validate(); err != nil {
+			return err
+		}
+
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		if err := engine.AddReport(report); err != nil {
+			return fmt.Errorf("failed to add report: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Report '%s' added: sent to %s %s\n", report.Name, report.Channel, report.Schedule)
+		fmt.Fprintf(stdout, "   Next run: %s\n", report.NextRun().Format("Mon Jan 2 15:04"))
+		fmt.Fprintf(stdout, "💡 Preview it with: peep report send %s --dry-run\n", report.Name)
+		return nil
+	},
+}
+
+var reportListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List scheduled reports",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		reports, err := engine.GetReports()
+		if err != nil {
+			return fmt.Errorf("failed to load reports: %w", err)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			if reports == nil {
+				reports = []*alerts.Report{}
+			}
+			encoder := json.NewEncoder(stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(reports)
+		}
+
+		if len(reports) == 0 {
+			fmt.Fprintln(stdout, "📭 No reports scheduled.")
+			fmt.Fprintln(stdout, "💡 Add one with: peep report add weekly --schedule @weekly --channel \"Team Alerts\"")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REPORT\tSCHEDULE\tCHANNEL\tDASHBOARD\tLAST SENT\tNEXT RUN")
+		for _, report := range reports {
+			lastSent := "never"
+			if !report.LastSent.IsZero() {
+				lastSent = report.LastSent.Local().Format("2006-01-02 15:04")
+			}
+			dashboard := report.Dashboard
+			if dashboard == "" {
+				dashboard = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", report.Name, report.Schedule, report.Channel, dashboard,
+				lastSent, report.NextRun().Format("2006-01-02 15:04"))
+		}
+		return w.Flush()
+	},
+}
+
+var reportSendCmd = &cobra.Command{
+	Use:   "send [name]",
+	Short: "Send a report now, or print it with --dry-run",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		report, err := engine.GetReport(args[0])
+		if err != nil {
+			return err
+		}
+
+		if dryRun, _ := cmd.Flags().GetBool("dry-run"); dryRun {
+			built, err := engine.BuildReport(report)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(stdout, built.Text())
+			return nil
+		}
+
+		start := time.Now()
+		if err := engine.SendReport(report); err != nil {
+			return err
+		}
+		fmt.Fprintf(stdout, "✅ Report '%s' sent to %s (%s)\n", report.Name, report.Channel, time.Since(start).Round(time.Millisecond))
+		return nil
+	},
+}
+
+var reportRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove a scheduled report",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		if err := engine.RemoveReport(args[0]); err != nil {
+			return fmt.Errorf("failed to remove report: %w", err)
+		}
+		fmt.Fprintf(stdout, "✅ Report '%s' removed\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	reportAddCmd.Flags().String("schedule", "@daily", "When to send it: a cron expression or @daily, @weekly, @monthly")
+	reportAddCmd.Flags().String("channel", "", "Name of the notification channel to send it to (required)")
+	reportAddCmd.Flags().String("dashboard", "", "Include this dashboard's stat, table, and SLO panels")
+	reportAddCmd.Flags().StringP("description", "d", "", "A line shown at the top of the report")
+	reportAddCmd.MarkFlagRequired("channel")
+
+	reportListCmd.Flags().Bool("json", false, "Print the reports as JSON")
+	reportSendCmd.Flags().Bool("dry-run", false, "Print the report instead of sending it")
+
+	reportCmd.AddCommand(reportAddCmd)
+	reportCmd.AddCommand(reportListCmd)
+	reportCmd.AddCommand(reportSendCmd)
+	reportCmd.AddCommand(reportRemoveCmd)
+}
@@ -3,7 +3,9 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strings"
 
+	"github.com/kylereynolds/peep/internal/config"
 	"github.com/spf13/cobra"
 )
 
@@ -13,39 +15,161 @@ var rootCmd = &cobra.Command{
 	Long: `Peep is a simple, powerful observability tool that stores logs in SQLite
 and provides both TUI and web interfaces for monitoring your applications.
 
-No YAML configuration hell. No cloud vendor lock-in. Just logs.`,
-	Run: func(cmd *cobra.Command, args []string) {
+No YAML configuration hell: everything works with no config at all. When the
+defaults don't fit, one optional file sets them for every command:
+
+  ~/.config/peep/config.yaml (or --config, or PEEP_CONFIG)
+
+  db_path: /var/lib/peep/logs.db
+  web:
+    port: 9090
+    bind: 127.0.0.1
+  retention:
+    max_age_days: 14
+    max_logs: 500000
+  parser:
+    patterns:
+      - '^(?P<timestamp>\S+) (?P<level>\w+) (?P<service>\S+): (?P<message>.*)$'
+  filters:
+    exclude_levels: [debug]
+  ingest:
+    syslog: ':5514'
+
+Environment variables override the file: PEEP_DB, PEEP_WEB_PORT,
+PEEP_WEB_BIND, PEEP_RETENTION_ENABLED, PEEP_RETENTION_MAX_LOGS,
+PEEP_RETENTION_MAX_AGE_DAYS, PEEP_RETENTION_MAX_SIZE_MB,
+PEEP_RETENTION_CHECK_MINS, PEEP_INGEST_TCP, PEEP_INGEST_SYSLOG,
+PEEP_INGEST_HTTP, PEEP_ALERTS_CHECK_INTERVAL, and PEEP_QUERY_TIMEOUT.
+Command-line flags override both.
+
+Peep's own operational messages (alerts fired, notifications sent, cleanups,
+the web server starting) are logged to stderr. --quiet keeps only warnings and
+errors, --verbose adds debug messages, and --log-format json makes them
+machine-readable when running as a service.
+
+Output piped to a file or CI log, or printed with NO_COLOR set (or TERM=dumb),
+has no emoji or terminal styling. JSON and CSV output is never changed.
+
+No cloud vendor lock-in. Just logs.`,
+	PersistentPreRunE: loadConfig,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Check if stdin has data (piped input)
 		stat, _ := os.Stdin.Stat()
 		if (stat.Mode() & os.ModeCharDevice) == 0 {
 			// Data is being piped to stdin, use ingest command
-			ingestCmd.Run(cmd, args)
-			return
+			return ingestCmd.RunE(cmd, args)
 		}
 
 		// No piped input, show help
-		fmt.Println("🔍 Peep - Observability for humans")
-		fmt.Println("Run 'peep --help' for available commands")
-		fmt.Println("")
-		fmt.Println("Quick examples:")
-		fmt.Println("  echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
-		fmt.Println("  peep ingest app.log")
-		fmt.Println("  peep list")
+		fmt.Fprintln(stdout, "🔍 Peep - Observability for humans")
+		fmt.Fprintln(stdout, "Run 'peep --help' for available commands")
+		fmt.Fprintln(stdout, "")
+		fmt.Fprintln(stdout, "Quick examples:")
+		fmt.Fprintln(stdout, "  echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
+		fmt.Fprintln(stdout, "  peep ingest app.log")
+		fmt.Fprintln(stdout, "  peep list")
+
+		return nil
 	},
 }
 
+// cfg is the global configuration, loaded before any command runs
+var cfg = config.Default()
+
+// loadConfig reads the config file and environment, then applies the global flags
+func loadConfig(cmd *cobra.Command, args []string) error {
+	// Flags and arguments have been validated by now, so later errors aren't
+	// about the usage and shouldn't print it
+	cmd.SilenceUsage = true
+
+	setupOutput(cmd)
+	if err := setupLogging(cmd); err != nil {
+		return err
+	}
+
+	loaded, err := config.Load(configPath(cmd))
+	if err != nil {
+		return err
+	}
+	cfg = loaded
+
+	if cmd.Flags().Changed("db") {
+		cfg.DBPath, _ = cmd.Flags().GetString("db")
+	}
+	return nil
+}
+
+// configPath is the config file given by --config or PEEP_CONFIG, or empty
+// for the default
+func configPath(cmd *cobra.Command) string {
+	path, _ := cmd.Flags().GetString("config")
+	if path == "" {
+		path = os.Getenv("PEEP_CONFIG")
+	}
+	return path
+}
+
+// Execute runs the command line, printing any error once on stderr. The
+// caller only has to exit non-zero when it returns one.
 func Execute() error {
-	return rootCmd.Execute()
+	registerCompletions()
+	rootCmd.SilenceErrors = true
+
+	err := rootCmd.Execute()
+	if err != nil {
+		fmt.Fprintf(stderr, "Error: %v\n", err)
+	}
+	return err
+}
+
+// hintError is an error followed by suggestions on how to fix it
+type hintError struct {
+	err   error
+	hints []string
+}
+
+func (e *hintError) Error() string {
+	return e.err.Error() + "\n" + strings.Join(e.hints, "\n")
+}
+
+func (e *hintError) Unwrap() error { return e.err }
+
+// withHint adds lines of advice printed after the error message
+func withHint(err error, hints ...string) error {
+	return &hintError{err: err, hints: hints}
 }
 
 func init() {
+	rootCmd.PersistentFlags().String("config", "", "Config file (default ~/.config/peep/config.yaml)")
+	rootCmd.PersistentFlags().String("db", "", "SQLite database file (default logs.db)")
+	rootCmd.PersistentFlags().BoolP("quiet", "q", false, "Only log warnings and errors")
+	rootCmd.PersistentFlags().BoolP("verbose", "v", false, "Also log debug messages")
+	rootCmd.PersistentFlags().String("log-format", "text", "Format of Peep's own log output on stderr: text or json")
+
 	rootCmd.AddCommand(ingestCmd)
 	rootCmd.AddCommand(listCmd)
 	rootCmd.AddCommand(alertsCmd)
+	rootCmd.AddCommand(sloCmd)
+	rootCmd.AddCommand(reportCmd)
 	rootCmd.AddCommand(testCmd)
+	rootCmd.AddCommand(topCmd)
 	rootCmd.AddCommand(tuiCmd)
 	rootCmd.AddCommand(webCmd)
 	rootCmd.AddCommand(cleanCmd)
+	rootCmd.AddCommand(optimizeCmd)
+	rootCmd.AddCommand(benchCmd)
 	rootCmd.AddCommand(statsCmd)
 	rootCmd.AddCommand(daemonCmd)
+	rootCmd.AddCommand(installServiceCmd)
+	rootCmd.AddCommand(agentCmd)
+	rootCmd.AddCommand(tokensCmd)
+	rootCmd.AddCommand(queryCmd)
+	rootCmd.AddCommand(askCmd)
+	rootCmd.AddCommand(searchCmd)
+	rootCmd.AddCommand(traceCmd)
+	rootCmd.AddCommand(patternsCmd)
+	rootCmd.AddCommand(completionCmd)
+	rootCmd.AddCommand(manCmd)
+	rootCmd.AddCommand(serveCmd)
+	rootCmd.AddCommand(demoCmd)
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var searchCmd = &cobra.Command{
+	Use:   "search [TEXT]",
+	Short: "Search logs by message text, level, service, and time",
+	Long: `Print the most recent logs matching the given filters, newest first, without
+opening the TUI. The text matches anywhere in the message; leave it out to
+filter on the flags alone. --since and --until take a duration ago or a
+timestamp, as in peep list.
+
+Examples:
+  peep search "connection refused" --level error --service db --since 2h
+  peep search timeout --level error,warn --limit 100
+  peep search --service api --since 30m --format json | jq '.[].message'
+
+Formats: table (default), json, csv`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: runSearch,
+}
+
+func init() {
+	searchCmd.Flags().IntP("limit", "l", 50, "Maximum number of logs to show")
+	searchCmd.Flags().StringP("format", "f", "table", "Output format: table, json, or csv")
+	addLogFilterFlags(searchCmd)
+}
+
+func runSearch(cmd *cobra.Command, args []string) error {
+	filter, err := logFilterFromFlags(cmd)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		filter.Search = args[0]
+	}
+
+	limit, _ := cmd.Flags().GetInt("limit")
+	if limit <= 0 {
+		return fmt.Errorf("--limit must be positive")
+	}
+
+	format, _ := cmd.Flags().GetString("format")
+	if format != "table" && format != "json" && format != "csv" {
+		return fmt.Errorf("unsupported format %q (use table, json, or csv)", format)
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	if format != "table" {
+		return writeLogs(cmd.Context(), store, filter, limit, format)
+	}
+
+	logs, err := store.GetFilteredLogs(cmd.Context(), filter, limit)
+	if err != nil {
+		return fmt.Errorf("failed to search logs: %w", err)
+	}
+
+	if len(logs) == 0 {
+		fmt.Fprintln(stderr, "No matching logs")
+		return nil
+	}
+
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "TIME\tLEVEL\tSERVICE\tMESSAGE")
+	for _, log := range logs {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+			log.Timestamp.Local().Format("2006-01-02 15:04:05"),
+			log.Level,
+			log.Service,
+			strings.Join(strings.Fields(log.Message), " "), // Keep each log on one line
+		)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,386 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/export"
+	"github.com/kylereynolds/peep/internal/ingestion"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/kylereynolds/peep/internal/tokens"
+	"github.com/spf13/cobra"
+)
+
+var serveCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "Run the web UI, alert engine, retention, and ingestion in one process",
+	Long: `Run everything Peep does in a single process sharing one database handle:
+
+  • The web UI and API, including POST /api/ingest for pushing logs over HTTP
+  • The alert engine, which also picks up rules added from the web UI right away
+  • Auto-retention, with the same settings and flags as peep daemon
+  • Ingestion listeners for newline-delimited TCP (--tcp), syslog over UDP
+    and TCP (--syslog), HTTP POSTs (--http), StatsD metrics (--statsd), and
+    OpenTelemetry traces (--otlp), when given here or in the ingest section
+    of the config file
+  • Log export to OTLP, Loki, or a webhook, for each destination in the
+    export section of the config file
+
+This replaces running peep web, peep alerts start, and peep daemon side by side.
+
+Examples:
+  peep serve                                   # Web UI on :8080, alerts, retention
+  peep serve --tcp :5170                       # Also accept logs: tail -f app.log | nc localhost 5170
+  peep serve --syslog :5514                    # Point rsyslog or network devices here
+  peep serve --statsd :8125                    # Apps' StatsD counters, gauges, and timers become metrics
+  peep serve --otlp :4318                      # OTLP/HTTP traces; see them with peep trace
+  peep serve --port 9090 --max-age-days 7      # Web flags and retention flags both apply
+  peep serve --username admin --password secret`,
+	RunE: runServe,
+}
+
+func init() {
+	addWebFlags(serveCmd)
+	addRetentionFlags(serveCmd)
+	addListenerFlags(serveCmd)
+}
+
+// addListenerFlags adds the ingestion listener flags read by startListeners
+func addListenerFlags(cmd *cobra.Command) {
+	cmd.Flags().String("tcp", "", "Accept newline-delimited logs on this TCP address (e.g. :5170)")
+	cmd.Flags().String("syslog", "", "Accept syslog messages over UDP and TCP on this address (e.g. :5514)")
+	cmd.Flags().String("http", "", "Accept logs POSTed to this address, on localhost unless a host is given (e.g. :5171 or 0.0.0.0:5171)")
+	cmd.Flags().Bool("http-require-token", false, "Only accept POSTs to --http with an API token allowed to ingest (see peep tokens)")
+	cmd.Flags().String("statsd", "", "Accept StatsD metrics over UDP on this address, stored in the metrics table (e.g. :8125)")
+	cmd.Flags().String("otlp", "", "Accept OpenTelemetry traces over OTLP/HTTP on this address, stored in the spans table (e.g. :4318)")
+}
+
+// ingestListener is implemented by the ingestion package's listeners. Listen
+// binds the address apart from Serve, so a taken port is reported before
+// any listener starts serving.
+type ingestListener interface {
+	Listen() error
+	Serve(ctx context.Context) error
+}
+
+// listenerNames are the ingestion listeners, in the order they're started
+var listenerNames = []string{"tcp", "syslog", "http", "statsd", "otlp"}
+
+// ingestListeners runs the ingestion listeners set by flags or the ingest
+// section of the config, storing what they receive
+type ingestListeners struct {
+	ctx     context.Context
+	parser  *ingestion.LogParser
+	deriver *ingestion.Deriver
+	handle  func(entry storage.LogEntry, line string) error
+	metrics func(metrics []storage.Metric) error // Stores what the StatsD listener aggregates
+	spans   func(spans []storage.Span) error     // Stores what the OTLP listener receives
+	running map[string]*runningListener
+	mu      sync.Mutex // Held while changing running, by reload and restart
+
+	// The HTTP listener checks these per request, so a reload applies to it without a restart
+	tokens       *tokens.Store
+	requireToken atomic.Bool
+}
+
+type runningListener struct {
+	addr string
+	stop context.CancelFunc
+}
+
+// startListeners opens the configured listeners until ctx is cancelled. Every
+// address is bound before it starts serving, so a taken port fails startup
+// rather than leaving that listener off.
+func startListeners(ctx context.Context, cmd *cobra.Command, store *storage.Storage) (*ingestListeners, error) {
+	parser, err := ingestion.NewLogParser(cfg.Parser.Patterns)
+	if err != nil {
+		return nil, err
+	}
+	applyFilterConfig()
+	deriver, err := newDeriver(store)
+	if err != nil {
+		return nil, err
+	}
+	tokenStore, err := tokens.NewStore(store.GetDB())
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize API tokens: %w", err)
+	}
+
+	l := &ingestListeners{
+		ctx:     ctx,
+		parser:  parser,
+		deriver: deriver,
+		handle: func(entry storage.LogEntry, line string) error {
+			if shouldSkipLog(entry, line) {
+				return nil
+			}
+			if err := store.InsertLog(entry); err != nil {
+				return err
+			}
+			return deriver.Observe(entry)
+		},
+		metrics: store.InsertMetrics,
+		spans:   store.InsertSpans,
+		running: make(map[string]*runningListener),
+		tokens:  tokenStore,
+	}
+	l.requireToken.Store(httpRequireToken(cmd))
+	return l, l.update(listenerAddrs(cmd))
+}
+
+// httpRequireToken reports whether the HTTP listener needs an API token. The
+// flag overrides the config.
+func httpRequireToken(cmd *cobra.Command) bool {
+	if cmd.Flags().Changed("http-require-token") {
+		required, _ := cmd.Flags().GetBool("http-require-token")
+		return required
+	}
+	return cfg.Ingest.HTTPRequireToken
+}
+
+// authorizeHTTP checks the token sent to the HTTP listener, when one is required
+func (l *ingestListeners) authorizeHTTP(secret string) bool {
+	if !l.requireToken.Load() {
+		return true
+	}
+	token, err := l.tokens.Authenticate(secret)
+	return err == nil && token.Allows(tokens.ScopeIngest)
+}
+
+// listenerAddrs is the address for each listener, empty if it's off. Flags
+// override the config.
+func listenerAddrs(cmd *cobra.Command) map[string]string {
+	addrs := map[string]string{
+		"tcp":    cfg.Ingest.TCP,
+		"syslog": cfg.Ingest.Syslog,
+		"http":   cfg.Ingest.HTTP,
+		"statsd": cfg.Ingest.StatsD,
+		"otlp":   cfg.Ingest.OTLP,
+	}
+	for _, name := range listenerNames {
+		if cmd.Flags().Changed(name) {
+			addrs[name], _ = cmd.Flags().GetString(name)
+		}
+	}
+	return addrs
+}
+
+// reload applies a reloaded config: new parser patterns and derive rules
+// take effect on the next line, and only listeners whose address changed are
+// restarted
+func (l *ingestListeners) reload(cmd *cobra.Command) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err := l.parser.SetPatterns(cfg.Parser.Patterns); err != nil {
+		return err
+	}
+	if err := l.deriver.SetRules(cfg.Derive); err != nil {
+		return err
+	}
+	l.requireToken.Store(httpRequireToken(cmd))
+	return l.update(listenerAddrs(cmd))
+}
+
+// update starts, stops, or moves listeners to match addrs. A listener that
+// can't bind its new address keeps running on the old one.
+func (l *ingestListeners) update(addrs map[string]string) error {
+	var errs []error
+	for _, name := range listenerNames {
+		name := name
+		addr, current := addrs[name], l.running[name]
+		if current != nil && current.addr == addr {
+			continue
+		}
+		if addr == "" {
+			if current != nil {
+				current.stop()
+				delete(l.running, name)
+				slog.Info("ingestion listener stopped", "listener", name, "addr", current.addr)
+			}
+			continue
+		}
+
+		listener := l.newListener(name, addr)
+		if err := listener.Listen(); err != nil {
+			errs = append(errs, fmt.Errorf("failed to listen for %s on %s: %w", name, addr, err))
+			continue
+		}
+		if current != nil {
+			current.stop()
+			slog.Info("ingestion listener stopped", "listener", name, "addr", current.addr)
+		}
+
+		ctx, stop := context.WithCancel(l.ctx)
+		l.running[name] = &runningListener{addr: addr, stop: stop}
+		slog.Info("ingestion listening", "listener", name, "addr", addr)
+		go func() {
+			if err := listener.Serve(ctx); err != nil {
+				slog.Error("ingestion listener stopped", "listener", name, "error", err)
+			}
+		}()
+	}
+	return errors.Join(errs...)
+}
+
+// restart stops every running listener and binds it again on the same
+// address, for when one has stopped taking in logs. It returns how many
+// were running.
+func (l *ingestListeners) restart() (int, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	addrs := make(map[string]string, len(l.running))
+	for name, current := range l.running {
+		addrs[name] = current.addr
+		current.stop()
+		delete(l.running, name)
+		slog.Info("ingestion listener stopped", "listener", name, "addr", current.addr)
+	}
+
+	// The old listeners close their sockets in the background, so binding
+	// again can briefly fail with the address in use
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		if err = l.update(addrs); err == nil {
+			break
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return len(addrs), err
+}
+
+func (l *ingestListeners) newListener(name, addr string) ingestListener {
+	onError := func(err error) {
+		slog.Warn("ingestion error", "listener", name, "error", err)
+	}
+	switch name {
+	case "syslog":
+		return &ingestion.SyslogListener{Addr: addr, Parser: l.parser, Handle: l.handle, OnError: onError}
+	case "http":
+		return &ingestion.HTTPListener{Addr: addr, Parser: l.parser, Handle: l.handle, Authorize: l.authorizeHTTP, OnError: onError}
+	case "statsd":
+		return &ingestion.StatsDListener{Addr: addr, Store: l.metrics, OnError: onError}
+	case "otlp":
+		return &ingestion.OTLPListener{Addr: addr, Store: l.spans, OnError: onError}
+	default:
+		return &ingestion.TCPListener{Addr: addr, Parser: l.parser, Handle: l.handle, OnError: onError}
+	}
+}
+
+func runServe(cmd *cobra.Command, args []string) error {
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+	store.SetQueryTimeout(cfg.QueryTimeout)
+	if err := enableWriteBuffer(store); err != nil {
+		return err
+	}
+
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+
+	server, bind, port, err := newWebServer(cmd, store, engine)
+	if err != nil {
+		return err
+	}
+
+	// The manager runs even when disabled, so it can pick up settings changed from the web UI
+	store.EnableAutoRetention(retentionConfigFromFlags(cmd, store))
+
+	slog.Info("alert engine starting", "rules", len(engine.GetRules()))
+	engine.SetCheckInterval(cfg.Alerts.CheckInterval)
+	engine.SetPatternDormancy(cfg.Alerts.PatternDormancy())
+	engine.Start()
+	defer engine.Stop()
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if _, err := startListeners(ctx, cmd, store); err != nil {
+		return err
+	}
+	if _, err := startExporters(ctx, store); err != nil {
+		return err
+	}
+
+	return server.Start(ctx, bind, port)
+}
+
+// logExporters runs an exporter for each destination in the config's export
+// section, teeing stored logs to other systems
+type logExporters struct {
+	ctx     context.Context
+	store   *storage.Storage
+	running map[string]*runningExporter
+	mu      sync.Mutex // Held while changing running
+}
+
+type runningExporter struct {
+	destination export.Destination
+	stop        context.CancelFunc
+	done        chan struct{}
+}
+
+// startExporters starts the configured exporters, until ctx is cancelled
+func startExporters(ctx context.Context, store *storage.Storage) (*logExporters, error) {
+	x := &logExporters{ctx: ctx, store: store, running: make(map[string]*runningExporter)}
+	return x, x.update(cfg.Export)
+}
+
+// update starts, stops, or restarts exporters to match destinations. A
+// restarted exporter resumes where the old one stopped.
+func (x *logExporters) update(destinations []export.Destination) error {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	wanted := make(map[string]export.Destination, len(destinations))
+	for _, destination := range destinations {
+		wanted[destination.Name] = destination
+	}
+	for name, current := range x.running {
+		if destination, ok := wanted[name]; ok && reflect.DeepEqual(destination, current.destination) {
+			delete(wanted, name)
+			continue
+		}
+		// Wait, so the old exporter's last saved position isn't written after the new one starts
+		current.stop()
+		<-current.done
+		delete(x.running, name)
+		slog.Info("log export stopped", "export", name)
+	}
+
+	var errs []error
+	for _, destination := range destinations {
+		if _, ok := wanted[destination.Name]; !ok {
+			continue
+		}
+		exporter, err := export.NewExporter(x.store, destination)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to start export %s: %w", destination.Name, err))
+			continue
+		}
+		ctx, stop := context.WithCancel(x.ctx)
+		running := &runningExporter{destination: destination, stop: stop, done: make(chan struct{})}
+		x.running[destination.Name] = running
+		slog.Info("exporting logs", "export", destination.Name, "type", destination.Type, "url", destination.URL)
+		go func() {
+			defer close(running.done)
+			exporter.Run(ctx)
+		}()
+	}
+	return errors.Join(errs...)
+}
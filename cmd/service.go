@@ -0,0 +1,368 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"text/template"
+
+	"github.com/spf13/cobra"
+)
+
+var installServiceCmd = &cobra.Command{
+	Use:   "install-service [-- daemon flags]",
+	Short: "Install peep daemon as a systemd, launchd, or Windows service",
+	Long: `Install peep daemon as a service that starts at boot and restarts if it
+fails: a systemd unit on Linux, a launchd plist on macOS, or a Windows service.
+On Windows the daemon answers the service manager itself, so it stops cleanly
+when the service is stopped, and it logs to the Windows event log.
+
+The service runs this peep binary with the current database and config file,
+as absolute paths. Flags after -- are passed on to peep daemon.
+
+Installing a system service usually needs root (sudo) or an administrator
+prompt. --user installs a systemd user unit or a launchd agent instead, which
+runs as you while you're logged in.
+
+Examples:
+  sudo peep install-service --db /var/lib/peep/logs.db --run-as peep
+  sudo peep install-service -- --syslog :5514 --max-age-days 14
+  peep install-service --user                   # Runs as you, no root needed
+  peep install-service --print                  # Show the unit without installing it`,
+	RunE: runInstallService,
+}
+
+func init() {
+	installServiceCmd.Flags().String("name", "peep", "Service name")
+	installServiceCmd.Flags().String("run-as", "", "Account to run the daemon as (default root, or you with --user)")
+	installServiceCmd.Flags().Bool("user", false, "Install for the current user instead of system-wide (systemd and launchd)")
+	installServiceCmd.Flags().Bool("print", false, "Print the service definition instead of installing it")
+	installServiceCmd.Flags().Bool("force", false, "Overwrite an existing service definition")
+}
+
+// serviceSpec is what every service manager needs to run the daemon
+type serviceSpec struct {
+	Name    string
+	Label   string   // launchd's reverse-DNS name
+	Args    []string // The peep binary, then its arguments
+	RunAs   string
+	WorkDir string
+	LogPath string // launchd writes stdout and stderr here; the others capture them
+	User    bool
+}
+
+func runInstallService(cmd *cobra.Command, args []string) error {
+	name, _ := cmd.Flags().GetString("name")
+	runAs, _ := cmd.Flags().GetString("run-as")
+	user, _ := cmd.Flags().GetBool("user")
+	printOnly, _ := cmd.Flags().GetBool("print")
+	force, _ := cmd.Flags().GetBool("force")
+
+	if name == "" || strings.ContainsAny(name, `/\ `) {
+		return fmt.Errorf("invalid --name %q", name)
+	}
+	if user && runAs != "" {
+		return fmt.Errorf("--run-as can't be used with --user, which runs as you")
+	}
+
+	// Catch a mistyped daemon flag now rather than when the service first starts
+	if err := daemonCmd.ParseFlags(args); err != nil {
+		return fmt.Errorf("invalid peep daemon flags: %w", err)
+	}
+	if extra := daemonCmd.Flags().Args(); len(extra) > 0 {
+		return fmt.Errorf("peep daemon takes no arguments, got %q", extra)
+	}
+
+	spec, err := newServiceSpec(cmd, name, runAs, user, args)
+	if err != nil {
+		return err
+	}
+
+	switch runtime.GOOS {
+	case "linux":
+		return installFile(spec, systemdUnitPath(spec), systemdUnitTemplate, printOnly, force, systemdNextSteps)
+	case "darwin":
+		return installFile(spec, launchdPlistPath(spec), launchdPlistTemplate, printOnly, force, launchdNextSteps)
+	case "windows":
+		return installWindowsService(spec, printOnly)
+	default:
+		return fmt.Errorf("installing a service isn't supported on %s; run peep daemon from your init system", runtime.GOOS)
+	}
+}
+
+// newServiceSpec builds the daemon's command line from this invocation, with
+// every path made absolute, since services don't start in this directory
+func newServiceSpec(cmd *cobra.Command, name, runAs string, user bool, daemonArgs []string) (serviceSpec, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return serviceSpec{}, fmt.Errorf("failed to find the peep binary: %w", err)
+	}
+	if resolved, err := filepath.EvalSymlinks(exe); err == nil {
+		exe = resolved
+	}
+
+	dbPath, err := filepath.Abs(cfg.DBPath)
+	if err != nil {
+		return serviceSpec{}, err
+	}
+	args := []string{exe, "--db", dbPath}
+
+	if configPath := configPath(cmd); configPath != "" {
+		configPath, err := filepath.Abs(configPath)
+		if err != nil {
+			return serviceSpec{}, err
+		}
+		args = append(args, "--config", configPath)
+	}
+	args = append(args, "daemon")
+	if runtime.GOOS == "windows" {
+		// The daemon logs to the event log under the service's name
+		args = append(args, "--service-name", name)
+	}
+	args = append(args, daemonArgs...)
+
+	spec := serviceSpec{
+		Name:    name,
+		Label:   "com.github.kdreynolds." + name,
+		Args:    args,
+		RunAs:   runAs,
+		WorkDir: filepath.Dir(dbPath),
+		User:    user,
+	}
+	if runtime.GOOS == "darwin" {
+		if user {
+			home, err := os.UserHomeDir()
+			if err != nil {
+				return serviceSpec{}, err
+			}
+			spec.LogPath = filepath.Join(home, "Library", "Logs", name+".log")
+		} else {
+			spec.LogPath = filepath.Join("/usr/local/var/log", name+".log")
+		}
+	}
+	return spec, nil
+}
+
+// installFile writes the service definition, or prints it with --print
+func installFile(spec serviceSpec, path string, tmpl *template.Template, printOnly, force bool, nextSteps func(serviceSpec, string) []string) error {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, spec); err != nil {
+		return err
+	}
+	if printOnly {
+		_, err := stdout.Write(buf.Bytes())
+		return err
+	}
+
+	if _, err := os.Stat(path); err == nil && !force {
+		return withHint(fmt.Errorf("%s already exists", path),
+			"💡 Use --force to overwrite it, or --name to install another service")
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return installError(err, path)
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return installError(err, path)
+	}
+
+	fmt.Fprintf(stdout, "✅ Installed %s\n", path)
+	fmt.Fprintln(stdout, "Start it with:")
+	for _, step := range nextSteps(spec, path) {
+		fmt.Fprintf(stdout, "  %s\n", step)
+	}
+	return nil
+}
+
+func installError(err error, path string) error {
+	err = fmt.Errorf("failed to write %s: %w", path, err)
+	if errors.Is(err, os.ErrPermission) {
+		return withHint(err, "💡 Run with sudo, or use --user to install for the current user")
+	}
+	return err
+}
+
+func systemdUnitPath(spec serviceSpec) string {
+	if spec.User {
+		dir, err := os.UserConfigDir()
+		if err != nil {
+			dir = filepath.Join(os.Getenv("HOME"), ".config")
+		}
+		return filepath.Join(dir, "systemd", "user", spec.Name+".service")
+	}
+	return filepath.Join("/etc/systemd/system", spec.Name+".service")
+}
+
+func systemdNextSteps(spec serviceSpec, path string) []string {
+	if spec.User {
+		return []string{
+			"systemctl --user daemon-reload",
+			"systemctl --user enable --now " + spec.Name,
+			"journalctl --user -u " + spec.Name + " -f   # Follow its logs",
+		}
+	}
+	return []string{
+		"sudo systemctl daemon-reload",
+		"sudo systemctl enable --now " + spec.Name,
+		"journalctl -u " + spec.Name + " -f   # Follow its logs",
+	}
+}
+
+var systemdUnitTemplate = template.Must(template.New("systemd").Funcs(template.FuncMap{
+	"quote": systemdQuote,
+}).Parse(`[Unit]
+Description=Peep log monitoring daemon
+Documentation=https://github.com/KDreynolds/peep
+After=network-online.target
+Wants=network-online.target
+
+[Service]
+Type=simple
+ExecStart={{range $i, $arg := .Args}}{{if $i}} {{end}}{{quote $arg}}{{end}}
+WorkingDirectory={{quote .WorkDir}}
+{{- if .RunAs}}
+User={{.RunAs}}
+{{- end}}
+Restart=on-failure
+RestartSec=5
+TimeoutStopSec=30
+
+[Install]
+WantedBy={{if .User}}default.target{{else}}multi-user.target{{end}}
+`))
+
+// systemdQuote quotes an ExecStart argument if it has spaces or characters
+// systemd would otherwise interpret
+func systemdQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"'\\;$%") {
+		return arg
+	}
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`, `%`, `%%`)
+	return `"` + r.Replace(arg) + `"`
+}
+
+func launchdPlistPath(spec serviceSpec) string {
+	if spec.User {
+		home, _ := os.UserHomeDir()
+		return filepath.Join(home, "Library", "LaunchAgents", spec.Label+".plist")
+	}
+	return filepath.Join("/Library/LaunchDaemons", spec.Label+".plist")
+}
+
+func launchdNextSteps(spec serviceSpec, path string) []string {
+	if spec.User {
+		return []string{
+			"launchctl load -w " + path,
+			"tail -f " + spec.LogPath + "   # Follow its logs",
+		}
+	}
+	return []string{
+		"sudo launchctl load -w " + path,
+		"tail -f " + spec.LogPath + "   # Follow its logs",
+	}
+}
+
+var launchdPlistTemplate = template.Must(template.New("launchd").Funcs(template.FuncMap{
+	"xml": func(s string) string {
+		var buf bytes.Buffer
+		xml.EscapeText(&buf, []byte(s))
+		return buf.String()
+	},
+}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{xml .Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+{{- range .Args}}
+		<string>{{xml .}}</string>
+{{- end}}
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkDir}}</string>
+{{- if .RunAs}}
+	<key>UserName</key>
+	<string>{{xml .RunAs}}</string>
+{{- end}}
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>5</integer>
+	<key>StandardOutPath</key>
+	<string>{{xml .LogPath}}</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .LogPath}}</string>
+</dict>
+</plist>
+`))
+
+// installWindowsService registers the service with sc.exe, restarting it
+// after a failure the way the systemd and launchd definitions do
+func installWindowsService(spec serviceSpec, printOnly bool) error {
+	if spec.User {
+		return fmt.Errorf("--user isn't supported for Windows services")
+	}
+	if spec.RunAs != "" {
+		return withHint(fmt.Errorf("--run-as isn't supported on Windows"),
+			"💡 Set the account on the service's Log On tab in services.msc after installing")
+	}
+
+	quoted := make([]string, len(spec.Args))
+	for i, arg := range spec.Args {
+		quoted[i] = windowsQuote(arg)
+	}
+	commands := [][]string{
+		{"sc.exe", "create", spec.Name, "binPath=", strings.Join(quoted, " "), "start=", "auto", "DisplayName=", "Peep (" + spec.Name + ")"},
+		{"sc.exe", "description", spec.Name, "Peep log monitoring daemon"},
+		{"sc.exe", "failure", spec.Name, "reset=", "86400", "actions=", "restart/5000/restart/5000/restart/30000"},
+	}
+
+	if printOnly {
+		for _, command := range commands {
+			for i, arg := range command {
+				command[i] = windowsQuote(arg)
+			}
+			fmt.Fprintln(stdout, strings.Join(command, " "))
+		}
+		fmt.Fprintf(stdout, "# Then registers %s as an event log source\n", spec.Name)
+		return nil
+	}
+
+	for _, command := range commands {
+		output, err := exec.Command(command[0], command[1:]...).CombinedOutput()
+		if err != nil {
+			err = fmt.Errorf("%s %s failed: %w: %s", command[0], command[1], err, strings.TrimSpace(string(output)))
+			return withHint(err, "💡 Run from an administrator prompt")
+		}
+	}
+
+	if err := installEventSource(spec.Name); err != nil {
+		fmt.Fprintf(stdout, "⚠️  Couldn't register %s as an event log source, so Event Viewer may not format its messages: %v\n", spec.Name, err)
+	}
+
+	fmt.Fprintf(stdout, "✅ Installed Windows service %s\n", spec.Name)
+	fmt.Fprintln(stdout, "Start it with:")
+	fmt.Fprintf(stdout, "  sc.exe start %s\n", spec.Name)
+	fmt.Fprintln(stdout, "Its logs are in Event Viewer, under Windows Logs > Application")
+	return nil
+}
+
+// windowsQuote quotes an argument for a Windows command line
+func windowsQuote(arg string) string {
+	if arg != "" && !strings.ContainsAny(arg, " \t\"") {
+		return arg
+	}
+	return `"` + strings.ReplaceAll(arg, `"`, `\"`) + `"`
+}
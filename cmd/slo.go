@@ -0,0 +1,191 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var sloCmd = &cobra.Command{
+	Use:   "slo",
+	Short: "Track service level objectives and their error budgets",
+	Long: `Define SLOs and see how much of their error budget is left.
+
+An SLO's indicator (SLI) is the ratio of two counting queries over a rolling
+window: good events over all events. With a 99.9% target over 30d, 0.1% of
+the window's events may be bad; that allowance is the error budget.
+
+The burn rate is how fast the budget is going: 1 spends it exactly over the
+window, 10 spends it in a tenth of the window. peep slo list shows it over the
+last hour; alert on it with peep alerts add --slo, which notifies through the
+same channels as other rules. Add an "slo" panel to a dashboard to chart one.
+
+Examples:
+  peep slo add checkout --target 99.9 --window 30d \
+    --good "SELECT COUNT(*) FROM logs WHERE service = 'checkout' AND level != 'error'" \
+    --total "SELECT COUNT(*) FROM logs WHERE service = 'checkout'"
+  peep slo list
+  peep alerts add "Checkout Budget Burn" --slo checkout --above 14.4 --window 1h
+  peep slo remove checkout`,
+}
+
+var sloAddCmd = &cobra.Command{
+	Use:   "add [name]",
+	Short: "Add an SLO",
+	Long: `Add an SLO from two counting queries, which are limited to the window
+the same way alert rule queries are, by appending a timestamp condition.
+
+The name is a slug (lowercase letters, numbers, dashes, or underscores), used
+by peep alerts add --slo and by dashboard panels.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		slo := &alerts.SLO{Name: args[0]}
+		slo.Good, _ = cmd.Flags().GetString("good")
+		slo.Total, _ = cmd.Flags().GetString("total")
+		slo.Target, _ = cmd.Flags().GetFloat64("target")
+		slo.Window, _ = cmd.Flags().GetString("window")
+		slo.Description, _ = cmd.Flags().GetString("description")
+		if err := slo.Validate(); err != nil {
+			return err
+		}
+
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		if err := engine.AddSLO(slo); err != nil {
+			return fmt.Errorf("failed to add SLO: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ SLO '%s' added: %s%% good over %s\n", slo.Name, alerts.FormatMetricValue(slo.Target), slo.Window)
+		status := engine.MeasureSLO(cmd.Context(), slo)
+		if status.Err != nil {
+			return fmt.Errorf("the SLO was saved, but its queries failed: %w", status.Err)
+		}
+		printSLOStatus(status)
+		fmt.Fprintf(stdout, "💡 Alert on budget burn with: peep alerts add \"%s budget burn\" --slo %s --above 14.4 --window 1h\n", slo.Name, slo.Name)
+		return nil
+	},
+}
+
+var sloListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List SLOs with their SLI, remaining budget, and burn rate",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		slos, err := engine.GetSLOs()
+		if err != nil {
+			return fmt.Errorf("failed to load SLOs: %w", err)
+		}
+		statuses := make([]alerts.SLOStatus, len(slos))
+		for i, slo := range slos {
+			statuses[i] = engine.MeasureSLO(cmd.Context(), slo)
+		}
+
+		if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+			type sloJSON struct {
+				alerts.SLOStatus
+				Error string `json:"error,omitempty"`
+			}
+			out := make([]sloJSON, len(statuses))
+			for i, status := range statuses {
+				out[i] = sloJSON{SLOStatus: status}
+				if status.Err != nil {
+					out[i].Error = status.Err.Error()
+				}
+			}
+			encoder := json.NewEncoder(stdout)
+			encoder.SetIndent("", "  ")
+			return encoder.Encode(out)
+		}
+
+		if len(slos) == 0 {
+			fmt.Fprintln(stdout, "📭 No SLOs defined.")
+			fmt.Fprintln(stdout, "💡 Add one with: peep slo add api --target 99.9 --window 30d --good \"SELECT COUNT(*) FROM logs WHERE service = 'api' AND level != 'error'\" --total \"SELECT COUNT(*) FROM logs WHERE service = 'api'\"")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "SLO\tTARGET\tWINDOW\tSLI\tBUDGET LEFT\tBURN (1h)\tEVENTS")
+		for _, status := range statuses {
+			slo := status.SLO
+			if status.Err != nil {
+				fmt.Fprintf(w, "%s\t%s%%\t%s\terror: %v\t\t\t\n", slo.Name, alerts.FormatMetricValue(slo.Target), slo.Window, status.Err)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s%%\t%s\t%s%%\t%s%%\t%s\t%s\n",
+				slo.Name, alerts.FormatMetricValue(slo.Target), slo.Window,
+				alerts.FormatMetricValue(status.SLI), alerts.FormatMetricValue(status.BudgetRemaining),
+				alerts.FormatMetricValue(status.BurnRate), alerts.FormatMetricValue(status.Total))
+		}
+		return w.Flush()
+	},
+}
+
+var sloRemoveCmd = &cobra.Command{
+	Use:   "remove [name]",
+	Short: "Remove an SLO",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		engine, closeStore, err := openAlertEngine()
+		if err != nil {
+			return err
+		}
+		defer closeStore()
+
+		if err := engine.RemoveSLO(args[0]); err != nil {
+			return fmt.Errorf("failed to remove SLO: %w", err)
+		}
+		fmt.Fprintf(stdout, "✅ SLO '%s' removed\n", args[0])
+		return nil
+	},
+}
+
+// printSLOStatus prints an SLO's current state under its name
+func printSLOStatus(status alerts.SLOStatus) {
+	fmt.Fprintf(stdout, "   SLI: %s%% of %s events\n", alerts.FormatMetricValue(status.SLI), alerts.FormatMetricValue(status.Total))
+	fmt.Fprintf(stdout, "   Budget left: %s%%\n", alerts.FormatMetricValue(status.BudgetRemaining))
+	fmt.Fprintf(stdout, "   Burn rate (1h): %s\n", alerts.FormatMetricValue(status.BurnRate))
+}
+
+// openAlertEngine opens the log database and the alert engine on it
+func openAlertEngine() (*alerts.Engine, func(), error) {
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		store.Close()
+		return nil, nil, fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+	return engine, func() { store.Close() }, nil
+}
+
+func init() {
+	sloAddCmd.Flags().String("good", "", "SQL counting good events (required)")
+	sloAddCmd.Flags().String("total", "", "SQL counting all events (required)")
+	sloAddCmd.Flags().Float64("target", 99.9, "Percent of events that should be good")
+	sloAddCmd.Flags().String("window", "30d", "Rolling window the target applies to (e.g., 30d, 7d, 24h)")
+	sloAddCmd.Flags().StringP("description", "d", "", "What the SLO promises")
+	sloAddCmd.MarkFlagRequired("good")
+	sloAddCmd.MarkFlagRequired("total")
+
+	sloListCmd.Flags().Bool("json", false, "Print the SLOs and their status as JSON")
+
+	sloCmd.AddCommand(sloAddCmd)
+	sloCmd.AddCommand(sloListCmd)
+	sloCmd.AddCommand(sloRemoveCmd)
+}
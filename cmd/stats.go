@@ -1,10 +1,16 @@
 package cmd
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"math"
 	"os"
+	"os/signal"
 	"runtime"
+	"sort"
+	"syscall"
 	"time"
 
 	"github.com/kylereynolds/peep/internal/storage"
@@ -12,8 +18,10 @@ import (
 )
 
 var (
-	detailed bool
-	json     bool
+	detailed      bool
+	jsonOutput    bool
+	watchStats    bool
+	watchInterval time.Duration
 )
 
 var statsCmd = &cobra.Command{
@@ -25,17 +33,29 @@ log counts, storage size, performance metrics, and system health.
 Examples:
   peep stats                    # Basic stats
   peep stats --detailed         # Detailed breakdown by level and service
-  peep stats --json             # JSON output for scripting`,
+  peep stats --json             # JSON output for scripting, with per-service
+                                # counts and SQLite internals
+  peep stats --watch            # Refresh every 5s, with logs/min since the last refresh
+  peep stats --watch -n 30s --detailed`,
 	RunE: runStats,
 }
 
 func init() {
 	statsCmd.Flags().BoolVar(&detailed, "detailed", false, "Show detailed breakdown by log level and service")
-	statsCmd.Flags().BoolVar(&json, "json", false, "Output stats in JSON format")
+	statsCmd.Flags().BoolVar(&jsonOutput, "json", false, "Output stats in JSON format")
+	statsCmd.Flags().BoolVarP(&watchStats, "watch", "w", false, "Keep refreshing, showing what was ingested since the last refresh")
+	statsCmd.Flags().DurationVarP(&watchInterval, "interval", "n", 5*time.Second, "How often to refresh with --watch")
 }
 
 func runStats(cmd *cobra.Command, args []string) error {
-	store, err := storage.NewStorage("logs.db")
+	if watchStats && jsonOutput {
+		return fmt.Errorf("--watch can't be combined with --json")
+	}
+	if watchInterval < time.Second {
+		return fmt.Errorf("--interval must be at least 1s")
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
 	if err != nil {
 		return fmt.Errorf("failed to initialize storage: %w", err)
 	}
@@ -43,21 +63,103 @@ func runStats(cmd *cobra.Command, args []string) error {
 
 	db := store.GetDB()
 
-	if json {
+	if jsonOutput {
 		return printJSONStats(db)
 	}
+	if watchStats {
+		return watchHumanStats(store)
+	}
 
 	return printHumanStats(db)
 }
 
+// watchHumanStats redraws the stats every watchInterval until interrupted,
+// followed by what was ingested since the previous refresh
+func watchHumanStats(store *storage.Storage) error {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Deltas count logs by ID, so deletions by retention don't show as negative rates
+	_, lastID, err := store.CountAfter(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("failed to count logs: %w", err)
+	}
+	lastAt := time.Now()
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	var counts []storage.ServiceLevelCount
+	var elapsed time.Duration
+	for {
+		fmt.Fprint(stdout, "\033[H\033[2J")
+		fmt.Fprintf(stdout, "🔄 Every %s - %s (Ctrl+C to quit)\n\n", watchInterval, time.Now().Format("15:04:05"))
+		if err := printHumanStats(store.GetDB()); err != nil {
+			return err
+		}
+		printStatsDelta(counts, elapsed)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		var newest int64
+		if counts, newest, err = store.CountAfter(ctx, lastID); err != nil {
+			return fmt.Errorf("failed to count logs: %w", err)
+		}
+		now := time.Now()
+		elapsed = now.Sub(lastAt)
+		lastID, lastAt = newest, now
+	}
+}
+
+// printStatsDelta shows how many logs of each level arrived over elapsed,
+// which is zero before the first refresh
+func printStatsDelta(counts []storage.ServiceLevelCount, elapsed time.Duration) {
+	if elapsed == 0 {
+		fmt.Fprintln(stdout, "\n📈 Since Last Refresh: measuring...")
+		return
+	}
+
+	var total int64
+	byLevel := make(map[string]int64)
+	for _, c := range counts {
+		total += c.Count
+		byLevel[c.Level] += c.Count
+	}
+
+	fmt.Fprintf(stdout, "\n📈 Since Last Refresh (%s): +%d logs, %.1f logs/min\n",
+		elapsed.Round(time.Second), total, float64(total)/elapsed.Minutes())
+
+	levels := make([]string, 0, len(byLevel))
+	for level := range byLevel {
+		levels = append(levels, level)
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		if byLevel[levels[i]] != byLevel[levels[j]] {
+			return byLevel[levels[i]] > byLevel[levels[j]]
+		}
+		return levels[i] < levels[j]
+	})
+	for _, level := range levels {
+		name := level
+		if name == "" {
+			name = "(none)"
+		}
+		fmt.Fprintf(stdout, "  %s: +%d\n", name, byLevel[level])
+	}
+}
+
 func printHumanStats(db *sql.DB) error {
-	fmt.Println("📊 Peep Database Statistics")
-	fmt.Println("========================================")
+	fmt.Fprintln(stdout, "📊 Peep Database Statistics")
+	fmt.Fprintln(stdout, "========================================")
 
 	// Database file info
-	if info, err := os.Stat("logs.db"); err == nil {
-		fmt.Printf("💾 Database Size: %.2f MB\n", float64(info.Size())/(1024*1024))
-		fmt.Printf("📅 Last Modified: %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
+	if info, err := os.Stat(cfg.DBPath); err == nil {
+		fmt.Fprintf(stdout, "💾 Database Size: %.2f MB\n", float64(info.Size())/(1024*1024))
+		fmt.Fprintf(stdout, "📅 Last Modified: %s\n", info.ModTime().Format("2006-01-02 15:04:05"))
 	}
 
 	// Log counts
@@ -66,10 +168,10 @@ func printHumanStats(db *sql.DB) error {
 	if err != nil {
 		return fmt.Errorf("failed to count logs: %w", err)
 	}
-	fmt.Printf("📝 Total Logs: %d\n", totalLogs)
+	fmt.Fprintf(stdout, "📝 Total Logs: %d\n", totalLogs)
 
 	if totalLogs == 0 {
-		fmt.Println("\n🔍 No logs found in database")
+		fmt.Fprintln(stdout, "\n🔍 No logs found in database")
 		return nil
 	}
 
@@ -101,16 +203,16 @@ func printHumanStats(db *sql.DB) error {
 			newestTime, err2 = time.Parse(time.RFC3339, newest)
 		}
 
-		fmt.Printf("⏰ Time Range: %s to %s\n", oldest, newest)
+		fmt.Fprintf(stdout, "⏰ Time Range: %s to %s\n", oldest, newest)
 
 		if err1 == nil && err2 == nil {
 			duration := newestTime.Sub(oldestTime)
-			fmt.Printf("⏱️  Duration: %s\n", formatDuration(duration))
+			fmt.Fprintf(stdout, "⏱️  Duration: %s\n", formatDuration(duration))
 		}
 	}
 
 	// Log levels breakdown
-	fmt.Println("\n📊 Log Levels:")
+	fmt.Fprintln(stdout, "\n📊 Log Levels:")
 	rows, err := db.Query(`
 		SELECT level, COUNT(*) as count, 
 		ROUND(COUNT(*) * 100.0 / (SELECT COUNT(*) FROM logs), 1) as percentage
@@ -131,12 +233,12 @@ func printHumanStats(db *sql.DB) error {
 		if err := rows.Scan(&level, &count, &percentage); err != nil {
 			continue
 		}
-		fmt.Printf("  %s: %d (%.1f%%)\n", level, count, percentage)
+		fmt.Fprintf(stdout, "  %s: %d (%.1f%%)\n", level, count, percentage)
 	}
 
 	// Services breakdown (if detailed)
 	if detailed {
-		fmt.Println("\n🔧 Services:")
+		fmt.Fprintln(stdout, "\n🔧 Services:")
 		rows, err := db.Query(`
 			SELECT service, COUNT(*) as count
 			FROM logs 
@@ -153,130 +255,175 @@ func printHumanStats(db *sql.DB) error {
 				if err := rows.Scan(&service, &count); err != nil {
 					continue
 				}
-				fmt.Printf("  %s: %d logs\n", service, count)
+				fmt.Fprintf(stdout, "  %s: %d logs\n", service, count)
 			}
 		}
 
 		// Recent activity
-		fmt.Println("\n📈 Recent Activity (last 24 hours):")
+		fmt.Fprintln(stdout, "\n📈 Recent Activity (last 24 hours):")
 		var recent24h int
 		err = db.QueryRow("SELECT COUNT(*) FROM logs WHERE timestamp > datetime('now', '-24 hours')").Scan(&recent24h)
 		if err == nil {
-			fmt.Printf("  Last 24h: %d logs\n", recent24h)
+			fmt.Fprintf(stdout, "  Last 24h: %d logs\n", recent24h)
 		}
 
 		var recent1h int
 		err = db.QueryRow("SELECT COUNT(*) FROM logs WHERE timestamp > datetime('now', '-1 hour')").Scan(&recent1h)
 		if err == nil {
-			fmt.Printf("  Last 1h: %d logs\n", recent1h)
+			fmt.Fprintf(stdout, "  Last 1h: %d logs\n", recent1h)
 		}
 	}
 
 	// Performance info
-	fmt.Println("\n⚡ Performance:")
+	fmt.Fprintln(stdout, "\n⚡ Performance:")
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	fmt.Printf("  Memory Usage: %.2f MB\n", float64(m.Alloc)/(1024*1024))
-	fmt.Printf("  Go Routines: %d\n", runtime.NumGoroutine())
+	fmt.Fprintf(stdout, "  Memory Usage: %.2f MB\n", float64(m.Alloc)/(1024*1024))
+	fmt.Fprintf(stdout, "  Go Routines: %d\n", runtime.NumGoroutine())
 
 	// Alert rules count
 	var alertCount int
 	err = db.QueryRow("SELECT COUNT(*) FROM alert_rules WHERE enabled = 1").Scan(&alertCount)
 	if err == nil && alertCount > 0 {
-		fmt.Printf("\n🚨 Active Alert Rules: %d\n", alertCount)
+		fmt.Fprintf(stdout, "\n🚨 Active Alert Rules: %d\n", alertCount)
 	}
 
 	return nil
 }
 
-func printJSONStats(db *sql.DB) error {
-	stats := make(map[string]interface{})
+// jsonStats is the document printed by peep stats --json. The flat fields
+// predate the nested ones and keep their names for existing scripts.
+type jsonStats struct {
+	DatabaseSizeBytes int64          `json:"database_size_bytes"`
+	DatabaseSizeMB    float64        `json:"database_size_mb"`
+	LastModified      int64          `json:"last_modified,omitempty"`
+	TotalLogs         int            `json:"total_logs"`
+	OldestLog         string         `json:"oldest_log,omitempty"`
+	NewestLog         string         `json:"newest_log,omitempty"`
+	Levels            map[string]int `json:"levels"`
+	Services          []serviceJSON  `json:"services"`
+	SQLite            sqliteJSON     `json:"sqlite"`
+	MemoryUsageBytes  uint64         `json:"memory_usage_bytes"`
+	MemoryUsageMB     float64        `json:"memory_usage_mb"`
+	Goroutines        int            `json:"goroutines"`
+	ActiveAlertRules  int            `json:"active_alert_rules"`
+	Timestamp         int64          `json:"timestamp"`
+}
 
-	// Database file info
-	if info, err := os.Stat("logs.db"); err == nil {
-		stats["database_size_bytes"] = info.Size()
-		stats["database_size_mb"] = float64(info.Size()) / (1024 * 1024)
-		stats["last_modified"] = info.ModTime().Unix()
+// serviceJSON is one service's share of the logs
+type serviceJSON struct {
+	Service  string `json:"service"` // Empty for logs without a service
+	Total    int    `json:"total"`
+	Errors   int    `json:"errors"`
+	Warnings int    `json:"warnings"`
+	Last24h  int    `json:"last_24h"`
+	LastSeen string `json:"last_seen,omitempty"`
+}
+
+// sqliteJSON reports SQLite's view of the database file
+type sqliteJSON struct {
+	Version       string `json:"version"`
+	JournalMode   string `json:"journal_mode"`
+	PageSize      int64  `json:"page_size"`
+	PageCount     int64  `json:"page_count"`
+	FreelistCount int64  `json:"freelist_count"` // Unused pages a VACUUM would reclaim
+	Indexes       int    `json:"indexes"`
+	Tables        int    `json:"tables"`
+}
+
+func printJSONStats(db *sql.DB) error {
+	stats := jsonStats{
+		Levels:    make(map[string]int),
+		Services:  []serviceJSON{},
+		Timestamp: time.Now().Unix(),
 	}
 
-	// Log counts
-	var totalLogs int
-	if err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&totalLogs); err == nil {
-		stats["total_logs"] = totalLogs
+	// Database file info
+	if info, err := os.Stat(cfg.DBPath); err == nil {
+		stats.DatabaseSizeBytes = info.Size()
+		stats.DatabaseSizeMB = roundTo(float64(info.Size())/(1024*1024), 2)
+		stats.LastModified = info.ModTime().Unix()
 	}
 
-	// Time range
-	var oldest, newest string
-	if err := db.QueryRow("SELECT MIN(timestamp), MAX(timestamp) FROM logs").Scan(&oldest, &newest); err == nil {
-		stats["oldest_log"] = oldest
-		stats["newest_log"] = newest
+	// Log counts and time range
+	var oldest, newest sql.NullString
+	err := db.QueryRow("SELECT COUNT(*), MIN(timestamp), MAX(timestamp) FROM logs").Scan(&stats.TotalLogs, &oldest, &newest)
+	if err != nil {
+		return fmt.Errorf("failed to count logs: %w", err)
 	}
+	stats.OldestLog, stats.NewestLog = oldest.String, newest.String
 
 	// Log levels
-	levels := make(map[string]int)
 	rows, err := db.Query("SELECT level, COUNT(*) FROM logs WHERE level != '' GROUP BY level")
-	if err == nil {
-		defer rows.Close()
-		for rows.Next() {
-			var level string
-			var count int
-			if rows.Scan(&level, &count) == nil {
-				levels[level] = count
-			}
+	if err != nil {
+		return fmt.Errorf("failed to get log levels: %w", err)
+	}
+	for rows.Next() {
+		var level string
+		var count int
+		if err := rows.Scan(&level, &count); err != nil {
+			rows.Close()
+			return err
 		}
-		stats["levels"] = levels
+		stats.Levels[level] = count
 	}
+	rows.Close()
+
+	// Services, busiest first
+	rows, err = db.Query(`
+		SELECT COALESCE(service, ''), COUNT(*),
+			SUM(level = 'error'),
+			SUM(level IN ('warn', 'warning')),
+			SUM(timestamp > datetime('now', '-24 hours')),
+			MAX(timestamp)
+		FROM logs
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to get services: %w", err)
+	}
+	for rows.Next() {
+		var service serviceJSON
+		var lastSeen sql.NullString
+		if err := rows.Scan(&service.Service, &service.Total, &service.Errors, &service.Warnings, &service.Last24h, &lastSeen); err != nil {
+			rows.Close()
+			return err
+		}
+		service.LastSeen = lastSeen.String
+		stats.Services = append(stats.Services, service)
+	}
+	rows.Close()
+
+	// SQLite internals
+	sqlite := &stats.SQLite
+	db.QueryRow("SELECT sqlite_version()").Scan(&sqlite.Version)
+	db.QueryRow("PRAGMA journal_mode").Scan(&sqlite.JournalMode)
+	db.QueryRow("PRAGMA page_size").Scan(&sqlite.PageSize)
+	db.QueryRow("PRAGMA page_count").Scan(&sqlite.PageCount)
+	db.QueryRow("PRAGMA freelist_count").Scan(&sqlite.FreelistCount)
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&sqlite.Tables)
+	db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index'").Scan(&sqlite.Indexes)
 
 	// Performance
 	var m runtime.MemStats
 	runtime.ReadMemStats(&m)
-	stats["memory_usage_bytes"] = m.Alloc
-	stats["memory_usage_mb"] = float64(m.Alloc) / (1024 * 1024)
-	stats["goroutines"] = runtime.NumGoroutine()
-
-	// Alert rules
-	var alertCount int
-	if err := db.QueryRow("SELECT COUNT(*) FROM alert_rules WHERE enabled = 1").Scan(&alertCount); err == nil {
-		stats["active_alert_rules"] = alertCount
-	}
+	stats.MemoryUsageBytes = m.Alloc
+	stats.MemoryUsageMB = roundTo(float64(m.Alloc)/(1024*1024), 2)
+	stats.Goroutines = runtime.NumGoroutine()
 
-	stats["timestamp"] = time.Now().Unix()
+	// Alert rules; the table is missing until the alert engine first runs
+	db.QueryRow("SELECT COUNT(*) FROM alert_rules WHERE enabled = 1").Scan(&stats.ActiveAlertRules)
 
-	// Print JSON
-	fmt.Printf("{\n")
-	first := true
-	for key, value := range stats {
-		if !first {
-			fmt.Printf(",\n")
-		}
-		first = false
-		switch v := value.(type) {
-		case string:
-			fmt.Printf("  \"%s\": \"%s\"", key, v)
-		case int:
-			fmt.Printf("  \"%s\": %d", key, v)
-		case int64:
-			fmt.Printf("  \"%s\": %d", key, v)
-		case float64:
-			fmt.Printf("  \"%s\": %.2f", key, v)
-		case map[string]int:
-			fmt.Printf("  \"%s\": {", key)
-			firstLevel := true
-			for k, count := range v {
-				if !firstLevel {
-					fmt.Printf(", ")
-				}
-				firstLevel = false
-				fmt.Printf("\"%s\": %d", k, count)
-			}
-			fmt.Printf("}")
-		default:
-			fmt.Printf("  \"%s\": \"%v\"", key, v)
-		}
-	}
-	fmt.Printf("\n}\n")
+	encoder := json.NewEncoder(stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(stats)
+}
 
-	return nil
+// roundTo rounds v to the given number of decimal places
+func roundTo(v float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(v*scale) / scale
 }
 
 func formatDuration(d time.Duration) string {
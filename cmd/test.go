@@ -6,14 +6,19 @@ import (
 	"strings"
 	"time"
 
+	"github.com/kylereynolds/peep/internal/alerts"
 	"github.com/kylereynolds/peep/internal/notifications"
+	"github.com/kylereynolds/peep/internal/storage"
 	"github.com/spf13/cobra"
 )
 
 var testCmd = &cobra.Command{
 	Use:   "test",
 	Short: "Test notification channels",
-	Long:  `Send test notifications to verify your channels are working correctly.`,
+	Long: `Send test notifications to verify your channels are working correctly.
+
+Test a channel you've already saved by name, without retyping its settings:
+  peep test channel "Team Slack"`,
 }
 
 var testSlackCmd = &cobra.Command{
@@ -24,23 +29,23 @@ var testSlackCmd = &cobra.Command{
 Example:
   peep test slack https://hooks.slack.com/services/YOUR/WEBHOOK/URL`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		webhookURL := args[0]
 
-		fmt.Println("📱 Sending test Slack notification...")
+		fmt.Fprintln(stdout, "📱 Sending test Slack notification...")
 
 		title := "Test Alert"
 		message := "This is a test notification from Peep! If you can see this, your Slack integration is working perfectly."
 
 		err := notifications.SendSlackNotification(webhookURL, title, message, 5, 3)
 		if err != nil {
-			fmt.Printf("❌ Failed to send Slack notification: %v\n", err)
-			fmt.Println("💡 Check your webhook URL and try again")
-			return
+			return withHint(fmt.Errorf("failed to send Slack notification: %w", err), "💡 Check your webhook URL and try again")
 		}
 
-		fmt.Println("✅ Test notification sent successfully!")
-		fmt.Println("🎉 Check your Slack channel to see the message")
+		fmt.Fprintln(stdout, "✅ Test notification sent successfully!")
+		fmt.Fprintln(stdout, "🎉 Check your Slack channel to see the message")
+
+		return nil
 	},
 }
 
@@ -48,18 +53,18 @@ var testDesktopCmd = &cobra.Command{
 	Use:   "desktop",
 	Short: "Test desktop notification",
 	Long:  `Send a test desktop notification to verify it's working on your system.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println("🖥️  Sending test desktop notification...")
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Fprintln(stdout, "🖥️  Sending test desktop notification...")
 
 		err := notifications.SendDesktopNotification("Peep Test", "This is a test notification from Peep!")
 		if err != nil {
-			fmt.Printf("❌ Failed to send desktop notification: %v\n", err)
-			fmt.Println("💡 Desktop notifications may not be supported on your system")
-			return
+			return withHint(fmt.Errorf("failed to send desktop notification: %w", err), "💡 Desktop notifications may not be supported on your system")
 		}
 
-		fmt.Println("✅ Test notification sent successfully!")
-		fmt.Println("🎉 You should see a desktop notification now")
+		fmt.Fprintln(stdout, "✅ Test notification sent successfully!")
+		fmt.Fprintln(stdout, "🎉 You should see a desktop notification now")
+
+		return nil
 	},
 }
 
@@ -70,7 +75,7 @@ var testEmailCmd = &cobra.Command{
 	
 Example:
   peep test email --smtp-host smtp.gmail.com --username user@gmail.com --password app-password --from user@gmail.com --to recipient@example.com`,
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Get configuration from flags
 		smtpHost, _ := cmd.Flags().GetString("smtp-host")
 		smtpPort, _ := cmd.Flags().GetString("smtp-port")
@@ -80,14 +85,19 @@ Example:
 		fromName, _ := cmd.Flags().GetString("from-name")
 		toEmail, _ := cmd.Flags().GetString("to")
 
-		if smtpHost == "" || username == "" || password == "" || fromEmail == "" || toEmail == "" {
-			fmt.Println("❌ Email test requires SMTP configuration")
-			fmt.Println("💡 Required flags: --smtp-host, --username, --password, --from, --to")
-			fmt.Println("💡 Example: peep test email --smtp-host smtp.gmail.com --username user@gmail.com --password app-password --from user@gmail.com --to recipient@example.com")
-			return
+		security, _ := cmd.Flags().GetString("security")
+		auth, _ := cmd.Flags().GetString("auth")
+		caFile, _ := cmd.Flags().GetString("ca-file")
+		skipVerify, _ := cmd.Flags().GetBool("skip-verify")
+
+		if smtpHost == "" || fromEmail == "" || toEmail == "" {
+			return withHint(fmt.Errorf("email test requires SMTP configuration"),
+				"💡 Required flags: --smtp-host, --from, --to (plus --username/--password unless the relay needs no auth)",
+				"💡 Example: peep test email --smtp-host smtp.gmail.com --username user@gmail.com --password app-password --from user@gmail.com --to recipient@example.com",
+			)
 		}
 
-		fmt.Println("📧 Sending test email notification...")
+		fmt.Fprintln(stdout, "📧 Sending test email notification...")
 
 		// Parse SMTP port
 		port := 587 // default
@@ -105,19 +115,24 @@ Example:
 			FromEmail: fromEmail,
 			FromName:  fromName,
 			ToEmails:  []string{toEmail},
+
+			Security:           security,
+			AuthMethod:         auth,
+			CAFile:             caFile,
+			InsecureSkipVerify: skipVerify,
 		}
 
 		emailNotifier := notifications.NewEmailNotification(emailConfig)
 
 		err := emailNotifier.TestConnection()
 		if err != nil {
-			fmt.Printf("❌ Failed to send email notification: %v\n", err)
-			fmt.Println("💡 Check your SMTP configuration and try again")
-			return
+			return withHint(fmt.Errorf("failed to send email notification: %w", err), "💡 Check your SMTP configuration and try again")
 		}
 
-		fmt.Println("✅ Test email sent successfully!")
-		fmt.Printf("🎉 Check %s for the test message\n", toEmail)
+		fmt.Fprintln(stdout, "✅ Test email sent successfully!")
+		fmt.Fprintf(stdout, "🎉 Check %s for the test message\n", toEmail)
+
+		return nil
 	},
 }
 
@@ -130,7 +145,7 @@ Example:
   peep test shell ./alert-handler.sh
   peep test shell /path/to/script.sh --timeout 60s --args "--verbose"`,
 	Args: cobra.ExactArgs(1),
-	Run: func(cmd *cobra.Command, args []string) {
+	RunE: func(cmd *cobra.Command, args []string) error {
 		scriptPath := args[0]
 
 		// Get configuration from flags
@@ -139,7 +154,7 @@ Example:
 		workingDir, _ := cmd.Flags().GetString("working-dir")
 		envStr, _ := cmd.Flags().GetString("env")
 
-		fmt.Printf("🖥️  Testing shell script: %s\n", scriptPath)
+		fmt.Fprintf(stdout, "🖥️  Testing shell script: %s\n", scriptPath)
 
 		// Parse timeout
 		timeout := 30 * time.Second
@@ -177,22 +192,84 @@ Example:
 
 		err := shellNotifier.TestScript()
 		if err != nil {
-			fmt.Printf("❌ Failed to execute shell script: %v\n", err)
-			fmt.Println("💡 Check script path, permissions, and try again")
-			return
+			return withHint(fmt.Errorf("failed to execute shell script: %w", err), "💡 Check script path, permissions, and try again")
 		}
 
-		fmt.Println("✅ Shell script executed successfully!")
-		fmt.Printf("🎉 Script %s handled the test alert\n", scriptPath)
+		fmt.Fprintln(stdout, "✅ Shell script executed successfully!")
+		fmt.Fprintf(stdout, "🎉 Script %s handled the test alert\n", scriptPath)
+
+		return nil
 	},
 }
 
+var testChannelCmd = &cobra.Command{
+	Use:   "channel [name]",
+	Short: "Test a saved notification channel by name",
+	Long: `Send a test notification through a channel saved with 'peep alerts channels add'
+(or from the web UI), using its stored webhook, SMTP, or script settings.
+
+Examples:
+  peep test channel "Team Slack"
+  peep test channel "Desktop Notifications"`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		name := args[0]
+
+		store, err := storage.NewStorage(cfg.DBPath)
+		if err != nil {
+			return fmt.Errorf("failed to initialize storage: %w", err)
+		}
+		defer store.Close()
+
+		engine, err := alerts.NewEngine(store)
+		if err != nil {
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
+		}
+
+		channel := findChannel(engine.GetChannels(), name)
+		if channel == nil {
+			return withHint(fmt.Errorf("no notification channel named %q", name), "💡 See your channels with: peep alerts channels list")
+		}
+		if !channel.Enabled {
+			fmt.Fprintf(stderr, "⚠️  Channel %q is disabled; alerts won't use it, but testing anyway\n", channel.Name)
+		}
+
+		fmt.Fprintf(stdout, "🧪 Sending test notification through %s (%s)...\n", channel.Name, channel.Type)
+		if err := engine.TestChannel(channel.ID); err != nil {
+			return fmt.Errorf("test failed: %w", err)
+		}
+
+		fmt.Fprintln(stdout, "✅ Test notification sent successfully!")
+
+		return nil
+	},
+}
+
+// findChannel looks a channel up by name, falling back to a case-insensitive match
+func findChannel(channels []*alerts.NotificationChannel, name string) *alerts.NotificationChannel {
+	for _, channel := range channels {
+		if channel.Name == name {
+			return channel
+		}
+	}
+	for _, channel := range channels {
+		if strings.EqualFold(channel.Name, name) {
+			return channel
+		}
+	}
+	return nil
+}
+
 func init() {
 	// Add email test flags
 	testEmailCmd.Flags().StringP("smtp-host", "", "", "SMTP server hostname (e.g., smtp.gmail.com)")
 	testEmailCmd.Flags().StringP("smtp-port", "", "587", "SMTP server port (default: 587)")
 	testEmailCmd.Flags().StringP("username", "", "", "SMTP username/email")
 	testEmailCmd.Flags().StringP("password", "", "", "SMTP password (use app password for Gmail)")
+	testEmailCmd.Flags().StringP("security", "", "", "Connection security: tls, starttls, or none (default: implicit TLS on 465, STARTTLS when offered)")
+	testEmailCmd.Flags().StringP("auth", "", "", "SMTP auth mechanism: plain, login, cram-md5, or none (default: plain when --username is set)")
+	testEmailCmd.Flags().StringP("ca-file", "", "", "PEM CA bundle used to verify the SMTP server")
+	testEmailCmd.Flags().Bool("skip-verify", false, "Skip SMTP server certificate verification")
 	testEmailCmd.Flags().StringP("from", "", "", "From email address")
 	testEmailCmd.Flags().StringP("from-name", "", "Peep Test", "From display name")
 	testEmailCmd.Flags().StringP("to", "", "", "Recipient email address")
@@ -207,4 +284,5 @@ func init() {
 	testCmd.AddCommand(testDesktopCmd)
 	testCmd.AddCommand(testEmailCmd)
 	testCmd.AddCommand(testShellCmd)
+	testCmd.AddCommand(testChannelCmd)
 }
@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/kylereynolds/peep/internal/tokens"
+	"github.com/spf13/cobra"
+)
+
+var tokensCmd = &cobra.Command{
+	Use:   "tokens",
+	Short: "Manage API tokens",
+	Long: `Create, list, and revoke scoped API tokens for the HTTP API.
+
+Tokens are sent as "Authorization: Bearer <token>" and are only stored hashed,
+so the secret is shown once when it is created.
+
+Scopes:
+  ingest - Send logs to /api/ingest, or the --http listener, only
+  read   - Read-only access to the web UI and API
+  admin  - Full access
+
+Examples:
+  peep tokens create "CI shipper" --scope ingest
+  peep tokens list
+  peep tokens revoke 3`,
+}
+
+var tokensCreateCmd = &cobra.Command{
+	Use:   "create [name]",
+	Short: "Create an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		scopeName, _ := cmd.Flags().GetString("scope")
+		scope, err := tokens.ParseScope(scopeName)
+		if err != nil {
+			return err
+		}
+
+		tokenStore, closeStore, err := openTokenStore()
+		if err != nil {
+			return fmt.Errorf("failed to initialize API tokens: %w", err)
+		}
+		defer closeStore()
+
+		secret, token, err := tokenStore.Create(args[0], scope)
+		if err != nil {
+			return fmt.Errorf("failed to create token: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Token '%s' created (id %d, scope %s)\n", token.Name, token.ID, token.Scope)
+		fmt.Fprintln(stdout)
+		fmt.Fprintf(stdout, "   %s\n", secret)
+		fmt.Fprintln(stdout)
+		fmt.Fprintln(stdout, "⚠️  Copy it now: the token can't be shown again.")
+		fmt.Fprintln(stdout, "💡 Use it with: curl -H \"Authorization: Bearer <token>\" ...")
+
+		return nil
+	},
+}
+
+var tokensListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List API tokens",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokenStore, closeStore, err := openTokenStore()
+		if err != nil {
+			return fmt.Errorf("failed to initialize API tokens: %w", err)
+		}
+		defer closeStore()
+
+		list, err := tokenStore.List()
+		if err != nil {
+			return fmt.Errorf("failed to list tokens: %w", err)
+		}
+
+		if len(list) == 0 {
+			fmt.Fprintln(stdout, "📭 No API tokens.")
+			fmt.Fprintln(stdout, "💡 Create one with: peep tokens create \"My Token\" --scope read")
+			return nil
+		}
+
+		fmt.Fprintf(stdout, "🔑 API Tokens (%d):\n\n", len(list))
+		for _, token := range list {
+			status := "🟢 Active"
+			if token.Revoked {
+				status = "🔴 Revoked"
+			}
+
+			fmt.Fprintf(stdout, "%s [%d] %s\n", status, token.ID, token.Name)
+			fmt.Fprintf(stdout, "   Scope: %s\n", token.Scope)
+			fmt.Fprintf(stdout, "   Token: %s...\n", token.Prefix)
+			fmt.Fprintf(stdout, "   Created: %s\n", token.CreatedAt.Format("2006-01-02 15:04:05"))
+			if !token.LastUsed.IsZero() {
+				fmt.Fprintf(stdout, "   Last Used: %s\n", token.LastUsed.Format("2006-01-02 15:04:05"))
+			}
+			fmt.Fprintln(stdout)
+		}
+
+		return nil
+	},
+}
+
+var tokensRevokeCmd = &cobra.Command{
+	Use:   "revoke [id]",
+	Short: "Revoke an API token",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.ParseInt(args[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid token id: %s", args[0])
+		}
+
+		tokenStore, closeStore, err := openTokenStore()
+		if err != nil {
+			return fmt.Errorf("failed to initialize API tokens: %w", err)
+		}
+		defer closeStore()
+
+		if err := tokenStore.Revoke(id); err != nil {
+			return fmt.Errorf("failed to revoke token: %w", err)
+		}
+
+		fmt.Fprintf(stdout, "✅ Token %d revoked\n", id)
+
+		return nil
+	},
+}
+
+// openTokenStore opens the log database and its token table
+func openTokenStore() (*tokens.Store, func(), error) {
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tokenStore, err := tokens.NewStore(store.GetDB())
+	if err != nil {
+		store.Close()
+		return nil, nil, err
+	}
+
+	return tokenStore, func() { store.Close() }, nil
+}
+
+func init() {
+	tokensCreateCmd.Flags().StringP("scope", "s", "read", "Token scope: ingest, read, or admin")
+
+	tokensCmd.AddCommand(tokensCreateCmd)
+	tokensCmd.AddCommand(tokensListCmd)
+	tokensCmd.AddCommand(tokensRevokeCmd)
+}
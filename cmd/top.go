@@ -0,0 +1,268 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var topCmd = &cobra.Command{
+	Use:   "top",
+	Short: "Live summary of log rates, top errors, and recent alerts",
+	Long: `Show a continuously refreshing summary of what's being logged, like htop for
+your logs: logs/sec per service broken down by level, the most frequent error
+messages, and the newest alerts.
+
+Rates are measured between refreshes, so they show what's arriving right now.
+
+Examples:
+  peep top                      # Refresh every 2 seconds
+  peep top --interval 5s --errors-since 15m
+  peep top --db /var/lib/peep/logs.db`,
+	RunE: runTop,
+}
+
+func init() {
+	topCmd.Flags().Duration("interval", 2*time.Second, "How often to refresh")
+	topCmd.Flags().String("errors-since", "1h", "How far back to look for top error messages (e.g. 15m, 1h, 1d)")
+	topCmd.Flags().Int("rows", 10, "Rows to show in each section")
+}
+
+// topLevels are the level columns, in display order
+var topLevels = []string{"error", "warn", "info", "debug", "other"}
+
+// topLevel maps a stored level onto one of the columns
+func topLevel(level string) string {
+	switch level {
+	case "error", "err", "fatal", "critical":
+		return "error"
+	case "warn", "warning":
+		return "warn"
+	case "info", "debug":
+		return level
+	default:
+		return "other"
+	}
+}
+
+// serviceRate is one row of the rates table
+type serviceRate struct {
+	service string
+	total   float64
+	levels  map[string]float64
+}
+
+func runTop(cmd *cobra.Command, args []string) error {
+	interval, _ := cmd.Flags().GetDuration("interval")
+	errorsSinceStr, _ := cmd.Flags().GetString("errors-since")
+	rows, _ := cmd.Flags().GetInt("rows")
+
+	if interval < 100*time.Millisecond {
+		return fmt.Errorf("--interval must be at least 100ms")
+	}
+	errorsSince, err := parseDuration(errorsSinceStr)
+	if err != nil {
+		return fmt.Errorf("invalid --errors-since %q: %w", errorsSinceStr, err)
+	}
+	if rows <= 0 {
+		return fmt.Errorf("--rows must be positive")
+	}
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		return fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start counting from the newest log, so the first rates aren't the whole table
+	_, lastID, err := store.CountAfter(ctx, 0)
+	if err != nil {
+		return fmt.Errorf("failed to read logs: %w", err)
+	}
+	lastAt := time.Now()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var rates []serviceRate
+	var stats storage.Stats
+	var statsAt time.Time
+	for {
+		// Totals are a table scan, so refresh them less often than the rates
+		if time.Since(statsAt) >= statsInterval {
+			if stats, err = store.GetStats(ctx); err != nil {
+				return fmt.Errorf("failed to load stats: %w", err)
+			}
+			statsAt = time.Now()
+		}
+
+		errors, err := store.TopMessages(ctx, "error", time.Now().Add(-errorsSince), rows)
+		if err != nil {
+			return fmt.Errorf("failed to load top errors: %w", err)
+		}
+		recent, err := engine.ListAlertInstances(alerts.InstanceFilter{Limit: rows})
+		if err != nil {
+			return fmt.Errorf("failed to load alerts: %w", err)
+		}
+
+		frame := &topFrame{
+			at:          time.Now(),
+			interval:    interval,
+			stats:       stats,
+			rates:       rates,
+			errors:      errors,
+			errorsSince: errorsSinceStr,
+			alerts:      recent,
+			rows:        rows,
+		}
+		// Clear the screen and draw from the top left
+		fmt.Fprint(stdout, "\033[H\033[2J")
+		frame.write(stdout)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		counts, newest, err := store.CountAfter(ctx, lastID)
+		if err != nil {
+			return fmt.Errorf("failed to count logs: %w", err)
+		}
+		now := time.Now()
+		rates = serviceRates(counts, now.Sub(lastAt))
+		lastID, lastAt = newest, now
+	}
+}
+
+// statsInterval is how often top refreshes the total counts
+const statsInterval = 10 * time.Second
+
+// serviceRates turns counts over elapsed into per-second rates per service,
+// busiest first
+func serviceRates(counts []storage.ServiceLevelCount, elapsed time.Duration) []serviceRate {
+	seconds := elapsed.Seconds()
+	if seconds <= 0 {
+		return nil
+	}
+
+	byService := make(map[string]*serviceRate)
+	for _, c := range counts {
+		rate, ok := byService[c.Service]
+		if !ok {
+			rate = &serviceRate{service: c.Service, levels: make(map[string]float64)}
+			byService[c.Service] = rate
+		}
+		perSecond := float64(c.Count) / seconds
+		rate.total += perSecond
+		rate.levels[topLevel(c.Level)] += perSecond
+	}
+
+	rates := make([]serviceRate, 0, len(byService))
+	for _, rate := range byService {
+		rates = append(rates, *rate)
+	}
+	sort.Slice(rates, func(i, j int) bool {
+		if rates[i].total != rates[j].total {
+			return rates[i].total > rates[j].total
+		}
+		return rates[i].service < rates[j].service
+	})
+	return rates
+}
+
+// topFrame is everything drawn on one refresh
+type topFrame struct {
+	at          time.Time
+	interval    time.Duration
+	stats       storage.Stats
+	rates       []serviceRate
+	errors      []storage.MessageCount
+	errorsSince string
+	alerts      []*alerts.AlertInstance
+	rows        int
+}
+
+func (f *topFrame) write(out io.Writer) {
+	var total float64
+	for _, rate := range f.rates {
+		total += rate.total
+	}
+
+	fmt.Fprintf(out, "🔍 peep top - %s - %s (every %s, Ctrl+C to quit)\n",
+		cfg.DBPath, f.at.Format("15:04:05"), f.interval)
+	fmt.Fprintf(out, "%d logs | %.1f logs/sec | %d errors, %d warnings in the last hour\n\n",
+		f.stats.TotalLogs, total, f.stats.Errors, f.stats.Warnings)
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "SERVICE\tLOGS/S\t%s\t\n", strings.ToUpper(strings.Join(topLevels, "\t")))
+	if f.rates == nil {
+		fmt.Fprintln(w, "(measuring)\t\t\t\t\t\t\t")
+	}
+	for i, rate := range f.rates {
+		if i == f.rows {
+			fmt.Fprintf(w, "(%d more)\t\t\t\t\t\t\t\n", len(f.rates)-f.rows)
+			break
+		}
+		service := rate.service
+		if service == "" {
+			service = "-"
+		}
+		fmt.Fprintf(w, "%s\t%.1f\t", service, rate.total)
+		for _, level := range topLevels {
+			fmt.Fprintf(w, "%.1f\t", rate.levels[level])
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	fmt.Fprintf(out, "\n🔴 Top errors (last %s)\n", f.errorsSince)
+	if len(f.errors) == 0 {
+		fmt.Fprintln(out, "   none")
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, e := range f.errors {
+		fmt.Fprintf(w, "   %d\t%s\t%s\n", e.Count, e.Service, truncate(e.Message, 80))
+	}
+	w.Flush()
+
+	fmt.Fprintln(out, "\n🚨 Newest alerts")
+	if len(f.alerts) == 0 {
+		fmt.Fprintln(out, "   none")
+	}
+	w = tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	for _, a := range f.alerts {
+		fmt.Fprintf(w, "   %s\t%s\t%d/%d\t%s\n",
+			a.FiredAt.Local().Format("01-02 15:04:05"), a.RuleName, a.Count, a.Threshold, a.State())
+	}
+	w.Flush()
+}
+
+// truncate shortens s to at most n runes, marking the cut with "..."
+func truncate(s string, n int) string {
+	s = strings.Join(strings.Fields(s), " ")
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-3]) + "..."
+}
@@ -0,0 +1,151 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/spf13/cobra"
+)
+
+var traceCmd = &cobra.Command{
+	Use:   "trace TRACE_ID",
+	Short: "Show a trace's spans and the logs that carry its ID",
+	Long: `Print a trace received over OTLP as a tree of spans, each with its service,
+start offset, and duration, followed by the logs whose context carries the
+trace ID in trace_id (or traceId, or trace.id), oldest first.
+
+Traces arrive on the web server's POST /v1/traces, or on the listener opened
+with --otlp on peep serve and peep daemon. Point an OpenTelemetry SDK or
+collector's OTLP/HTTP exporter there, as protobuf or JSON.
+
+Examples:
+  peep trace 4bf92f3577b34da6a3ce929d0e0e4736
+  peep trace 4bf92f3577b34da6a3ce929d0e0e4736 --json | jq '.spans[].name'`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTrace,
+}
+
+func init() {
+	traceCmd.Flags().Bool("json", false, "Print the spans and logs as JSON")
+	traceCmd.Flags().IntP("limit", "l", 200, "Maximum number of logs to show")
+}
+
+func runTrace(cmd *cobra.Command, args []string) error {
+	traceID := strings.ToLower(strings.TrimSpace(args[0]))
+	limit, _ := cmd.Flags().GetInt("limit")
+
+	store, err := storage.NewStorage(cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	defer store.Close()
+
+	spans, err := store.GetTrace(cmd.Context(), traceID)
+	if err != nil {
+		return fmt.Errorf("failed to load trace: %w", err)
+	}
+	logs, err := store.GetTraceLogs(cmd.Context(), traceID, limit)
+	if err != nil {
+		return fmt.Errorf("failed to load the trace's logs: %w", err)
+	}
+
+	if asJSON, _ := cmd.Flags().GetBool("json"); asJSON {
+		// Empty results are [] rather than null, for jq
+		if spans == nil {
+			spans = []storage.Span{}
+		}
+		if logs == nil {
+			logs = []storage.LogEntry{}
+		}
+		encoder := json.NewEncoder(stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(map[string]interface{}{
+			"trace_id": traceID,
+			"spans":    spans,
+			"logs":     logs,
+		})
+	}
+
+	if len(spans) == 0 && len(logs) == 0 {
+		return withHint(fmt.Errorf("no spans or logs found for trace %s", traceID),
+			"💡 Spans are stored when an OTLP exporter sends them to /v1/traces on the web server or the --otlp listener")
+	}
+
+	if len(spans) > 0 {
+		printSpanTree(spans)
+	}
+	if len(logs) > 0 {
+		if len(spans) > 0 {
+			fmt.Fprintln(stdout)
+		}
+		w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tLEVEL\tSERVICE\tMESSAGE")
+		for _, log := range logs {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n",
+				log.Timestamp.Local().Format("2006-01-02 15:04:05.000"),
+				log.Level,
+				log.Service,
+				strings.Join(strings.Fields(log.Message), " "),
+			)
+		}
+		return w.Flush()
+	}
+	return nil
+}
+
+// printSpanTree prints spans indented under their parents, with each span's
+// start relative to the trace's first. Spans whose parent wasn't received are
+// printed as roots.
+func printSpanTree(spans []storage.Span) {
+	known := make(map[string]bool, len(spans))
+	for _, span := range spans {
+		known[span.SpanID] = true
+	}
+	children := make(map[string][]storage.Span)
+	var roots []storage.Span
+	for _, span := range spans {
+		if span.ParentSpanID == "" || !known[span.ParentSpanID] {
+			roots = append(roots, span)
+		} else {
+			children[span.ParentSpanID] = append(children[span.ParentSpanID], span)
+		}
+	}
+
+	start := spans[0].StartTime
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SPAN\tSERVICE\tSTART\tDURATION\tSTATUS")
+	var walk func(span storage.Span, depth int)
+	walk = func(span storage.Span, depth int) {
+		status := span.Status
+		if span.StatusMessage != "" {
+			status += ": " + span.StatusMessage
+		}
+		fmt.Fprintf(w, "%s%s\t%s\t+%s\t%s\t%s\n",
+			strings.Repeat("  ", depth), span.Name, span.Service,
+			formatSpanDuration(span.StartTime.Sub(start)), formatSpanDuration(span.Duration), status)
+		for _, child := range children[span.SpanID] {
+			walk(child, depth+1)
+		}
+	}
+	for _, root := range roots {
+		walk(root, 0)
+	}
+	w.Flush()
+}
+
+// formatSpanDuration rounds a duration to a precision that suits its size,
+// e.g. 1.234s, 84.2ms, or 312µs
+func formatSpanDuration(d time.Duration) string {
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond).String()
+	case d >= time.Millisecond:
+		return d.Round(100 * time.Microsecond).String()
+	default:
+		return d.Round(time.Microsecond).String()
+	}
+}
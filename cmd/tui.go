@@ -12,45 +12,102 @@ var tuiCmd = &cobra.Command{
 	Use:   "tui",
 	Short: "Start the Terminal UI for browsing logs",
 	Long: `Start the interactive Terminal User Interface for browsing, filtering,
-and searching through your logs in real-time.
+and searching through your logs in real-time. A header shows the total log
+count, errors and warnings in the last hour, database size, and ingest rate.
 
 Controls:
   q, Ctrl+C  - Quit
-  /          - Search mode
+  /          - Search with a regex, highlighting matches
+  n, N       - Next or previous match
+  ], [       - Next or previous error or warning
   r          - Manual refresh
-  esc        - Cancel search
-  ↑/↓        - Navigate logs
-  enter      - Apply search filter`,
-	Run: func(cmd *cobra.Command, args []string) {
+  esc        - Clear the search
+  ↑/↓        - Navigate logs; going past either end loads more. The list
+               holds 1000 logs, so paging far back pauses the tail.
+  enter      - Run the search, or show the selected log's details
+  e          - Cycle the level filter
+  s          - Pick a service to filter by
+  f          - Follow new logs or pause
+  t          - Jump to a time
+  :          - Run a SQL query (read-only, like the web console)
+  y, Y       - Copy the selected log's line, or its raw JSON, to the clipboard
+  x          - Export the logs shown to a file (.csv, or .json)
+
+The mouse wheel scrolls, and clicking a log opens it. Hold Shift to select
+text, or turn the mouse off with --no-mouse.
+
+Colors, keys, and layout can be changed in a JSON config file, read from
+~/.config/peep/tui.json (or the platform's config directory) or --tui-config:
+
+  {
+    "no_color": false,
+    "no_mouse": false,
+    "colors": {"title_bg": "#7D56F4", "level_error": "9"},
+    "keys": {"quit": ["ctrl+q"], "search": ["/", "ctrl+f"]},
+    "layout": {"timestamp": "datetime", "hide_service": true, "message": "raw",
+               "hide_description": true, "detail_width": 100}
+  }
+
+Colors: title_fg, title_bg, status, help, error, selected_title,
+selected_desc, heading, label, match_fg, match_bg, table_border,
+level_error, level_warn, level_info, level_debug
+Keys: quit, search, next_match, prev_match, refresh, follow, jump,
+query, level, service, copy, copy_raw, export, next_issue, prev_issue, trace
+Layout: timestamp (time, millis, datetime, rfc3339, or a Go time layout),
+hide_service, message (parsed or raw), hide_description, detail_width
+
+--no-color or the NO_COLOR environment variable turns colors off, for limited
+terminals and screen readers; search matches are then marked with « ».`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		configPath, _ := cmd.Flags().GetString("tui-config")
+		noColor, _ := cmd.Flags().GetBool("no-color")
+		noMouse, _ := cmd.Flags().GetBool("no-mouse")
+
+		config, err := tui.LoadConfig(configPath)
+		if err != nil {
+			return fmt.Errorf("failed to load TUI config: %w", err)
+		}
+		if noColor {
+			config.NoColor = true
+		}
+		if noMouse {
+			config.NoMouse = true
+		}
+
 		// Initialize storage
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
 
 		// Check if we have any logs
-		logs, err := store.GetLogs(1)
+		logs, err := store.GetLogs(cmd.Context(), 1)
 		if err != nil {
-			fmt.Printf("❌ Error checking logs: %v\n", err)
-			return
+			return fmt.Errorf("failed to check logs: %w", err)
 		}
 
 		if len(logs) == 0 {
-			fmt.Println("📭 No logs found!")
-			fmt.Println("💡 Try ingesting some logs first:")
-			fmt.Println("   echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
-			fmt.Println("   peep ingest sample.log")
-			return
+			fmt.Fprintln(stdout, "📭 No logs found!")
+			fmt.Fprintln(stdout, "💡 Try ingesting some logs first:")
+			fmt.Fprintln(stdout, "   echo '{\"level\":\"info\",\"message\":\"Hello!\"}' | peep")
+			fmt.Fprintln(stdout, "   peep ingest sample.log")
+			return nil
 		}
 
-		fmt.Println("🖥️  Starting Peep TUI...")
+		fmt.Fprintln(stdout, "🖥️  Starting Peep TUI...")
 
 		// Start the TUI
-		if err := tui.Start(store); err != nil {
-			fmt.Printf("❌ Error starting TUI: %v\n", err)
-			return
+		if err := tui.Start(store, config); err != nil {
+			return fmt.Errorf("failed to start TUI: %w", err)
 		}
+
+		return nil
 	},
 }
+
+func init() {
+	tuiCmd.Flags().String("tui-config", "", "TUI config file (default ~/.config/peep/tui.json)")
+	tuiCmd.Flags().Bool("no-color", false, "Turn off colors (or set NO_COLOR)")
+	tuiCmd.Flags().Bool("no-mouse", false, "Leave the mouse to the terminal, for selecting text")
+}
@@ -0,0 +1,181 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// watchdog notices when a part of the daemon stops making progress, sends a
+// notice through a channel, and restarts it
+type watchdog struct {
+	engine     *alerts.Engine // Sends the notices; nil when the daemon has no engine
+	channel    string         // Channel name to notify; empty logs only
+	components []*watchedComponent
+}
+
+// watchedComponent is one part of the daemon the watchdog checks
+type watchedComponent struct {
+	name     string                    // e.g. "alert engine"
+	idle     string                    // What stalling looks like, e.g. "No alert checks"
+	resumed  string                    // The notice once it recovers
+	after    func() time.Duration      // How long without progress counts as stalled
+	progress func() (time.Time, error) // When it last made progress
+	restart  func() (string, error)    // Restarts it and says what was done
+
+	stalled     bool
+	restartedAt time.Time
+}
+
+// newWatchdog watches the alert engine, when it's running, and whether new
+// logs are arriving
+func newWatchdog(after time.Duration, channel string, store *storage.Storage, engine *alerts.Engine, alertsRunning bool, listeners *ingestListeners) *watchdog {
+	w := &watchdog{engine: engine, channel: channel}
+
+	if alertsRunning {
+		w.components = append(w.components, &watchedComponent{
+			name:    "alert engine",
+			idle:    "No alert checks",
+			resumed: "Alert checks have resumed.",
+			// A long check interval isn't a stall
+			after: func() time.Duration {
+				interval := currentConfig().Alerts.CheckInterval
+				if interval <= 0 {
+					interval = alerts.DefaultCheckInterval
+				}
+				return after + interval
+			},
+			progress: func() (time.Time, error) {
+				return engine.LastCheck(), nil
+			},
+			restart: func() (string, error) {
+				engine.Restart()
+				return "Restarted the alert engine.", nil
+			},
+		})
+	}
+
+	// Counting new rows includes logs written by other peep commands
+	var newestID int64
+	lastLog := time.Now()
+	w.components = append(w.components, &watchedComponent{
+		name:    "ingestion",
+		idle:    "No new logs",
+		resumed: "New logs are arriving again.",
+		after:   func() time.Duration { return after },
+		progress: func() (time.Time, error) {
+			_, newest, err := store.CountAfter(context.Background(), newestID)
+			if err != nil {
+				return time.Time{}, err
+			}
+			if newest > newestID {
+				newestID = newest
+				lastLog = time.Now()
+			}
+			return lastLog, nil
+		},
+		restart: func() (string, error) {
+			restarted, err := listeners.restart()
+			if err != nil {
+				return "", err
+			}
+			if restarted == 0 {
+				return "No ingestion listeners run in this daemon; check whatever sends it logs.", nil
+			}
+			return fmt.Sprintf("Restarted %d ingestion listener(s).", restarted), nil
+		},
+	})
+
+	// Set the starting point, so logs already stored aren't taken as progress
+	w.components[len(w.components)-1].progress()
+	return w
+}
+
+// checkEvery is how often the watchdog looks, often enough to notice a stall
+// soon after it passes the limit
+func checkEvery(after time.Duration) time.Duration {
+	if after/2 < time.Minute {
+		return after / 2
+	}
+	return time.Minute
+}
+
+// Run checks the components until ctx is cancelled
+func (w *watchdog) Run(ctx context.Context, after time.Duration) {
+	ticker := time.NewTicker(checkEvery(after))
+	defer ticker.Stop()
+
+	slog.Info("watchdog started", "after", after.String(), "channel", w.channel)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, c := range w.components {
+				w.check(c)
+			}
+		}
+	}
+}
+
+// check notifies and restarts a component that's stalled, tries again if a
+// restart didn't help, and notifies when it recovers
+func (w *watchdog) check(c *watchedComponent) {
+	progress, err := c.progress()
+	if err != nil {
+		slog.Warn("watchdog check failed", "component", c.name, "error", err)
+		return
+	}
+
+	if c.stalled {
+		if progress.After(c.restartedAt) {
+			c.stalled = false
+			slog.Info("watchdog: component recovered", "component", c.name)
+			w.notify(fmt.Sprintf("✅ Peep %s recovered", c.name),
+				c.resumed, "info")
+			return
+		}
+		if time.Since(c.restartedAt) >= c.after() {
+			action, err := c.restart()
+			c.restartedAt = time.Now()
+			slog.Warn("watchdog: component still stalled, restarted again", "component", c.name, "action", action, "error", err)
+		}
+		return
+	}
+
+	idle := time.Since(progress)
+	if idle < c.after() {
+		return
+	}
+
+	c.stalled = true
+	action, err := c.restart()
+	c.restartedAt = time.Now()
+	if err != nil {
+		action = fmt.Sprintf("Restarting it failed: %v", err)
+	}
+	slog.Error("watchdog: component stalled", "component", c.name, "idle", idle.Round(time.Second).String(), "action", action)
+	w.notify(fmt.Sprintf("⚠️ Peep %s stalled", c.name),
+		fmt.Sprintf("%s for %s (since %s). %s", c.idle, idle.Round(time.Second), progress.Format("2006-01-02 15:04:05"), action),
+		"critical")
+}
+
+// notify sends a notice through the watchdog's channel, if it has one
+func (w *watchdog) notify(title, message, severity string) {
+	if w.channel == "" {
+		return
+	}
+	// Looked up each time, so a channel edited in the web UI is picked up
+	channel, ok := w.engine.FindChannel(w.channel)
+	if !ok {
+		slog.Error("watchdog notification channel not found", "channel", w.channel)
+		return
+	}
+	if err := w.engine.SendMessage(channel, title, message, severity); err != nil {
+		slog.Error("watchdog notification failed", "channel", w.channel, "error", err)
+	}
+}
@@ -1,11 +1,17 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/ingestion"
 	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/kylereynolds/peep/internal/tokens"
 	"github.com/kylereynolds/peep/internal/web"
 	"github.com/spf13/cobra"
 )
@@ -16,38 +22,203 @@ var webCmd = &cobra.Command{
 	Long: `Start the web interface for browsing logs, managing alerts, and viewing dashboards.
 	
 Features:
-  • Real-time dashboard with log statistics
+  • Live dashboard with log statistics (--refresh-interval sets how often it updates)
   • Log viewer and search interface  
   • Alert rules and notification management
   • HTMX-powered interactivity
   
-Access it at http://localhost:8080`,
-	Run: func(cmd *cobra.Command, args []string) {
-		port, _ := cmd.Flags().GetInt("port")
+Access it at http://localhost:8080, or the port and address set with --port
+and --bind (or web.port and web.bind in the config file).
 
+Authentication:
+  peep web --username admin --password secret    # Login form with session cookies
+  peep web --token my-shared-token               # Single shared token
+  PEEP_WEB_PASSWORD / PEEP_WEB_TOKEN can be used instead of the flags.
+  When authentication is enabled, API tokens from 'peep tokens create' are
+  also accepted as Bearer tokens.
+
+Roles:
+  The --username login and the shared token are admins: they can run SQL and
+  manage alert rules, channels, and dashboards. Add a viewer login that can
+  only browse logs and dashboards with:
+  peep web --username admin --password secret --viewer-username team --viewer-password view
+  (or set PEEP_WEB_VIEWER_PASSWORD). API tokens with the read scope act as viewers.
+
+Read-only mode:
+  peep web --read-only    # Browse and query only; nothing can be changed
+
+SQL console:
+  Queries must be a single SELECT and can never write. They stop after
+  --query-timeout and return at most --query-max-rows rows and --query-max-bytes.
+
+Slack slash command:
+  Create a Slack app with a slash command (e.g. /peep) whose request URL is
+  https://your-peep-host/slack/commands, then start the server with the app's
+  signing secret:
+  peep web --slack-signing-secret abc123    # Or set PEEP_SLACK_SIGNING_SECRET
+  Then in Slack: /peep errors api 1h, /peep services 24h, /peep search timeout,
+  /peep alerts. Requests must carry Slack's signature, so no login is needed.
+  Set the app's interactivity request URL to https://your-peep-host/slack/actions
+  and the Ack button on alert messages acknowledges the alert.
+
+Webhooks:
+  Each hook in the config file's hooks section takes JSON POSTed to
+  /hooks/NAME, e.g. from Stripe, CI, or an uptime monitor, and stores it as
+  logs, with JSONPath mappings for the level, message, service, and context.
+  A hook with a secret takes it as ?token=SECRET in place of a login.
+
+Elasticsearch clients:
+  Shippers that only speak Elasticsearch (Filebeat, Logstash, Fluent Bit)
+  can send to http://your-peep-host:8080/es, which accepts the _bulk API
+  and stores each document as a log. Turn off index template and ILM setup
+  in the shipper, and send an ingest token as a Bearer Authorization header.
+
+Streaming:
+  /logs/stream sends new logs as Server-Sent Events (narrow it with level
+  and service), and /logs/search?format=csv&limit=0 exports every log
+  matching the search, streamed as it's read.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		// Initialize storage
-		store, err := storage.NewStorage("logs.db")
+		store, err := storage.NewStorage(cfg.DBPath)
 		if err != nil {
-			fmt.Printf("❌ Error initializing storage: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize storage: %w", err)
 		}
 		defer store.Close()
+		store.SetQueryTimeout(cfg.QueryTimeout)
+		if err := enableWriteBuffer(store); err != nil {
+			return err
+		}
 
 		// Initialize alert engine
 		engine, err := alerts.NewEngine(store)
 		if err != nil {
-			fmt.Printf("❌ Error initializing alert engine: %v\n", err)
-			return
+			return fmt.Errorf("failed to initialize alert engine: %w", err)
+		}
+
+		server, bind, port, err := newWebServer(cmd, store, engine)
+		if err != nil {
+			return err
 		}
 
-		// Create and start web server
-		server := web.NewServer(store, engine)
-		if err := server.Start(port); err != nil {
-			log.Fatal("❌ Failed to start web server:", err)
+		// Stop cleanly on Ctrl-C or SIGTERM so in-flight requests finish and the port is released
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := server.Start(ctx, bind, port); err != nil {
+			return fmt.Errorf("web server error: %w", err)
 		}
+
+		return nil
 	},
 }
 
+// newWebServer builds the web server from the flags added by addWebFlags,
+// returning it with the address to listen on
+func newWebServer(cmd *cobra.Command, store *storage.Storage, engine *alerts.Engine) (*web.Server, string, int, error) {
+	port := cfg.Web.Port
+	if cmd.Flags().Changed("port") {
+		port, _ = cmd.Flags().GetInt("port")
+	}
+	bind := cfg.Web.Bind
+	if cmd.Flags().Changed("bind") {
+		bind, _ = cmd.Flags().GetString("bind")
+	}
+	username, _ := cmd.Flags().GetString("username")
+	password, _ := cmd.Flags().GetString("password")
+	token, _ := cmd.Flags().GetString("token")
+	viewerUsername, _ := cmd.Flags().GetString("viewer-username")
+	viewerPassword, _ := cmd.Flags().GetString("viewer-password")
+	sessionTTL, _ := cmd.Flags().GetDuration("session-ttl")
+	refreshInterval, _ := cmd.Flags().GetDuration("refresh-interval")
+	statsCacheTTL, _ := cmd.Flags().GetDuration("stats-cache-ttl")
+	readOnly, _ := cmd.Flags().GetBool("read-only")
+	queryTimeout, _ := cmd.Flags().GetDuration("query-timeout")
+	queryMaxRows, _ := cmd.Flags().GetInt("query-max-rows")
+	queryMaxBytes, _ := cmd.Flags().GetInt64("query-max-bytes")
+	slackSecret, _ := cmd.Flags().GetString("slack-signing-secret")
+
+	// Environment variables keep secrets out of the process list
+	if password == "" {
+		password = os.Getenv("PEEP_WEB_PASSWORD")
+	}
+	if token == "" {
+		token = os.Getenv("PEEP_WEB_TOKEN")
+	}
+	if viewerPassword == "" {
+		viewerPassword = os.Getenv("PEEP_WEB_VIEWER_PASSWORD")
+	}
+	if slackSecret == "" {
+		slackSecret = os.Getenv("PEEP_SLACK_SIGNING_SECRET")
+	}
+	if password != "" && username == "" {
+		return nil, "", 0, fmt.Errorf("--password requires --username")
+	}
+	if viewerPassword != "" && viewerUsername == "" {
+		return nil, "", 0, fmt.Errorf("--viewer-password requires --viewer-username")
+	}
+	if viewerPassword != "" && viewerUsername == username {
+		return nil, "", 0, fmt.Errorf("--viewer-username must differ from --username")
+	}
+
+	tokenStore, err := tokens.NewStore(store.GetDB())
+	if err != nil {
+		return nil, "", 0, fmt.Errorf("failed to initialize API tokens: %w", err)
+	}
+
+	parser, err := ingestion.NewLogParser(cfg.Parser.Patterns)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	deriver, err := newDeriver(store)
+	if err != nil {
+		return nil, "", 0, err
+	}
+
+	server := web.NewServer(store, engine)
+	server.SetParser(parser)
+	server.SetDeriver(deriver)
+	server.SetAuth(web.AuthConfig{
+		Username:   username,
+		Password:   password,
+		Token:      token,
+		SessionTTL: sessionTTL,
+		Tokens:     tokenStore,
+
+		ViewerUsername: viewerUsername,
+		ViewerPassword: viewerPassword,
+	})
+	server.SetRefreshInterval(refreshInterval)
+	server.SetStatsCacheTTL(statsCacheTTL)
+	server.SetReadOnly(readOnly)
+	server.SetQueryLimits(queryTimeout, queryMaxRows, queryMaxBytes)
+	server.SetSlackSigningSecret(slackSecret)
+	if err := server.SetWebhooks(cfg.Hooks); err != nil {
+		return nil, "", 0, err
+	}
+
+	return server, bind, port, nil
+}
+
+// addWebFlags adds the web server's listen, auth, and guardrail flags
+func addWebFlags(cmd *cobra.Command) {
+	cmd.Flags().IntP("port", "p", 8080, "Port to run the web server on (or set web.port in the config)")
+	cmd.Flags().String("bind", "", "Address to listen on, e.g. 127.0.0.1 (default every interface)")
+	cmd.Flags().StringP("username", "u", "", "Username for web UI login")
+	cmd.Flags().String("password", "", "Password for web UI login (or set PEEP_WEB_PASSWORD)")
+	cmd.Flags().String("token", "", "Shared access token for the web UI (or set PEEP_WEB_TOKEN)")
+	cmd.Flags().String("viewer-username", "", "Username for a login that can only browse logs and dashboards")
+	cmd.Flags().String("viewer-password", "", "Password for the viewer login (or set PEEP_WEB_VIEWER_PASSWORD)")
+	cmd.Flags().Duration("session-ttl", 24*time.Hour, "How long a web login session lasts")
+	cmd.Flags().Bool("read-only", false, "Disable rule/channel changes and ingestion")
+	cmd.Flags().Duration("refresh-interval", 5*time.Second, "How often the dashboard checks for new data")
+	cmd.Flags().Duration("stats-cache-ttl", 10*time.Second, "How long the dashboard reuses its log counts (negative to count every time)")
+	cmd.Flags().Duration("query-timeout", 30*time.Second, "How long a SQL console or dashboard query may run")
+	cmd.Flags().Int("query-max-rows", 1000, "Most rows a SQL console query returns")
+	cmd.Flags().Int64("query-max-bytes", 10<<20, "Most bytes of results a SQL console query returns")
+	cmd.Flags().String("slack-signing-secret", "", "Slack app signing secret, enabling /slack/commands and /slack/actions (or set PEEP_SLACK_SIGNING_SECRET)")
+}
+
 func init() {
-	webCmd.Flags().IntP("port", "p", 8080, "Port to run the web server on")
+	addWebFlags(webCmd)
 }
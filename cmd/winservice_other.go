@@ -0,0 +1,23 @@
+//go:build !windows
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// isWindowsService is only true on Windows
+func isWindowsService() bool {
+	return false
+}
+
+func runWindowsService(cmd *cobra.Command, run func(ctx context.Context) error) error {
+	return fmt.Errorf("Windows services aren't supported on this platform")
+}
+
+func installEventSource(name string) error {
+	return nil
+}
@@ -0,0 +1,142 @@
+//go:build windows
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+)
+
+func init() {
+	daemonCmd.Flags().String("service-name", "peep", "Name of the Windows service the daemon runs as, which it logs to the event log under")
+}
+
+// isWindowsService reports whether the service control manager started this
+// process, rather than someone at a console
+func isWindowsService() bool {
+	service, err := svc.IsWindowsService()
+	return err == nil && service
+}
+
+// runWindowsService runs the daemon under the service control manager until
+// it asks the service to stop, logging to the Windows event log, since a
+// service's stderr goes nowhere
+func runWindowsService(cmd *cobra.Command, run func(ctx context.Context) error) error {
+	name, _ := cmd.Flags().GetString("service-name")
+	if events, err := eventlog.Open(name); err == nil {
+		defer events.Close()
+		slog.SetDefault(slog.New(newEventLogHandler(events)))
+	}
+	return svc.Run(name, &windowsService{run: run})
+}
+
+// windowsService answers the service control manager for the daemon
+type windowsService struct {
+	run func(ctx context.Context) error
+}
+
+// Execute starts the daemon and stops it when the service is stopped or
+// Windows shuts down. A daemon that fails exits with an error code, so the
+// service's recovery actions restart it.
+func (s *windowsService) Execute(args []string, requests <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- s.run(ctx) }()
+
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			if err != nil {
+				slog.Error("peep daemon failed", "error", err)
+				return true, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				status <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				slog.Info("shutting down", "request", "service stop")
+				status <- svc.Status{State: svc.StopPending}
+				cancel()
+				if err := <-done; err != nil {
+					slog.Error("peep daemon failed", "error", err)
+					return true, 1
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogHandler writes each record, formatted as by --log-format text, to
+// the event log as information, a warning, or an error
+type eventLogHandler struct {
+	slog.Handler // Formats records into buf
+
+	events *eventlog.Log
+	buf    *bytes.Buffer
+	mu     *sync.Mutex
+}
+
+func newEventLogHandler(events *eventlog.Log) *eventLogHandler {
+	buf := &bytes.Buffer{}
+	return &eventLogHandler{
+		Handler: slog.NewTextHandler(buf, &slog.HandlerOptions{Level: logLevel}),
+		events:  events,
+		buf:     buf,
+		mu:      &sync.Mutex{},
+	}
+}
+
+func (h *eventLogHandler) Handle(ctx context.Context, record slog.Record) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.buf.Reset()
+	if err := h.Handler.Handle(ctx, record); err != nil {
+		return err
+	}
+	message := strings.TrimSpace(h.buf.String())
+
+	// Peep has one kind of event; the level is what event viewers filter on
+	const eventID = 1
+	switch {
+	case record.Level >= slog.LevelError:
+		return h.events.Error(eventID, message)
+	case record.Level >= slog.LevelWarn:
+		return h.events.Warning(eventID, message)
+	default:
+		return h.events.Info(eventID, message)
+	}
+}
+
+func (h *eventLogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &eventLogHandler{Handler: h.Handler.WithAttrs(attrs), events: h.events, buf: h.buf, mu: h.mu}
+}
+
+func (h *eventLogHandler) WithGroup(name string) slog.Handler {
+	return &eventLogHandler{Handler: h.Handler.WithGroup(name), events: h.events, buf: h.buf, mu: h.mu}
+}
+
+// installEventSource registers the service as an event log source, so
+// Event Viewer shows its messages without a "description can't be found"
+// warning. It's fine if it's already registered.
+func installEventSource(name string) error {
+	err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info)
+	if err != nil && strings.Contains(err.Error(), "already exists") {
+		return nil
+	}
+	return err
+}
@@ -1,3 +1,7 @@
+// Notifications sends a test desktop notification and runs a test shell alert
+// script, to check notifications work on this machine:
+//
+//	go run ./examples/notifications
 package main
 
 import (
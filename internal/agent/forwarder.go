@@ -0,0 +1,328 @@
+// Package agent ships logs collected on one machine to a central Peep. Logs
+// are stored in a local database first, which doubles as the buffer while the
+// central Peep can't be reached.
+package agent
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+const (
+	cursorSetting = "agent.forwarded_id" // The ID of the last log the central Peep accepted
+	idSetting     = "agent.id"           // The agent's ID, which its sequence numbers belong to
+)
+
+const (
+	defaultBatchSize  = 500
+	flushInterval     = time.Second // Longest a log waits for a batch to fill
+	heartbeatInterval = time.Minute // Longest the central Peep goes without hearing from the agent
+	maxBackoff        = time.Minute
+)
+
+// Forwarder sends stored logs to a central Peep in the order they were
+// stored, resuming after the last one it accepted across restarts and outages
+type Forwarder struct {
+	Store     *storage.Storage
+	URL       string // The central Peep's base URL, e.g. https://peep.internal:8080
+	Token     string // Sent as a Bearer token; an ingest-scoped API token is enough
+	Name      string // Shown on the central Peep's settings page
+	BatchSize int
+	Client    *http.Client
+
+	id          string
+	cursor      int64
+	lastContact time.Time
+	legacy      bool // The central Peep predates /api/ingest/bulk
+	forwarded   atomic.Int64
+	wake        chan struct{}
+}
+
+// NewForwarder creates a forwarder for store. On first use it starts after
+// the logs already stored, so a database that predates the agent isn't resent.
+func NewForwarder(store *storage.Storage, url, token, name string) (*Forwarder, error) {
+	f := &Forwarder{
+		Store:     store,
+		URL:       strings.TrimRight(url, "/"),
+		Token:     token,
+		Name:      name,
+		BatchSize: defaultBatchSize,
+		Client:    &http.Client{Timeout: 30 * time.Second},
+		wake:      make(chan struct{}, 1),
+	}
+
+	_, found, err := store.GetSetting(cursorSetting, &f.cursor)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if _, f.cursor, err = store.CountAfter(context.Background(), 0); err != nil {
+			return nil, err
+		}
+		if err := store.PutSetting(cursorSetting, f.cursor); err != nil {
+			return nil, err
+		}
+	}
+
+	// Log IDs are the sequence numbers the central Peep drops resends by. A
+	// new buffer database starts its IDs over, so it gets a new agent ID too.
+	if _, found, err := store.GetSetting(idSetting, &f.id); err != nil {
+		return nil, err
+	} else if !found {
+		buf := make([]byte, 16)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, err
+		}
+		f.id = hex.EncodeToString(buf)
+		if err := store.PutSetting(idSetting, f.id); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// Notify tells the forwarder new logs are stored, so a quiet forwarder sends
+// them without waiting for its next flush
+func (f *Forwarder) Notify() {
+	select {
+	case f.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Forwarded returns how many logs the central Peep has accepted from this forwarder
+func (f *Forwarder) Forwarded() int64 {
+	return f.forwarded.Load()
+}
+
+// Pending returns how many stored logs are waiting to be sent
+func (f *Forwarder) Pending() (int64, error) {
+	var pending int64
+	err := f.Store.GetDB().QueryRow("SELECT COUNT(*) FROM logs WHERE id > ?", f.cursor).Scan(&pending)
+	return pending, err
+}
+
+// Run sends logs as they're stored until ctx is cancelled. While the central
+// Peep can't be reached it retries with backoff, and logs wait in the store.
+func (f *Forwarder) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var backoff time.Duration
+	for {
+		sent, err := f.sendBatch(ctx, time.Since(f.lastContact) >= heartbeatInterval)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			if backoff == 0 {
+				pending, _ := f.Pending()
+				slog.Warn("forwarding failed, buffering logs locally", "url", f.URL, "pending", pending, "error", err)
+				backoff = time.Second
+			} else {
+				slog.Debug("forwarding still failing", "retry_in", backoff.String(), "error", err)
+				backoff = min(backoff*2, maxBackoff)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		if backoff > 0 {
+			slog.Info("forwarding resumed", "url", f.URL)
+			backoff = 0
+		}
+
+		// A full batch means there's more waiting
+		if sent == f.BatchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-f.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// Flush sends everything stored so far, without retrying. Call it after Run
+// returns, e.g. once stdin is exhausted.
+func (f *Forwarder) Flush(ctx context.Context) error {
+	for {
+		sent, err := f.sendBatch(ctx, false)
+		if err != nil || sent < f.BatchSize {
+			return err
+		}
+	}
+}
+
+// sendBatch posts the next batch of stored logs and advances the cursor past
+// them once the central Peep accepts them. With heartbeat set it posts even
+// when there's nothing to send, so the central Peep knows the agent is up.
+func (f *Forwarder) sendBatch(ctx context.Context, heartbeat bool) (int, error) {
+	logs, err := f.Store.QueryLogs(ctx, `
+	SELECT id, timestamp, level, message, service, context, raw_log, created_at
+	FROM logs WHERE id > ? ORDER BY id LIMIT ?`, f.cursor, f.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+	if len(logs) == 0 && (!heartbeat || f.legacy) {
+		return 0, nil
+	}
+
+	if !f.legacy {
+		err = f.postBulk(ctx, logs)
+		var status *statusError
+		if errors.As(err, &status) && status.code == http.StatusNotFound {
+			slog.Warn("the central Peep has no /api/ingest/bulk; sending to /api/ingest, without dropping resends", "url", f.URL)
+			f.legacy = true
+		}
+	}
+	if f.legacy {
+		if len(logs) == 0 {
+			return 0, nil
+		}
+		err = f.postLines(ctx, logs)
+	}
+	if err != nil {
+		return 0, err
+	}
+	f.lastContact = time.Now()
+	if len(logs) == 0 {
+		return 0, nil
+	}
+
+	f.cursor = logs[len(logs)-1].ID
+	if err := f.Store.PutSetting(cursorSetting, f.cursor); err != nil {
+		return 0, fmt.Errorf("failed to save forwarding position: %w", err)
+	}
+	f.forwarded.Add(int64(len(logs)))
+	return len(logs), nil
+}
+
+// bulkEntry is a log as /api/ingest/bulk takes it; Seq is its local ID
+type bulkEntry struct {
+	Seq       int64     `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Service   string    `json:"service"`
+	Context   string    `json:"context"`
+	RawLog    string    `json:"raw_log"`
+}
+
+// postBulk sends logs, gzipped, to /api/ingest/bulk
+func (f *Forwarder) postBulk(ctx context.Context, logs []storage.LogEntry) error {
+	entries := make([]bulkEntry, len(logs))
+	for i, entry := range logs {
+		entries[i] = bulkEntry{
+			Seq:       entry.ID,
+			Timestamp: entry.Timestamp,
+			Level:     entry.Level,
+			Message:   entry.Message,
+			Service:   entry.Service,
+			Context:   entry.Context,
+			RawLog:    entry.RawLog,
+		}
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	batch := map[string]interface{}{"agent": f.id, "name": f.Name, "entries": entries}
+	if err := json.NewEncoder(gz).Encode(batch); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	return f.post(ctx, "/api/ingest/bulk", &body, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Content-Encoding", "gzip")
+	})
+}
+
+// postLines sends logs as JSON lines to /api/ingest, which any Peep with a
+// web server accepts
+func (f *Forwarder) postLines(ctx context.Context, logs []storage.LogEntry) error {
+	var body bytes.Buffer
+	for _, entry := range logs {
+		line, err := encodeEntry(entry)
+		if err != nil {
+			return err
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+	}
+
+	return f.post(ctx, "/api/ingest", &body, func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	})
+}
+
+// statusError is a response from the central Peep other than 200 OK
+type statusError struct {
+	code   int
+	status string
+	detail string
+}
+
+func (e *statusError) Error() string {
+	return e.status + ": " + e.detail
+}
+
+func (f *Forwarder) post(ctx context.Context, path string, body io.Reader, setHeaders func(*http.Request)) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, f.URL+path, body)
+	if err != nil {
+		return err
+	}
+	setHeaders(req)
+	if f.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+f.Token)
+	}
+
+	resp, err := f.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return &statusError{code: resp.StatusCode, status: resp.Status, detail: strings.TrimSpace(string(detail))}
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
+
+// encodeEntry writes a parsed log as a JSON line the central Peep's parser
+// reads back into the same timestamp, level, message, and service, with the
+// original JSON fields kept as context
+func encodeEntry(entry storage.LogEntry) ([]byte, error) {
+	fields := map[string]interface{}{}
+	if entry.Context != "" {
+		json.Unmarshal([]byte(entry.Context), &fields) // Plain-text logs have no fields
+	}
+	fields["timestamp"] = entry.Timestamp.Format(time.RFC3339Nano)
+	fields["level"] = entry.Level
+	fields["message"] = entry.Message
+	fields["service"] = entry.Service
+	return json.Marshal(fields)
+}
@@ -0,0 +1,103 @@
+package agent
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// tailPollInterval is how often a tailed file is checked for new lines
+const tailPollInterval = 250 * time.Millisecond
+
+// Tail sends each line appended to path until ctx is cancelled. Like tail -F
+// it starts at the end of the file, waits for a file that doesn't exist yet,
+// and follows it across rotation and truncation, reading replacements from
+// the start.
+func Tail(ctx context.Context, path string, fromStart bool, lines chan<- string) error {
+	var file *os.File
+	var reader *bufio.Reader
+	var partial string
+	// send delivers a line, reporting false once ctx is cancelled
+	send := func(line string) bool {
+		if line = strings.TrimRight(line, "\r\n"); line == "" {
+			return true
+		}
+		select {
+		case lines <- line:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+	defer func() {
+		if file != nil {
+			file.Close()
+		}
+	}()
+
+	for {
+		if file == nil {
+			opened, err := os.Open(path)
+			if err != nil && !errors.Is(err, os.ErrNotExist) {
+				return err
+			}
+			if err == nil {
+				if !fromStart {
+					if _, err := opened.Seek(0, io.SeekEnd); err != nil {
+						opened.Close()
+						return err
+					}
+				}
+				file, reader, partial = opened, bufio.NewReader(opened), ""
+				fromStart = true // Whatever replaces it is new
+			}
+		}
+
+		if file != nil {
+			for {
+				chunk, err := reader.ReadString('\n')
+				partial += chunk
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					return err
+				}
+				if !send(partial) {
+					return nil
+				}
+				partial = ""
+			}
+
+			current, statErr := os.Stat(path)
+			opened, err := file.Stat()
+			if err != nil {
+				return err
+			}
+			if statErr != nil || !os.SameFile(opened, current) {
+				// Rotated away: everything written to the old file has been read,
+				// and a last line without a newline won't get one now
+				if !send(partial) {
+					return nil
+				}
+				file.Close()
+				file = nil
+			} else if offset, err := file.Seek(0, io.SeekCurrent); err == nil && current.Size() < offset {
+				// Truncated in place
+				file.Seek(0, io.SeekStart)
+				reader.Reset(file)
+				partial = ""
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(tailPollInterval):
+		}
+	}
+}
@@ -1,11 +1,18 @@
 package alerts
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
+	"log/slog"
+	"math"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kylereynolds/peep/internal/notifications"
@@ -24,6 +31,10 @@ type AlertRule struct {
 	CreatedAt   time.Time `json:"created_at"`
 	LastCheck   time.Time `json:"last_check"`
 	LastAlert   time.Time `json:"last_alert"`
+
+	Labels map[string]string `json:"labels"` // Free-form labels (team, service, priority)
+
+	Metric *MetricCondition `json:"metric,omitempty"` // Set for rules on the metrics table; Query then describes it
 }
 
 // AlertInstance represents a triggered alert
@@ -33,9 +44,14 @@ type AlertInstance struct {
 	RuleName  string    `json:"rule_name"`
 	Count     int       `json:"count"`
 	Threshold int       `json:"threshold"`
+	Value     float64   `json:"value,omitempty"` // A metric rule's aggregated value; Count is it rounded
 	Query     string    `json:"query"`
 	FiredAt   time.Time `json:"fired_at"`
 	Resolved  bool      `json:"resolved"`
+
+	ResolvedAt     time.Time `json:"resolved_at,omitempty"`
+	AcknowledgedAt time.Time `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string    `json:"acknowledged_by,omitempty"`
 }
 
 // NotificationChannel represents a way to send alerts
@@ -49,12 +65,44 @@ type NotificationChannel struct {
 
 // Engine manages alert rules and notifications
 type Engine struct {
-	storage   *storage.Storage
-	db        *sql.DB
-	rules     map[int64]*AlertRule
-	channels  map[int64]*NotificationChannel
-	stopChan  chan struct{}
-	isRunning bool
+	storage *storage.Storage
+	db      *sql.DB
+
+	// mu guards rules, channels, and digests: the monitoring loop reads them
+	// while the web UI and embedding programs change rules and add channels.
+	// Rules and channels are handed out as copies, so they can be read without it.
+	mu       sync.RWMutex
+	rules    map[int64]*AlertRule
+	channels map[int64]*NotificationChannel
+	digests  map[int64]*pendingDigest // Queued digest and quiet-hours alerts, keyed by channel ID
+
+	loopMu     sync.Mutex         // Guards cancelLoop, loopDone, and isRunning
+	cancelLoop context.CancelFunc // Stops the running loop, cancelling its queries
+	loopDone   chan struct{}      // Closed when the running loop returns
+	isRunning  bool
+
+	checkInterval time.Duration
+	intervalChan  chan time.Duration // Changes the running loop's interval
+
+	notificationsSent   atomic.Int64
+	notificationsFailed atomic.Int64
+
+	lastCheck atomic.Int64 // Unix nanoseconds of the loop's last finished check, for LastCheck
+
+	patternDormancy atomic.Int64 // How long a log pattern must go unseen to count as new again, for detectPatterns
+}
+
+// DefaultCheckInterval is how often rules are evaluated unless SetCheckInterval changes it
+const DefaultCheckInterval = 30 * time.Second
+
+// DefaultPatternDormancy is how long a log pattern must go unseen before it
+// counts as an anomaly again, unless SetPatternDormancy changes it
+const DefaultPatternDormancy = 7 * 24 * time.Hour
+
+// pendingDigest collects alerts for a channel between digest sends, or until its quiet hours end
+type pendingDigest struct {
+	alerts []*AlertInstance
+	since  time.Time
 }
 
 // NewEngine creates a new alert engine
@@ -64,8 +112,12 @@ func NewEngine(store *storage.Storage) (*Engine, error) {
 		db:       store.GetDB(),
 		rules:    make(map[int64]*AlertRule),
 		channels: make(map[int64]*NotificationChannel),
-		stopChan: make(chan struct{}),
+		digests:  make(map[int64]*pendingDigest),
+
+		checkInterval: DefaultCheckInterval,
+		intervalChan:  make(chan time.Duration, 1),
 	}
+	engine.patternDormancy.Store(int64(DefaultPatternDormancy))
 
 	if err := engine.createTables(); err != nil {
 		return nil, fmt.Errorf("failed to create alert tables: %w", err)
@@ -108,7 +160,8 @@ func (e *Engine) createTables() error {
 		enabled BOOLEAN NOT NULL DEFAULT 1,
 		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		last_check DATETIME,
-		last_alert DATETIME
+		last_alert DATETIME,
+		labels TEXT NOT NULL DEFAULT '{}' -- JSON
 	);
 
 	CREATE TABLE IF NOT EXISTS alert_instances (
@@ -138,6 +191,7 @@ func (e *Engine) createTables() error {
 		sent_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 		success BOOLEAN NOT NULL,
 		error_message TEXT,
+		external_ref TEXT, -- e.g. Slack thread for follow-ups
 		FOREIGN KEY (alert_id) REFERENCES alert_instances (id),
 		FOREIGN KEY (channel_id) REFERENCES notification_channels (id)
 	);
@@ -146,18 +200,91 @@ func (e *Engine) createTables() error {
 	CREATE INDEX IF NOT EXISTS idx_alert_instances_fired_at ON alert_instances(fired_at);
 	`
 
-	_, err := e.db.Exec(schema)
+	if _, err := e.db.Exec(schema); err != nil {
+		return err
+	}
+	if err := e.createSLOTable(); err != nil {
+		return err
+	}
+	if err := e.createReportTable(); err != nil {
+		return err
+	}
+
+	// Columns added after the initial schema; older databases need them backfilled
+	if err := e.ensureColumn("alert_rules", "labels", "TEXT NOT NULL DEFAULT '{}'"); err != nil {
+		return err
+	}
+	if err := e.ensureColumn("alert_rules", "metric", "TEXT"); err != nil { // JSON MetricCondition
+		return err
+	}
+	for _, column := range []struct{ name, definition string }{
+		{"resolved_at", "DATETIME"},
+		{"acknowledged_at", "DATETIME"},
+		{"acknowledged_by", "TEXT"},
+		{"value", "REAL"},
+	} {
+		if err := e.ensureColumn("alert_instances", column.name, column.definition); err != nil {
+			return err
+		}
+	}
+	return e.ensureColumn("alert_notifications", "external_ref", "TEXT")
+}
+
+// ensureColumn adds a column to an existing table if it is not already present
+func (e *Engine) ensureColumn(table, column, definition string) error {
+	rows, err := e.db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			cid       int
+			name      string
+			colType   string
+			notNull   bool
+			dfltValue sql.NullString
+			pk        int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &dfltValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	rows.Close()
+
+	_, err = e.db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
 	return err
 }
 
 // AddRule adds a new alert rule
 func (e *Engine) AddRule(rule *AlertRule) error {
+	if rule.Labels == nil {
+		rule.Labels = map[string]string{}
+	}
+	labelsJSON, err := json.Marshal(rule.Labels)
+	if err != nil {
+		return err
+	}
+	metricJSON, err := prepareRule(rule)
+	if err != nil {
+		return err
+	}
+	if rule.Metric != nil && rule.Metric.SLO != "" {
+		if _, err := e.GetSLO(rule.Metric.SLO); err != nil {
+			return err
+		}
+	}
+
 	query := `
-	INSERT INTO alert_rules (name, description, query, threshold, window, enabled)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO alert_rules (name, description, query, threshold, window, enabled, labels, metric)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := e.db.Exec(query, rule.Name, rule.Description, rule.Query, rule.Threshold, rule.Window, rule.Enabled)
+	result, err := e.db.Exec(query, rule.Name, rule.Description, rule.Query, rule.Threshold, rule.Window, rule.Enabled, string(labelsJSON), metricJSON)
 	if err != nil {
 		return err
 	}
@@ -169,26 +296,160 @@ func (e *Engine) AddRule(rule *AlertRule) error {
 
 	rule.ID = id
 	rule.CreatedAt = time.Now()
-	e.rules[id] = rule
+	stored := *rule // The caller's copy can change without racing the loop
+	e.mu.Lock()
+	e.rules[id] = &stored
+	e.mu.Unlock()
+
+	return nil
+}
+
+// GetRule returns a copy of a single alert rule by ID
+func (e *Engine) GetRule(id int64) (*AlertRule, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	rule, exists := e.rules[id]
+	if !exists {
+		return nil, false
+	}
+	copied := *rule
+	return &copied, true
+}
+
+// UpdateRule saves changes to an existing rule's definition. Check and alert
+// timestamps are left alone so editing a rule doesn't reset its history.
+// A metric rule updated without a Metric, e.g. from the web UI's rule form,
+// keeps its condition.
+func (e *Engine) UpdateRule(rule *AlertRule) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	existing, exists := e.rules[rule.ID]
+	if !exists {
+		return fmt.Errorf("alert rule %d not found", rule.ID)
+	}
+
+	if rule.Labels == nil {
+		rule.Labels = map[string]string{}
+	}
+	labelsJSON, err := json.Marshal(rule.Labels)
+	if err != nil {
+		return err
+	}
+	if rule.Metric == nil && existing.Metric != nil {
+		metric := *existing.Metric
+		rule.Metric = &metric
+	}
+	metricJSON, err := prepareRule(rule)
+	if err != nil {
+		return err
+	}
+
+	query := `
+	UPDATE alert_rules
+	SET name = ?, description = ?, query = ?, threshold = ?, window = ?, enabled = ?, labels = ?, metric = ?
+	WHERE id = ?
+	`
+
+	if _, err := e.db.Exec(query, rule.Name, rule.Description, rule.Query, rule.Threshold, rule.Window, rule.Enabled, string(labelsJSON), metricJSON, rule.ID); err != nil {
+		return err
+	}
+
+	existing.Name = rule.Name
+	existing.Description = rule.Description
+	existing.Query = rule.Query
+	existing.Threshold = rule.Threshold
+	existing.Window = rule.Window
+	existing.Enabled = rule.Enabled
+	existing.Labels = rule.Labels
+	existing.Metric = rule.Metric
+
+	return nil
+}
+
+// prepareRule checks a log rule's query is a single read statement, or
+// validates a metric rule's condition and derives its query and
+// whole-number threshold from it. It returns the condition as JSON, or nil
+// for a log rule.
+func prepareRule(rule *AlertRule) (interface{}, error) {
+	if rule.Metric == nil {
+		if _, err := storage.ReadStatement(rule.Query); err != nil {
+			return nil, fmt.Errorf("query: %w", err)
+		}
+		return nil, nil
+	}
+	if rule.Metric.Aggregate == "" && rule.Metric.SLO == "" {
+		rule.Metric.Aggregate = "avg"
+	}
+	if err := rule.Metric.Validate(); err != nil {
+		return nil, err
+	}
+	rule.Query = rule.Metric.String()
+	rule.Threshold = int(math.Round(rule.Metric.Threshold))
+
+	encoded, err := json.Marshal(rule.Metric)
+	if err != nil {
+		return nil, err
+	}
+	return string(encoded), nil
+}
+
+// SetRuleEnabled turns a rule on or off without changing its definition
+func (e *Engine) SetRuleEnabled(id int64, enabled bool) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	rule, exists := e.rules[id]
+	if !exists {
+		return fmt.Errorf("alert rule %d not found", id)
+	}
 
+	if _, err := e.db.Exec(`UPDATE alert_rules SET enabled = ? WHERE id = ?`, enabled, id); err != nil {
+		return err
+	}
+
+	rule.Enabled = enabled
+	return nil
+}
+
+// DeleteRule removes an alert rule. Its past alert instances are kept, since they
+// carry the rule name, so history and stats still make sense afterwards.
+func (e *Engine) DeleteRule(id int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.rules[id]; !exists {
+		return fmt.Errorf("alert rule %d not found", id)
+	}
+
+	if _, err := e.db.Exec(`DELETE FROM alert_rules WHERE id = ?`, id); err != nil {
+		return err
+	}
+
+	delete(e.rules, id)
 	return nil
 }
 
-// GetChannels returns all notification channels
+// GetChannels returns copies of all notification channels, oldest first
 func (e *Engine) GetChannels() []*NotificationChannel {
+	e.mu.RLock()
 	channels := make([]*NotificationChannel, 0, len(e.channels))
 	for _, channel := range e.channels {
-		channels = append(channels, channel)
+		copied := *channel
+		channels = append(channels, &copied)
 	}
+	e.mu.RUnlock()
+	sort.Slice(channels, func(i, j int) bool { return channels[i].ID < channels[j].ID })
 	return channels
 }
 
-// GetRules returns all alert rules
+// GetRules returns copies of all alert rules, oldest first
 func (e *Engine) GetRules() []*AlertRule {
+	e.mu.RLock()
 	rules := make([]*AlertRule, 0, len(e.rules))
 	for _, rule := range e.rules {
-		rules = append(rules, rule)
+		copied := *rule
+		rules = append(rules, &copied)
 	}
+	e.mu.RUnlock()
+	sort.Slice(rules, func(i, j int) bool { return rules[i].ID < rules[j].ID })
 	return rules
 }
 
@@ -215,15 +476,36 @@ func (e *Engine) AddNotificationChannel(channel *NotificationChannel) error {
 	}
 
 	channel.ID = id
-	e.channels[id] = channel
+	stored := *channel // The caller's copy can change without racing the loop
+	e.mu.Lock()
+	e.channels[id] = &stored
+	e.mu.Unlock()
+
+	return nil
+}
+
+// DeleteChannel removes a notification channel, dropping any digest it was
+// holding. Its past deliveries in alert_notifications are kept.
+func (e *Engine) DeleteChannel(id int64) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if _, exists := e.channels[id]; !exists {
+		return fmt.Errorf("notification channel %d not found", id)
+	}
+
+	if _, err := e.db.Exec(`DELETE FROM notification_channels WHERE id = ?`, id); err != nil {
+		return err
+	}
 
+	delete(e.channels, id)
+	delete(e.digests, id)
 	return nil
 }
 
 // loadRules loads all alert rules from the database
 func (e *Engine) loadRules() error {
 	query := `
-	SELECT id, name, description, query, threshold, window, enabled, created_at, last_check, last_alert
+	SELECT id, name, description, query, threshold, window, enabled, created_at, last_check, last_alert, labels, metric
 	FROM alert_rules
 	`
 
@@ -236,16 +518,28 @@ func (e *Engine) loadRules() error {
 	for rows.Next() {
 		rule := &AlertRule{}
 		var lastCheck, lastAlert sql.NullTime
+		var labelsJSON string
+		var metricJSON sql.NullString
 
 		err := rows.Scan(
 			&rule.ID, &rule.Name, &rule.Description, &rule.Query,
 			&rule.Threshold, &rule.Window, &rule.Enabled, &rule.CreatedAt,
-			&lastCheck, &lastAlert,
+			&lastCheck, &lastAlert, &labelsJSON, &metricJSON,
 		)
 		if err != nil {
 			return err
 		}
 
+		if err := json.Unmarshal([]byte(labelsJSON), &rule.Labels); err != nil || rule.Labels == nil {
+			rule.Labels = map[string]string{}
+		}
+		if metricJSON.String != "" {
+			rule.Metric = &MetricCondition{}
+			if err := json.Unmarshal([]byte(metricJSON.String), rule.Metric); err != nil {
+				return fmt.Errorf("rule %q has an invalid metric condition: %w", rule.Name, err)
+			}
+		}
+
 		if lastCheck.Valid {
 			rule.LastCheck = lastCheck.Time
 		}
@@ -293,79 +587,280 @@ func (e *Engine) loadChannels() error {
 
 // Start begins the alert monitoring loop
 func (e *Engine) Start() {
+	e.loopMu.Lock()
+	defer e.loopMu.Unlock()
 	if e.isRunning {
 		return
 	}
 
 	e.isRunning = true
-	go e.monitorLoop()
+	e.startLoop()
 }
 
-// Stop stops the alert monitoring
+// Stop stops the alert monitoring once the loop finishes what it's doing,
+// then sends the digests it was holding
 func (e *Engine) Stop() {
+	e.loopMu.Lock()
 	if !e.isRunning {
+		e.loopMu.Unlock()
 		return
 	}
-
-	e.stopChan <- struct{}{}
+	cancel, done := e.cancelLoop, e.loopDone
 	e.isRunning = false
+	e.loopMu.Unlock()
+
+	cancel()
+	<-done
+	e.flushDigests(true)
+}
+
+// Restart abandons the monitoring loop and starts a new one, for when the
+// loop has stopped checking, e.g. stuck on a query. The old loop's context
+// is cancelled, and it checks that between steps, so if it ever gets
+// unstuck it exits without evaluating rules, sending digests, or sending
+// reports alongside the new loop.
+func (e *Engine) Restart() {
+	e.loopMu.Lock()
+	defer e.loopMu.Unlock()
+	if !e.isRunning {
+		return
+	}
+
+	e.cancelLoop() // Interrupts a stuck query
+	e.startLoop()
+}
+
+// startLoop runs a monitoring loop with its own context; loopMu must be held
+func (e *Engine) startLoop() {
+	ctx, cancel := context.WithCancel(context.Background())
+	e.cancelLoop = cancel
+	e.loopDone = make(chan struct{})
+	e.lastCheck.Store(time.Now().UnixNano())
+	go e.monitorLoop(ctx, e.loopDone)
+}
+
+// LastCheck returns when the monitoring loop last finished checking the
+// rules, or when it started if it hasn't yet
+func (e *Engine) LastCheck() time.Time {
+	return time.Unix(0, e.lastCheck.Load())
+}
+
+// SetCheckInterval changes how often rules are evaluated, taking effect right
+// away if the engine is running. Zero restores the default.
+func (e *Engine) SetCheckInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultCheckInterval
+	}
+	e.loopMu.Lock()
+	defer e.loopMu.Unlock()
+	if !e.isRunning {
+		e.checkInterval = interval
+		return
+	}
+	// Replace an interval the loop hasn't picked up yet
+	select {
+	case <-e.intervalChan:
+	default:
+	}
+	e.intervalChan <- interval
 }
 
-// monitorLoop runs the alert checking loop
-func (e *Engine) monitorLoop() {
-	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
+// monitorLoop runs the alert checking loop until ctx is cancelled, then closes done
+func (e *Engine) monitorLoop(ctx context.Context, done chan struct{}) {
+	defer close(done)
+	ticker := time.NewTicker(e.checkInterval)
 	defer ticker.Stop()
 
 	for {
 		select {
 		case <-ticker.C:
-			e.checkAlerts()
-		case <-e.stopChan:
+			// A cancelled ctx means Stop or Restart came mid-check
+			e.detectPatterns(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			e.checkAlerts(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			e.lastCheck.Store(time.Now().UnixNano())
+			e.flushDigests(false)
+			if ctx.Err() != nil {
+				return
+			}
+			e.sendDueReports(time.Now())
+		case interval := <-e.intervalChan:
+			if interval != e.checkInterval {
+				e.checkInterval = interval
+				ticker.Reset(interval)
+				slog.Info("alert check interval changed", "interval", interval.String())
+			}
+		case <-ctx.Done():
+			// Stop sends the held digests; after Restart the new loop does
 			return
 		}
 	}
 }
 
-// checkAlerts evaluates all enabled alert rules
-func (e *Engine) checkAlerts() {
-	for _, rule := range e.rules {
+// SetPatternDormancy changes how long a log pattern must go unseen before
+// its return is flagged like a new one. Zero restores the default.
+func (e *Engine) SetPatternDormancy(dormancy time.Duration) {
+	if dormancy <= 0 {
+		dormancy = DefaultPatternDormancy
+	}
+	e.patternDormancy.Store(int64(dormancy))
+}
+
+// detectPatterns clusters the warnings and errors stored since the last
+// check, recording new and returning patterns in pattern_anomalies, where
+// alert rules can count them
+func (e *Engine) detectPatterns(ctx context.Context) {
+	anomalies, err := e.storage.DetectPatterns(ctx, time.Duration(e.patternDormancy.Load()))
+	if err != nil {
+		slog.Error("pattern detection failed", "error", err)
+	}
+	for _, anomaly := range anomalies {
+		slog.Info("log pattern anomaly", "kind", anomaly.Kind, "service", anomaly.Service, "level", anomaly.Level, "pattern", anomaly.Pattern)
+	}
+}
+
+// checkAlerts evaluates all enabled alert rules. They're copied first, so
+// rules edited meanwhile take effect on the next check.
+func (e *Engine) checkAlerts(ctx context.Context) {
+	for _, rule := range e.GetRules() {
+		if ctx.Err() != nil {
+			return
+		}
 		if !rule.Enabled {
 			continue
 		}
 
-		if err := e.evaluateRule(rule); err != nil {
-			fmt.Printf("Error evaluating rule %s: %v\n", rule.Name, err)
+		if err := e.evaluateRule(ctx, rule); err != nil {
+			slog.Error("rule evaluation failed", "rule", rule.Name, "error", err)
 		}
 	}
 }
 
 // evaluateRule checks a single alert rule
-func (e *Engine) evaluateRule(rule *AlertRule) error {
-	// Parse time window and create time-bounded query
-	timeQuery := e.buildTimeQuery(rule.Query, rule.Window)
+func (e *Engine) evaluateRule(ctx context.Context, rule *AlertRule) error {
+	result := e.measureRule(ctx, rule)
+	if result.Err != nil {
+		return result.Err
+	}
+	// Don't fire or resolve for a loop that's been replaced while measuring
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return e.applyResult(rule, result)
+}
 
-	var count int
-	err := e.db.QueryRow(timeQuery).Scan(&count)
+// measureRule runs a rule's query, or aggregates its metric, over its window
+func (e *Engine) measureRule(ctx context.Context, rule *AlertRule) RuleResult {
+	result := RuleResult{Rule: rule}
+	if rule.Metric == nil {
+		result.Count, result.Err = e.countRule(ctx, rule)
+		result.Value = float64(result.Count)
+		result.Firing = result.Err == nil && result.Count >= rule.Threshold
+		return result
+	}
+
+	// A series with no samples in the window has nothing to compare, so it
+	// doesn't fire; count rules on the series' sample count catch silence
+	value, found, err := e.metricValue(ctx, rule)
+	result.Value, result.Err = value, err
+	result.Count = int(math.Round(value))
+	result.Firing = err == nil && found && rule.Metric.Firing(value)
+	return result
+}
+
+// countRule runs a rule's query over its time window. A query running past
+// the storage query timeout fails the check rather than holding up the others.
+func (e *Engine) countRule(ctx context.Context, rule *AlertRule) (int, error) {
+	query, args, err := windowQuery(rule.Query, rule.Window)
 	if err != nil {
-		return err
+		return 0, err
+	}
+
+	ctx, cancel := e.storage.WithTimeout(ctx)
+	defer cancel()
+	var count int
+	err = e.scanReadOnly(ctx, query, args, &count)
+	return count, err
+}
+
+// scanReadOnly scans the first row of a user-written query, run read-only so
+// a rule or SLO can't write however its SQL is dressed up. It returns
+// sql.ErrNoRows if there's no row.
+func (e *Engine) scanReadOnly(ctx context.Context, query string, args []interface{}, dest ...interface{}) error {
+	scanned := false
+	err := e.storage.EachRowReadOnly(ctx, query, args, func(rows *sql.Rows) error {
+		if scanned {
+			return nil
+		}
+		scanned = true
+		return rows.Scan(dest...)
+	})
+	if err == nil && !scanned {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// RuleResult is the outcome of evaluating one rule with CheckRules
+type RuleResult struct {
+	Rule   *AlertRule
+	Count  int
+	Value  float64 // A metric rule's aggregated value; Count for log rules
+	Firing bool    // Count reached the threshold, or the metric condition held
+	Err    error   // The query failed
+}
+
+// CheckRules evaluates every enabled rule once, oldest first. Without notify
+// nothing is recorded. With notify, rules go through the monitor loop's path:
+// new log patterns are detected first, firing ones save an alert and notify
+// (cooldowns apply), cleared ones resolve, and queued digests are sent before
+// returning. Cancelling ctx stops the rule being measured.
+func (e *Engine) CheckRules(ctx context.Context, notify bool) []RuleResult {
+	if notify {
+		e.detectPatterns(ctx)
+	}
+
+	var results []RuleResult
+	for _, rule := range e.GetRules() {
+		if !rule.Enabled {
+			continue
+		}
+
+		result := e.measureRule(ctx, rule)
+		if result.Err == nil && notify {
+			result.Err = e.applyResult(rule, result)
+		}
+		results = append(results, result)
 	}
 
+	if notify {
+		e.flushDigests(true)
+	}
+	return results
+}
+
+// applyResult records a rule check, firing or resolving its alerts as the result requires
+func (e *Engine) applyResult(rule *AlertRule, result RuleResult) error {
 	// Update last check time
 	rule.LastCheck = time.Now()
 	e.updateRuleLastCheck(rule)
 
-	// Check if threshold is exceeded
-	if count >= rule.Threshold {
-		// Check if we should suppress this alert (cooldown period)
-		if e.shouldSuppressAlert(rule, count) {
-			// Optional: log suppression for debugging
-			fmt.Printf("🔕 Alert suppressed: %s - Count: %d (cooldown active)\n", rule.Name, count)
-			return nil // Alert suppressed
-		}
-		return e.fireAlert(rule, count)
+	// Condition cleared: resolve any open alerts for this rule
+	if !result.Firing {
+		return e.resolveAlerts(rule)
 	}
 
-	return nil
+	// Check if we should suppress this alert (cooldown period)
+	if e.shouldSuppressAlert(rule, result.Count) {
+		slog.Debug("alert suppressed", "rule", rule.Name, "count", result.Count, "reason", "cooldown")
+		return nil // Alert suppressed
+	}
+	return e.fireAlert(rule, result)
 }
 
 // shouldSuppressAlert determines if an alert should be suppressed based on cooldown period
@@ -427,40 +922,75 @@ func (e *Engine) getLastAlertInstance(ruleID int64) (*AlertInstance, error) {
 	return &instance, nil
 }
 
-// buildTimeQuery adds time window constraints to the alert query
-func (e *Engine) buildTimeQuery(query, window string) string {
-	// Parse window duration
+// windowQuery limits a rule's query to its window (default 5m), returning
+// the query and the arguments to bind
+func windowQuery(query, window string) (string, []interface{}, error) {
 	duration, err := time.ParseDuration(window)
 	if err != nil {
-		duration = 5 * time.Minute // Default to 5 minutes
+		duration = 5 * time.Minute
 	}
+	return timeBoundQuery(query, time.Now().Add(-duration))
+}
 
-	// Use local time with timezone offset to match the database timestamp format
-	localTime := time.Now().Local()
-	since := localTime.Add(-duration).Format("2006-01-02 15:04:05-07:00")
+// windowedTables are the tables a rule's window applies to, by their
+// timestamp column, with the patterns that find them in a query
+var windowedTables = []struct {
+	name      string
+	mention   *regexp.Regexp
+	qualified *regexp.Regexp // main.<table>, which names the table itself
+}{
+	{"logs", regexp.MustCompile(`(?i)\blogs\b`), regexp.MustCompile(`(?i)\bmain\s*\.\s*logs\b`)},
+	{"pattern_anomalies", regexp.MustCompile(`(?i)\bpattern_anomalies\b`), regexp.MustCompile(`(?i)\bmain\s*\.\s*pattern_anomalies\b`)},
+}
 
-	// Add time constraint to the query
-	if !containsWhere(query) {
-		return query + fmt.Sprintf(" WHERE timestamp >= '%s'", since)
-	} else {
-		return query + fmt.Sprintf(" AND timestamp >= '%s'", since)
+// timeBoundQuery limits a query to rows at or after start. Instead of adding
+// to the query's WHERE clause, which breaks on GROUP BY, subqueries, and
+// "where" inside strings, each windowed table the query reads is shadowed by
+// a CTE of the same name holding only the window's rows, and the query runs
+// as written inside it. Nesting rather than joining the query's own WITH
+// lets it name a CTE logs too; that CTE has to read main.logs, so main.logs
+// is pointed at the window as well. start is bound as a parameter rather
+// than spliced in.
+func timeBoundQuery(query string, start time.Time) (string, []interface{}, error) {
+	query, err := storage.ReadStatement(query)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var ctes []string
+	var args []interface{}
+	for _, table := range windowedTables {
+		if !table.mention.MatchString(query) {
+			continue
+		}
+		window := "peep_window_" + table.name
+		ctes = append(ctes,
+			fmt.Sprintf("%s AS (SELECT * FROM main.%s WHERE timestamp >= ?)", window, table.name),
+			fmt.Sprintf("%s AS (SELECT * FROM %s)", table.name, window))
+		query = storage.ReplaceInCode(query, table.qualified, window)
+		// Local time matches how timestamps are stored
+		args = append(args, start.Local())
+	}
+	if len(ctes) == 0 {
+		return query, nil, nil
 	}
-} // containsWhere checks if query already has a WHERE clause
-func containsWhere(query string) bool {
-	return strings.Contains(strings.ToUpper(query), "WHERE")
+	return "WITH " + strings.Join(ctes, ", ") + " SELECT * FROM (" + query + ")", args, nil
 }
 
 // fireAlert creates an alert instance and sends notifications
-func (e *Engine) fireAlert(rule *AlertRule, count int) error {
+func (e *Engine) fireAlert(rule *AlertRule, result RuleResult) error {
 	// Create alert instance
 	instance := &AlertInstance{
 		RuleID:    rule.ID,
 		RuleName:  rule.Name,
-		Count:     count,
+		Count:     result.Count,
 		Threshold: rule.Threshold,
 		Query:     rule.Query,
 		FiredAt:   time.Now(),
 	}
+	if rule.Metric != nil {
+		instance.Value = result.Value
+	}
 
 	if err := e.saveAlertInstance(instance); err != nil {
 		return err
@@ -470,11 +1000,15 @@ func (e *Engine) fireAlert(rule *AlertRule, count int) error {
 	rule.LastAlert = time.Now()
 	e.updateRuleLastAlert(rule)
 
-	// Send notifications to all enabled channels
-	for _, channel := range e.channels {
-		if channel.Enabled {
-			e.sendNotification(instance, channel)
+	// Send notifications to all enabled channels whose label selector matches the rule
+	for _, channel := range e.GetChannels() {
+		if !channel.Enabled {
+			continue
 		}
+		if !MatchLabels(rule.Labels, channel.Config["label_selector"]) {
+			continue
+		}
+		e.sendNotification(instance, channel)
 	}
 
 	return nil
@@ -483,11 +1017,11 @@ func (e *Engine) fireAlert(rule *AlertRule, count int) error {
 // saveAlertInstance saves an alert instance to the database
 func (e *Engine) saveAlertInstance(instance *AlertInstance) error {
 	query := `
-	INSERT INTO alert_instances (rule_id, rule_name, count, threshold, query, fired_at)
-	VALUES (?, ?, ?, ?, ?, ?)
+	INSERT INTO alert_instances (rule_id, rule_name, count, threshold, value, query, fired_at)
+	VALUES (?, ?, ?, ?, ?, ?, ?)
 	`
 
-	result, err := e.db.Exec(query, instance.RuleID, instance.RuleName, instance.Count, instance.Threshold, instance.Query, instance.FiredAt)
+	result, err := e.db.Exec(query, instance.RuleID, instance.RuleName, instance.Count, instance.Threshold, instance.Value, instance.Query, instance.FiredAt)
 	if err != nil {
 		return err
 	}
@@ -503,25 +1037,96 @@ func (e *Engine) saveAlertInstance(instance *AlertInstance) error {
 
 // updateRuleLastCheck updates the last check time for a rule
 func (e *Engine) updateRuleLastCheck(rule *AlertRule) {
+	e.mu.Lock()
+	if current, ok := e.rules[rule.ID]; ok {
+		current.LastCheck = rule.LastCheck
+	}
+	e.mu.Unlock()
+
 	query := `UPDATE alert_rules SET last_check = ? WHERE id = ?`
 	e.db.Exec(query, rule.LastCheck, rule.ID)
 }
 
 // updateRuleLastAlert updates the last alert time for a rule
 func (e *Engine) updateRuleLastAlert(rule *AlertRule) {
+	e.mu.Lock()
+	if current, ok := e.rules[rule.ID]; ok {
+		current.LastAlert = rule.LastAlert
+	}
+	e.mu.Unlock()
+
 	query := `UPDATE alert_rules SET last_alert = ? WHERE id = ?`
 	e.db.Exec(query, rule.LastAlert, rule.ID)
 }
 
+// resolveAlerts marks a rule's open alert instances as resolved and posts threaded resolution notices
+func (e *Engine) resolveAlerts(rule *AlertRule) error {
+	var open int
+	if err := e.db.QueryRow(`SELECT COUNT(*) FROM alert_instances WHERE rule_id = ? AND resolved = 0`, rule.ID).Scan(&open); err != nil {
+		return err
+	}
+	if open == 0 {
+		return nil
+	}
+
+	// Post before resolving, since threads are looked up from open instances
+	e.sendSlackResolutions(rule.ID, rule.Name)
+
+	if _, err := e.db.Exec(`UPDATE alert_instances SET resolved = 1, resolved_at = ? WHERE rule_id = ? AND resolved = 0`, time.Now(), rule.ID); err != nil {
+		return err
+	}
+
+	slog.Info("alert resolved", "rule", rule.Name)
+	return nil
+}
+
+// sendSlackResolutions posts a resolution reply to each Slack thread opened for a rule's open alerts
+func (e *Engine) sendSlackResolutions(ruleID int64, ruleName string) {
+	for _, channel := range e.GetChannels() {
+		if !channel.Enabled || channel.Type != "slack" {
+			continue
+		}
+		thread := e.findSlackThread(ruleID, channel.ID)
+		if thread == nil {
+			continue
+		}
+		if err := e.slackNotifier(channel).SendResolved(ruleName, thread); err != nil {
+			slog.Error("slack resolution failed", "rule", ruleName, "channel", channel.Name, "error", err)
+		}
+	}
+}
+
 // sendNotification sends an alert to a notification channel
 func (e *Engine) sendNotification(instance *AlertInstance, channel *NotificationChannel) {
+	// Outside the channel's active window, alerts are dropped or held for later
+	if !channelActive(channel, time.Now()) {
+		if quietAction(channel) == QuietDefer {
+			e.queueDigest(instance, channel)
+		} else {
+			slog.Info("alert suppressed", "rule", instance.RuleName, "channel", channel.Name, "reason", "quiet hours")
+		}
+		return
+	}
+
+	// Email channels with a digest interval batch alerts instead of sending each one
+	if channel.Type == "email" && digestInterval(channel) > 0 {
+		e.queueDigest(instance, channel)
+		return
+	}
+
+	e.dispatch(instance, channel)
+}
+
+// dispatch sends an alert through a channel immediately and records the result
+func (e *Engine) dispatch(instance *AlertInstance, channel *NotificationChannel) {
 	var err error
+	var externalRef string
 
 	switch channel.Type {
 	case "desktop":
 		err = e.sendDesktopNotification(instance, channel)
 	case "slack":
-		err = e.sendSlackNotification(instance, channel)
+		externalRef, err = e.sendSlackNotification(instance, channel)
 	case "email":
 		err = e.sendEmailNotification(instance, channel)
 	case "shell":
@@ -531,53 +1136,125 @@ func (e *Engine) sendNotification(instance *AlertInstance, channel *Notification
 	}
 
 	// Log notification result
-	e.logNotification(instance.ID, channel.ID, err == nil, err)
+	e.logNotification(instance.ID, channel.ID, externalRef, err == nil, err)
 }
 
 // sendDesktopNotification sends a desktop notification
 func (e *Engine) sendDesktopNotification(instance *AlertInstance, channel *NotificationChannel) error {
 	title := fmt.Sprintf("🚨 Peep Alert: %s", instance.RuleName)
-	message := fmt.Sprintf("Threshold exceeded: %d events (limit: %d)", instance.Count, instance.Threshold)
+	message := "Threshold exceeded: " + e.alertSummary(instance)
 
 	if err := notifications.SendDesktopNotification(title, message); err != nil {
-		// Fallback to console if desktop notification fails
-		fmt.Printf("🚨 ALERT: %s - Count: %d (threshold: %d)\n", instance.RuleName, instance.Count, instance.Threshold)
+		// Fall back to the log if desktop notification fails
+		slog.Warn("alert fired", "rule", instance.RuleName, "count", instance.Count, "threshold", instance.Threshold, "desktop_error", err)
 		return err
 	}
 
-	fmt.Printf("🚨 ALERT: %s - Count: %d (threshold: %d) [Desktop notification sent]\n", instance.RuleName, instance.Count, instance.Threshold)
+	slog.Warn("alert fired", "rule", instance.RuleName, "count", instance.Count, "threshold", instance.Threshold, "notified", "desktop")
 	return nil
 }
 
-// sendSlackNotification sends a Slack notification
-func (e *Engine) sendSlackNotification(instance *AlertInstance, channel *NotificationChannel) error {
-	webhookURL, exists := channel.Config["webhook_url"]
-	if !exists {
-		return fmt.Errorf("slack channel missing webhook_url in config")
+// sendSlackNotification sends a Slack notification, threading follow-ups under the
+// first message for the rule's open alert. It returns the thread reference to store.
+func (e *Engine) sendSlackNotification(instance *AlertInstance, channel *NotificationChannel) (string, error) {
+	if channel.Config["webhook_url"] == "" && channel.Config["bot_token"] == "" {
+		return "", fmt.Errorf("slack channel missing webhook_url or bot_token in config")
 	}
 
-	title := instance.RuleName
-	message := fmt.Sprintf("Alert threshold exceeded: **%d events** detected (limit: %d)", instance.Count, instance.Threshold)
+	var labels map[string]string
+	if rule, ok := e.GetRule(instance.RuleID); ok {
+		labels = rule.Labels
+	}
 
-	if err := notifications.SendSlackNotification(webhookURL, title, message, instance.Count, instance.Threshold); err != nil {
-		fmt.Printf("❌ Failed to send Slack notification: %v\n", err)
-		return err
+	alert := notifications.SlackAlert{
+		AlertID:   instance.ID,
+		Title:     instance.RuleName,
+		Message:   e.slackMessage(instance),
+		Count:     instance.Count,
+		Threshold: instance.Threshold,
+		Labels:    labels,
+		FiredAt:   instance.FiredAt,
 	}
 
-	fmt.Printf("📱 Slack notification sent: %s [%d/%d]\n", instance.RuleName, instance.Count, instance.Threshold)
-	return nil
+	thread := e.findSlackThread(instance.RuleID, channel.ID)
+	posted, err := e.slackNotifier(channel).SendAlert(alert, thread)
+	if err != nil {
+		slog.Error("slack notification failed", "rule", instance.RuleName, "channel", channel.Name, "error", err)
+		return "", err
+	}
+
+	slog.Info("slack notification sent", "rule", instance.RuleName, "channel", channel.Name,
+		"count", instance.Count, "threshold", instance.Threshold, "follow_up", thread != nil)
+	return posted.String(), nil
+}
+
+// slackNotifier builds a Slack notifier from a channel's config
+func (e *Engine) slackNotifier(channel *NotificationChannel) *notifications.SlackNotification {
+	return notifications.NewSlackNotification(notifications.SlackConfig{
+		WebhookURL: channel.Config["webhook_url"],
+		BotToken:   channel.Config["bot_token"],
+		Channel:    channel.Config["channel"],
+		PeepURL:    channel.Config["peep_url"],
+	})
+}
+
+// findSlackThread returns the thread of the oldest open alert for a rule on a channel, if any
+func (e *Engine) findSlackThread(ruleID, channelID int64) *notifications.SlackThread {
+	query := `
+	SELECT n.external_ref
+	FROM alert_notifications n
+	JOIN alert_instances i ON i.id = n.alert_id
+	WHERE i.rule_id = ? AND n.channel_id = ? AND i.resolved = 0
+	  AND n.success = 1 AND n.external_ref IS NOT NULL AND n.external_ref != ''
+	ORDER BY i.fired_at ASC
+	LIMIT 1
+	`
+
+	var ref string
+	if err := e.db.QueryRow(query, ruleID, channelID).Scan(&ref); err != nil {
+		return nil
+	}
+
+	thread, ok := notifications.ParseSlackThread(ref)
+	if !ok {
+		return nil
+	}
+	return &thread
 }
 
 // sendEmailNotification sends an email notification
 func (e *Engine) sendEmailNotification(instance *AlertInstance, channel *NotificationChannel) error {
+	emailNotifier := e.emailNotifier(channel)
+
+	if err := emailNotifier.SendAlert(e.emailAlert(instance)); err != nil {
+		slog.Error("email notification failed", "rule", instance.RuleName, "channel", channel.Name, "error", err)
+		return err
+	}
+
+	slog.Info("email notification sent", "rule", instance.RuleName, "channel", channel.Name)
+	return nil
+}
+
+// emailNotifier builds an email notifier from a channel's config
+func (e *Engine) emailNotifier(channel *NotificationChannel) *notifications.EmailNotification {
 	// Extract email configuration from channel config
 	emailConfig := notifications.EmailConfig{
-		SMTPHost:  channel.Config["smtp_host"],
-		Username:  channel.Config["username"],
-		Password:  channel.Config["password"],
-		FromEmail: channel.Config["from_email"],
-		FromName:  channel.Config["from_name"],
-		ToEmails:  strings.Split(channel.Config["to_emails"], ","),
+		SMTPHost:         channel.Config["smtp_host"],
+		Username:         channel.Config["username"],
+		Password:         channel.Config["password"],
+		FromEmail:        channel.Config["from_email"],
+		FromName:         channel.Config["from_name"],
+		ToEmails:         strings.Split(channel.Config["to_emails"], ","),
+		HTMLTemplatePath: channel.Config["html_template"],
+		TextTemplatePath: channel.Config["text_template"],
+		SubjectTemplate:  channel.Config["subject_template"],
+		DashboardURL:     channel.Config["peep_url"],
+
+		UseTLS:             channel.Config["use_tls"] == "true",
+		Security:           channel.Config["security"],
+		AuthMethod:         channel.Config["auth"],
+		CAFile:             channel.Config["ca_file"],
+		InsecureSkipVerify: channel.Config["skip_verify"] == "true",
 	}
 
 	// Parse SMTP port
@@ -596,36 +1273,202 @@ func (e *Engine) sendEmailNotification(instance *AlertInstance, channel *Notific
 		emailConfig.ToEmails[i] = strings.TrimSpace(email)
 	}
 
-	emailNotifier := notifications.NewEmailNotification(emailConfig)
+	return notifications.NewEmailNotification(emailConfig)
+}
 
-	title := fmt.Sprintf("Alert: %s", instance.RuleName)
-	message := fmt.Sprintf("Alert threshold exceeded!\n\nRule: %s\nQuery: %s\nCount: %d\nThreshold: %d\nTime: %s",
-		instance.RuleName,
-		instance.Query,
-		instance.Count,
-		instance.Threshold,
-		instance.FiredAt.Format("2006-01-02 15:04:05"),
-	)
+// emailAlert collects the rule details and sample logs for an alert email
+func (e *Engine) emailAlert(instance *AlertInstance) notifications.EmailAlert {
+	alert := notifications.EmailAlert{
+		RuleName:  instance.RuleName,
+		Query:     instance.Query,
+		Count:     instance.Count,
+		Threshold: instance.Threshold,
+		Severity:  alertSeverity(instance),
+		FiredAt:   instance.FiredAt,
+	}
 
-	severity := "warning"
+	if rule, ok := e.GetRule(instance.RuleID); ok {
+		alert.Description = rule.Description
+		alert.Labels = rule.Labels
+		for _, entry := range e.sampleLogs(rule, 5) {
+			alert.SampleLogs = append(alert.SampleLogs, notifications.EmailLogLine{
+				Timestamp: entry.Timestamp,
+				Level:     entry.Level,
+				Service:   entry.Service,
+				Message:   entry.Message,
+			})
+		}
+	}
+
+	return alert
+}
+
+// alertSeverity maps how far a count exceeds its threshold to a severity
+func alertSeverity(instance *AlertInstance) string {
 	if instance.Count >= instance.Threshold*2 {
-		severity = "critical"
+		return "critical"
+	}
+	return "warning"
+}
+
+// countQueryPattern matches rule queries of the form "SELECT COUNT(*) FROM logs ..."
+var countQueryPattern = regexp.MustCompile(`(?is)^\s*SELECT\s+COUNT\s*\(\s*\*\s*\)\s+FROM\s+logs\b`)
+
+// sampleLogs returns the most recent logs matched by a rule's query within its window.
+// Only plain COUNT(*) queries over the logs table can be turned into a sample query.
+func (e *Engine) sampleLogs(rule *AlertRule, limit int) []storage.LogEntry {
+	if !countQueryPattern.MatchString(rule.Query) {
+		return nil
 	}
 
-	if err := emailNotifier.Send(title, message, severity); err != nil {
-		fmt.Printf("❌ Failed to send email notification: %v\n", err)
-		return err
+	query := countQueryPattern.ReplaceAllString(rule.Query,
+		"SELECT id, timestamp, level, message, service, context, raw_log, created_at FROM logs")
+	query, args, err := windowQuery(query, rule.Window)
+	if err != nil {
+		return nil
 	}
 
-	fmt.Printf("📧 Email notification sent: %s\n", instance.RuleName)
-	return nil
+	var logs []storage.LogEntry
+	err = e.storage.EachRowReadOnly(context.Background(), query+" ORDER BY timestamp DESC LIMIT ?", append(args, limit), func(rows *sql.Rows) error {
+		entry, err := storage.ScanLog(rows)
+		if err != nil {
+			return err
+		}
+		logs = append(logs, entry)
+		return nil
+	})
+	if err != nil {
+		return nil
+	}
+	return logs
 }
 
-// sendShellNotification executes a shell script
-func (e *Engine) sendShellNotification(instance *AlertInstance, channel *NotificationChannel) error {
+// digestInterval returns how long an email channel batches alerts, or 0 to send immediately
+func digestInterval(channel *NotificationChannel) time.Duration {
+	interval, err := time.ParseDuration(channel.Config["digest_interval"])
+	if err != nil || interval <= 0 {
+		return 0
+	}
+	return interval
+}
+
+// queueDigest holds an alert until the channel's next digest is due
+func (e *Engine) queueDigest(instance *AlertInstance, channel *NotificationChannel) {
+	e.mu.Lock()
+	digest, exists := e.digests[channel.ID]
+	if !exists {
+		digest = &pendingDigest{since: time.Now()}
+		e.digests[channel.ID] = digest
+	}
+	digest.alerts = append(digest.alerts, instance)
+	pending := len(digest.alerts)
+	e.mu.Unlock()
+
+	slog.Info("alert queued for digest", "rule", instance.RuleName, "channel", channel.Name, "pending", pending)
+}
+
+// flushDigests sends every digest whose interval has elapsed, or all of them when force is set.
+// Digests for channels in quiet hours are held until the window opens.
+func (e *Engine) flushDigests(force bool) {
+	type dueDigest struct {
+		channel *NotificationChannel
+		alerts  []*AlertInstance
+	}
+	var due []dueDigest
+
+	// Take the due digests out under the lock, then send them without it
+	now := time.Now()
+	e.mu.Lock()
+	for channelID, digest := range e.digests {
+		channel, exists := e.channels[channelID]
+		if !exists {
+			delete(e.digests, channelID)
+			continue
+		}
+
+		if !channelActive(channel, now) {
+			if force {
+				slog.Warn("dropping deferred alerts", "channel", channel.Name, "alerts", len(digest.alerts), "reason", "quiet hours")
+				delete(e.digests, channelID)
+			}
+			continue
+		}
+		if !force && now.Sub(digest.since) < digestInterval(channel) {
+			continue
+		}
+
+		due = append(due, dueDigest{channel, digest.alerts})
+		delete(e.digests, channelID)
+	}
+	e.mu.Unlock()
+
+	for _, digest := range due {
+		e.sendDigest(digest.channel, digest.alerts)
+	}
+}
+
+// sendDigest delivers a batch of held alerts as a single notification where the channel supports it
+func (e *Engine) sendDigest(channel *NotificationChannel, instances []*AlertInstance) {
+	// A single held alert goes out as a normal notification
+	if len(instances) == 1 && channel.Type != "email" {
+		e.dispatch(instances[0], channel)
+		return
+	}
+
+	var err error
+	switch channel.Type {
+	case "email":
+		emailAlerts := make([]notifications.EmailAlert, len(instances))
+		for i, instance := range instances {
+			emailAlerts[i] = e.emailAlert(instance)
+		}
+		if err = e.emailNotifier(channel).SendDigest(emailAlerts); err == nil {
+			slog.Info("digest sent", "type", channel.Type, "channel", channel.Name, "alerts", len(instances))
+		}
+	case "slack":
+		slackAlerts := make([]notifications.SlackAlert, len(instances))
+		for i, instance := range instances {
+			slackAlerts[i] = notifications.SlackAlert{
+				AlertID:   instance.ID,
+				Title:     instance.RuleName,
+				Count:     instance.Count,
+				Threshold: instance.Threshold,
+				FiredAt:   instance.FiredAt,
+			}
+		}
+		if err = e.slackNotifier(channel).SendDigest(slackAlerts); err == nil {
+			slog.Info("digest sent", "type", channel.Type, "channel", channel.Name, "alerts", len(instances))
+		}
+	case "desktop":
+		names := make([]string, len(instances))
+		for i, instance := range instances {
+			names[i] = instance.RuleName
+		}
+		title := fmt.Sprintf("🚨 Peep: %d alerts while you were away", len(instances))
+		if err = notifications.SendDesktopNotification(title, strings.Join(names, ", ")); err == nil {
+			slog.Info("digest sent", "type", channel.Type, "channel", channel.Name, "alerts", len(instances))
+		}
+	default:
+		// Shell scripts and unknown types get each alert individually
+		for _, instance := range instances {
+			e.dispatch(instance, channel)
+		}
+		return
+	}
+
+	if err != nil {
+		slog.Error("digest failed", "type", channel.Type, "channel", channel.Name, "error", err)
+	}
+	for _, instance := range instances {
+		e.logNotification(instance.ID, channel.ID, "", err == nil, err)
+	}
+}
+
+// shellNotifier builds a shell notifier from a channel's config
+func (e *Engine) shellNotifier(channel *NotificationChannel) (*notifications.ShellNotification, error) {
 	scriptPath, exists := channel.Config["script_path"]
 	if !exists {
-		return fmt.Errorf("shell channel missing script_path in config")
+		return nil, fmt.Errorf("shell channel missing script_path in config")
 	}
 
 	// Parse timeout (optional)
@@ -664,42 +1507,164 @@ func (e *Engine) sendShellNotification(instance *AlertInstance, channel *Notific
 		Environment: environment,
 	}
 
-	shellNotifier := notifications.NewShellNotification(shellConfig)
+	return notifications.NewShellNotification(shellConfig), nil
+}
 
-	title := instance.RuleName
-	message := fmt.Sprintf("Alert threshold exceeded!\n\nRule: %s\nQuery: %s\nCount: %d\nThreshold: %d\nTime: %s",
-		instance.RuleName,
-		instance.Query,
-		instance.Count,
-		instance.Threshold,
-		instance.FiredAt.Format("2006-01-02 15:04:05"),
-	)
+// sendShellNotification executes a shell script
+func (e *Engine) sendShellNotification(instance *AlertInstance, channel *NotificationChannel) error {
+	shellNotifier, err := e.shellNotifier(channel)
+	if err != nil {
+		return err
+	}
 
-	severity := "warning"
-	if instance.Count >= instance.Threshold*2 {
-		severity = "critical"
+	payload := notifications.ShellPayload{
+		AlertID: instance.ID,
+		Title:   instance.RuleName,
+		Message: fmt.Sprintf("Alert threshold exceeded!\n\nRule: %s\nQuery: %s\n%s\nTime: %s",
+			instance.RuleName,
+			instance.Query,
+			e.shellDetail(instance),
+			instance.FiredAt.Format("2006-01-02 15:04:05"),
+		),
+		Severity:  alertSeverity(instance),
+		Count:     instance.Count,
+		Threshold: instance.Threshold,
+		FiredAt:   instance.FiredAt,
+		Rule: notifications.ShellRule{
+			ID:    instance.RuleID,
+			Name:  instance.RuleName,
+			Query: instance.Query,
+		},
+	}
+
+	if rule, ok := e.GetRule(instance.RuleID); ok {
+		payload.Rule.Description = rule.Description
+		payload.Rule.Window = rule.Window
+		payload.Rule.Labels = rule.Labels
+		for _, entry := range e.sampleLogs(rule, 10) {
+			payload.SampleLogs = append(payload.SampleLogs, notifications.ShellLogLine{
+				Timestamp: entry.Timestamp,
+				Level:     entry.Level,
+				Service:   entry.Service,
+				Message:   entry.Message,
+			})
+		}
 	}
 
-	if err := shellNotifier.Execute(title, message, severity, instance.Count, instance.Threshold); err != nil {
-		fmt.Printf("❌ Failed to execute shell notification: %v\n", err)
+	if err := shellNotifier.ExecuteAlert(payload); err != nil {
+		slog.Error("shell notification failed", "rule", instance.RuleName, "channel", channel.Name, "error", err)
 		return err
 	}
 
-	fmt.Printf("🖥️  Shell script executed: %s [%s]\n", instance.RuleName, scriptPath)
+	slog.Info("shell notification sent", "rule", instance.RuleName, "channel", channel.Name, "script", channel.Config["script_path"])
+	return nil
+}
+
+// TestChannel sends a test notification through a stored channel's config.
+// Test sends aren't recorded in alert_notifications and never start Slack threads.
+func (e *Engine) TestChannel(channelID int64) error {
+	e.mu.RLock()
+	channel, ok := e.channels[channelID]
+	e.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("notification channel %d not found", channelID)
+	}
+
+	switch channel.Type {
+	case "desktop":
+		return notifications.SendDesktopNotification("🧪 Peep Test", "Desktop notifications are working!")
+	case "slack":
+		if channel.Config["webhook_url"] == "" && channel.Config["bot_token"] == "" {
+			return fmt.Errorf("slack channel missing webhook_url or bot_token in config")
+		}
+		_, err := e.slackNotifier(channel).SendAlert(notifications.SlackAlert{
+			Title:     "Peep Test Alert",
+			Message:   fmt.Sprintf("This is a test notification for the *%s* channel. If you can see this, it's configured correctly!", channel.Name),
+			Count:     5,
+			Threshold: 3,
+			FiredAt:   time.Now(),
+		}, nil)
+		return err
+	case "email":
+		return e.emailNotifier(channel).TestConnection()
+	case "shell":
+		shellNotifier, err := e.shellNotifier(channel)
+		if err != nil {
+			return err
+		}
+		return shellNotifier.TestScript()
+	default:
+		return fmt.Errorf("unknown notification type: %s", channel.Type)
+	}
+}
+
+// FindChannel returns the notification channel with the given name
+func (e *Engine) FindChannel(name string) (*NotificationChannel, bool) {
+	for _, channel := range e.GetChannels() {
+		if strings.EqualFold(channel.Name, name) {
+			return channel, true
+		}
+	}
+	return nil, false
+}
+
+// SendMessage sends a one-off message that isn't about an alert rule, such as
+// a notice from the daemon itself, through a channel. Quiet hours and digests
+// don't apply, and nothing is recorded in alert_notifications.
+func (e *Engine) SendMessage(channel *NotificationChannel, title, message, severity string) error {
+	var err error
+	switch channel.Type {
+	case "desktop":
+		err = notifications.SendDesktopNotification(title, message)
+	case "slack":
+		err = e.slackNotifier(channel).SendMessage(fmt.Sprintf("*%s*\n%s", title, message))
+	case "email":
+		err = e.emailNotifier(channel).Send(title, message, severity)
+	case "shell":
+		var shellNotifier *notifications.ShellNotification
+		if shellNotifier, err = e.shellNotifier(channel); err == nil {
+			err = shellNotifier.ExecuteAlert(notifications.ShellPayload{
+				Title:    title,
+				Message:  message,
+				Severity: severity,
+				FiredAt:  time.Now(),
+				Rule:     notifications.ShellRule{Name: title},
+			})
+		}
+	default:
+		err = fmt.Errorf("unknown notification type: %s", channel.Type)
+	}
+
+	if err != nil {
+		e.notificationsFailed.Add(1)
+		return fmt.Errorf("failed to notify %s: %w", channel.Name, err)
+	}
+	e.notificationsSent.Add(1)
 	return nil
 }
 
 // logNotification logs the result of sending a notification
-func (e *Engine) logNotification(alertID, channelID int64, success bool, err error) {
+func (e *Engine) logNotification(alertID, channelID int64, externalRef string, success bool, err error) {
 	query := `
-	INSERT INTO alert_notifications (alert_id, channel_id, success, error_message)
-	VALUES (?, ?, ?, ?)
+	INSERT INTO alert_notifications (alert_id, channel_id, success, error_message, external_ref)
+	VALUES (?, ?, ?, ?, ?)
 	`
 
 	var errorMsg string
 	if err != nil {
 		errorMsg = err.Error()
 	}
+	if success {
+		e.notificationsSent.Add(1)
+	} else {
+		e.notificationsFailed.Add(1)
+	}
+
+	e.db.Exec(query, alertID, channelID, success, errorMsg, externalRef)
+}
 
-	e.db.Exec(query, alertID, channelID, success, errorMsg)
+// NotificationStats returns how many notifications this engine has sent, and
+// how many failed, since it was created
+func (e *Engine) NotificationStats() (sent, failed int64) {
+	return e.notificationsSent.Load(), e.notificationsFailed.Load()
 }
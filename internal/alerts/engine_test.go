@@ -0,0 +1,230 @@
+package alerts
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// newTestEngine returns an engine on a fresh database
+func newTestEngine(t *testing.T) *Engine {
+	t.Helper()
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+
+	engine, err := NewEngine(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return engine
+}
+
+// Rules edited from the web UI while the monitoring loop checks them mustn't race
+func TestRulesEditedDuringChecks(t *testing.T) {
+	engine := newTestEngine(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for ctx.Err() == nil {
+			engine.checkAlerts(ctx)
+			engine.flushDigests(false)
+		}
+	}()
+
+	for i := 0; i < 50; i++ {
+		rule := &AlertRule{
+			Name:      fmt.Sprintf("rule %d", i),
+			Query:     "SELECT COUNT(*) FROM logs WHERE level = 'error'",
+			Threshold: 1000,
+			Window:    "5m",
+			Enabled:   true,
+		}
+		if err := engine.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+		rule.Threshold = 2000 // The engine keeps its own copy
+		if err := engine.UpdateRule(rule); err != nil {
+			t.Fatal(err)
+		}
+		if err := engine.SetRuleEnabled(rule.ID, i%2 == 0); err != nil {
+			t.Fatal(err)
+		}
+		engine.GetRules()
+		engine.GetChannels()
+		if i%3 == 0 {
+			if err := engine.DeleteRule(rule.ID); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	cancel()
+	wg.Wait()
+
+	if got := len(engine.GetRules()); got != 33 {
+		t.Errorf("got %d rules, want 33", got)
+	}
+}
+
+// Channels handed in and out are copies, so callers can't change the engine's
+func TestChannelsAreCopies(t *testing.T) {
+	engine := newTestEngine(t)
+	channel := &NotificationChannel{Name: "ops", Type: "shell", Config: map[string]string{"script_path": "/bin/true"}, Enabled: true}
+	if err := engine.AddNotificationChannel(channel); err != nil {
+		t.Fatal(err)
+	}
+	channel.Enabled = false
+
+	found, ok := engine.FindChannel("ops")
+	if !ok || !found.Enabled {
+		t.Fatalf("got %+v, want the enabled ops channel", found)
+	}
+	found.Name = "renamed"
+	for _, channel := range engine.GetChannels() {
+		if channel.Name == "renamed" {
+			t.Error("changing a returned channel changed the engine's")
+		}
+	}
+}
+
+// A restarted engine runs one loop, and Stop waits for it
+func TestRestartThenStop(t *testing.T) {
+	engine := newTestEngine(t)
+	engine.SetCheckInterval(time.Millisecond)
+	engine.Start()
+	engine.Restart()
+	engine.Restart()
+
+	stopped := make(chan struct{})
+	go func() {
+		engine.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Stop didn't return")
+	}
+	engine.Stop() // Stopping twice is harmless
+}
+
+// insertTestLogs stores two logs of each level an hour ago, outside a 5m
+// window, and one of each now
+func insertTestLogs(t *testing.T, engine *Engine) {
+	t.Helper()
+	var entries []storage.LogEntry
+	for _, level := range []string{"error", "info"} {
+		for _, at := range []time.Time{time.Now().Add(-time.Hour), time.Now().Add(-time.Hour), time.Now()} {
+			entries = append(entries, storage.LogEntry{Timestamp: at, Level: level, Message: level + " where logs", Service: "api"})
+		}
+	}
+	if err := engine.storage.InsertLogs(entries); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTimeBoundQuery(t *testing.T) {
+	engine := newTestEngine(t)
+	insertTestLogs(t, engine)
+
+	tests := []struct {
+		name  string
+		query string
+		want  int
+	}{
+		{"plain", "SELECT COUNT(*) FROM logs WHERE level = 'error'", 1},
+		{"qualified", "SELECT COUNT(*) FROM main.logs WHERE level = 'error'", 1},
+		{"group by", "SELECT MAX(n) FROM (SELECT level, COUNT(*) AS n FROM logs GROUP BY level)", 1},
+		{"subquery", "SELECT COUNT(*) FROM logs WHERE id IN (SELECT id FROM logs WHERE level = 'error')", 1},
+		{"join", "SELECT COUNT(*) FROM logs a JOIN logs b ON a.service = b.service WHERE a.level = 'error' AND b.level = 'info'", 1},
+		{"string literal", "SELECT COUNT(*) FROM logs WHERE message = 'error where logs'", 1},
+		{"string naming main.logs", "SELECT COUNT(*) FROM logs WHERE message <> 'main.logs'", 2},
+		{"own CTE", "WITH errors AS (SELECT * FROM logs WHERE level = 'error') SELECT COUNT(*) FROM errors", 1},
+		{"own CTE named logs", "WITH logs AS (SELECT * FROM main.logs WHERE level = 'error') SELECT COUNT(*) FROM logs", 1},
+		{"trailing semicolon", "SELECT COUNT(*) FROM logs; -- errors and all", 2},
+		{"no windowed table", "SELECT 7", 7},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := timeBoundQuery(tt.query, time.Now().Add(-5*time.Minute))
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got int
+			if err := engine.scanReadOnly(context.Background(), query, args, &got); err != nil {
+				t.Fatalf("%s: %v", query, err)
+			}
+			if got != tt.want {
+				t.Errorf("got %d, want %d from %s", got, tt.want, query)
+			}
+		})
+	}
+}
+
+// A rule can't write, whether it's added that way or the check runs it anyway
+func TestRuleQueriesCantWrite(t *testing.T) {
+	engine := newTestEngine(t)
+	insertTestLogs(t, engine)
+
+	for _, query := range []string{
+		"DELETE FROM logs",
+		"WITH c AS (SELECT 1) DELETE FROM logs",
+		"SELECT COUNT(*) FROM logs; DELETE FROM logs",
+	} {
+		err := engine.AddRule(&AlertRule{Name: "bad", Query: query, Threshold: 1, Window: "5m", Enabled: true})
+		if err == nil {
+			t.Errorf("AddRule accepted %q", query)
+		}
+	}
+
+	rule := &AlertRule{Name: "good", Query: "SELECT COUNT(*) FROM logs", Threshold: 1, Window: "5m", Enabled: true}
+	if err := engine.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	rule.Query = "WITH c AS (SELECT 1) DELETE FROM logs"
+	if err := engine.UpdateRule(rule); err == nil {
+		t.Error("UpdateRule accepted a DELETE")
+	}
+
+	// A rule stored before queries were checked still only reads
+	engine.mu.Lock()
+	engine.rules[rule.ID].Query = "WITH c AS (SELECT 1) DELETE FROM logs"
+	engine.mu.Unlock()
+	for _, result := range engine.CheckRules(context.Background(), false) {
+		if result.Err == nil || !strings.Contains(result.Err.Error(), "DELETE") {
+			t.Errorf("check got %v, want an error refusing the DELETE", result.Err)
+		}
+	}
+
+	var count int
+	if err := engine.scanReadOnly(context.Background(), "SELECT COUNT(*) FROM logs", nil, &count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 6 {
+		t.Errorf("%d logs left, want 6", count)
+	}
+}
+
+// Sample logs come from the rule's window, newest first
+func TestSampleLogs(t *testing.T) {
+	engine := newTestEngine(t)
+	insertTestLogs(t, engine)
+
+	rule := &AlertRule{Query: "SELECT COUNT(*) FROM logs WHERE level = 'error'", Window: "5m"}
+	logs := engine.sampleLogs(rule, 10)
+	if len(logs) != 1 || logs[0].Level != "error" {
+		t.Errorf("got %+v, want the one recent error", logs)
+	}
+}
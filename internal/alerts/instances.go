@@ -0,0 +1,180 @@
+package alerts
+
+import (
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// Alert instance states, as shown in the history view
+const (
+	StateOpen         = "open"
+	StateAcknowledged = "acknowledged"
+	StateResolved     = "resolved"
+)
+
+// State reports whether an alert is open, acknowledged, or resolved
+func (a *AlertInstance) State() string {
+	switch {
+	case a.Resolved:
+		return StateResolved
+	case !a.AcknowledgedAt.IsZero():
+		return StateAcknowledged
+	default:
+		return StateOpen
+	}
+}
+
+// InstanceFilter narrows the alert history. Zero values match everything.
+type InstanceFilter struct {
+	RuleID     int64
+	RuleName   string // Matches alerts from deleted rules too, unlike RuleID
+	Since      time.Time
+	State      string // StateOpen, StateAcknowledged, or StateResolved
+	Unresolved bool   // Open or acknowledged
+	Limit      int    // Default: 100
+}
+
+const instanceColumns = `id, rule_id, rule_name, count, threshold, value, query, fired_at, resolved, resolved_at, acknowledged_at, acknowledged_by`
+
+// ListAlertInstances returns fired alerts matching the filter, newest first
+func (e *Engine) ListAlertInstances(filter InstanceFilter) ([]*AlertInstance, error) {
+	query := `SELECT ` + instanceColumns + ` FROM alert_instances WHERE 1=1`
+	args := []interface{}{}
+
+	if filter.RuleID != 0 {
+		query += " AND rule_id = ?"
+		args = append(args, filter.RuleID)
+	}
+
+	if filter.RuleName != "" {
+		query += " AND rule_name = ?"
+		args = append(args, filter.RuleName)
+	}
+
+	if !filter.Since.IsZero() {
+		query += " AND fired_at >= ?"
+		args = append(args, filter.Since)
+	}
+
+	switch filter.State {
+	case "":
+	case StateOpen:
+		query += " AND resolved = 0 AND acknowledged_at IS NULL"
+	case StateAcknowledged:
+		query += " AND resolved = 0 AND acknowledged_at IS NOT NULL"
+	case StateResolved:
+		query += " AND resolved = 1"
+	default:
+		return nil, fmt.Errorf("unknown alert state %q", filter.State)
+	}
+
+	if filter.Unresolved {
+		query += " AND resolved = 0"
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	query += " ORDER BY fired_at DESC LIMIT ?"
+	args = append(args, limit)
+
+	rows, err := e.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var instances []*AlertInstance
+	for rows.Next() {
+		instance, err := scanInstance(rows)
+		if err != nil {
+			return nil, err
+		}
+		instances = append(instances, instance)
+	}
+
+	return instances, rows.Err()
+}
+
+// GetAlertInstance returns a single fired alert by ID
+func (e *Engine) GetAlertInstance(id int64) (*AlertInstance, error) {
+	row := e.db.QueryRow(`SELECT `+instanceColumns+` FROM alert_instances WHERE id = ?`, id)
+
+	instance, err := scanInstance(row)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("alert %d not found", id)
+	}
+	return instance, err
+}
+
+// AcknowledgeAlert records that someone is looking at an open alert
+func (e *Engine) AcknowledgeAlert(id int64, user string) error {
+	instance, err := e.GetAlertInstance(id)
+	if err != nil {
+		return err
+	}
+	if instance.Resolved {
+		return fmt.Errorf("alert %d is already resolved", id)
+	}
+
+	_, err = e.db.Exec(`UPDATE alert_instances SET acknowledged_at = ?, acknowledged_by = ? WHERE id = ?`, time.Now(), user, id)
+	return err
+}
+
+// ResolveAlert manually resolves a single alert. If it was the rule's last open alert,
+// Slack threads get the same resolution reply as when the condition clears on its own.
+func (e *Engine) ResolveAlert(id int64) error {
+	instance, err := e.GetAlertInstance(id)
+	if err != nil {
+		return err
+	}
+	if instance.Resolved {
+		return nil
+	}
+
+	var open int
+	if err := e.db.QueryRow(`SELECT COUNT(*) FROM alert_instances WHERE rule_id = ? AND resolved = 0`, instance.RuleID).Scan(&open); err != nil {
+		return err
+	}
+	if open == 1 {
+		e.sendSlackResolutions(instance.RuleID, instance.RuleName)
+	}
+
+	if _, err := e.db.Exec(`UPDATE alert_instances SET resolved = 1, resolved_at = ? WHERE id = ?`, time.Now(), id); err != nil {
+		return err
+	}
+
+	slog.Info("alert resolved manually", "rule", instance.RuleName, "alert_id", id)
+	return nil
+}
+
+// scanInstance reads a row selected with instanceColumns
+func scanInstance(row scanner) (*AlertInstance, error) {
+	instance := &AlertInstance{}
+	var resolvedAt, acknowledgedAt sql.NullTime
+	var acknowledgedBy sql.NullString
+	var value sql.NullFloat64
+
+	err := row.Scan(
+		&instance.ID, &instance.RuleID, &instance.RuleName, &instance.Count,
+		&instance.Threshold, &value, &instance.Query, &instance.FiredAt, &instance.Resolved,
+		&resolvedAt, &acknowledgedAt, &acknowledgedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	instance.Value = value.Float64
+	instance.ResolvedAt = resolvedAt.Time
+	instance.AcknowledgedAt = acknowledgedAt.Time
+	instance.AcknowledgedBy = acknowledgedBy.String
+	return instance, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
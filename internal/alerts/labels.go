@@ -0,0 +1,97 @@
+package alerts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ParseLabels parses a comma-separated list of key=value pairs (e.g. "team=payments,priority=high")
+func ParseLabels(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		parts := strings.SplitN(pair, "=", 2)
+		key := strings.TrimSpace(parts[0])
+		if len(parts) != 2 || key == "" {
+			return nil, fmt.Errorf("invalid label %q (expected key=value)", pair)
+		}
+		labels[key] = strings.TrimSpace(parts[1])
+	}
+	return labels, nil
+}
+
+// FormatLabels renders labels as a sorted, comma-separated list of key=value pairs
+func FormatLabels(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, key := range keys {
+		pairs[i] = key + "=" + labels[key]
+	}
+	return strings.Join(pairs, ",")
+}
+
+// MatchLabels reports whether labels satisfy a selector expression.
+//
+// A selector is a comma-separated list of terms that must all hold:
+//
+//	team=payments      label equals value
+//	priority!=low      label is missing or differs from value
+//	service            label is present
+//	!service           label is absent
+//
+// An empty selector matches everything.
+func MatchLabels(labels map[string]string, selector string) bool {
+	for _, term := range strings.Split(selector, ",") {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+
+		switch {
+		case strings.Contains(term, "!="):
+			parts := strings.SplitN(term, "!=", 2)
+			if value, ok := labels[strings.TrimSpace(parts[0])]; ok && value == strings.TrimSpace(parts[1]) {
+				return false
+			}
+		case strings.Contains(term, "="):
+			parts := strings.SplitN(term, "=", 2)
+			if value, ok := labels[strings.TrimSpace(parts[0])]; !ok || value != strings.TrimSpace(parts[1]) {
+				return false
+			}
+		case strings.HasPrefix(term, "!"):
+			if _, ok := labels[strings.TrimSpace(term[1:])]; ok {
+				return false
+			}
+		default:
+			if _, ok := labels[term]; !ok {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// FilterRules returns the rules whose labels match the selector
+func FilterRules(rules []*AlertRule, selector string) []*AlertRule {
+	if strings.TrimSpace(selector) == "" {
+		return rules
+	}
+
+	filtered := make([]*AlertRule, 0, len(rules))
+	for _, rule := range rules {
+		if MatchLabels(rule.Labels, selector) {
+			filtered = append(filtered, rule)
+		}
+	}
+	return filtered
+}
@@ -0,0 +1,196 @@
+package alerts
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetricCondition makes a rule watch a series in the metrics table instead of
+// counting logs: it fires when the series, aggregated over the rule's window,
+// is above (or below) the threshold
+type MetricCondition struct {
+	Name      string  `json:"name"`             // e.g. api.latency.p95
+	Labels    string  `json:"labels,omitempty"` // Label selector, as for channels, e.g. service=api
+	Aggregate string  `json:"aggregate"`        // avg, min, max, sum, last, or count
+	Below     bool    `json:"below,omitempty"`  // Fire when the value drops below Threshold
+	Threshold float64 `json:"threshold"`
+
+	// SLO watches this SLO's error budget burn rate over the window instead of
+	// a series; Name, Labels, and Aggregate are unused
+	SLO string `json:"slo,omitempty"`
+}
+
+// MetricAggregates are the ways a series can be summarised over a window
+var MetricAggregates = []string{"avg", "min", "max", "sum", "last", "count"}
+
+// Validate checks the condition can be evaluated
+func (m *MetricCondition) Validate() error {
+	if m.SLO != "" {
+		return nil
+	}
+	if strings.TrimSpace(m.Name) == "" {
+		return fmt.Errorf("metric name is required")
+	}
+	for _, aggregate := range MetricAggregates {
+		if m.Aggregate == aggregate {
+			return nil
+		}
+	}
+	return fmt.Errorf("unknown aggregate %q (use %s)", m.Aggregate, strings.Join(MetricAggregates, ", "))
+}
+
+// String describes the condition, e.g. "avg(api.latency.p95{service=api}) > 250".
+// It's stored as the rule's query, so alert history and notifications show it.
+func (m *MetricCondition) String() string {
+	op := ">"
+	if m.Below {
+		op = "<"
+	}
+	if m.SLO != "" {
+		return fmt.Sprintf("burn_rate(%s) %s %s", m.SLO, op, FormatMetricValue(m.Threshold))
+	}
+
+	series := m.Name
+	if m.Labels != "" {
+		series += "{" + m.Labels + "}"
+	}
+	return fmt.Sprintf("%s(%s) %s %s", m.Aggregate, series, op, FormatMetricValue(m.Threshold))
+}
+
+// Firing reports whether an aggregated value trips the condition
+func (m *MetricCondition) Firing(value float64) bool {
+	if m.Below {
+		return value < m.Threshold
+	}
+	return value > m.Threshold
+}
+
+// subject names what the condition measures, for notifications
+func (m *MetricCondition) subject() string {
+	if m.SLO != "" {
+		return "SLO " + m.SLO + " burn rate"
+	}
+	return m.Name
+}
+
+// FormatMetricValue prints a value to at most three decimal places, and
+// whole numbers without a decimal point
+func FormatMetricValue(v float64) string {
+	return strconv.FormatFloat(math.Round(v*1000)/1000, 'f', -1, 64)
+}
+
+// metricValue aggregates a metric rule's series over its window, or measures
+// its SLO's burn rate over it. It reports false when no samples matched (or
+// the SLO saw no events), so there's nothing to compare; count aggregates are
+// 0 then instead.
+func (e *Engine) metricValue(ctx context.Context, rule *AlertRule) (float64, bool, error) {
+	condition := rule.Metric
+	duration, err := time.ParseDuration(rule.Window)
+	if err != nil {
+		duration = 5 * time.Minute
+	}
+
+	if condition.SLO != "" {
+		slo, err := e.GetSLO(condition.SLO)
+		if err != nil {
+			return 0, false, err
+		}
+		return e.sloBurnRate(ctx, slo, duration)
+	}
+
+	ctx, cancel := e.storage.WithTimeout(ctx)
+	defer cancel()
+	// Metric timestamps are stored in UTC
+	rows, err := e.db.QueryContext(ctx, `SELECT labels, value FROM metrics WHERE name = ? AND timestamp >= ? ORDER BY timestamp`,
+		condition.Name, time.Now().Add(-duration).UTC())
+	if err != nil {
+		return 0, false, err
+	}
+	defer rows.Close()
+
+	var (
+		samples  int
+		sum      float64
+		min, max = math.Inf(1), math.Inf(-1)
+		last     float64
+	)
+	for rows.Next() {
+		var labelsJSON string
+		var value float64
+		if err := rows.Scan(&labelsJSON, &value); err != nil {
+			return 0, false, err
+		}
+		if condition.Labels != "" {
+			var labels map[string]string
+			json.Unmarshal([]byte(labelsJSON), &labels)
+			if !MatchLabels(labels, condition.Labels) {
+				continue
+			}
+		}
+
+		samples++
+		sum += value
+		min = math.Min(min, value)
+		max = math.Max(max, value)
+		last = value
+	}
+	if err := rows.Err(); err != nil {
+		return 0, false, err
+	}
+
+	if condition.Aggregate == "count" {
+		return float64(samples), true, nil
+	}
+	if samples == 0 {
+		return 0, false, nil
+	}
+	switch condition.Aggregate {
+	case "min":
+		return min, true, nil
+	case "max":
+		return max, true, nil
+	case "sum":
+		return sum, true, nil
+	case "last":
+		return last, true, nil
+	default:
+		return sum / float64(samples), true, nil
+	}
+}
+
+// alertSummary describes what tripped an alert, for notifications
+func (e *Engine) alertSummary(instance *AlertInstance) string {
+	if metric := e.ruleMetric(instance); metric != nil {
+		return fmt.Sprintf("%s = %s (%s)", metric.subject(), FormatMetricValue(instance.Value), metric)
+	}
+	return fmt.Sprintf("%d events (limit: %d)", instance.Count, instance.Threshold)
+}
+
+// slackMessage is alertSummary in Slack's markup
+func (e *Engine) slackMessage(instance *AlertInstance) string {
+	if metric := e.ruleMetric(instance); metric != nil {
+		return fmt.Sprintf("Alert threshold exceeded: *%s = %s* (%s)", metric.subject(), FormatMetricValue(instance.Value), metric)
+	}
+	return fmt.Sprintf("Alert threshold exceeded: *%d events* detected (limit: %d)", instance.Count, instance.Threshold)
+}
+
+// shellDetail is the lines of a shell notification's message about what tripped it
+func (e *Engine) shellDetail(instance *AlertInstance) string {
+	if metric := e.ruleMetric(instance); metric != nil {
+		return fmt.Sprintf("Value: %s\nThreshold: %s", FormatMetricValue(instance.Value), FormatMetricValue(metric.Threshold))
+	}
+	return fmt.Sprintf("Count: %d\nThreshold: %d", instance.Count, instance.Threshold)
+}
+
+// ruleMetric returns the metric condition of the rule an alert came from, if any
+func (e *Engine) ruleMetric(instance *AlertInstance) *MetricCondition {
+	if rule, ok := e.GetRule(instance.RuleID); ok {
+		return rule.Metric
+	}
+	return nil
+}
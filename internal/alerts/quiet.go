@@ -0,0 +1,152 @@
+package alerts
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Quiet hours actions for alerts that fire outside a channel's active window
+const (
+	QuietSuppress = "suppress" // Drop the notification
+	QuietDefer    = "defer"    // Hold it and send a digest once the window opens
+)
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Schedule is the window during which a channel delivers notifications
+type Schedule struct {
+	start    int // Minutes after midnight
+	end      int
+	allDay   bool
+	days     [7]bool
+	location *time.Location
+}
+
+// ParseSchedule parses a channel's active hours (e.g. "09:00-18:00"), active days
+// (e.g. "mon-fri" or "sat,sun"), and IANA timezone. Empty hours means all day,
+// empty days means every day, and an empty timezone uses the local time. Hours may
+// wrap midnight ("22:00-06:00"); the day check uses the day the alert fires on.
+func ParseSchedule(hours, days, timezone string) (*Schedule, error) {
+	schedule := &Schedule{allDay: true, location: time.Local}
+
+	if strings.TrimSpace(hours) != "" {
+		parts := strings.SplitN(hours, "-", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid active hours %q (expected HH:MM-HH:MM)", hours)
+		}
+
+		start, err := parseClock(parts[0])
+		if err != nil {
+			return nil, err
+		}
+		end, err := parseClock(parts[1])
+		if err != nil {
+			return nil, err
+		}
+		if start == end {
+			return nil, fmt.Errorf("invalid active hours %q (start and end are the same)", hours)
+		}
+
+		schedule.start, schedule.end, schedule.allDay = start, end, false
+	}
+
+	if strings.TrimSpace(days) == "" {
+		for i := range schedule.days {
+			schedule.days[i] = true
+		}
+	} else {
+		for _, term := range strings.Split(strings.ToLower(days), ",") {
+			term = strings.TrimSpace(term)
+			if term == "" {
+				continue
+			}
+
+			bounds := strings.SplitN(term, "-", 2)
+			first, ok := weekdayNames[strings.TrimSpace(bounds[0])]
+			if !ok {
+				return nil, fmt.Errorf("invalid day %q (use sun, mon, tue, wed, thu, fri, sat)", bounds[0])
+			}
+			last := first
+			if len(bounds) == 2 {
+				if last, ok = weekdayNames[strings.TrimSpace(bounds[1])]; !ok {
+					return nil, fmt.Errorf("invalid day %q (use sun, mon, tue, wed, thu, fri, sat)", bounds[1])
+				}
+			}
+
+			// Ranges may wrap the week, e.g. fri-mon
+			for day := first; ; day = (day + 1) % 7 {
+				schedule.days[day] = true
+				if day == last {
+					break
+				}
+			}
+		}
+	}
+
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, fmt.Errorf("invalid timezone %q: %w", timezone, err)
+		}
+		schedule.location = location
+	}
+
+	return schedule, nil
+}
+
+// parseClock converts "HH:MM" to minutes after midnight
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q (expected HH:MM)", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Active reports whether t falls inside the schedule
+func (s *Schedule) Active(t time.Time) bool {
+	t = t.In(s.location)
+	if !s.days[t.Weekday()] {
+		return false
+	}
+	if s.allDay {
+		return true
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	if s.start < s.end {
+		return minute >= s.start && minute < s.end
+	}
+	return minute >= s.start || minute < s.end
+}
+
+// channelActive reports whether a channel should deliver notifications at t.
+// Channels with an invalid schedule always deliver rather than silently dropping alerts.
+func channelActive(channel *NotificationChannel, t time.Time) bool {
+	if channel.Config["active_hours"] == "" && channel.Config["active_days"] == "" {
+		return true
+	}
+
+	schedule, err := ParseSchedule(channel.Config["active_hours"], channel.Config["active_days"], channel.Config["timezone"])
+	if err != nil {
+		return true
+	}
+	return schedule.Active(t)
+}
+
+// quietAction returns what a channel does with alerts outside its active window
+func quietAction(channel *NotificationChannel) string {
+	if channel.Config["quiet_action"] == QuietDefer {
+		return QuietDefer
+	}
+	return QuietSuppress
+}
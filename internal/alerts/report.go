@@ -0,0 +1,426 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/notifications"
+	"github.com/kylereynolds/peep/internal/schedule"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Report is a digest sent to a notification channel on a schedule: log
+// volume and errors against the period before, the top errors, the alerts
+// that fired, and the panels of a saved dashboard
+type Report struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"` // Slug
+	Description string    `json:"description,omitempty"`
+	Schedule    string    `json:"schedule"`            // Cron expression or macro, e.g. @daily or "0 9 * * mon"
+	Channel     string    `json:"channel"`             // Name of the notification channel it's sent to
+	Dashboard   string    `json:"dashboard,omitempty"` // Dashboard whose stat, table, and SLO panels are included
+	LastSent    time.Time `json:"last_sent,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// reportTopErrors is how many error messages and alert rules a report lists
+const reportTopErrors = 5
+
+// reportMaxRows caps each dashboard panel's rows in a report
+const reportMaxRows = 10
+
+// reportQueryTimeout bounds each of a report's dashboard panel queries
+const reportQueryTimeout = 30 * time.Second
+
+// Validate checks the report can be scheduled. Whether its channel and
+// dashboard exist is checked by AddReport.
+func (r *Report) Validate() error {
+	if !slugPattern.MatchString(r.Name) {
+		return fmt.Errorf("name must be lowercase letters, numbers, dashes, or underscores")
+	}
+	if _, err := schedule.ParseCron(r.Schedule); err != nil {
+		return err
+	}
+	if strings.TrimSpace(r.Channel) == "" {
+		return fmt.Errorf("a notification channel is required")
+	}
+	return nil
+}
+
+// Period is how far back a report looks: the time between two of its
+// scheduled runs, so a daily report covers a day and a weekly one a week
+func (r *Report) Period(now time.Time) (time.Duration, error) {
+	cron, err := schedule.ParseCron(r.Schedule)
+	if err != nil {
+		return 0, err
+	}
+	next := cron.Next(now)
+	after := cron.Next(next)
+	if next.IsZero() || after.IsZero() {
+		return 0, fmt.Errorf("schedule %q never repeats", r.Schedule)
+	}
+	return after.Sub(next), nil
+}
+
+// NextRun returns when the report is next due: its schedule's first time
+// after it was last sent, or after it was added
+func (r *Report) NextRun() time.Time {
+	cron, err := schedule.ParseCron(r.Schedule)
+	if err != nil {
+		return time.Time{}
+	}
+	from := r.CreatedAt
+	if r.LastSent.After(from) {
+		from = r.LastSent
+	}
+	return cron.Next(from.Local())
+}
+
+func (e *Engine) createReportTable() error {
+	_, err := e.db.Exec(`
+	CREATE TABLE IF NOT EXISTS reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		schedule TEXT NOT NULL,
+		channel TEXT NOT NULL,
+		dashboard TEXT NOT NULL DEFAULT '',
+		last_sent DATETIME,
+		created_at DATETIME NOT NULL
+	)`)
+	return err
+}
+
+// AddReport validates and saves a report
+func (e *Engine) AddReport(report *Report) error {
+	if err := report.Validate(); err != nil {
+		return err
+	}
+	if e.channelByName(report.Channel) == nil {
+		return fmt.Errorf("no notification channel named %q", report.Channel)
+	}
+	if report.Dashboard != "" {
+		_, err := e.storage.GetDashboard(report.Dashboard)
+		if err == sql.ErrNoRows {
+			return fmt.Errorf("no dashboard named %q", report.Dashboard)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	report.CreatedAt = time.Now().UTC()
+	result, err := e.db.Exec(`INSERT INTO reports (name, description, schedule, channel, dashboard, created_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		report.Name, report.Description, report.Schedule, report.Channel, report.Dashboard, report.CreatedAt)
+	if err != nil {
+		return err
+	}
+	report.ID, err = result.LastInsertId()
+	return err
+}
+
+const reportColumns = `id, name, description, schedule, channel, dashboard, last_sent, created_at`
+
+// GetReports returns every report, in name order. Like SLOs, they're read
+// from the database each time, so the running engine picks up ones added by
+// peep report add.
+func (e *Engine) GetReports() ([]*Report, error) {
+	rows, err := e.db.Query(`SELECT ` + reportColumns + ` FROM reports ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []*Report
+	for rows.Next() {
+		report, err := scanReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, report)
+	}
+	return reports, rows.Err()
+}
+
+// GetReport returns the report with this name
+func (e *Engine) GetReport(name string) (*Report, error) {
+	report, err := scanReport(e.db.QueryRow(`SELECT `+reportColumns+` FROM reports WHERE name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no report named %q", name)
+	}
+	return report, err
+}
+
+func scanReport(row interface{ Scan(...interface{}) error }) (*Report, error) {
+	report := &Report{}
+	var lastSent sql.NullTime
+	err := row.Scan(&report.ID, &report.Name, &report.Description, &report.Schedule, &report.Channel, &report.Dashboard, &lastSent, &report.CreatedAt)
+	report.LastSent = lastSent.Time
+	return report, err
+}
+
+// RemoveReport deletes a report
+func (e *Engine) RemoveReport(name string) error {
+	result, err := e.db.Exec(`DELETE FROM reports WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no report named %q", name)
+	}
+	return nil
+}
+
+// channelByName returns the notification channel with this name, or nil
+func (e *Engine) channelByName(name string) *NotificationChannel {
+	for _, channel := range e.GetChannels() {
+		if channel.Name == name {
+			return channel
+		}
+	}
+	return nil
+}
+
+// BuildReport gathers a report's figures for the period ending now
+func (e *Engine) BuildReport(report *Report) (notifications.Report, error) {
+	end := time.Now()
+	period, err := report.Period(end)
+	if err != nil {
+		return notifications.Report{}, err
+	}
+	start := end.Add(-period)
+
+	built := notifications.Report{
+		Name:        report.Name,
+		Description: report.Description,
+		Start:       start,
+		End:         end,
+	}
+	if channel := e.channelByName(report.Channel); channel != nil && channel.Config["peep_url"] != "" {
+		built.DashboardURL = strings.TrimRight(channel.Config["peep_url"], "/")
+		if report.Dashboard != "" {
+			built.DashboardURL += "/dashboards/" + report.Dashboard
+		}
+	}
+
+	ctx, cancel := e.storage.WithTimeout(context.Background())
+	defer cancel()
+
+	volume := `SELECT COUNT(*), COALESCE(SUM(level = 'error'), 0) FROM logs WHERE timestamp >= ? AND timestamp < ?`
+	if err := e.db.QueryRowContext(ctx, volume, start.Local(), end.Local()).Scan(&built.Logs, &built.Errors); err != nil {
+		return built, fmt.Errorf("failed to count logs: %w", err)
+	}
+	if err := e.db.QueryRowContext(ctx, volume, start.Add(-period).Local(), start.Local()).Scan(&built.PreviousLogs, &built.PreviousErrors); err != nil {
+		return built, fmt.Errorf("failed to count logs: %w", err)
+	}
+
+	rows, err := e.db.QueryContext(ctx, `SELECT COALESCE(service, ''), message, COUNT(*) FROM logs
+		WHERE level = 'error' AND timestamp >= ? AND timestamp < ?
+		GROUP BY 1, 2 ORDER BY 3 DESC LIMIT ?`, start.Local(), end.Local(), reportTopErrors)
+	if err != nil {
+		return built, fmt.Errorf("failed to find top errors: %w", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var service, message string
+		var count int
+		if err := rows.Scan(&service, &message, &count); err != nil {
+			return built, err
+		}
+		name := strings.Join(strings.Fields(message), " ")
+		if service != "" {
+			name = service + ": " + name
+		}
+		built.TopErrors = append(built.TopErrors, notifications.ReportCount{Name: name, Count: count})
+	}
+	if err := rows.Err(); err != nil {
+		return built, err
+	}
+
+	stats, err := e.GetRuleStats(period)
+	if err != nil {
+		return built, fmt.Errorf("failed to count alerts: %w", err)
+	}
+	for _, rule := range stats {
+		built.AlertsFired += rule.Fires
+		if rule.Fires > 0 && len(built.NoisiestAlerts) < reportTopErrors {
+			built.NoisiestAlerts = append(built.NoisiestAlerts, notifications.ReportCount{Name: rule.RuleName, Count: rule.Fires})
+		}
+	}
+
+	if report.Dashboard != "" {
+		dashboard, err := e.storage.GetDashboard(report.Dashboard)
+		if err != nil {
+			return built, fmt.Errorf("dashboard %q: %w", report.Dashboard, err)
+		}
+		for _, panel := range dashboard.Panels {
+			if panel.Type == storage.PanelTimeSeries {
+				continue // Charts don't survive email and chat
+			}
+			built.Sections = append(built.Sections, e.reportSection(panel))
+		}
+	}
+	return built, nil
+}
+
+// reportSection runs a dashboard panel for a report
+func (e *Engine) reportSection(panel storage.DashboardPanel) notifications.ReportSection {
+	section := notifications.ReportSection{Title: panel.Title}
+
+	if panel.Type == storage.PanelSLO {
+		slo, err := e.GetSLO(panel.Query)
+		if err != nil {
+			section.Error = err.Error()
+			return section
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), reportQueryTimeout)
+		defer cancel()
+		status := e.MeasureSLO(ctx, slo)
+		if status.Err != nil {
+			section.Error = status.Err.Error()
+			return section
+		}
+		section.Columns = []string{"SLI", "Target", "Budget left", "Burn (1h)"}
+		section.Rows = [][]string{{
+			FormatMetricValue(status.SLI) + "%", FormatMetricValue(slo.Target) + "%",
+			FormatMetricValue(status.BudgetRemaining) + "%", FormatMetricValue(status.BurnRate),
+		}}
+		return section
+	}
+
+	query, err := storage.ReadStatement(panel.Query)
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), reportQueryTimeout)
+	defer cancel()
+	rows, release, err := e.storage.QueryReadOnly(ctx, query)
+	if err != nil {
+		section.Error = err.Error()
+		return section
+	}
+	defer release()
+	defer rows.Close()
+
+	if section.Columns, err = rows.Columns(); err != nil {
+		section.Error = err.Error()
+		return section
+	}
+	limit := reportMaxRows
+	if panel.Type == storage.PanelStat {
+		limit = 1
+	}
+	values := make([]interface{}, len(section.Columns))
+	pointers := make([]interface{}, len(values))
+	for i := range values {
+		pointers[i] = &values[i]
+	}
+	for len(section.Rows) < limit && rows.Next() {
+		if err := rows.Scan(pointers...); err != nil {
+			section.Error = err.Error()
+			return section
+		}
+		row := make([]string, len(values))
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+			case []byte:
+				row[i] = string(v)
+			case float64:
+				row[i] = FormatMetricValue(v)
+			case time.Time:
+				row[i] = v.Local().Format("2006-01-02 15:04")
+			default:
+				row[i] = fmt.Sprint(v)
+			}
+		}
+		if panel.Type == storage.PanelStat {
+			row = row[:1]
+		}
+		section.Rows = append(section.Rows, row)
+	}
+	if err := rows.Err(); err != nil {
+		section.Error = err.Error()
+		section.Rows = nil
+	}
+	if panel.Type == storage.PanelStat && len(section.Columns) > 0 {
+		section.Columns = section.Columns[:1]
+	}
+	return section
+}
+
+// SendReport builds a report and sends it to its channel, recording when it
+// went out. Quiet hours don't apply; the schedule is the report's own.
+func (e *Engine) SendReport(report *Report) error {
+	channel := e.channelByName(report.Channel)
+	if channel == nil {
+		return fmt.Errorf("no notification channel named %q", report.Channel)
+	}
+	built, err := e.BuildReport(report)
+	if err != nil {
+		return err
+	}
+
+	switch channel.Type {
+	case "email":
+		err = e.emailNotifier(channel).SendReport(built)
+	case "slack":
+		err = e.slackNotifier(channel).SendReport(built)
+	case "desktop":
+		err = notifications.SendDesktopNotification("📊 Peep report: "+built.Name,
+			fmt.Sprintf("%d logs (%s), %d errors (%s), %d alerts", built.Logs, built.LogsTrend(), built.Errors, built.ErrorsTrend(), built.AlertsFired))
+	case "shell":
+		var shell *notifications.ShellNotification
+		if shell, err = e.shellNotifier(channel); err == nil {
+			err = shell.Execute("Peep report: "+built.Name, built.Text(), "info", built.Errors, 0)
+		}
+	default:
+		err = fmt.Errorf("channel %q has unknown type %q", channel.Name, channel.Type)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to send report %s to %s: %w", report.Name, channel.Name, err)
+	}
+
+	report.LastSent = time.Now().UTC()
+	if _, err := e.db.Exec(`UPDATE reports SET last_sent = ? WHERE id = ?`, report.LastSent, report.ID); err != nil {
+		return err
+	}
+	slog.Info("report sent", "report", report.Name, "channel", channel.Name, "logs", built.Logs, "errors", built.Errors)
+	return nil
+}
+
+// sendDueReports sends every report whose scheduled time has passed. Each is
+// claimed in the database first, so two processes sharing it, such as peep
+// daemon and peep alerts start, don't both send it; one that fails to send
+// waits for its next run.
+func (e *Engine) sendDueReports(now time.Time) {
+	reports, err := e.GetReports()
+	if err != nil {
+		slog.Error("failed to load reports", "error", err)
+		return
+	}
+	for _, report := range reports {
+		due := report.NextRun()
+		if due.IsZero() || now.Before(due) {
+			continue
+		}
+
+		claimed, err := e.db.Exec(`UPDATE reports SET last_sent = ? WHERE id = ? AND (last_sent IS NULL OR last_sent < ?)`,
+			now.UTC(), report.ID, due.UTC())
+		if err != nil {
+			slog.Error("failed to claim report", "report", report.Name, "error", err)
+			continue
+		}
+		if n, _ := claimed.RowsAffected(); n == 0 {
+			continue // Another process sent it
+		}
+		if err := e.SendReport(report); err != nil {
+			slog.Error("report failed", "report", report.Name, "error", err)
+		}
+	}
+}
@@ -0,0 +1,239 @@
+package alerts
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// SLO is a service level objective: the share of events that should be good,
+// measured by two counting queries over a rolling window. The error budget is
+// the share allowed to be bad, 0.1% for a 99.9% target.
+type SLO struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"` // Slug, referenced by metric rules and dashboard panels
+	Description string    `json:"description,omitempty"`
+	Good        string    `json:"good"`   // SQL counting good events, e.g. SELECT COUNT(*) FROM logs WHERE service = 'api' AND level != 'error'
+	Total       string    `json:"total"`  // SQL counting every event, e.g. SELECT COUNT(*) FROM logs WHERE service = 'api'
+	Target      float64   `json:"target"` // Percent of events that should be good, e.g. 99.9
+	Window      string    `json:"window"` // Rolling window the target applies to, e.g. 30d
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// SLOStatus is an SLO measured now
+type SLOStatus struct {
+	SLO   *SLO    `json:"slo"`
+	Good  float64 `json:"good"`
+	Total float64 `json:"total"`
+
+	// SLI is the percent of events in the window that were good; 100 when
+	// there were none
+	SLI float64 `json:"sli"`
+
+	// BudgetRemaining is the percent of the window's error budget left. It
+	// goes below zero once the SLO is missed.
+	BudgetRemaining float64 `json:"budget_remaining"`
+
+	// BurnRate is how fast the budget is going over the last SLOBurnWindow:
+	// 1 spends it exactly over the window, 10 in a tenth of it
+	BurnRate float64 `json:"burn_rate"`
+
+	Err error `json:"-"` // A query failed
+}
+
+// SLOBurnWindow is the recent window SLOStatus reports the burn rate over
+const SLOBurnWindow = time.Hour
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks the SLO can be measured
+func (s *SLO) Validate() error {
+	if !slugPattern.MatchString(s.Name) {
+		return fmt.Errorf("name must be lowercase letters, numbers, dashes, or underscores")
+	}
+	for _, query := range []struct{ name, sql string }{{"good", s.Good}, {"total", s.Total}} {
+		if strings.TrimSpace(query.sql) == "" {
+			return fmt.Errorf("%s query is required", query.name)
+		}
+		if _, err := storage.ReadStatement(query.sql); err != nil {
+			return fmt.Errorf("%s query: %w", query.name, err)
+		}
+	}
+	if s.Target <= 0 || s.Target >= 100 {
+		return fmt.Errorf("target must be a percentage between 0 and 100, e.g. 99.9")
+	}
+	if _, err := ParseSLOWindow(s.Window); err != nil {
+		return err
+	}
+	return nil
+}
+
+// ParseSLOWindow reads a window such as 30d, 7d, or 12h. Days are allowed, as
+// SLO windows are usually weeks or a month.
+func ParseSLOWindow(window string) (time.Duration, error) {
+	var d time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		d = time.Duration(n) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(window)
+	}
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid window %q (use e.g. 30d, 7d, or 12h)", window)
+	}
+	return d, nil
+}
+
+func (e *Engine) createSLOTable() error {
+	_, err := e.db.Exec(`
+	CREATE TABLE IF NOT EXISTS slos (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		description TEXT NOT NULL DEFAULT '',
+		good_query TEXT NOT NULL,
+		total_query TEXT NOT NULL,
+		target REAL NOT NULL,
+		window TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	)`)
+	return err
+}
+
+// AddSLO validates and saves an SLO
+func (e *Engine) AddSLO(slo *SLO) error {
+	if err := slo.Validate(); err != nil {
+		return err
+	}
+	result, err := e.db.Exec(`INSERT INTO slos (name, description, good_query, total_query, target, window) VALUES (?, ?, ?, ?, ?, ?)`,
+		slo.Name, slo.Description, slo.Good, slo.Total, slo.Target, slo.Window)
+	if err != nil {
+		return err
+	}
+	slo.ID, err = result.LastInsertId()
+	slo.CreatedAt = time.Now()
+	return err
+}
+
+// GetSLOs returns every SLO, in name order. SLOs are read from the database
+// each time, so ones added by another process show up without a restart.
+func (e *Engine) GetSLOs() ([]*SLO, error) {
+	rows, err := e.db.Query(`SELECT id, name, description, good_query, total_query, target, window, created_at FROM slos ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var slos []*SLO
+	for rows.Next() {
+		slo := &SLO{}
+		if err := rows.Scan(&slo.ID, &slo.Name, &slo.Description, &slo.Good, &slo.Total, &slo.Target, &slo.Window, &slo.CreatedAt); err != nil {
+			return nil, err
+		}
+		slos = append(slos, slo)
+	}
+	return slos, rows.Err()
+}
+
+// GetSLO returns the SLO with this name
+func (e *Engine) GetSLO(name string) (*SLO, error) {
+	slo := &SLO{}
+	err := e.db.QueryRow(`SELECT id, name, description, good_query, total_query, target, window, created_at FROM slos WHERE name = ?`, name).
+		Scan(&slo.ID, &slo.Name, &slo.Description, &slo.Good, &slo.Total, &slo.Target, &slo.Window, &slo.CreatedAt)
+	if err == sql.ErrNoRows {
+		return nil, fmt.Errorf("no SLO named %q", name)
+	}
+	return slo, err
+}
+
+// RemoveSLO deletes an SLO. One that metric rules alert on can't be removed
+// until they are, or they'd fail every check.
+func (e *Engine) RemoveSLO(name string) error {
+	for _, rule := range e.GetRules() {
+		if rule.Metric != nil && rule.Metric.SLO == name {
+			return fmt.Errorf("alert rule %q alerts on SLO %q; remove it first", rule.Name, name)
+		}
+	}
+	result, err := e.db.Exec(`DELETE FROM slos WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("no SLO named %q", name)
+	}
+	return nil
+}
+
+// MeasureSLO computes an SLO's SLI and remaining budget over its window, and
+// its burn rate over the last SLOBurnWindow
+func (e *Engine) MeasureSLO(ctx context.Context, slo *SLO) SLOStatus {
+	status := SLOStatus{SLO: slo, SLI: 100}
+	window, err := ParseSLOWindow(slo.Window)
+	if err != nil {
+		status.Err = err
+		return status
+	}
+
+	status.Good, status.Total, status.Err = e.sloCounts(ctx, slo, window)
+	if status.Err != nil {
+		return status
+	}
+	if status.Total > 0 {
+		status.SLI = status.Good / status.Total * 100
+	}
+	status.BudgetRemaining = (1 - burnRate(status.Good, status.Total, slo.Target)) * 100
+
+	status.BurnRate, _, status.Err = e.sloBurnRate(ctx, slo, SLOBurnWindow)
+	return status
+}
+
+// sloBurnRate is the rate an SLO's budget burned over the last window. It
+// reports false when there were no events, so nothing burned or didn't.
+func (e *Engine) sloBurnRate(ctx context.Context, slo *SLO, window time.Duration) (float64, bool, error) {
+	good, total, err := e.sloCounts(ctx, slo, window)
+	if err != nil || total == 0 {
+		return 0, false, err
+	}
+	return burnRate(good, total, slo.Target), true, nil
+}
+
+// burnRate is the share of events that were bad over the share the target
+// allows to be
+func burnRate(good, total, target float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	bad := math.Max(total-good, 0) / total
+	return bad / (1 - target/100)
+}
+
+// sloCounts runs an SLO's good and total queries over the last window
+func (e *Engine) sloCounts(ctx context.Context, slo *SLO, window time.Duration) (good, total float64, err error) {
+	ctx, cancel := e.storage.WithTimeout(ctx)
+	defer cancel()
+
+	since := time.Now().Add(-window)
+	for _, count := range []struct {
+		query string
+		into  *float64
+	}{{slo.Good, &good}, {slo.Total, &total}} {
+		query, args, err := timeBoundQuery(count.query, since)
+		if err != nil {
+			return 0, 0, err
+		}
+		var n sql.NullFloat64 // SUM over no rows is NULL
+		if err := e.scanReadOnly(ctx, query, args, &n); err != nil {
+			return 0, 0, fmt.Errorf("SLO %s: %w", slo.Name, err)
+		}
+		*count.into = n.Float64
+	}
+	return good, total, nil
+}
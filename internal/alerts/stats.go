@@ -0,0 +1,131 @@
+package alerts
+
+import (
+	"sort"
+	"time"
+)
+
+// RuleStats summarizes how noisy an alert rule has been
+type RuleStats struct {
+	RuleID               int64         `json:"rule_id"`
+	RuleName             string        `json:"rule_name"`
+	Fires                int           `json:"fires"`
+	NotificationsSent    int           `json:"notifications_sent"`
+	NotificationFailures int           `json:"notification_failures"`
+	FirstFired           time.Time     `json:"first_fired,omitempty"`
+	LastFired            time.Time     `json:"last_fired,omitempty"`
+	MeanTimeBetweenFires time.Duration `json:"mean_time_between_fires"` // Zero with fewer than two fires
+}
+
+// FailureRate returns the fraction of notifications that failed to send
+func (s *RuleStats) FailureRate() float64 {
+	total := s.NotificationsSent + s.NotificationFailures
+	if total == 0 {
+		return 0
+	}
+	return float64(s.NotificationFailures) / float64(total)
+}
+
+// GetRuleStats returns per-rule fire and notification counts, noisiest rules first.
+// Only alerts fired within the given period are counted; a zero period covers all history.
+// Rules that haven't fired in the period are included with zero counts.
+func (e *Engine) GetRuleStats(period time.Duration) ([]*RuleStats, error) {
+	since := time.Time{}
+	if period > 0 {
+		since = time.Now().Add(-period)
+	}
+
+	stats := make(map[int64]*RuleStats)
+	for _, rule := range e.GetRules() {
+		stats[rule.ID] = &RuleStats{RuleID: rule.ID, RuleName: rule.Name}
+	}
+
+	// Fires, oldest first so the first and last fire times fall out of the scan
+	rows, err := e.db.Query(`
+	SELECT rule_id, rule_name, fired_at
+	FROM alert_instances
+	WHERE fired_at >= ?
+	ORDER BY fired_at ASC
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ruleID int64
+		var ruleName string
+		var firedAt time.Time
+		if err := rows.Scan(&ruleID, &ruleName, &firedAt); err != nil {
+			return nil, err
+		}
+
+		// Deleted rules still show up under their last known name
+		s, ok := stats[ruleID]
+		if !ok {
+			s = &RuleStats{RuleID: ruleID, RuleName: ruleName}
+			stats[ruleID] = s
+		}
+
+		if s.Fires == 0 {
+			s.FirstFired = firedAt
+		}
+		s.LastFired = firedAt
+		s.Fires++
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Notification outcomes for those fires
+	rows, err = e.db.Query(`
+	SELECT i.rule_id, n.success, COUNT(*)
+	FROM alert_notifications n
+	JOIN alert_instances i ON i.id = n.alert_id
+	WHERE i.fired_at >= ?
+	GROUP BY i.rule_id, n.success
+	`, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var ruleID int64
+		var success bool
+		var count int
+		if err := rows.Scan(&ruleID, &success, &count); err != nil {
+			return nil, err
+		}
+
+		s, ok := stats[ruleID]
+		if !ok {
+			continue
+		}
+		if success {
+			s.NotificationsSent += count
+		} else {
+			s.NotificationFailures += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	result := make([]*RuleStats, 0, len(stats))
+	for _, s := range stats {
+		if s.Fires > 1 {
+			s.MeanTimeBetweenFires = s.LastFired.Sub(s.FirstFired) / time.Duration(s.Fires-1)
+		}
+		result = append(result, s)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Fires != result[j].Fires {
+			return result[i].Fires > result[j].Fires
+		}
+		return result[i].RuleName < result[j].RuleName
+	})
+
+	return result, nil
+}
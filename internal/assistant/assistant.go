@@ -0,0 +1,195 @@
+// Package assistant turns questions about the logs into SQL with a language
+// model behind an OpenAI-compatible chat completions endpoint, such as
+// OpenAI's API or a local Ollama, llama.cpp, or vLLM server.
+package assistant
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Config points the assistant at a model
+type Config struct {
+	Endpoint string        // Base URL of the API, e.g. http://localhost:11434/v1
+	Model    string        // e.g. llama3.1 or gpt-4o-mini
+	APIKey   string        // Sent as a bearer token; local servers usually need none
+	Timeout  time.Duration // For each request; default 60s
+}
+
+// Client asks a model for SQL
+type Client struct {
+	config Config
+	http   *http.Client
+}
+
+// NewClient returns a client for the configured endpoint
+func NewClient(config Config) (*Client, error) {
+	if config.Endpoint == "" || config.Model == "" {
+		return nil, fmt.Errorf("no model configured")
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 60 * time.Second
+	}
+	config.Endpoint = strings.TrimRight(config.Endpoint, "/")
+	return &Client{config: config, http: &http.Client{Timeout: config.Timeout}}, nil
+}
+
+// Attempt is SQL the model wrote before and why it failed, so it can fix it
+type Attempt struct {
+	SQL   string
+	Error string
+}
+
+// schemaTables are the tables described to the model, most useful first
+var schemaTables = []string{"logs", "metrics", "spans", "alert_instances", "log_patterns", "pattern_anomalies"}
+
+// Schema describes the tables questions can be answered from, as their
+// CREATE statements
+func Schema(db *sql.DB) (string, error) {
+	var statements []string
+	for _, table := range schemaTables {
+		var statement string
+		err := db.QueryRow(`SELECT sql FROM sqlite_master WHERE type = 'table' AND name = ?`, table).Scan(&statement)
+		if err == sql.ErrNoRows {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		statements = append(statements, statement+";")
+	}
+	return strings.Join(statements, "\n\n"), nil
+}
+
+// systemPrompt explains the database to the model. The notes cover what
+// the CREATE statements don't say and models otherwise get wrong.
+const systemPrompt = `You translate questions about application logs into one SQLite SELECT query.
+
+Schema:
+%s
+
+Notes:
+- logs.timestamp is stored as text like '2024-05-01 14:03:07.123456-07:00' in the server's local time; compare it with datetime('now', 'localtime', ...) or with literal times in that format. It is now %s.
+- logs.level is lowercase: debug, info, warn, error, fatal.
+- logs.context is JSON; read fields with json_extract(context, '$.field').
+- For time buckets use strftime, e.g. strftime('%%Y-%%m-%%d %%H:00', timestamp) for hours.
+- Add a LIMIT (at most 1000) unless the query aggregates to a few rows.
+
+Reply with only the SQL, no explanation and no Markdown.`
+
+// ToSQL asks the model for a query that answers the question. With a
+// previous attempt, the model is shown its SQL and the error to fix it.
+func (c *Client) ToSQL(ctx context.Context, question, schema string, previous *Attempt) (string, error) {
+	messages := []chatMessage{
+		{Role: "system", Content: fmt.Sprintf(systemPrompt, schema, time.Now().Format("2006-01-02 15:04:05-07:00 (Monday)"))},
+		{Role: "user", Content: question},
+	}
+	if previous != nil {
+		messages = append(messages,
+			chatMessage{Role: "assistant", Content: previous.SQL},
+			chatMessage{Role: "user", Content: "That query failed with: " + previous.Error + "\nReply with a corrected query."})
+	}
+
+	reply, err := c.complete(ctx, messages)
+	if err != nil {
+		return "", err
+	}
+	query, err := storage.ReadStatement(ExtractSQL(reply))
+	if err != nil {
+		return "", fmt.Errorf("the model didn't reply with a usable query (%w): %s", err, strings.TrimSpace(reply))
+	}
+	return query, nil
+}
+
+type chatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type chatRequest struct {
+	Model       string        `json:"model"`
+	Messages    []chatMessage `json:"messages"`
+	Temperature float64       `json:"temperature"`
+}
+
+type chatResponse struct {
+	Choices []struct {
+		Message chatMessage `json:"message"`
+	} `json:"choices"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// complete sends a chat completion request and returns the reply
+func (c *Client) complete(ctx context.Context, messages []chatMessage) (string, error) {
+	body, err := json.Marshal(chatRequest{Model: c.config.Model, Messages: messages})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.config.Endpoint+"/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if c.config.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+c.config.APIKey)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach %s: %w", c.config.Endpoint, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return "", err
+	}
+	var parsed chatResponse
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		return "", fmt.Errorf("unexpected response from %s (HTTP %d): %s", c.config.Endpoint, resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if parsed.Error != nil {
+		return "", fmt.Errorf("%s: %s", c.config.Endpoint, parsed.Error.Message)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s returned HTTP %d", c.config.Endpoint, resp.StatusCode)
+	}
+	if len(parsed.Choices) == 0 {
+		return "", fmt.Errorf("%s returned no reply", c.config.Endpoint)
+	}
+	return parsed.Choices[0].Message.Content, nil
+}
+
+// sqlFence matches a Markdown code block, which models add despite being asked not to
+var sqlFence = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*(.*?)```")
+
+// ExtractSQL pulls the query out of a model's reply: the first code block
+// if there is one, otherwise the reply from its first SELECT or WITH
+func ExtractSQL(reply string) string {
+	if match := sqlFence.FindStringSubmatch(reply); match != nil {
+		return strings.TrimSpace(match[1])
+	}
+	upper := strings.ToUpper(reply)
+	start := -1
+	for _, keyword := range []string{"SELECT", "WITH"} {
+		if i := strings.Index(upper, keyword); i >= 0 && (start < 0 || i < start) {
+			start = i
+		}
+	}
+	if start > 0 {
+		reply = reply[start:]
+	}
+	return strings.TrimSpace(reply)
+}
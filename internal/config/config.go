@@ -0,0 +1,408 @@
+// Package config loads Peep's global settings from a YAML file, with PEEP_*
+// environment variables overriding the file and command-line flags overriding both.
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/export"
+	"github.com/kylereynolds/peep/internal/ingestion"
+	"github.com/kylereynolds/peep/internal/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the global configuration. Everything is optional:
+//
+//	db_path: /var/lib/peep/logs.db
+//	query_timeout: 1m
+//	web:
+//	  port: 9090
+//	  bind: 127.0.0.1
+//	retention:
+//	  max_age_days: 14
+//	  max_logs: 500000
+//	parser:
+//	  patterns:
+//	    - '^(?P<timestamp>\S+) (?P<level>\w+) (?P<service>\S+): (?P<message>.*)$'
+//	filters:
+//	  exclude_levels: [debug]
+//	  exclude_patterns: ['health.*check']
+//	ingest:
+//	  syslog: ':5514'
+//	  http: ':5171'
+//	  http_require_token: true
+//	alerts:
+//	  check_interval: 1m
+//	  dormant_days: 7
+//	ask:
+//	  endpoint: http://localhost:11434/v1
+//	  model: llama3.1
+//	derive:
+//	  - metric: http.latency_ms
+//	    pattern: 'took (?P<value>\d+)ms'
+//	    labels: [service]
+//	hooks:
+//	  - name: uptime
+//	    secret: s3cret
+//	    message: '{$.monitor.name} is {$.status}'
+//	    level: $.status
+//	    levels: {down: error, up: info}
+//	write_buffer:
+//	  enabled: true
+//	  durability: buffered
+//	  flush_interval: 200ms
+//	export:
+//	  - name: central-loki
+//	    type: loki
+//	    url: http://loki:3100
+//	    levels: [warn, error]
+type Config struct {
+	DBPath    string          `yaml:"db_path"`
+	Web       WebConfig       `yaml:"web"`
+	Retention RetentionConfig `yaml:"retention"`
+	Parser    ParserConfig    `yaml:"parser"`
+	Filters   FilterConfig    `yaml:"filters"`
+	Ingest    IngestConfig    `yaml:"ingest"`
+	Alerts    AlertsConfig    `yaml:"alerts"`
+	Ask       AskConfig       `yaml:"ask"`
+
+	// QueryTimeout is how long peep web, serve, and daemon let a log query
+	// or alert rule run before cancelling it; 0 uses the default (30s)
+	QueryTimeout time.Duration `yaml:"query_timeout"`
+
+	// Derive turns numbers in ingested logs into metric samples
+	Derive []ingestion.DeriveRule `yaml:"derive"`
+
+	// Hooks turn JSON POSTed to the web server's /hooks/NAME into logs
+	Hooks []ingestion.Webhook `yaml:"hooks"`
+
+	// WriteBuffer groups inserts from every source into batched commits
+	WriteBuffer WriteBufferConfig `yaml:"write_buffer"`
+
+	// Export tees stored logs to other systems, from peep serve and peep daemon
+	Export []export.Destination `yaml:"export"`
+}
+
+// WebConfig sets where peep web listens
+type WebConfig struct {
+	Port int    `yaml:"port"`
+	Bind string `yaml:"bind"` // Address to listen on; empty listens on every interface
+}
+
+// RetentionConfig overrides the retention settings. Fields left out keep the
+// value saved from the web UI, or the default.
+type RetentionConfig struct {
+	Enabled    *bool    `yaml:"enabled"`
+	MaxLogs    *int     `yaml:"max_logs"`
+	MaxAgeDays *int     `yaml:"max_age_days"`
+	MaxSizeMB  *float64 `yaml:"max_size_mb"`
+	CheckMins  *int     `yaml:"check_mins"`
+}
+
+// ParserConfig adds log formats to the parser
+type ParserConfig struct {
+	// Regexes tried on lines that aren't JSON, before the built-in format. The
+	// named groups timestamp, level, service, and message fill in those fields.
+	Patterns []string `yaml:"patterns"`
+}
+
+// FilterConfig holds the defaults for peep ingest's filter flags
+type FilterConfig struct {
+	ExcludeLevels   []string `yaml:"exclude_levels"`
+	IncludeLevels   []string `yaml:"include_levels"`
+	ExcludePatterns []string `yaml:"exclude_patterns"`
+	IncludePatterns []string `yaml:"include_patterns"`
+}
+
+// IngestConfig sets the listeners peep serve and peep daemon open for pushed
+// logs. An empty address leaves that listener off.
+type IngestConfig struct {
+	TCP    string `yaml:"tcp"`    // Newline-delimited logs
+	Syslog string `yaml:"syslog"` // Syslog over UDP and TCP on the same port
+	HTTP   string `yaml:"http"`   // POSTed logs, like the web server's /api/ingest; localhost unless a host is given
+	StatsD string `yaml:"statsd"` // StatsD metrics over UDP, into the metrics table
+	OTLP   string `yaml:"otlp"`   // OpenTelemetry traces over OTLP/HTTP, into the spans table
+
+	// HTTPRequireToken makes the HTTP listener take only requests with an API
+	// token allowed to ingest, as created by peep tokens create --scope ingest
+	HTTPRequireToken bool `yaml:"http_require_token"`
+}
+
+// AlertsConfig tunes the alert engine
+type AlertsConfig struct {
+	CheckInterval time.Duration `yaml:"check_interval"` // How often rules are evaluated; 0 uses the engine's default
+
+	// DormantDays is how long a warning or error pattern must go unseen
+	// before it's flagged again like a new one; 0 uses the engine's default
+	DormantDays int `yaml:"dormant_days"`
+}
+
+// PatternDormancy is DormantDays as a duration
+func (a AlertsConfig) PatternDormancy() time.Duration {
+	return time.Duration(a.DormantDays) * 24 * time.Hour
+}
+
+// AskConfig points peep ask at a language model behind an OpenAI-compatible
+// chat completions API, hosted or local (Ollama, llama.cpp, vLLM)
+type AskConfig struct {
+	Endpoint string        `yaml:"endpoint"` // Base URL, e.g. https://api.openai.com/v1
+	Model    string        `yaml:"model"`
+	APIKey   string        `yaml:"api_key"` // Prefer PEEP_ASK_API_KEY to keeping it in the file
+	Timeout  time.Duration `yaml:"timeout"` // For each request to the model; 0 uses the default
+}
+
+// WriteBufferConfig turns on the write buffer, which commits logs from every
+// source in batches rather than one commit per log. Zero fields use the
+// storage defaults.
+type WriteBufferConfig struct {
+	Enabled bool `yaml:"enabled"`
+
+	// Durability is full (inserts return once committed) or buffered (once
+	// queued, losing up to flush_interval of logs if peep crashes)
+	Durability string `yaml:"durability"`
+
+	// FlushInterval is how long to gather logs for each commit; 0 commits
+	// as soon as the previous commit finishes
+	FlushInterval time.Duration `yaml:"flush_interval"`
+
+	MaxBatch   int `yaml:"max_batch"`   // Logs per commit
+	MaxPending int `yaml:"max_pending"` // Logs queued before inserts wait for the writer
+}
+
+// Storage returns the settings in the form storage.EnableWriteBuffer takes
+func (w WriteBufferConfig) Storage() storage.WriteBufferConfig {
+	return storage.WriteBufferConfig{
+		Durability:    w.Durability,
+		FlushInterval: w.FlushInterval,
+		MaxBatch:      w.MaxBatch,
+		MaxPending:    w.MaxPending,
+	}
+}
+
+// Default returns the settings used when nothing is configured
+func Default() Config {
+	return Config{
+		DBPath: "logs.db",
+		Web:    WebConfig{Port: 8080},
+	}
+}
+
+// DefaultPath is where Load looks when no path is given
+func DefaultPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "peep", "config.yaml")
+}
+
+// Load reads the config file at path, or at DefaultPath if path is empty, over
+// the defaults, then applies PEEP_* environment variables. A missing default
+// file isn't an error; a missing explicit one is.
+func Load(path string) (Config, error) {
+	config := Default()
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultPath()
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return config, err
+		default:
+			decoder := yaml.NewDecoder(bytes.NewReader(data))
+			decoder.KnownFields(true) // Catch misspelled settings
+			if err := decoder.Decode(&config); err != nil && err != io.EOF {
+				return config, fmt.Errorf("invalid config %s: %w", path, err)
+			}
+		}
+	}
+
+	if err := config.applyEnv(); err != nil {
+		return config, err
+	}
+	return config, config.Validate()
+}
+
+// applyEnv overrides settings with the PEEP_* environment variables that are set
+func (c *Config) applyEnv() error {
+	if v := os.Getenv("PEEP_DB"); v != "" {
+		c.DBPath = v
+	}
+	if v := os.Getenv("PEEP_WEB_BIND"); v != "" {
+		c.Web.Bind = v
+	}
+	if v := os.Getenv("PEEP_INGEST_TCP"); v != "" {
+		c.Ingest.TCP = v
+	}
+	if v := os.Getenv("PEEP_INGEST_SYSLOG"); v != "" {
+		c.Ingest.Syslog = v
+	}
+	if v := os.Getenv("PEEP_INGEST_HTTP"); v != "" {
+		c.Ingest.HTTP = v
+	}
+	if v := os.Getenv("PEEP_INGEST_STATSD"); v != "" {
+		c.Ingest.StatsD = v
+	}
+	if v := os.Getenv("PEEP_INGEST_OTLP"); v != "" {
+		c.Ingest.OTLP = v
+	}
+	if v := os.Getenv("PEEP_WRITE_BUFFER_DURABILITY"); v != "" {
+		c.WriteBuffer.Durability = v
+	}
+	if v := os.Getenv("PEEP_ASK_ENDPOINT"); v != "" {
+		c.Ask.Endpoint = v
+	}
+	if v := os.Getenv("PEEP_ASK_MODEL"); v != "" {
+		c.Ask.Model = v
+	}
+	if v := os.Getenv("PEEP_ASK_API_KEY"); v != "" {
+		c.Ask.APIKey = v
+	}
+
+	ints := []struct {
+		name string
+		set  func(int)
+	}{
+		{"PEEP_WEB_PORT", func(n int) { c.Web.Port = n }},
+		{"PEEP_RETENTION_MAX_LOGS", func(n int) { c.Retention.MaxLogs = &n }},
+		{"PEEP_RETENTION_MAX_AGE_DAYS", func(n int) { c.Retention.MaxAgeDays = &n }},
+		{"PEEP_RETENTION_CHECK_MINS", func(n int) { c.Retention.CheckMins = &n }},
+		{"PEEP_ALERTS_DORMANT_DAYS", func(n int) { c.Alerts.DormantDays = n }},
+	}
+	for _, env := range ints {
+		if v := os.Getenv(env.name); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil {
+				return fmt.Errorf("%s: %q isn't a whole number", env.name, v)
+			}
+			env.set(n)
+		}
+	}
+
+	if v := os.Getenv("PEEP_QUERY_TIMEOUT"); v != "" {
+		timeout, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_QUERY_TIMEOUT: %q isn't a duration like 30s or 1m", v)
+		}
+		c.QueryTimeout = timeout
+	}
+	if v := os.Getenv("PEEP_ALERTS_CHECK_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_ALERTS_CHECK_INTERVAL: %q isn't a duration like 30s or 1m", v)
+		}
+		c.Alerts.CheckInterval = interval
+	}
+	if v := os.Getenv("PEEP_RETENTION_MAX_SIZE_MB"); v != "" {
+		size, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return fmt.Errorf("PEEP_RETENTION_MAX_SIZE_MB: %q isn't a number", v)
+		}
+		c.Retention.MaxSizeMB = &size
+	}
+	if v := os.Getenv("PEEP_WRITE_BUFFER_FLUSH_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_WRITE_BUFFER_FLUSH_INTERVAL: %q isn't a duration like 100ms or 1s", v)
+		}
+		c.WriteBuffer.FlushInterval = interval
+	}
+	if v := os.Getenv("PEEP_WRITE_BUFFER"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_WRITE_BUFFER: %q isn't true or false", v)
+		}
+		c.WriteBuffer.Enabled = enabled
+	}
+	if v := os.Getenv("PEEP_INGEST_HTTP_REQUIRE_TOKEN"); v != "" {
+		required, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_INGEST_HTTP_REQUIRE_TOKEN: %q isn't true or false", v)
+		}
+		c.Ingest.HTTPRequireToken = required
+	}
+	if v := os.Getenv("PEEP_RETENTION_ENABLED"); v != "" {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("PEEP_RETENTION_ENABLED: %q isn't true or false", v)
+		}
+		c.Retention.Enabled = &enabled
+	}
+	return nil
+}
+
+// Validate checks the settings that would otherwise fail later, or silently
+func (c Config) Validate() error {
+	if c.DBPath == "" {
+		return fmt.Errorf("db_path can't be empty")
+	}
+	if c.Web.Port < 1 || c.Web.Port > 65535 {
+		return fmt.Errorf("web port %d is out of range", c.Web.Port)
+	}
+	if c.QueryTimeout < 0 {
+		return fmt.Errorf("query_timeout can't be negative")
+	}
+	if c.Alerts.CheckInterval != 0 && c.Alerts.CheckInterval < time.Second {
+		return fmt.Errorf("alerts check_interval %s is shorter than a second", c.Alerts.CheckInterval)
+	}
+	if c.Alerts.DormantDays < 0 {
+		return fmt.Errorf("alerts dormant_days can't be negative")
+	}
+	if c.Ask.Timeout < 0 {
+		return fmt.Errorf("ask timeout can't be negative")
+	}
+	if err := c.WriteBuffer.Storage().Validate(); err != nil {
+		return fmt.Errorf("write_buffer: %w", err)
+	}
+	for _, pattern := range c.Parser.Patterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid parser pattern %q: %w", pattern, err)
+		}
+	}
+	for _, patterns := range [][]string{c.Filters.ExcludePatterns, c.Filters.IncludePatterns} {
+		for _, pattern := range patterns {
+			if _, err := regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid filter pattern %q: %w", pattern, err)
+			}
+		}
+	}
+	if err := ingestion.ValidateDeriveRules(c.Derive); err != nil {
+		return err
+	}
+	if err := ingestion.ValidateWebhooks(c.Hooks); err != nil {
+		return err
+	}
+	return export.Validate(c.Export)
+}
+
+// Apply overrides the retention settings that are configured
+func (r RetentionConfig) Apply(config storage.RetentionConfig) storage.RetentionConfig {
+	if r.Enabled != nil {
+		config.Enabled = *r.Enabled
+	}
+	if r.MaxLogs != nil {
+		config.MaxLogs = *r.MaxLogs
+	}
+	if r.MaxAgeDays != nil {
+		config.MaxAge = time.Duration(*r.MaxAgeDays) * 24 * time.Hour
+	}
+	if r.MaxSizeMB != nil {
+		config.MaxSizeMB = *r.MaxSizeMB
+	}
+	if r.CheckMins != nil {
+		config.CheckInterval = time.Duration(*r.CheckMins) * time.Minute
+	}
+	return config
+}
@@ -0,0 +1,265 @@
+// Package export tees stored logs to another system (an OpenTelemetry
+// collector, Loki, or any webhook) so Peep can be the local buffer and viewer
+// while a central system still gets the logs. Like the agent's forwarder, an
+// exporter reads the logs table in ID order from a saved position, so logs
+// from every ingestion path are sent, and wait in the database while the
+// destination is down.
+package export
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Destination types
+const (
+	TypeOTLP    = "otlp"    // OTLP/HTTP JSON, to a collector's /v1/logs
+	TypeLoki    = "loki"    // Loki's /loki/api/v1/push
+	TypeWebhook = "webhook" // A JSON array of logs POSTed to the URL as is
+)
+
+// Destination is where an exporter sends logs, and which ones
+type Destination struct {
+	Name string `yaml:"name"` // Names the saved position, so keep it stable
+	Type string `yaml:"type"`
+
+	// URL is the collector's or Loki's base URL (the standard path is
+	// added), or the webhook's full URL
+	URL string `yaml:"url"`
+
+	// Headers are added to every request, e.g. Authorization or X-Scope-OrgID
+	Headers map[string]string `yaml:"headers"`
+
+	Levels   []string `yaml:"levels"`   // Only logs with these levels; empty sends every level
+	Services []string `yaml:"services"` // Only logs from these services; empty sends every service
+}
+
+const (
+	batchSize     = 500
+	flushInterval = time.Second // Longest a log waits for a batch to fill
+	maxBackoff    = time.Minute
+)
+
+// destinationNamePattern keeps names usable in the settings key
+var destinationNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// Validate checks destinations have unique names, a known type, and a URL
+func Validate(destinations []Destination) error {
+	names := make(map[string]bool, len(destinations))
+	for _, d := range destinations {
+		if !destinationNamePattern.MatchString(d.Name) {
+			return fmt.Errorf("invalid export name %q: use letters, digits, '-', '_', and '.'", d.Name)
+		}
+		if names[d.Name] {
+			return fmt.Errorf("export %q is defined twice", d.Name)
+		}
+		names[d.Name] = true
+
+		switch d.Type {
+		case TypeOTLP, TypeLoki, TypeWebhook:
+		default:
+			return fmt.Errorf("export %s: unknown type %q (use otlp, loki, or webhook)", d.Name, d.Type)
+		}
+		if u, err := url.Parse(d.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("export %s: url %q isn't an http or https URL", d.Name, d.URL)
+		}
+	}
+	return nil
+}
+
+// endpoint is the URL logs are POSTed to
+func (d Destination) endpoint() string {
+	base := strings.TrimRight(d.URL, "/")
+	switch {
+	case d.Type == TypeOTLP && !strings.HasSuffix(base, "/v1/logs"):
+		return base + "/v1/logs"
+	case d.Type == TypeLoki && !strings.HasSuffix(base, "/loki/api/v1/push"):
+		return base + "/loki/api/v1/push"
+	}
+	return d.URL
+}
+
+// matches reports whether a log passes the level and service filters
+func (d Destination) matches(entry storage.LogEntry) bool {
+	return matchesAny(d.Levels, strings.ToLower(entry.Level)) && matchesAny(d.Services, entry.Service)
+}
+
+func matchesAny(allowed []string, value string) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(a, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// Exporter sends stored logs to a destination as they arrive
+type Exporter struct {
+	Store       *storage.Storage
+	Destination Destination
+	Client      *http.Client
+
+	cursor int64 // ID of the last log sent, or skipped by the filters
+}
+
+// cursorSetting is where an exporter's position is saved
+func cursorSetting(name string) string {
+	return "export." + name + ".forwarded_id"
+}
+
+// NewExporter creates an exporter. A new destination starts after the logs
+// already stored, so turning one on doesn't resend the whole database.
+func NewExporter(store *storage.Storage, destination Destination) (*Exporter, error) {
+	e := &Exporter{
+		Store:       store,
+		Destination: destination,
+		Client:      &http.Client{Timeout: 30 * time.Second},
+	}
+	key := cursorSetting(destination.Name)
+	_, found, err := store.GetSetting(key, &e.cursor)
+	if err != nil {
+		return nil, err
+	}
+	if !found {
+		if _, e.cursor, err = store.CountAfter(context.Background(), 0); err != nil {
+			return nil, err
+		}
+		if err := store.PutSetting(key, e.cursor); err != nil {
+			return nil, err
+		}
+	}
+	return e, nil
+}
+
+// Run sends logs until ctx is cancelled, retrying with backoff while the
+// destination fails
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	var backoff time.Duration
+	for {
+		scanned, err := e.sendBatch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			if backoff == 0 {
+				slog.Warn("export failed, keeping logs until it recovers", "export", e.Destination.Name, "error", err)
+				backoff = time.Second
+			} else {
+				slog.Debug("export still failing", "export", e.Destination.Name, "retry_in", backoff.String(), "error", err)
+				backoff = min(backoff*2, maxBackoff)
+			}
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			continue
+		}
+		if backoff > 0 {
+			slog.Info("export resumed", "export", e.Destination.Name)
+			backoff = 0
+		}
+
+		// A full batch means there's more waiting
+		if scanned == batchSize {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// sendBatch sends the matching logs among the next batch stored and moves
+// the saved position past the whole batch. It returns how many logs it read.
+func (e *Exporter) sendBatch(ctx context.Context) (int, error) {
+	// Only the logs the destination wants are kept from the batch
+	var matching []storage.LogEntry
+	var scanned int
+	var last int64
+	readCtx, cancel := e.Store.WithTimeout(ctx)
+	err := e.Store.EachLog(readCtx, `
+	SELECT id, timestamp, level, message, service, context, raw_log, created_at
+	FROM logs WHERE id > ? ORDER BY id LIMIT ?`, []interface{}{e.cursor, batchSize}, func(entry storage.LogEntry) error {
+		scanned++
+		last = entry.ID
+		if e.Destination.matches(entry) {
+			matching = append(matching, entry)
+		}
+		return nil
+	})
+	cancel()
+	if err != nil || scanned == 0 {
+		return 0, err
+	}
+
+	if len(matching) > 0 {
+		if err := e.post(ctx, matching); err != nil {
+			return 0, err
+		}
+	}
+
+	e.cursor = last
+	if err := e.Store.PutSetting(cursorSetting(e.Destination.Name), e.cursor); err != nil {
+		return 0, fmt.Errorf("failed to save export position: %w", err)
+	}
+	return scanned, nil
+}
+
+// post encodes logs for the destination and sends them
+func (e *Exporter) post(ctx context.Context, logs []storage.LogEntry) error {
+	var body []byte
+	var err error
+	switch e.Destination.Type {
+	case TypeOTLP:
+		body, err = encodeOTLP(logs)
+	case TypeLoki:
+		body, err = encodeLoki(logs)
+	default:
+		body, err = encodeWebhook(logs)
+	}
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Destination.endpoint(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "peep-export")
+	for name, value := range e.Destination.Headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		detail, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s: %s", resp.Status, strings.TrimSpace(string(detail)))
+	}
+	io.Copy(io.Discard, resp.Body)
+	return nil
+}
@@ -0,0 +1,188 @@
+package export
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// webhookLog is a log as the webhook destination sends it
+type webhookLog struct {
+	Timestamp time.Time              `json:"timestamp"`
+	Level     string                 `json:"level"`
+	Service   string                 `json:"service"`
+	Message   string                 `json:"message"`
+	Context   map[string]interface{} `json:"context,omitempty"`
+}
+
+// encodeWebhook writes logs as a JSON array
+func encodeWebhook(logs []storage.LogEntry) ([]byte, error) {
+	out := make([]webhookLog, len(logs))
+	for i, entry := range logs {
+		out[i] = webhookLog{
+			Timestamp: entry.Timestamp,
+			Level:     entry.Level,
+			Service:   entry.Service,
+			Message:   entry.Message,
+			Context:   contextFields(entry.Context),
+		}
+	}
+	return json.Marshal(out)
+}
+
+// contextFields decodes a log's JSON context; plain-text logs have none
+func contextFields(context string) map[string]interface{} {
+	if context == "" {
+		return nil
+	}
+	var fields map[string]interface{}
+	json.Unmarshal([]byte(context), &fields)
+	return fields
+}
+
+// encodeLoki writes logs as a Loki push request, with a stream for each
+// service and level. The line is the log as it arrived, so Loki's json and
+// logfmt parsers see the original fields.
+func encodeLoki(logs []storage.LogEntry) ([]byte, error) {
+	type stream struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	}
+	var streams []*stream
+	byLabels := map[[2]string]*stream{}
+	for _, entry := range logs {
+		key := [2]string{entry.Service, strings.ToLower(entry.Level)}
+		s, ok := byLabels[key]
+		if !ok {
+			labels := map[string]string{"source": "peep", "level": key[1]}
+			if entry.Service != "" {
+				labels["service"] = entry.Service
+			}
+			s = &stream{Stream: labels}
+			byLabels[key] = s
+			streams = append(streams, s)
+		}
+		line := entry.RawLog
+		if line == "" {
+			line = entry.Message
+		}
+		s.Values = append(s.Values, [2]string{strconv.FormatInt(entry.Timestamp.UnixNano(), 10), line})
+	}
+	return json.Marshal(map[string]interface{}{"streams": streams})
+}
+
+// OTLP/JSON shapes for an ExportLogsServiceRequest
+type (
+	otlpKeyValue struct {
+		Key   string       `json:"key"`
+		Value otlpAnyValue `json:"value"`
+	}
+	otlpAnyValue struct {
+		StringValue *string  `json:"stringValue,omitempty"`
+		BoolValue   *bool    `json:"boolValue,omitempty"`
+		DoubleValue *float64 `json:"doubleValue,omitempty"`
+	}
+	otlpLogRecord struct {
+		TimeUnixNano   string         `json:"timeUnixNano"`
+		SeverityNumber int            `json:"severityNumber,omitempty"`
+		SeverityText   string         `json:"severityText,omitempty"`
+		Body           otlpAnyValue   `json:"body"`
+		Attributes     []otlpKeyValue `json:"attributes,omitempty"`
+		TraceID        string         `json:"traceId,omitempty"`
+	}
+	otlpResourceLogs struct {
+		Resource struct {
+			Attributes []otlpKeyValue `json:"attributes,omitempty"`
+		} `json:"resource"`
+		ScopeLogs []otlpScopeLogs `json:"scopeLogs"`
+	}
+	otlpScopeLogs struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		LogRecords []otlpLogRecord `json:"logRecords"`
+	}
+)
+
+// otlpSeverity maps levels onto OpenTelemetry severity numbers
+var otlpSeverity = map[string]int{
+	"trace": 1, "debug": 5, "info": 9, "warn": 13, "warning": 13, "error": 17, "fatal": 21, "critical": 21,
+}
+
+// otlpCarried are context fields the record already carries as its time,
+// severity, body, or service
+var otlpCarried = map[string]bool{
+	"timestamp": true, "time": true, "level": true, "message": true, "msg": true, "service": true,
+}
+
+// encodeOTLP writes logs as an OTLP/HTTP JSON request, with a resource for
+// each service and the context fields as attributes
+func encodeOTLP(logs []storage.LogEntry) ([]byte, error) {
+	var resources []*otlpResourceLogs
+	byService := map[string]*otlpResourceLogs{}
+	for _, entry := range logs {
+		resource, ok := byService[entry.Service]
+		if !ok {
+			resource = &otlpResourceLogs{}
+			if entry.Service != "" {
+				resource.Resource.Attributes = []otlpKeyValue{{Key: "service.name", Value: otlpString(entry.Service)}}
+			}
+			scope := otlpScopeLogs{}
+			scope.Scope.Name = "peep"
+			resource.ScopeLogs = []otlpScopeLogs{scope}
+			byService[entry.Service] = resource
+			resources = append(resources, resource)
+		}
+
+		record := otlpLogRecord{
+			TimeUnixNano:   strconv.FormatInt(entry.Timestamp.UnixNano(), 10),
+			SeverityNumber: otlpSeverity[strings.ToLower(entry.Level)],
+			SeverityText:   entry.Level,
+			Body:           otlpString(entry.Message),
+		}
+		// OTLP/JSON carries trace IDs as 32 hex digits
+		if id := storage.TraceID(entry.Context); len(id) == 32 {
+			if _, err := hex.DecodeString(id); err == nil {
+				record.TraceID = id
+			}
+		}
+		fields := contextFields(entry.Context)
+		keys := make([]string, 0, len(fields))
+		for key := range fields {
+			// The trace ID field is carried as the record's traceId
+			if !otlpCarried[key] && (record.TraceID == "" || fields[key] != record.TraceID) {
+				keys = append(keys, key)
+			}
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			record.Attributes = append(record.Attributes, otlpKeyValue{Key: key, Value: otlpValue(fields[key])})
+		}
+		resource.ScopeLogs[0].LogRecords = append(resource.ScopeLogs[0].LogRecords, record)
+	}
+	return json.Marshal(map[string]interface{}{"resourceLogs": resources})
+}
+
+func otlpString(s string) otlpAnyValue {
+	return otlpAnyValue{StringValue: &s}
+}
+
+// otlpValue converts a decoded JSON value; objects and arrays are sent as JSON text
+func otlpValue(value interface{}) otlpAnyValue {
+	switch v := value.(type) {
+	case string:
+		return otlpString(v)
+	case bool:
+		return otlpAnyValue{BoolValue: &v}
+	case float64:
+		return otlpAnyValue{DoubleValue: &v}
+	default:
+		data, _ := json.Marshal(v)
+		return otlpString(string(data))
+	}
+}
@@ -0,0 +1,33 @@
+// Package format prints numbers for people, the same way in the TUI and the web UI
+package format
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// Count adds thousands separators, e.g. 1204 -> "1,204"
+func Count(n int64) string {
+	if n < 0 {
+		return "-" + Count(-n)
+	}
+	s := strconv.FormatInt(n, 10)
+	for i := len(s) - 3; i > 0; i -= 3 {
+		s = s[:i] + "," + s[i:]
+	}
+	return s
+}
+
+// Bytes prints a byte count in the largest fitting unit, e.g. 1536 -> "1.5 KB"
+func Bytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
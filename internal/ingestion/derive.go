@@ -0,0 +1,192 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// DeriveRule extracts a number from matching logs as they're ingested and
+// stores it as a sample of a metric series, e.g. the latency in
+// "GET /orders took 84ms" as http.latency_ms{service="api"}
+type DeriveRule struct {
+	Metric string `yaml:"metric"` // The series' name
+
+	// Pattern is a regex matched against the message. Its named group "value",
+	// or else its first unnamed group, is the number; other named groups become
+	// labels. Without a value group, each matching log counts as 1.
+	Pattern string `yaml:"pattern"`
+
+	// Field takes the number from this JSON context field instead. With a
+	// Pattern too, only logs matching it count.
+	Field string `yaml:"field"`
+
+	// Labels are log fields copied onto the sample: service, level, or
+	// context fields
+	Labels []string `yaml:"labels"`
+}
+
+// derivation is a compiled DeriveRule
+type derivation struct {
+	DeriveRule
+	re         *regexp.Regexp
+	valueGroup int // Index of the value group, or -1 to count matches
+}
+
+// Deriver applies derive rules to ingested logs, storing what they extract
+type Deriver struct {
+	Store func(metrics []storage.Metric) error
+
+	mu    sync.RWMutex // Guards rules against SetRules while logs are observed
+	rules []derivation
+}
+
+// NewDeriver compiles rules, storing the samples they extract with store
+func NewDeriver(rules []DeriveRule, store func(metrics []storage.Metric) error) (*Deriver, error) {
+	d := &Deriver{Store: store}
+	if err := d.SetRules(rules); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ValidateDeriveRules checks rules compile, for config validation
+func ValidateDeriveRules(rules []DeriveRule) error {
+	_, err := compileDeriveRules(rules)
+	return err
+}
+
+// SetRules replaces the rules, e.g. after a config reload. On error the
+// current rules are kept.
+func (d *Deriver) SetRules(rules []DeriveRule) error {
+	compiled, err := compileDeriveRules(rules)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	d.rules = compiled
+	d.mu.Unlock()
+	return nil
+}
+
+func compileDeriveRules(rules []DeriveRule) ([]derivation, error) {
+	compiled := make([]derivation, 0, len(rules))
+	for _, rule := range rules {
+		if strings.TrimSpace(rule.Metric) == "" {
+			return nil, fmt.Errorf("derive rule needs a metric name")
+		}
+		if rule.Pattern == "" && rule.Field == "" {
+			return nil, fmt.Errorf("derive rule for %s needs a pattern or a field", rule.Metric)
+		}
+
+		c := derivation{DeriveRule: rule, valueGroup: -1}
+		if rule.Pattern != "" {
+			re, err := regexp.Compile(rule.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid derive pattern for %s: %w", rule.Metric, err)
+			}
+			c.re = re
+			c.valueGroup = re.SubexpIndex("value")
+			if c.valueGroup < 0 {
+				for i, name := range re.SubexpNames() {
+					if i > 0 && name == "" {
+						c.valueGroup = i
+						break
+					}
+				}
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// Observe stores a sample for each rule that matches entry. A nil Deriver
+// does nothing.
+func (d *Deriver) Observe(entry storage.LogEntry) error {
+	if d == nil {
+		return nil
+	}
+	d.mu.RLock()
+	rules := d.rules
+	d.mu.RUnlock()
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var fields map[string]interface{}
+	var metrics []storage.Metric
+	for _, rule := range rules {
+		labels := map[string]string{}
+		value := 1.0
+
+		if rule.re != nil {
+			match := rule.re.FindStringSubmatch(entry.Message)
+			if match == nil {
+				continue
+			}
+			for i, name := range rule.re.SubexpNames() {
+				if name != "" && name != "value" && match[i] != "" {
+					labels[name] = match[i]
+				}
+			}
+			if rule.Field == "" && rule.valueGroup > 0 {
+				v, err := strconv.ParseFloat(match[rule.valueGroup], 64)
+				if err != nil {
+					continue
+				}
+				value = v
+			}
+		}
+
+		if fields == nil && (rule.Field != "" || len(rule.Labels) > 0) {
+			fields = map[string]interface{}{}
+			json.Unmarshal([]byte(entry.Context), &fields) // Plain-text logs have no fields
+		}
+		if rule.Field != "" {
+			v, ok := numberField(fields[rule.Field])
+			if !ok {
+				continue
+			}
+			value = v
+		}
+
+		for _, name := range rule.Labels {
+			switch name {
+			case "service":
+				labels[name] = entry.Service
+			case "level":
+				labels[name] = entry.Level
+			default:
+				if v, ok := fields[name]; ok && v != nil {
+					labels[name] = fmt.Sprint(v)
+				}
+			}
+		}
+
+		metrics = append(metrics, storage.Metric{Timestamp: entry.Timestamp, Name: rule.Metric, Labels: labels, Value: value})
+	}
+
+	if len(metrics) == 0 {
+		return nil
+	}
+	return d.Store(metrics)
+}
+
+// numberField reads a JSON number, or a string holding one
+func numberField(v interface{}) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
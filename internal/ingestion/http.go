@@ -0,0 +1,127 @@
+package ingestion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// maxHTTPBody caps a request to the HTTP listener, like the web server's /api/ingest
+const maxHTTPBody = 64 << 20
+
+// HTTPListener accepts logs POSTed as newline-delimited text or JSON, the same
+// body the web server's /api/ingest takes, for hosts that collect logs without
+// serving the UI. An address without a host listens on localhost only; give
+// one such as 0.0.0.0:5171 to accept logs from other machines, and set
+// Authorize to require a token from them.
+type HTTPListener struct {
+	Addr   string // e.g. ":5171" for localhost
+	Parser *LogParser
+
+	// Authorize, if set, checks the Bearer token of each request before its
+	// body is read. Requests it refuses get a 401.
+	Authorize func(token string) bool
+
+	// Handle stores a parsed line; line is the raw text for filters that match on it
+	Handle func(entry storage.LogEntry, line string) error
+
+	// OnError reports storage errors; the client also gets a 500
+	OnError func(err error)
+
+	ln net.Listener
+}
+
+// Listen binds l.Addr, on localhost if it has no host
+func (l *HTTPListener) Listen() error {
+	addr := l.Addr
+	if host, port, err := net.SplitHostPort(addr); err == nil && host == "" {
+		addr = net.JoinHostPort("127.0.0.1", port)
+	}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+	return nil
+}
+
+// Serve handles requests until ctx is cancelled, then lets in-flight ones
+// finish. It calls Listen first if it hasn't been.
+func (l *HTTPListener) Serve(ctx context.Context) error {
+	if l.ln == nil {
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", l.handleIngest)
+	mux.HandleFunc("/api/ingest", l.handleIngest)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(l.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (l *HTTPListener) handleIngest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" && r.URL.Path != "/api/ingest" {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if l.Authorize != nil {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || !l.Authorize(token) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	ingested := 0
+	var storeErr error
+	err := readNewlineDelimited(http.MaxBytesReader(w, r.Body, maxHTTPBody), func(line string) {
+		if storeErr != nil {
+			return
+		}
+		if storeErr = l.Handle(l.Parser.ParseLine(line), line); storeErr == nil {
+			ingested++
+		}
+	})
+	if storeErr != nil {
+		if l.OnError != nil {
+			l.OnError(storeErr)
+		}
+		http.Error(w, fmt.Sprintf("failed to store log: %v", storeErr), http.StatusInternalServerError)
+		return
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("request body is over %d MB after %d logs; send smaller batches", maxHTTPBody>>20, ingested), http.StatusRequestEntityTooLarge)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ingested": %d}`, ingested)
+}
@@ -0,0 +1,60 @@
+package ingestion
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+func TestHTTPListenerBindsLocalhost(t *testing.T) {
+	l := &HTTPListener{Addr: ":0"}
+	if err := l.Listen(); err != nil {
+		t.Fatal(err)
+	}
+	defer l.ln.Close()
+
+	if ip := l.ln.Addr().(*net.TCPAddr).IP; !ip.IsLoopback() {
+		t.Errorf("listening on %s, want localhost", ip)
+	}
+}
+
+func TestHTTPListenerAuthorize(t *testing.T) {
+	var stored []storage.LogEntry
+	l := &HTTPListener{
+		Parser: &LogParser{},
+		Handle: func(entry storage.LogEntry, line string) error {
+			stored = append(stored, entry)
+			return nil
+		},
+		Authorize: func(token string) bool { return token == "peep_good" },
+	}
+
+	tests := []struct {
+		header string
+		want   int
+	}{
+		{"", http.StatusUnauthorized},
+		{"peep_good", http.StatusUnauthorized},
+		{"Basic peep_good", http.StatusUnauthorized},
+		{"Bearer peep_bad", http.StatusUnauthorized},
+		{"Bearer peep_good", http.StatusOK},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodPost, "/api/ingest", strings.NewReader("first\nsecond\n"))
+		if tt.header != "" {
+			r.Header.Set("Authorization", tt.header)
+		}
+		w := httptest.NewRecorder()
+		l.handleIngest(w, r)
+		if w.Code != tt.want {
+			t.Errorf("Authorization %q: got status %d, want %d", tt.header, w.Code, tt.want)
+		}
+	}
+	if len(stored) != 2 {
+		t.Errorf("stored %d logs, want only the authorized request's 2", len(stored))
+	}
+}
@@ -0,0 +1,137 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// maxLineSize is the longest line a listener accepts; longer ones end the connection
+const maxLineSize = 1024 * 1024
+
+// TCPListener accepts connections and parses each newline-delimited line sent
+// on them, so `tail -f app.log | nc host 5170` ships logs without an HTTP client
+type TCPListener struct {
+	Addr   string // e.g. ":5170"
+	Parser *LogParser
+
+	// Handle stores a parsed line; line is the raw text for filters that match on it
+	Handle func(entry storage.LogEntry, line string) error
+
+	// OnError reports connection and storage errors; they never stop the listener
+	OnError func(err error)
+
+	ln net.Listener
+
+	// Other protocols on top of TCP replace the framing and parsing
+	readLines func(r io.Reader, fn func(line string)) error
+	parse     func(line string) storage.LogEntry
+}
+
+// Listen binds l.Addr
+func (l *TCPListener) Listen() error {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+	return nil
+}
+
+// Serve accepts connections until ctx is cancelled, then closes the listener
+// and every open connection. It calls Listen first if it hasn't been.
+func (l *TCPListener) Serve(ctx context.Context) error {
+	if l.ln == nil {
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+	ln := l.ln
+
+	var (
+		mu    sync.Mutex
+		conns = make(map[net.Conn]struct{})
+		wg    sync.WaitGroup
+	)
+	go func() {
+		<-ctx.Done()
+		ln.Close()
+		mu.Lock()
+		for conn := range conns {
+			conn.Close()
+		}
+		mu.Unlock()
+	}()
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			wg.Wait()
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		mu.Lock()
+		conns[conn] = struct{}{}
+		mu.Unlock()
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			l.serveConn(conn)
+			mu.Lock()
+			delete(conns, conn)
+			mu.Unlock()
+			conn.Close()
+		}()
+	}
+}
+
+// serveConn reads lines from one connection until it closes
+func (l *TCPListener) serveConn(conn net.Conn) {
+	readLines, parse := l.readLines, l.parse
+	if readLines == nil {
+		readLines = readNewlineDelimited
+	}
+	if parse == nil {
+		parse = l.Parser.ParseLine
+	}
+
+	err := readLines(conn, func(line string) {
+		if err := l.Handle(parse(line), line); err != nil {
+			l.report(err)
+		}
+	})
+	if err != nil && !errors.Is(err, net.ErrClosed) {
+		l.report(err)
+	}
+}
+
+// readNewlineDelimited calls fn with each non-blank line read from r
+func readNewlineDelimited(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maxLineSize)
+
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fn(line)
+	}
+	return scanner.Err()
+}
+
+func (l *TCPListener) report(err error) {
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+}
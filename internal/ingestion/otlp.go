@@ -0,0 +1,564 @@
+package ingestion
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// maxOTLPBody caps a decompressed OTLP request, as collectors batch spans
+// into requests of a few megabytes at most
+const maxOTLPBody = 32 << 20
+
+// OTLPListener accepts traces from OpenTelemetry SDKs and collectors over
+// OTLP/HTTP, as protobuf or JSON, on the standard /v1/traces path. It has no
+// authentication, so bind it to a trusted network.
+type OTLPListener struct {
+	Addr string // e.g. ":4318"
+
+	// Store saves the spans of each request
+	Store func(spans []storage.Span) error
+
+	// OnError reports storage errors; the client also gets a 500, so it retries
+	OnError func(err error)
+
+	ln net.Listener
+}
+
+// Listen binds l.Addr
+func (l *OTLPListener) Listen() error {
+	ln, err := net.Listen("tcp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.ln = ln
+	return nil
+}
+
+// Serve handles requests until ctx is cancelled, then lets in-flight ones
+// finish. It calls Listen first if it hasn't been.
+func (l *OTLPListener) Serve(ctx context.Context) error {
+	if l.ln == nil {
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/traces", l.handleTraces)
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		server.Shutdown(shutdownCtx)
+	}()
+
+	if err := server.Serve(l.ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+func (l *OTLPListener) handleTraces(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	spans, err := ReadOTLPTraces(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(spans) > 0 {
+		if err := l.Store(spans); err != nil {
+			if l.OnError != nil {
+				l.OnError(err)
+			}
+			http.Error(w, fmt.Sprintf("failed to store spans: %v", err), http.StatusInternalServerError)
+			return
+		}
+	}
+	WriteOTLPResponse(w, r)
+}
+
+// ReadOTLPTraces decodes an OTLP/HTTP ExportTraceServiceRequest, as protobuf
+// or JSON depending on its Content-Type, and gzipped or not
+func ReadOTLPTraces(r *http.Request) ([]storage.Span, error) {
+	var body io.Reader = r.Body
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("invalid gzip body: %w", err)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, fmt.Errorf("unsupported Content-Encoding %q", r.Header.Get("Content-Encoding"))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxOTLPBody+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read request body: %w", err)
+	}
+	if len(data) > maxOTLPBody {
+		return nil, fmt.Errorf("request body is over %d MB", maxOTLPBody>>20)
+	}
+
+	if isOTLPJSON(r) {
+		return ParseOTLPTracesJSON(data)
+	}
+	return ParseOTLPTracesProto(data)
+}
+
+// WriteOTLPResponse acknowledges an export in the encoding it was sent in.
+// An empty ExportTraceServiceResponse means every span was accepted.
+func WriteOTLPResponse(w http.ResponseWriter, r *http.Request) {
+	if isOTLPJSON(r) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("{}"))
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-protobuf")
+	w.WriteHeader(http.StatusOK)
+}
+
+func isOTLPJSON(r *http.Request) bool {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mediaType == "application/json"
+}
+
+// otlpSpanKinds and otlpStatusCodes name OTLP's enum values
+var (
+	otlpSpanKinds   = []string{"unspecified", "internal", "server", "client", "producer", "consumer"}
+	otlpStatusCodes = []string{"unset", "ok", "error"}
+)
+
+func otlpEnum(names []string, v int64) string {
+	if v < 0 || v >= int64(len(names)) {
+		return names[0]
+	}
+	return names[v]
+}
+
+// otlpSpan is a span as decoded, before its resource's attributes are merged in
+type otlpSpan struct {
+	storage.Span
+	start, end uint64 // Unix nanoseconds
+}
+
+// finishOTLPSpans turns a resource's decoded spans into storage spans. The
+// resource's service.name becomes each span's service, and its other
+// attributes fill in any the span doesn't set itself.
+func finishOTLPSpans(decoded []otlpSpan, resource map[string]interface{}) []storage.Span {
+	service, _ := resource["service.name"].(string)
+	spans := make([]storage.Span, 0, len(decoded))
+	for _, d := range decoded {
+		span := d.Span
+		span.Service = service
+		for k, v := range resource {
+			if _, ok := span.Attributes[k]; !ok && k != "service.name" {
+				if span.Attributes == nil {
+					span.Attributes = make(map[string]interface{})
+				}
+				span.Attributes[k] = v
+			}
+		}
+		span.StartTime = time.Unix(0, int64(d.start))
+		if d.end > d.start {
+			span.Duration = time.Duration(d.end - d.start)
+		}
+		if span.Kind == "unspecified" {
+			span.Kind = "internal"
+		}
+		spans = append(spans, span)
+	}
+	return spans
+}
+
+// OTLP/JSON encodes IDs as hex, 64-bit integers as strings, and enums as
+// numbers, though some exporters send enum names instead
+type otlpJSONRequest struct {
+	ResourceSpans []struct {
+		Resource struct {
+			Attributes []otlpJSONKeyValue `json:"attributes"`
+		} `json:"resource"`
+		ScopeSpans []struct {
+			Spans []struct {
+				TraceID           string             `json:"traceId"`
+				SpanID            string             `json:"spanId"`
+				ParentSpanID      string             `json:"parentSpanId"`
+				Name              string             `json:"name"`
+				Kind              json.RawMessage    `json:"kind"`
+				StartTimeUnixNano json.Number        `json:"startTimeUnixNano"`
+				EndTimeUnixNano   json.Number        `json:"endTimeUnixNano"`
+				Attributes        []otlpJSONKeyValue `json:"attributes"`
+				Status            struct {
+					Code    json.RawMessage `json:"code"`
+					Message string          `json:"message"`
+				} `json:"status"`
+			} `json:"spans"`
+		} `json:"scopeSpans"`
+	} `json:"resourceSpans"`
+}
+
+type otlpJSONKeyValue struct {
+	Key   string           `json:"key"`
+	Value otlpJSONAnyValue `json:"value"`
+}
+
+type otlpJSONAnyValue struct {
+	StringValue *string     `json:"stringValue"`
+	BoolValue   *bool       `json:"boolValue"`
+	IntValue    json.Number `json:"intValue"`
+	DoubleValue *float64    `json:"doubleValue"`
+	BytesValue  string      `json:"bytesValue"`
+	ArrayValue  *struct {
+		Values []otlpJSONAnyValue `json:"values"`
+	} `json:"arrayValue"`
+	KvlistValue *struct {
+		Values []otlpJSONKeyValue `json:"values"`
+	} `json:"kvlistValue"`
+}
+
+func (v otlpJSONAnyValue) value() interface{} {
+	switch {
+	case v.StringValue != nil:
+		return *v.StringValue
+	case v.BoolValue != nil:
+		return *v.BoolValue
+	case v.IntValue != "":
+		if i, err := v.IntValue.Int64(); err == nil {
+			return i
+		}
+		return v.IntValue.String()
+	case v.DoubleValue != nil:
+		return *v.DoubleValue
+	case v.BytesValue != "":
+		if b, err := base64.StdEncoding.DecodeString(v.BytesValue); err == nil {
+			return hex.EncodeToString(b)
+		}
+		return v.BytesValue
+	case v.ArrayValue != nil:
+		values := make([]interface{}, 0, len(v.ArrayValue.Values))
+		for _, item := range v.ArrayValue.Values {
+			values = append(values, item.value())
+		}
+		return values
+	case v.KvlistValue != nil:
+		return otlpJSONAttributes(v.KvlistValue.Values)
+	default:
+		return nil
+	}
+}
+
+func otlpJSONAttributes(kvs []otlpJSONKeyValue) map[string]interface{} {
+	if len(kvs) == 0 {
+		return nil
+	}
+	attributes := make(map[string]interface{}, len(kvs))
+	for _, kv := range kvs {
+		attributes[kv.Key] = kv.Value.value()
+	}
+	return attributes
+}
+
+// otlpJSONEnum reads an enum sent as its number or as its full name, e.g.
+// 2 or "SPAN_KIND_SERVER"
+func otlpJSONEnum(raw json.RawMessage, names []string, prefix string) string {
+	var n int64
+	if err := json.Unmarshal(raw, &n); err == nil {
+		return otlpEnum(names, n)
+	}
+	var name string
+	if err := json.Unmarshal(raw, &name); err == nil {
+		name = strings.ToLower(strings.TrimPrefix(name, prefix))
+		for _, known := range names {
+			if name == known {
+				return known
+			}
+		}
+	}
+	return names[0]
+}
+
+// ParseOTLPTracesJSON decodes an ExportTraceServiceRequest in OTLP/JSON
+func ParseOTLPTracesJSON(data []byte) ([]storage.Span, error) {
+	var request otlpJSONRequest
+	if err := json.Unmarshal(data, &request); err != nil {
+		return nil, fmt.Errorf("invalid OTLP JSON: %w", err)
+	}
+
+	var spans []storage.Span
+	for _, rs := range request.ResourceSpans {
+		var decoded []otlpSpan
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				if s.TraceID == "" || s.SpanID == "" {
+					return nil, fmt.Errorf("span %q has no traceId or spanId", s.Name)
+				}
+				start, _ := strconv.ParseUint(s.StartTimeUnixNano.String(), 10, 64)
+				end, _ := strconv.ParseUint(s.EndTimeUnixNano.String(), 10, 64)
+				decoded = append(decoded, otlpSpan{
+					Span: storage.Span{
+						TraceID:       strings.ToLower(s.TraceID),
+						SpanID:        strings.ToLower(s.SpanID),
+						ParentSpanID:  strings.ToLower(s.ParentSpanID),
+						Name:          s.Name,
+						Kind:          otlpJSONEnum(s.Kind, otlpSpanKinds, "SPAN_KIND_"),
+						Status:        otlpJSONEnum(s.Status.Code, otlpStatusCodes, "STATUS_CODE_"),
+						StatusMessage: s.Status.Message,
+						Attributes:    otlpJSONAttributes(s.Attributes),
+					},
+					start: start,
+					end:   end,
+				})
+			}
+		}
+		spans = append(spans, finishOTLPSpans(decoded, otlpJSONAttributes(rs.Resource.Attributes))...)
+	}
+	return spans, nil
+}
+
+// ParseOTLPTracesProto decodes an ExportTraceServiceRequest in protobuf. Only
+// the fields peep stores are read; the rest are skipped, so newer exporters'
+// additions don't break ingestion.
+func ParseOTLPTracesProto(data []byte) ([]storage.Span, error) {
+	var spans []storage.Span
+	err := protoFields(data, func(field int, wire int, v uint64, b []byte) error {
+		if field != 1 || wire != protoBytes { // resource_spans
+			return nil
+		}
+		resourceSpans, err := parseProtoResourceSpans(b)
+		spans = append(spans, resourceSpans...)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("invalid OTLP protobuf: %w", err)
+	}
+	return spans, nil
+}
+
+func parseProtoResourceSpans(data []byte) ([]storage.Span, error) {
+	var resource map[string]interface{}
+	var decoded []otlpSpan
+	err := protoFields(data, func(field int, wire int, v uint64, b []byte) error {
+		if wire != protoBytes {
+			return nil
+		}
+		switch field {
+		case 1: // resource
+			return protoFields(b, func(field int, wire int, v uint64, b []byte) error {
+				if field == 1 && wire == protoBytes { // attributes
+					return protoKeyValue(b, &resource)
+				}
+				return nil
+			})
+		case 2: // scope_spans
+			return protoFields(b, func(field int, wire int, v uint64, b []byte) error {
+				if field != 2 || wire != protoBytes { // spans
+					return nil
+				}
+				span, err := parseProtoSpan(b)
+				if err != nil {
+					return err
+				}
+				decoded = append(decoded, span)
+				return nil
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return finishOTLPSpans(decoded, resource), nil
+}
+
+func parseProtoSpan(data []byte) (otlpSpan, error) {
+	span := otlpSpan{Span: storage.Span{Kind: "unspecified", Status: "unset"}}
+	err := protoFields(data, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == protoBytes:
+			span.TraceID = hex.EncodeToString(b)
+		case field == 2 && wire == protoBytes:
+			span.SpanID = hex.EncodeToString(b)
+		case field == 4 && wire == protoBytes:
+			span.ParentSpanID = hex.EncodeToString(b)
+		case field == 5 && wire == protoBytes:
+			span.Name = string(b)
+		case field == 6 && wire == protoVarint:
+			span.Kind = otlpEnum(otlpSpanKinds, int64(v))
+		case field == 7 && wire == protoFixed64:
+			span.start = v
+		case field == 8 && wire == protoFixed64:
+			span.end = v
+		case field == 9 && wire == protoBytes:
+			return protoKeyValue(b, &span.Attributes)
+		case field == 15 && wire == protoBytes:
+			return protoFields(b, func(field int, wire int, v uint64, b []byte) error {
+				switch {
+				case field == 2 && wire == protoBytes:
+					span.StatusMessage = string(b)
+				case field == 3 && wire == protoVarint:
+					span.Status = otlpEnum(otlpStatusCodes, int64(v))
+				}
+				return nil
+			})
+		}
+		return nil
+	})
+	if err == nil && (span.TraceID == "" || span.SpanID == "") {
+		err = fmt.Errorf("span %q has no trace_id or span_id", span.Name)
+	}
+	return span, err
+}
+
+// protoKeyValue decodes a KeyValue into attributes
+func protoKeyValue(data []byte, attributes *map[string]interface{}) error {
+	var key string
+	var value interface{}
+	err := protoFields(data, func(field int, wire int, v uint64, b []byte) error {
+		if wire != protoBytes {
+			return nil
+		}
+		switch field {
+		case 1:
+			key = string(b)
+		case 2:
+			var err error
+			value, err = protoAnyValue(b)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if *attributes == nil {
+		*attributes = make(map[string]interface{})
+	}
+	(*attributes)[key] = value
+	return nil
+}
+
+// protoAnyValue decodes an AnyValue into the Go value JSON would give it
+func protoAnyValue(data []byte) (interface{}, error) {
+	var value interface{}
+	err := protoFields(data, func(field int, wire int, v uint64, b []byte) error {
+		switch {
+		case field == 1 && wire == protoBytes:
+			value = string(b)
+		case field == 2 && wire == protoVarint:
+			value = v != 0
+		case field == 3 && wire == protoVarint:
+			value = int64(v)
+		case field == 4 && wire == protoFixed64:
+			value = math.Float64frombits(v)
+		case field == 5 && wire == protoBytes: // ArrayValue
+			values := []interface{}{}
+			err := protoFields(b, func(field int, wire int, v uint64, b []byte) error {
+				if field != 1 || wire != protoBytes {
+					return nil
+				}
+				item, err := protoAnyValue(b)
+				values = append(values, item)
+				return err
+			})
+			value = values
+			return err
+		case field == 6 && wire == protoBytes: // KeyValueList
+			values := map[string]interface{}{}
+			err := protoFields(b, func(field int, wire int, v uint64, b []byte) error {
+				if field != 1 || wire != protoBytes {
+					return nil
+				}
+				return protoKeyValue(b, &values)
+			})
+			value = values
+			return err
+		case field == 7 && wire == protoBytes:
+			value = hex.EncodeToString(b)
+		}
+		return nil
+	})
+	return value, err
+}
+
+// Protobuf wire types
+const (
+	protoVarint  = 0
+	protoFixed64 = 1
+	protoBytes   = 2
+	protoFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("truncated message")
+
+// protoFields calls fn with each field of a protobuf message: varints and
+// fixed-width numbers in v, length-delimited fields in b
+func protoFields(data []byte, fn func(field int, wire int, v uint64, b []byte) error) error {
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return errProtoTruncated
+		}
+		data = data[n:]
+		field, wire := int(tag>>3), int(tag&7)
+
+		var v uint64
+		var b []byte
+		switch wire {
+		case protoVarint:
+			v, n = binary.Uvarint(data)
+			if n <= 0 {
+				return errProtoTruncated
+			}
+			data = data[n:]
+		case protoFixed64:
+			if len(data) < 8 {
+				return errProtoTruncated
+			}
+			v = binary.LittleEndian.Uint64(data)
+			data = data[8:]
+		case protoFixed32:
+			if len(data) < 4 {
+				return errProtoTruncated
+			}
+			v = uint64(binary.LittleEndian.Uint32(data))
+			data = data[4:]
+		case protoBytes:
+			length, n := binary.Uvarint(data)
+			if n <= 0 || length > uint64(len(data)-n) {
+				return errProtoTruncated
+			}
+			b = data[n : n+int(length)]
+			data = data[n+int(length):]
+		default:
+			return fmt.Errorf("unsupported wire type %d", wire)
+		}
+
+		if err := fn(field, wire, v, b); err != nil {
+			return err
+		}
+	}
+	return nil
+}
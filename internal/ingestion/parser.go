@@ -2,15 +2,50 @@ package ingestion
 
 import (
 	"encoding/json"
+	"fmt"
 	"regexp"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/kylereynolds/peep/internal/storage"
 )
 
 // LogParser handles parsing different log formats
-type LogParser struct{}
+type LogParser struct {
+	// Patterns are extra formats, tried before the common one. Their named groups
+	// timestamp, level, service, and message fill in those fields.
+	Patterns []*regexp.Regexp
+
+	mu sync.RWMutex // Guards Patterns against SetPatterns while lines are parsed
+}
+
+// NewLogParser creates a parser that also understands the given regex patterns
+func NewLogParser(patterns []string) (*LogParser, error) {
+	p := &LogParser{}
+	if err := p.SetPatterns(patterns); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetPatterns replaces the extra formats, e.g. when the config is reloaded. On
+// an error the current patterns are kept.
+func (p *LogParser) SetPatterns(patterns []string) error {
+	var compiled []*regexp.Regexp
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid parser pattern %q: %w", pattern, err)
+		}
+		compiled = append(compiled, re)
+	}
+
+	p.mu.Lock()
+	p.Patterns = compiled
+	p.mu.Unlock()
+	return nil
+}
 
 // ParseLine attempts to parse a log line and extract structured information
 func (p *LogParser) ParseLine(line string) storage.LogEntry {
@@ -19,6 +54,11 @@ func (p *LogParser) ParseLine(line string) storage.LogEntry {
 		return *entry
 	}
 
+	// Then the configured patterns
+	if entry := p.tryParsePatterns(line); entry != nil {
+		return *entry
+	}
+
 	// Try common log patterns
 	if entry := p.tryParseCommonFormat(line); entry != nil {
 		return *entry
@@ -95,6 +135,61 @@ func (p *LogParser) tryParseJSON(line string) *storage.LogEntry {
 	return &entry
 }
 
+// patternTimeLayouts are tried in order on a pattern's timestamp group
+var patternTimeLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02 15:04:05.999999999Z07:00",
+	"2006-01-02 15:04:05.999999999",
+	"2006-01-02T15:04:05.999999999",
+	"02/Jan/2006:15:04:05 -0700",
+}
+
+// tryParsePatterns parses a line with the first configured pattern that matches it
+func (p *LogParser) tryParsePatterns(line string) *storage.LogEntry {
+	p.mu.RLock()
+	patterns := p.Patterns
+	p.mu.RUnlock()
+
+	for _, re := range patterns {
+		matches := re.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		entry := storage.LogEntry{
+			Timestamp: time.Now(),
+			Level:     "info",
+			Message:   line,
+			Service:   "unknown",
+			Context:   "{}",
+			RawLog:    line,
+		}
+		for i, name := range re.SubexpNames() {
+			value := matches[i]
+			if value == "" {
+				continue
+			}
+			switch name {
+			case "timestamp":
+				for _, layout := range patternTimeLayouts {
+					if t, err := time.Parse(layout, value); err == nil {
+						entry.Timestamp = t
+						break
+					}
+				}
+			case "level":
+				entry.Level = strings.ToLower(value)
+			case "service":
+				entry.Service = value
+			case "message":
+				entry.Message = value
+			}
+		}
+		return &entry
+	}
+	return nil
+}
+
 func (p *LogParser) tryParseCommonFormat(line string) *storage.LogEntry {
 	// Common patterns like: "2023-08-06 10:30:45 INFO [service] message"
 	patterns := []struct {
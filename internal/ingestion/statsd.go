@@ -0,0 +1,348 @@
+package ingestion
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// DefaultStatsDFlushInterval is how often StatsD samples are aggregated into
+// metrics when FlushInterval isn't set, the same as StatsD's own default
+const DefaultStatsDFlushInterval = 10 * time.Second
+
+// statsdPercentiles are stored for every timer, as name.p50, name.p90, ...
+var statsdPercentiles = []int{50, 90, 95, 99}
+
+// StatsDListener receives StatsD metrics over UDP and stores them, aggregated,
+// every FlushInterval:
+//
+//   - Counters (c) as name, the total for the interval, and name.rate, per second
+//   - Gauges (g) as name, the latest value; +N and -N adjust the last one
+//   - Timers and histograms (ms, h, d) as name.count, .mean, .min, .max, .p50,
+//     .p90, .p95, and .p99
+//   - Sets (s) as name, the number of distinct values seen in the interval
+//
+// Sample rates (|@0.1) scale counters and timer counts, and DogStatsD tags
+// (|#env:prod,region:eu) become labels.
+type StatsDListener struct {
+	Addr          string // e.g. ":8125"
+	FlushInterval time.Duration
+
+	// Store saves each interval's aggregated metrics
+	Store func(metrics []storage.Metric) error
+
+	// OnError reports malformed lines and storage errors; they never stop the listener
+	OnError func(err error)
+
+	conn net.PacketConn
+
+	mu       sync.Mutex
+	counters map[string]*statsdSeries
+	gauges   map[string]*statsdSeries // Kept across intervals, so +N and -N have a base
+	timers   map[string]*statsdSeries
+	sets     map[string]*statsdSeries
+	updated  map[string]bool // Gauges set since the last flush
+	invalid  int             // Malformed lines since the last flush
+	example  string          // One of them, for the error
+	flushed  time.Time       // When the interval started, for counter rates
+}
+
+// statsdSeries is one metric's samples within an interval
+type statsdSeries struct {
+	name   string
+	labels map[string]string
+	sum    float64 // Counters: the scaled total; gauges: the value
+	count  float64 // Timers: the scaled number of samples
+	values []float64
+	unique map[string]struct{}
+}
+
+// Listen binds l.Addr over UDP
+func (l *StatsDListener) Listen() error {
+	conn, err := net.ListenPacket("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+	l.conn = conn
+	return nil
+}
+
+// Serve receives metrics until ctx is cancelled, then stores what's been
+// aggregated so far. It calls Listen first if it hasn't been.
+func (l *StatsDListener) Serve(ctx context.Context) error {
+	if l.conn == nil {
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+	l.reset()
+
+	interval := l.FlushInterval
+	if interval <= 0 {
+		interval = DefaultStatsDFlushInterval
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				l.conn.Close()
+				return
+			case <-ticker.C:
+				l.flush()
+			}
+		}
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := l.conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				l.flush()
+				return nil
+			}
+			l.conn.Close()
+			return err
+		}
+
+		// A datagram may hold several metrics on separate lines
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line != "" {
+				l.add(line)
+			}
+		}
+	}
+}
+
+func (l *StatsDListener) reset() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counters = make(map[string]*statsdSeries)
+	l.gauges = make(map[string]*statsdSeries)
+	l.timers = make(map[string]*statsdSeries)
+	l.sets = make(map[string]*statsdSeries)
+	l.updated = make(map[string]bool)
+	l.flushed = time.Now()
+}
+
+// add parses one line, name:value|type[|@rate][|#tags], into the current interval
+func (l *StatsDListener) add(line string) {
+	sample, err := ParseStatsD(line)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if err != nil {
+		l.invalid++
+		l.example = line
+		return
+	}
+
+	key := sample.key()
+	switch sample.Type {
+	case "c":
+		series := l.series(l.counters, key, sample)
+		series.sum += sample.Value / sample.Rate
+	case "g":
+		series := l.series(l.gauges, key, sample)
+		if sample.Delta {
+			series.sum += sample.Value
+		} else {
+			series.sum = sample.Value
+		}
+		l.updated[key] = true
+	case "ms", "h", "d":
+		series := l.series(l.timers, key, sample)
+		series.values = append(series.values, sample.Value)
+		series.count += 1 / sample.Rate
+	case "s":
+		series := l.series(l.sets, key, sample)
+		if series.unique == nil {
+			series.unique = make(map[string]struct{})
+		}
+		series.unique[sample.Member] = struct{}{}
+	}
+}
+
+func (l *StatsDListener) series(m map[string]*statsdSeries, key string, sample StatsDSample) *statsdSeries {
+	series := m[key]
+	if series == nil {
+		series = &statsdSeries{name: sample.Name, labels: sample.Labels}
+		m[key] = series
+	}
+	return series
+}
+
+// flush stores the interval's aggregates and starts a new interval
+func (l *StatsDListener) flush() {
+	now := time.Now()
+
+	l.mu.Lock()
+	elapsed := now.Sub(l.flushed)
+	l.flushed = now
+	var metrics []storage.Metric
+	sample := func(name string, labels map[string]string, value float64) {
+		metrics = append(metrics, storage.Metric{Timestamp: now, Name: name, Labels: labels, Value: value})
+	}
+
+	for _, series := range l.counters {
+		sample(series.name, series.labels, series.sum)
+		if elapsed > 0 {
+			sample(series.name+".rate", series.labels, series.sum/elapsed.Seconds())
+		}
+	}
+	for key := range l.updated {
+		series := l.gauges[key]
+		sample(series.name, series.labels, series.sum)
+	}
+	for _, series := range l.timers {
+		values := series.values
+		sort.Float64s(values)
+		total := 0.0
+		for _, v := range values {
+			total += v
+		}
+		sample(series.name+".count", series.labels, series.count)
+		sample(series.name+".mean", series.labels, total/float64(len(values)))
+		sample(series.name+".min", series.labels, values[0])
+		sample(series.name+".max", series.labels, values[len(values)-1])
+		for _, p := range statsdPercentiles {
+			sample(fmt.Sprintf("%s.p%d", series.name, p), series.labels, percentile(values, p))
+		}
+	}
+	for _, series := range l.sets {
+		sample(series.name, series.labels, float64(len(series.unique)))
+	}
+
+	invalid, example := l.invalid, l.example
+	l.counters = make(map[string]*statsdSeries)
+	l.timers = make(map[string]*statsdSeries)
+	l.sets = make(map[string]*statsdSeries)
+	l.updated = make(map[string]bool)
+	l.invalid, l.example = 0, ""
+	l.mu.Unlock()
+
+	// Reported once per interval, so a misconfigured client doesn't flood the log
+	if invalid > 0 {
+		l.report(fmt.Errorf("dropped %d malformed StatsD lines, e.g. %q", invalid, example))
+	}
+	if len(metrics) == 0 {
+		return
+	}
+	if err := l.Store(metrics); err != nil {
+		l.report(fmt.Errorf("failed to store %d metrics: %w", len(metrics), err))
+	}
+}
+
+func (l *StatsDListener) report(err error) {
+	if l.OnError != nil {
+		l.OnError(err)
+	}
+}
+
+// percentile returns the nearest-rank percentile of sorted values
+func percentile(sorted []float64, p int) float64 {
+	rank := int(math.Ceil(float64(p) / 100 * float64(len(sorted))))
+	if rank < 1 {
+		rank = 1
+	}
+	return sorted[rank-1]
+}
+
+// StatsDSample is one parsed StatsD line
+type StatsDSample struct {
+	Name   string
+	Type   string // c, g, ms, h, d, or s
+	Value  float64
+	Member string  // A set's value, which needn't be a number
+	Delta  bool    // A gauge given as +N or -N
+	Rate   float64 // The sample rate, 1 when not given
+	Labels map[string]string
+}
+
+// key tells series apart by type, name, and labels
+func (s StatsDSample) key() string {
+	keys := make([]string, 0, len(s.Labels))
+	for k := range s.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.Type + "|" + s.Name)
+	for _, k := range keys {
+		b.WriteString("|" + k + "=" + s.Labels[k])
+	}
+	return b.String()
+}
+
+// ParseStatsD parses a StatsD line, name:value|type[|@rate][|#tags], as
+// sent by StatsD and DogStatsD clients
+func ParseStatsD(line string) (StatsDSample, error) {
+	sample := StatsDSample{Rate: 1}
+
+	name, rest, ok := strings.Cut(line, ":")
+	if !ok || name == "" || strings.ContainsAny(name, " \t|#") {
+		return sample, fmt.Errorf("expected name:value|type")
+	}
+	sample.Name = name
+
+	fields := strings.Split(rest, "|")
+	if len(fields) < 2 {
+		return sample, fmt.Errorf("expected name:value|type")
+	}
+	value := fields[0]
+	sample.Type = fields[1]
+
+	for _, field := range fields[2:] {
+		switch {
+		case strings.HasPrefix(field, "@"):
+			rate, err := strconv.ParseFloat(field[1:], 64)
+			if err != nil || rate <= 0 || rate > 1 {
+				return sample, fmt.Errorf("invalid sample rate %q", field)
+			}
+			sample.Rate = rate
+		case strings.HasPrefix(field, "#"):
+			sample.Labels = make(map[string]string)
+			for _, tag := range strings.Split(field[1:], ",") {
+				if tag == "" {
+					continue
+				}
+				k, v, _ := strings.Cut(tag, ":")
+				sample.Labels[k] = v
+			}
+		}
+		// Other extensions, such as DogStatsD's |c:container, are ignored
+	}
+
+	switch sample.Type {
+	case "s":
+		sample.Member = value
+		return sample, nil
+	case "c", "g", "ms", "h", "d":
+	default:
+		return sample, fmt.Errorf("unknown metric type %q", sample.Type)
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return sample, fmt.Errorf("invalid value %q", value)
+	}
+	sample.Value = v
+	sample.Delta = sample.Type == "g" && (strings.HasPrefix(value, "+") || strings.HasPrefix(value, "-"))
+	return sample, nil
+}
@@ -0,0 +1,300 @@
+package ingestion
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// syslogSeverities maps a syslog severity (0-7) to a level
+var syslogSeverities = [8]string{"error", "error", "error", "error", "warn", "info", "info", "debug"}
+
+// syslogSeverityNames are the severities' own names, kept in the context
+var syslogSeverityNames = [8]string{"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug"}
+
+// ParseSyslog parses an RFC 5424 or RFC 3164 (BSD) syslog message. The
+// severity becomes the level, the app name or tag the service, and the
+// hostname, facility, and process ID go into the context. It reports false
+// for lines that don't start with a <PRI> header.
+func ParseSyslog(line string) (storage.LogEntry, bool) {
+	line = strings.TrimRight(line, "\r\n\x00")
+	if !strings.HasPrefix(line, "<") {
+		return storage.LogEntry{}, false
+	}
+	end := strings.IndexByte(line, '>')
+	if end < 2 || end > 4 {
+		return storage.LogEntry{}, false
+	}
+	pri, err := strconv.Atoi(line[1:end])
+	if err != nil || pri < 0 || pri > 191 {
+		return storage.LogEntry{}, false
+	}
+	rest := line[end+1:]
+
+	fields := map[string]interface{}{
+		"facility": pri / 8,
+		"severity": syslogSeverityNames[pri%8],
+	}
+	entry := storage.LogEntry{
+		Level:   syslogSeverities[pri%8],
+		Service: "syslog",
+		RawLog:  line,
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		parseSyslog5424(rest[2:], &entry, fields)
+	} else {
+		parseSyslog3164(rest, &entry, fields)
+	}
+
+	if entry.Timestamp.IsZero() {
+		entry.Timestamp = time.Now()
+	}
+	context, _ := json.Marshal(fields)
+	entry.Context = string(context)
+	return entry, true
+}
+
+// parseSyslog5424 parses what follows "<PRI>1 ":
+// TIMESTAMP HOSTNAME APP-NAME PROCID MSGID STRUCTURED-DATA MSG
+func parseSyslog5424(rest string, entry *storage.LogEntry, fields map[string]interface{}) {
+	parts := strings.SplitN(rest, " ", 6)
+	for len(parts) < 6 {
+		parts = append(parts, "-")
+	}
+	timestamp, host, app, procID, msgID, remainder := parts[0], parts[1], parts[2], parts[3], parts[4], parts[5]
+
+	if t, err := time.Parse(time.RFC3339Nano, timestamp); err == nil {
+		entry.Timestamp = t
+	}
+	setSyslogField(fields, "hostname", host)
+	setSyslogField(fields, "procid", procID)
+	setSyslogField(fields, "msgid", msgID)
+	if app != "-" && app != "" {
+		entry.Service = app
+	}
+
+	// Structured data is "-" or one or more [id key="value" ...] elements
+	message := remainder
+	if strings.HasPrefix(remainder, "-") {
+		message = strings.TrimPrefix(remainder[1:], " ")
+	} else if strings.HasPrefix(remainder, "[") {
+		if i := structuredDataEnd(remainder); i > 0 {
+			fields["structured_data"] = remainder[:i]
+			message = strings.TrimPrefix(remainder[i:], " ")
+		}
+	}
+	entry.Message = strings.TrimPrefix(message, "\ufeff") // The BOM marks UTF-8 messages
+}
+
+// structuredDataEnd returns the length of the structured data elements at the
+// start of s, allowing for escaped brackets and quotes in values
+func structuredDataEnd(s string) int {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '\\':
+			i++
+		case '"':
+			inQuotes = !inQuotes
+		case ']':
+			if !inQuotes && (i+1 == len(s) || s[i+1] != '[') {
+				return i + 1
+			}
+		}
+	}
+	return -1
+}
+
+// parseSyslog3164 parses what follows "<PRI>" in a BSD syslog message:
+// Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG
+func parseSyslog3164(rest string, entry *storage.LogEntry, fields map[string]interface{}) {
+	const stampLen = len("Jan _2 15:04:05")
+	if len(rest) > stampLen && rest[stampLen] == ' ' {
+		if t, err := time.ParseInLocation(time.Stamp, rest[:stampLen], time.Local); err == nil {
+			now := time.Now()
+			t = t.AddDate(now.Year(), 0, 0)
+			// A December message read in January is from last year
+			if t.After(now.Add(24 * time.Hour)) {
+				t = t.AddDate(-1, 0, 0)
+			}
+			entry.Timestamp = t
+			rest = rest[stampLen+1:]
+
+			if host, after, ok := strings.Cut(rest, " "); ok && !strings.HasSuffix(host, ":") {
+				setSyslogField(fields, "hostname", host)
+				rest = after
+			}
+		}
+	}
+
+	// The tag is the program name, optionally with a PID, ending in a colon
+	if tag, message, ok := strings.Cut(rest, ": "); ok && tag != "" && !strings.ContainsAny(tag, " \t") {
+		if open := strings.IndexByte(tag, '['); open > 0 && strings.HasSuffix(tag, "]") {
+			setSyslogField(fields, "procid", tag[open+1:len(tag)-1])
+			tag = tag[:open]
+		}
+		entry.Service = tag
+		rest = message
+	}
+	entry.Message = rest
+}
+
+func setSyslogField(fields map[string]interface{}, key, value string) {
+	if value != "" && value != "-" {
+		fields[key] = value
+	}
+}
+
+// SyslogListener receives syslog messages over UDP and TCP on the same address.
+// TCP accepts both newline-delimited and octet-counted (RFC 6587) framing.
+// Messages without a syslog header are parsed like any other log line.
+type SyslogListener struct {
+	Addr   string // e.g. ":5514"
+	Parser *LogParser
+
+	// Handle stores a parsed message; line is the raw text for filters that match on it
+	Handle func(entry storage.LogEntry, line string) error
+
+	// OnError reports connection and storage errors; they never stop the listener
+	OnError func(err error)
+
+	udp net.PacketConn
+	tcp *TCPListener
+}
+
+// Listen binds l.Addr over both UDP and TCP, closing the UDP socket if TCP fails
+func (l *SyslogListener) Listen() error {
+	udp, err := net.ListenPacket("udp", l.Addr)
+	if err != nil {
+		return err
+	}
+
+	l.tcp = &TCPListener{Addr: l.Addr, Parser: l.Parser, Handle: l.Handle, OnError: l.OnError, readLines: readSyslogFrames, parse: l.parse}
+	if err := l.tcp.Listen(); err != nil {
+		udp.Close()
+		return err
+	}
+	l.udp = udp
+	return nil
+}
+
+// Serve receives messages until ctx is cancelled. It calls Listen first if it hasn't been.
+func (l *SyslogListener) Serve(ctx context.Context) error {
+	if l.udp == nil {
+		if err := l.Listen(); err != nil {
+			return err
+		}
+	}
+
+	// Stop the TCP side too if reading UDP fails
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	var tcpErr error
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		tcpErr = l.tcp.Serve(ctx)
+	}()
+
+	go func() {
+		<-ctx.Done()
+		l.udp.Close()
+	}()
+
+	buf := make([]byte, 64*1024)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			stopped := ctx.Err() != nil
+			cancel()
+			wg.Wait()
+			if stopped {
+				return tcpErr
+			}
+			return err
+		}
+
+		// A datagram may hold several messages on separate lines
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			if err := l.Handle(l.parse(line), line); err != nil && l.OnError != nil {
+				l.OnError(err)
+			}
+		}
+	}
+}
+
+func (l *SyslogListener) parse(line string) storage.LogEntry {
+	if entry, ok := ParseSyslog(line); ok {
+		return entry
+	}
+	return l.Parser.ParseLine(line)
+}
+
+// readSyslogFrames calls fn with each message on a syslog TCP connection,
+// telling octet-counted frames ("57 <34>1 ...") from newline-delimited ones
+func readSyslogFrames(r io.Reader, fn func(line string)) error {
+	reader := bufio.NewReaderSize(r, 64*1024)
+	for {
+		first, err := reader.Peek(1)
+		if err != nil {
+			return ignoreEOF(err)
+		}
+
+		if first[0] >= '1' && first[0] <= '9' {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return ignoreEOF(err)
+			}
+			n, convErr := strconv.Atoi(strings.TrimSpace(length))
+			if convErr != nil {
+				// Not a frame length, just a line starting with a digit
+				rest, err := reader.ReadString('\n')
+				fn(strings.TrimRight(length+rest, "\r\n"))
+				if err != nil {
+					return ignoreEOF(err)
+				}
+				continue
+			}
+			if n > maxLineSize {
+				return fmt.Errorf("syslog frame of %d bytes is too long", n)
+			}
+			frame := make([]byte, n)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return ignoreEOF(err)
+			}
+			fn(string(frame))
+			continue
+		}
+
+		line, err := reader.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); strings.TrimSpace(line) != "" {
+			fn(line)
+		}
+		if err != nil {
+			return ignoreEOF(err)
+		}
+	}
+}
+
+func ignoreEOF(err error) error {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,321 @@
+package ingestion
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Webhook maps the JSON a service POSTs to /hooks/NAME, such as a Stripe
+// event, a CI build notification, or an uptime monitor's alert, onto logs:
+//
+//	hooks:
+//	  - name: github-actions
+//	    secret: s3cret          # Sent as ?token=s3cret
+//	    service: ci
+//	    message: '{$.workflow_run.name} {$.workflow_run.conclusion} on {$.repository.full_name}'
+//	    level: $.workflow_run.conclusion
+//	    levels: {failure: error, cancelled: warn, success: info}
+//	    context:
+//	      url: $.workflow_run.html_url
+//
+// A value starting with $ is a JSONPath into the event ($.a.b, $.items[0],
+// $['odd-key']); any other value is text in which each {$.path} is replaced
+// by what's found there.
+type Webhook struct {
+	Name string `yaml:"name"` // The last part of the URL
+
+	// Secret, if set, must be sent as the token query parameter or in the
+	// X-Peep-Token header, and lets the service post without logging in
+	Secret string `yaml:"secret"`
+
+	// Events is the path to an array of events, for services that send
+	// several at once; without it the whole payload is one event
+	Events string `yaml:"events"`
+
+	Timestamp string `yaml:"timestamp"` // RFC 3339 or Unix seconds or milliseconds; default: when it arrived
+	Level     string `yaml:"level"`     // Default: info
+	Message   string `yaml:"message"`   // Default: the event as JSON
+	Service   string `yaml:"service"`   // Default: the hook's name
+
+	// Levels translates the level found in the event, e.g. down: error.
+	// Values that aren't listed are kept as they are.
+	Levels map[string]string `yaml:"levels"`
+
+	// Context fields stored with each log; default: the whole event
+	Context map[string]string `yaml:"context"`
+}
+
+// webhookNamePattern keeps hook names usable in a URL path
+var webhookNamePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidateWebhooks checks hook names are unique and their paths parse
+func ValidateWebhooks(hooks []Webhook) error {
+	names := make(map[string]bool, len(hooks))
+	for _, hook := range hooks {
+		if !webhookNamePattern.MatchString(hook.Name) {
+			return fmt.Errorf("invalid hook name %q: use letters, digits, '-', '_', and '.'", hook.Name)
+		}
+		if names[hook.Name] {
+			return fmt.Errorf("hook %q is defined twice", hook.Name)
+		}
+		names[hook.Name] = true
+
+		values := []string{hook.Events, hook.Timestamp, hook.Level, hook.Message, hook.Service}
+		for _, value := range hook.Context {
+			values = append(values, value)
+		}
+		for _, value := range values {
+			if err := checkMapping(value); err != nil {
+				return fmt.Errorf("hook %s: %w", hook.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// checkMapping parses a value's paths without an event to look them up in
+func checkMapping(value string) error {
+	if strings.HasPrefix(value, "$") {
+		_, err := parseJSONPath(value)
+		return err
+	}
+	for _, match := range placeholderPattern.FindAllStringSubmatch(value, -1) {
+		if _, err := parseJSONPath(match[1]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Map turns a payload into the logs it describes
+func (h Webhook) Map(body []byte, received time.Time) ([]storage.LogEntry, error) {
+	var payload interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("payload isn't JSON: %w", err)
+	}
+
+	events := []interface{}{payload}
+	if h.Events != "" {
+		found, err := lookupJSONPath(payload, h.Events)
+		if err != nil {
+			return nil, err
+		}
+		list, ok := found.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s isn't an array in the payload", h.Events)
+		}
+		events = list
+	}
+
+	entries := make([]storage.LogEntry, 0, len(events))
+	for _, event := range events {
+		entries = append(entries, h.mapEvent(event, received))
+	}
+	return entries, nil
+}
+
+// mapEvent builds one log from an event
+func (h Webhook) mapEvent(event interface{}, received time.Time) storage.LogEntry {
+	raw, _ := json.Marshal(event)
+	entry := storage.LogEntry{
+		Timestamp: received,
+		Level:     "info",
+		Message:   string(raw),
+		Service:   h.Name,
+		RawLog:    string(raw),
+	}
+
+	if h.Timestamp != "" {
+		if found, err := lookupJSONPath(event, h.Timestamp); err == nil {
+			if t, ok := parseEventTime(found); ok {
+				entry.Timestamp = t
+			}
+		}
+	}
+	if h.Level != "" {
+		if level := mapValue(event, h.Level); level != "" {
+			if mapped, ok := h.Levels[level]; ok {
+				level = mapped
+			}
+			entry.Level = strings.ToLower(level)
+		}
+	}
+	if h.Message != "" {
+		if message := mapValue(event, h.Message); message != "" {
+			entry.Message = message
+		}
+	}
+	if h.Service != "" {
+		if service := mapValue(event, h.Service); service != "" {
+			entry.Service = service
+		}
+	}
+
+	context := map[string]interface{}{}
+	if len(h.Context) == 0 {
+		if fields, ok := event.(map[string]interface{}); ok {
+			context = fields
+		} else {
+			context["event"] = event
+		}
+	}
+	for field, value := range h.Context {
+		if strings.HasPrefix(value, "$") {
+			if found, err := lookupJSONPath(event, value); err == nil && found != nil {
+				context[field] = found
+			}
+			continue
+		}
+		context[field] = mapValue(event, value)
+	}
+	context["hook"] = h.Name
+	if data, err := json.Marshal(context); err == nil {
+		entry.Context = string(data)
+	}
+	return entry
+}
+
+// placeholderPattern finds the {$.path} placeholders in a text value
+var placeholderPattern = regexp.MustCompile(`\{(\$[^{}]*)\}`)
+
+// mapValue resolves a mapping value against an event as text. Paths that
+// aren't found resolve to "".
+func mapValue(event interface{}, value string) string {
+	if strings.HasPrefix(value, "$") {
+		found, err := lookupJSONPath(event, value)
+		if err != nil {
+			return ""
+		}
+		return jsonText(found)
+	}
+	return placeholderPattern.ReplaceAllStringFunc(value, func(placeholder string) string {
+		found, err := lookupJSONPath(event, placeholder[1:len(placeholder)-1])
+		if err != nil {
+			return ""
+		}
+		return jsonText(found)
+	})
+}
+
+// jsonText prints a JSON value: strings as they are, numbers without
+// exponents, and objects and arrays as JSON
+func jsonText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+}
+
+// parseEventTime reads an RFC 3339 time, or Unix seconds or milliseconds
+func parseEventTime(value interface{}) (time.Time, bool) {
+	var n float64
+	switch v := value.(type) {
+	case string:
+		if t, err := time.Parse(time.RFC3339Nano, v); err == nil {
+			return t, true
+		}
+		f, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return time.Time{}, false
+		}
+		n = f
+	case float64:
+		n = v
+	default:
+		return time.Time{}, false
+	}
+	if n <= 0 {
+		return time.Time{}, false
+	}
+	if n > 1e12 { // Milliseconds
+		return time.UnixMilli(int64(n)), true
+	}
+	return time.Unix(int64(n), int64((n-float64(int64(n)))*1e9)), true
+}
+
+// parseJSONPath splits a path like $.data.object['customer-id'][0] into its
+// steps: object keys as strings and array indexes as ints
+func parseJSONPath(path string) ([]interface{}, error) {
+	rest, ok := strings.CutPrefix(path, "$")
+	if !ok {
+		return nil, fmt.Errorf("path %q must start with $", path)
+	}
+
+	var steps []interface{}
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("path %q has an empty key", path)
+			}
+			steps = append(steps, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("path %q has an unclosed [", path)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				steps = append(steps, inner[1:len(inner)-1])
+				continue
+			}
+			index, err := strconv.Atoi(inner)
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("path %q: [%s] isn't an array index or a quoted key", path, inner)
+			}
+			steps = append(steps, index)
+		default:
+			return nil, fmt.Errorf("path %q: expected . or [ before %q", path, rest)
+		}
+	}
+	return steps, nil
+}
+
+// lookupJSONPath finds the value at a path in decoded JSON
+func lookupJSONPath(value interface{}, path string) (interface{}, error) {
+	steps, err := parseJSONPath(path)
+	if err != nil {
+		return nil, err
+	}
+	for _, step := range steps {
+		switch step := step.(type) {
+		case string:
+			object, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+			if value, ok = object[step]; !ok {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+		case int:
+			array, ok := value.([]interface{})
+			if !ok || step >= len(array) {
+				return nil, fmt.Errorf("%s not found", path)
+			}
+			value = array[step]
+		}
+	}
+	return value, nil
+}
@@ -0,0 +1,280 @@
+// Package logql parses the log-query subset of Grafana Loki's LogQL and translates
+// it to SQL over Peep's logs table, so Loki clients can read Peep data.
+//
+// Supported: stream selectors ({service="api", level=~"warn|error"}) with the
+// =, !=, =~ and !~ matchers, followed by any number of line filters (|= "text",
+// != "text", |~ "regex", !~ "regex"). Parser stages and metric queries are not.
+package logql
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Labels maps the stream labels Peep exposes to their columns in the logs table
+var Labels = map[string]string{
+	"level":   "level",
+	"service": "service",
+}
+
+// LabelNames lists the exposed labels in a stable order
+var LabelNames = []string{"level", "service"}
+
+// LineColumn is the column log lines come from, and that line filters match against
+const LineColumn = "raw_log"
+
+// Matcher is one label matcher in a stream selector
+type Matcher struct {
+	Label string
+	Op    string // =, !=, =~, or !~
+	Value string
+}
+
+// LineFilter is one line filter stage
+type LineFilter struct {
+	Op    string // |=, !=, |~, or !~
+	Value string
+}
+
+// Query is a parsed log query
+type Query struct {
+	Matchers []Matcher
+	Filters  []LineFilter
+}
+
+var (
+	identPattern  = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*`)
+	matcherOps    = []string{"=~", "!~", "!=", "="}
+	lineFilterOps = []string{"|=", "!=", "|~", "!~"}
+)
+
+// parser walks the query string; pos is the index of the next unread byte
+type parser struct {
+	input string
+	pos   int
+}
+
+// Parse parses a LogQL log query
+func Parse(query string) (*Query, error) {
+	p := &parser{input: query}
+	q := &Query{}
+
+	p.skipSpace()
+	if !p.consume("{") {
+		if ident := identPattern.FindString(p.rest()); ident != "" {
+			return nil, fmt.Errorf("metric queries like %s(...) are not supported, only log queries", ident)
+		}
+		return nil, p.errorf("expected '{' to start a stream selector")
+	}
+
+	for {
+		p.skipSpace()
+		if p.consume("}") {
+			break
+		}
+		if len(q.Matchers) > 0 {
+			if !p.consume(",") {
+				return nil, p.errorf("expected ',' or '}'")
+			}
+			p.skipSpace()
+		}
+
+		m, err := p.matcher()
+		if err != nil {
+			return nil, err
+		}
+		q.Matchers = append(q.Matchers, m)
+	}
+
+	for {
+		p.skipSpace()
+		if p.pos == len(p.input) {
+			break
+		}
+
+		op := p.oneOf(lineFilterOps)
+		if op == "" {
+			if strings.HasPrefix(p.rest(), "|") {
+				return nil, p.errorf("only line filters (|=, !=, |~, !~) are supported after the selector")
+			}
+			return nil, p.errorf("expected a line filter")
+		}
+		p.skipSpace()
+
+		value, err := p.str()
+		if err != nil {
+			return nil, err
+		}
+		q.Filters = append(q.Filters, LineFilter{Op: op, Value: value})
+	}
+
+	return q, q.validate()
+}
+
+func (p *parser) matcher() (Matcher, error) {
+	label := identPattern.FindString(p.rest())
+	if label == "" {
+		return Matcher{}, p.errorf("expected a label name")
+	}
+	p.pos += len(label)
+	p.skipSpace()
+
+	op := p.oneOf(matcherOps)
+	if op == "" {
+		return Matcher{}, p.errorf("expected =, !=, =~, or !~ after %s", label)
+	}
+	p.skipSpace()
+
+	value, err := p.str()
+	if err != nil {
+		return Matcher{}, err
+	}
+	return Matcher{Label: label, Op: op, Value: value}, nil
+}
+
+// str reads a double-quoted string with Go escapes, or a backtick-quoted raw string
+func (p *parser) str() (string, error) {
+	rest := p.rest()
+	if rest == "" || (rest[0] != '"' && rest[0] != '`') {
+		return "", p.errorf("expected a quoted string")
+	}
+
+	quote := rest[0]
+	for i := 1; i < len(rest); i++ {
+		if quote == '"' && rest[i] == '\\' {
+			i++
+			continue
+		}
+		if rest[i] == quote {
+			value, err := strconv.Unquote(rest[:i+1])
+			if err != nil {
+				return "", p.errorf("invalid string %s", rest[:i+1])
+			}
+			p.pos += i + 1
+			return value, nil
+		}
+	}
+	return "", p.errorf("unterminated string")
+}
+
+func (p *parser) oneOf(options []string) string {
+	for _, option := range options {
+		if p.consume(option) {
+			return option
+		}
+	}
+	return ""
+}
+
+func (p *parser) consume(token string) bool {
+	if strings.HasPrefix(p.rest(), token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *parser) skipSpace() {
+	for p.pos < len(p.input) && strings.ContainsRune(" \t\r\n", rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *parser) rest() string {
+	return p.input[p.pos:]
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("parse error at position %d: %s", p.pos, fmt.Sprintf(format, args...))
+}
+
+// validate checks that every regex compiles, so bad patterns fail before reaching SQLite
+func (q *Query) validate() error {
+	for _, m := range q.Matchers {
+		if m.Op == "=~" || m.Op == "!~" {
+			if _, err := regexp.Compile(anchor(m.Value)); err != nil {
+				return fmt.Errorf("invalid regex for %s: %v", m.Label, err)
+			}
+		}
+	}
+	for _, f := range q.Filters {
+		if f.Op == "|~" || f.Op == "!~" {
+			if _, err := regexp.Compile(f.Value); err != nil {
+				return fmt.Errorf("invalid line filter regex: %v", err)
+			}
+		}
+	}
+	return nil
+}
+
+// anchor makes a label regex match the whole value, as Loki's do
+func anchor(pattern string) string {
+	return "^(?:" + pattern + ")$"
+}
+
+// Where returns SQL conditions, to append to "WHERE 1=1", and their arguments.
+// Matching uses the REGEXP function Peep registers on its SQLite connections.
+func (q *Query) Where() (string, []interface{}) {
+	var where strings.Builder
+	var args []interface{}
+
+	for _, m := range q.Matchers {
+		column, known := Labels[m.Label]
+		if !known {
+			// Labels Peep doesn't have are empty on every stream, as in Loki
+			if !m.matches("") {
+				where.WriteString(" AND 0")
+			}
+			continue
+		}
+
+		value := "COALESCE(" + column + ", '')"
+		switch m.Op {
+		case "=":
+			where.WriteString(" AND " + value + " = ?")
+			args = append(args, m.Value)
+		case "!=":
+			where.WriteString(" AND " + value + " != ?")
+			args = append(args, m.Value)
+		case "=~":
+			where.WriteString(" AND " + value + " REGEXP ?")
+			args = append(args, anchor(m.Value))
+		case "!~":
+			where.WriteString(" AND NOT " + value + " REGEXP ?")
+			args = append(args, anchor(m.Value))
+		}
+	}
+
+	for _, f := range q.Filters {
+		switch f.Op {
+		case "|=":
+			where.WriteString(" AND instr(" + LineColumn + ", ?) > 0")
+		case "!=":
+			where.WriteString(" AND instr(" + LineColumn + ", ?) = 0")
+		case "|~":
+			where.WriteString(" AND " + LineColumn + " REGEXP ?")
+		case "!~":
+			where.WriteString(" AND NOT " + LineColumn + " REGEXP ?")
+		}
+		args = append(args, f.Value)
+	}
+
+	return where.String(), args
+}
+
+// matches evaluates the matcher against a single value
+func (m Matcher) matches(value string) bool {
+	switch m.Op {
+	case "=":
+		return value == m.Value
+	case "!=":
+		return value != m.Value
+	case "=~":
+		return regexp.MustCompile(anchor(m.Value)).MatchString(value)
+	case "!~":
+		return !regexp.MustCompile(anchor(m.Value)).MatchString(value)
+	}
+	return false
+}
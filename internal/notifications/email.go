@@ -1,12 +1,36 @@
 package notifications
 
 import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	htmltemplate "html/template"
+	"net"
 	"net/smtp"
+	"os"
+	"strconv"
 	"strings"
+	texttemplate "text/template"
 	"time"
 )
 
+// SMTP connection security modes
+const (
+	SMTPSecurityAuto     = ""         // Implicit TLS on port 465, otherwise STARTTLS when the server offers it
+	SMTPSecurityTLS      = "tls"      // Implicit TLS from the first byte (usually port 465)
+	SMTPSecurityStartTLS = "starttls" // Plain connection upgraded with STARTTLS; fails if unsupported
+	SMTPSecurityNone     = "none"     // Plaintext, never upgraded (local relays only)
+)
+
+// SMTP authentication mechanisms
+const (
+	SMTPAuthPlain   = "plain"
+	SMTPAuthLogin   = "login"
+	SMTPAuthCRAMMD5 = "cram-md5"
+	SMTPAuthNone    = "none"
+)
+
 type EmailConfig struct {
 	SMTPHost  string
 	SMTPPort  int
@@ -15,7 +39,52 @@ type EmailConfig struct {
 	FromEmail string
 	FromName  string
 	ToEmails  []string
-	UseTLS    bool
+	UseTLS    bool // Legacy: equivalent to Security "starttls" when Security is empty
+
+	Security           string // One of the SMTPSecurity* modes
+	AuthMethod         string // One of the SMTPAuth* mechanisms; defaults to plain when a username is set
+	CAFile             string // PEM bundle used to verify the server instead of the system roots
+	InsecureSkipVerify bool   // Skip server certificate verification (self-signed relays)
+
+	// Optional per-channel overrides. Template paths point at Go template files
+	// rendered with EmailTemplateData; SubjectTemplate is an inline template.
+	HTMLTemplatePath string
+	TextTemplatePath string
+	SubjectTemplate  string
+
+	// DashboardURL is linked from every email (default: http://localhost:8080)
+	DashboardURL string
+}
+
+// EmailLogLine is a sample log included in alert emails
+type EmailLogLine struct {
+	Timestamp time.Time
+	Level     string
+	Service   string
+	Message   string
+}
+
+// EmailAlert holds the details of a single alert rendered into an email
+type EmailAlert struct {
+	RuleName    string
+	Description string
+	Query       string
+	Count       int
+	Threshold   int
+	Severity    string
+	FiredAt     time.Time
+	Labels      map[string]string
+	SampleLogs  []EmailLogLine
+}
+
+// EmailTemplateData is passed to the subject, HTML, and plaintext templates
+type EmailTemplateData struct {
+	Title        string
+	Severity     string
+	Alerts       []EmailAlert
+	Digest       bool
+	DashboardURL string
+	GeneratedAt  time.Time
 }
 
 type EmailNotification struct {
@@ -23,6 +92,11 @@ type EmailNotification struct {
 }
 
 func NewEmailNotification(config EmailConfig) *EmailNotification {
+	if config.DashboardURL == "" {
+		config.DashboardURL = "http://localhost:8080"
+	}
+	config.DashboardURL = strings.TrimRight(config.DashboardURL, "/")
+
 	return &EmailNotification{
 		config: config,
 	}
@@ -38,12 +112,140 @@ func (e *EmailNotification) Send(title, message string, severity string) error {
 	body := e.formatEmailBody(title, message, severity)
 
 	// Create MIME email
-	email := e.createMIMEEmail(subject, body)
+	email := e.createMIMEEmail(subject, body, message)
 
 	// Send email
 	return e.sendSMTP(email)
 }
 
+// SendAlert sends a single alert using the HTML and plaintext templates
+func (e *EmailNotification) SendAlert(alert EmailAlert) error {
+	return e.sendTemplated(EmailTemplateData{
+		Title:        alert.RuleName,
+		Severity:     alert.Severity,
+		Alerts:       []EmailAlert{alert},
+		DashboardURL: e.config.DashboardURL,
+		GeneratedAt:  time.Now(),
+	})
+}
+
+// SendDigest sends several alerts batched into one email
+func (e *EmailNotification) SendDigest(alerts []EmailAlert) error {
+	if len(alerts) == 0 {
+		return nil
+	}
+
+	// The digest takes the most severe alert's severity
+	severity := "info"
+	for _, alert := range alerts {
+		if severityRank(alert.Severity) > severityRank(severity) {
+			severity = alert.Severity
+		}
+	}
+
+	return e.sendTemplated(EmailTemplateData{
+		Title:        fmt.Sprintf("%d alerts", len(alerts)),
+		Severity:     severity,
+		Alerts:       alerts,
+		Digest:       true,
+		DashboardURL: e.config.DashboardURL,
+		GeneratedAt:  time.Now(),
+	})
+}
+
+// sendTemplated renders the subject and bodies and sends them as multipart/alternative
+func (e *EmailNotification) sendTemplated(data EmailTemplateData) error {
+	if len(e.config.ToEmails) == 0 {
+		return fmt.Errorf("no recipient emails configured")
+	}
+
+	subject, err := e.renderSubject(data)
+	if err != nil {
+		return err
+	}
+
+	htmlBody, err := e.renderHTML(data)
+	if err != nil {
+		return err
+	}
+
+	textBody, err := e.renderText(data)
+	if err != nil {
+		return err
+	}
+
+	return e.sendSMTP(e.createMIMEEmail(subject, htmlBody, textBody))
+}
+
+func (e *EmailNotification) renderSubject(data EmailTemplateData) (string, error) {
+	source := defaultSubjectTemplate
+	if e.config.SubjectTemplate != "" {
+		source = e.config.SubjectTemplate
+	}
+
+	t, err := texttemplate.New("subject").Funcs(emailTemplateFuncs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid subject template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render subject: %w", err)
+	}
+
+	// Headers can't contain line breaks
+	return strings.Join(strings.Fields(buf.String()), " "), nil
+}
+
+func (e *EmailNotification) renderHTML(data EmailTemplateData) (string, error) {
+	source, err := loadTemplate(e.config.HTMLTemplatePath, defaultHTMLTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := htmltemplate.New("html").Funcs(htmltemplate.FuncMap(emailTemplateFuncs)).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid HTML template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render HTML email: %w", err)
+	}
+	return buf.String(), nil
+}
+
+func (e *EmailNotification) renderText(data EmailTemplateData) (string, error) {
+	source, err := loadTemplate(e.config.TextTemplatePath, defaultTextTemplate)
+	if err != nil {
+		return "", err
+	}
+
+	t, err := texttemplate.New("text").Funcs(emailTemplateFuncs).Parse(source)
+	if err != nil {
+		return "", fmt.Errorf("invalid text template: %w", err)
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render text email: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// loadTemplate reads a template override from disk, falling back to the built-in template
+func loadTemplate(path, fallback string) (string, error) {
+	if path == "" {
+		return fallback, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read email template: %w", err)
+	}
+	return string(content), nil
+}
+
 func (e *EmailNotification) formatEmailBody(title, message, severity string) string {
 	timestamp := time.Now().Format("2006-01-02 15:04:05 MST")
 
@@ -62,14 +264,14 @@ func (e *EmailNotification) formatEmailBody(title, message, severity string) str
             <h1 style="margin: 0; font-size: 24px;">🔍 Peep Alert</h1>
             <p style="margin: 5px 0 0 0; font-size: 14px; opacity: 0.9;">Observability for humans</p>
         </div>
-        
+
         <!-- Alert Badge -->
         <div style="padding: 20px; text-align: center; border-bottom: 1px solid #eee;">
             <div style="display: inline-block; background-color: %s; color: white; padding: 8px 16px; border-radius: 20px; font-weight: bold; font-size: 14px;">
                 %s ALERT
             </div>
         </div>
-        
+
         <!-- Content -->
         <div style="padding: 20px;">
             <h2 style="margin: 0 0 15px 0; color: #333; font-size: 20px;">%s</h2>
@@ -77,7 +279,7 @@ func (e *EmailNotification) formatEmailBody(title, message, severity string) str
                 <pre style="margin: 0; font-family: 'Courier New', monospace; font-size: 13px; white-space: pre-wrap; word-wrap: break-word;">%s</pre>
             </div>
         </div>
-        
+
         <!-- Footer -->
         <div style="background-color: #f8f9fa; padding: 15px 20px; border-top: 1px solid #eee; font-size: 12px; color: #666;">
             <p style="margin: 0;"><strong>Time:</strong> %s</p>
@@ -89,9 +291,9 @@ func (e *EmailNotification) formatEmailBody(title, message, severity string) str
 		e.getSeverityHeaderColor(severity),
 		e.getSeverityColor(severity),
 		strings.ToUpper(severity),
-		title,
+		htmltemplate.HTMLEscapeString(title),
 		e.getSeverityColor(severity),
-		message,
+		htmltemplate.HTMLEscapeString(message),
 		timestamp,
 	)
 
@@ -124,51 +326,220 @@ func (e *EmailNotification) getSeverityHeaderColor(severity string) string {
 	}
 }
 
-func (e *EmailNotification) createMIMEEmail(subject, htmlBody string) string {
+// severityRank orders severities so digests can pick the most severe one
+func severityRank(severity string) int {
+	switch strings.ToLower(severity) {
+	case "critical":
+		return 3
+	case "warning":
+		return 2
+	case "info":
+		return 1
+	default:
+		return 0
+	}
+}
+
+// createMIMEEmail builds a multipart/alternative message with HTML and plaintext parts
+func (e *EmailNotification) createMIMEEmail(subject, htmlBody, textBody string) string {
 	fromHeader := e.config.FromEmail
 	if e.config.FromName != "" {
 		fromHeader = fmt.Sprintf("%s <%s>", e.config.FromName, e.config.FromEmail)
 	}
 
-	headers := make(map[string]string)
-	headers["From"] = fromHeader
-	headers["To"] = strings.Join(e.config.ToEmails, ", ")
-	headers["Subject"] = subject
-	headers["MIME-Version"] = "1.0"
-	headers["Content-Type"] = "text/html; charset=UTF-8"
-	headers["Date"] = time.Now().Format(time.RFC1123Z)
+	boundary := fmt.Sprintf("peep-%d", time.Now().UnixNano())
+
+	headers := []struct{ key, value string }{
+		{"From", fromHeader},
+		{"To", strings.Join(e.config.ToEmails, ", ")},
+		{"Subject", subject},
+		{"Date", time.Now().Format(time.RFC1123Z)},
+		{"MIME-Version", "1.0"},
+		{"Content-Type", fmt.Sprintf("multipart/alternative; boundary=%q", boundary)},
+	}
 
 	var email strings.Builder
-	for key, value := range headers {
-		email.WriteString(fmt.Sprintf("%s: %s\r\n", key, value))
+	for _, header := range headers {
+		email.WriteString(fmt.Sprintf("%s: %s\r\n", header.key, header.value))
 	}
 	email.WriteString("\r\n")
+
+	// Clients render the last part they understand, so plaintext goes first
+	email.WriteString("--" + boundary + "\r\n")
+	email.WriteString("Content-Type: text/plain; charset=UTF-8\r\n\r\n")
+	email.WriteString(textBody)
+	email.WriteString("\r\n--" + boundary + "\r\n")
+	email.WriteString("Content-Type: text/html; charset=UTF-8\r\n\r\n")
 	email.WriteString(htmlBody)
+	email.WriteString("\r\n--" + boundary + "--\r\n")
 
 	return email.String()
 }
 
 func (e *EmailNotification) sendSMTP(email string) error {
-	// Connect to SMTP server
-	addr := fmt.Sprintf("%s:%d", e.config.SMTPHost, e.config.SMTPPort)
+	if err := e.deliver(email); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
 
-	// Authenticate
-	auth := smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.SMTPHost)
+// security resolves the effective connection security mode
+func (e *EmailNotification) security() string {
+	security := strings.ToLower(e.config.Security)
+	if security == SMTPSecurityAuto && e.config.UseTLS {
+		return SMTPSecurityStartTLS
+	}
+	if security == SMTPSecurityAuto && e.config.SMTPPort == 465 {
+		return SMTPSecurityTLS
+	}
+	return security
+}
 
-	// Send email
-	err := smtp.SendMail(
-		addr,
-		auth,
-		e.config.FromEmail,
-		e.config.ToEmails,
-		[]byte(email),
-	)
+// tlsConfig builds the TLS settings used for implicit TLS and STARTTLS
+func (e *EmailNotification) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		ServerName:         e.config.SMTPHost,
+		InsecureSkipVerify: e.config.InsecureSkipVerify,
+	}
+
+	if e.config.CAFile != "" {
+		pem, err := os.ReadFile(e.config.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file %s", e.config.CAFile)
+		}
+		config.RootCAs = pool
+	}
+
+	return config, nil
+}
+
+// auth returns the SMTP authentication mechanism, or nil for unauthenticated relays
+func (e *EmailNotification) auth() (smtp.Auth, error) {
+	method := strings.ToLower(e.config.AuthMethod)
+	if method == SMTPAuthNone || (method == "" && e.config.Username == "") {
+		return nil, nil
+	}
 
+	switch method {
+	case "", SMTPAuthPlain:
+		return smtp.PlainAuth("", e.config.Username, e.config.Password, e.config.SMTPHost), nil
+	case SMTPAuthLogin:
+		return &loginAuth{username: e.config.Username, password: e.config.Password}, nil
+	case SMTPAuthCRAMMD5:
+		return smtp.CRAMMD5Auth(e.config.Username, e.config.Password), nil
+	default:
+		return nil, fmt.Errorf("unknown SMTP auth method: %s", e.config.AuthMethod)
+	}
+}
+
+// deliver runs the SMTP conversation with the configured security and auth settings
+func (e *EmailNotification) deliver(email string) error {
+	addr := net.JoinHostPort(e.config.SMTPHost, strconv.Itoa(e.config.SMTPPort))
+	security := e.security()
+
+	tlsConfig, err := e.tlsConfig()
 	if err != nil {
-		return fmt.Errorf("failed to send email: %w", err)
+		return err
 	}
 
-	return nil
+	auth, err := e.auth()
+	if err != nil {
+		return err
+	}
+
+	var conn net.Conn
+	dialer := &net.Dialer{Timeout: 30 * time.Second}
+	switch security {
+	case SMTPSecurityTLS:
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	case SMTPSecurityAuto, SMTPSecurityStartTLS, SMTPSecurityNone:
+		conn, err = dialer.Dial("tcp", addr)
+	default:
+		return fmt.Errorf("unknown SMTP security mode: %s", e.config.Security)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, e.config.SMTPHost)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer client.Close()
+
+	if security == SMTPSecurityAuto || security == SMTPSecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); ok {
+			if err := client.StartTLS(tlsConfig); err != nil {
+				return fmt.Errorf("STARTTLS failed: %w", err)
+			}
+		} else if security == SMTPSecurityStartTLS {
+			return fmt.Errorf("server %s does not support STARTTLS", addr)
+		}
+	}
+
+	if auth != nil {
+		if ok, _ := client.Extension("AUTH"); !ok {
+			return fmt.Errorf("server %s does not support authentication", addr)
+		}
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(e.config.FromEmail); err != nil {
+		return err
+	}
+	for _, recipient := range e.config.ToEmails {
+		if err := client.Rcpt(recipient); err != nil {
+			return fmt.Errorf("recipient %s rejected: %w", recipient, err)
+		}
+	}
+
+	writer, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := writer.Write([]byte(email)); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+
+	return client.Quit()
+}
+
+// loginAuth implements the LOGIN mechanism used by Office 365 and some older servers
+type loginAuth struct {
+	username string
+	password string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" {
+		return "", nil, fmt.Errorf("refusing LOGIN auth over an unencrypted connection")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+
+	switch strings.ToLower(strings.TrimSpace(string(fromServer))) {
+	case "username:":
+		return []byte(a.username), nil
+	case "password:":
+		return []byte(a.password), nil
+	default:
+		return nil, fmt.Errorf("unexpected LOGIN challenge: %s", fromServer)
+	}
 }
 
 // ValidateConfig checks if the email configuration is valid
@@ -181,12 +552,19 @@ func (e *EmailNotification) ValidateConfig() error {
 		return fmt.Errorf("SMTP port is required")
 	}
 
-	if e.config.Username == "" {
-		return fmt.Errorf("SMTP username is required")
+	// Relays that don't need auth can leave the username empty
+	if e.config.Username != "" && e.config.Password == "" && strings.ToLower(e.config.AuthMethod) != SMTPAuthNone {
+		return fmt.Errorf("SMTP password is required when a username is set")
+	}
+
+	switch strings.ToLower(e.config.Security) {
+	case SMTPSecurityAuto, SMTPSecurityTLS, SMTPSecurityStartTLS, SMTPSecurityNone:
+	default:
+		return fmt.Errorf("unknown SMTP security mode: %s (use tls, starttls, or none)", e.config.Security)
 	}
 
-	if e.config.Password == "" {
-		return fmt.Errorf("SMTP password is required")
+	if _, err := e.auth(); err != nil {
+		return err
 	}
 
 	if e.config.FromEmail == "" {
@@ -212,3 +590,95 @@ func (e *EmailNotification) TestConnection() error {
 		"info",
 	)
 }
+
+// emailTemplateFuncs are available to the built-in and user-provided templates
+var emailTemplateFuncs = map[string]interface{}{
+	"upper": strings.ToUpper,
+	"severityColor": func(severity string) string {
+		return (&EmailNotification{}).getSeverityColor(severity)
+	},
+	"severityHeaderColor": func(severity string) string {
+		return (&EmailNotification{}).getSeverityHeaderColor(severity)
+	},
+	"formatTime": func(t time.Time) string {
+		return t.Format("2006-01-02 15:04:05 MST")
+	},
+}
+
+const defaultSubjectTemplate = `[Peep {{if .Digest}}Digest{{else}}Alert{{end}} - {{upper .Severity}}] {{.Title}}`
+
+const defaultTextTemplate = `{{if .Digest}}PEEP ALERT DIGEST - {{len .Alerts}} alerts{{else}}PEEP ALERT - {{upper .Severity}}{{end}}
+{{range .Alerts}}
+== {{.RuleName}} ({{upper .Severity}}) ==
+{{if .Description}}{{.Description}}
+{{end}}Count:     {{.Count}} (threshold {{.Threshold}})
+Fired at:  {{formatTime .FiredAt}}
+Query:     {{.Query}}
+{{if .Labels}}Labels:   {{range $key, $value := .Labels}} {{$key}}={{$value}}{{end}}
+{{end}}{{if .SampleLogs}}
+Sample logs:
+{{range .SampleLogs}}  {{formatTime .Timestamp}} [{{.Level}}] {{.Service}}: {{.Message}}
+{{end}}{{end}}{{end}}
+Dashboard: {{.DashboardURL}}/alerts
+
+Generated by Peep at {{formatTime .GeneratedAt}}
+`
+
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Peep Alert</title>
+</head>
+<body style="font-family: Arial, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5;">
+    <div style="max-width: 640px; margin: 0 auto; background-color: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
+        <div style="background-color: {{severityHeaderColor .Severity}}; color: white; padding: 20px; text-align: center;">
+            <h1 style="margin: 0; font-size: 24px;">🔍 Peep {{if .Digest}}Alert Digest{{else}}Alert{{end}}</h1>
+            <p style="margin: 5px 0 0 0; font-size: 14px; opacity: 0.9;">{{if .Digest}}{{len .Alerts}} alerts since the last digest{{else}}Observability for humans{{end}}</p>
+        </div>
+
+        {{range .Alerts}}
+        <div style="padding: 20px; border-bottom: 1px solid #eee;">
+            <div style="display: inline-block; background-color: {{severityColor .Severity}}; color: white; padding: 4px 12px; border-radius: 20px; font-weight: bold; font-size: 12px;">
+                {{upper .Severity}}
+            </div>
+            <h2 style="margin: 12px 0 8px 0; color: #333; font-size: 20px;">{{.RuleName}}</h2>
+            {{if .Description}}<p style="margin: 0 0 12px 0; color: #555;">{{.Description}}</p>{{end}}
+
+            <table style="width: 100%; border-collapse: collapse; font-size: 14px; margin-bottom: 12px;">
+                <tr><td style="padding: 4px 0; color: #666; width: 110px;">Count</td><td style="padding: 4px 0;"><strong>{{.Count}}</strong> (threshold {{.Threshold}})</td></tr>
+                <tr><td style="padding: 4px 0; color: #666;">Fired at</td><td style="padding: 4px 0;">{{formatTime .FiredAt}}</td></tr>
+                {{if .Labels}}<tr><td style="padding: 4px 0; color: #666;">Labels</td><td style="padding: 4px 0;">{{range $key, $value := .Labels}}<code style="background: #f1f3f5; padding: 1px 6px; border-radius: 10px; margin-right: 4px;">{{$key}}={{$value}}</code>{{end}}</td></tr>{{end}}
+            </table>
+
+            <div style="background-color: #f8f9fa; border-left: 4px solid {{severityColor .Severity}}; padding: 12px; border-radius: 0 4px 4px 0;">
+                <pre style="margin: 0; font-family: 'Courier New', monospace; font-size: 12px; white-space: pre-wrap; word-wrap: break-word;">{{.Query}}</pre>
+            </div>
+
+            {{if .SampleLogs}}
+            <h3 style="margin: 16px 0 8px 0; font-size: 14px; color: #333;">Sample logs</h3>
+            <table style="width: 100%; border-collapse: collapse; font-family: 'Courier New', monospace; font-size: 12px;">
+                {{range .SampleLogs}}
+                <tr style="border-top: 1px solid #eee;">
+                    <td style="padding: 4px 8px 4px 0; color: #666; white-space: nowrap; vertical-align: top;">{{.Timestamp.Format "15:04:05"}}</td>
+                    <td style="padding: 4px 8px 4px 0; vertical-align: top;">{{.Level}}</td>
+                    <td style="padding: 4px 8px 4px 0; color: #666; vertical-align: top;">{{.Service}}</td>
+                    <td style="padding: 4px 0; word-break: break-word;">{{.Message}}</td>
+                </tr>
+                {{end}}
+            </table>
+            {{end}}
+        </div>
+        {{end}}
+
+        <div style="padding: 20px; text-align: center;">
+            <a href="{{.DashboardURL}}/alerts" style="display: inline-block; background-color: #2563eb; color: white; padding: 10px 20px; border-radius: 6px; text-decoration: none; font-weight: bold;">Open Peep Dashboard</a>
+        </div>
+
+        <div style="background-color: #f8f9fa; padding: 15px 20px; border-top: 1px solid #eee; font-size: 12px; color: #666;">
+            <p style="margin: 0;"><strong>Generated:</strong> {{formatTime .GeneratedAt}}</p>
+            <p style="margin: 5px 0 0 0;"><em>Generated by Peep - One binary. No boilerplate. No YAML cults.</em></p>
+        </div>
+    </div>
+</body>
+</html>`
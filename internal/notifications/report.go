@@ -0,0 +1,259 @@
+package notifications
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/tabwriter"
+	texttemplate "text/template"
+	"time"
+)
+
+// Report is a scheduled summary of a period's logs and alerts, for people
+// who won't open the dashboard
+type Report struct {
+	Name        string
+	Description string
+	Start       time.Time
+	End         time.Time
+
+	Logs           int // Logs received in the period
+	PreviousLogs   int // ...and in the period before it, for the trend
+	Errors         int
+	PreviousErrors int
+	AlertsFired    int
+
+	NoisiestAlerts []ReportCount   // Rules that fired in the period, most first
+	TopErrors      []ReportCount   // Most frequent error messages, as "service: message"
+	Sections       []ReportSection // Saved queries the report includes, in order
+
+	DashboardURL string
+}
+
+// ReportCount is a name and how often it happened
+type ReportCount struct {
+	Name  string
+	Count int
+}
+
+// ReportSection is one saved query's result
+type ReportSection struct {
+	Title   string
+	Columns []string
+	Rows    [][]string
+	Error   string // The query failed; Rows is empty
+}
+
+// Trend describes how a count changed from the previous period, e.g. "+12%",
+// "-3%", or "new" when there was nothing before
+func Trend(current, previous int) string {
+	switch {
+	case previous == 0 && current == 0:
+		return "no change"
+	case previous == 0:
+		return "new"
+	}
+	change := float64(current-previous) / float64(previous) * 100
+	return fmt.Sprintf("%+.0f%%", change)
+}
+
+// LogsTrend is the change in log volume from the previous period
+func (r Report) LogsTrend() string { return Trend(r.Logs, r.PreviousLogs) }
+
+// ErrorsTrend is the change in errors from the previous period
+func (r Report) ErrorsTrend() string { return Trend(r.Errors, r.PreviousErrors) }
+
+// Period describes the time the report covers
+func (r Report) Period() string {
+	return r.Start.Format("Jan 2 15:04") + " – " + r.End.Format("Jan 2 15:04")
+}
+
+// Table renders a section's rows as aligned plain text
+func (s ReportSection) Table() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	if len(s.Columns) > 1 {
+		fmt.Fprintln(w, strings.Join(s.Columns, "\t"))
+	}
+	for _, row := range s.Rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+// Text renders the report as plain text, for shell scripts and the email's
+// text part
+func (r Report) Text() string {
+	var buf bytes.Buffer
+	if err := reportTextTemplate.Execute(&buf, r); err != nil {
+		return fmt.Sprintf("Peep report %s: %v", r.Name, err)
+	}
+	return buf.String()
+}
+
+// SendReport emails a report
+func (e *EmailNotification) SendReport(report Report) error {
+	if len(e.config.ToEmails) == 0 {
+		return fmt.Errorf("no recipient emails configured")
+	}
+	if report.DashboardURL == "" {
+		report.DashboardURL = e.config.DashboardURL
+	}
+
+	var html bytes.Buffer
+	if err := reportHTMLTemplate.Execute(&html, report); err != nil {
+		return fmt.Errorf("failed to render report email: %w", err)
+	}
+	subject := fmt.Sprintf("[Peep Report] %s: %d logs, %d errors", report.Name, report.Logs, report.Errors)
+	return e.sendSMTP(e.createMIMEEmail(subject, html.String(), report.Text()))
+}
+
+// SendReport posts a report as one message
+func (s *SlackNotification) SendReport(report Report) error {
+	header := fmt.Sprintf("📊 Peep report: %s", report.Name)
+	context := report.Period()
+	if report.Description != "" {
+		context = report.Description + " · " + context
+	}
+	blocks := []SlackBlock{
+		{Type: "header", Text: &SlackText{Type: "plain_text", Text: header}},
+		{Type: "context", Elements: []interface{}{SlackText{Type: "mrkdwn", Text: context}}},
+		{Type: "section", Fields: []SlackText{
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Logs*\n%d (%s)", report.Logs, report.LogsTrend())},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Errors*\n%d (%s)", report.Errors, report.ErrorsTrend())},
+			{Type: "mrkdwn", Text: fmt.Sprintf("*Alerts fired*\n%d", report.AlertsFired)},
+		}},
+	}
+
+	if lines := slackCountLines(report.TopErrors); lines != "" {
+		blocks = append(blocks, SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: "*Top errors*\n" + lines}})
+	}
+	if lines := slackCountLines(report.NoisiestAlerts); lines != "" {
+		blocks = append(blocks, SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: "*Noisiest alerts*\n" + lines}})
+	}
+	for _, section := range report.Sections {
+		body := "```" + section.Table() + "```"
+		switch {
+		case section.Error != "":
+			body = "⚠️ " + section.Error
+		case len(section.Rows) == 0:
+			body = "_No rows_"
+		}
+		blocks = append(blocks, SlackBlock{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: "*" + section.Title + "*\n" + body}})
+	}
+
+	blocks = append(blocks, SlackBlock{Type: "actions", Elements: []interface{}{
+		SlackButton{
+			Type:     "button",
+			Text:     &SlackText{Type: "plain_text", Text: "View in Peep"},
+			ActionID: "peep_view",
+			URL:      s.config.PeepURL,
+		},
+	}})
+
+	_, err := s.post(SlackMessage{
+		Text:      fmt.Sprintf("%s — %d logs, %d errors, %d alerts", header, report.Logs, report.Errors, report.AlertsFired),
+		Username:  "Peep",
+		IconEmoji: ":bar_chart:",
+		Channel:   s.config.Channel,
+		Blocks:    blocks,
+	}, nil)
+	return err
+}
+
+// slackCountLines lists counts as bullets, or "" when there are none
+func slackCountLines(counts []ReportCount) string {
+	lines := make([]string, len(counts))
+	for i, count := range counts {
+		lines[i] = fmt.Sprintf("• %s — %d", count.Name, count.Count)
+	}
+	return strings.Join(lines, "\n")
+}
+
+var reportTextTemplate = texttemplate.Must(texttemplate.New("report").Parse(`PEEP REPORT - {{.Name}}
+{{if .Description}}{{.Description}}
+{{end}}{{.Period}}
+
+Logs:         {{.Logs}} ({{.LogsTrend}})
+Errors:       {{.Errors}} ({{.ErrorsTrend}})
+Alerts fired: {{.AlertsFired}}
+{{if .TopErrors}}
+Top errors:
+{{range .TopErrors}}{{printf "%6d" .Count}}  {{.Name}}
+{{end}}{{end}}{{if .NoisiestAlerts}}
+Noisiest alerts:
+{{range .NoisiestAlerts}}{{printf "%6d" .Count}}  {{.Name}}
+{{end}}{{end}}{{range .Sections}}
+== {{.Title}} ==
+{{if .Error}}Error: {{.Error}}{{else if .Rows}}{{.Table}}{{else}}No rows{{end}}
+{{end}}{{if .DashboardURL}}
+Dashboard: {{.DashboardURL}}
+{{end}}`))
+
+var reportHTMLTemplate = htmltemplate.Must(htmltemplate.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+    <meta charset="UTF-8">
+    <title>Peep Report</title>
+</head>
+<body style="font-family: Arial, sans-serif; margin: 0; padding: 20px; background-color: #f5f5f5;">
+    <div style="max-width: 640px; margin: 0 auto; background-color: white; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 4px rgba(0,0,0,0.1);">
+        <div style="background-color: #2563eb; color: white; padding: 20px; text-align: center;">
+            <h1 style="margin: 0; font-size: 24px;">📊 {{.Name}}</h1>
+            <p style="margin: 5px 0 0 0; font-size: 14px; opacity: 0.9;">{{.Period}}</p>
+        </div>
+        {{if .Description}}<p style="margin: 20px 20px 0 20px; color: #555;">{{.Description}}</p>{{end}}
+
+        <table style="width: 100%; border-collapse: collapse; text-align: center; margin: 12px 0;">
+            <tr>
+                <td style="padding: 12px;"><div style="font-size: 28px; font-weight: bold; color: #333;">{{.Logs}}</div><div style="color: #666; font-size: 13px;">logs ({{.LogsTrend}})</div></td>
+                <td style="padding: 12px;"><div style="font-size: 28px; font-weight: bold; color: #dc3545;">{{.Errors}}</div><div style="color: #666; font-size: 13px;">errors ({{.ErrorsTrend}})</div></td>
+                <td style="padding: 12px;"><div style="font-size: 28px; font-weight: bold; color: #fd7e14;">{{.AlertsFired}}</div><div style="color: #666; font-size: 13px;">alerts fired</div></td>
+            </tr>
+        </table>
+
+        {{if .TopErrors}}
+        <div style="padding: 0 20px 16px 20px;">
+            <h3 style="margin: 0 0 8px 0; font-size: 15px; color: #333;">Top errors</h3>
+            <table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+                {{range .TopErrors}}<tr style="border-top: 1px solid #eee;"><td style="padding: 4px 8px 4px 0; width: 60px; font-weight: bold;">{{.Count}}</td><td style="padding: 4px 0; word-break: break-word;">{{.Name}}</td></tr>{{end}}
+            </table>
+        </div>
+        {{end}}
+
+        {{if .NoisiestAlerts}}
+        <div style="padding: 0 20px 16px 20px;">
+            <h3 style="margin: 0 0 8px 0; font-size: 15px; color: #333;">Noisiest alerts</h3>
+            <table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+                {{range .NoisiestAlerts}}<tr style="border-top: 1px solid #eee;"><td style="padding: 4px 8px 4px 0; width: 60px; font-weight: bold;">{{.Count}}</td><td style="padding: 4px 0;">{{.Name}}</td></tr>{{end}}
+            </table>
+        </div>
+        {{end}}
+
+        {{range .Sections}}
+        <div style="padding: 0 20px 16px 20px;">
+            <h3 style="margin: 0 0 8px 0; font-size: 15px; color: #333;">{{.Title}}</h3>
+            {{if .Error}}<p style="margin: 0; color: #dc3545;">{{.Error}}</p>
+            {{else if .Rows}}
+            <table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+                {{if gt (len .Columns) 1}}<tr>{{range .Columns}}<th style="padding: 4px 8px 4px 0; text-align: left; color: #666;">{{.}}</th>{{end}}</tr>{{end}}
+                {{range .Rows}}<tr style="border-top: 1px solid #eee;">{{range .}}<td style="padding: 4px 8px 4px 0;">{{.}}</td>{{end}}</tr>{{end}}
+            </table>
+            {{else}}<p style="margin: 0; color: #666;">No rows</p>{{end}}
+        </div>
+        {{end}}
+
+        {{if .DashboardURL}}
+        <div style="padding: 20px; text-align: center;">
+            <a href="{{.DashboardURL}}" style="display: inline-block; background-color: #2563eb; color: white; padding: 10px 20px; border-radius: 6px; text-decoration: none; font-weight: bold;">Open Peep Dashboard</a>
+        </div>
+        {{end}}
+
+        <div style="background-color: #f8f9fa; padding: 15px 20px; border-top: 1px solid #eee; font-size: 12px; color: #666;">
+            <p style="margin: 0;"><em>Generated by Peep - One binary. No boilerplate. No YAML cults.</em></p>
+        </div>
+    </div>
+</body>
+</html>`))
@@ -1,6 +1,8 @@
 package notifications
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
@@ -16,6 +18,38 @@ type ShellConfig struct {
 	Environment map[string]string
 }
 
+// ShellPayload is the JSON document written to the script's stdin
+type ShellPayload struct {
+	AlertID    int64          `json:"alert_id,omitempty"`
+	Title      string         `json:"title"`
+	Message    string         `json:"message"`
+	Severity   string         `json:"severity"`
+	Count      int            `json:"count"`
+	Threshold  int            `json:"threshold"`
+	Ratio      float64        `json:"ratio"`
+	FiredAt    time.Time      `json:"fired_at"`
+	Rule       ShellRule      `json:"rule"`
+	SampleLogs []ShellLogLine `json:"sample_logs"`
+}
+
+// ShellRule describes the alert rule that fired
+type ShellRule struct {
+	ID          int64             `json:"id,omitempty"`
+	Name        string            `json:"name"`
+	Description string            `json:"description,omitempty"`
+	Query       string            `json:"query,omitempty"`
+	Window      string            `json:"window,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// ShellLogLine is a sample log matched by the rule's query
+type ShellLogLine struct {
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Service   string    `json:"service,omitempty"`
+	Message   string    `json:"message"`
+}
+
 type ShellNotification struct {
 	config ShellConfig
 }
@@ -32,6 +66,20 @@ func NewShellNotification(config ShellConfig) *ShellNotification {
 }
 
 func (s *ShellNotification) Execute(title, message, severity string, count, threshold int) error {
+	return s.ExecuteAlert(ShellPayload{
+		Title:     title,
+		Message:   message,
+		Severity:  severity,
+		Count:     count,
+		Threshold: threshold,
+		FiredAt:   time.Now(),
+		Rule:      ShellRule{Name: title},
+	})
+}
+
+// ExecuteAlert runs the script with the alert exposed as environment variables
+// and as a JSON document on stdin
+func (s *ShellNotification) ExecuteAlert(payload ShellPayload) error {
 	if s.config.ScriptPath == "" {
 		return fmt.Errorf("script path is required")
 	}
@@ -41,8 +89,21 @@ func (s *ShellNotification) Execute(title, message, severity string, count, thre
 		return fmt.Errorf("script validation failed: %w", err)
 	}
 
+	if payload.Threshold > 0 {
+		payload.Ratio = float64(payload.Count) / float64(payload.Threshold)
+	}
+	if payload.SampleLogs == nil {
+		payload.SampleLogs = []ShellLogLine{}
+	}
+
+	stdin, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal alert payload: %w", err)
+	}
+
 	// Prepare command
 	cmd := exec.Command(s.config.ScriptPath, s.config.Args...)
+	cmd.Stdin = bytes.NewReader(stdin)
 
 	// Set working directory if specified
 	if s.config.WorkingDir != "" {
@@ -53,7 +114,7 @@ func (s *ShellNotification) Execute(title, message, severity string, count, thre
 	cmd.Env = os.Environ()
 
 	// Add alert-specific environment variables
-	alertEnv := s.buildAlertEnvironment(title, message, severity, count, threshold)
+	alertEnv := s.buildAlertEnvironment(payload)
 	for key, value := range alertEnv {
 		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", key, value))
 	}
@@ -90,17 +151,17 @@ func (s *ShellNotification) validateScript() error {
 	return nil
 }
 
-func (s *ShellNotification) buildAlertEnvironment(title, message, severity string, count, threshold int) map[string]string {
-	timestamp := time.Now().Format("2006-01-02T15:04:05Z07:00")
+func (s *ShellNotification) buildAlertEnvironment(payload ShellPayload) map[string]string {
+	timestamp := payload.FiredAt.Format("2006-01-02T15:04:05Z07:00")
 
 	return map[string]string{
-		"PEEP_ALERT_TITLE":     title,
-		"PEEP_ALERT_MESSAGE":   message,
-		"PEEP_ALERT_SEVERITY":  severity,
-		"PEEP_ALERT_COUNT":     fmt.Sprintf("%d", count),
-		"PEEP_ALERT_THRESHOLD": fmt.Sprintf("%d", threshold),
+		"PEEP_ALERT_TITLE":     payload.Title,
+		"PEEP_ALERT_MESSAGE":   payload.Message,
+		"PEEP_ALERT_SEVERITY":  payload.Severity,
+		"PEEP_ALERT_COUNT":     fmt.Sprintf("%d", payload.Count),
+		"PEEP_ALERT_THRESHOLD": fmt.Sprintf("%d", payload.Threshold),
 		"PEEP_ALERT_TIMESTAMP": timestamp,
-		"PEEP_ALERT_RATIO":     fmt.Sprintf("%.2f", float64(count)/float64(threshold)),
+		"PEEP_ALERT_RATIO":     fmt.Sprintf("%.2f", payload.Ratio),
 	}
 }
 
@@ -171,7 +232,8 @@ func CreateExampleScript(path string) error {
 	script := `#!/bin/bash
 
 # Peep Alert Handler Example Script
-# This script receives alert information via environment variables
+# This script receives alert information via environment variables, and the
+# full alert (rule, labels, sample logs) as a JSON document on stdin
 
 echo "🚨 Peep Alert Received!"
 echo "======================="
@@ -186,13 +248,20 @@ echo "Message:"
 echo "$PEEP_ALERT_MESSAGE"
 echo ""
 
+# Read the JSON payload from stdin
+PAYLOAD=$(cat)
+
+# Example: Parse the payload with jq (uncomment to use)
+# echo "$PAYLOAD" | jq -r '.sample_logs[] | "\(.timestamp) [\(.level)] \(.message)"'
+# TEAM=$(echo "$PAYLOAD" | jq -r '.rule.labels.team // "unknown"')
+
 # Example: Log to a file
 echo "$(date): Alert - $PEEP_ALERT_TITLE ($PEEP_ALERT_COUNT/$PEEP_ALERT_THRESHOLD)" >> /tmp/peep-alerts.log
 
-# Example: Send to a webhook (uncomment to use)
-# curl -X POST https://your-webhook-url.com/alerts \
+# Example: Forward the payload to a webhook (uncomment to use)
+# echo "$PAYLOAD" | curl -X POST https://your-webhook-url.com/alerts \
 #   -H "Content-Type: application/json" \
-#   -d "{\"title\":\"$PEEP_ALERT_TITLE\",\"severity\":\"$PEEP_ALERT_SEVERITY\",\"count\":$PEEP_ALERT_COUNT}"
+#   --data-binary @-
 
 # Example: Play a sound (macOS)
 # if command -v afplay &> /dev/null; then
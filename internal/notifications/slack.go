@@ -5,15 +5,30 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
+	"strings"
 	"time"
 )
 
+// slackPostMessageURL is the Web API endpoint used when a bot token is configured
+const slackPostMessageURL = "https://slack.com/api/chat.postMessage"
+
+// slackClient sends webhooks and API calls. Alerts are sent from the engine's
+// loop, so a Slack outage must fail the send rather than stall every rule.
+var slackClient = &http.Client{Timeout: 10 * time.Second}
+
+// SlackAckAction is the action ID of an alert message's Ack button. Clicks
+// go to the Slack app's interactivity request URL, peep web's /slack/actions.
+const SlackAckAction = "peep_ack"
+
 // SlackMessage represents a Slack webhook message
 type SlackMessage struct {
 	Text        string            `json:"text,omitempty"`
 	Username    string            `json:"username,omitempty"`
 	IconEmoji   string            `json:"icon_emoji,omitempty"`
 	Channel     string            `json:"channel,omitempty"`
+	ThreadTS    string            `json:"thread_ts,omitempty"`
+	Blocks      []SlackBlock      `json:"blocks,omitempty"`
 	Attachments []SlackAttachment `json:"attachments,omitempty"`
 }
 
@@ -26,6 +41,7 @@ type SlackAttachment struct {
 	Footer     string       `json:"footer,omitempty"`
 	Timestamp  int64        `json:"ts,omitempty"`
 	MarkdownIn []string     `json:"mrkdwn_in,omitempty"`
+	Blocks     []SlackBlock `json:"blocks,omitempty"`
 }
 
 // SlackField represents a field in a Slack attachment
@@ -35,45 +51,253 @@ type SlackField struct {
 	Short bool   `json:"short"`
 }
 
-// SendSlackNotification sends a notification to Slack via webhook
-func SendSlackNotification(webhookURL, title, message string, count, threshold int) error {
-	// Determine color based on severity
-	color := getAlertColor(count, threshold)
+// SlackBlock represents a Block Kit layout block
+type SlackBlock struct {
+	Type     string        `json:"type"`
+	BlockID  string        `json:"block_id,omitempty"`
+	Text     *SlackText    `json:"text,omitempty"`
+	Fields   []SlackText   `json:"fields,omitempty"`
+	Elements []interface{} `json:"elements,omitempty"` // SlackText for context blocks, SlackButton for actions blocks
+}
 
-	// Create rich Slack message
-	slackMsg := SlackMessage{
+// SlackText represents a Block Kit text object
+type SlackText struct {
+	Type string `json:"type"` // "plain_text" or "mrkdwn"
+	Text string `json:"text"`
+}
+
+// SlackButton represents a Block Kit button element
+type SlackButton struct {
+	Type     string     `json:"type"`
+	Text     *SlackText `json:"text"`
+	ActionID string     `json:"action_id,omitempty"`
+	Value    string     `json:"value,omitempty"`
+	URL      string     `json:"url,omitempty"`
+	Style    string     `json:"style,omitempty"`
+}
+
+// SlackConfig configures how alerts are delivered to Slack.
+//
+// Either WebhookURL or BotToken must be set. Threaded follow-ups need a bot
+// token, because incoming webhooks don't return the timestamp of the message
+// they post.
+type SlackConfig struct {
+	WebhookURL string
+	BotToken   string
+	Channel    string // Channel override (e.g. "#alerts"); required with a bot token
+	PeepURL    string // Base URL used for "View in Peep" links
+}
+
+// SlackAlert holds the details of an alert to render as a Block Kit message
+type SlackAlert struct {
+	AlertID   int64
+	Title     string
+	Message   string
+	Count     int
+	Threshold int
+	Labels    map[string]string
+	FiredAt   time.Time
+}
+
+// SlackThread identifies a posted message that follow-ups can be threaded under
+type SlackThread struct {
+	Channel string
+	TS      string
+}
+
+// String encodes the thread as "channel:ts" for storage
+func (t SlackThread) String() string {
+	if t.TS == "" {
+		return ""
+	}
+	return t.Channel + ":" + t.TS
+}
+
+// ParseSlackThread decodes a thread reference produced by SlackThread.String
+func ParseSlackThread(ref string) (SlackThread, bool) {
+	parts := strings.SplitN(ref, ":", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return SlackThread{}, false
+	}
+	return SlackThread{Channel: parts[0], TS: parts[1]}, true
+}
+
+type SlackNotification struct {
+	config SlackConfig
+}
+
+func NewSlackNotification(config SlackConfig) *SlackNotification {
+	if config.PeepURL == "" {
+		config.PeepURL = "http://localhost:8080"
+	}
+	config.PeepURL = strings.TrimRight(config.PeepURL, "/")
+
+	return &SlackNotification{
+		config: config,
+	}
+}
+
+// SendAlert posts an alert as a Block Kit message. If thread is set, the alert is
+// posted as a reply in that thread instead of a new top-level message. The
+// returned thread is empty when the message was sent through a webhook.
+func (s *SlackNotification) SendAlert(alert SlackAlert, thread *SlackThread) (SlackThread, error) {
+	header := fmt.Sprintf("🚨 Alert: %s", alert.Title)
+	if thread != nil {
+		header = fmt.Sprintf("🔁 Still firing: %s", alert.Title)
+	}
+
+	fields := []SlackText{
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Count*\n%d", alert.Count)},
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Threshold*\n%d", alert.Threshold)},
+		{Type: "mrkdwn", Text: fmt.Sprintf("*Severity*\n%s", getSeverityText(alert.Count, alert.Threshold))},
+	}
+	if len(alert.Labels) > 0 {
+		pairs := make([]string, 0, len(alert.Labels))
+		for key, value := range alert.Labels {
+			pairs = append(pairs, fmt.Sprintf("`%s=%s`", key, value))
+		}
+		sort.Strings(pairs)
+		fields = append(fields, SlackText{Type: "mrkdwn", Text: "*Labels*\n" + strings.Join(pairs, " ")})
+	}
+
+	firedAt := alert.FiredAt
+	if firedAt.IsZero() {
+		firedAt = time.Now()
+	}
+
+	blocks := []SlackBlock{
+		{Type: "header", Text: &SlackText{Type: "plain_text", Text: header}},
+		{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: alert.Message}, Fields: fields},
+		{Type: "context", Elements: []interface{}{
+			SlackText{Type: "mrkdwn", Text: fmt.Sprintf("Peep Observability • <!date^%d^{date_short_pretty} {time_secs}|%s>", firedAt.Unix(), firedAt.Format(time.RFC1123))},
+		}},
+		{Type: "actions", BlockID: fmt.Sprintf("peep_alert_%d", alert.AlertID), Elements: []interface{}{
+			SlackButton{
+				Type:     "button",
+				Text:     &SlackText{Type: "plain_text", Text: "Ack"},
+				ActionID: SlackAckAction,
+				Value:    fmt.Sprintf("%d", alert.AlertID),
+				Style:    "primary",
+			},
+			SlackButton{
+				Type:     "button",
+				Text:     &SlackText{Type: "plain_text", Text: "View in Peep"},
+				ActionID: "peep_view",
+				URL:      s.config.PeepURL + "/alerts",
+			},
+		}},
+	}
+
+	msg := SlackMessage{
+		Text:      fmt.Sprintf("%s (%d/%d)", header, alert.Count, alert.Threshold),
 		Username:  "Peep",
 		IconEmoji: ":rotating_light:",
+		Channel:   s.config.Channel,
 		Attachments: []SlackAttachment{
-			{
-				Color: color,
-				Title: fmt.Sprintf("🚨 Alert: %s", title),
-				Text:  message,
-				Fields: []SlackField{
-					{
-						Title: "Count",
-						Value: fmt.Sprintf("%d", count),
-						Short: true,
-					},
-					{
-						Title: "Threshold",
-						Value: fmt.Sprintf("%d", threshold),
-						Short: true,
-					},
-					{
-						Title: "Severity",
-						Value: getSeverityText(count, threshold),
-						Short: true,
+			{Color: getAlertColor(alert.Count, alert.Threshold), Blocks: blocks},
+		},
+	}
+
+	return s.post(msg, thread)
+}
+
+// SendResolved posts a resolution notice, threaded under the original alert when possible
+func (s *SlackNotification) SendResolved(title string, thread *SlackThread) error {
+	text := fmt.Sprintf("✅ Resolved: %s", title)
+
+	msg := SlackMessage{
+		Text:      text,
+		Username:  "Peep",
+		IconEmoji: ":white_check_mark:",
+		Channel:   s.config.Channel,
+		Attachments: []SlackAttachment{
+			{Color: "good", Blocks: []SlackBlock{
+				{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: fmt.Sprintf("*%s*\nThe alert condition is no longer met.", text)}},
+			}},
+		},
+	}
+
+	_, err := s.post(msg, thread)
+	return err
+}
+
+// SendDigest posts several alerts as one message, e.g. those held during quiet hours
+func (s *SlackNotification) SendDigest(alerts []SlackAlert) error {
+	lines := make([]string, len(alerts))
+	for i, alert := range alerts {
+		lines[i] = fmt.Sprintf("• *%s* — %d events (limit: %d) at %s",
+			alert.Title, alert.Count, alert.Threshold, alert.FiredAt.Format("15:04"))
+	}
+
+	header := fmt.Sprintf("🚨 %d alerts while you were away", len(alerts))
+	msg := SlackMessage{
+		Text:      header,
+		Username:  "Peep",
+		IconEmoji: ":rotating_light:",
+		Channel:   s.config.Channel,
+		Attachments: []SlackAttachment{
+			{Color: "warning", Blocks: []SlackBlock{
+				{Type: "header", Text: &SlackText{Type: "plain_text", Text: header}},
+				{Type: "section", Text: &SlackText{Type: "mrkdwn", Text: strings.Join(lines, "\n")}},
+				{Type: "actions", Elements: []interface{}{
+					SlackButton{
+						Type:     "button",
+						Text:     &SlackText{Type: "plain_text", Text: "View in Peep"},
+						ActionID: "peep_view",
+						URL:      s.config.PeepURL + "/alerts",
 					},
-				},
-				Footer:     "Peep Observability",
-				Timestamp:  time.Now().Unix(),
-				MarkdownIn: []string{"text", "fields"},
-			},
+				}},
+			}},
 		},
 	}
 
-	return sendSlackWebhook(webhookURL, slackMsg)
+	_, err := s.post(msg, nil)
+	return err
+}
+
+// SendMessage posts a plain message that isn't about an alert rule
+func (s *SlackNotification) SendMessage(text string) error {
+	_, err := s.post(SlackMessage{
+		Text:      text,
+		Username:  "Peep",
+		IconEmoji: ":mag:",
+		Channel:   s.config.Channel,
+	}, nil)
+	return err
+}
+
+// post delivers a message through the Web API when a bot token is configured, or the webhook otherwise
+func (s *SlackNotification) post(msg SlackMessage, thread *SlackThread) (SlackThread, error) {
+	if thread != nil {
+		msg.ThreadTS = thread.TS
+		if thread.Channel != "" {
+			msg.Channel = thread.Channel
+		}
+	}
+
+	if s.config.BotToken != "" {
+		if msg.Channel == "" {
+			return SlackThread{}, fmt.Errorf("slack bot token requires a channel")
+		}
+		return postSlackAPI(s.config.BotToken, msg)
+	}
+
+	if s.config.WebhookURL == "" {
+		return SlackThread{}, fmt.Errorf("slack channel needs a webhook URL or bot token")
+	}
+	return SlackThread{}, sendSlackWebhook(s.config.WebhookURL, msg)
+}
+
+// SendSlackNotification sends a notification to Slack via webhook
+func SendSlackNotification(webhookURL, title, message string, count, threshold int) error {
+	notifier := NewSlackNotification(SlackConfig{WebhookURL: webhookURL})
+	_, err := notifier.SendAlert(SlackAlert{
+		Title:     title,
+		Message:   message,
+		Count:     count,
+		Threshold: threshold,
+	}, nil)
+	return err
 }
 
 // SendSlackMessage sends a simple text message to Slack
@@ -94,7 +318,7 @@ func sendSlackWebhook(webhookURL string, message SlackMessage) error {
 		return fmt.Errorf("failed to marshal Slack message: %w", err)
 	}
 
-	resp, err := http.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
+	resp, err := slackClient.Post(webhookURL, "application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to send Slack webhook: %w", err)
 	}
@@ -107,6 +331,48 @@ func sendSlackWebhook(webhookURL string, message SlackMessage) error {
 	return nil
 }
 
+// postSlackAPI sends a message with chat.postMessage and returns where it was posted
+func postSlackAPI(token string, message SlackMessage) (SlackThread, error) {
+	jsonData, err := json.Marshal(message)
+	if err != nil {
+		return SlackThread{}, fmt.Errorf("failed to marshal Slack message: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", slackPostMessageURL, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return SlackThread{}, fmt.Errorf("failed to create Slack request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := slackClient.Do(req)
+	if err != nil {
+		return SlackThread{}, fmt.Errorf("failed to call Slack API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var result struct {
+		OK      bool   `json:"ok"`
+		Error   string `json:"error"`
+		Channel string `json:"channel"`
+		TS      string `json:"ts"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return SlackThread{}, fmt.Errorf("failed to decode Slack API response: %w", err)
+	}
+	if !result.OK {
+		return SlackThread{}, fmt.Errorf("Slack API error: %s", result.Error)
+	}
+
+	// Replies keep the root message's timestamp as the thread
+	ts := result.TS
+	if message.ThreadTS != "" {
+		ts = message.ThreadTS
+	}
+
+	return SlackThread{Channel: result.Channel, TS: ts}, nil
+}
+
 // getAlertColor returns appropriate color based on alert severity
 func getAlertColor(count, threshold int) string {
 	ratio := float64(count) / float64(threshold)
@@ -0,0 +1,190 @@
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed five-field cron expression: minute, hour, day of month,
+// month, and day of week
+type Cron struct {
+	minutes  [60]bool
+	hours    [24]bool
+	days     [32]bool // 1-31
+	months   [13]bool // 1-12
+	weekdays [7]bool  // 0-6, Sunday is 0
+
+	// Standard cron matches either day field when both are restricted
+	anyDay     bool
+	anyWeekday bool
+}
+
+var cronMacros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// ParseCron parses a cron expression such as "0 3 * * *" (every day at 03:00)
+// or "*/15 9-17 * * mon-fri". Fields take *, numbers, ranges (a-b), steps
+// (*/n or a-b/n), and lists (a,b,c); months and weekdays also take names.
+// The macros @hourly, @daily, @midnight, @weekly, @monthly, and @yearly work too.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if macro, ok := cronMacros[strings.ToLower(spec)]; ok {
+		spec = macro
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid cron expression %q (expected 5 fields: minute hour day month weekday)", expr)
+	}
+
+	c := &Cron{}
+	if err := parseField(fields[0], 0, 59, nil, c.minutes[:]); err != nil {
+		return nil, fmt.Errorf("invalid minute field: %w", err)
+	}
+	if err := parseField(fields[1], 0, 23, nil, c.hours[:]); err != nil {
+		return nil, fmt.Errorf("invalid hour field: %w", err)
+	}
+	if err := parseField(fields[2], 1, 31, nil, c.days[:]); err != nil {
+		return nil, fmt.Errorf("invalid day of month field: %w", err)
+	}
+	if err := parseField(fields[3], 1, 12, monthNames, c.months[:]); err != nil {
+		return nil, fmt.Errorf("invalid month field: %w", err)
+	}
+
+	// Weekday 7 is Sunday too
+	var weekdays [8]bool
+	if err := parseField(fields[4], 0, 7, dayNames, weekdays[:]); err != nil {
+		return nil, fmt.Errorf("invalid weekday field: %w", err)
+	}
+	copy(c.weekdays[:], weekdays[:7])
+	if weekdays[7] {
+		c.weekdays[0] = true
+	}
+
+	c.anyDay = fields[2] == "*"
+	c.anyWeekday = fields[4] == "*"
+	return c, nil
+}
+
+// parseField marks the values a field matches in set, which is indexed by value
+func parseField(field string, min, max int, names map[string]int, set []bool) error {
+	for _, term := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(term, "/"); i >= 0 {
+			n, err := strconv.Atoi(term[i+1:])
+			if err != nil || n <= 0 {
+				return fmt.Errorf("invalid step in %q", term)
+			}
+			step = n
+			term = term[:i]
+		}
+
+		first, last := min, max
+		switch {
+		case term == "*":
+		case strings.Contains(term, "-"):
+			bounds := strings.SplitN(term, "-", 2)
+			var err error
+			if first, err = parseValue(bounds[0], min, max, names); err != nil {
+				return err
+			}
+			if last, err = parseValue(bounds[1], min, max, names); err != nil {
+				return err
+			}
+			if first > last {
+				return fmt.Errorf("invalid range %q", term)
+			}
+		default:
+			value, err := parseValue(term, min, max, names)
+			if err != nil {
+				return err
+			}
+			first, last = value, value
+			if step > 1 {
+				last = max // "5/15" means from 5 on, every 15
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			set[v] = true
+		}
+	}
+	return nil
+}
+
+func parseValue(s string, min, max int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < min || v > max {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, min, max)
+	}
+	return v, nil
+}
+
+// matchesDay applies cron's rule for the two day fields: when both are
+// restricted, a day matching either one counts
+func (c *Cron) matchesDay(t time.Time) bool {
+	day, weekday := c.days[t.Day()], c.weekdays[t.Weekday()]
+	switch {
+	case c.anyDay && c.anyWeekday:
+		return true
+	case c.anyDay:
+		return weekday
+	case c.anyWeekday:
+		return day
+	default:
+		return day || weekday
+	}
+}
+
+// Next returns the first time after t the expression matches, to the minute,
+// in t's location. It returns the zero time if nothing matches within five
+// years (e.g. "0 0 30 2 *").
+func (c *Cron) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !c.months[t.Month()] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchesDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.hours[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.minutes[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sort"
+	"time"
+)
+
+// Agent is a peep agent that forwards logs to this database
+type Agent struct {
+	ID         string    `json:"id"`         // Random ID the agent keeps in its buffer database
+	Name       string    `json:"name"`       // Its hostname, unless set with --name
+	Address    string    `json:"address"`    // Where its last batch came from
+	LastSeq    int64     `json:"last_seq"`   // Highest sequence number stored from it
+	Received   int       `json:"received"`   // Logs stored from it
+	Duplicates int       `json:"duplicates"` // Resent logs that were dropped
+	FirstSeen  time.Time `json:"first_seen"`
+	LastSeen   time.Time `json:"last_seen"`
+}
+
+// SequencedEntry is a log numbered by the agent that sent it. Agents number
+// logs in the order they send them, so a batch resent after a lost response
+// is recognised and not stored twice.
+type SequencedEntry struct {
+	Seq int64
+	LogEntry
+}
+
+func (s *Storage) createAgentsTable() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS agents (
+		id TEXT PRIMARY KEY,
+		name TEXT NOT NULL,
+		address TEXT NOT NULL DEFAULT '',
+		last_seq INTEGER NOT NULL DEFAULT 0,
+		received INTEGER NOT NULL DEFAULT 0,
+		duplicates INTEGER NOT NULL DEFAULT 0,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL
+	);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// StoreAgentBatch stores the logs in a batch from an agent that it hasn't
+// sent before, and records that the agent was seen. An empty batch is a
+// heartbeat. It returns how many logs were stored and how many were resends.
+func (s *Storage) StoreAgentBatch(agent Agent, entries []SequencedEntry) (stored, duplicates int, err error) {
+	sort.SliceStable(entries, func(i, j int) bool { return entries[i].Seq < entries[j].Seq })
+
+	start := time.Now()
+	err = s.write(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		stored, duplicates, err = storeAgentBatch(tx, agent, entries)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+
+	s.ops.inserts.Add(int64(stored))
+	s.ops.insertNanos.Add(int64(time.Since(start)))
+	return stored, duplicates, nil
+}
+
+func storeAgentBatch(tx *sql.Tx, agent Agent, entries []SequencedEntry) (stored, duplicates int, err error) {
+	// Read in the transaction, so two copies of a batch arriving at once can't both be stored
+	var lastSeq int64
+	err = tx.QueryRow("SELECT last_seq FROM agents WHERE id = ?", agent.ID).Scan(&lastSeq)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return 0, 0, err
+	}
+
+	stmt, err := tx.Prepare("INSERT INTO logs (timestamp, level, message, service, context, raw_log) VALUES (?, ?, ?, ?, ?, ?)")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer stmt.Close()
+
+	for _, entry := range entries {
+		if entry.Seq <= lastSeq {
+			duplicates++
+			continue
+		}
+		if _, err := stmt.Exec(entry.Timestamp, entry.Level, entry.Message, entry.Service, entry.Context, entry.RawLog); err != nil {
+			return 0, 0, err
+		}
+		lastSeq = entry.Seq
+		stored++
+	}
+
+	now := time.Now().UTC()
+	_, err = tx.Exec(`
+	INSERT INTO agents (id, name, address, last_seq, received, duplicates, first_seen, last_seen)
+	VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	ON CONFLICT(id) DO UPDATE SET
+		name = excluded.name,
+		address = excluded.address,
+		last_seq = excluded.last_seq,
+		received = received + excluded.received,
+		duplicates = duplicates + excluded.duplicates,
+		last_seen = excluded.last_seen`,
+		agent.ID, agent.Name, agent.Address, lastSeq, stored, duplicates, now, now)
+	if err != nil {
+		return 0, 0, err
+	}
+	return stored, duplicates, nil
+}
+
+// ListAgents returns the agents that have sent logs, most recently seen first
+func (s *Storage) ListAgents() ([]Agent, error) {
+	rows, err := s.db.Query(`
+	SELECT id, name, address, last_seq, received, duplicates, first_seen, last_seen
+	FROM agents ORDER BY last_seen DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var agents []Agent
+	for rows.Next() {
+		var a Agent
+		if err := rows.Scan(&a.ID, &a.Name, &a.Address, &a.LastSeq, &a.Received, &a.Duplicates, &a.FirstSeen, &a.LastSeen); err != nil {
+			return nil, err
+		}
+		agents = append(agents, a)
+	}
+	return agents, rows.Err()
+}
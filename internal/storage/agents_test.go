@@ -0,0 +1,49 @@
+package storage
+
+import "testing"
+
+// sequenced returns logs numbered from first to last
+func sequenced(first, last int64) []SequencedEntry {
+	var entries []SequencedEntry
+	for seq := first; seq <= last; seq++ {
+		entries = append(entries, SequencedEntry{Seq: seq, LogEntry: LogEntry{Level: "info", Message: "hello"}})
+	}
+	return entries
+}
+
+func TestStoreAgentBatchDedup(t *testing.T) {
+	store := newTestStorage(t)
+	agent := Agent{ID: "a1", Name: "web-1", Address: "10.0.0.1"}
+
+	tests := []struct {
+		name               string
+		entries            []SequencedEntry
+		stored, duplicates int
+	}{
+		{"first batch", sequenced(1, 5), 5, 0},
+		{"resent after a lost response", sequenced(1, 5), 0, 5},
+		{"overlapping", sequenced(4, 8), 3, 2},
+		{"out of order", append(sequenced(10, 11), sequenced(9, 9)...), 3, 0},
+		{"heartbeat", nil, 0, 0},
+	}
+	for _, tt := range tests {
+		stored, duplicates, err := store.StoreAgentBatch(agent, tt.entries)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if stored != tt.stored || duplicates != tt.duplicates {
+			t.Errorf("%s: stored %d with %d duplicates, want %d with %d", tt.name, stored, duplicates, tt.stored, tt.duplicates)
+		}
+	}
+
+	if got := countLogs(t, store); got != 11 {
+		t.Errorf("%d logs stored, want 11", got)
+	}
+	agents, err := store.ListAgents()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 1 || agents[0].LastSeq != 11 || agents[0].Received != 11 || agents[0].Duplicates != 7 {
+		t.Errorf("got agents %+v, want a1 at seq 11 with 11 received and 7 duplicates", agents)
+	}
+}
@@ -0,0 +1,234 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+// Dashboard panel types
+const (
+	PanelStat       = "stat"       // First value of the first row, shown as a big number
+	PanelTimeSeries = "timeseries" // A time column plus numeric columns, charted as lines
+	PanelTable      = "table"      // Rows as a table, for top-N style queries
+	PanelSLO        = "slo"        // An SLO's SLI and error budget; the query is the SLO's name
+)
+
+// PanelTypes lists the panel types in the order the builder offers them
+var PanelTypes = []string{PanelStat, PanelTimeSeries, PanelTable, PanelSLO}
+
+// Dashboard is a user-defined page of panels, shown at /dashboards/{name}
+type Dashboard struct {
+	ID          int64            `json:"id"`
+	Name        string           `json:"name"` // URL slug
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Panels      []DashboardPanel `json:"panels"`
+	CreatedAt   time.Time        `json:"created_at"`
+	UpdatedAt   time.Time        `json:"updated_at"`
+}
+
+// DashboardPanel is one SQL query rendered as a stat, chart, or table
+type DashboardPanel struct {
+	ID    int64  `json:"id"`
+	Title string `json:"title"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+var dashboardNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// Validate checks the fields a dashboard needs before it can be saved
+func (d *Dashboard) Validate() error {
+	if !dashboardNamePattern.MatchString(d.Name) {
+		return fmt.Errorf("name must be lowercase letters, numbers, dashes, or underscores")
+	}
+	if d.Name == "new" {
+		return fmt.Errorf("%q is reserved", d.Name)
+	}
+	if d.Title == "" {
+		return fmt.Errorf("title is required")
+	}
+	for i, panel := range d.Panels {
+		if panel.Query == "" {
+			return fmt.Errorf("panel %d: query is required", i+1)
+		}
+		switch panel.Type {
+		case PanelStat, PanelTimeSeries, PanelTable, PanelSLO:
+		default:
+			return fmt.Errorf("panel %d: unknown type %q", i+1, panel.Type)
+		}
+	}
+	return nil
+}
+
+func (s *Storage) createDashboardTables() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS dashboards (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		title TEXT NOT NULL,
+		description TEXT,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE TABLE IF NOT EXISTS dashboard_panels (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		dashboard_id INTEGER NOT NULL,
+		position INTEGER NOT NULL,
+		title TEXT,
+		type TEXT NOT NULL,
+		query TEXT NOT NULL,
+		FOREIGN KEY (dashboard_id) REFERENCES dashboards(id)
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_dashboard_panels_dashboard ON dashboard_panels(dashboard_id, position);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// ListDashboards returns all dashboards by title, without their panels
+func (s *Storage) ListDashboards() ([]Dashboard, error) {
+	rows, err := s.db.Query(`
+	SELECT id, name, title, description, created_at, updated_at
+	FROM dashboards
+	ORDER BY title
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var dashboards []Dashboard
+	for rows.Next() {
+		var d Dashboard
+		var description sql.NullString
+		if err := rows.Scan(&d.ID, &d.Name, &d.Title, &description, &d.CreatedAt, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		d.Description = description.String
+		dashboards = append(dashboards, d)
+	}
+
+	return dashboards, rows.Err()
+}
+
+// GetDashboard returns a dashboard and its panels by name, or sql.ErrNoRows
+func (s *Storage) GetDashboard(name string) (*Dashboard, error) {
+	d := &Dashboard{}
+	var description sql.NullString
+	err := s.db.QueryRow(`
+	SELECT id, name, title, description, created_at, updated_at
+	FROM dashboards
+	WHERE name = ?
+	`, name).Scan(&d.ID, &d.Name, &d.Title, &description, &d.CreatedAt, &d.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	d.Description = description.String
+
+	rows, err := s.db.Query(`
+	SELECT id, title, type, query
+	FROM dashboard_panels
+	WHERE dashboard_id = ?
+	ORDER BY position
+	`, d.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var panel DashboardPanel
+		var title sql.NullString
+		if err := rows.Scan(&panel.ID, &title, &panel.Type, &panel.Query); err != nil {
+			return nil, err
+		}
+		panel.Title = title.String
+		d.Panels = append(d.Panels, panel)
+	}
+
+	return d, rows.Err()
+}
+
+// SaveDashboard creates the dashboard, or updates it when ID is set. Panels are
+// replaced wholesale, in the order given.
+func (s *Storage) SaveDashboard(d *Dashboard) error {
+	if err := d.Validate(); err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	if d.ID == 0 {
+		result, err := tx.Exec(`
+		INSERT INTO dashboards (name, title, description, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?)
+		`, d.Name, d.Title, d.Description, now, now)
+		if err != nil {
+			return fmt.Errorf("failed to create dashboard: %w", err)
+		}
+		if d.ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+		d.CreatedAt = now
+	} else {
+		_, err := tx.Exec(`
+		UPDATE dashboards SET name = ?, title = ?, description = ?, updated_at = ?
+		WHERE id = ?
+		`, d.Name, d.Title, d.Description, now, d.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update dashboard: %w", err)
+		}
+		if _, err := tx.Exec(`DELETE FROM dashboard_panels WHERE dashboard_id = ?`, d.ID); err != nil {
+			return err
+		}
+	}
+	d.UpdatedAt = now
+
+	for i := range d.Panels {
+		result, err := tx.Exec(`
+		INSERT INTO dashboard_panels (dashboard_id, position, title, type, query)
+		VALUES (?, ?, ?, ?, ?)
+		`, d.ID, i, d.Panels[i].Title, d.Panels[i].Type, d.Panels[i].Query)
+		if err != nil {
+			return fmt.Errorf("failed to save panel %d: %w", i+1, err)
+		}
+		if d.Panels[i].ID, err = result.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// DeleteDashboard removes a dashboard and its panels
+func (s *Storage) DeleteDashboard(name string) error {
+	d, err := s.GetDashboard(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM dashboard_panels WHERE dashboard_id = ?`, d.ID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(`DELETE FROM dashboards WHERE id = ?`, d.ID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
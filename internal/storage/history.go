@@ -0,0 +1,91 @@
+package storage
+
+import (
+	"database/sql"
+	"time"
+)
+
+// QueryHistoryEntry records one query run from the SQL console
+type QueryHistoryEntry struct {
+	ID         int64         `json:"id"`
+	Query      string        `json:"query"`
+	Duration   time.Duration `json:"duration"`
+	RowCount   int           `json:"row_count"`
+	User       string        `json:"user"`
+	Error      string        `json:"error,omitempty"`
+	ExecutedAt time.Time     `json:"executed_at"`
+}
+
+// maxQueryHistory caps the history table so it can't grow without bound
+const maxQueryHistory = 1000
+
+func (s *Storage) createHistoryTables() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS query_history (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		query TEXT NOT NULL,
+		duration_ms INTEGER NOT NULL,
+		row_count INTEGER NOT NULL,
+		user TEXT NOT NULL,
+		error TEXT,
+		executed_at DATETIME DEFAULT CURRENT_TIMESTAMP
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_query_history_executed_at ON query_history(executed_at);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// RecordQuery adds a query to the history, trimming the oldest entries past the cap
+func (s *Storage) RecordQuery(entry QueryHistoryEntry) error {
+	if entry.ExecutedAt.IsZero() {
+		entry.ExecutedAt = time.Now()
+	}
+
+	_, err := s.db.Exec(`
+	INSERT INTO query_history (query, duration_ms, row_count, user, error, executed_at)
+	VALUES (?, ?, ?, ?, ?, ?)
+	`, entry.Query, entry.Duration.Milliseconds(), entry.RowCount, entry.User, entry.Error, entry.ExecutedAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.db.Exec(`
+	DELETE FROM query_history
+	WHERE id <= (SELECT id FROM query_history ORDER BY id DESC LIMIT 1 OFFSET ?)
+	`, maxQueryHistory)
+	return err
+}
+
+// RecentQueries returns the most recently executed queries, newest first
+func (s *Storage) RecentQueries(limit int) ([]QueryHistoryEntry, error) {
+	rows, err := s.db.Query(`
+	SELECT id, query, duration_ms, row_count, user, error, executed_at
+	FROM query_history
+	ORDER BY id DESC
+	LIMIT ?
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var history []QueryHistoryEntry
+	for rows.Next() {
+		var entry QueryHistoryEntry
+		var durationMS int64
+		var errorMsg sql.NullString
+
+		if err := rows.Scan(&entry.ID, &entry.Query, &durationMS, &entry.RowCount, &entry.User, &errorMsg, &entry.ExecutedAt); err != nil {
+			return nil, err
+		}
+
+		entry.Duration = time.Duration(durationMS) * time.Millisecond
+		entry.Error = errorMsg.String
+		history = append(history, entry)
+	}
+
+	return history, rows.Err()
+}
@@ -0,0 +1,103 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Metric is one sample of a numeric series. Series are told apart by name and
+// labels, e.g. peep.ingest.eps or http.latency_ms{service="api"}.
+type Metric struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Name      string            `json:"name"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     float64           `json:"value"`
+}
+
+func (s *Storage) createMetricsTable() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS metrics (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		timestamp DATETIME NOT NULL,
+		name TEXT NOT NULL,
+		labels TEXT NOT NULL DEFAULT '{}', -- JSON, for json_extract
+		value REAL NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_metrics_name_timestamp ON metrics(name, timestamp);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// InsertMetrics stores samples in one transaction. Timestamps are stored in
+// UTC, so they compare correctly with SQLite's datetime('now', ...).
+func (s *Storage) InsertMetrics(metrics []Metric) error {
+	return s.write(context.Background(), func(tx *sql.Tx) error {
+		return insertMetrics(tx, metrics)
+	})
+}
+
+func insertMetrics(tx *sql.Tx, metrics []Metric) error {
+	stmt, err := tx.Prepare("INSERT INTO metrics (timestamp, name, labels, value) VALUES (?, ?, ?, ?)")
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		labels := "{}"
+		if len(m.Labels) > 0 {
+			encoded, err := json.Marshal(m.Labels) // Keys are sorted, so equal labels store equal text
+			if err != nil {
+				return err
+			}
+			labels = string(encoded)
+		}
+		timestamp := m.Timestamp
+		if timestamp.IsZero() {
+			timestamp = time.Now()
+		}
+		if _, err := stmt.Exec(timestamp.UTC(), m.Name, labels, m.Value); err != nil {
+			return fmt.Errorf("failed to store metric %s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// DeleteMetricsBefore removes samples older than cutoff and returns how many
+func (s *Storage) DeleteMetricsBefore(cutoff time.Time) (int64, error) {
+	return s.deleteBefore("DELETE FROM metrics WHERE timestamp < ?", cutoff.UTC())
+}
+
+// OpStats counts this process's storage work since it opened the database,
+// for Peep's own telemetry
+type OpStats struct {
+	Inserts        int64         // Logs stored with InsertLog
+	InsertTime     time.Duration // Total time spent in those inserts
+	Cleanups       int64         // Retention cleanups run
+	CleanupDeleted int64         // Logs those cleanups deleted
+}
+
+// opCounters backs OpStats
+type opCounters struct {
+	inserts        atomic.Int64
+	insertNanos    atomic.Int64
+	cleanups       atomic.Int64
+	cleanupDeleted atomic.Int64
+}
+
+// OpStats returns the running totals
+func (s *Storage) OpStats() OpStats {
+	return OpStats{
+		Inserts:        s.ops.inserts.Load(),
+		InsertTime:     time.Duration(s.ops.insertNanos.Load()),
+		Cleanups:       s.ops.cleanups.Load(),
+		CleanupDeleted: s.ops.cleanupDeleted.Load(),
+	}
+}
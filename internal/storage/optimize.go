@@ -0,0 +1,439 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// peep optimize refreshes the query planner's statistics and checks a query
+// workload for tables it scans in full, suggesting indexes that would turn
+// those scans into searches.
+
+// Analyze refreshes the statistics SQLite's query planner uses to pick indexes
+func (s *Storage) Analyze(ctx context.Context) error {
+	_, err := s.db.ExecContext(ctx, "ANALYZE")
+	return err
+}
+
+// HistoryQueries returns the distinct queries in the query history that ran
+// without an error, most recently run first
+func (s *Storage) HistoryQueries(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT query FROM query_history
+	WHERE error IS NULL OR error = ''
+	GROUP BY query ORDER BY MAX(id) DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var queries []string
+	for rows.Next() {
+		var query string
+		if err := rows.Scan(&query); err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+	}
+	return queries, rows.Err()
+}
+
+// IndexSuggestion is an index the workload is missing
+type IndexSuggestion struct {
+	Name    string
+	Table   string
+	Columns []string // Columns or json_extract expressions, in index order
+	Queries []string // The workload queries that would use it
+}
+
+// SQL is the statement creating the index
+func (i IndexSuggestion) SQL() string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s(%s)", i.Name, i.Table, strings.Join(i.Columns, ", "))
+}
+
+// IndexReport is what AdviseIndexes found
+type IndexReport struct {
+	Queries int // Workload queries planned
+	Skipped int // Queries that couldn't be planned, e.g. ones no longer valid
+
+	Missing []IndexSuggestion
+
+	// Indexes no workload query used. peep's own idx_ indexes are left out,
+	// since peep's built-in reads rely on them whatever the workload is.
+	Unused []string
+}
+
+// suggestedIndexPrefix starts the names of the indexes AdviseIndexes suggests
+const suggestedIndexPrefix = "opt_"
+
+// AdviseIndexes plans each workload query and looks for tables read with a
+// full scan. For each it suggests an index on the columns the query compares
+// with =, IN, or a range, and keeps the suggestion only if SQLite's planner
+// would actually use it: that's checked on an empty copy of the schema,
+// carrying the current statistics, so nothing is built on the real database.
+func (s *Storage) AdviseIndexes(ctx context.Context, queries []string) (*IndexReport, error) {
+	tables, err := s.tableColumns(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IndexReport{}
+	used := make(map[string]bool)
+	candidates := make(map[string]*IndexSuggestion)
+	var order []string
+	for _, query := range queries {
+		query, err := ReadStatement(query)
+		if err != nil || strings.HasPrefix(strings.ToUpper(query), "EXPLAIN") {
+			report.Skipped++
+			continue
+		}
+		steps, err := explainPlan(ctx, s.db, query)
+		if err != nil {
+			report.Skipped++
+			continue
+		}
+		report.Queries++
+
+		aliases := tableAliases(query, tables)
+		for _, step := range steps {
+			if m := planIndexPattern.FindStringSubmatch(step); m != nil {
+				used[m[1]] = true
+				continue
+			}
+			m := planScanPattern.FindStringSubmatch(step)
+			if m == nil {
+				continue
+			}
+			ref := strings.ToLower(m[1])
+			table, ok := aliases[ref]
+			if !ok {
+				continue
+			}
+			columns := indexColumns(query, ref, table, tables[table])
+			if len(columns) == 0 {
+				continue
+			}
+
+			key := table + "(" + strings.Join(columns, ", ") + ")"
+			candidate, ok := candidates[key]
+			if !ok {
+				candidate = &IndexSuggestion{Name: suggestedIndexName(table, columns), Table: table, Columns: columns}
+				candidates[key] = candidate
+				order = append(order, key)
+			}
+			if !containsString(candidate.Queries, query) {
+				candidate.Queries = append(candidate.Queries, query)
+			}
+		}
+	}
+
+	if len(candidates) > 0 {
+		missing, err := s.checkSuggestions(ctx, candidates, order)
+		if err != nil {
+			return nil, err
+		}
+		report.Missing = missing
+	}
+
+	if report.Queries > 0 {
+		indexes, err := s.indexNames(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range indexes {
+			if !used[name] && !strings.HasPrefix(name, "idx_") {
+				report.Unused = append(report.Unused, name)
+			}
+		}
+	}
+	return report, nil
+}
+
+// CreateIndex builds a suggested index
+func (s *Storage) CreateIndex(ctx context.Context, index IndexSuggestion) error {
+	_, err := s.db.ExecContext(ctx, index.SQL())
+	return err
+}
+
+// EXPLAIN QUERY PLAN details: "SCAN logs" or "SCAN l" for a full scan, and
+// "SEARCH logs USING INDEX idx (level=?)" or "SCAN logs USING COVERING INDEX
+// idx" for reads through an index
+var (
+	planScanPattern  = regexp.MustCompile(`^SCAN (?:TABLE )?(\w+)(?: AS \w+)?$`)
+	planIndexPattern = regexp.MustCompile(`USING (?:COVERING )?INDEX (\w+)`)
+)
+
+// explainPlan returns the details of a query's plan, without running it
+func explainPlan(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var steps []string
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			return nil, err
+		}
+		steps = append(steps, detail)
+	}
+	return steps, rows.Err()
+}
+
+// tableColumns returns each table's columns, lower-cased
+func (s *Storage) tableColumns(ctx context.Context) (map[string]map[string]bool, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT m.name, LOWER(p.name) FROM sqlite_master m, pragma_table_info(m.name) p
+	WHERE m.type = 'table' AND m.name NOT LIKE 'sqlite_%'`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tables := make(map[string]map[string]bool)
+	for rows.Next() {
+		var table, column string
+		if err := rows.Scan(&table, &column); err != nil {
+			return nil, err
+		}
+		if tables[table] == nil {
+			tables[table] = make(map[string]bool)
+		}
+		tables[table][column] = true
+	}
+	return tables, rows.Err()
+}
+
+// indexNames returns the database's named indexes, leaving out the ones
+// SQLite makes for UNIQUE and PRIMARY KEY constraints
+func (s *Storage) indexNames(ctx context.Context) ([]string, error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT name FROM sqlite_master WHERE type = 'index' AND sql IS NOT NULL ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+	return names, rows.Err()
+}
+
+// tableReference matches a table after FROM or JOIN, with its alias if it has one
+var tableReference = regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+(?:main\.)?(\w+)(?:\s+(?:AS\s+)?(\w+))?`)
+
+// notAliases are the keywords that can follow a table name in place of an alias
+var notAliases = map[string]bool{
+	"WHERE": true, "JOIN": true, "LEFT": true, "RIGHT": true, "INNER": true, "OUTER": true,
+	"CROSS": true, "FULL": true, "NATURAL": true, "ON": true, "USING": true, "GROUP": true,
+	"ORDER": true, "LIMIT": true, "HAVING": true, "WINDOW": true, "UNION": true,
+	"INTERSECT": true, "EXCEPT": true, "INDEXED": true, "NOT": true,
+}
+
+// tableAliases maps the names a query's plan can show for each table it
+// reads, the table's own name or its alias, to the table
+func tableAliases(query string, tables map[string]map[string]bool) map[string]string {
+	aliases := make(map[string]string)
+	for _, m := range tableReference.FindAllStringSubmatch(query, -1) {
+		table := strings.ToLower(m[1])
+		if tables[table] == nil {
+			continue
+		}
+		aliases[table] = table
+		if m[2] != "" && !notAliases[strings.ToUpper(m[2])] {
+			aliases[strings.ToLower(m[2])] = table
+		}
+	}
+	return aliases
+}
+
+// comparison matches a column or json_extract(context, '$.path') compared
+// with =, IN, IS, or a range operator, optionally qualified by a table or alias
+var comparison = regexp.MustCompile(`(?i)(json_extract\(\s*(?:(\w+)\.)?context\s*,\s*'[^']*'\s*\)|(?:(\w+)\.)?(\w+))\s*(==|=|IN\b|IS\b|<=|>=|<|>|BETWEEN\b)`)
+
+// indexColumns picks the columns for an index serving a query's full scan of
+// table (named ref in the plan): the columns compared for equality, in the
+// order the query names them, then the first compared by range, which an
+// index can only use last
+func indexColumns(query, ref, table string, columns map[string]bool) []string {
+	var equal []string
+	var ranged string
+	for _, m := range comparison.FindAllStringSubmatch(query, -1) {
+		var term, qualifier string
+		if strings.HasPrefix(strings.ToLower(m[1]), "json_extract") {
+			if !columns["context"] {
+				continue
+			}
+			term, qualifier = strings.Join(strings.Fields(m[1]), " "), m[2]
+		} else {
+			if !columns[strings.ToLower(m[4])] {
+				continue
+			}
+			term, qualifier = strings.ToLower(m[4]), m[3]
+		}
+		if qualifier = strings.ToLower(qualifier); qualifier != "" && qualifier != ref && qualifier != table {
+			continue
+		}
+
+		switch strings.ToUpper(m[5]) {
+		case "=", "==", "IN", "IS":
+			if !containsString(equal, term) {
+				equal = append(equal, term)
+			}
+		default:
+			if ranged == "" {
+				ranged = term
+			}
+		}
+	}
+
+	if ranged != "" && !containsString(equal, ranged) {
+		return append(equal, ranged)
+	}
+	return equal
+}
+
+// suggestedIndexName names an index after its table and columns, e.g.
+// opt_logs_service_level, or opt_logs_user_id for json_extract(context, '$.user_id')
+func suggestedIndexName(table string, columns []string) string {
+	parts := []string{strings.TrimSuffix(suggestedIndexPrefix, "_"), table}
+	for _, column := range columns {
+		if i := strings.Index(column, "'$."); i >= 0 {
+			column = column[i+3 : strings.LastIndex(column, "'")]
+		}
+		parts = append(parts, nonWordRun.ReplaceAllString(column, "_"))
+	}
+	return strings.Join(parts, "_")
+}
+
+var nonWordRun = regexp.MustCompile(`\W+`)
+
+// checkSuggestions keeps the candidate indexes the planner would use for at
+// least one of their queries, trying each on an in-memory copy of the schema
+func (s *Storage) checkSuggestions(ctx context.Context, candidates map[string]*IndexSuggestion, order []string) ([]IndexSuggestion, error) {
+	scratch, err := s.schemaCopy(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to copy the schema: %w", err)
+	}
+	defer scratch.Close()
+
+	var missing []IndexSuggestion
+	for _, key := range order {
+		candidate := candidates[key]
+		if _, err := scratch.ExecContext(ctx, "CREATE INDEX "+candidate.Name+" ON "+candidate.Table+"("+strings.Join(candidate.Columns, ", ")+")"); err != nil {
+			continue
+		}
+
+		var helped []string
+		for _, query := range candidate.Queries {
+			steps, err := explainPlan(ctx, scratch, query)
+			if err != nil {
+				continue
+			}
+			for _, step := range steps {
+				if m := planIndexPattern.FindStringSubmatch(step); m != nil && m[1] == candidate.Name {
+					helped = append(helped, query)
+					break
+				}
+			}
+		}
+		scratch.ExecContext(ctx, "DROP INDEX "+candidate.Name)
+
+		if len(helped) > 0 {
+			candidate.Queries = helped
+			missing = append(missing, *candidate)
+		}
+	}
+
+	// Most useful first
+	sort.SliceStable(missing, func(i, j int) bool { return len(missing[i].Queries) > len(missing[j].Queries) })
+	return missing, nil
+}
+
+// schemaCopy opens an in-memory database with this one's tables, indexes,
+// and planner statistics but none of its rows, so candidate indexes can be
+// tried without building them over real data
+func (s *Storage) schemaCopy(ctx context.Context) (*sql.DB, error) {
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT sql FROM sqlite_master
+	WHERE type IN ('table', 'index') AND sql IS NOT NULL AND name NOT LIKE 'sqlite_%'
+	ORDER BY type = 'index'`)
+	if err != nil {
+		return nil, err
+	}
+	var statements []string
+	for rows.Next() {
+		var statement string
+		if err := rows.Scan(&statement); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		statements = append(statements, statement)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	scratch, err := sql.Open(driverName, ":memory:")
+	if err != nil {
+		return nil, err
+	}
+	// Every connection to :memory: is its own database
+	scratch.SetMaxOpenConns(1)
+
+	for _, statement := range statements {
+		// Tables this build of SQLite can't create, like virtual tables from
+		// missing modules, are left out; their queries just won't plan
+		scratch.ExecContext(ctx, statement)
+	}
+
+	// ANALYZE on the empty copy creates sqlite_stat1, which then gets the real
+	// statistics; ANALYZE sqlite_master makes the planner load them
+	if _, err := scratch.ExecContext(ctx, "ANALYZE"); err != nil {
+		scratch.Close()
+		return nil, err
+	}
+	stats, err := s.db.QueryContext(ctx, "SELECT tbl, idx, stat FROM sqlite_stat1")
+	if err != nil {
+		// No statistics yet: the planner falls back on its defaults
+		return scratch, nil
+	}
+	defer stats.Close()
+	for stats.Next() {
+		var table, index, stat sql.NullString
+		if err := stats.Scan(&table, &index, &stat); err != nil {
+			scratch.Close()
+			return nil, err
+		}
+		scratch.ExecContext(ctx, "INSERT INTO sqlite_stat1 (tbl, idx, stat) VALUES (?, ?, ?)", table, index, stat)
+	}
+	if _, err := scratch.ExecContext(ctx, "ANALYZE sqlite_master"); err != nil {
+		scratch.Close()
+		return nil, err
+	}
+	return scratch, nil
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
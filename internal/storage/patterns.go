@@ -0,0 +1,348 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// A pattern is a log message with its variable parts masked, so "timeout
+// after 30s talking to 10.0.0.7:5432" and "timeout after 12s talking to
+// 10.0.0.9:5432" cluster as "timeout after <num>s talking to <ip>". Patterns
+// of warnings and errors are tracked per service, and one seen for the first
+// time, or again after a dormant stretch, is recorded as an anomaly.
+
+// PatternLevels are the levels whose messages are clustered into patterns
+var PatternLevels = []string{"warn", "warning", "error", "err", "fatal", "critical", "crit"}
+
+// Pattern anomaly kinds
+const (
+	PatternNew     = "new"     // Never seen before
+	PatternDormant = "dormant" // Back after going unseen for the dormancy period
+)
+
+// LogPattern is a cluster of messages that differ only in their variable parts
+type LogPattern struct {
+	ID        int64     `json:"id"`
+	Service   string    `json:"service"`
+	Pattern   string    `json:"pattern"`
+	Level     string    `json:"level"`   // Of the latest match
+	Example   string    `json:"example"` // The first message that matched
+	Count     int64     `json:"count"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+// PatternAnomaly is a pattern that appeared for the first time, or after
+// going unseen for the dormancy period
+type PatternAnomaly struct {
+	ID           int64     `json:"id"`
+	PatternID    int64     `json:"pattern_id"`
+	Kind         string    `json:"kind"` // PatternNew or PatternDormant
+	Service      string    `json:"service"`
+	Pattern      string    `json:"pattern"`
+	Level        string    `json:"level"`
+	Example      string    `json:"example"` // The message that brought it (back)
+	LogID        int64     `json:"log_id"`
+	PreviousSeen time.Time `json:"previous_seen,omitempty"` // When a dormant pattern was last seen before
+	Timestamp    time.Time `json:"timestamp"`               // When it was detected
+}
+
+// patternCursorKey is the setting holding the ID of the last log clustered
+const patternCursorKey = "pattern_cursor"
+
+// patternBatch is how many logs DetectPatterns reads at a time
+const patternBatch = 5000
+
+// maxPatternLength keeps very long messages from making huge patterns
+const maxPatternLength = 300
+
+// Masks applied in order, so a UUID isn't first cut into hex and numbers
+var patternMasks = []struct {
+	re   *regexp.Regexp
+	mask string
+}{
+	{regexp.MustCompile(`"[^"]*"|'[^']*'`), "<str>"},
+	{regexp.MustCompile(`(?i)\b[0-9a-f]{8}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{4}-[0-9a-f]{12}\b`), "<uuid>"},
+	{regexp.MustCompile(`\b\d{1,3}(\.\d{1,3}){3}(:\d+)?\b`), "<ip>"},
+	{regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:?\d{2})?`), "<time>"},
+	{regexp.MustCompile(`(?i)\b0x[0-9a-f]+\b|\b[0-9a-f]*[0-9][0-9a-f]*[a-f][0-9a-f]*\b`), "<hex>"},
+	{regexp.MustCompile(`\d+(\.\d+)?`), "<num>"},
+}
+
+// MessagePattern masks the variable parts of a message: quoted strings,
+// UUIDs, IP addresses, timestamps, hex IDs, and numbers
+func MessagePattern(message string) string {
+	pattern := strings.Join(strings.Fields(message), " ")
+	for _, m := range patternMasks {
+		pattern = m.re.ReplaceAllString(pattern, m.mask)
+	}
+	if len(pattern) > maxPatternLength {
+		pattern = pattern[:maxPatternLength]
+	}
+	return pattern
+}
+
+func (s *Storage) createPatternTables() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS log_patterns (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		service TEXT NOT NULL,
+		pattern TEXT NOT NULL,
+		level TEXT NOT NULL,
+		example TEXT NOT NULL,
+		count INTEGER NOT NULL DEFAULT 0,
+		first_seen DATETIME NOT NULL,
+		last_seen DATETIME NOT NULL
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_log_patterns_service_pattern ON log_patterns(service, pattern);
+
+	-- Has a timestamp column so alert rules can count it like logs:
+	-- SELECT COUNT(*) FROM pattern_anomalies
+	CREATE TABLE IF NOT EXISTS pattern_anomalies (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		pattern_id INTEGER NOT NULL,
+		kind TEXT NOT NULL,
+		service TEXT NOT NULL,
+		pattern TEXT NOT NULL,
+		level TEXT NOT NULL,
+		example TEXT NOT NULL,
+		log_id INTEGER NOT NULL,
+		previous_seen DATETIME,
+		timestamp DATETIME NOT NULL
+	);
+
+	CREATE INDEX IF NOT EXISTS idx_pattern_anomalies_timestamp ON pattern_anomalies(timestamp);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// DetectPatterns clusters the warnings and errors stored since the last call
+// and returns the patterns that are new, or back after going unseen for
+// dormancy, recording them in pattern_anomalies. The first call on a database
+// learns the patterns already there without flagging any, so existing logs
+// become the baseline rather than a flood of anomalies.
+func (s *Storage) DetectPatterns(ctx context.Context, dormancy time.Duration) ([]PatternAnomaly, error) {
+	var cursor struct {
+		LastID int64 `json:"last_id"`
+	}
+	_, found, err := s.GetSetting(patternCursorKey, &cursor)
+	if err != nil {
+		return nil, err
+	}
+	baseline := !found
+
+	levels := strings.Repeat("?, ", len(PatternLevels)-1) + "?"
+	var anomalies []PatternAnomaly
+	for {
+		args := []interface{}{cursor.LastID}
+		for _, level := range PatternLevels {
+			args = append(args, level)
+		}
+		args = append(args, patternBatch)
+
+		batchCtx, cancel := s.WithTimeout(ctx)
+		rows, err := s.db.QueryContext(batchCtx, `SELECT id, timestamp, COALESCE(LOWER(level), ''), COALESCE(service, ''), COALESCE(message, '')
+			FROM logs WHERE id > ? AND LOWER(level) IN (`+levels+`) ORDER BY id LIMIT ?`, args...)
+		if err != nil {
+			cancel()
+			return anomalies, err
+		}
+		var batch []LogEntry
+		for rows.Next() {
+			var entry LogEntry
+			var timestamp sql.NullTime
+			if err := rows.Scan(&entry.ID, &timestamp, &entry.Level, &entry.Service, &entry.Message); err != nil {
+				rows.Close()
+				cancel()
+				return anomalies, err
+			}
+			entry.Timestamp = timestamp.Time
+			batch = append(batch, entry)
+		}
+		rows.Close()
+		cancel()
+		if err := rows.Err(); err != nil {
+			return anomalies, err
+		}
+		if len(batch) == 0 {
+			break
+		}
+
+		detected, err := s.clusterBatch(batch, dormancy, baseline)
+		if err != nil {
+			return anomalies, err
+		}
+		anomalies = append(anomalies, detected...)
+
+		cursor.LastID = batch[len(batch)-1].ID
+		if err := s.PutSetting(patternCursorKey, cursor); err != nil {
+			return anomalies, err
+		}
+		if len(batch) < patternBatch {
+			break
+		}
+	}
+
+	// Nothing to learn yet still counts as the baseline
+	if !found {
+		if err := s.PutSetting(patternCursorKey, cursor); err != nil {
+			return anomalies, err
+		}
+	}
+	return anomalies, nil
+}
+
+// clusterBatch folds a batch of logs into log_patterns in one transaction
+func (s *Storage) clusterBatch(batch []LogEntry, dormancy time.Duration, baseline bool) ([]PatternAnomaly, error) {
+	var anomalies []PatternAnomaly
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		var err error
+		anomalies, err = clusterInTx(tx, batch, dormancy, baseline)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return anomalies, nil
+}
+
+func clusterInTx(tx *sql.Tx, batch []LogEntry, dormancy time.Duration, baseline bool) ([]PatternAnomaly, error) {
+	now := time.Now()
+	var anomalies []PatternAnomaly
+	for _, entry := range batch {
+		pattern := MessagePattern(entry.Message)
+		if pattern == "" {
+			continue
+		}
+		seen := entry.Timestamp
+		if seen.IsZero() {
+			seen = now
+		}
+
+		var id int64
+		var lastSeen time.Time
+		err := tx.QueryRow(`SELECT id, last_seen FROM log_patterns WHERE service = ? AND pattern = ?`, entry.Service, pattern).Scan(&id, &lastSeen)
+		kind := ""
+		switch {
+		case err == sql.ErrNoRows:
+			result, err := tx.Exec(`INSERT INTO log_patterns (service, pattern, level, example, count, first_seen, last_seen) VALUES (?, ?, ?, ?, 1, ?, ?)`,
+				entry.Service, pattern, entry.Level, entry.Message, seen.UTC(), seen.UTC())
+			if err != nil {
+				return nil, err
+			}
+			if id, err = result.LastInsertId(); err != nil {
+				return nil, err
+			}
+			kind = PatternNew
+		case err != nil:
+			return nil, err
+		default:
+			// Logs can arrive out of order, so last_seen only moves forward
+			if _, err := tx.Exec(`UPDATE log_patterns SET count = count + 1, level = ?, last_seen = MAX(last_seen, ?) WHERE id = ?`,
+				entry.Level, seen.UTC(), id); err != nil {
+				return nil, err
+			}
+			if dormancy > 0 && seen.Sub(lastSeen) >= dormancy {
+				kind = PatternDormant
+			}
+		}
+		if kind == "" || baseline {
+			continue
+		}
+
+		anomaly := PatternAnomaly{
+			PatternID: id,
+			Kind:      kind,
+			Service:   entry.Service,
+			Pattern:   pattern,
+			Level:     entry.Level,
+			Example:   entry.Message,
+			LogID:     entry.ID,
+			Timestamp: now,
+		}
+		var previous interface{}
+		if kind == PatternDormant {
+			anomaly.PreviousSeen = lastSeen
+			previous = lastSeen
+		}
+		result, err := tx.Exec(`INSERT INTO pattern_anomalies (pattern_id, kind, service, pattern, level, example, log_id, previous_seen, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, kind, entry.Service, pattern, entry.Level, entry.Message, entry.ID, previous, now.Local())
+		if err != nil {
+			return nil, fmt.Errorf("failed to record pattern anomaly: %w", err)
+		}
+		anomaly.ID, _ = result.LastInsertId()
+		anomalies = append(anomalies, anomaly)
+	}
+	return anomalies, nil
+}
+
+// GetPatterns returns the patterns for a service, or every service when it's
+// empty, most frequent first
+func (s *Storage) GetPatterns(ctx context.Context, service string, limit int) ([]LogPattern, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	query := `SELECT id, service, pattern, level, example, count, first_seen, last_seen FROM log_patterns`
+	var args []interface{}
+	if service != "" {
+		query += ` WHERE service = ?`
+		args = append(args, service)
+	}
+	query += ` ORDER BY count DESC, last_seen DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var patterns []LogPattern
+	for rows.Next() {
+		var p LogPattern
+		if err := rows.Scan(&p.ID, &p.Service, &p.Pattern, &p.Level, &p.Example, &p.Count, &p.FirstSeen, &p.LastSeen); err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+	return patterns, rows.Err()
+}
+
+// GetPatternAnomalies returns the anomalies detected since a time, newest first
+func (s *Storage) GetPatternAnomalies(ctx context.Context, since time.Time, limit int) ([]PatternAnomaly, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `SELECT id, pattern_id, kind, service, pattern, level, example, log_id, previous_seen, timestamp
+		FROM pattern_anomalies WHERE timestamp >= ? ORDER BY timestamp DESC, id DESC LIMIT ?`, since.Local(), limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var anomalies []PatternAnomaly
+	for rows.Next() {
+		var a PatternAnomaly
+		var previous sql.NullTime
+		if err := rows.Scan(&a.ID, &a.PatternID, &a.Kind, &a.Service, &a.Pattern, &a.Level, &a.Example, &a.LogID, &previous, &a.Timestamp); err != nil {
+			return nil, err
+		}
+		a.PreviousSeen = previous.Time
+		anomalies = append(anomalies, a)
+	}
+	return anomalies, rows.Err()
+}
+
+// DeletePatternAnomaliesBefore removes anomalies detected before cutoff and
+// returns how many. The patterns themselves are kept, so a pattern that
+// comes back after its logs were deleted is still known.
+func (s *Storage) DeletePatternAnomaliesBefore(cutoff time.Time) (int64, error) {
+	return s.deleteBefore("DELETE FROM pattern_anomalies WHERE timestamp < ?", cutoff.Local())
+}
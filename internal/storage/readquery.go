@@ -0,0 +1,235 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// User-written SQL, from the web console, dashboards, and the TUI, runs through
+// ReadStatement and QueryReadOnly so it can only read.
+
+// readQueryKeywords are the statements a read-only query may start with
+var readQueryKeywords = map[string]bool{"SELECT": true, "WITH": true, "VALUES": true, "EXPLAIN": true}
+
+// statementKeywords are the words that can follow a WITH clause's tables and
+// start the statement they're for
+var statementKeywords = map[string]bool{"SELECT": true, "VALUES": true, "INSERT": true, "REPLACE": true, "UPDATE": true, "DELETE": true}
+
+// ReadStatement makes sure the query is a single read statement and returns it without
+// the comments and semicolons around it, which the SQLite driver would treat as another
+// statement. Strings, quoted identifiers, and comments are skipped, so a ';' inside
+// them doesn't count.
+func ReadStatement(query string) (string, error) {
+	var keywords []string
+	inStatement := false
+	start, end := 0, 0
+
+	for i := 0; i < len(query); {
+		c := query[i]
+		switch {
+		case strings.HasPrefix(query[i:], "--"):
+			if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+				i += n + 1
+			} else {
+				i = len(query)
+			}
+			continue
+		case strings.HasPrefix(query[i:], "/*"):
+			if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+				i += n + 4
+			} else {
+				i = len(query)
+			}
+			continue
+		case c == ';':
+			inStatement = false
+			i++
+			continue
+		case unicode.IsSpace(rune(c)):
+			i++
+			continue
+		}
+
+		if !inStatement {
+			inStatement = true
+			if len(keywords) == 0 {
+				start = i
+			}
+			word := i
+			for word < len(query) && (unicode.IsLetter(rune(query[word])) || query[word] == '_') {
+				word++
+			}
+			keywords = append(keywords, strings.ToUpper(query[i:word]))
+		}
+
+		// Skip the token; '' inside a string reads as two adjacent strings, which is fine here
+		switch c {
+		case '\'', '"', '`', '[':
+			closing := c
+			if c == '[' {
+				closing = ']'
+			}
+			if n := strings.IndexByte(query[i+1:], closing); n >= 0 {
+				i += n + 2
+			} else {
+				i = len(query)
+			}
+		default:
+			i++
+		}
+		if len(keywords) == 1 {
+			end = i
+		}
+	}
+
+	switch {
+	case len(keywords) == 0:
+		return "", errors.New("no query provided")
+	case len(keywords) > 1:
+		return "", errors.New("only one statement can run at a time")
+	case !readQueryKeywords[keywords[0]]:
+		return "", fmt.Errorf("only SELECT queries can run here, not %s", keywords[0])
+	}
+
+	// WITH can lead into a write too, e.g. WITH c AS (SELECT 1) DELETE FROM logs
+	statement := query[start:end]
+	if keywords[0] == "WITH" {
+		switch main := withStatement(statement); main {
+		case "SELECT", "VALUES":
+		case "":
+			return "", errors.New("WITH must be followed by a SELECT")
+		default:
+			return "", fmt.Errorf("only SELECT queries can run here, not %s", main)
+		}
+	}
+	return statement, nil
+}
+
+// withStatement returns the keyword starting the statement a WITH clause is
+// for: the first statement keyword outside the parentheses around its tables.
+// Table names that are keywords have to be quoted, so they aren't mistaken
+// for it.
+func withStatement(statement string) string {
+	depth := 0
+	for i := 0; i < len(statement); {
+		if next := skipNonCode(statement, i); next > i {
+			i = next
+			continue
+		}
+		switch c := statement[i]; {
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case unicode.IsLetter(rune(c)) || c == '_':
+			word := i
+			for word < len(statement) && (unicode.IsLetter(rune(statement[word])) || unicode.IsDigit(rune(statement[word])) || statement[word] == '_') {
+				word++
+			}
+			if keyword := strings.ToUpper(statement[i:word]); depth == 0 && statementKeywords[keyword] {
+				return keyword
+			}
+			i = word
+			continue
+		}
+		i++
+	}
+	return ""
+}
+
+// ReplaceInCode replaces re's matches in query the way ReplaceAllString does,
+// leaving comments, strings, and quoted identifiers alone
+func ReplaceInCode(query string, re *regexp.Regexp, repl string) string {
+	var out strings.Builder
+	code := 0
+	for i := 0; i < len(query); {
+		next := skipNonCode(query, i)
+		if next == i {
+			i++
+			continue
+		}
+		out.WriteString(re.ReplaceAllString(query[code:i], repl))
+		out.WriteString(query[i:next])
+		code, i = next, next
+	}
+	out.WriteString(re.ReplaceAllString(query[code:], repl))
+	return out.String()
+}
+
+// skipNonCode returns where the comment, string, or quoted identifier
+// starting at query[i] ends, or i if none starts there
+func skipNonCode(query string, i int) int {
+	c := query[i]
+	switch {
+	case strings.HasPrefix(query[i:], "--"):
+		if n := strings.IndexByte(query[i:], '\n'); n >= 0 {
+			return i + n + 1
+		}
+		return len(query)
+	case strings.HasPrefix(query[i:], "/*"):
+		if n := strings.Index(query[i+2:], "*/"); n >= 0 {
+			return i + n + 4
+		}
+		return len(query)
+	case c == '\'' || c == '"' || c == '`' || c == '[':
+		closing := c
+		if c == '[' {
+			closing = ']'
+		}
+		if n := strings.IndexByte(query[i+1:], closing); n >= 0 {
+			return i + n + 2
+		}
+		return len(query)
+	}
+	return i
+}
+
+// QueryReadOnly runs a user query on its own connection with SQLite's query_only pragma
+// set, so INSERT, UPDATE, DROP and friends fail even inside a WITH. Call release
+// once the rows are closed.
+func (s *Storage) QueryReadOnly(ctx context.Context, query string, args ...interface{}) (rows *sql.Rows, release func(), err error) {
+	conn, err := s.db.Conn(ctx)
+	if err != nil {
+		return nil, func() {}, err
+	}
+	release = func() {
+		// The connection goes back to the pool, where other code needs to write
+		conn.ExecContext(context.Background(), "PRAGMA query_only = OFF")
+		conn.Close()
+	}
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA query_only = ON"); err != nil {
+		release()
+		return nil, func() {}, err
+	}
+
+	rows, err = conn.QueryContext(ctx, query, args...)
+	if err != nil {
+		release()
+		return nil, func() {}, err
+	}
+	return rows, release, nil
+}
+
+// EachRowReadOnly is EachRow for user-written SQL, such as alert rule
+// queries: it runs as QueryReadOnly does, so it can't write whatever it says
+func (s *Storage) EachRowReadOnly(ctx context.Context, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	rows, release, err := s.QueryReadOnly(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer release()
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
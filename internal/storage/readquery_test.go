@@ -0,0 +1,85 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestReadStatement(t *testing.T) {
+	tests := []struct {
+		query string
+		want  string // The statement returned, or "" if it's rejected
+	}{
+		{"SELECT 1", "SELECT 1"},
+		{"  select 1;  ", "select 1"},
+		{"-- errors\nSELECT * FROM logs; /* done */", "SELECT * FROM logs"},
+		{"SELECT ';DELETE FROM logs' AS s", "SELECT ';DELETE FROM logs' AS s"},
+		{`SELECT "update" FROM [delete]`, `SELECT "update" FROM [delete]`},
+		{"WITH c AS (SELECT 1) SELECT * FROM c", "WITH c AS (SELECT 1) SELECT * FROM c"},
+		{"WITH RECURSIVE n(x) AS (VALUES(1) UNION SELECT x+1 FROM n WHERE x < 3) SELECT x FROM n", "WITH RECURSIVE n(x) AS (VALUES(1) UNION SELECT x+1 FROM n WHERE x < 3) SELECT x FROM n"},
+		{"WITH c AS (SELECT 'delete') VALUES (1)", "WITH c AS (SELECT 'delete') VALUES (1)"},
+		{"VALUES (1)", "VALUES (1)"},
+		{"EXPLAIN QUERY PLAN SELECT * FROM logs", "EXPLAIN QUERY PLAN SELECT * FROM logs"},
+		{"", ""},
+		{"-- nothing", ""},
+		{"DELETE FROM logs", ""},
+		{"SELECT 1; DELETE FROM logs", ""},
+		{"WITH c AS (SELECT 1) DELETE FROM logs", ""},
+		{"WITH c AS (SELECT 1), d AS (SELECT 2) INSERT INTO logs (message) SELECT * FROM c", ""},
+		{"with c as (select 1) update logs set level = 'x'", ""},
+		{"WITH c AS (SELECT 1) /* SELECT */ REPLACE INTO logs (id) VALUES (1)", ""},
+		{"WITH c AS (SELECT 1)", ""},
+	}
+	for _, tt := range tests {
+		got, err := ReadStatement(tt.query)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("ReadStatement(%q) = %q, want an error", tt.query, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ReadStatement(%q) = %q, %v, want %q", tt.query, got, err, tt.want)
+		}
+	}
+}
+
+func TestReplaceInCode(t *testing.T) {
+	re := regexp.MustCompile(`(?i)\bmain\.logs\b`)
+	query := "SELECT 'main.logs' FROM main.logs -- main.logs\nJOIN MAIN.logs /* main.logs */"
+	want := "SELECT 'main.logs' FROM w -- main.logs\nJOIN w /* main.logs */"
+	if got := ReplaceInCode(query, re, "w"); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+// User queries can't write through QueryReadOnly, even past ReadStatement
+func TestQueryReadOnly(t *testing.T) {
+	store := newTestStorage(t)
+	if err := store.InsertLogs([]LogEntry{{Level: "error", Message: "boom"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	rows, release, err := store.QueryReadOnly(context.Background(), "DELETE FROM logs RETURNING id")
+	if err == nil {
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+		release()
+	}
+	if err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("got %v, want a read-only error", err)
+	}
+
+	var count int
+	err = store.EachRowReadOnly(context.Background(), "SELECT COUNT(*) FROM logs WHERE level = ?", []interface{}{"error"}, func(rows *sql.Rows) error {
+		return rows.Scan(&count)
+	})
+	if err != nil || count != 1 {
+		t.Errorf("got %d, %v, want 1 log", count, err)
+	}
+}
@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"regexp"
+	"sync"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// driverName is go-sqlite3 with a REGEXP function registered on every connection
+const driverName = "sqlite3_peep"
+
+func init() {
+	sql.Register(driverName, &sqlite3.SQLiteDriver{
+		ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+			return conn.RegisterFunc("regexp", sqlRegexp, true)
+		},
+	})
+}
+
+// maxCachedPatterns bounds the compiled pattern cache; it is cleared when full
+const maxCachedPatterns = 100
+
+var (
+	patternCache   = map[string]*regexp.Regexp{}
+	patternCacheMu sync.Mutex
+)
+
+// sqlRegexp implements `value REGEXP pattern`. SQLite calls it once per row,
+// so compiled patterns are cached. NULL never matches.
+func sqlRegexp(pattern string, value interface{}) (bool, error) {
+	var s string
+	switch v := value.(type) {
+	case nil:
+		return false, nil
+	case string:
+		s = v
+	case []byte:
+		if v == nil {
+			return false, nil
+		}
+		s = string(v)
+	default:
+		s = fmt.Sprint(v)
+	}
+
+	re, err := compilePattern(pattern)
+	if err != nil {
+		return false, err
+	}
+	return re.MatchString(s), nil
+}
+
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	patternCacheMu.Lock()
+	defer patternCacheMu.Unlock()
+
+	if re, ok := patternCache[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(patternCache) >= maxCachedPatterns {
+		patternCache = map[string]*regexp.Regexp{}
+	}
+	patternCache[pattern] = re
+	return re, nil
+}
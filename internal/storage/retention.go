@@ -1,9 +1,12 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
+	"sync"
 	"time"
 )
 
@@ -36,120 +39,214 @@ func DefaultRetentionConfig() RetentionConfig {
 	}
 }
 
+// Validate checks that the limits make sense before they're saved
+func (c RetentionConfig) Validate() error {
+	if c.MaxLogs < 0 || c.MaxAge < 0 || c.MaxSizeMB < 0 {
+		return fmt.Errorf("retention limits can't be negative")
+	}
+	if c.CheckInterval < time.Minute {
+		return fmt.Errorf("check interval must be at least a minute")
+	}
+	return nil
+}
+
+// settingsPollInterval is how often a running manager looks for retention
+// settings saved from the web UI
+const settingsPollInterval = 30 * time.Second
+
 // AutoRetentionManager handles automatic cleanup
 type AutoRetentionManager struct {
 	storage *Storage
-	config  RetentionConfig
 	ticker  *time.Ticker
-	stop    chan bool
+	trigger chan struct{} // Ingestion asking for a check between ticks
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+
+	mu       sync.Mutex
+	config   RetentionConfig
+	loadedAt time.Time // When the settings in config were saved, if they came from the settings table
 }
 
 // NewAutoRetentionManager creates a new retention manager
 func NewAutoRetentionManager(storage *Storage, config RetentionConfig) *AutoRetentionManager {
+	if config.CheckInterval <= 0 {
+		config.CheckInterval = DefaultRetentionConfig().CheckInterval
+	}
+	ctx, cancel := context.WithCancel(context.Background())
 	return &AutoRetentionManager{
 		storage: storage,
 		config:  config,
-		stop:    make(chan bool),
+		trigger: make(chan struct{}, 1),
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+		// The caller already merged in anything saved before now
+		loadedAt: time.Now(),
 	}
 }
 
-// Start begins automatic retention checking
+// Start begins automatic retention checking. The manager runs even when cleanup is
+// disabled, so enabling it on the settings page takes effect without a restart.
 func (arm *AutoRetentionManager) Start() {
-	if !arm.config.Enabled {
-		return
+	config := arm.currentConfig()
+	if config.Enabled {
+		slog.Info("retention manager started", "check_interval", config.CheckInterval.String())
 	}
-
-	log.Printf("🧹 Starting automatic retention manager (check every %v)", arm.config.CheckInterval)
-	arm.ticker = time.NewTicker(arm.config.CheckInterval)
+	arm.ticker = time.NewTicker(config.CheckInterval)
+	poll := time.NewTicker(settingsPollInterval)
 
 	go func() {
+		defer close(arm.done)
+		defer poll.Stop()
 		for {
 			select {
 			case <-arm.ticker.C:
 				arm.performCleanup()
-			case <-arm.stop:
+			case <-arm.trigger:
+				arm.performCleanup()
+			case <-poll.C:
+				arm.reloadSettings()
+			case <-arm.ctx.Done():
 				return
 			}
 		}
 	}()
 }
 
-// Stop stops the automatic retention manager
+func (arm *AutoRetentionManager) currentConfig() RetentionConfig {
+	arm.mu.Lock()
+	defer arm.mu.Unlock()
+	return arm.config
+}
+
+// reloadSettings applies retention settings saved since the manager last looked
+func (arm *AutoRetentionManager) reloadSettings() {
+	config, updatedAt, err := arm.storage.LoadRetentionConfig()
+	if err != nil {
+		slog.Warn("failed to load retention settings", "error", err)
+		return
+	}
+
+	arm.mu.Lock()
+	if updatedAt.IsZero() || !updatedAt.After(arm.loadedAt) {
+		arm.mu.Unlock()
+		return
+	}
+	previous := arm.config
+	arm.config = config
+	arm.loadedAt = updatedAt
+	arm.mu.Unlock()
+
+	slog.Info("retention settings updated", "max_logs", config.MaxLogs, "max_age", config.MaxAge.String(),
+		"max_size_mb", config.MaxSizeMB, "check_interval", config.CheckInterval.String(), "enabled", config.Enabled)
+	if config.CheckInterval != previous.CheckInterval {
+		arm.ticker.Reset(config.CheckInterval)
+	}
+}
+
+// Stop stops the automatic retention manager, interrupting a cleanup in
+// progress between batches, and waits for it to finish
 func (arm *AutoRetentionManager) Stop() {
+	arm.cancel()
 	if arm.ticker != nil {
 		arm.ticker.Stop()
+		<-arm.done
 	}
-	close(arm.stop)
 }
 
 // performCleanup runs the actual cleanup logic
 func (arm *AutoRetentionManager) performCleanup() {
+	config := arm.currentConfig()
+	if !config.Enabled {
+		return
+	}
 	db := arm.storage.GetDB()
 
+	// Metrics, spans, and pattern anomalies are small, so they're only ever aged out
+	if config.MaxAge > 0 {
+		if deleted, err := arm.storage.DeleteMetricsBefore(time.Now().Add(-config.MaxAge)); err != nil {
+			slog.Warn("failed to delete old metrics", "error", err)
+		} else if deleted > 0 {
+			slog.Debug("old metrics deleted", "deleted", deleted)
+		}
+		if deleted, err := arm.storage.DeleteSpansBefore(time.Now().Add(-config.MaxAge)); err != nil {
+			slog.Warn("failed to delete old spans", "error", err)
+		} else if deleted > 0 {
+			slog.Debug("old spans deleted", "deleted", deleted)
+		}
+		if deleted, err := arm.storage.DeletePatternAnomaliesBefore(time.Now().Add(-config.MaxAge)); err != nil {
+			slog.Warn("failed to delete old pattern anomalies", "error", err)
+		} else if deleted > 0 {
+			slog.Debug("old pattern anomalies deleted", "deleted", deleted)
+		}
+	}
+
 	// Check if cleanup is needed
-	shouldCleanup, reason := arm.shouldCleanup(db)
+	shouldCleanup, reason := arm.shouldCleanup(db, config)
 	if !shouldCleanup {
 		return
 	}
 
-	log.Printf("🧹 Auto-cleanup triggered: %s", reason)
+	slog.Info("auto-cleanup triggered", "reason", reason)
 
 	var deletedCount int
 	var err error
 
 	// Priority order: MaxLogs > MaxAge > Size-based cleanup
-	if arm.config.MaxLogs > 0 {
-		deletedCount, err = arm.cleanupByCount(db)
-	} else if arm.config.MaxAge > 0 {
-		deletedCount, err = arm.cleanupByAge(db)
+	if config.MaxLogs > 0 {
+		deletedCount, err = arm.cleanupByCount(config)
+	} else if config.MaxAge > 0 {
+		deletedCount, err = arm.cleanupByAge(config)
 	}
 
+	if errors.Is(err, context.Canceled) {
+		slog.Info("auto-cleanup stopped", "deleted", deletedCount)
+		return
+	}
 	if err != nil {
-		log.Printf("❌ Auto-cleanup failed: %v", err)
+		slog.Error("auto-cleanup failed", "error", err)
 		return
 	}
+	arm.storage.ops.cleanups.Add(1)
+	arm.storage.ops.cleanupDeleted.Add(int64(deletedCount))
 
+	// No VACUUM here: it rewrites the whole file while holding the write lock.
+	// SQLite reuses the freed pages for new logs, so the file stops growing;
+	// peep clean --vacuum shrinks it when that's wanted.
 	if deletedCount > 0 {
-		log.Printf("🗑️  Auto-cleanup: removed %d logs", deletedCount)
-
-		// Vacuum database to reclaim space
-		_, err = db.Exec("VACUUM")
-		if err != nil {
-			log.Printf("⚠️  Warning: Failed to vacuum database: %v", err)
-		} else {
-			log.Printf("✅ Database optimized after cleanup")
-		}
+		slog.Info("auto-cleanup finished", "deleted", deletedCount)
 	}
 }
 
 // shouldCleanup determines if cleanup is needed
-func (arm *AutoRetentionManager) shouldCleanup(db *sql.DB) (bool, string) {
+func (arm *AutoRetentionManager) shouldCleanup(db *sql.DB, config RetentionConfig) (bool, string) {
 	// Check log count
-	if arm.config.MaxLogs > 0 {
+	if config.MaxLogs > 0 {
 		var count int
 		err := db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count)
-		if err == nil && count > arm.config.MaxLogs {
-			return true, fmt.Sprintf("log count (%d) exceeds limit (%d)", count, arm.config.MaxLogs)
+		if err == nil && count > config.MaxLogs {
+			return true, fmt.Sprintf("log count (%d) exceeds limit (%d)", count, config.MaxLogs)
 		}
 	}
 
 	// Check database size
-	if arm.config.MaxSizeMB > 0 {
+	if config.MaxSizeMB > 0 {
 		size := arm.getDatabaseSizeMB()
-		if size > arm.config.MaxSizeMB {
-			return true, fmt.Sprintf("database size (%.1f MB) exceeds limit (%.1f MB)", size, arm.config.MaxSizeMB)
+		if size > config.MaxSizeMB {
+			return true, fmt.Sprintf("database size (%.1f MB) exceeds limit (%.1f MB)", size, config.MaxSizeMB)
 		}
 	}
 
 	// Check age-based cleanup
-	if arm.config.MaxAge > 0 {
-		cutoff := time.Now().Add(-arm.config.MaxAge)
+	if config.MaxAge > 0 {
+		cutoff := time.Now().Add(-config.MaxAge)
 		cutoffStr := cutoff.Format("2006-01-02 15:04:05")
 
 		var oldCount int
 		err := db.QueryRow("SELECT COUNT(*) FROM logs WHERE timestamp < ?", cutoffStr).Scan(&oldCount)
 		if err == nil && oldCount > 0 {
-			return true, fmt.Sprintf("found %d logs older than %v", oldCount, arm.config.MaxAge)
+			return true, fmt.Sprintf("found %d logs older than %v", oldCount, config.MaxAge)
 		}
 	}
 
@@ -157,43 +254,99 @@ func (arm *AutoRetentionManager) shouldCleanup(db *sql.DB) (bool, string) {
 }
 
 // cleanupByCount keeps only the most recent N logs
-func (arm *AutoRetentionManager) cleanupByCount(db *sql.DB) (int, error) {
-	result, err := db.Exec(`
-		DELETE FROM logs 
-		WHERE id NOT IN (
-			SELECT id FROM logs 
-			ORDER BY timestamp DESC 
-			LIMIT ?
-		)`, arm.config.MaxLogs)
-
+func (arm *AutoRetentionManager) cleanupByCount(config RetentionConfig) (int, error) {
+	deleted, err := arm.storage.DeleteOldestLogs(arm.ctx, config.MaxLogs, logCleanupProgress)
 	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup by count: %w", err)
+		return int(deleted), fmt.Errorf("failed to cleanup by count: %w", err)
 	}
+	return int(deleted), nil
+}
+
+// cleanupByAge removes logs older than MaxAge
+func (arm *AutoRetentionManager) cleanupByAge(config RetentionConfig) (int, error) {
+	cutoff := time.Now().Add(-config.MaxAge)
+	cutoffStr := cutoff.Format("2006-01-02 15:04:05")
 
-	rowsAffected, err := result.RowsAffected()
+	deleted, err := arm.storage.DeleteLogs(arm.ctx, "timestamp < ?", []interface{}{cutoffStr}, logCleanupProgress)
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return int(deleted), fmt.Errorf("failed to cleanup by age: %w", err)
 	}
+	return int(deleted), nil
+}
 
-	return int(rowsAffected), nil
+func logCleanupProgress(deleted int64) {
+	slog.Debug("auto-cleanup progress", "deleted", deleted)
 }
 
-// cleanupByAge removes logs older than MaxAge
-func (arm *AutoRetentionManager) cleanupByAge(db *sql.DB) (int, error) {
-	cutoff := time.Now().Add(-arm.config.MaxAge)
-	cutoffStr := cutoff.Format("2006-01-02 15:04:05")
+// Logs are deleted in batches so a large cleanup never holds the write lock
+// for long: each batch is its own transaction, and the pause after it lets
+// waiting ingestion and web requests in before the next.
+const (
+	deleteBatchSize  = 10000
+	deleteBatchPause = 20 * time.Millisecond
+)
 
-	result, err := db.Exec("DELETE FROM logs WHERE timestamp < ?", cutoffStr)
-	if err != nil {
-		return 0, fmt.Errorf("failed to cleanup by age: %w", err)
+// DeleteLogs deletes the logs matching condition, an SQL condition on the
+// logs table bound to args, in batches of deleteBatchSize. progress, if set,
+// gets the running total after each batch. A cancelled ctx stops it between
+// batches; the batches already deleted stay deleted, and are counted in the
+// total returned with the error.
+func (s *Storage) DeleteLogs(ctx context.Context, condition string, args []interface{}, progress func(deleted int64)) (int64, error) {
+	query := "DELETE FROM logs WHERE id IN (SELECT id FROM logs WHERE " + condition + " LIMIT ?)"
+	args = append(append([]interface{}{}, args...), deleteBatchSize)
+
+	var deleted int64
+	for {
+		var n int64
+		err := s.write(ctx, func(tx *sql.Tx) error {
+			result, err := tx.ExecContext(ctx, query, args...)
+			if err != nil {
+				return err
+			}
+			n, err = result.RowsAffected()
+			return err
+		})
+		if err != nil {
+			return deleted, err
+		}
+		deleted += n
+		if n > 0 && progress != nil {
+			progress(deleted)
+		}
+		if n < deleteBatchSize {
+			return deleted, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return deleted, ctx.Err()
+		case <-time.After(deleteBatchPause):
+		}
+	}
+}
+
+// DeleteOldestLogs deletes all but the keep most recent logs, in batches as
+// DeleteLogs does
+func (s *Storage) DeleteOldestLogs(ctx context.Context, keep int, progress func(deleted int64)) (int64, error) {
+	if keep <= 0 {
+		return s.DeleteLogs(ctx, "1", nil, progress)
 	}
 
-	rowsAffected, err := result.RowsAffected()
+	// Find the oldest log to keep once, rather than ranking every log per batch
+	var boundary int64
+	err := s.db.QueryRowContext(ctx, "SELECT id FROM logs ORDER BY timestamp DESC, id DESC LIMIT 1 OFFSET ?", keep-1).Scan(&boundary)
+	if err == sql.ErrNoRows {
+		return 0, nil // No more than keep logs
+	}
 	if err != nil {
-		return 0, fmt.Errorf("failed to get rows affected: %w", err)
+		return 0, err
 	}
 
-	return int(rowsAffected), nil
+	// Comparing against the stored row sidesteps timestamps being stored in a
+	// different text form than bound ones
+	return s.DeleteLogs(ctx, `timestamp < (SELECT timestamp FROM logs WHERE id = ?)
+		OR (timestamp = (SELECT timestamp FROM logs WHERE id = ?) AND id < ?)`,
+		[]interface{}{boundary, boundary, boundary}, progress)
 }
 
 // getDatabaseSizeMB returns the database file size in MB
@@ -211,16 +364,12 @@ func (arm *AutoRetentionManager) getDatabaseSizeMB() float64 {
 	return estimatedBytes / (1024 * 1024)
 }
 
-// TriggerCleanupIfNeeded can be called during ingestion to check if cleanup is needed
+// TriggerCleanupIfNeeded asks the manager to check whether cleanup is needed
+// without waiting for the next tick. Ingestion calls it after storing logs, so
+// it only wakes the manager's goroutine; the check and any cleanup run there.
 func (arm *AutoRetentionManager) TriggerCleanupIfNeeded() {
-	if !arm.config.Enabled {
-		return
-	}
-
-	db := arm.storage.GetDB()
-	shouldCleanup, reason := arm.shouldCleanup(db)
-	if shouldCleanup {
-		log.Printf("🧹 Triggering immediate cleanup: %s", reason)
-		arm.performCleanup()
+	select {
+	case arm.trigger <- struct{}{}:
+	default: // A check is already pending
 	}
 }
@@ -0,0 +1,77 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// A config reload replacing the retention manager mustn't race ingestion
+// triggering checks
+func TestRetentionReplacedDuringIngest(t *testing.T) {
+	store := newTestStorage(t)
+	config := DefaultRetentionConfig()
+	config.Enabled = true
+
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				store.TriggerRetentionCheck()
+				store.GetRetentionConfig()
+			}
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		store.EnableAutoRetention(config)
+		if i%5 == 0 {
+			store.DisableAutoRetention()
+		}
+	}
+	close(done)
+	wg.Wait()
+}
+
+// Ingestion only wakes the manager; the cleanup runs on its goroutine and
+// leaves the freed pages for reuse instead of vacuuming
+func TestRetentionTriggeredFromIngest(t *testing.T) {
+	store := newTestStorage(t)
+	var entries []LogEntry
+	for i := 0; i < 2000; i++ {
+		entries = append(entries, LogEntry{Timestamp: time.Now(), Level: "info", Message: fmt.Sprintf("request %d %s", i, strings.Repeat("x", 100))})
+	}
+	if err := store.InsertLogs(entries); err != nil {
+		t.Fatal(err)
+	}
+
+	config := DefaultRetentionConfig()
+	config.MaxLogs = 100
+	config.CheckInterval = time.Hour
+	store.EnableAutoRetention(config)
+	store.TriggerRetentionCheck()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for countLogs(t, store) != 100 {
+		if time.Now().After(deadline) {
+			t.Fatalf("got %d logs, want 100", countLogs(t, store))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	var freelist int
+	if err := store.GetDB().QueryRow("PRAGMA freelist_count").Scan(&freelist); err != nil {
+		t.Fatal(err)
+	}
+	if freelist == 0 {
+		t.Error("freelist is empty, so the cleanup vacuumed the database")
+	}
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Settings are runtime configuration saved in the database, so they can be changed
+// from the web UI and picked up by every peep process sharing logs.db. Each value is
+// stored as JSON under a key.
+
+// retentionSettingKey holds the RetentionConfig edited on the settings page
+const retentionSettingKey = "retention"
+
+func (s *Storage) createSettingsTable() error {
+	_, err := s.db.Exec(`
+	CREATE TABLE IF NOT EXISTS settings (
+		key TEXT PRIMARY KEY,
+		value TEXT NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`)
+	return err
+}
+
+// GetSetting decodes the setting stored under key into v and returns when it was
+// last saved. found is false if it has never been saved.
+func (s *Storage) GetSetting(key string, v interface{}) (updatedAt time.Time, found bool, err error) {
+	var value string
+	err = s.db.QueryRow("SELECT value, updated_at FROM settings WHERE key = ?", key).Scan(&value, &updatedAt)
+	if err == sql.ErrNoRows {
+		return time.Time{}, false, nil
+	}
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return time.Time{}, false, fmt.Errorf("invalid %s setting: %w", key, err)
+	}
+	return updatedAt, true, nil
+}
+
+// PutSetting saves v as JSON under key
+func (s *Storage) PutSetting(key string, v interface{}) error {
+	value, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = s.db.Exec(`INSERT INTO settings (key, value, updated_at) VALUES (?, ?, ?)
+		ON CONFLICT(key) DO UPDATE SET value = excluded.value, updated_at = excluded.updated_at`,
+		key, string(value), time.Now())
+	return err
+}
+
+// LoadRetentionConfig returns the saved retention settings, or the defaults if
+// none have been saved, and when they were saved
+func (s *Storage) LoadRetentionConfig() (RetentionConfig, time.Time, error) {
+	config := DefaultRetentionConfig()
+	updatedAt, _, err := s.GetSetting(retentionSettingKey, &config)
+	return config, updatedAt, err
+}
+
+// SaveRetentionConfig validates and saves the retention settings. A running daemon
+// applies them within settingsPollInterval.
+func (s *Storage) SaveRetentionConfig(config RetentionConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	return s.PutSetting(retentionSettingKey, config)
+}
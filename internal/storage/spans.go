@@ -0,0 +1,171 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Span is one operation in a distributed trace, as received over OTLP. Spans
+// of a trace share its TraceID; ParentSpanID links a span to the one that
+// called it, and is empty for the trace's root.
+type Span struct {
+	ID            int64                  `json:"id"`
+	TraceID       string                 `json:"trace_id"` // Lowercase hex, as logs carry it
+	SpanID        string                 `json:"span_id"`
+	ParentSpanID  string                 `json:"parent_span_id,omitempty"`
+	Name          string                 `json:"name"`
+	Service       string                 `json:"service"` // The resource's service.name
+	Kind          string                 `json:"kind"`    // internal, server, client, producer, or consumer
+	StartTime     time.Time              `json:"start_time"`
+	Duration      time.Duration          `json:"duration"`
+	Status        string                 `json:"status"` // unset, ok, or error
+	StatusMessage string                 `json:"status_message,omitempty"`
+	Attributes    map[string]interface{} `json:"attributes,omitempty"` // The span's, then its resource's
+}
+
+func (s *Storage) createSpansTable() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS spans (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		trace_id TEXT NOT NULL,
+		span_id TEXT NOT NULL,
+		parent_span_id TEXT NOT NULL DEFAULT '',
+		name TEXT NOT NULL,
+		service TEXT NOT NULL DEFAULT '',
+		kind TEXT NOT NULL DEFAULT '',
+		start_time DATETIME NOT NULL,
+		duration_ms REAL NOT NULL, -- For SQL; Span.Duration is exact
+		status TEXT NOT NULL DEFAULT 'unset',
+		status_message TEXT NOT NULL DEFAULT '',
+		attributes TEXT NOT NULL DEFAULT '{}' -- JSON, for json_extract
+	);
+
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_spans_trace_span ON spans(trace_id, span_id);
+	CREATE INDEX IF NOT EXISTS idx_spans_start_time ON spans(start_time);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// InsertSpans stores spans in one transaction. Start times are stored in UTC,
+// like metric timestamps. Exporters resend a batch when they don't hear back,
+// so a span already stored is replaced rather than duplicated.
+func (s *Storage) InsertSpans(spans []Span) error {
+	return s.write(context.Background(), func(tx *sql.Tx) error {
+		return insertSpans(tx, spans)
+	})
+}
+
+func insertSpans(tx *sql.Tx, spans []Span) error {
+	stmt, err := tx.Prepare(`INSERT OR REPLACE INTO spans
+		(trace_id, span_id, parent_span_id, name, service, kind, start_time, duration_ms, status, status_message, attributes)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, span := range spans {
+		attributes := "{}"
+		if len(span.Attributes) > 0 {
+			encoded, err := json.Marshal(span.Attributes)
+			if err != nil {
+				return err
+			}
+			attributes = string(encoded)
+		}
+		status := span.Status
+		if status == "" {
+			status = "unset"
+		}
+		_, err := stmt.Exec(span.TraceID, span.SpanID, span.ParentSpanID, span.Name, span.Service, span.Kind,
+			span.StartTime.UTC(), float64(span.Duration)/float64(time.Millisecond), status, span.StatusMessage, attributes)
+		if err != nil {
+			return fmt.Errorf("failed to store span %s: %w", span.SpanID, err)
+		}
+	}
+	return nil
+}
+
+// GetTrace returns a trace's spans in the order they started
+func (s *Storage) GetTrace(ctx context.Context, traceID string) ([]Span, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT id, trace_id, span_id, parent_span_id, name, service, kind, start_time, duration_ms, status, status_message, attributes
+	FROM spans WHERE trace_id = ? ORDER BY start_time, id`, traceID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spans []Span
+	for rows.Next() {
+		var span Span
+		var durationMS float64
+		var attributes string
+		err := rows.Scan(&span.ID, &span.TraceID, &span.SpanID, &span.ParentSpanID, &span.Name, &span.Service, &span.Kind,
+			&span.StartTime, &durationMS, &span.Status, &span.StatusMessage, &attributes)
+		if err != nil {
+			return nil, err
+		}
+		span.Duration = time.Duration(durationMS * float64(time.Millisecond))
+		json.Unmarshal([]byte(attributes), &span.Attributes)
+		spans = append(spans, span)
+	}
+	return spans, rows.Err()
+}
+
+// traceIDFields are the context fields a log's trace ID is read from: the
+// names OpenTelemetry's log bridges and common loggers use
+var traceIDFields = []string{"trace_id", "traceId", "trace.id"}
+
+// TraceLogCondition matches logs whose context carries a trace ID, for
+// appending to a WHERE clause. It takes the ID once per field, as
+// TraceLogArgs gives it.
+const TraceLogCondition = `(json_valid(context) AND (json_extract(context, '$.trace_id') = ? OR json_extract(context, '$.traceId') = ? OR json_extract(context, '$."trace.id"') = ?))`
+
+// TraceLogArgs are the arguments for TraceLogCondition
+func TraceLogArgs(traceID string) []interface{} {
+	return []interface{}{traceID, traceID, traceID}
+}
+
+// TraceID returns the trace ID a log's context carries, or "" if none
+func TraceID(context string) string {
+	var fields map[string]interface{}
+	if json.Unmarshal([]byte(context), &fields) != nil {
+		return ""
+	}
+	for _, name := range traceIDFields {
+		if id, ok := fields[name].(string); ok && id != "" {
+			return id
+		}
+	}
+	return ""
+}
+
+// GetTraceLogs returns the logs whose context carries traceID, oldest first
+func (s *Storage) GetTraceLogs(ctx context.Context, traceID string, limit int) ([]LogEntry, error) {
+	return s.QueryLogs(ctx, `
+	SELECT id, timestamp, level, message, service, context, raw_log, created_at
+	FROM logs WHERE `+TraceLogCondition+`
+	ORDER BY timestamp, id LIMIT ?`, append(TraceLogArgs(traceID), limit)...)
+}
+
+// CountTraceSpans returns how many spans of a trace have been received
+func (s *Storage) CountTraceSpans(ctx context.Context, traceID string) (int, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM spans WHERE trace_id = ?", traceID).Scan(&count)
+	return count, err
+}
+
+// DeleteSpansBefore removes spans that started before cutoff and returns how many
+func (s *Storage) DeleteSpansBefore(cutoff time.Time) (int64, error) {
+	return s.deleteBefore("DELETE FROM spans WHERE start_time < ?", cutoff.UTC())
+}
@@ -1,11 +1,15 @@
 package storage
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"log/slog"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
-
-	_ "github.com/mattn/go-sqlite3"
 )
 
 type LogEntry struct {
@@ -21,24 +25,78 @@ type LogEntry struct {
 
 type Storage struct {
 	db              *sql.DB
+	retentionMu     sync.Mutex // Guards retentionMgr and retentionConfig, replaced on a config reload while ingestion triggers checks
 	retentionMgr    *AutoRetentionManager
 	retentionConfig RetentionConfig
+	ops             opCounters
+	writes          *writeBuffer // Set by EnableWriteBuffer
+	writer          *writer
+	queryTimeout    atomic.Int64 // Nanoseconds, as SetQueryTimeout may run during queries
 }
 
+// DefaultQueryTimeout is how long a read may run unless SetQueryTimeout changes it
+const DefaultQueryTimeout = 30 * time.Second
+
+// maxConns is how many connections a Storage keeps open: enough for the web
+// UI, TUI, alert engine, and ingestion to read at once while the writer
+// goroutine writes. Idle ones are kept, as opening one redoes its setup.
+var maxConns = max(4, runtime.NumCPU())
+
 func NewStorage(dbPath string) (*Storage, error) {
-	db, err := sql.Open("sqlite3", dbPath)
+	db, err := sql.Open(driverName, withConnParams(dbPath))
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(maxConns)
+	db.SetMaxIdleConns(maxConns)
 
 	storage := &Storage{db: db}
+	storage.queryTimeout.Store(int64(DefaultQueryTimeout))
+	if err := storage.useWAL(); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to enable write-ahead logging: %w", err)
+	}
 	if err := storage.createTables(); err != nil {
+		db.Close()
 		return nil, fmt.Errorf("failed to create tables: %w", err)
 	}
+	storage.startWriter()
 
 	return storage, nil
 }
 
+// busyTimeout is how long a statement waits for another connection's lock
+// before failing with "database is locked". Writes outside the writer
+// goroutine, like alert state and settings, rely on it.
+const busyTimeout = 10 * time.Second
+
+// withConnParams sets up every connection opened on dsn. Transactions take
+// the write lock up front: SQLite can wait out a busy lock when a
+// transaction starts, but not when a transaction that has already read
+// tries to write; that fails at once. Statements wait up to busyTimeout.
+func withConnParams(dsn string) string {
+	separator := "?"
+	if strings.Contains(dsn, "?") {
+		separator = "&"
+	}
+	return fmt.Sprintf("%s%s_txlock=immediate&_busy_timeout=%d", dsn, separator, busyTimeout.Milliseconds())
+}
+
+// useWAL switches the database to write-ahead logging, which lets reads run
+// while a write commits instead of holding it up. The setting is stored in
+// the database, so this only changes anything the first time. Databases
+// that can't use it, like in-memory ones, keep their journal mode.
+func (s *Storage) useWAL() error {
+	var mode string
+	if err := s.db.QueryRow("PRAGMA journal_mode = WAL").Scan(&mode); err != nil {
+		return err
+	}
+	if mode != "wal" {
+		slog.Debug("database doesn't support write-ahead logging", "journal_mode", mode)
+	}
+	return nil
+}
+
 func (s *Storage) createTables() error {
 	schema := `
 	CREATE TABLE IF NOT EXISTS logs (
@@ -57,68 +115,340 @@ func (s *Storage) createTables() error {
 	CREATE INDEX IF NOT EXISTS idx_logs_service ON logs(service);
 	`
 
-	_, err := s.db.Exec(schema)
-	return err
+	if _, err := s.db.Exec(schema); err != nil {
+		return err
+	}
+
+	if err := s.createHistoryTables(); err != nil {
+		return err
+	}
+
+	if err := s.createSettingsTable(); err != nil {
+		return err
+	}
+
+	if err := s.createMetricsTable(); err != nil {
+		return err
+	}
+
+	if err := s.createAgentsTable(); err != nil {
+		return err
+	}
+
+	if err := s.createSpansTable(); err != nil {
+		return err
+	}
+
+	if err := s.createPatternTables(); err != nil {
+		return err
+	}
+
+	return s.createDashboardTables()
 }
 
+// InsertLog stores a log, or queues it for the write buffer when that's enabled
 func (s *Storage) InsertLog(entry LogEntry) error {
+	if s.writes != nil {
+		if queued, err := s.writes.add(entry); queued {
+			return err
+		}
+	}
+	return s.insertLog(entry)
+}
+
+func (s *Storage) insertLog(entry LogEntry) error {
 	query := `
 	INSERT INTO logs (timestamp, level, message, service, context, raw_log)
 	VALUES (?, ?, ?, ?, ?, ?)
 	`
 
-	_, err := s.db.Exec(query,
-		entry.Timestamp,
-		entry.Level,
-		entry.Message,
-		entry.Service,
-		entry.Context,
-		entry.RawLog,
-	)
+	start := time.Now()
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		_, err := tx.Exec(query,
+			entry.Timestamp,
+			entry.Level,
+			entry.Message,
+			entry.Service,
+			entry.Context,
+			entry.RawLog,
+		)
+		return err
+	})
+	if err == nil {
+		s.ops.inserts.Add(1)
+		s.ops.insertNanos.Add(int64(time.Since(start)))
+	}
 
 	return err
 }
 
-func (s *Storage) GetLogs(limit int) ([]LogEntry, error) {
+// InsertLogs stores entries in one transaction. SQLite then commits, and
+// syncs to disk, once for the batch rather than once per log, which makes
+// it many times faster than calling InsertLog for each.
+func (s *Storage) InsertLogs(entries []LogEntry) error {
+	start := time.Now()
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		stmt, err := tx.Prepare("INSERT INTO logs (timestamp, level, message, service, context, raw_log) VALUES (?, ?, ?, ?, ?, ?)")
+		if err != nil {
+			return err
+		}
+		defer stmt.Close()
+
+		for _, entry := range entries {
+			if _, err := stmt.Exec(entry.Timestamp, entry.Level, entry.Message, entry.Service, entry.Context, entry.RawLog); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	s.ops.inserts.Add(int64(len(entries)))
+	s.ops.insertNanos.Add(int64(time.Since(start)))
+	return nil
+}
+
+// SetQueryTimeout changes how long a read may run before it's cancelled.
+// Zero restores the default.
+func (s *Storage) SetQueryTimeout(timeout time.Duration) {
+	if timeout <= 0 {
+		timeout = DefaultQueryTimeout
+	}
+	s.queryTimeout.Store(int64(timeout))
+}
+
+// WithTimeout bounds ctx by the query timeout, for reads made on GetDB
+// directly. Reads are cancelled when ctx is, e.g. when a web client
+// disconnects; SQLite then stops the statement where it is.
+func (s *Storage) WithTimeout(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(s.queryTimeout.Load()))
+}
+
+func (s *Storage) GetLogs(ctx context.Context, limit int) ([]LogEntry, error) {
+	return s.GetFilteredLogs(ctx, LogFilter{}, limit)
+}
+
+// LogFilter narrows GetFilteredLogs. Empty fields match every log.
+type LogFilter struct {
+	Levels  []string // Any of these levels
+	Service string
+	Search  string // Only messages containing this text
+	Trace   string // Only logs whose context carries this trace ID
+	AfterID int64  // Only logs stored after this one
+
+	Since  time.Time // Only logs at or after this time
+	Before time.Time // Only logs before this time
+
+	// Only logs after this one in GetFilteredLogs' order, so passing the last
+	// log of a page fetches the next page. Comparing against the stored row
+	// sidesteps timestamps being stored in a different text form than bound ones.
+	BeforeID int64
+
+	// Only logs before this one in GetFilteredLogs' order: the page just above
+	// it, still returned newest first
+	NewerThanID int64
+}
+
+// GetFilteredLogs returns the most recent logs matching filter
+func (s *Storage) GetFilteredLogs(ctx context.Context, filter LogFilter, limit int) ([]LogEntry, error) {
+	query, args, oldestFirst := filteredLogsQuery(filter, limit)
+	logs, err := s.QueryLogs(ctx, query, args...)
+	if oldestFirst {
+		// Put the page back in the usual order
+		for i, j := 0, len(logs)-1; i < j; i, j = i+1, j-1 {
+			logs[i], logs[j] = logs[j], logs[i]
+		}
+	}
+	return logs, err
+}
+
+// EachFilteredLog calls fn with each log matching filter, in GetFilteredLogs'
+// order, up to limit (zero for every match), reading them as it goes so an
+// export of millions of logs doesn't hold them all. With NewerThanID they
+// come oldest first. See EachRow for how fn's errors and ctx stop it.
+func (s *Storage) EachFilteredLog(ctx context.Context, filter LogFilter, limit int, fn func(LogEntry) error) error {
+	query, args, _ := filteredLogsQuery(filter, limit)
+	return s.EachLog(ctx, query, args, fn)
+}
+
+// filteredLogsQuery builds the query for GetFilteredLogs and reports whether
+// it returns the logs oldest first, as it does for NewerThanID
+func filteredLogsQuery(filter LogFilter, limit int) (string, []interface{}, bool) {
+	if limit <= 0 {
+		limit = -1 // SQLite's LIMIT -1 is no limit
+	}
+
 	query := `
 	SELECT id, timestamp, level, message, service, context, raw_log, created_at
 	FROM logs
-	ORDER BY timestamp DESC
-	LIMIT ?
-	`
+	WHERE 1=1`
+	var args []interface{}
+
+	if len(filter.Levels) > 0 {
+		query += " AND level IN (?" + strings.Repeat(", ?", len(filter.Levels)-1) + ")"
+		for _, level := range filter.Levels {
+			args = append(args, level)
+		}
+	}
+	if filter.Service != "" {
+		query += " AND service = ?"
+		args = append(args, filter.Service)
+	}
+	if filter.Search != "" {
+		query += " AND message LIKE ?"
+		args = append(args, "%"+filter.Search+"%")
+	}
+	if filter.Trace != "" {
+		query += " AND " + TraceLogCondition
+		args = append(args, TraceLogArgs(filter.Trace)...)
+	}
+	if filter.AfterID > 0 {
+		query += " AND id > ?"
+		args = append(args, filter.AfterID)
+	}
+	if !filter.Since.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, filter.Since)
+	}
+	if !filter.Before.IsZero() {
+		query += " AND timestamp < ?"
+		args = append(args, filter.Before)
+	}
+	if filter.BeforeID > 0 {
+		query += ` AND (timestamp < (SELECT timestamp FROM logs WHERE id = ?)
+		OR (timestamp = (SELECT timestamp FROM logs WHERE id = ?) AND id < ?))`
+		args = append(args, filter.BeforeID, filter.BeforeID, filter.BeforeID)
+	}
+	if filter.NewerThanID > 0 {
+		query += ` AND (timestamp > (SELECT timestamp FROM logs WHERE id = ?)
+		OR (timestamp = (SELECT timestamp FROM logs WHERE id = ?) AND id > ?))`
+		args = append(args, filter.NewerThanID, filter.NewerThanID, filter.NewerThanID)
+
+		// Take the logs closest to it
+		query += `
+	ORDER BY timestamp ASC, id ASC
+	LIMIT ?`
+		return query, append(args, limit), true
+	}
 
-	rows, err := s.db.Query(query, limit)
+	query += `
+	ORDER BY timestamp DESC, id DESC
+	LIMIT ?`
+	return query, append(args, limit), false
+}
+
+// GetServices returns the distinct services that have logged, in name order
+func (s *Storage) GetServices(ctx context.Context) ([]string, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT service FROM logs WHERE service IS NOT NULL AND service != '' ORDER BY service")
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var logs []LogEntry
+	var services []string
 	for rows.Next() {
-		var entry LogEntry
-		err := rows.Scan(
-			&entry.ID,
-			&entry.Timestamp,
-			&entry.Level,
-			&entry.Message,
-			&entry.Service,
-			&entry.Context,
-			&entry.RawLog,
-			&entry.CreatedAt,
-		)
-		if err != nil {
+		var service string
+		if err := rows.Scan(&service); err != nil {
 			return nil, err
 		}
-		logs = append(logs, entry)
+		services = append(services, service)
+	}
+	return services, rows.Err()
+}
+
+// GetLog returns a single log entry by ID, or sql.ErrNoRows if it doesn't exist
+func (s *Storage) GetLog(ctx context.Context, id int64) (*LogEntry, error) {
+	query := `
+	SELECT id, timestamp, level, message, service, context, raw_log, created_at
+	FROM logs
+	WHERE id = ?
+	`
+
+	logs, err := s.QueryLogs(ctx, query, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(logs) == 0 {
+		return nil, sql.ErrNoRows
 	}
+	return &logs[0], nil
+}
+
+// QueryLogs runs a query selecting the full set of log columns (id, timestamp, level,
+// message, service, context, raw_log, created_at) and scans the results
+func (s *Storage) QueryLogs(ctx context.Context, query string, args ...interface{}) ([]LogEntry, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
 
+	var logs []LogEntry
+	err := s.EachLog(ctx, query, args, func(entry LogEntry) error {
+		logs = append(logs, entry)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
 	return logs, nil
 }
 
+// EachLog runs a query selecting the full set of log columns, as QueryLogs
+// does, and calls fn with each log as it's read
+func (s *Storage) EachLog(ctx context.Context, query string, args []interface{}, fn func(LogEntry) error) error {
+	return s.EachRow(ctx, query, args, func(rows *sql.Rows) error {
+		entry, err := ScanLog(rows)
+		if err != nil {
+			return err
+		}
+		return fn(entry)
+	})
+}
+
+// ScanLog scans a row holding the full set of log columns, in QueryLogs' order
+func ScanLog(rows *sql.Rows) (LogEntry, error) {
+	var entry LogEntry
+	err := rows.Scan(
+		&entry.ID,
+		&entry.Timestamp,
+		&entry.Level,
+		&entry.Message,
+		&entry.Service,
+		&entry.Context,
+		&entry.RawLog,
+		&entry.CreatedAt,
+	)
+	return entry, err
+}
+
+// EachRow runs a query and calls fn to scan each row as it's read, so large
+// results stream through instead of piling up in memory. An error from fn
+// stops the query and is returned. The query timeout doesn't apply, since
+// how long a stream takes is up to its reader; ctx still ends it, e.g. when
+// a web client disconnects mid-export.
+func (s *Storage) EachRow(ctx context.Context, query string, args []interface{}, fn func(*sql.Rows) error) error {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		if err := fn(rows); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
 func (s *Storage) Close() error {
-	if s.retentionMgr != nil {
-		s.retentionMgr.Stop()
+	if s.writes != nil {
+		s.writes.close()
 	}
+	s.DisableAutoRetention()
+	s.writer.close()
 	return s.db.Close()
 }
 
@@ -127,29 +457,49 @@ func (s *Storage) GetDB() *sql.DB {
 	return s.db
 }
 
-// EnableAutoRetention starts automatic log retention with the given config
+// EnableAutoRetention starts automatic log retention with the given config,
+// replacing any manager already running
 func (s *Storage) EnableAutoRetention(config RetentionConfig) {
+	manager := NewAutoRetentionManager(s, config)
+	manager.Start()
+
+	s.retentionMu.Lock()
+	old := s.retentionMgr
 	s.retentionConfig = config
-	s.retentionMgr = NewAutoRetentionManager(s, config)
-	s.retentionMgr.Start()
+	s.retentionMgr = manager
+	s.retentionMu.Unlock()
+
+	if old != nil {
+		old.Stop()
+	}
 }
 
 // DisableAutoRetention stops automatic log retention
 func (s *Storage) DisableAutoRetention() {
-	if s.retentionMgr != nil {
-		s.retentionMgr.Stop()
-		s.retentionMgr = nil
+	s.retentionMu.Lock()
+	old := s.retentionMgr
+	s.retentionMgr = nil
+	s.retentionMu.Unlock()
+
+	if old != nil {
+		old.Stop()
 	}
 }
 
 // GetRetentionConfig returns the current retention configuration
 func (s *Storage) GetRetentionConfig() RetentionConfig {
+	s.retentionMu.Lock()
+	defer s.retentionMu.Unlock()
 	return s.retentionConfig
 }
 
 // TriggerRetentionCheck manually triggers a retention check
 func (s *Storage) TriggerRetentionCheck() {
-	if s.retentionMgr != nil {
-		s.retentionMgr.TriggerCleanupIfNeeded()
+	s.retentionMu.Lock()
+	manager := s.retentionMgr
+	s.retentionMu.Unlock()
+
+	if manager != nil {
+		manager.TriggerCleanupIfNeeded()
 	}
 }
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+)
+
+// newTestStorage returns storage on a fresh database
+func newTestStorage(t *testing.T) *Storage {
+	t.Helper()
+	return newTestStorageAt(t, filepath.Join(t.TempDir(), "logs.db"))
+}
+
+// newTestStorageAt opens storage on the database at path, closing it when the test ends
+func newTestStorageAt(t *testing.T, path string) *Storage {
+	t.Helper()
+	store, err := NewStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store
+}
+
+// countLogs returns how many logs are stored
+func countLogs(t *testing.T, store *Storage) int {
+	t.Helper()
+	var count int
+	if err := store.db.QueryRow("SELECT COUNT(*) FROM logs").Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	return count
+}
+
+// Every pooled connection waits out another's lock instead of failing at once
+func TestConnectionsWaitForLocks(t *testing.T) {
+	store := newTestStorage(t)
+	for i := 0; i < 3; i++ {
+		conn, err := store.db.Conn(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer conn.Close()
+
+		var timeout int64
+		if err := conn.QueryRowContext(context.Background(), "PRAGMA busy_timeout").Scan(&timeout); err != nil {
+			t.Fatal(err)
+		}
+		if timeout != busyTimeout.Milliseconds() {
+			t.Errorf("connection %d has a busy timeout of %dms, want %dms", i, timeout, busyTimeout.Milliseconds())
+		}
+	}
+}
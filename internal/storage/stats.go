@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"context"
+	"time"
+)
+
+// Stats summarizes the log store for status displays
+type Stats struct {
+	TotalLogs  int64
+	Errors     int64   // Error logs in the last hour
+	Warnings   int64   // Warning logs in the last hour
+	SizeBytes  int64   // Database size, from its page count
+	IngestRate float64 // Logs stored per minute over the last 5 minutes
+}
+
+// GetStats counts logs and measures the database
+func (s *Storage) GetStats(ctx context.Context) (Stats, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	var stats Stats
+	var recent int64
+	err := s.db.QueryRowContext(ctx, `
+	SELECT
+		(SELECT COUNT(*) FROM logs),
+		(SELECT COUNT(*) FROM logs WHERE level = 'error' AND timestamp >= datetime('now', '-1 hour')),
+		(SELECT COUNT(*) FROM logs WHERE level IN ('warn', 'warning') AND timestamp >= datetime('now', '-1 hour')),
+		(SELECT COUNT(*) FROM logs WHERE created_at >= datetime('now', '-5 minutes'))`,
+	).Scan(&stats.TotalLogs, &stats.Errors, &stats.Warnings, &recent)
+	if err != nil {
+		return stats, err
+	}
+	stats.IngestRate = float64(recent) / 5
+
+	var pages, pageSize int64
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages); err != nil {
+		return stats, err
+	}
+	if err := s.db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize); err != nil {
+		return stats, err
+	}
+	stats.SizeBytes = pages * pageSize
+
+	return stats, nil
+}
+
+// ServiceLevelCount is how many logs one service stored at one level
+type ServiceLevelCount struct {
+	Service string
+	Level   string
+	Count   int64
+}
+
+// CountAfter counts the logs stored after the log with ID afterID by service
+// and level, and returns the newest ID to pass next time. Counting by ID walks
+// the primary key, so polling it for live rates stays cheap on large databases.
+func (s *Storage) CountAfter(ctx context.Context, afterID int64) ([]ServiceLevelCount, int64, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+
+	var newest int64
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(id), 0) FROM logs`).Scan(&newest); err != nil {
+		return nil, afterID, err
+	}
+	if newest <= afterID {
+		return nil, newest, nil
+	}
+
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT COALESCE(service, ''), COALESCE(LOWER(level), ''), COUNT(*)
+	FROM logs WHERE id > ? AND id <= ?
+	GROUP BY 1, 2`, afterID, newest)
+	if err != nil {
+		return nil, afterID, err
+	}
+	defer rows.Close()
+
+	var counts []ServiceLevelCount
+	for rows.Next() {
+		var c ServiceLevelCount
+		if err := rows.Scan(&c.Service, &c.Level, &c.Count); err != nil {
+			return nil, afterID, err
+		}
+		counts = append(counts, c)
+	}
+	return counts, newest, rows.Err()
+}
+
+// MessageCount is how often a service logged a message
+type MessageCount struct {
+	Service string
+	Message string
+	Count   int64
+}
+
+// TopMessages returns the most frequent messages logged at a level since a
+// time, most frequent first
+func (s *Storage) TopMessages(ctx context.Context, level string, since time.Time, limit int) ([]MessageCount, error) {
+	ctx, cancel := s.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.db.QueryContext(ctx, `
+	SELECT COALESCE(service, ''), COALESCE(message, ''), COUNT(*) AS n
+	FROM logs WHERE level = ? AND timestamp >= ?
+	GROUP BY 1, 2 ORDER BY n DESC LIMIT ?`, level, since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []MessageCount
+	for rows.Next() {
+		var m MessageCount
+		if err := rows.Scan(&m.Service, &m.Message, &m.Count); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+	return messages, rows.Err()
+}
@@ -0,0 +1,224 @@
+package storage
+
+import (
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Write buffer durability modes
+const (
+	// DurabilityFull makes InsertLog wait until its log is committed, so a
+	// caller that gets no error knows the log is on disk. Logs from sources
+	// inserting at the same time still share commits.
+	DurabilityFull = "full"
+
+	// DurabilityBuffered makes InsertLog return as soon as its log is
+	// queued. A crash loses the logs not yet committed, up to a flush
+	// interval's worth; Close still commits everything queued.
+	DurabilityBuffered = "buffered"
+)
+
+// Write buffer defaults
+const (
+	defaultWriteBatch   = 1000
+	defaultWritePending = 10000
+)
+
+// WriteBufferConfig sets how the write buffer groups inserts into commits.
+// Zero fields use the defaults.
+type WriteBufferConfig struct {
+	Durability string // DurabilityFull (default) or DurabilityBuffered
+
+	// FlushInterval is how long the writer gathers logs before committing
+	// them. 0 commits as soon as the previous commit finishes, so each batch
+	// is whatever arrived during it.
+	FlushInterval time.Duration
+
+	MaxBatch   int // Logs per commit; a full batch commits without waiting (default 1000)
+	MaxPending int // Logs queued before InsertLog waits for the writer (default 10000)
+}
+
+// Validate checks the settings
+func (c WriteBufferConfig) Validate() error {
+	switch c.Durability {
+	case "", DurabilityFull, DurabilityBuffered:
+	default:
+		return fmt.Errorf("unknown durability %q (use %s or %s)", c.Durability, DurabilityFull, DurabilityBuffered)
+	}
+	if c.FlushInterval < 0 {
+		return fmt.Errorf("flush_interval can't be negative")
+	}
+	if c.MaxBatch < 0 || c.MaxPending < 0 {
+		return fmt.Errorf("max_batch and max_pending can't be negative")
+	}
+	return nil
+}
+
+// writeBuffer queues logs from every InsertLog caller and commits them in
+// batches from one goroutine. SQLite syncs to disk once per commit, so one
+// commit for many logs raises sustained ingest many times over.
+type writeBuffer struct {
+	store  *Storage
+	config WriteBufferConfig
+	queue  chan pendingLog
+	done   chan struct{} // Closed once the writer has committed everything and exited
+
+	mu     sync.RWMutex // Held for reading while queueing, so close can't race a send
+	closed bool
+}
+
+// pendingLog is a queued log and, in full durability, where its result goes
+type pendingLog struct {
+	entry  LogEntry
+	result chan error
+}
+
+// EnableWriteBuffer makes InsertLog queue logs for a background writer that
+// commits them in batches. Call it before anything inserts; Close commits
+// whatever is still queued.
+func (s *Storage) EnableWriteBuffer(config WriteBufferConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	if config.Durability == "" {
+		config.Durability = DurabilityFull
+	}
+	if config.MaxBatch == 0 {
+		config.MaxBatch = defaultWriteBatch
+	}
+	if config.MaxPending == 0 {
+		config.MaxPending = defaultWritePending
+	}
+
+	s.writes = &writeBuffer{
+		store:  s,
+		config: config,
+		queue:  make(chan pendingLog, config.MaxPending),
+		done:   make(chan struct{}),
+	}
+	go s.writes.run()
+	slog.Info("write buffer enabled", "durability", config.Durability,
+		"flush_interval", config.FlushInterval.String(), "max_batch", config.MaxBatch)
+	return nil
+}
+
+// add queues entry, waiting for its commit in full durability. It reports
+// false once the buffer is closed, leaving the caller to insert directly.
+func (b *writeBuffer) add(entry LogEntry) (bool, error) {
+	b.mu.RLock()
+	if b.closed {
+		b.mu.RUnlock()
+		return false, nil
+	}
+	pending := pendingLog{entry: entry}
+	if b.config.Durability == DurabilityFull {
+		pending.result = make(chan error, 1)
+	}
+	b.queue <- pending
+	b.mu.RUnlock()
+
+	if pending.result == nil {
+		return true, nil
+	}
+	return true, <-pending.result
+}
+
+// close stops queueing and waits for the writer to commit what's queued
+func (b *writeBuffer) close() {
+	b.mu.Lock()
+	if !b.closed {
+		b.closed = true
+		close(b.queue)
+	}
+	b.mu.Unlock()
+	<-b.done
+}
+
+// run gathers queued logs into batches and commits them until the queue closes
+func (b *writeBuffer) run() {
+	defer close(b.done)
+
+	batch := make([]pendingLog, 0, b.config.MaxBatch)
+	for first := range b.queue {
+		batch = append(batch[:0], first)
+		open := b.gather(&batch)
+		b.commit(batch)
+		if !open {
+			return
+		}
+	}
+}
+
+// gather adds queued logs to batch until it's full or the flush interval has
+// passed, or, with no interval, until the queue is empty. It reports whether
+// the queue is still open.
+func (b *writeBuffer) gather(batch *[]pendingLog) bool {
+	var deadline <-chan time.Time
+	if b.config.FlushInterval > 0 {
+		timer := time.NewTimer(b.config.FlushInterval)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for len(*batch) < b.config.MaxBatch {
+		if deadline == nil {
+			select {
+			case pending, ok := <-b.queue:
+				if !ok {
+					return false
+				}
+				*batch = append(*batch, pending)
+			default:
+				return true
+			}
+			continue
+		}
+
+		select {
+		case pending, ok := <-b.queue:
+			if !ok {
+				return false
+			}
+			*batch = append(*batch, pending)
+		case <-deadline:
+			return true
+		}
+	}
+	return true
+}
+
+// commit stores batch in one transaction. If that fails the logs are stored
+// one by one, so a single bad log only fails itself.
+func (b *writeBuffer) commit(batch []pendingLog) {
+	entries := make([]LogEntry, len(batch))
+	for i, pending := range batch {
+		entries[i] = pending.entry
+	}
+
+	err := b.store.InsertLogs(entries)
+	if err == nil {
+		for _, pending := range batch {
+			b.report(pending, nil)
+		}
+		return
+	}
+
+	slog.Warn("batched insert failed, storing logs one by one", "logs", len(batch), "error", err)
+	for _, pending := range batch {
+		b.report(pending, b.store.insertLog(pending.entry))
+	}
+}
+
+// report hands a log's result to its waiting caller, or logs a failure no
+// one is waiting for
+func (b *writeBuffer) report(pending pendingLog, err error) {
+	if pending.result != nil {
+		pending.result <- err
+		return
+	}
+	if err != nil {
+		slog.Error("failed to store buffered log", "service", pending.entry.Service, "error", err)
+	}
+}
@@ -0,0 +1,112 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+)
+
+// errClosed is returned by writes made after Close
+var errClosed = errors.New("storage is closed")
+
+// writer runs storage's frequent writes (log, metric, and span inserts,
+// pattern updates, and retention deletes) one at a time on its own goroutine.
+// SQLite allows one writer at a time anyway; queueing writes here, instead
+// of letting connections race for the lock, means a busy web UI, TUI,
+// alert engine, and ingestion don't fail each other with "database is
+// locked". It matters most for transactions that read before they write,
+// which SQLite can't make wait for the lock and fails at once instead.
+type writer struct {
+	ops  chan writeOp
+	done chan struct{} // Closed once the goroutine has run every queued write and exited
+
+	mu     sync.RWMutex // Held for reading while queueing, so close can't race a send
+	closed bool
+}
+
+// writeOp is a queued write and where its result goes
+type writeOp struct {
+	ctx    context.Context
+	fn     func(*sql.Tx) error
+	result chan error
+}
+
+func (s *Storage) startWriter() {
+	s.writer = &writer{
+		ops:  make(chan writeOp),
+		done: make(chan struct{}),
+	}
+	go s.writer.run(s.db)
+}
+
+// write runs fn in a transaction on the writer goroutine and commits it
+// unless fn returns an error. fn mustn't call write itself.
+func (s *Storage) write(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	op := writeOp{ctx: ctx, fn: fn, result: make(chan error, 1)}
+
+	s.writer.mu.RLock()
+	if s.writer.closed {
+		s.writer.mu.RUnlock()
+		return errClosed
+	}
+	select {
+	case s.writer.ops <- op:
+	case <-ctx.Done():
+		s.writer.mu.RUnlock()
+		return ctx.Err()
+	}
+	s.writer.mu.RUnlock()
+
+	return <-op.result
+}
+
+func (w *writer) run(db *sql.DB) {
+	defer close(w.done)
+	for op := range w.ops {
+		op.result <- apply(db, op)
+	}
+}
+
+func apply(db *sql.DB, op writeOp) error {
+	if err := op.ctx.Err(); err != nil {
+		return err
+	}
+	tx, err := db.BeginTx(op.ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := op.fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// deleteBefore runs a DELETE on the writer goroutine and returns how many
+// rows it removed
+func (s *Storage) deleteBefore(query string, cutoff time.Time) (int64, error) {
+	var deleted int64
+	err := s.write(context.Background(), func(tx *sql.Tx) error {
+		result, err := tx.Exec(query, cutoff)
+		if err != nil {
+			return err
+		}
+		deleted, err = result.RowsAffected()
+		return err
+	})
+	return deleted, err
+}
+
+// close stops taking writes and waits for the queued ones to finish
+func (w *writer) close() {
+	w.mu.Lock()
+	if !w.closed {
+		w.closed = true
+		close(w.ops)
+	}
+	w.mu.Unlock()
+	<-w.done
+}
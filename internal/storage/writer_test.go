@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// Close finishes the writes already queued, and writes after it fail
+func TestWriterCloseOrdering(t *testing.T) {
+	store := newTestStorage(t)
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var stored, refused int
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := store.InsertLogs([]LogEntry{{Level: "info", Message: "hello"}})
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				stored++
+			case errors.Is(err, errClosed):
+				refused++
+			default:
+				t.Error(err)
+			}
+		}()
+	}
+	store.writer.close()
+	wg.Wait()
+
+	if stored+refused != 50 {
+		t.Fatalf("%d stored and %d refused, want 50 in all", stored, refused)
+	}
+	// Reads still work until the database itself closes
+	if got := countLogs(t, store); got != stored {
+		t.Errorf("%d logs in the database, but %d inserts succeeded", got, stored)
+	}
+	if err := store.InsertLogs([]LogEntry{{Message: "late"}}); !errors.Is(err, errClosed) {
+		t.Errorf("insert after close got %v, want errClosed", err)
+	}
+}
+
+// Close commits logs still in a buffered write buffer before closing the database
+func TestCloseFlushesWriteBuffer(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.db")
+	store, err := NewStorage(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.EnableWriteBuffer(WriteBufferConfig{Durability: DurabilityBuffered, FlushInterval: time.Hour}); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		if err := store.InsertLog(LogEntry{Level: "info", Message: "queued"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := newTestStorageAt(t, path)
+	if got := countLogs(t, reopened); got != 20 {
+		t.Errorf("%d logs committed on close, want 20", got)
+	}
+}
+
+// Copies of a batch arriving at once, as when an agent retries while the
+// first request is still running, are stored once
+func TestStoreAgentBatchConcurrentResends(t *testing.T) {
+	store := newTestStorage(t)
+	agent := Agent{ID: "a1", Name: "web-1"}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, _, err := store.StoreAgentBatch(agent, sequenced(1, 100)); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := countLogs(t, store); got != 100 {
+		t.Errorf("%d logs stored, want 100", got)
+	}
+}
@@ -0,0 +1,188 @@
+// Package telemetry records Peep's own operational metrics into the metrics
+// table, so its health history can be queried and charted like any other data.
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"runtime"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Recorder samples the daemon's health every Interval
+type Recorder struct {
+	Store    *storage.Storage
+	Engine   *alerts.Engine // nil when alerts aren't running
+	Interval time.Duration
+}
+
+// snapshot is the running totals at one moment; metrics are the differences
+// between consecutive snapshots
+type snapshot struct {
+	at       time.Time
+	newestID int64
+	ops      storage.OpStats
+	sent     int64
+	failed   int64
+}
+
+// Run records a sample every interval until ctx is cancelled
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.Interval)
+	defer ticker.Stop()
+
+	last, err := r.snapshot(0)
+	if err != nil {
+		slog.Warn("telemetry disabled", "error", err)
+		return
+	}
+	slog.Debug("telemetry recording", "interval", r.Interval.String())
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		current, err := r.snapshot(last.newestID)
+		if err != nil {
+			slog.Warn("failed to sample telemetry", "error", err)
+			continue
+		}
+		if err := r.Store.InsertMetrics(r.metrics(last, current)); err != nil {
+			slog.Warn("failed to record telemetry", "error", err)
+		}
+		last = current
+	}
+}
+
+func (r *Recorder) snapshot(afterID int64) (snapshot, error) {
+	s := snapshot{at: time.Now(), ops: r.Store.OpStats()}
+	if r.Engine != nil {
+		s.sent, s.failed = r.Engine.NotificationStats()
+	}
+
+	// Counting new rows rather than this process's inserts includes logs
+	// ingested by other peep commands writing to the same database
+	_, newest, err := r.Store.CountAfter(context.Background(), afterID)
+	if err != nil {
+		return s, err
+	}
+	s.newestID = newest
+	return s, nil
+}
+
+// metrics turns the change between two snapshots into samples
+func (r *Recorder) metrics(last, current snapshot) []storage.Metric {
+	seconds := current.at.Sub(last.at).Seconds()
+	logs := float64(current.newestID - last.newestID)
+
+	samples := map[string]float64{
+		"peep.ingest.logs":          logs,
+		"peep.ingest.eps":           logs / seconds,
+		"peep.retention.cleanups":   float64(current.ops.Cleanups - last.ops.Cleanups),
+		"peep.retention.deleted":    float64(current.ops.CleanupDeleted - last.ops.CleanupDeleted),
+		"peep.notifications.sent":   float64(current.sent - last.sent),
+		"peep.notifications.failed": float64(current.failed - last.failed),
+	}
+	if inserts := current.ops.Inserts - last.ops.Inserts; inserts > 0 {
+		insertTime := current.ops.InsertTime - last.ops.InsertTime
+		samples["peep.insert.latency_ms"] = float64(insertTime) / float64(time.Millisecond) / float64(inserts)
+	}
+
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	samples["peep.memory.heap_mb"] = float64(mem.HeapAlloc) / (1 << 20)
+	samples["peep.memory.sys_mb"] = float64(mem.Sys) / (1 << 20)
+	samples["peep.goroutines"] = float64(runtime.NumGoroutine())
+
+	metrics := make([]storage.Metric, 0, len(samples))
+	for name, value := range samples {
+		metrics = append(metrics, storage.Metric{Timestamp: current.at, Name: name, Value: value})
+	}
+	return metrics
+}
+
+// dashboardSetting marks that the health dashboard has been created, so it
+// isn't recreated after someone deletes it
+const dashboardSetting = "telemetry.dashboard_created"
+
+// EnsureDashboard creates the "Peep health" dashboard the first time telemetry
+// runs against a database
+func EnsureDashboard(store *storage.Storage) error {
+	var created bool
+	if _, found, err := store.GetSetting(dashboardSetting, &created); err != nil || found {
+		return err
+	}
+
+	// Don't overwrite a dashboard someone made with the same name
+	if _, err := store.GetDashboard(Dashboard.Name); err == nil {
+		return store.PutSetting(dashboardSetting, true)
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+	dashboard := Dashboard
+	dashboard.Panels = append([]storage.DashboardPanel(nil), Dashboard.Panels...)
+	if err := store.SaveDashboard(&dashboard); err != nil {
+		return err
+	}
+	return store.PutSetting(dashboardSetting, true)
+}
+
+// Dashboard charts the telemetry over the last day
+var Dashboard = storage.Dashboard{
+	Name:        "peep-health",
+	Title:       "Peep health",
+	Description: "Peep's own metrics, recorded by peep daemon",
+	Panels: []storage.DashboardPanel{
+		{
+			Title: "Logs/sec (latest)",
+			Type:  storage.PanelStat,
+			Query: `SELECT ROUND(value, 1) FROM metrics WHERE name = 'peep.ingest.eps' ORDER BY timestamp DESC LIMIT 1`,
+		},
+		{
+			Title: "Ingest rate (logs/sec)",
+			Type:  storage.PanelTimeSeries,
+			Query: seriesQuery("eps", "peep.ingest.eps"),
+		},
+		{
+			Title: "Insert latency (ms)",
+			Type:  storage.PanelTimeSeries,
+			Query: seriesQuery("latency_ms", "peep.insert.latency_ms"),
+		},
+		{
+			Title: "Memory (MB)",
+			Type:  storage.PanelTimeSeries,
+			Query: `SELECT strftime('%Y-%m-%d %H:%M', timestamp) AS minute,
+  ROUND(MAX(CASE WHEN name = 'peep.memory.heap_mb' THEN value END), 1) AS heap_mb,
+  ROUND(MAX(CASE WHEN name = 'peep.memory.sys_mb' THEN value END), 1) AS sys_mb
+FROM metrics
+WHERE name IN ('peep.memory.heap_mb', 'peep.memory.sys_mb') AND timestamp > datetime('now', '-24 hours')
+GROUP BY minute ORDER BY minute`,
+		},
+		{
+			Title: "Last 24 hours",
+			Type:  storage.PanelTable,
+			Query: `SELECT name, SUM(value) AS total
+FROM metrics
+WHERE name IN ('peep.ingest.logs', 'peep.retention.cleanups', 'peep.retention.deleted',
+  'peep.notifications.sent', 'peep.notifications.failed')
+  AND timestamp > datetime('now', '-24 hours')
+GROUP BY name ORDER BY name`,
+		},
+	},
+}
+
+// seriesQuery charts one metric over the last day
+func seriesQuery(column, name string) string {
+	return `SELECT strftime('%Y-%m-%d %H:%M', timestamp) AS minute, ROUND(value, 2) AS ` + column + `
+FROM metrics
+WHERE name = '` + name + `' AND timestamp > datetime('now', '-24 hours')
+ORDER BY timestamp`
+}
@@ -0,0 +1,212 @@
+package tokens
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Scope limits what an API token can do
+type Scope string
+
+const (
+	ScopeIngest Scope = "ingest" // Send logs only
+	ScopeRead   Scope = "read"   // Browse logs and dashboards, like a viewer login
+	ScopeAdmin  Scope = "admin"  // Everything, including changes to alerts and channels
+)
+
+// tokenPrefix marks Peep tokens so they're easy to spot in config files and secret scanners
+const tokenPrefix = "peep_"
+
+// lastUsedResolution is how stale a token's last use can get before Authenticate updates it
+const lastUsedResolution = time.Minute
+
+// ParseScope validates a scope name
+func ParseScope(s string) (Scope, error) {
+	switch scope := Scope(strings.ToLower(strings.TrimSpace(s))); scope {
+	case ScopeIngest, ScopeRead, ScopeAdmin:
+		return scope, nil
+	default:
+		return "", fmt.Errorf("invalid scope %q (use ingest, read, or admin)", s)
+	}
+}
+
+// Token is a stored API token. The secret itself is never stored, only its SHA-256 hash.
+type Token struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	Scope     Scope     `json:"scope"`
+	Prefix    string    `json:"prefix"` // First characters of the secret, for identifying tokens in lists
+	CreatedAt time.Time `json:"created_at"`
+	LastUsed  time.Time `json:"last_used,omitempty"`
+	Revoked   bool      `json:"revoked"`
+}
+
+// Allows reports whether the token grants the given scope.
+// Admin tokens grant everything; read and ingest tokens only grant themselves.
+func (t *Token) Allows(scope Scope) bool {
+	return t.Scope == ScopeAdmin || t.Scope == scope
+}
+
+// Store manages API tokens in the database
+type Store struct {
+	db *sql.DB
+}
+
+// NewStore creates a token store, creating its table if needed
+func NewStore(db *sql.DB) (*Store, error) {
+	store := &Store{db: db}
+	if err := store.createTables(); err != nil {
+		return nil, fmt.Errorf("failed to create token tables: %w", err)
+	}
+	return store, nil
+}
+
+func (s *Store) createTables() error {
+	schema := `
+	CREATE TABLE IF NOT EXISTS api_tokens (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL,
+		scope TEXT NOT NULL,
+		token_hash TEXT NOT NULL UNIQUE,
+		prefix TEXT NOT NULL,
+		created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+		last_used DATETIME,
+		revoked BOOLEAN NOT NULL DEFAULT 0
+	);
+	`
+
+	_, err := s.db.Exec(schema)
+	return err
+}
+
+// Create generates a new token and returns its secret. The secret can't be recovered later.
+func (s *Store) Create(name string, scope Scope) (string, *Token, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", nil, fmt.Errorf("failed to generate token: %w", err)
+	}
+	secret := tokenPrefix + hex.EncodeToString(buf)
+
+	token := &Token{
+		Name:      name,
+		Scope:     scope,
+		Prefix:    secret[:len(tokenPrefix)+8],
+		CreatedAt: time.Now(),
+	}
+
+	result, err := s.db.Exec(`
+	INSERT INTO api_tokens (name, scope, token_hash, prefix, created_at)
+	VALUES (?, ?, ?, ?, ?)
+	`, token.Name, string(token.Scope), hashToken(secret), token.Prefix, token.CreatedAt)
+	if err != nil {
+		return "", nil, err
+	}
+
+	token.ID, err = result.LastInsertId()
+	if err != nil {
+		return "", nil, err
+	}
+
+	return secret, token, nil
+}
+
+// List returns all tokens, including revoked ones
+func (s *Store) List() ([]*Token, error) {
+	rows, err := s.db.Query(`
+	SELECT id, name, scope, prefix, created_at, last_used, revoked
+	FROM api_tokens
+	ORDER BY id
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var result []*Token
+	for rows.Next() {
+		token, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, token)
+	}
+	return result, rows.Err()
+}
+
+// Revoke disables a token. Revoked tokens stay listed so their use can be audited.
+func (s *Store) Revoke(id int64) error {
+	result, err := s.db.Exec(`UPDATE api_tokens SET revoked = 1 WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("token %d not found", id)
+	}
+	return nil
+}
+
+// Authenticate looks up an active token by its secret and records its use
+func (s *Store) Authenticate(secret string) (*Token, error) {
+	if !strings.HasPrefix(secret, tokenPrefix) {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	row := s.db.QueryRow(`
+	SELECT id, name, scope, prefix, created_at, last_used, revoked
+	FROM api_tokens
+	WHERE token_hash = ?
+	`, hashToken(secret))
+
+	token, err := scanToken(row)
+	if err != nil || token.Revoked {
+		return nil, fmt.Errorf("invalid token")
+	}
+
+	// A log shipper authenticates every batch, so only write when the stored
+	// time is stale rather than on every request
+	if now := time.Now(); now.Sub(token.LastUsed) >= lastUsedResolution {
+		if _, err := s.db.Exec(`UPDATE api_tokens SET last_used = ? WHERE id = ?`, now, token.ID); err == nil {
+			token.LastUsed = now
+		}
+	}
+
+	return token, nil
+}
+
+// scanner is implemented by *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanToken(row scanner) (*Token, error) {
+	var token Token
+	var scope string
+	var lastUsed sql.NullTime
+
+	if err := row.Scan(&token.ID, &token.Name, &scope, &token.Prefix, &token.CreatedAt, &lastUsed, &token.Revoked); err != nil {
+		return nil, err
+	}
+
+	token.Scope = Scope(scope)
+	if lastUsed.Valid {
+		token.LastUsed = lastUsed.Time
+	}
+	return &token, nil
+}
+
+// hashToken returns the hex SHA-256 of a token secret. Tokens are long random
+// strings, so a fast unsalted hash is enough to keep them safe at rest.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,151 @@
+package tokens
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+func newTestStore(t *testing.T) *Store {
+	t.Helper()
+	db, err := storage.NewStorage(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	store, err := NewStore(db.GetDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestCreateStoresOnlyHash(t *testing.T) {
+	store := newTestStore(t)
+	secret, token, err := store.Create("shipper", ScopeIngest)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(secret, tokenPrefix) || !strings.HasPrefix(secret, token.Prefix) {
+		t.Errorf("secret %q doesn't start with %q and prefix %q", secret, tokenPrefix, token.Prefix)
+	}
+
+	var name, scope, hash, prefix string
+	err = store.db.QueryRow("SELECT name, scope, token_hash, prefix FROM api_tokens WHERE id = ?", token.ID).Scan(&name, &scope, &hash, &prefix)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, column := range []string{name, scope, hash, prefix} {
+		if strings.Contains(column, secret) {
+			t.Errorf("stored %q contains the secret", column)
+		}
+	}
+	if hash != hashToken(secret) {
+		t.Errorf("stored hash %q, want %q", hash, hashToken(secret))
+	}
+
+	got, err := store.Authenticate(secret)
+	if err != nil || got.ID != token.ID || got.Scope != ScopeIngest {
+		t.Errorf("Authenticate = %+v, %v, want token %d", got, err, token.ID)
+	}
+	for _, wrong := range []string{"", hash, secret[:len(secret)-1], tokenPrefix + strings.Repeat("0", 64)} {
+		if _, err := store.Authenticate(wrong); err == nil {
+			t.Errorf("Authenticate(%q) succeeded", wrong)
+		}
+	}
+}
+
+func TestRevoke(t *testing.T) {
+	store := newTestStore(t)
+	secret, token, err := store.Create("old", ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Revoke(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(secret); err == nil {
+		t.Error("revoked token still authenticates")
+	}
+	if err := store.Revoke(token.ID + 1); err == nil {
+		t.Error("revoking a missing token succeeded")
+	}
+
+	// Revoked tokens stay listed for auditing
+	list, err := store.List()
+	if err != nil || len(list) != 1 || !list[0].Revoked {
+		t.Errorf("List = %+v, %v, want the revoked token", list, err)
+	}
+}
+
+func TestAllows(t *testing.T) {
+	tests := []struct {
+		token Scope
+		scope Scope
+		want  bool
+	}{
+		{ScopeAdmin, ScopeAdmin, true},
+		{ScopeAdmin, ScopeIngest, true},
+		{ScopeAdmin, ScopeRead, true},
+		{ScopeIngest, ScopeIngest, true},
+		{ScopeIngest, ScopeRead, false},
+		{ScopeIngest, ScopeAdmin, false},
+		{ScopeRead, ScopeRead, true},
+		{ScopeRead, ScopeIngest, false},
+		{ScopeRead, ScopeAdmin, false},
+	}
+	for _, tt := range tests {
+		token := &Token{Scope: tt.token}
+		if got := token.Allows(tt.scope); got != tt.want {
+			t.Errorf("%s token Allows(%s) = %v, want %v", tt.token, tt.scope, got, tt.want)
+		}
+	}
+}
+
+func TestLastUsedThrottled(t *testing.T) {
+	store := newTestStore(t)
+	secret, token, err := store.Create("shipper", ScopeIngest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lastUsed := func() time.Time {
+		t.Helper()
+		list, err := store.List()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return list[0].LastUsed
+	}
+
+	if _, err := store.Authenticate(secret); err != nil {
+		t.Fatal(err)
+	}
+	first := lastUsed()
+	if first.IsZero() {
+		t.Fatal("first use wasn't recorded")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := store.Authenticate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); !got.Equal(first) {
+		t.Errorf("last_used moved from %v to %v within %v", first, got, lastUsedResolution)
+	}
+
+	stale := time.Now().Add(-2 * lastUsedResolution)
+	if _, err := store.db.Exec("UPDATE api_tokens SET last_used = ? WHERE id = ?", stale, token.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Authenticate(secret); err != nil {
+		t.Fatal(err)
+	}
+	if got := lastUsed(); !got.After(stale) {
+		t.Errorf("stale last_used %v wasn't updated", got)
+	}
+}
@@ -0,0 +1,253 @@
+package tui
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/muesli/termenv"
+)
+
+// Config customizes the TUI's colors and keys. It's read from a JSON file, where
+// anything left out keeps its default:
+//
+//	{
+//	  "no_color": false,
+//	  "no_mouse": false,
+//	  "colors": {"title_bg": "#7D56F4", "level_error": "9"},
+//	  "keys": {"quit": ["ctrl+q"], "search": ["/", "ctrl+f"]},
+//	  "layout": {"timestamp": "datetime", "hide_service": true, "message": "raw", "detail_width": 100}
+//	}
+type Config struct {
+	NoColor bool                `json:"no_color"` // Plain text, for limited terminals and screen readers
+	NoMouse bool                `json:"no_mouse"` // Leave the mouse to the terminal, for selecting text
+	Colors  map[string]string   `json:"colors"`   // Style name -> hex color or ANSI color number
+	Keys    map[string][]string `json:"keys"`     // Action -> keys that trigger it
+	Layout  Layout              `json:"layout"`
+}
+
+// Layout picks the fields shown for each log
+type Layout struct {
+	Timestamp       string `json:"timestamp"`        // A timestampFormats name or a Go time layout
+	HideService     bool   `json:"hide_service"`     // Leave [service] out of list items
+	Message         string `json:"message"`          // "parsed" (default) or "raw": the line as ingested
+	HideDescription bool   `json:"hide_description"` // One line per log, without the second line
+	DetailWidth     int    `json:"detail_width"`     // Detail pane columns; 0 fills the terminal
+}
+
+// timestampFormats are the named list timestamp formats
+var timestampFormats = map[string]string{
+	"time":     "15:04:05",
+	"millis":   "15:04:05.000",
+	"datetime": "2006-01-02 15:04:05",
+	"rfc3339":  time.RFC3339,
+}
+
+// timestampLayout is the Go layout for list timestamps
+func (l Layout) timestampLayout() string {
+	if layout, ok := timestampFormats[l.Timestamp]; ok {
+		return layout
+	}
+	if l.Timestamp != "" {
+		return l.Timestamp
+	}
+	return timestampFormats["time"]
+}
+
+// defaultColors are the styles the colors setting can change
+var defaultColors = map[string]string{
+	"title_fg":       "#FFFDF5",
+	"title_bg":       "#25A065",
+	"status":         "#888888",
+	"help":           "#626262",
+	"error":          "#FF0000",
+	"selected_title": "#F25D94",
+	"selected_desc":  "#AD58B4",
+	"heading":        "#25A065",
+	"label":          "#888888",
+	"match_fg":       "#282A36",
+	"match_bg":       "#F1FA8C",
+	"table_border":   "#626262",
+	"level_error":    "#FF5555",
+	"level_warn":     "#FFB86C",
+	"level_info":     "#8BE9FD",
+	"level_debug":    "#BD93F9",
+}
+
+// defaultKeys are the actions the keys setting can rebind. Navigation (arrows,
+// enter, esc) stays fixed.
+var defaultKeys = map[string][]string{
+	"quit":       {"q", "ctrl+c"},
+	"search":     {"/"},
+	"next_match": {"n"},
+	"prev_match": {"N"},
+	"refresh":    {"r"},
+	"follow":     {"f"},
+	"jump":       {"t"},
+	"query":      {":"},
+	"level":      {"e"},
+	"service":    {"s"},
+	"copy":       {"y"},
+	"copy_raw":   {"Y"},
+	"export":     {"x"},
+	"next_issue": {"]"},
+	"prev_issue": {"["},
+	"trace":      {"T"},
+}
+
+// DefaultConfigPath is where LoadConfig looks when no path is given
+func DefaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "peep", "tui.json")
+}
+
+// LoadConfig reads the config file at path, or at DefaultConfigPath if path is
+// empty. A missing default file isn't an error; a missing explicit one is. The
+// NO_COLOR environment variable turns on no_color.
+func LoadConfig(path string) (Config, error) {
+	var config Config
+
+	explicit := path != ""
+	if !explicit {
+		path = DefaultConfigPath()
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist) && !explicit:
+		case err != nil:
+			return config, err
+		default:
+			if err := json.Unmarshal(data, &config); err != nil {
+				return config, fmt.Errorf("invalid TUI config %s: %w", path, err)
+			}
+		}
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		config.NoColor = true
+	}
+	return config, config.Validate()
+}
+
+// Validate catches misspelled style and action names, which would otherwise be ignored
+func (c Config) Validate() error {
+	for name := range c.Colors {
+		if _, ok := defaultColors[name]; !ok {
+			return fmt.Errorf("unknown color %q; expected one of %s", name, strings.Join(sortedKeys(defaultColors), ", "))
+		}
+	}
+	for action, keys := range c.Keys {
+		if _, ok := defaultKeys[action]; !ok {
+			return fmt.Errorf("unknown key action %q; expected one of %s", action, strings.Join(sortedKeys(defaultKeys), ", "))
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("key action %q needs at least one key", action)
+		}
+	}
+	if m := c.Layout.Message; m != "" && m != "parsed" && m != "raw" {
+		return fmt.Errorf("unknown layout message %q; expected parsed or raw", m)
+	}
+	if c.Layout.DetailWidth < 0 {
+		return fmt.Errorf("layout detail_width can't be negative")
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// keyMap holds the configurable key bindings
+type keyMap struct {
+	Quit, Search, NextMatch, PrevMatch, Refresh, Follow, Jump, Query, Level, Service key.Binding
+
+	Copy, CopyRaw, Export, NextIssue, PrevIssue, Trace key.Binding
+}
+
+// keys returns the key bindings with the config's overrides
+func (c Config) keys() keyMap {
+	binding := func(action string) key.Binding {
+		keys, ok := c.Keys[action]
+		if !ok {
+			keys = defaultKeys[action]
+		}
+		return key.NewBinding(key.WithKeys(keys...))
+	}
+
+	return keyMap{
+		Quit:      binding("quit"),
+		Search:    binding("search"),
+		NextMatch: binding("next_match"),
+		PrevMatch: binding("prev_match"),
+		Refresh:   binding("refresh"),
+		Follow:    binding("follow"),
+		Jump:      binding("jump"),
+		Query:     binding("query"),
+		Level:     binding("level"),
+		Service:   binding("service"),
+		Copy:      binding("copy"),
+		CopyRaw:   binding("copy_raw"),
+		Export:    binding("export"),
+		NextIssue: binding("next_issue"),
+		PrevIssue: binding("prev_issue"),
+		Trace:     binding("trace"),
+	}
+}
+
+// keyName is the first key of a binding, for help text
+func keyName(b key.Binding) string {
+	return b.Keys()[0]
+}
+
+// newDelegate renders list items in the configured selection colors
+func newDelegate(c Config) list.DefaultDelegate {
+	delegate := list.NewDefaultDelegate()
+	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.Foreground(c.color("selected_title"))
+	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.Foreground(c.color("selected_desc"))
+	return delegate
+}
+
+// color returns the configured color for a style
+func (c Config) color(name string) lipgloss.Color {
+	if value, ok := c.Colors[name]; ok {
+		return lipgloss.Color(value)
+	}
+	return lipgloss.Color(defaultColors[name])
+}
+
+// applyTheme colors the package's styles. With no_color, lipgloss drops all
+// colors and text attributes, and search matches are marked with « » instead.
+func (c Config) applyTheme() {
+	if c.NoColor {
+		lipgloss.SetColorProfile(termenv.Ascii)
+		plainMatches = true
+	}
+
+	titleStyle = titleStyle.Foreground(c.color("title_fg")).Background(c.color("title_bg"))
+	statusStyle = statusStyle.Foreground(c.color("status"))
+	helpStyle = helpStyle.Foreground(c.color("help"))
+	errorStyle = errorStyle.Foreground(c.color("error"))
+	detailLabelStyle = detailLabelStyle.Foreground(c.color("label"))
+	detailHeadingStyle = detailHeadingStyle.Foreground(c.color("heading"))
+	matchStyle = matchStyle.Foreground(c.color("match_fg")).Background(c.color("match_bg"))
+	for level := range levelStyles {
+		levelStyles[level] = levelStyles[level].Foreground(c.color("level_" + level))
+	}
+}
@@ -0,0 +1,76 @@
+package tui
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Detail pane styles, colored by Config.applyTheme
+var (
+	detailLabelStyle   = lipgloss.NewStyle().Width(10)
+	detailHeadingStyle = lipgloss.NewStyle().Bold(true).MarginTop(1)
+)
+
+// renderDetail formats every field of a log entry for the detail pane, wrapping
+// long text to width and highlighting matches of the search, if any
+func renderDetail(entry storage.LogEntry, width int, match *regexp.Regexp) string {
+	wrap := lipgloss.NewStyle().Width(max(width, 20))
+
+	levelStyle, exists := levelStyles[strings.ToLower(entry.Level)]
+	if !exists {
+		levelStyle = lipgloss.NewStyle()
+	}
+
+	var b strings.Builder
+	field := func(label, value string) {
+		if value == "" {
+			value = "-"
+		}
+		b.WriteString(detailLabelStyle.Render(label) + value + "\n")
+	}
+
+	field("ID", fmt.Sprintf("%d", entry.ID))
+	field("Time", entry.Timestamp.Format("2006-01-02 15:04:05.000 MST"))
+	field("Level", levelStyle.Render(strings.ToUpper(entry.Level)))
+	field("Service", highlight(entry.Service, match))
+	if traceID := storage.TraceID(entry.Context); traceID != "" {
+		field("Trace", highlight(traceID, match))
+	}
+	if !entry.CreatedAt.IsZero() {
+		field("Stored", entry.CreatedAt.Format("2006-01-02 15:04:05 MST"))
+	}
+
+	b.WriteString(detailHeadingStyle.Render("Message") + "\n")
+	b.WriteString(wrap.Render(highlight(entry.Message, match)) + "\n")
+
+	if context := prettyContext(entry.Context); context != "" {
+		b.WriteString(detailHeadingStyle.Render("Context") + "\n")
+		b.WriteString(wrap.Render(highlight(context, match)) + "\n")
+	}
+
+	b.WriteString(detailHeadingStyle.Render("Raw Log") + "\n")
+	b.WriteString(wrap.Render(highlight(entry.RawLog, match)) + "\n")
+
+	return b.String()
+}
+
+// prettyContext indents the context JSON, returning it unchanged if it isn't
+// valid JSON and "" if there's nothing in it
+func prettyContext(context string) string {
+	context = strings.TrimSpace(context)
+	if context == "" || context == "{}" || context == "null" {
+		return ""
+	}
+
+	var out bytes.Buffer
+	if err := json.Indent(&out, []byte(context), "", "  "); err != nil {
+		return context
+	}
+	return out.String()
+}
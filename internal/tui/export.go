@@ -0,0 +1,180 @@
+package tui
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kylereynolds/peep/internal/storage"
+	"github.com/muesli/termenv"
+)
+
+// clipboardCommands are tried in order to copy text; the first one installed and
+// working wins
+var clipboardCommands = [][]string{
+	{"pbcopy"},
+	{"wl-copy"},
+	{"xclip", "-selection", "clipboard"},
+	{"xsel", "--clipboard", "--input"},
+	{"clip.exe"},
+}
+
+// noticeMsg reports the result of a copy in the status bar
+type noticeMsg string
+
+// copyToClipboard copies text with the system clipboard tool, falling back to the
+// OSC 52 escape sequence, which most terminals (and SSH sessions) pass through
+func copyToClipboard(text, what string) tea.Cmd {
+	return func() tea.Msg {
+		for _, args := range clipboardCommands {
+			if _, err := exec.LookPath(args[0]); err != nil {
+				continue
+			}
+			cmd := exec.Command(args[0], args[1:]...)
+			cmd.Stdin = strings.NewReader(text)
+			if err := cmd.Run(); err == nil {
+				return noticeMsg("Copied " + what)
+			}
+		}
+		termenv.Copy(text)
+		return noticeMsg("Copied " + what + " via the terminal")
+	}
+}
+
+// logLine formats a log as one line of plain text
+func logLine(entry storage.LogEntry) string {
+	return fmt.Sprintf("%s %s [%s] %s",
+		entry.Timestamp.Format("2006-01-02 15:04:05"),
+		strings.ToUpper(entry.Level),
+		entry.Service,
+		entry.Message)
+}
+
+// rawJSON is a log's raw line if it's JSON, or else the parsed entry as JSON
+func rawJSON(entry storage.LogEntry) string {
+	if json.Valid([]byte(entry.RawLog)) {
+		return entry.RawLog
+	}
+	data, _ := json.Marshal(entry)
+	return string(data)
+}
+
+// copySelected copies the log in the detail pane or selected in the list
+func (m *Model) copySelected(raw bool) tea.Cmd {
+	entry := m.detailEntry
+	if !m.detailMode {
+		item, ok := m.list.SelectedItem().(LogItem)
+		if !ok {
+			return nil
+		}
+		entry = item.Entry
+	}
+
+	if raw {
+		return copyToClipboard(rawJSON(entry), fmt.Sprintf("log #%d as JSON", entry.ID))
+	}
+	return copyToClipboard(logLine(entry), fmt.Sprintf("log #%d", entry.ID))
+}
+
+// visibleLogs are the logs the list shows, after the list's own filter
+func (m *Model) visibleLogs() []storage.LogEntry {
+	var logs []storage.LogEntry
+	for _, item := range m.list.VisibleItems() {
+		if logItem, ok := item.(LogItem); ok {
+			logs = append(logs, logItem.Entry)
+		}
+	}
+	return logs
+}
+
+// exportLogs writes logs to path, as JSON if it ends in .json and CSV otherwise,
+// in the same layout as the web UI's exports
+func exportLogs(path string, logs []storage.LogEntry) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		if logs == nil {
+			logs = []storage.LogEntry{}
+		}
+		encoder := json.NewEncoder(f)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(logs); err != nil {
+			return err
+		}
+		return f.Close()
+	}
+
+	cw := csv.NewWriter(f)
+	cw.Write([]string{"id", "timestamp", "level", "service", "message", "raw_log"})
+	for _, log := range logs {
+		cw.Write([]string{
+			strconv.FormatInt(log.ID, 10),
+			log.Timestamp.Format(time.RFC3339),
+			log.Level,
+			log.Service,
+			log.Message,
+			log.RawLog,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// openExportPrompt asks where to export the list, suggesting a timestamped file
+func (m *Model) openExportPrompt() tea.Cmd {
+	m.exportMode = true
+	m.exportErr = ""
+	m.export.SetValue(fmt.Sprintf("peep-logs-%s.csv", time.Now().Format("20060102-150405")))
+	m.export.CursorEnd()
+	m.export.Focus()
+	return textinput.Blink
+}
+
+// updateExportPrompt handles keys while the export prompt is open
+func (m *Model) updateExportPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.exportMode = false
+		m.export.Blur()
+		return m, nil
+	case "enter":
+		path := strings.TrimSpace(m.export.Value())
+		if path == "" {
+			return m, nil
+		}
+		logs := m.visibleLogs()
+		if err := exportLogs(path, logs); err != nil {
+			m.exportErr = err.Error()
+			return m, nil
+		}
+		m.exportMode = false
+		m.export.Blur()
+		m.notice = fmt.Sprintf("Exported %d logs to %s", len(logs), path)
+		if m.list.FilterState() != list.Unfiltered {
+			m.notice += " (matching the list filter)"
+		}
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.export, cmd = m.export.Update(msg)
+	return m, cmd
+}
@@ -0,0 +1,162 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// levelFilter is one step of the level filter cycled with 'e'
+type levelFilter struct {
+	Name   string
+	Levels []string // Stored levels it matches
+}
+
+// levelFilters are cycled in order; the first shows every level
+var levelFilters = []levelFilter{
+	{Name: "all"},
+	{Name: "error", Levels: []string{"error"}},
+	{Name: "warn", Levels: []string{"warn", "warning"}},
+	{Name: "info", Levels: []string{"info"}},
+	{Name: "debug", Levels: []string{"debug"}},
+}
+
+// issueLevels are the levels the next/previous issue keys stop at
+var issueLevels = map[string]bool{"error": true, "warn": true, "warning": true}
+
+// nextIssue selects the next error or warning from the selection, stepping by
+// step (1 down, -1 up) and wrapping around like n/N
+func (m *Model) nextIssue(step int) {
+	found := m.selectNext(m.list.Index(), step, func(entry storage.LogEntry) bool {
+		return issueLevels[strings.ToLower(entry.Level)]
+	})
+	if !found {
+		m.notice = "No errors or warnings in the list"
+	}
+}
+
+// serviceItem is an entry in the service picker; the empty service means all
+type serviceItem string
+
+func (i serviceItem) FilterValue() string { return string(i) }
+
+func (i serviceItem) Title() string {
+	if i == "" {
+		return "All services"
+	}
+	return string(i)
+}
+
+func (i serviceItem) Description() string { return "" }
+
+// logFilter returns the storage filter for the current level and service
+func (m *Model) logFilter() storage.LogFilter {
+	return storage.LogFilter{
+		Levels:  levelFilters[m.levelIndex].Levels,
+		Service: m.service,
+		Trace:   m.trace,
+	}
+}
+
+// updateTitle shows the active filters in the list title
+func (m *Model) updateTitle() {
+	title := "🔍 Peep - Live Logs"
+	if m.levelIndex != 0 {
+		title += fmt.Sprintf(" [level: %s]", levelFilters[m.levelIndex].Name)
+	}
+	if m.service != "" {
+		title += fmt.Sprintf(" [service: %s]", m.service)
+	}
+	if m.trace != "" {
+		title += fmt.Sprintf(" [trace: %s]", m.trace)
+	}
+	m.list.Title = title
+}
+
+// toggleTrace filters the list to the logs carrying entry's trace ID, back in
+// the list if it was opened from the detail pane. With a trace filter already
+// on, it's turned off instead.
+func (m *Model) toggleTrace(entry storage.LogEntry) {
+	if m.trace != "" {
+		m.trace = ""
+	} else if m.trace = storage.TraceID(entry.Context); m.trace == "" {
+		m.notice = "This log has no trace_id"
+		return
+	}
+	m.detailMode = false
+	m.updateTitle()
+	m.reload()
+}
+
+// cycleLevel moves to the next level filter and reloads the logs
+func (m *Model) cycleLevel() {
+	m.levelIndex = (m.levelIndex + 1) % len(levelFilters)
+	m.updateTitle()
+	m.reload()
+}
+
+// openServicePicker lists the services that have logged, with the current one selected
+func (m *Model) openServicePicker() {
+	services, err := m.storage.GetServices(context.Background())
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	items := []list.Item{serviceItem("")}
+	selected := 0
+	for _, service := range services {
+		if service == m.service {
+			selected = len(items)
+		}
+		items = append(items, serviceItem(service))
+	}
+
+	delegate := newDelegate(m.config)
+	delegate.ShowDescription = false
+	delegate.SetSpacing(0)
+
+	m.picker = list.New(items, delegate, m.width, m.height-2)
+	m.picker.Title = "Filter by service"
+	m.picker.Styles.Title = titleStyle
+	m.picker.SetShowHelp(false)
+	m.picker.Select(selected)
+	m.pickingService = true
+}
+
+// updateServicePicker handles keys while the service picker is open
+func (m *Model) updateServicePicker(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	// While typing a filter, every key goes to the picker
+	if m.picker.FilterState() != list.Filtering {
+		switch msg.String() {
+		case "ctrl+c":
+			return m, tea.Quit
+		case "esc", "q":
+			if m.picker.FilterState() == list.Unfiltered {
+				m.pickingService = false
+				return m, nil
+			}
+		case "enter":
+			m.selectService()
+			return m, nil
+		}
+	}
+
+	var cmd tea.Cmd
+	m.picker, cmd = m.picker.Update(msg)
+	return m, cmd
+}
+
+// selectService filters by the service selected in the picker and closes it
+func (m *Model) selectService() {
+	if item, ok := m.picker.SelectedItem().(serviceItem); ok {
+		m.service = string(item)
+		m.updateTitle()
+		m.reload()
+	}
+	m.pickingService = false
+}
@@ -0,0 +1,190 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// jumpLayouts are the formats accepted by the jump-to-time prompt, in local time.
+// A bare time of day means today.
+var jumpLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+	"15:04:05",
+	"15:04",
+}
+
+// parseJumpTime reads a time typed into the jump prompt
+func parseJumpTime(value string, now time.Time) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range jumpLayouts {
+		t, err := time.ParseInLocation(layout, value, now.Location())
+		if err != nil {
+			continue
+		}
+		if !strings.Contains(layout, "-") {
+			t = time.Date(now.Year(), now.Month(), now.Day(), t.Hour(), t.Minute(), t.Second(), 0, now.Location())
+		}
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("can't read %q; use YYYY-MM-DD HH:MM[:SS] or HH:MM", value)
+}
+
+// loadMore pages in logs when the selection reaches either end of the list
+func (m *Model) loadMore() {
+	if m.list.FilterState() != list.Unfiltered || len(m.list.Items()) == 0 {
+		return
+	}
+	switch m.list.Index() {
+	case len(m.list.Items()) - 1:
+		m.loadOlder()
+	case 0:
+		m.loadNewer()
+	}
+}
+
+// loadOlder appends the page of logs before the oldest one in the list, dropping
+// the newest ones past maxListLogs. The tail pauses once logs are dropped.
+func (m *Model) loadOlder() {
+	if m.olderDone || len(m.logs) == 0 {
+		return
+	}
+
+	oldest := m.logs[len(m.logs)-1]
+	filter := m.logFilter()
+	filter.BeforeID = oldest.ID
+	older, err := m.storage.GetFilteredLogs(context.Background(), filter, initialLogs)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	m.olderDone = len(older) < initialLogs
+	if len(older) == 0 {
+		return
+	}
+
+	index := m.list.Index()
+	m.logs = append(m.logs, older...)
+	if drop := len(m.logs) - maxListLogs; drop > 0 {
+		m.logs = m.logs[drop:]
+		index -= drop
+		m.newerHidden = true
+		m.paused = true
+	}
+	m.setItems()
+	m.list.Select(index)
+}
+
+// loadNewer prepends the page of logs after the newest one in the list, when
+// newer ones were dropped or jumped past, dropping the oldest past maxListLogs
+func (m *Model) loadNewer() {
+	if !m.newerHidden || len(m.logs) == 0 {
+		return
+	}
+
+	filter := m.logFilter()
+	filter.NewerThanID = m.logs[0].ID
+	newer, err := m.storage.GetFilteredLogs(context.Background(), filter, initialLogs)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	if len(newer) < initialLogs {
+		// Caught up: the tail can pick up from here when unpaused
+		m.newerHidden = false
+		m.historyAt = time.Time{}
+		for _, log := range newer {
+			m.lastID = max(m.lastID, log.ID)
+		}
+		for _, log := range m.logs {
+			m.lastID = max(m.lastID, log.ID)
+		}
+	}
+	if len(newer) == 0 {
+		return
+	}
+
+	index := m.list.Index() + len(newer)
+	m.logs = append(newer, m.logs...)
+	if len(m.logs) > maxListLogs {
+		m.logs = m.logs[:maxListLogs]
+		m.olderDone = false
+	}
+	m.setItems()
+	m.list.Select(index)
+}
+
+// jumpTo shows the logs from t backwards and pauses the tail, so the list stays
+// put while browsing history
+func (m *Model) jumpTo(t time.Time) {
+	filter := m.logFilter()
+	filter.Before = t
+	logs, err := m.storage.GetFilteredLogs(context.Background(), filter, initialLogs)
+	if err != nil {
+		m.err = err
+		return
+	}
+
+	m.logs = logs
+	m.olderDone = len(logs) < initialLogs
+	m.newerHidden = true
+	m.historyAt = t
+	m.paused = true
+	m.setItems()
+	m.list.ResetSelected()
+	m.lastRefresh = time.Now()
+}
+
+// reload fetches the logs again after a filter change, staying at the time jumped to
+func (m *Model) reload() {
+	if m.historyAt.IsZero() {
+		m.refreshLogs()
+	} else {
+		m.jumpTo(m.historyAt)
+	}
+	m.list.ResetSelected()
+}
+
+// openJumpPrompt asks for a time to jump to
+func (m *Model) openJumpPrompt() tea.Cmd {
+	m.jumpMode = true
+	m.jumpErr = ""
+	m.jump.SetValue("")
+	m.jump.Focus()
+	return textinput.Blink
+}
+
+// updateJumpPrompt handles keys while the jump prompt is open
+func (m *Model) updateJumpPrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch msg.String() {
+	case "ctrl+c":
+		return m, tea.Quit
+	case "esc":
+		m.jumpMode = false
+		m.jump.Blur()
+		return m, nil
+	case "enter":
+		t, err := parseJumpTime(m.jump.Value(), time.Now())
+		if err != nil {
+			m.jumpErr = err.Error()
+			return m, nil
+		}
+		m.jumpMode = false
+		m.jump.Blur()
+		m.jumpTo(t)
+		return m, nil
+	}
+
+	var cmd tea.Cmd
+	m.jump, cmd = m.jump.Update(msg)
+	return m, cmd
+}
@@ -1,45 +1,42 @@
 package tui
 
 import (
+	"context"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
+	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/list"
+	"github.com/charmbracelet/bubbles/table"
 	"github.com/charmbracelet/bubbles/textinput"
+	"github.com/charmbracelet/bubbles/viewport"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/kylereynolds/peep/internal/storage"
 )
 
-// Styles
+// Styles, colored by Config.applyTheme
 var (
-	titleStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FFFDF5")).
-			Background(lipgloss.Color("#25A065")).
-			Padding(0, 1)
-
-	statusStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#888888")).
-			Italic(true)
-
-	helpStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#626262"))
-
-	errorStyle = lipgloss.NewStyle().
-			Foreground(lipgloss.Color("#FF0000"))
+	titleStyle  = lipgloss.NewStyle().Padding(0, 1)
+	statusStyle = lipgloss.NewStyle().Italic(true)
+	helpStyle   = lipgloss.NewStyle()
+	errorStyle  = lipgloss.NewStyle()
 
 	levelStyles = map[string]lipgloss.Style{
-		"error": lipgloss.NewStyle().Foreground(lipgloss.Color("#FF5555")),
-		"warn":  lipgloss.NewStyle().Foreground(lipgloss.Color("#FFB86C")),
-		"info":  lipgloss.NewStyle().Foreground(lipgloss.Color("#8BE9FD")),
-		"debug": lipgloss.NewStyle().Foreground(lipgloss.Color("#BD93F9")),
+		"error": lipgloss.NewStyle(),
+		"warn":  lipgloss.NewStyle(),
+		"info":  lipgloss.NewStyle(),
+		"debug": lipgloss.NewStyle(),
 	}
 )
 
 // LogItem represents a log entry in the list
 type LogItem struct {
-	Entry storage.LogEntry
+	Entry  storage.LogEntry
+	Match  *regexp.Regexp // Search to highlight, if any
+	Layout Layout
 }
 
 func (i LogItem) FilterValue() string {
@@ -52,52 +49,128 @@ func (i LogItem) Title() string {
 		levelStyle = lipgloss.NewStyle()
 	}
 
-	timestamp := i.Entry.Timestamp.Format("15:04:05")
-	level := levelStyle.Render(strings.ToUpper(i.Entry.Level))
-	service := fmt.Sprintf("[%s]", i.Entry.Service)
-
-	return fmt.Sprintf("%s %s %s %s", timestamp, level, service, i.Entry.Message)
+	fields := []string{
+		i.Entry.Timestamp.Format(i.Layout.timestampLayout()),
+		levelStyle.Render(strings.ToUpper(i.Entry.Level)),
+	}
+	if !i.Layout.HideService {
+		fields = append(fields, fmt.Sprintf("[%s]", highlight(i.Entry.Service, i.Match)))
+	}
+	if i.Layout.Message == "raw" {
+		fields = append(fields, highlight(i.Entry.RawLog, i.Match))
+	} else {
+		fields = append(fields, highlight(i.Entry.Message, i.Match))
+	}
+	return strings.Join(fields, " ")
 }
 
+// Description is the second line: the raw log, or the parsed message when the
+// raw log is already the title
 func (i LogItem) Description() string {
-	return i.Entry.RawLog
+	if i.Layout.Message == "raw" {
+		return highlight(i.Entry.Message, i.Match)
+	}
+	return highlight(i.Entry.RawLog, i.Match)
 }
 
 // Model represents the TUI application state
 type Model struct {
-	list         list.Model
-	search       textinput.Model
-	storage      *storage.Storage
-	searchMode   bool
-	lastRefresh  time.Time
-	refreshTimer *time.Timer
-	width        int
-	height       int
-	err          error
+	list           list.Model
+	search         textinput.Model
+	detail         viewport.Model // Scrollable detail pane for detailEntry
+	detailEntry    storage.LogEntry
+	storage        *storage.Storage
+	keys           keyMap
+	config         Config
+	itemHeight     int // Rows each log takes in the list, for finding the one clicked
+	searchMode     bool
+	detailMode     bool
+	picker         list.Model // Service picker, shown while pickingService
+	pickingService bool
+	levelIndex     int            // Index into levelFilters
+	service        string         // Service filter, or "" for all
+	trace          string         // Trace filter, or "" for all
+	searchRe       *regexp.Regexp // Applied search, highlighted in the logs
+	searchErr      string
+	matches        int // Logs in the list matching searchRe
+	logs           []storage.LogEntry
+	lastID         int64 // Newest log fetched, where the tail picks up
+	paused         bool  // Stop tailing, leaving the list as it is
+	olderDone      bool  // No logs older than the list's oldest
+	newerHidden    bool  // Logs newer than the list's newest were trimmed or skipped
+	historyAt      time.Time
+	jump           textinput.Model // Jump-to-time prompt, shown while jumpMode
+	jumpMode       bool
+	jumpErr        string
+	export         textinput.Model // Export path prompt, shown while exportMode
+	exportMode     bool
+	exportErr      string
+	notice         string          // Result of the last copy or export, until the next key
+	query          textinput.Model // SQL prompt, shown while queryMode
+	queryMode      bool
+	queryHistory   []string // Recent queries for the prompt, newest first
+	historyIndex   int      // Position in queryHistory, or -1 for the prompt's own text
+	lastQuery      string
+	results        table.Model // Last query's results, shown while showResults
+	showResults    bool
+	queryRunning   bool
+	queryErr       error
+	queryInfo      string
+	stats          *storage.Stats // Header stats, or nil until loaded
+	statsErr       error
+	lastRefresh    time.Time
+	refreshTimer   *time.Timer
+	width          int
+	height         int
+	err            error
 }
 
 // NewModel creates a new TUI model
-func NewModel(store *storage.Storage) *Model {
+func NewModel(store *storage.Storage, config Config) *Model {
+	config.applyTheme()
+
 	// Create search input
 	search := textinput.New()
 	search.Placeholder = "Search logs..."
 	search.Focus()
 
+	jump := textinput.New()
+	jump.Placeholder = "YYYY-MM-DD HH:MM[:SS] or HH:MM"
+
+	export := textinput.New()
+	export.Placeholder = "file.csv or file.json"
+
+	query := textinput.New()
+	query.Prompt = ""
+	query.Placeholder = "SELECT level, COUNT(*) FROM logs GROUP BY level"
+
 	// Create list
 	items := []list.Item{}
-	delegate := list.NewDefaultDelegate()
-	delegate.Styles.SelectedTitle = delegate.Styles.SelectedTitle.Foreground(lipgloss.Color("#F25D94"))
-	delegate.Styles.SelectedDesc = delegate.Styles.SelectedDesc.Foreground(lipgloss.Color("#AD58B4"))
-
+	delegate := newDelegate(config)
+	delegate.ShowDescription = !config.Layout.HideDescription
+	if !delegate.ShowDescription {
+		delegate.SetSpacing(0)
+	}
 	l := list.New(items, delegate, 0, 0)
 	l.Title = "🔍 Peep - Live Logs"
 	l.SetShowStatusBar(true)
 	l.SetFilteringEnabled(true)
 	l.Styles.Title = titleStyle
+	// Quitting is handled by Update, so a rebound quit key isn't shadowed by the list's own
+	l.KeyMap.Quit.SetEnabled(false)
+	l.KeyMap.ForceQuit.SetEnabled(false)
 
 	m := &Model{
 		list:        l,
 		search:      search,
+		jump:        jump,
+		export:      export,
+		query:       query,
+		results:     newResultsTable(config),
+		itemHeight:  delegate.Height() + delegate.Spacing(),
+		keys:        config.keys(),
+		config:      config,
+		detail:      viewport.New(0, 0),
 		storage:     store,
 		lastRefresh: time.Now(),
 	}
@@ -108,32 +181,88 @@ func NewModel(store *storage.Storage) *Model {
 	return m
 }
 
-// refreshLogs loads the latest logs from storage
+// List sizes: how many logs a reload or page fetches, and how many the list
+// keeps. The list is a window onto the logs; paging past maxListLogs in either
+// direction drops logs from the other end, so memory stays bounded however far
+// back you scroll.
+const (
+	initialLogs = 100
+	maxListLogs = 1000
+)
+
+// refreshLogs reloads the latest logs from storage, starting the tail over
 func (m *Model) refreshLogs() {
-	logs, err := m.storage.GetLogs(100) // Get last 100 logs
+	logs, err := m.storage.GetFilteredLogs(context.Background(), m.logFilter(), initialLogs)
 	if err != nil {
 		m.err = err
 		return
 	}
 
-	items := make([]list.Item, len(logs))
-	for i, log := range logs {
-		items[i] = LogItem{Entry: log}
+	m.logs = logs
+	m.olderDone = len(logs) < initialLogs
+	m.newerHidden = false
+	m.historyAt = time.Time{}
+	m.lastID = 0
+	for _, log := range logs {
+		m.lastID = max(m.lastID, log.ID)
 	}
 
-	m.list.SetItems(items)
+	m.setItems()
 	m.lastRefresh = time.Now()
 }
 
+// tailLogs fetches only logs stored since the last one seen and adds them to the
+// top of the list. The selection stays on the same log unless it was on the newest.
+func (m *Model) tailLogs() {
+	filter := m.logFilter()
+	filter.AfterID = m.lastID
+	newer, err := m.storage.GetFilteredLogs(context.Background(), filter, maxListLogs)
+	if err != nil {
+		m.err = err
+		return
+	}
+	m.lastRefresh = time.Now()
+	if len(newer) == 0 {
+		return
+	}
+
+	for _, log := range newer {
+		m.lastID = max(m.lastID, log.ID)
+	}
+	m.logs = append(newer, m.logs...)
+	if len(m.logs) > maxListLogs {
+		m.logs = m.logs[:maxListLogs]
+		m.olderDone = false
+	}
+
+	index := m.list.Index()
+	shown := len(m.list.Items())
+	m.setItems()
+	if index > 0 && m.list.FilterState() == list.Unfiltered {
+		m.list.Select(min(index+len(m.list.Items())-shown, len(m.list.Items())-1))
+	}
+}
+
+// setItems fills the list from the fetched logs, highlighting the search
+func (m *Model) setItems() {
+	items := make([]list.Item, len(m.logs))
+	for i, log := range m.logs {
+		items[i] = LogItem{Entry: log, Match: m.searchRe, Layout: m.config.Layout}
+	}
+	m.list.SetItems(items)
+	m.matches = m.matchCount()
+}
+
 // Init initializes the model
 func (m *Model) Init() tea.Cmd {
 	return tea.Batch(
 		textinput.Blink,
 		m.tickRefresh(),
+		m.loadStats(0),
 	)
 }
 
-// tickRefresh returns a command that refreshes logs every 2 seconds
+// tickRefresh returns a command that checks for new logs every 2 seconds
 func (m *Model) tickRefresh() tea.Cmd {
 	return tea.Tick(time.Second*2, func(t time.Time) tea.Msg {
 		return refreshMsg{}
@@ -151,65 +280,191 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		m.width = msg.Width
 		m.height = msg.Height
 		m.list.SetWidth(msg.Width)
-		m.list.SetHeight(msg.Height - 4) // Leave space for search and help
+		m.list.SetHeight(msg.Height - 5) // Leave space for the header, search, and help
+		m.detail.Width = m.detailWidth()
+		m.detail.Height = msg.Height - 2 // Leave space for help
+		if m.detailMode {
+			m.showDetail(m.detailEntry)
+		}
+		if m.pickingService {
+			m.picker.SetSize(msg.Width, msg.Height-2)
+		}
+		m.results.SetWidth(msg.Width)
+		m.results.SetHeight(msg.Height - 7) // Leave space for the title, info, prompt, and help
+
+	case queryResultMsg:
+		m.showQueryResult(msg)
+		return m, nil
+
+	case statsMsg:
+		if msg.err != nil {
+			m.statsErr = msg.err
+		} else {
+			m.stats, m.statsErr = &msg.stats, nil
+		}
+		return m, m.loadStats(statsInterval)
 
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
-			return m, tea.Quit
+	case tea.MouseMsg:
+		return m.updateMouse(msg)
 
-		case "/":
-			// Toggle search mode
-			m.searchMode = !m.searchMode
-			if m.searchMode {
-				m.search.Focus()
-				return m, textinput.Blink
-			} else {
-				m.search.Blur()
-				m.list.SetFilteringEnabled(true)
-			}
+	case noticeMsg:
+		m.notice = string(msg)
+		return m, nil
 
-		case "r":
-			// Manual refresh
-			if !m.searchMode {
-				m.refreshLogs()
-				return m, m.tickRefresh()
+	case tea.KeyMsg:
+		m.notice = ""
+		if m.queryMode || m.showResults {
+			return m.updateQuery(msg)
+		}
+		if m.jumpMode {
+			return m.updateJumpPrompt(msg)
+		}
+		if m.exportMode {
+			return m.updateExportPrompt(msg)
+		}
+		if m.pickingService {
+			return m.updateServicePicker(msg)
+		}
+		if m.detailMode {
+			switch {
+			case key.Matches(msg, m.keys.Quit):
+				return m, tea.Quit
+			case key.Matches(msg, m.keys.Copy):
+				return m, m.copySelected(false)
+			case key.Matches(msg, m.keys.CopyRaw):
+				return m, m.copySelected(true)
+			case key.Matches(msg, m.keys.Trace):
+				m.toggleTrace(m.detailEntry)
+				return m, nil
+			case msg.String() == "esc", msg.String() == "enter":
+				m.detailMode = false
+				return m, nil
 			}
+			var cmd tea.Cmd
+			m.detail, cmd = m.detail.Update(msg)
+			return m, cmd
+		}
 
-		case "esc":
-			if m.searchMode {
+		if m.searchMode {
+			switch msg.String() {
+			case "ctrl+c":
+				return m, tea.Quit
+			case "esc":
 				m.searchMode = false
 				m.search.Blur()
-				m.search.SetValue("")
-				m.list.SetFilteringEnabled(true)
-			}
-
-		case "enter":
-			if m.searchMode {
-				// Apply search filter
-				searchTerm := m.search.Value()
-				if searchTerm != "" {
-					m.list.SetFilteringEnabled(false)
-					// Filter items based on search term
-					allItems := m.list.Items()
-					var filteredItems []list.Item
-					for _, item := range allItems {
-						if logItem, ok := item.(LogItem); ok {
-							if strings.Contains(strings.ToLower(logItem.FilterValue()), strings.ToLower(searchTerm)) {
-								filteredItems = append(filteredItems, item)
-							}
-						}
-					}
-					m.list.SetItems(filteredItems)
+				m.clearSearch()
+				return m, nil
+			case "enter":
+				// Highlight the search, in new logs too
+				if err := m.applySearch(m.search.Value()); err != nil {
+					m.searchErr = err.Error()
+					return m, nil
 				}
 				m.searchMode = false
 				m.search.Blur()
+				return m, nil
+			}
+			break
+		}
+
+		// While the list's own filter is being typed, keys go to it
+		if m.list.FilterState() == list.Filtering {
+			break
+		}
+
+		switch {
+		case key.Matches(msg, m.keys.Quit):
+			return m, tea.Quit
+
+		case key.Matches(msg, m.keys.Search):
+			m.searchMode = true
+			m.searchErr = ""
+			m.search.Focus()
+			return m, textinput.Blink
+
+		case key.Matches(msg, m.keys.NextMatch):
+			m.nextMatch(m.list.Index(), 1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.PrevMatch):
+			m.nextMatch(m.list.Index(), -1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.NextIssue):
+			m.nextIssue(1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.PrevIssue):
+			m.nextIssue(-1)
+			return m, nil
+
+		case key.Matches(msg, m.keys.Refresh):
+			m.refreshLogs()
+			return m, m.tickRefresh()
+
+		case key.Matches(msg, m.keys.Follow):
+			m.paused = !m.paused
+			if !m.paused {
+				if !m.newerHidden {
+					m.tailLogs()
+				} else {
+					// Leave history for the newest logs
+					m.refreshLogs()
+					m.list.ResetSelected()
+				}
+			}
+			return m, nil
+
+		case key.Matches(msg, m.keys.Query):
+			return m, m.openQueryPrompt()
+
+		case key.Matches(msg, m.keys.Jump):
+			return m, m.openJumpPrompt()
+
+		case key.Matches(msg, m.keys.Level):
+			m.cycleLevel()
+			return m, nil
+
+		case key.Matches(msg, m.keys.Service):
+			m.openServicePicker()
+			return m, nil
+
+		case key.Matches(msg, m.keys.Trace):
+			if item, ok := m.list.SelectedItem().(LogItem); ok {
+				m.toggleTrace(item.Entry)
+			} else if m.trace != "" {
+				m.toggleTrace(storage.LogEntry{})
+			}
+			return m, nil
+
+		case key.Matches(msg, m.keys.Copy):
+			return m, m.copySelected(false)
+
+		case key.Matches(msg, m.keys.CopyRaw):
+			return m, m.copySelected(true)
+
+		case key.Matches(msg, m.keys.Export):
+			return m, m.openExportPrompt()
+
+		case msg.String() == "esc" && m.searchRe != nil && m.list.FilterState() == list.Unfiltered:
+			m.clearSearch()
+			return m, nil
+
+		case msg.String() == "enter":
+			// Open the selected log in the detail pane
+			if item, ok := m.list.SelectedItem().(LogItem); ok {
+				m.detailMode = true
+				m.showDetail(item.Entry)
+				m.detail.GotoTop()
+				return m, nil
 			}
 		}
 
 	case refreshMsg:
-		// Auto-refresh logs
-		m.refreshLogs()
+		// Pick up new logs unless paused
+		if !m.paused {
+			m.tailLogs()
+		}
 		return m, m.tickRefresh()
 	}
 
@@ -222,47 +477,123 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		var cmd tea.Cmd
 		m.list, cmd = m.list.Update(msg)
 		cmds = append(cmds, cmd)
+
+		if _, ok := msg.(tea.KeyMsg); ok {
+			m.loadMore()
+		}
 	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// showDetail renders a log into the detail pane. It keeps showing that log while
+// the list refreshes underneath.
+func (m *Model) showDetail(entry storage.LogEntry) {
+	m.detailEntry = entry
+	m.detail.SetContent(renderDetail(entry, m.detailWidth(), m.searchRe))
+}
+
+// detailWidth is the detail pane's width: the configured one, if it fits
+func (m *Model) detailWidth() int {
+	if w := m.config.Layout.DetailWidth; w > 0 {
+		return min(w, m.width)
+	}
+	return m.width
+}
+
 // View renders the TUI
 func (m *Model) View() string {
 	if m.err != nil {
 		return errorStyle.Render(fmt.Sprintf("Error: %v", m.err))
 	}
 
+	if m.queryMode || m.showResults {
+		return m.queryView()
+	}
+
+	if m.pickingService {
+		help := "↑/↓ to move, '/' to find, 'enter' to filter by service, 'esc' to cancel"
+		return m.picker.View() + "\n" + helpStyle.Render(help)
+	}
+
+	if m.detailMode {
+		help := fmt.Sprintf("Log #%d | ↑/↓ to scroll, '%s'/'%s' to copy the line/JSON, 'esc' or 'enter' to go back, '%s' to quit",
+			m.detailEntry.ID, keyName(m.keys.Copy), keyName(m.keys.CopyRaw), keyName(m.keys.Quit))
+		if storage.TraceID(m.detailEntry.Context) != "" {
+			help = fmt.Sprintf("'%s' for all logs in this trace | ", keyName(m.keys.Trace)) + help
+		}
+		if m.notice != "" {
+			help = m.notice + " | " + help
+		}
+		return m.detail.View() + "\n" + helpStyle.Render(help)
+	}
+
 	var content strings.Builder
 
-	// Main list view
+	// Stats header and main list view
+	content.WriteString(m.statsView() + "\n")
 	content.WriteString(m.list.View())
 	content.WriteString("\n")
 
-	// Search bar (if in search mode)
+	// Search bar or jump prompt, if open
 	if m.searchMode {
-		content.WriteString("Search: " + m.search.View())
+		content.WriteString("Search (regex): " + m.search.View())
+		if m.searchErr != "" {
+			content.WriteString(" " + errorStyle.Render(m.searchErr))
+		}
+	} else if m.jumpMode {
+		content.WriteString("Jump to: " + m.jump.View())
+		if m.jumpErr != "" {
+			content.WriteString(" " + errorStyle.Render(m.jumpErr))
+		}
+	} else if m.exportMode {
+		content.WriteString("Export to: " + m.export.View())
+		if m.exportErr != "" {
+			content.WriteString(" " + errorStyle.Render(m.exportErr))
+		}
 	} else {
 		// Status bar
-		status := fmt.Sprintf("Last refresh: %s | %d logs",
+		follow := "● Following"
+		if !m.historyAt.IsZero() {
+			follow = "⏪ From " + m.historyAt.Format("2006-01-02 15:04:05")
+		} else if m.paused {
+			follow = "⏸ Paused"
+		}
+		status := fmt.Sprintf("%s | Last refresh: %s | %d logs",
+			follow,
 			m.lastRefresh.Format("15:04:05"),
 			len(m.list.Items()))
+		if m.searchRe != nil {
+			status += fmt.Sprintf(" | /%s/ %d matches", m.search.Value(), m.matches)
+		}
+		if m.notice != "" {
+			status += " | " + m.notice
+		}
 		content.WriteString(statusStyle.Render(status))
 	}
 	content.WriteString("\n")
 
 	// Help text
-	help := "Press 'q' to quit, '/' to search, 'r' to refresh, 'esc' to cancel search"
+	k := m.keys
+	help := fmt.Sprintf("Press '%s' to quit, 'enter' for details, '%s' to cycle level, '%s' to pick service, '%s' to filter by the log's trace, '%s' to follow/pause, '%s' to jump to a time, '%s' for SQL, '%s' to search, '%s'/'%s' for next/previous match, '%s'/'%s' for next/previous error or warning, '%s'/'%s' to copy the line/JSON, '%s' to export, '%s' to refresh, 'esc' to clear search",
+		keyName(k.Quit), keyName(k.Level), keyName(k.Service), keyName(k.Trace), keyName(k.Follow), keyName(k.Jump), keyName(k.Query),
+		keyName(k.Search), keyName(k.NextMatch), keyName(k.PrevMatch), keyName(k.NextIssue), keyName(k.PrevIssue),
+		keyName(k.Copy), keyName(k.CopyRaw),
+		keyName(k.Export), keyName(k.Refresh))
 	content.WriteString(helpStyle.Render(help))
 
 	return content.String()
 }
 
 // Start runs the TUI application
-func Start(store *storage.Storage) error {
-	model := NewModel(store)
+func Start(store *storage.Storage, config Config) error {
+	model := NewModel(store, config)
 
-	p := tea.NewProgram(model, tea.WithAltScreen())
+	options := []tea.ProgramOption{tea.WithAltScreen()}
+	if !config.NoMouse {
+		options = append(options, tea.WithMouseCellMotion())
+	}
+	p := tea.NewProgram(model, options...)
 
 	if _, err := p.Run(); err != nil {
 		return fmt.Errorf("error running TUI: %w", err)
@@ -0,0 +1,99 @@
+package tui
+
+import (
+	"github.com/charmbracelet/bubbles/list"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// updateMouse handles the scroll wheel and clicks: the wheel scrolls whatever is
+// shown, and clicking a log opens it (or a service, picks it)
+func (m *Model) updateMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	wheel := 0
+	switch msg.Type {
+	case tea.MouseWheelUp:
+		wheel = -1
+	case tea.MouseWheelDown:
+		wheel = 1
+	case tea.MouseLeft:
+	default:
+		return m, nil
+	}
+
+	switch {
+	case m.queryMode || m.jumpMode || m.exportMode || m.searchMode:
+		// Prompts are keyboard only
+
+	case m.showResults:
+		if wheel < 0 {
+			m.results.MoveUp(1)
+		} else if wheel > 0 {
+			m.results.MoveDown(1)
+		}
+
+	case m.detailMode:
+		var cmd tea.Cmd
+		m.detail, cmd = m.detail.Update(msg)
+		return m, cmd
+
+	case m.pickingService:
+		if wheel != 0 {
+			scrollList(&m.picker, wheel)
+		} else if i, ok := listIndexAt(m.picker, msg.Y, 1); ok {
+			m.picker.Select(i)
+			m.selectService()
+		}
+
+	default:
+		if wheel != 0 {
+			scrollList(&m.list, wheel)
+			m.loadMore()
+		} else if i, ok := listIndexAt(m.list, msg.Y-1, m.itemHeight); ok { // Below the stats header
+			m.list.Select(i)
+			if item, ok := m.list.SelectedItem().(LogItem); ok {
+				m.detailMode = true
+				m.showDetail(item.Entry)
+				m.detail.GotoTop()
+			}
+		}
+	}
+	return m, nil
+}
+
+// scrollList moves a list's selection up (step < 0) or down
+func scrollList(l *list.Model, step int) {
+	if l.FilterState() == list.Filtering {
+		return
+	}
+	if step < 0 {
+		l.CursorUp()
+	} else {
+		l.CursorDown()
+	}
+}
+
+// listIndexAt finds the item drawn at row y of a list, counting from the list's
+// top line, where each item takes itemHeight rows
+func listIndexAt(l list.Model, y, itemHeight int) (int, bool) {
+	if l.FilterState() == list.Filtering || itemHeight <= 0 {
+		return 0, false
+	}
+
+	// Items start below the title and status bars
+	top := 1 + l.Styles.TitleBar.GetVerticalFrameSize()
+	if l.ShowStatusBar() {
+		top += 1 + l.Styles.StatusBar.GetVerticalFrameSize()
+	}
+	if y < top {
+		return 0, false
+	}
+
+	row := (y - top) / itemHeight
+	if row >= l.Paginator.PerPage {
+		return 0, false
+	}
+	index := l.Paginator.Page*l.Paginator.PerPage + row
+	if index >= len(l.VisibleItems()) {
+		return 0, false
+	}
+	return index, true
+}
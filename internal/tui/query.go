@@ -0,0 +1,255 @@
+package tui
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/table"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Query mode runs SQL typed after ':' and shows the results in a table, with the
+// same read-only guard as the web console
+const (
+	queryTimeout      = 30 * time.Second
+	maxQueryRows      = 1000
+	maxColumnWidth    = 40
+	queryHistoryLimit = 50
+)
+
+// queryResultMsg carries a finished query back to Update
+type queryResultMsg struct {
+	query    string
+	columns  []string
+	rows     [][]string
+	duration time.Duration
+	err      error
+}
+
+// openQueryPrompt starts typing a query, beginning with the last one run
+func (m *Model) openQueryPrompt() tea.Cmd {
+	m.queryMode = true
+	m.query.SetValue(m.lastQuery)
+	m.query.CursorEnd()
+	m.query.Focus()
+
+	m.queryHistory = nil
+	m.historyIndex = -1
+	if history, err := m.storage.RecentQueries(queryHistoryLimit); err == nil {
+		for _, entry := range history {
+			m.queryHistory = append(m.queryHistory, entry.Query)
+		}
+	}
+	return textinput.Blink
+}
+
+// updateQuery handles keys in query mode: the prompt, or the results table
+func (m *Model) updateQuery(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	if msg.String() == "ctrl+c" {
+		return m, tea.Quit
+	}
+
+	if !m.queryMode {
+		switch {
+		case key.Matches(msg, m.keys.Query):
+			return m, m.openQueryPrompt()
+		case msg.String() == "esc" || key.Matches(msg, m.keys.Quit):
+			m.showResults = false
+			return m, nil
+		}
+		var cmd tea.Cmd
+		m.results, cmd = m.results.Update(msg)
+		return m, cmd
+	}
+
+	switch msg.String() {
+	case "esc":
+		m.queryMode = false
+		m.query.Blur()
+		return m, nil
+	case "up", "down":
+		// Step through earlier queries, newest first
+		if msg.String() == "up" && m.historyIndex < len(m.queryHistory)-1 {
+			m.historyIndex++
+		} else if msg.String() == "down" && m.historyIndex >= 0 {
+			m.historyIndex--
+		}
+		if m.historyIndex >= 0 {
+			m.query.SetValue(m.queryHistory[m.historyIndex])
+		} else {
+			m.query.SetValue(m.lastQuery)
+		}
+		m.query.CursorEnd()
+		return m, nil
+	case "enter":
+		query := strings.TrimSpace(m.query.Value())
+		if query == "" {
+			return m, nil
+		}
+		m.queryMode = false
+		m.query.Blur()
+		m.lastQuery = query
+		m.queryRunning = true
+		m.showResults = true
+		return m, m.runQuery(query)
+	}
+
+	var cmd tea.Cmd
+	m.query, cmd = m.query.Update(msg)
+	return m, cmd
+}
+
+// runQuery runs the query in the background and records it in the query history
+func (m *Model) runQuery(query string) tea.Cmd {
+	store := m.storage
+	return func() tea.Msg {
+		started := time.Now()
+		columns, rows, err := execQuery(store, query)
+		result := queryResultMsg{query: query, columns: columns, rows: rows, duration: time.Since(started), err: err}
+
+		entry := storage.QueryHistoryEntry{Query: query, Duration: result.duration, RowCount: len(rows), User: "tui"}
+		if err != nil {
+			entry.Error = err.Error()
+		}
+		store.RecordQuery(entry)
+		return result
+	}
+}
+
+// execQuery runs a read-only query and formats its results for display
+func execQuery(store *storage.Storage, query string) ([]string, [][]string, error) {
+	query, err := storage.ReadStatement(query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), queryTimeout)
+	defer cancel()
+
+	rows, release, err := store.QueryReadOnly(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var results [][]string
+	for rows.Next() && len(results) < maxQueryRows {
+		values := make([]interface{}, len(columns))
+		ptrs := make([]interface{}, len(columns))
+		for i := range values {
+			ptrs[i] = &values[i]
+		}
+		if err := rows.Scan(ptrs...); err != nil {
+			return nil, nil, err
+		}
+
+		row := make([]string, len(columns))
+		for i, value := range values {
+			switch v := value.(type) {
+			case nil:
+				row[i] = "NULL"
+			case []byte:
+				row[i] = string(v)
+			case time.Time:
+				row[i] = v.Format("2006-01-02 15:04:05")
+			default:
+				row[i] = fmt.Sprintf("%v", v)
+			}
+			row[i] = strings.Join(strings.Fields(row[i]), " ") // Keep each row on one line
+		}
+		results = append(results, row)
+	}
+	if err := rows.Err(); err != nil {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, nil, fmt.Errorf("query timed out after %s", queryTimeout)
+		}
+		return nil, nil, err
+	}
+	return columns, results, nil
+}
+
+// showQueryResult fills the results table, sizing columns to their contents
+func (m *Model) showQueryResult(msg queryResultMsg) {
+	m.queryRunning = false
+	m.queryErr = msg.err
+	if msg.err != nil {
+		return
+	}
+
+	columns := make([]table.Column, len(msg.columns))
+	for i, name := range msg.columns {
+		width := lipgloss.Width(name)
+		for _, row := range msg.rows {
+			width = max(width, lipgloss.Width(row[i]))
+		}
+		columns[i] = table.Column{Title: name, Width: min(width, maxColumnWidth)}
+	}
+	rows := make([]table.Row, len(msg.rows))
+	for i, row := range msg.rows {
+		rows[i] = row
+	}
+
+	// Clear the old rows first: changing the columns re-renders them, and a row
+	// with fewer cells than the new columns would panic
+	m.results.SetRows(nil)
+	m.results.SetColumns(columns)
+	m.results.SetRows(rows)
+	m.results.GotoTop()
+
+	m.queryInfo = fmt.Sprintf("%d rows in %s", len(rows), msg.duration.Round(time.Millisecond))
+	if len(rows) == maxQueryRows {
+		m.queryInfo += fmt.Sprintf(" (showing the first %d; add a LIMIT to see others)", maxQueryRows)
+	}
+}
+
+// newResultsTable creates the empty table query results are shown in
+func newResultsTable(config Config) table.Model {
+	styles := table.DefaultStyles()
+	styles.Header = styles.Header.
+		BorderStyle(lipgloss.NormalBorder()).
+		BorderForeground(config.color("table_border")).
+		BorderBottom(true).
+		Bold(true)
+	styles.Selected = styles.Selected.
+		Foreground(config.color("selected_title")).
+		Bold(false)
+
+	return table.New(table.WithFocused(true), table.WithStyles(styles))
+}
+
+// queryView renders query mode
+func (m *Model) queryView() string {
+	var content strings.Builder
+	content.WriteString(titleStyle.Render("🔍 Peep - SQL Query") + "\n\n")
+
+	switch {
+	case m.queryRunning:
+		content.WriteString(statusStyle.Render("Running...") + "\n")
+	case m.queryErr != nil:
+		content.WriteString(errorStyle.Render("Error: "+m.queryErr.Error()) + "\n")
+	case m.showResults:
+		content.WriteString(m.results.View() + "\n")
+		content.WriteString(statusStyle.Render(m.queryInfo) + "\n")
+	}
+
+	if m.queryMode {
+		content.WriteString(":" + m.query.View() + "\n")
+		content.WriteString(helpStyle.Render("'enter' to run, ↑/↓ for history, 'esc' to cancel"))
+	} else {
+		content.WriteString(helpStyle.Render(fmt.Sprintf("↑/↓ to scroll, '%s' for a new query, 'esc' to go back to logs", keyName(m.keys.Query))))
+	}
+	return content.String()
+}
@@ -0,0 +1,112 @@
+package tui
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Searching works like less: '/' takes a regex (case-insensitive), matches are
+// highlighted in the list and detail pane, and n/N move between matching logs.
+
+var (
+	matchStyle   = lipgloss.NewStyle() // Colored by Config.applyTheme
+	plainMatches bool                  // Mark matches with « » where there's no color
+)
+
+// highlight marks every match of re in text
+func highlight(text string, re *regexp.Regexp) string {
+	if re == nil || text == "" {
+		return text
+	}
+	return re.ReplaceAllStringFunc(text, func(match string) string {
+		if plainMatches {
+			return "«" + match + "»"
+		}
+		return matchStyle.Render(match)
+	})
+}
+
+// compileSearch reads a search term as a case-insensitive regex
+func compileSearch(term string) (*regexp.Regexp, error) {
+	if _, err := regexp.Compile(term); err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return regexp.MustCompile("(?i)" + term), nil
+}
+
+// matchesSearch reports whether any of a log's text matches re
+func matchesSearch(entry storage.LogEntry, re *regexp.Regexp) bool {
+	for _, text := range []string{entry.Message, entry.Service, entry.Level, entry.Context, entry.RawLog} {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// applySearch highlights term's matches and selects the first matching log at
+// or below the selection. An empty term clears the search.
+func (m *Model) applySearch(term string) error {
+	if term == "" {
+		m.clearSearch()
+		return nil
+	}
+
+	re, err := compileSearch(term)
+	if err != nil {
+		return err
+	}
+	m.searchRe = re
+	m.setItems()
+	m.nextMatch(m.list.Index()-1, 1)
+	return nil
+}
+
+// clearSearch removes the highlights
+func (m *Model) clearSearch() {
+	m.searchRe = nil
+	m.search.SetValue("")
+	m.setItems()
+}
+
+// nextMatch selects the next log matching the search after index, stepping by
+// step (1 down, -1 up) and wrapping around
+func (m *Model) nextMatch(index, step int) {
+	if m.searchRe == nil {
+		return
+	}
+	m.selectNext(index, step, func(entry storage.LogEntry) bool {
+		return matchesSearch(entry, m.searchRe)
+	})
+}
+
+// selectNext selects the next log after index that match accepts, stepping by
+// step and wrapping around. It reports whether there was one.
+func (m *Model) selectNext(index, step int, match func(storage.LogEntry) bool) bool {
+	items := m.list.Items()
+	for n := 1; n <= len(items); n++ {
+		i := ((index+step*n)%len(items) + len(items)) % len(items)
+		if item, ok := items[i].(LogItem); ok && match(item.Entry) {
+			m.list.Select(i)
+			return true
+		}
+	}
+	return false
+}
+
+// matchCount counts the logs in the list matching the search
+func (m *Model) matchCount() int {
+	if m.searchRe == nil {
+		return 0
+	}
+	count := 0
+	for _, item := range m.list.Items() {
+		if logItem, ok := item.(LogItem); ok && matchesSearch(logItem.Entry, m.searchRe) {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,57 @@
+package tui
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/kylereynolds/peep/internal/format"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// statsInterval is how often the header's stats are refreshed. Counting is a
+// table scan, so it's done less often than the tail.
+const statsInterval = 10 * time.Second
+
+// statsMsg carries fresh stats for the header
+type statsMsg struct {
+	stats storage.Stats
+	err   error
+}
+
+// loadStats fetches the stats in the background after delay
+func (m *Model) loadStats(delay time.Duration) tea.Cmd {
+	store := m.storage
+	fetch := func(time.Time) tea.Msg {
+		stats, err := store.GetStats(context.Background())
+		return statsMsg{stats: stats, err: err}
+	}
+	if delay == 0 {
+		return func() tea.Msg { return fetch(time.Now()) }
+	}
+	return tea.Tick(delay, fetch)
+}
+
+// statsView is the one-line header above the list
+func (m *Model) statsView() string {
+	if m.statsErr != nil {
+		return errorStyle.Render("Stats unavailable: " + m.statsErr.Error())
+	}
+	if m.stats == nil {
+		return statusStyle.Render("Loading stats...")
+	}
+
+	s := m.stats
+	errors := levelStyles["error"].Render(fmt.Sprintf("%s errors", format.Count(s.Errors)))
+	warnings := levelStyles["warn"].Render(fmt.Sprintf("%s warnings", format.Count(s.Warnings)))
+	header := fmt.Sprintf("%s logs | %s, %s in the last hour | %s | %.1f logs/min",
+		format.Count(s.TotalLogs), errors, warnings, format.Bytes(s.SizeBytes), s.IngestRate)
+
+	// Cut it short rather than wrap, which would push the list down
+	if m.width > 0 {
+		header = lipgloss.NewStyle().MaxWidth(m.width).Render(header)
+	}
+	return header
+}
@@ -0,0 +1,118 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+)
+
+// alertHistoryData backs the alert history page and its results table
+type alertHistoryData struct {
+	Instances []*alerts.AlertInstance
+	Rules     []*alerts.AlertRule
+	RuleID    int64
+	Since     string
+	State     string
+}
+
+// alertHistoryFilter reads the rule, since, and state filters from the query string.
+// History defaults to the last 7 days.
+func (s *Server) alertHistoryFilter(r *http.Request) (alerts.InstanceFilter, *alertHistoryData) {
+	data := &alertHistoryData{
+		Since: r.URL.Query().Get("since"),
+		State: r.URL.Query().Get("state"),
+	}
+	filter := alerts.InstanceFilter{State: data.State}
+
+	if id, err := strconv.ParseInt(r.URL.Query().Get("rule"), 10, 64); err == nil {
+		data.RuleID = id
+		filter.RuleID = id
+	}
+
+	if data.Since == "" {
+		data.Since = "168h"
+	}
+	if data.Since != "all" {
+		if since, err := time.ParseDuration(data.Since); err == nil {
+			filter.Since = time.Now().Add(-since)
+		}
+	}
+
+	return filter, data
+}
+
+// handleAlertHistory shows fired alerts with filters and ack/resolve actions
+func (s *Server) handleAlertHistory(w http.ResponseWriter, r *http.Request) {
+	filter, data := s.alertHistoryFilter(r)
+
+	instances, err := s.engine.ListAlertInstances(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data.Instances = instances
+	data.Rules = s.engine.GetRules()
+
+	s.renderPage(w, r, "alert_history", PageData{Title: "Alert History - Peep", Active: "alerts", Content: data})
+}
+
+// handleAlertHistorySearch returns just the results table for HTMX filter changes
+func (s *Server) handleAlertHistorySearch(w http.ResponseWriter, r *http.Request) {
+	filter, data := s.alertHistoryFilter(r)
+
+	instances, err := s.engine.ListAlertInstances(filter)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data.Instances = instances
+
+	s.renderPartial(w, "alertHistoryTable", data)
+}
+
+// handleAlertInstanceAction routes POST /alerts/instances/{id}/ack and /alerts/instances/{id}/resolve
+// and returns the updated table row
+func (s *Server) handleAlertInstanceAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/alerts/instances/"), "/"), "/")
+	if len(parts) != 2 {
+		http.NotFound(w, r)
+		return
+	}
+
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	switch parts[1] {
+	case "ack":
+		err = s.engine.AcknowledgeAlert(id, currentUser(r))
+	case "resolve":
+		err = s.engine.ResolveAlert(id)
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, fmt.Sprintf("Failed to update alert: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	instance, err := s.engine.GetAlertInstance(id)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.renderPartial(w, "alertInstanceRow", instance)
+}
@@ -0,0 +1,315 @@
+package web
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/tokens"
+)
+
+const sessionCookieName = "peep_session"
+
+// AuthConfig protects the web UI. Set Username and Password for a login form,
+// or Token for a single shared secret. With neither set, the UI is open.
+// Both grant the admin role; ViewerUsername and ViewerPassword add a login
+// that can only browse logs and dashboards.
+type AuthConfig struct {
+	Username   string
+	Password   string
+	Token      string
+	SessionTTL time.Duration // How long a login lasts (default: 24h)
+
+	ViewerUsername string
+	ViewerPassword string
+
+	// Tokens, if set, also accepts scoped API tokens as Bearer tokens
+	Tokens *tokens.Store
+}
+
+var (
+	errUnauthorized = errors.New("unauthorized")
+	errForbidden    = errors.New("your role does not allow this request")
+)
+
+// Enabled reports whether any credentials are configured
+func (c AuthConfig) Enabled() bool {
+	return c.Password != "" || c.Token != "" || c.ViewerPassword != ""
+}
+
+// account is a username and password that logs in with a role
+type account struct {
+	username string
+	password string
+	role     Role
+}
+
+// accounts returns the configured password logins
+func (c AuthConfig) accounts() []account {
+	var accounts []account
+	if c.Password != "" {
+		accounts = append(accounts, account{c.Username, c.Password, RoleAdmin})
+	}
+	if c.ViewerPassword != "" {
+		accounts = append(accounts, account{c.ViewerUsername, c.ViewerPassword, RoleViewer})
+	}
+	return accounts
+}
+
+// sessionStore tracks logged-in browsers. Sessions live in memory, so restarting
+// the server signs everyone out.
+type sessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]session // Keyed by session ID
+}
+
+type session struct {
+	identity
+	expiry time.Time
+}
+
+// userContextKey carries the authenticated identity through request contexts
+type userContextKey struct{}
+
+func newSessionStore() *sessionStore {
+	return &sessionStore{sessions: make(map[string]session)}
+}
+
+func (s *sessionStore) create(id identity, ttl time.Duration) (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	sessionID := hex.EncodeToString(buf)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Drop expired sessions while we hold the lock
+	now := time.Now()
+	for existing, sess := range s.sessions {
+		if now.After(sess.expiry) {
+			delete(s.sessions, existing)
+		}
+	}
+	s.sessions[sessionID] = session{identity: id, expiry: now.Add(ttl)}
+
+	return sessionID, nil
+}
+
+// lookup returns who a live session belongs to
+func (s *sessionStore) lookup(id string) (identity, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, exists := s.sessions[id]
+	if !exists {
+		return identity{}, false
+	}
+	if time.Now().After(sess.expiry) {
+		delete(s.sessions, id)
+		return identity{}, false
+	}
+	return sess.identity, true
+}
+
+func (s *sessionStore) delete(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+// SetAuth enables authentication for the web UI
+func (s *Server) SetAuth(config AuthConfig) {
+	if config.SessionTTL == 0 {
+		config.SessionTTL = 24 * time.Hour
+	}
+	s.auth = config
+	s.sessions = newSessionStore()
+}
+
+// requireAuth wraps a handler so only logged-in users, or requests bearing the
+// shared token or an API token with the right scope, reach it
+func (s *Server) requireAuth(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Slack requests and webhooks with a secret authenticate themselves (see slack.go and hooks.go)
+		if !s.auth.Enabled() || r.URL.Path == "/login" || r.URL.Path == slackCommandPath || r.URL.Path == slackActionsPath || s.hookAuthenticates(r.URL.Path) ||
+			strings.HasPrefix(r.URL.Path, "/static/") {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		id, err := s.authorize(r)
+		if err == nil && !id.role.allows(r) {
+			err = errForbidden
+		}
+		if err == nil {
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), userContextKey{}, id)))
+			return
+		}
+		if err == errForbidden {
+			http.Error(w, "Forbidden: "+err.Error(), http.StatusForbidden)
+			return
+		}
+
+		// htmx follows HX-Redirect instead of swapping the login page into a fragment
+		login := "/login?next=" + url.QueryEscape(r.URL.RequestURI())
+		if r.Header.Get("HX-Request") == "true" {
+			w.Header().Set("HX-Redirect", login)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.Method != "GET" || strings.HasPrefix(r.URL.Path, "/api/") {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		http.Redirect(w, r, login, http.StatusSeeOther)
+	})
+}
+
+// authorize checks the session cookie, then a Bearer token in the Authorization header,
+// and returns who made the request. Password logins carry their account's role, the
+// shared token is an admin, and API tokens act with the role matching their scope.
+func (s *Server) authorize(r *http.Request) (identity, error) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if id, ok := s.sessions.lookup(cookie.Value); ok {
+			return id, nil
+		}
+	}
+
+	bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || bearer == "" {
+		return identity{}, errUnauthorized
+	}
+
+	if s.auth.Token != "" && secureCompare(bearer, s.auth.Token) {
+		return identity{user: "token", role: RoleAdmin}, nil
+	}
+
+	if s.auth.Tokens != nil {
+		if token, err := s.auth.Tokens.Authenticate(bearer); err == nil {
+			return identity{user: "token:" + token.Name, role: roleForScope(token.Scope)}, nil
+		}
+	}
+
+	return identity{}, errUnauthorized
+}
+
+// currentUser returns the authenticated user for a request, or "anonymous" when auth is off
+func currentUser(r *http.Request) string {
+	if id, ok := r.Context().Value(userContextKey{}).(identity); ok && id.user != "" {
+		return id.user
+	}
+	return "anonymous"
+}
+
+// checkCredentials validates a submitted login form and returns who it identifies
+func (s *Server) checkCredentials(username, password, token string) (identity, bool) {
+	if s.auth.Token != "" && token != "" && secureCompare(token, s.auth.Token) {
+		return identity{user: "token", role: RoleAdmin}, true
+	}
+	if password == "" {
+		return identity{}, false
+	}
+
+	// Check every account and both fields so timing doesn't reveal which one failed
+	var match identity
+	matched := false
+	for _, a := range s.auth.accounts() {
+		userOK := secureCompare(username, a.username)
+		passOK := secureCompare(password, a.password)
+		if userOK && passOK && !matched {
+			match, matched = identity{user: a.username, role: a.role}, true
+		}
+	}
+	return match, matched
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
+
+// safeRedirect only allows redirects back into this site after login. Browsers
+// read a backslash as a slash, so /\evil.com would leave the site like //evil.com.
+func safeRedirect(next string) string {
+	u, err := url.Parse(next)
+	if err != nil || u.Scheme != "" || u.Host != "" || strings.Contains(next, "\\") ||
+		!strings.HasPrefix(next, "/") || strings.HasPrefix(next, "//") || strings.HasPrefix(next, "/login") {
+		return "/"
+	}
+	return next
+}
+
+func (s *Server) handleLogin(w http.ResponseWriter, r *http.Request) {
+	next := safeRedirect(r.FormValue("next"))
+
+	if !s.auth.Enabled() {
+		http.Redirect(w, r, next, http.StatusSeeOther)
+		return
+	}
+
+	var loginError string
+	if r.Method == "POST" {
+		if user, ok := s.checkCredentials(r.FormValue("username"), r.FormValue("password"), r.FormValue("token")); ok {
+			id, err := s.sessions.create(user, s.auth.SessionTTL)
+			if err != nil {
+				http.Error(w, "Failed to create session", http.StatusInternalServerError)
+				return
+			}
+
+			http.SetCookie(w, &http.Cookie{
+				Name:     sessionCookieName,
+				Value:    id,
+				Path:     "/",
+				MaxAge:   int(s.auth.SessionTTL.Seconds()),
+				HttpOnly: true,
+				Secure:   r.TLS != nil,
+				SameSite: http.SameSiteLaxMode,
+			})
+			http.Redirect(w, r, next, http.StatusSeeOther)
+			return
+		}
+
+		// Slow down password guessing a little
+		time.Sleep(500 * time.Millisecond)
+		loginError = "Invalid credentials"
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(http.StatusUnauthorized)
+	}
+
+	data := struct {
+		Error         string
+		Next          string
+		CSRF          string
+		PasswordLogin bool
+	}{
+		Error:         loginError,
+		Next:          next,
+		CSRF:          csrfToken(r),
+		PasswordLogin: len(s.auth.accounts()) > 0,
+	}
+
+	s.renderPage(w, r, "login", PageData{Title: "Peep - Sign In", Content: data})
+}
+
+func (s *Server) handleLogout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil && s.sessions != nil {
+		s.sessions.delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
@@ -0,0 +1,240 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/tokens"
+)
+
+// testCSRFToken stands in for the cookie protectCSRF hands a browser
+var testCSRFToken = strings.Repeat("ab", 32)
+
+func TestSafeRedirect(t *testing.T) {
+	tests := []struct {
+		next string
+		want string
+	}{
+		{"/logs?level=error", "/logs?level=error"},
+		{"/alerts", "/alerts"},
+		{"", "/"},
+		{"logs", "/"},
+		{"//evil.com", "/"},
+		{"/\\evil.com", "/"},
+		{"\\\\evil.com", "/"},
+		{"/\\/evil.com", "/"},
+		{"https://evil.com/", "/"},
+		{"javascript:alert(1)", "/"},
+		{"/\t/evil.com", "/"},
+		{"/login", "/"},
+		{"/login?next=/logs", "/"},
+	}
+	for _, tt := range tests {
+		if got := safeRedirect(tt.next); got != tt.want {
+			t.Errorf("safeRedirect(%q) = %q, want %q", tt.next, got, tt.want)
+		}
+	}
+}
+
+func TestCSRFSafe(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		path    string
+		headers map[string]string
+		want    bool
+	}{
+		{"page load", "GET", "/logs", nil, true},
+		{"no token", "POST", "/alerts/rules", nil, false},
+		{"wrong token", "POST", "/alerts/rules", map[string]string{csrfHeaderName: strings.Repeat("cd", 32)}, false},
+		{"matching token", "POST", "/alerts/rules", map[string]string{csrfHeaderName: testCSRFToken}, true},
+		{"bearer token", "POST", "/alerts/rules", map[string]string{"Authorization": "Bearer secret"}, true},
+		{"log shipper", "POST", "/api/ingest", nil, true},
+		{"cross-site ingest", "POST", "/api/ingest", map[string]string{"Sec-Fetch-Site": "cross-site"}, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, nil)
+		for name, value := range tt.headers {
+			r.Header.Set(name, value)
+		}
+		if got := csrfSafe(r, testCSRFToken); got != tt.want {
+			t.Errorf("%s: csrfSafe = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+// newAuthServer returns the routes of a server with an admin and a viewer login
+func newAuthServer(t *testing.T, ttl time.Duration) http.Handler {
+	t.Helper()
+	server := newTestServer(t)
+	server.SetAuth(AuthConfig{
+		Username:       "admin",
+		Password:       "admin-pass",
+		ViewerUsername: "viewer",
+		ViewerPassword: "viewer-pass",
+		SessionTTL:     ttl,
+	})
+	return server.routes()
+}
+
+// login submits the login form and returns the response
+func login(handler http.Handler, username, password, next string) *httptest.ResponseRecorder {
+	form := url.Values{"username": {username}, "password": {password}, "next": {next}, csrfFieldName: {testCSRFToken}}
+	r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.AddCookie(&http.Cookie{Name: csrfCookieName, Value: testCSRFToken})
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// sessionCookie logs in and returns the session cookie
+func sessionCookie(t *testing.T, handler http.Handler, username, password string) *http.Cookie {
+	t.Helper()
+	w := login(handler, username, password, "/")
+	for _, cookie := range w.Result().Cookies() {
+		if cookie.Name == sessionCookieName {
+			return cookie
+		}
+	}
+	t.Fatalf("logging in as %s set no session cookie (status %d)", username, w.Code)
+	return nil
+}
+
+// get requests path with the cookie, if any
+func get(handler http.Handler, path string, cookie *http.Cookie) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodGet, path, nil)
+	if cookie != nil {
+		r.AddCookie(cookie)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestLogin(t *testing.T) {
+	handler := newAuthServer(t, time.Hour)
+
+	if w := login(handler, "admin", "wrong", "/logs"); w.Code != http.StatusUnauthorized {
+		t.Errorf("wrong password: got status %d, want 401", w.Code)
+	}
+
+	w := login(handler, "admin", "admin-pass", "/\\evil.com")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/" {
+		t.Errorf("got %d to %q, want a redirect to /", w.Code, w.Header().Get("Location"))
+	}
+
+	w = login(handler, "admin", "admin-pass", "/logs?level=error")
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/logs?level=error" {
+		t.Errorf("got %d to %q, want a redirect back to the logs", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	handler := newAuthServer(t, 50*time.Millisecond)
+	cookie := sessionCookie(t, handler, "admin", "admin-pass")
+
+	if w := get(handler, "/logs", cookie); w.Code != http.StatusOK {
+		t.Fatalf("fresh session: got status %d, want 200", w.Code)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	w := get(handler, "/logs", cookie)
+	if w.Code != http.StatusSeeOther || w.Header().Get("Location") != "/login?next=%2Flogs" {
+		t.Errorf("expired session: got %d to %q, want a redirect to the login page", w.Code, w.Header().Get("Location"))
+	}
+}
+
+func TestRequireAuthRoles(t *testing.T) {
+	handler := newAuthServer(t, time.Hour)
+	cookies := map[string]*http.Cookie{
+		"admin":  sessionCookie(t, handler, "admin", "admin-pass"),
+		"viewer": sessionCookie(t, handler, "viewer", "viewer-pass"),
+	}
+
+	tests := []struct {
+		who  string
+		path string
+		want int
+	}{
+		{"anonymous", "/logs", http.StatusSeeOther},
+		{"anonymous", "/api/stats", http.StatusUnauthorized},
+		{"viewer", "/logs", http.StatusOK},
+		{"viewer", "/api/stats", http.StatusOK},
+		{"viewer", "/query", http.StatusForbidden},
+		{"viewer", "/alerts", http.StatusForbidden},
+		{"viewer", "/settings", http.StatusForbidden},
+		{"admin", "/query", http.StatusOK},
+		{"admin", "/alerts", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if w := get(handler, tt.path, cookies[tt.who]); w.Code != tt.want {
+			t.Errorf("%s GET %s: got status %d, want %d", tt.who, tt.path, w.Code, tt.want)
+		}
+	}
+
+	// htmx gets told where to log in rather than a redirect it would swap in
+	r := httptest.NewRequest(http.MethodGet, "/logs/search", nil)
+	r.Header.Set("HX-Request", "true")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	if w.Code != http.StatusUnauthorized || !strings.HasPrefix(w.Header().Get("HX-Redirect"), "/login?next=") {
+		t.Errorf("htmx request: got %d with HX-Redirect %q", w.Code, w.Header().Get("HX-Redirect"))
+	}
+}
+
+func TestAPITokenScopes(t *testing.T) {
+	server := newTestServer(t)
+	store, err := tokens.NewStore(server.storage.GetDB())
+	if err != nil {
+		t.Fatal(err)
+	}
+	secrets := make(map[tokens.Scope]string)
+	for _, scope := range []tokens.Scope{tokens.ScopeIngest, tokens.ScopeRead, tokens.ScopeAdmin} {
+		secret, _, err := store.Create(string(scope), scope)
+		if err != nil {
+			t.Fatal(err)
+		}
+		secrets[scope] = secret
+	}
+	revoked, token, err := store.Create("revoked", tokens.ScopeAdmin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Revoke(token.ID); err != nil {
+		t.Fatal(err)
+	}
+	server.SetAuth(AuthConfig{Username: "admin", Password: "admin-pass", Tokens: store})
+	handler := server.routes()
+
+	tests := []struct {
+		secret string
+		method string
+		path   string
+		want   int
+	}{
+		{secrets[tokens.ScopeIngest], "POST", "/api/ingest", http.StatusOK},
+		{secrets[tokens.ScopeIngest], "GET", "/api/stats", http.StatusForbidden},
+		{secrets[tokens.ScopeIngest], "GET", "/logs", http.StatusForbidden},
+		{secrets[tokens.ScopeRead], "GET", "/api/stats", http.StatusOK},
+		{secrets[tokens.ScopeRead], "POST", "/api/ingest", http.StatusForbidden},
+		{secrets[tokens.ScopeRead], "GET", "/alerts", http.StatusForbidden},
+		{secrets[tokens.ScopeAdmin], "GET", "/alerts", http.StatusOK},
+		{secrets[tokens.ScopeAdmin], "POST", "/api/ingest", http.StatusOK},
+		{revoked, "POST", "/api/ingest", http.StatusUnauthorized},
+		{"peep_not-a-token", "GET", "/api/stats", http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(tt.method, tt.path, strings.NewReader("hello\n"))
+		r.Header.Set("Authorization", "Bearer "+tt.secret)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != tt.want {
+			t.Errorf("%s %s with %.13s: got status %d, want %d", tt.method, tt.path, tt.secret, w.Code, tt.want)
+		}
+	}
+}
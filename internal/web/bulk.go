@@ -0,0 +1,148 @@
+package web
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// maxBulkBytes caps a bulk batch once decompressed
+const maxBulkBytes = 64 << 20
+
+// bulkRequest is a batch of parsed logs from peep agent
+type bulkRequest struct {
+	Agent   string      `json:"agent"` // The agent's ID; sequence numbers are per agent
+	Name    string      `json:"name"`
+	Entries []bulkEntry `json:"entries"`
+}
+
+type bulkEntry struct {
+	Seq       int64     `json:"seq"`
+	Timestamp time.Time `json:"timestamp"`
+	Level     string    `json:"level"`
+	Message   string    `json:"message"`
+	Service   string    `json:"service"`
+	Context   string    `json:"context"`
+	RawLog    string    `json:"raw_log"`
+}
+
+// readBulkBody reads a batch, gunzipping it if needed. On error it returns
+// the status to answer with.
+func readBulkBody(r *http.Request) ([]byte, int, error) {
+	body := io.Reader(r.Body)
+	switch strings.ToLower(r.Header.Get("Content-Encoding")) {
+	case "", "identity":
+	case "gzip":
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, http.StatusBadRequest, fmt.Errorf("invalid gzip body: %v", err)
+		}
+		defer gz.Close()
+		body = gz
+	default:
+		return nil, http.StatusUnsupportedMediaType, fmt.Errorf("unsupported Content-Encoding; use gzip or none")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(body, maxBulkBytes+1))
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("failed to read request body: %v", err)
+	}
+	if len(data) > maxBulkBytes {
+		return nil, http.StatusRequestEntityTooLarge, fmt.Errorf("batch is over %d MB; send smaller batches", maxBulkBytes>>20)
+	}
+	return data, 0, nil
+}
+
+// handleAPIIngestBulk stores a batch from peep agent. Unlike /api/ingest the
+// logs arrive already parsed, the body may be gzipped, and logs the agent
+// has sent before are dropped, so it can safely resend a batch whose
+// response it never got.
+func (s *Server) handleAPIIngestBulk(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	data, status, err := readBulkBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	var batch bulkRequest
+	if err := json.Unmarshal(data, &batch); err != nil {
+		http.Error(w, fmt.Sprintf("invalid batch: %v", err), http.StatusBadRequest)
+		return
+	}
+	if batch.Agent == "" {
+		http.Error(w, "invalid batch: agent is required", http.StatusBadRequest)
+		return
+	}
+	if batch.Name == "" {
+		batch.Name = batch.Agent
+	}
+
+	entries := make([]storage.SequencedEntry, len(batch.Entries))
+	for i, e := range batch.Entries {
+		if e.Seq <= 0 {
+			http.Error(w, "invalid batch: every entry needs a positive seq", http.StatusBadRequest)
+			return
+		}
+		entry := storage.LogEntry{
+			Timestamp: e.Timestamp,
+			Level:     e.Level,
+			Message:   e.Message,
+			Service:   e.Service,
+			Context:   e.Context,
+			RawLog:    e.RawLog,
+		}
+		if entry.Timestamp.IsZero() {
+			entry.Timestamp = time.Now()
+		}
+		if entry.Level == "" {
+			entry.Level = "info"
+		}
+		if entry.Service == "" {
+			entry.Service = "unknown"
+		}
+		if entry.Context == "" {
+			entry.Context = "{}"
+		}
+		if entry.RawLog == "" {
+			entry.RawLog = entry.Message
+		}
+		entries[i] = storage.SequencedEntry{Seq: e.Seq, LogEntry: entry}
+	}
+
+	address, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		address = r.RemoteAddr
+	}
+	stored, duplicates, err := s.storage.StoreAgentBatch(storage.Agent{ID: batch.Agent, Name: batch.Name, Address: address}, entries)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to store logs: %v", err), http.StatusInternalServerError)
+		return
+	}
+	if stored > 0 {
+		s.storage.TriggerRetentionCheck()
+	}
+
+	// StoreAgentBatch sorts the batch by seq, so the resends it dropped come
+	// first. A failure is only logged, since a resent batch isn't derived again.
+	for _, entry := range entries[len(entries)-stored:] {
+		if err := s.deriver.Observe(entry.LogEntry); err != nil {
+			slog.Warn("failed to store derived metrics", "error", err)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"stored": stored, "duplicates": duplicates})
+}
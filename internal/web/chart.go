@@ -0,0 +1,218 @@
+package web
+
+import (
+	"fmt"
+	"html/template"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Chart dimensions in SVG user units; the SVG scales to its container
+const (
+	chartWidth   = 800
+	chartHeight  = 300
+	chartPadLeft = 60
+	chartPadBot  = 40
+	chartPadTop  = 20
+	chartPadRite = 20
+)
+
+var chartColors = []string{"#2563eb", "#10b981", "#f59e0b", "#ef4444", "#8b5cf6", "#06b6d4"}
+
+// timeLayouts are the formats recognized as time-like values in query results
+var timeLayouts = []string{
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02 15:04:05.999999999-07:00",
+	"2006-01-02",
+	"2006-01",
+}
+
+// chartSpec describes which result columns can be plotted
+type chartSpec struct {
+	x      int   // Index of the time-like column
+	series []int // Indexes of numeric columns
+}
+
+// parseTimeValue parses a result value as a time, trying each supported layout
+func parseTimeValue(s string) (time.Time, bool) {
+	for _, layout := range timeLayouts {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// detectChart finds a time-like column and at least one numeric column in a result set
+func detectChart(columns []string, rows [][]string) (*chartSpec, bool) {
+	if len(rows) < 2 {
+		return nil, false
+	}
+
+	spec := &chartSpec{x: -1}
+	for col := range columns {
+		timeLike, numeric := true, true
+		for _, row := range rows {
+			if _, ok := parseTimeValue(row[col]); !ok {
+				timeLike = false
+			}
+			if _, err := strconv.ParseFloat(row[col], 64); err != nil {
+				numeric = false
+			}
+		}
+
+		switch {
+		case timeLike && spec.x == -1:
+			spec.x = col
+		case numeric && !timeLike:
+			spec.series = append(spec.series, col)
+		}
+	}
+
+	if spec.x == -1 || len(spec.series) == 0 {
+		return nil, false
+	}
+	return spec, true
+}
+
+// renderChart draws the result set as an inline SVG line or bar chart, oldest point first
+func renderChart(columns []string, rows [][]string, spec *chartSpec, mode string) template.HTML {
+	points := make([][]string, len(rows))
+	copy(points, rows)
+	sort.SliceStable(points, func(i, j int) bool {
+		a, _ := parseTimeValue(points[i][spec.x])
+		b, _ := parseTimeValue(points[j][spec.x])
+		return a.Before(b)
+	})
+
+	maxValue := 0.0
+	for _, row := range points {
+		for _, col := range spec.series {
+			v, _ := strconv.ParseFloat(row[col], 64)
+			maxValue = math.Max(maxValue, v)
+		}
+	}
+	if maxValue == 0 {
+		maxValue = 1
+	}
+
+	plotW := float64(chartWidth - chartPadLeft - chartPadRite)
+	plotH := float64(chartHeight - chartPadTop - chartPadBot)
+	slot := plotW / float64(len(points))
+
+	xFor := func(i int) float64 { return chartPadLeft + slot*(float64(i)+0.5) }
+	yFor := func(v float64) float64 { return chartPadTop + plotH - v/maxValue*plotH }
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<svg class="query-chart" viewBox="0 0 %d %d" xmlns="http://www.w3.org/2000/svg" role="img">`, chartWidth, chartHeight)
+
+	// Axes and gridlines at 0, 50%, and 100% of the max value
+	for _, fraction := range []float64{0, 0.5, 1} {
+		y := yFor(maxValue * fraction)
+		fmt.Fprintf(&b, `<line x1="%d" y1="%.1f" x2="%d" y2="%.1f" style="stroke: var(--gray-200)"/>`, chartPadLeft, y, chartWidth-chartPadRite, y)
+		fmt.Fprintf(&b, `<text x="%d" y="%.1f" font-size="11" text-anchor="end" style="fill: var(--gray-500)">%s</text>`,
+			chartPadLeft-6, y+4, template.HTMLEscapeString(formatChartNumber(maxValue*fraction)))
+	}
+
+	// X labels: first, middle, and last points to avoid overlap
+	labelIdx := []int{0, len(points) / 2, len(points) - 1}
+	seen := make(map[int]bool)
+	for _, i := range labelIdx {
+		if seen[i] {
+			continue
+		}
+		seen[i] = true
+		fmt.Fprintf(&b, `<text x="%.1f" y="%d" font-size="11" text-anchor="middle" style="fill: var(--gray-500)">%s</text>`,
+			xFor(i), chartHeight-chartPadBot+16, template.HTMLEscapeString(points[i][spec.x]))
+	}
+
+	for s, col := range spec.series {
+		color := chartColors[s%len(chartColors)]
+
+		if mode == "bar" {
+			barW := slot * 0.8 / float64(len(spec.series))
+			for i, row := range points {
+				v, _ := strconv.ParseFloat(row[col], 64)
+				x := chartPadLeft + slot*float64(i) + slot*0.1 + barW*float64(s)
+				y := yFor(v)
+				fmt.Fprintf(&b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s"><title>%s: %s</title></rect>`,
+					x, y, barW, chartPadTop+plotH-y, color,
+					template.HTMLEscapeString(row[spec.x]), template.HTMLEscapeString(row[col]))
+			}
+			continue
+		}
+
+		coords := make([]string, len(points))
+		for i, row := range points {
+			v, _ := strconv.ParseFloat(row[col], 64)
+			coords[i] = fmt.Sprintf("%.1f,%.1f", xFor(i), yFor(v))
+		}
+		fmt.Fprintf(&b, `<polyline points="%s" fill="none" stroke="%s" stroke-width="2"/>`, strings.Join(coords, " "), color)
+		for i, row := range points {
+			v, _ := strconv.ParseFloat(row[col], 64)
+			fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s"><title>%s: %s</title></circle>`,
+				xFor(i), yFor(v), color,
+				template.HTMLEscapeString(row[spec.x]), template.HTMLEscapeString(row[col]))
+		}
+	}
+
+	b.WriteString(`</svg>`)
+
+	// Legend
+	b.WriteString(`<div class="chart-legend">`)
+	for s, col := range spec.series {
+		fmt.Fprintf(&b, `<span><span class="chart-swatch" style="background: %s;"></span>%s</span>`,
+			chartColors[s%len(chartColors)], template.HTMLEscapeString(columns[col]))
+	}
+	b.WriteString(`</div>`)
+
+	return template.HTML(b.String())
+}
+
+// formatChartNumber keeps axis labels short
+func formatChartNumber(v float64) string {
+	switch {
+	case v >= 1e6:
+		return fmt.Sprintf("%.1fM", v/1e6)
+	case v >= 1e3:
+		return fmt.Sprintf("%.1fk", v/1e3)
+	case v == math.Trunc(v):
+		return fmt.Sprintf("%.0f", v)
+	default:
+		return fmt.Sprintf("%.2f", v)
+	}
+}
+
+// Sparkline dimensions in SVG user units
+const (
+	sparklineWidth  = 120
+	sparklineHeight = 24
+)
+
+// renderSparkline draws counts as a small inline SVG line, oldest first
+func renderSparkline(counts []int) template.HTML {
+	if len(counts) < 2 {
+		return ""
+	}
+
+	maxCount := 1
+	for _, c := range counts {
+		maxCount = max(maxCount, c)
+	}
+
+	coords := make([]string, len(counts))
+	for i, c := range counts {
+		x := float64(i) * sparklineWidth / float64(len(counts)-1)
+		y := sparklineHeight - 1 - float64(c)/float64(maxCount)*(sparklineHeight-2)
+		coords[i] = fmt.Sprintf("%.1f,%.1f", x, y)
+	}
+
+	return template.HTML(fmt.Sprintf(`<svg class="sparkline" viewBox="0 0 %d %d" width="%d" height="%d" xmlns="http://www.w3.org/2000/svg" aria-hidden="true">`+
+		`<polyline points="%s" fill="none" stroke="%s" stroke-width="1.5"/></svg>`,
+		sparklineWidth, sparklineHeight, sparklineWidth, sparklineHeight, strings.Join(coords, " "), chartColors[0]))
+}
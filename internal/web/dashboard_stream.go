@@ -0,0 +1,85 @@
+package web
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// defaultRefreshInterval is how often the dashboard stream checks for new data
+const defaultRefreshInterval = 5 * time.Second
+
+// SetRefreshInterval sets how often the dashboard stream checks for new data
+func (s *Server) SetRefreshInterval(interval time.Duration) {
+	if interval <= 0 {
+		interval = defaultRefreshInterval
+	}
+	s.refreshInterval = interval
+}
+
+// dashboardEvents are the SSE events the dashboard listens for, each a partial
+// rendered from DashboardData and swapped into the element with the matching sse-swap
+var dashboardEvents = []struct{ event, partial string }{
+	{"stats", "dashboardStats"},
+	{"alerts", "recentAlerts"},
+}
+
+// handleDashboardStream pushes the dashboard's stat cards and recent alerts over
+// Server-Sent Events. Each fragment is only sent when its HTML changes, so idle
+// dashboards cost a few queries per interval and no bandwidth.
+func (s *Server) handleDashboardStream(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	// The stream outlives the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+
+	ticker := time.NewTicker(s.refreshInterval)
+	defer ticker.Stop()
+
+	// Sending everything on connect also catches up a page that reconnected after a drop
+	last := make(map[string]string)
+
+	for {
+		data, err := s.getDashboardData(r.Context())
+		if err == nil {
+			for _, e := range dashboardEvents {
+				var buf bytes.Buffer
+				if err := s.templates.partials.ExecuteTemplate(&buf, e.partial, data); err != nil {
+					continue
+				}
+				html := buf.String()
+				if html == last[e.event] {
+					continue
+				}
+				last[e.event] = html
+				writeSSE(w, e.event, html)
+			}
+		}
+		flusher.Flush()
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// writeSSE writes one event; multi-line data needs a "data:" prefix on every line
+func writeSSE(w http.ResponseWriter, event, data string) {
+	fmt.Fprintf(w, "event: %s\n", event)
+	for _, line := range strings.Split(data, "\n") {
+		fmt.Fprintf(w, "data: %s\n", line)
+	}
+	fmt.Fprint(w, "\n")
+}
@@ -0,0 +1,245 @@
+package web
+
+import (
+	"database/sql"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// maxPanelRows caps how many rows a table panel shows
+const maxPanelRows = 100
+
+// panelResult is a panel plus the outcome of running its query
+type panelResult struct {
+	Dashboard string
+	Panel     storage.DashboardPanel
+	Columns   []string
+	Rows      [][]string
+	Stat      string
+	Chart     template.HTML
+	SLO       *alerts.SLOStatus // For SLO panels
+	Error     string
+}
+
+// handleDashboards lists the user-defined dashboards
+func (s *Server) handleDashboards(w http.ResponseWriter, r *http.Request) {
+	dashboards, err := s.storage.ListDashboards()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	s.renderPage(w, r, "dashboards", PageData{Title: "Dashboards - Peep", Active: "dashboards", Content: dashboards})
+}
+
+// handleNewDashboard shows the dashboard builder and creates the dashboard on submit
+func (s *Server) handleNewDashboard(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		s.renderDashboardForm(w, r, nil)
+
+	case "POST":
+		dashboard, err := parseDashboardForm(r)
+		if err != nil {
+			writeFormError(w, err.Error())
+			return
+		}
+
+		if err := s.storage.SaveDashboard(dashboard); err != nil {
+			writeFormError(w, "Error creating dashboard: "+err.Error())
+			return
+		}
+
+		writeDashboardSaved(w, dashboard, "created")
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// handleDashboardAction routes /dashboards/{name}, /dashboards/{name}/edit,
+// /dashboards/{name}/panels/{id}, and DELETE /dashboards/{name}
+func (s *Server) handleDashboardAction(w http.ResponseWriter, r *http.Request) {
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/dashboards/"), "/"), "/")
+
+	dashboard, err := s.storage.GetDashboard(parts[0])
+	if err == sql.ErrNoRows {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	switch {
+	case len(parts) == 1 && r.Method == "GET":
+		s.renderPage(w, r, "dashboard_view", PageData{Title: dashboard.Title + " - Peep", Active: "dashboards", Content: dashboard})
+
+	case len(parts) == 1 && r.Method == "DELETE":
+		if err := s.storage.DeleteDashboard(dashboard.Name); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("HX-Redirect", "/dashboards")
+
+	case len(parts) == 2 && parts[1] == "edit" && r.Method == "GET":
+		s.renderDashboardForm(w, r, dashboard)
+
+	case len(parts) == 2 && parts[1] == "edit" && r.Method == "POST":
+		updated, err := parseDashboardForm(r)
+		if err != nil {
+			writeFormError(w, err.Error())
+			return
+		}
+
+		updated.ID = dashboard.ID
+		if err := s.storage.SaveDashboard(updated); err != nil {
+			writeFormError(w, "Error updating dashboard: "+err.Error())
+			return
+		}
+
+		writeDashboardSaved(w, updated, "updated")
+
+	case len(parts) == 3 && parts[1] == "panels" && r.Method == "GET":
+		panelID, err := strconv.ParseInt(parts[2], 10, 64)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		for _, panel := range dashboard.Panels {
+			if panel.ID == panelID {
+				s.renderPartial(w, "dashboardPanel", s.runPanel(r, dashboard.Name, panel))
+				return
+			}
+		}
+		http.NotFound(w, r)
+
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// runPanel runs a panel's query and shapes the result for its type
+func (s *Server) runPanel(r *http.Request, dashboard string, panel storage.DashboardPanel) panelResult {
+	result := panelResult{Dashboard: dashboard, Panel: panel}
+
+	if panel.Type == storage.PanelSLO {
+		slo, err := s.engine.GetSLO(panel.Query)
+		if err != nil {
+			result.Error = err.Error()
+			return result
+		}
+		status := s.engine.MeasureSLO(r.Context(), slo)
+		result.SLO = &status
+		return result
+	}
+
+	query, err := s.execQuery(r.Context(), panel.Query)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	columns, values := query.Columns, query.Rows
+	result.Columns = columns
+
+	switch panel.Type {
+	case storage.PanelStat:
+		if len(values) == 0 || len(values[0]) == 0 {
+			result.Stat = "-"
+		} else {
+			result.Stat = values[0][0]
+		}
+
+	case storage.PanelTimeSeries:
+		spec, ok := detectChart(columns, values)
+		if !ok {
+			result.Error = "Time series panels need a time column and at least one numeric column, with two or more rows"
+			return result
+		}
+		result.Chart = renderChart(columns, values, spec, "line")
+
+	case storage.PanelTable:
+		if len(values) > maxPanelRows {
+			values = values[:maxPanelRows]
+		}
+		result.Rows = values
+	}
+
+	return result
+}
+
+// renderDashboardForm shows the builder, empty for a new dashboard or filled in for editing
+func (s *Server) renderDashboardForm(w http.ResponseWriter, r *http.Request, dashboard *storage.Dashboard) {
+	data := struct {
+		Dashboard  *storage.Dashboard // nil when creating
+		Action     string
+		Panels     []storage.DashboardPanel
+		PanelTypes []string
+	}{
+		Dashboard:  dashboard,
+		Action:     "/dashboards/new",
+		PanelTypes: storage.PanelTypes,
+	}
+
+	title := "New Dashboard - Peep"
+	if dashboard != nil {
+		title = "Edit Dashboard - Peep"
+		data.Action = "/dashboards/" + dashboard.Name + "/edit"
+		data.Panels = dashboard.Panels
+	}
+	// Always offer at least one panel to fill in
+	if len(data.Panels) == 0 {
+		data.Panels = []storage.DashboardPanel{{Type: storage.PanelStat}}
+	}
+
+	s.renderPage(w, r, "dashboard_form", PageData{Title: title, Active: "dashboards", Content: data})
+}
+
+// parseDashboardForm reads the builder form. Panels arrive as parallel
+// panel_title/panel_type/panel_query fields; rows left entirely blank are skipped.
+func parseDashboardForm(r *http.Request) (*storage.Dashboard, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	dashboard := &storage.Dashboard{
+		Name:        strings.TrimSpace(r.FormValue("name")),
+		Title:       strings.TrimSpace(r.FormValue("title")),
+		Description: strings.TrimSpace(r.FormValue("description")),
+	}
+
+	titles := r.PostForm["panel_title"]
+	types := r.PostForm["panel_type"]
+	queries := r.PostForm["panel_query"]
+	if len(titles) != len(types) || len(types) != len(queries) {
+		return nil, fmt.Errorf("malformed panel fields")
+	}
+
+	for i := range queries {
+		panel := storage.DashboardPanel{
+			Title: strings.TrimSpace(titles[i]),
+			Type:  types[i],
+			Query: strings.TrimSpace(queries[i]),
+		}
+		if panel.Title == "" && panel.Query == "" {
+			continue
+		}
+		dashboard.Panels = append(dashboard.Panels, panel)
+	}
+
+	return dashboard, dashboard.Validate()
+}
+
+func writeDashboardSaved(w http.ResponseWriter, dashboard *storage.Dashboard, verb string) {
+	w.Header().Set("Content-Type", "text/html")
+	w.Write([]byte(fmt.Sprintf(`<div class="notice notice-success">
+		✅ Dashboard %s! <a href="/dashboards/%s">View dashboard</a>
+	</div>`, verb, template.HTMLEscapeString(dashboard.Name))))
+}
@@ -0,0 +1,275 @@
+package web
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// esPrefix is where Peep answers like an Elasticsearch cluster, for shippers
+// that can only send there (Filebeat, Logstash, Fluent Bit, appliances): set
+// their Elasticsearch URL to http://peep:8080/es. Only the bulk API, and the
+// cluster info clients check first, are supported; documents become logs.
+const esPrefix = "/es"
+
+// esVersion is the Elasticsearch version reported to clients, recent enough
+// that current Beats and clients accept it
+const esVersion = "8.11.0"
+
+// esBulkItem is one action's result in a bulk response
+type esBulkItem struct {
+	Index   string   `json:"_index"`
+	ID      string   `json:"_id"`
+	Version int      `json:"_version,omitempty"`
+	Result  string   `json:"result,omitempty"`
+	Status  int      `json:"status"`
+	Error   *esError `json:"error,omitempty"`
+}
+
+type esError struct {
+	Type   string `json:"type"`
+	Reason string `json:"reason"`
+}
+
+func (s *Server) handleElasticsearch(w http.ResponseWriter, r *http.Request) {
+	// Official clients refuse to talk to a server without this header
+	w.Header().Set("X-Elastic-Product", "Elasticsearch")
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, esPrefix), "/")
+	parts := strings.Split(path, "/")
+	switch {
+	case path == "":
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			writeESError(w, http.StatusMethodNotAllowed, "method_not_allowed", "only GET and HEAD are allowed here")
+			return
+		}
+		writeESJSON(w, http.StatusOK, map[string]interface{}{
+			"name":         "peep",
+			"cluster_name": "peep",
+			"cluster_uuid": "peep",
+			"version": map[string]interface{}{
+				"number":                              esVersion,
+				"build_flavor":                        "default",
+				"lucene_version":                      "9.8.0",
+				"minimum_wire_compatibility_version":  "7.17.0",
+				"minimum_index_compatibility_version": "7.0.0",
+			},
+			"tagline": "You Know, for Search",
+		})
+	case parts[len(parts)-1] == "_bulk" && len(parts) <= 2:
+		if r.Method != http.MethodPost && r.Method != http.MethodPut {
+			writeESError(w, http.StatusMethodNotAllowed, "method_not_allowed", "use POST for _bulk")
+			return
+		}
+		index := ""
+		if len(parts) == 2 {
+			index = parts[0]
+		}
+		s.handleESBulk(w, r, index)
+	default:
+		writeESError(w, http.StatusNotFound, "unsupported_operation_exception",
+			"Peep only accepts the bulk API ("+esPrefix+"/_bulk); turn off template and ILM setup in the shipper")
+	}
+}
+
+// handleESBulk stores the documents of index and create actions as logs.
+// Other actions can't apply to logs and fail individually, as Elasticsearch
+// reports per-item errors.
+func (s *Server) handleESBulk(w http.ResponseWriter, r *http.Request, defaultIndex string) {
+	started := time.Now()
+	data, status, err := readBulkBody(r)
+	if err != nil {
+		writeESError(w, status, "parse_exception", err.Error())
+		return
+	}
+
+	var items []map[string]esBulkItem
+	failed := false
+	stored := 0
+	lines := bytes.Split(data, []byte("\n"))
+	for i := 0; i < len(lines); i++ {
+		line := bytes.TrimSpace(lines[i])
+		if len(line) == 0 {
+			continue
+		}
+
+		var action map[string]struct {
+			Index string `json:"_index"`
+			ID    string `json:"_id"`
+		}
+		if err := json.Unmarshal(line, &action); err != nil || len(action) != 1 {
+			writeESError(w, http.StatusBadRequest, "illegal_argument_exception", "malformed action/metadata line "+strconv.Itoa(i+1))
+			return
+		}
+		var op string
+		for op = range action {
+		}
+		meta := action[op]
+		if meta.Index == "" {
+			meta.Index = defaultIndex
+		}
+		if meta.ID == "" {
+			meta.ID = esDocumentID()
+		}
+
+		// Every action but delete is followed by a source line
+		var source []byte
+		if op != "delete" {
+			i++
+			if i >= len(lines) {
+				writeESError(w, http.StatusBadRequest, "illegal_argument_exception", "the bulk request must be terminated by a newline")
+				return
+			}
+			source = bytes.TrimSpace(lines[i])
+		}
+
+		item := esBulkItem{Index: meta.Index, ID: meta.ID}
+		switch op {
+		case "index", "create":
+			entry, err := esDocumentLog(source, meta.Index)
+			if err != nil {
+				item.Status, item.Error = http.StatusBadRequest, &esError{Type: "document_parsing_exception", Reason: err.Error()}
+				break
+			}
+			if err := s.storage.InsertLog(entry); err != nil {
+				item.Status, item.Error = http.StatusInternalServerError, &esError{Type: "exception", Reason: err.Error()}
+				break
+			}
+			if err := s.deriver.Observe(entry); err != nil {
+				slog.Warn("failed to store derived metrics", "error", err)
+			}
+			item.Status, item.Result, item.Version = http.StatusCreated, "created", 1
+			stored++
+		default:
+			item.Status, item.Error = http.StatusBadRequest, &esError{Type: "illegal_argument_exception", Reason: "Peep stores logs; " + op + " isn't supported"}
+		}
+		failed = failed || item.Error != nil
+		items = append(items, map[string]esBulkItem{op: item})
+	}
+	if stored > 0 {
+		s.storage.TriggerRetentionCheck()
+	}
+
+	if items == nil {
+		items = []map[string]esBulkItem{}
+	}
+	writeESJSON(w, http.StatusOK, map[string]interface{}{
+		"took":   time.Since(started).Milliseconds(),
+		"errors": failed,
+		"items":  items,
+	})
+}
+
+// esDocumentLog maps a document onto a log, reading the fields Beats and
+// ECS loggers use (@timestamp, log.level, service.name), dotted or nested,
+// as well as the plain names /api/ingest reads. Logs without a service are
+// attributed to their index.
+func esDocumentLog(source []byte, index string) (storage.LogEntry, error) {
+	var doc map[string]interface{}
+	if err := json.Unmarshal(source, &doc); err != nil {
+		return storage.LogEntry{}, err
+	}
+
+	entry := storage.LogEntry{
+		Timestamp: time.Now(),
+		Level:     "info",
+		Message:   string(source),
+		Service:   index,
+		RawLog:    string(source),
+	}
+	for _, field := range []string{"@timestamp", "timestamp", "time"} {
+		if t, ok := esTime(esField(doc, field)); ok {
+			entry.Timestamp = t
+			break
+		}
+	}
+	for _, field := range []string{"log.level", "level", "severity"} {
+		if level, ok := esField(doc, field).(string); ok && level != "" {
+			entry.Level = strings.ToLower(level)
+			break
+		}
+	}
+	for _, field := range []string{"message", "msg", "event.original"} {
+		if message, ok := esField(doc, field).(string); ok && message != "" {
+			entry.Message = message
+			break
+		}
+	}
+	for _, field := range []string{"service.name", "service", "app", "kubernetes.container.name"} {
+		if service, ok := esField(doc, field).(string); ok && service != "" {
+			entry.Service = service
+			break
+		}
+	}
+	if entry.Service == "" {
+		entry.Service = "unknown"
+	}
+
+	if index != "" {
+		doc["_index"] = index
+	}
+	context, err := json.Marshal(doc)
+	if err != nil {
+		return storage.LogEntry{}, err
+	}
+	entry.Context = string(context)
+	return entry, nil
+}
+
+// esField looks up a dotted field name in a document, as a literal key
+// ("log.level": "error") or as nested objects ("log": {"level": "error"})
+func esField(doc map[string]interface{}, name string) interface{} {
+	if value, ok := doc[name]; ok {
+		return value
+	}
+	head, rest, found := strings.Cut(name, ".")
+	if !found {
+		return nil
+	}
+	nested, ok := doc[head].(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	return esField(nested, rest)
+}
+
+// esTime reads an RFC 3339 time or epoch milliseconds, Elasticsearch's default date formats
+func esTime(value interface{}) (time.Time, bool) {
+	switch v := value.(type) {
+	case string:
+		t, err := time.Parse(time.RFC3339Nano, v)
+		return t, err == nil
+	case float64:
+		return time.UnixMilli(int64(v)), v > 0
+	}
+	return time.Time{}, false
+}
+
+// esDocumentID makes an ID for a document sent without one, as Elasticsearch does
+func esDocumentID() string {
+	buf := make([]byte, 10)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func writeESJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// writeESError answers in Elasticsearch's error format, which clients log
+func writeESError(w http.ResponseWriter, status int, errorType, reason string) {
+	writeESJSON(w, status, map[string]interface{}{
+		"error":  map[string]interface{}{"type": errorType, "reason": reason, "root_cause": []esError{{Type: errorType, Reason: reason}}},
+		"status": status,
+	})
+}
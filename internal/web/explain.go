@@ -0,0 +1,128 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/kylereynolds/peep/internal/format"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// largeTableRows is the size above which a full table scan gets a warning
+const largeTableRows = 100000
+
+// planStep is one line of EXPLAIN QUERY PLAN output
+type planStep struct {
+	Detail   string
+	Depth    int
+	FullScan bool
+}
+
+// queryPlan is what the Explain button shows
+type queryPlan struct {
+	Query    string
+	Steps    []planStep
+	Warnings []string
+}
+
+// fullScanPattern matches steps that read a whole table: "SCAN logs", but not
+// "SCAN logs USING INDEX ..." or "SCAN CONSTANT ROW"
+var fullScanPattern = regexp.MustCompile(`^SCAN (?:TABLE )?(\S+)(?: AS \S+)?$`)
+
+// handleQueryExplain shows how SQLite will run the query, without running it
+func (s *Server) handleQueryExplain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	plan, err := s.explainQuery(r.Context(), r.FormValue("query"))
+	if err != nil {
+		writeFormError(w, "Explain Error: "+err.Error())
+		return
+	}
+
+	s.renderPartial(w, "queryPlan", plan)
+}
+
+func (s *Server) explainQuery(ctx context.Context, query string) (*queryPlan, error) {
+	query, err := storage.ReadStatement(query)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToUpper(query), "EXPLAIN") {
+		return nil, fmt.Errorf("the query is already an EXPLAIN; execute it instead")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	rows, release, err := s.storage.QueryReadOnly(ctx, "EXPLAIN QUERY PLAN "+query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer rows.Close()
+
+	plan := &queryPlan{Query: query}
+	depths := make(map[int]int) // Step id -> depth; top-level steps have parent 0
+	for rows.Next() {
+		var id, parent, unused int
+		var detail string
+		if err := rows.Scan(&id, &parent, &unused, &detail); err != nil {
+			return nil, err
+		}
+
+		depth := 0
+		if parent != 0 {
+			depth = depths[parent] + 1
+		}
+		depths[id] = depth
+
+		step := planStep{Detail: detail, Depth: depth}
+		if m := fullScanPattern.FindStringSubmatch(detail); m != nil {
+			step.FullScan = true
+			if warning := s.fullScanWarning(ctx, query, m[1]); warning != "" {
+				plan.Warnings = append(plan.Warnings, warning)
+			}
+		}
+		plan.Steps = append(plan.Steps, step)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return plan, nil
+}
+
+// fullScanWarning warns about a full scan of name, if it's a large table. SQLite names
+// aliased tables by their alias, so those are looked up in the query.
+func (s *Server) fullScanWarning(ctx context.Context, query, name string) string {
+	table := name
+	var exists int
+	db := s.storage.GetDB()
+	if db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&exists); exists == 0 {
+		alias := regexp.MustCompile(`(?i)\b(?:FROM|JOIN)\s+["\x60\[]?(\w+)["\x60\]]?\s+(?:AS\s+)?["\x60\[]?` + regexp.QuoteMeta(name) + `["\x60\]]?(?:\s|,|\)|$)`)
+		m := alias.FindStringSubmatch(query)
+		if m == nil {
+			return "" // A CTE or subquery; its size isn't known up front
+		}
+		table = m[1]
+	}
+
+	// MAX(rowid) is a cheap estimate that doesn't itself scan the table
+	var estimate int64
+	quoted := `"` + strings.ReplaceAll(table, `"`, `""`) + `"`
+	if err := db.QueryRowContext(ctx, "SELECT COALESCE(MAX(rowid), 0) FROM "+quoted).Scan(&estimate); err != nil || estimate < largeTableRows {
+		return ""
+	}
+
+	warning := fmt.Sprintf("Full table scan of %s (about %s rows) may be slow. Filter on an indexed column", table, format.Count(estimate))
+	if table == "logs" {
+		warning += " such as timestamp, level, or service"
+	}
+	return warning + ", or add a LIMIT."
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Export formats accepted by ?format= on the query and log endpoints
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+)
+
+// maxExportRows caps log exports when no explicit limit is given
+const maxExportRows = 10000
+
+// exportFormat returns the requested export format, or "" for the normal HTML response
+func exportFormat(r *http.Request) (string, error) {
+	switch format := strings.ToLower(r.FormValue("format")); format {
+	case "", "html":
+		return "", nil
+	case formatCSV, formatJSON:
+		return format, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q (use csv or json)", format)
+	}
+}
+
+// exportFilename builds a timestamped download name, e.g. peep-logs-20240102-150405.csv
+func exportFilename(kind, format string) string {
+	return fmt.Sprintf("peep-%s-%s.%s", kind, time.Now().Format("20060102-150405"), format)
+}
+
+// writeCSV sends a header row and data rows as a CSV download
+func writeCSV(w http.ResponseWriter, filename string, header []string, rows [][]string) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	cw := csv.NewWriter(w)
+	cw.Write(header)
+	cw.WriteAll(rows)
+}
+
+// writeJSON sends v as an indented JSON download
+func writeJSON(w http.ResponseWriter, filename string, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+// exportLogs streams the logs matching filter as a download, writing each as
+// it's read so a large export doesn't build up in memory
+func (s *Server) exportLogs(w http.ResponseWriter, r *http.Request, format string, filter logFilter) {
+	// A big export can outlast the server's write timeout
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	out := &logExport{w: w, format: format, filename: exportFilename("logs", format)}
+	if err := s.eachFilteredLog(r.Context(), filter, out.write); err != nil {
+		if !out.started {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		// Cut the download off rather than end it as if it were complete
+		panic(http.ErrAbortHandler)
+	}
+	out.close()
+}
+
+// logExport writes logs as CSV or a JSON array, sending the download headers
+// with the first log
+type logExport struct {
+	w        http.ResponseWriter
+	format   string
+	filename string
+	csv      *csv.Writer
+	started  bool
+	logs     int
+}
+
+func (e *logExport) start() {
+	e.started = true
+	if e.format == formatJSON {
+		e.w.Header().Set("Content-Type", "application/json")
+	} else {
+		e.w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+		e.csv = csv.NewWriter(e.w)
+		e.csv.Write([]string{"id", "timestamp", "level", "service", "message", "raw_log"})
+	}
+	e.w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", e.filename))
+}
+
+func (e *logExport) write(log *LogEntry) error {
+	if !e.started {
+		e.start()
+	}
+	e.logs++
+
+	if e.format == formatJSON {
+		data, err := json.MarshalIndent(log, "  ", "  ")
+		if err != nil {
+			return err
+		}
+		separator := "[\n  "
+		if e.logs > 1 {
+			separator = ",\n  "
+		}
+		_, err = fmt.Fprintf(e.w, "%s%s", separator, data)
+		return err
+	}
+
+	e.csv.Write([]string{
+		strconv.FormatInt(log.ID, 10),
+		log.Timestamp.Format(time.RFC3339),
+		log.Level,
+		log.Service,
+		log.Message,
+		log.RawLog,
+	})
+	return e.csv.Error()
+}
+
+func (e *logExport) close() {
+	if !e.started {
+		e.start()
+	}
+	if e.format == formatJSON {
+		if e.logs == 0 {
+			io.WriteString(e.w, "[]\n")
+		} else {
+			io.WriteString(e.w, "\n]\n")
+		}
+		return
+	}
+	e.csv.Flush()
+}
+
+// exportQueryResults writes query results in the given format. JSON rows are objects
+// keyed by column name, with NULLs as null.
+func exportQueryResults(w http.ResponseWriter, format string, columns []string, rows [][]interface{}) {
+	filename := exportFilename("query", format)
+
+	if format == formatJSON {
+		records := make([]map[string]interface{}, len(rows))
+		for i, row := range rows {
+			record := make(map[string]interface{}, len(columns))
+			for j, col := range columns {
+				record[col] = row[j]
+			}
+			records[i] = record
+		}
+		writeJSON(w, filename, records)
+		return
+	}
+
+	records := make([][]string, len(rows))
+	for i, row := range rows {
+		record := make([]string, len(row))
+		for j, val := range row {
+			switch v := val.(type) {
+			case nil:
+				record[j] = ""
+			case time.Time:
+				record[j] = v.Format(time.RFC3339)
+			default:
+				record[j] = fmt.Sprintf("%v", v)
+			}
+		}
+		records[i] = record
+	}
+	writeCSV(w, filename, columns, records)
+}
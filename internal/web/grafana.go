@@ -0,0 +1,289 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+)
+
+// Grafana JSON datasource endpoints (the simple-json / JSON API contract). Point the
+// datasource at http://host:port/grafana to chart log counts and alert firings.
+//
+// Targets:
+//
+//	logs                   all log lines per interval
+//	logs:level=<level>     lines at one level
+//	logs:service=<name>    lines from one service
+//	alerts                 alert firings per interval
+//	alerts:rule=<name>     firings of one rule
+
+const grafanaMaxAnnotations = 1000
+
+type grafanaRange struct {
+	From time.Time `json:"from"`
+	To   time.Time `json:"to"`
+}
+
+type grafanaQueryRequest struct {
+	Range         grafanaRange `json:"range"`
+	IntervalMs    int64        `json:"intervalMs"`
+	MaxDataPoints int64        `json:"maxDataPoints"`
+	Targets       []struct {
+		Target string `json:"target"`
+		RefID  string `json:"refId"`
+		Type   string `json:"type"` // "timeserie" (default) or "table"
+	} `json:"targets"`
+}
+
+type grafanaAnnotationRequest struct {
+	Range      grafanaRange    `json:"range"`
+	Annotation json.RawMessage `json:"annotation"`
+}
+
+// grafanaTarget is where a target's counts come from
+type grafanaTarget struct {
+	table      string
+	timeColumn string
+	condition  string
+	arg        interface{}
+}
+
+// parseGrafanaTarget maps a target name onto a table and optional filter
+func parseGrafanaTarget(target string) (*grafanaTarget, error) {
+	kind, filter, _ := strings.Cut(target, ":")
+
+	var t grafanaTarget
+	var allowed map[string]string // Filter key -> column
+	switch kind {
+	case "logs":
+		t = grafanaTarget{table: "logs", timeColumn: "timestamp"}
+		allowed = map[string]string{"level": "level", "service": "service"}
+	case "alerts":
+		t = grafanaTarget{table: "alert_instances", timeColumn: "fired_at"}
+		allowed = map[string]string{"rule": "rule_name"}
+	default:
+		return nil, fmt.Errorf("unknown target %q", target)
+	}
+
+	if filter != "" {
+		key, value, ok := strings.Cut(filter, "=")
+		column, known := allowed[key]
+		if !ok || !known {
+			return nil, fmt.Errorf("unknown filter %q in target %q", filter, target)
+		}
+		t.condition = " AND " + column + " = ?"
+		t.arg = value
+	}
+
+	return &t, nil
+}
+
+func writeGrafanaJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// handleGrafanaTest answers the datasource's "Save & test" request
+func (s *Server) handleGrafanaTest(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/grafana/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Write([]byte("OK"))
+}
+
+// handleGrafanaSearch lists the targets, filtered by whatever the user has typed
+func (s *Server) handleGrafanaSearch(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Target string `json:"target"`
+	}
+	json.NewDecoder(r.Body).Decode(&req) // An empty body lists everything
+
+	targets := []string{"logs"}
+	db := s.storage.GetDB()
+	ctx, cancel := s.storage.WithTimeout(r.Context())
+	defer cancel()
+	for _, facet := range []string{"level", "service"} {
+		rows, err := db.QueryContext(ctx, "SELECT DISTINCT "+facet+" FROM logs WHERE "+facet+" IS NOT NULL AND "+facet+" != '' ORDER BY "+facet)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for rows.Next() {
+			var value string
+			if rows.Scan(&value) == nil {
+				targets = append(targets, "logs:"+facet+"="+value)
+			}
+		}
+		rows.Close()
+	}
+
+	targets = append(targets, "alerts")
+	for _, rule := range s.engine.GetRules() {
+		targets = append(targets, "alerts:rule="+rule.Name)
+	}
+
+	search := strings.ToLower(strings.TrimSpace(req.Target))
+	if search == "" || search == "select metric" {
+		writeGrafanaJSON(w, targets)
+		return
+	}
+
+	matches := []string{}
+	for _, target := range targets {
+		if strings.Contains(strings.ToLower(target), search) {
+			matches = append(matches, target)
+		}
+	}
+	writeGrafanaJSON(w, matches)
+}
+
+// handleGrafanaQuery returns a count per interval for each target
+func (s *Server) handleGrafanaQuery(w http.ResponseWriter, r *http.Request) {
+	var req grafanaQueryRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Range.From.IsZero() || req.Range.To.IsZero() {
+		http.Error(w, "range.from and range.to are required", http.StatusBadRequest)
+		return
+	}
+
+	// Bucket by the requested interval, widened so we never return more than maxDataPoints
+	span := req.Range.To.Unix() - req.Range.From.Unix()
+	bucket := req.IntervalMs / 1000
+	if req.MaxDataPoints > 0 && span/req.MaxDataPoints > bucket {
+		bucket = (span + req.MaxDataPoints - 1) / req.MaxDataPoints
+	}
+	if bucket < 1 {
+		bucket = 1
+	}
+
+	results := []interface{}{}
+	for _, t := range req.Targets {
+		if t.Target == "" {
+			continue
+		}
+
+		points, err := s.grafanaSeries(r.Context(), t.Target, req.Range, bucket)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if t.Type == "table" {
+			rows := make([][2]int64, len(points))
+			for i, p := range points {
+				rows[i] = [2]int64{p[1], p[0]}
+			}
+			results = append(results, map[string]interface{}{
+				"type": "table",
+				"columns": []map[string]string{
+					{"text": "Time", "type": "time"},
+					{"text": t.Target, "type": "number"},
+				},
+				"rows": rows,
+			})
+			continue
+		}
+
+		results = append(results, map[string]interface{}{
+			"target":     t.Target,
+			"refId":      t.RefID,
+			"datapoints": points,
+		})
+	}
+
+	writeGrafanaJSON(w, results)
+}
+
+// grafanaSeries counts a target's rows per bucket (in seconds), returning
+// Grafana datapoints: [count, unix milliseconds], with empty buckets as zero
+func (s *Server) grafanaSeries(ctx context.Context, target string, rng grafanaRange, bucket int64) ([][2]int64, error) {
+	t, err := parseGrafanaTarget(target)
+	if err != nil {
+		return nil, err
+	}
+
+	query := fmt.Sprintf(`SELECT CAST(strftime('%%s', %[1]s) AS INTEGER) / ? * ? AS bucket, COUNT(*)
+		FROM %[2]s WHERE %[1]s >= ? AND %[1]s <= ?%[3]s GROUP BY bucket`, t.timeColumn, t.table, t.condition)
+	args := []interface{}{bucket, bucket, rng.From.Local(), rng.To.Local()}
+	if t.arg != nil {
+		args = append(args, t.arg)
+	}
+
+	ctx, cancel := s.storage.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.storage.GetDB().QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int64]int64)
+	for rows.Next() {
+		var start, count int64
+		if err := rows.Scan(&start, &count); err != nil {
+			return nil, err
+		}
+		counts[start] = count
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	points := [][2]int64{}
+	for start := rng.From.Unix() / bucket * bucket; start <= rng.To.Unix(); start += bucket {
+		points = append(points, [2]int64{counts[start], start * 1000})
+	}
+	return points, nil
+}
+
+// handleGrafanaAnnotations returns alert firings in the range as annotations. The
+// annotation's query text, if set, limits them to rules with that name.
+func (s *Server) handleGrafanaAnnotations(w http.ResponseWriter, r *http.Request) {
+	var req grafanaAnnotationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "invalid request: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var settings struct {
+		Query string `json:"query"`
+	}
+	json.Unmarshal(req.Annotation, &settings)
+	ruleName := strings.TrimSpace(settings.Query)
+
+	instances, err := s.engine.ListAlertInstances(alerts.InstanceFilter{Since: req.Range.From, Limit: grafanaMaxAnnotations})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	annotations := []map[string]interface{}{}
+	for _, instance := range instances {
+		if (!req.Range.To.IsZero() && instance.FiredAt.After(req.Range.To)) || (ruleName != "" && instance.RuleName != ruleName) {
+			continue
+		}
+
+		annotation := map[string]interface{}{
+			"annotation": req.Annotation,
+			"time":       instance.FiredAt.UnixMilli(),
+			"title":      instance.RuleName,
+			"text":       fmt.Sprintf("%s fired: %d/%d events", instance.RuleName, instance.Count, instance.Threshold),
+			"tags":       []string{"peep", "alert", instance.State()},
+		}
+		if !instance.ResolvedAt.IsZero() {
+			annotation["timeEnd"] = instance.ResolvedAt.UnixMilli()
+		}
+		annotations = append(annotations, annotation)
+	}
+
+	writeGrafanaJSON(w, annotations)
+}
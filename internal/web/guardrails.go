@@ -0,0 +1,88 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/format"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// Guardrails for user-written SQL (the query console and dashboard panels): one
+// read-only statement, a time limit, and a cap on how much comes back.
+const (
+	defaultQueryTimeout   = 30 * time.Second
+	defaultMaxQueryRows   = 1000
+	defaultMaxResultBytes = 10 << 20
+)
+
+// SetQueryLimits sets how long a user query may run and how many rows and bytes
+// of results it may return. Zero keeps the default.
+func (s *Server) SetQueryLimits(timeout time.Duration, maxRows int, maxBytes int64) {
+	if timeout > 0 {
+		s.queryTimeout = timeout
+	}
+	if maxRows > 0 {
+		s.maxQueryRows = maxRows
+	}
+	if maxBytes > 0 {
+		s.maxResultBytes = maxBytes
+	}
+}
+
+// queryResult is a user query's output, cut off at the row and size limits
+type queryResult struct {
+	Columns   []string
+	Rows      [][]string
+	Raw       [][]interface{} // Typed values, for exports
+	Truncated string          // Why the rows stop short, if they do
+}
+
+// execQuery runs a user query within the guardrails and reads its results
+func (s *Server) execQuery(ctx context.Context, query string) (*queryResult, error) {
+	query, err := storage.ReadStatement(query)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, s.queryTimeout)
+	defer cancel()
+
+	result, err := s.scanQuery(ctx, query)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("query timed out after %s", s.queryTimeout)
+	}
+	return result, err
+}
+
+func (s *Server) scanQuery(ctx context.Context, query string) (*queryResult, error) {
+	rows, release, err := s.storage.QueryReadOnly(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+
+	// Some errors, like writes refused by query_only, only surface while stepping
+	results, raw, truncated, err := scanResults(rows, len(columns), s.maxQueryRows, s.maxResultBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &queryResult{Columns: columns, Rows: results, Raw: raw}
+	if truncated {
+		if len(results) >= s.maxQueryRows {
+			result.Truncated = fmt.Sprintf("Showing the first %d rows; add a LIMIT or narrow the query to see the rest", s.maxQueryRows)
+		} else {
+			result.Truncated = fmt.Sprintf("Results stopped at %s bytes; select fewer or smaller columns to see more", format.Count(s.maxResultBytes))
+		}
+	}
+	return result, nil
+}
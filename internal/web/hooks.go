@@ -0,0 +1,102 @@
+package web
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/ingestion"
+)
+
+// hooksPrefix is where services POST webhook payloads, as /hooks/NAME
+const hooksPrefix = "/hooks/"
+
+// maxHookBody caps a webhook payload
+const maxHookBody = 5 << 20
+
+// SetWebhooks replaces the inbound webhooks, e.g. after a config reload. On
+// error the current hooks are kept.
+func (s *Server) SetWebhooks(hooks []ingestion.Webhook) error {
+	if err := ingestion.ValidateWebhooks(hooks); err != nil {
+		return err
+	}
+	byName := make(map[string]ingestion.Webhook, len(hooks))
+	for _, hook := range hooks {
+		byName[hook.Name] = hook
+	}
+	s.hooksMu.Lock()
+	s.hooks = byName
+	s.hooksMu.Unlock()
+	return nil
+}
+
+// webhook returns the hook a path posts to
+func (s *Server) webhook(path string) (ingestion.Webhook, bool) {
+	name, ok := strings.CutPrefix(path, hooksPrefix)
+	if !ok {
+		return ingestion.Webhook{}, false
+	}
+	s.hooksMu.RLock()
+	defer s.hooksMu.RUnlock()
+	hook, ok := s.hooks[name]
+	return hook, ok
+}
+
+// hookAuthenticates reports whether a request is to a hook with a secret,
+// which the handler checks instead of a login: services like Stripe can't
+// log in, but can put a token in the URL
+func (s *Server) hookAuthenticates(path string) bool {
+	hook, ok := s.webhook(path)
+	return ok && hook.Secret != ""
+}
+
+func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request) {
+	hook, ok := s.webhook(r.URL.Path)
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if hook.Secret != "" {
+		token := r.URL.Query().Get("token")
+		if token == "" {
+			token = r.Header.Get("X-Peep-Token")
+		}
+		if !secureCompare(token, hook.Secret) {
+			slog.Warn("rejected webhook", "hook", hook.Name, "remote", r.RemoteAddr)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxHookBody))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to read request body: %v", err), http.StatusBadRequest)
+		return
+	}
+	entries, err := hook.Map(body, time.Now())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	for _, entry := range entries {
+		if err := s.storage.InsertLog(entry); err != nil {
+			http.Error(w, fmt.Sprintf("failed to store log: %v", err), http.StatusInternalServerError)
+			return
+		}
+		if err := s.deriver.Observe(entry); err != nil {
+			slog.Warn("failed to store derived metrics", "error", err)
+		}
+	}
+	s.storage.TriggerRetentionCheck()
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"ingested": %d}`, len(entries))
+}
@@ -0,0 +1,191 @@
+package web
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// The log table's layout: which columns it shows, including fields promoted from
+// each log's JSON context, and how it's sorted. The layout is saved in a cookie so
+// each browser keeps its own.
+
+// logColumn is a column the log table can show
+type logColumn struct {
+	Key   string // timestamp, level, service, message, raw_log, or context.<field>
+	Label string
+	Field string // The context field, for promoted columns
+}
+
+var builtinLogColumns = []logColumn{
+	{Key: "timestamp", Label: "Timestamp"},
+	{Key: "level", Label: "Level"},
+	{Key: "service", Label: "Service"},
+	{Key: "message", Label: "Message"},
+	{Key: "raw_log", Label: "Raw Log"},
+}
+
+const (
+	contextColumnPrefix = "context."
+	logLayoutCookie     = "peep_log_layout"
+	maxContextFields    = 50  // Context fields offered in the column menu
+	contextFieldSample  = 500 // Recent logs whose context keys are offered
+)
+
+// contextFieldPattern keeps promoted field names safe to put in a JSON path
+var contextFieldPattern = regexp.MustCompile(`^[A-Za-z0-9_.@-]{1,64}$`)
+
+// columnChoice is a checkbox in the column menu
+type columnChoice struct {
+	logColumn
+	Checked bool
+}
+
+// lookupLogColumn resolves a column key, reporting whether it's valid
+func lookupLogColumn(key string) (logColumn, bool) {
+	if field, ok := strings.CutPrefix(key, contextColumnPrefix); ok {
+		return logColumn{Key: key, Label: field, Field: field}, contextFieldPattern.MatchString(field)
+	}
+	for _, column := range builtinLogColumns {
+		if column.Key == key {
+			return column, true
+		}
+	}
+	return logColumn{}, false
+}
+
+// parseLogLayout reads the columns and sort order from the request, or, when the
+// request doesn't set them, from the layout cookie. Unknown columns are dropped.
+func parseLogLayout(r *http.Request) (columns []string, sort, order string) {
+	values := r.URL.Query()
+	if values.Get("layout") == "" {
+		if cookie, err := r.Cookie(logLayoutCookie); err == nil {
+			values, _ = url.ParseQuery(cookie.Value)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, key := range values["columns"] {
+		if _, ok := lookupLogColumn(key); ok && !seen[key] {
+			seen[key] = true
+			columns = append(columns, key)
+		}
+	}
+	if len(columns) == 0 {
+		for _, column := range builtinLogColumns {
+			columns = append(columns, column.Key)
+		}
+	}
+
+	sort, order = values.Get("sort"), values.Get("order")
+	if _, ok := lookupLogColumn(sort); !ok {
+		sort = "timestamp"
+	}
+	if order != "asc" {
+		order = "desc"
+	}
+	return columns, sort, order
+}
+
+// saveLogLayout remembers the layout for this browser when the request changed it
+func saveLogLayout(w http.ResponseWriter, r *http.Request, filter logFilter) {
+	if r.URL.Query().Get("layout") == "" {
+		return
+	}
+	value := url.Values{"columns": filter.Columns, "sort": {filter.Sort}, "order": {filter.Order}}
+	http.SetCookie(w, &http.Cookie{
+		Name:     logLayoutCookie,
+		Value:    value.Encode(),
+		Path:     "/",
+		Expires:  time.Now().AddDate(1, 0, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+// contextFieldPath is the JSON path of a promoted field
+func contextFieldPath(field string) string {
+	return `$."` + field + `"`
+}
+
+// orderBy returns the ORDER BY clause for the filter's sort, and its argument if any
+func (f logFilter) orderBy() (string, []interface{}) {
+	direction := " DESC"
+	if f.Order == "asc" {
+		direction = " ASC"
+	}
+
+	column, _ := lookupLogColumn(f.Sort)
+	if column.Field != "" {
+		return " ORDER BY json_extract(context, ?)" + direction + ", id" + direction, []interface{}{contextFieldPath(column.Field)}
+	}
+	// The timestamp ties often; id keeps the order stable
+	return " ORDER BY " + column.Key + direction + ", id" + direction, nil
+}
+
+// logColumns resolves the filter's visible columns
+func (f logFilter) logColumns() []logColumn {
+	columns := make([]logColumn, 0, len(f.Columns))
+	for _, key := range f.Columns {
+		column, _ := lookupLogColumn(key)
+		columns = append(columns, column)
+	}
+	return columns
+}
+
+// contextFields returns the promoted fields among the visible columns
+func (f logFilter) contextFields() []string {
+	var fields []string
+	for _, column := range f.logColumns() {
+		if column.Field != "" {
+			fields = append(fields, column.Field)
+		}
+	}
+	return fields
+}
+
+// getColumnChoices lists the column menu: the built-in columns, then context fields
+// found in recent logs plus any already promoted
+func (s *Server) getColumnChoices(ctx context.Context, filter logFilter) []columnChoice {
+	visible := make(map[string]bool)
+	for _, key := range filter.Columns {
+		visible[key] = true
+	}
+
+	var choices []columnChoice
+	seen := make(map[string]bool)
+	add := func(column logColumn) {
+		if !seen[column.Key] {
+			seen[column.Key] = true
+			choices = append(choices, columnChoice{logColumn: column, Checked: visible[column.Key]})
+		}
+	}
+
+	for _, column := range builtinLogColumns {
+		add(column)
+	}
+	for _, field := range filter.contextFields() {
+		add(logColumn{Key: contextColumnPrefix + field, Label: field, Field: field})
+	}
+
+	ctx, cancel := s.storage.WithTimeout(ctx)
+	defer cancel()
+	rows, err := s.storage.GetDB().QueryContext(ctx, `SELECT DISTINCT j.key FROM
+		(SELECT context FROM logs WHERE json_valid(context) AND json_type(context) = 'object' ORDER BY id DESC LIMIT ?) AS l, json_each(l.context) AS j
+		ORDER BY j.key LIMIT ?`, contextFieldSample, maxContextFields)
+	if err != nil {
+		return choices
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var field string
+		if rows.Scan(&field) == nil && contextFieldPattern.MatchString(field) {
+			add(logColumn{Key: contextColumnPrefix + field, Label: field, Field: field})
+		}
+	}
+	return choices
+}
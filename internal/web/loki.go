@@ -0,0 +1,261 @@
+package web
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/logql"
+)
+
+// Loki-compatible read API, enough for Grafana's Loki datasource to browse and
+// search Peep logs: /loki/api/v1/query_range, /labels, /label/{name}/values, /series
+
+const (
+	lokiDefaultLimit    = 100
+	lokiMaxLimit        = 5000
+	lokiDefaultLookback = time.Hour
+)
+
+// lokiStream is one entry in a "streams" result: a label set and its [timestamp, line] pairs
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// writeLokiJSON writes a successful Loki API response
+func writeLokiJSON(w http.ResponseWriter, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": "success",
+		"data":   data,
+	})
+}
+
+// parseLokiTime reads a Loki timestamp: Unix nanoseconds, (fractional) Unix seconds, or RFC3339
+func parseLokiTime(value string, fallback time.Time) (time.Time, error) {
+	if value == "" {
+		return fallback, nil
+	}
+	if n, err := strconv.ParseInt(value, 10, 64); err == nil {
+		// Anything this large is nanoseconds; smaller numbers are seconds
+		if n > 1e12 {
+			return time.Unix(0, n), nil
+		}
+		return time.Unix(n, 0), nil
+	}
+	if f, err := strconv.ParseFloat(value, 64); err == nil {
+		sec, frac := math.Modf(f)
+		return time.Unix(int64(sec), int64(frac*1e9)), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+// lokiRange reads the start and end parameters, defaulting to the last hour
+func lokiRange(r *http.Request) (start, end time.Time, err error) {
+	end, err = parseLokiTime(r.FormValue("end"), time.Now())
+	if err != nil {
+		return
+	}
+	start, err = parseLokiTime(r.FormValue("start"), end.Add(-lokiDefaultLookback))
+	return
+}
+
+// handleLokiQueryRange runs a LogQL log query and returns matching lines grouped into streams
+func (s *Server) handleLokiQueryRange(w http.ResponseWriter, r *http.Request) {
+	query, err := logql.Parse(r.FormValue("query"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start, end, err := lokiRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	limit := lokiDefaultLimit
+	if l := r.FormValue("limit"); l != "" {
+		if limit, err = strconv.Atoi(l); err != nil || limit <= 0 {
+			http.Error(w, "invalid limit", http.StatusBadRequest)
+			return
+		}
+		if limit > lokiMaxLimit {
+			limit = lokiMaxLimit
+		}
+	}
+
+	order := "DESC"
+	if r.FormValue("direction") == "forward" {
+		order = "ASC"
+	}
+
+	where, args := query.Where()
+	sqlQuery := "SELECT timestamp, level, service, " + logql.LineColumn + " FROM logs WHERE timestamp >= ? AND timestamp <= ?" +
+		where + " ORDER BY timestamp " + order + " LIMIT ?"
+	args = append([]interface{}{start, end}, args...)
+	args = append(args, limit)
+
+	ctx, cancel := s.storage.WithTimeout(r.Context())
+	defer cancel()
+	rows, err := s.storage.GetDB().QueryContext(ctx, sqlQuery, args...)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	// Streams come out in the order their first line was seen
+	streams := []*lokiStream{}
+	byLabels := make(map[string]*lokiStream)
+	for rows.Next() {
+		var timestamp time.Time
+		var level, service, line sql.NullString
+		if err := rows.Scan(&timestamp, &level, &service, &line); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		labels := lokiLabels(level.String, service.String)
+		key := level.String + "\x00" + service.String
+		stream, exists := byLabels[key]
+		if !exists {
+			stream = &lokiStream{Stream: labels, Values: [][2]string{}}
+			byLabels[key] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(timestamp.UnixNano(), 10), line.String})
+	}
+	if err := rows.Err(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeLokiJSON(w, map[string]interface{}{
+		"resultType": "streams",
+		"result":     streams,
+		"stats":      map[string]interface{}{},
+	})
+}
+
+// lokiLabels builds a stream's label set, leaving out empty labels as Loki does
+func lokiLabels(level, service string) map[string]string {
+	labels := make(map[string]string)
+	if level != "" {
+		labels["level"] = level
+	}
+	if service != "" {
+		labels["service"] = service
+	}
+	return labels
+}
+
+// handleLokiLabels lists the label names streams can have
+func (s *Server) handleLokiLabels(w http.ResponseWriter, r *http.Request) {
+	writeLokiJSON(w, logql.LabelNames)
+}
+
+// handleLokiLabelValues handles /loki/api/v1/label/{name}/values
+func (s *Server) handleLokiLabelValues(w http.ResponseWriter, r *http.Request) {
+	name, ok := strings.CutSuffix(strings.TrimPrefix(r.URL.Path, "/loki/api/v1/label/"), "/values")
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	values := []string{}
+	column, known := logql.Labels[name]
+	if !known {
+		writeLokiJSON(w, values)
+		return
+	}
+
+	start, end, err := lokiRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ctx, cancel := s.storage.WithTimeout(r.Context())
+	defer cancel()
+	rows, err := s.storage.GetDB().QueryContext(ctx,
+		"SELECT DISTINCT "+column+" FROM logs WHERE "+column+" IS NOT NULL AND "+column+" != '' AND timestamp >= ? AND timestamp <= ? ORDER BY "+column,
+		start, end)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var value string
+		if err := rows.Scan(&value); err == nil {
+			values = append(values, value)
+		}
+	}
+
+	writeLokiJSON(w, values)
+}
+
+// handleLokiSeries lists the label sets of streams matching any of the match[] selectors
+func (s *Server) handleLokiSeries(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	start, end, err := lokiRange(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	selectors := r.Form["match[]"]
+	if len(selectors) == 0 {
+		selectors = []string{"{}"}
+	}
+
+	series := []map[string]string{}
+	seen := make(map[string]bool)
+	ctx, cancel := s.storage.WithTimeout(r.Context())
+	defer cancel()
+	for _, selector := range selectors {
+		query, err := logql.Parse(selector)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		where, args := query.Where()
+		args = append([]interface{}{start, end}, args...)
+		rows, err := s.storage.GetDB().QueryContext(ctx,
+			"SELECT DISTINCT COALESCE(level, ''), COALESCE(service, '') FROM logs WHERE timestamp >= ? AND timestamp <= ?"+where, args...)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for rows.Next() {
+			var level, service string
+			if err := rows.Scan(&level, &service); err != nil {
+				continue
+			}
+			if key := level + "\x00" + service; !seen[key] {
+				seen[key] = true
+				series = append(series, lokiLabels(level, service))
+			}
+		}
+		rows.Close()
+	}
+
+	writeLokiJSON(w, series)
+}
@@ -0,0 +1,30 @@
+package web
+
+import (
+	"net/url"
+)
+
+// permalinkSkip are request parameters that shouldn't end up in a shared link
+var permalinkSkip = map[string]bool{"format": true, "limit": true}
+
+// permalink builds the URL that reproduces a view: the page path plus the non-empty
+// parameters that shaped it. htmx puts it in the address bar (HX-Replace-Url), so
+// copying the URL shares exactly what's on screen.
+func permalink(path string, params url.Values) string {
+	values := url.Values{}
+	for name, list := range params {
+		if permalinkSkip[name] {
+			continue
+		}
+		for _, value := range list {
+			if value != "" {
+				values.Add(name, value)
+			}
+		}
+	}
+
+	if len(values) == 0 {
+		return path
+	}
+	return path + "?" + values.Encode()
+}
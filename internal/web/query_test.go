@@ -0,0 +1,50 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+// executeQuery posts a query to the SQL console and returns the rendered results
+func executeQuery(t *testing.T, server *Server, query string) string {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodPost, "/query/execute", strings.NewReader(url.Values{"query": {query}}.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	w := httptest.NewRecorder()
+	server.handleQueryExecute(w, r)
+	if w.Code != http.StatusOK {
+		t.Fatalf("got status %d: %s", w.Code, w.Body.String())
+	}
+	return w.Body.String()
+}
+
+func TestQueryResultsEscaped(t *testing.T) {
+	server := newTestServer(t)
+
+	body := executeQuery(t, server, `SELECT '<script>alert(1)</script>' AS "<b>col</b>"`)
+	if strings.Contains(body, "<script>") || strings.Contains(body, "<b>") {
+		t.Errorf("results not escaped:\n%s", body)
+	}
+	if !strings.Contains(body, "1 rows returned") || !strings.Contains(body, "&lt;script&gt;") {
+		t.Errorf("missing result row:\n%s", body)
+	}
+
+	body = executeQuery(t, server, `SELECT * FROM "<img src=x>"`)
+	if !strings.Contains(body, "Query Error") || strings.Contains(body, "<img") {
+		t.Errorf("query error not escaped:\n%s", body)
+	}
+}
+
+func TestQueryResultsEmpty(t *testing.T) {
+	server := newTestServer(t)
+
+	if body := executeQuery(t, server, ""); !strings.Contains(body, "No query provided") {
+		t.Errorf("got %s, want the missing query notice", body)
+	}
+	if body := executeQuery(t, server, "SELECT * FROM logs"); !strings.Contains(body, "No results") {
+		t.Errorf("got %s, want the empty results notice", body)
+	}
+}
@@ -0,0 +1,49 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+)
+
+// SetReadOnly disables everything that changes state, so the UI can be shared
+// with people who should only look
+func (s *Server) SetReadOnly(readOnly bool) {
+	s.readOnly = readOnly
+}
+
+// readOnlyAllowed are the non-GET routes that don't change anything. SQL queries
+// can't write anyway (see storage.QueryReadOnly). Grafana posts its queries, and
+// Slack its slash commands.
+var readOnlyAllowed = map[string]bool{
+	"/login":               true,
+	"/logout":              true,
+	"/query/execute":       true,
+	"/query/explain":       true,
+	"/grafana/search":      true,
+	"/grafana/metrics":     true,
+	"/grafana/query":       true,
+	"/grafana/annotations": true,
+	slackCommandPath:       true,
+}
+
+// blockWrites refuses mutating requests, and the forms that lead to them, in read-only mode
+func (s *Server) blockWrites(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.readOnly && isWriteRequest(r) {
+			http.Error(w, "Forbidden: this server is read-only", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func isWriteRequest(r *http.Request) bool {
+	if r.Method != "GET" && r.Method != "HEAD" {
+		return !readOnlyAllowed[r.URL.Path]
+	}
+
+	// Rule, channel, and dashboard forms would only fail on submit
+	path := r.URL.Path
+	return path == "/alerts/rules/add" || path == "/alerts/channels/add" || path == "/dashboards/new" ||
+		((strings.HasPrefix(path, "/alerts/rules/") || strings.HasPrefix(path, "/dashboards/")) && strings.HasSuffix(path, "/edit"))
+}
@@ -0,0 +1,77 @@
+package web
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/kylereynolds/peep/internal/tokens"
+)
+
+// Role decides which parts of the web UI and API an identity can use
+type Role string
+
+const (
+	RoleAdmin  Role = "admin"  // Everything: SQL, alert rules and channels, dashboards, ingestion
+	RoleViewer Role = "viewer" // Browse logs and dashboards
+	RoleIngest Role = "ingest" // Send logs to /api/ingest, /api/ingest/bulk, and /hooks/, and traces to /v1/traces, and nothing else
+)
+
+// identity is who made a request and what they're allowed to do
+type identity struct {
+	user string
+	role Role
+}
+
+// roleForScope maps an API token's scope onto the role it acts with
+func roleForScope(scope tokens.Scope) Role {
+	switch scope {
+	case tokens.ScopeAdmin:
+		return RoleAdmin
+	case tokens.ScopeRead:
+		return RoleViewer
+	default:
+		return RoleIngest
+	}
+}
+
+// allows reports whether the role may make this request
+func (role Role) allows(r *http.Request) bool {
+	switch role {
+	case RoleAdmin:
+		return true
+	case RoleViewer:
+		return (r.Method == "GET" || r.Method == "HEAD" || readOnlyAllowed[r.URL.Path]) && viewerCanSee(r.URL.Path)
+	case RoleIngest:
+		return r.URL.Path == "/api/ingest" || r.URL.Path == "/api/ingest/bulk" || r.URL.Path == "/v1/traces" ||
+			strings.HasPrefix(r.URL.Path, hooksPrefix) || r.URL.Path == esPrefix || strings.HasPrefix(r.URL.Path, esPrefix+"/")
+	default:
+		return false
+	}
+}
+
+// viewerCanSee lists the pages and fragments behind browsing logs and dashboards
+func viewerCanSee(path string) bool {
+	switch path {
+	case "/", "/logs", "/logs/search", "/logs/stream", "/services", "/dashboards", "/api/stats", "/api/stats/stream", "/logout":
+		return true
+	}
+
+	switch {
+	case strings.HasPrefix(path, "/logs/"): // Log detail
+		return true
+	case strings.HasPrefix(path, "/loki/api/v1/"), strings.HasPrefix(path, "/grafana/"): // Grafana datasources
+		return true
+	case strings.HasPrefix(path, "/dashboards/"): // Dashboards and their panels, but not the builder
+		return path != "/dashboards/new" && !strings.HasSuffix(path, "/edit")
+	default:
+		return false
+	}
+}
+
+// currentRole returns the role for a request. With auth off, everyone is an admin.
+func currentRole(r *http.Request) Role {
+	if id, ok := r.Context().Value(userContextKey{}).(identity); ok {
+		return id.role
+	}
+	return RoleAdmin
+}
@@ -0,0 +1,132 @@
+package web
+
+import (
+	"context"
+	"database/sql"
+	"html/template"
+	"net/http"
+	"time"
+)
+
+// sparklineBuckets is how many points each service's volume sparkline has
+const sparklineBuckets = 24
+
+// serviceStats summarizes one service's logs. Volume, errors, and the sparkline
+// cover the selected range; Total and SizeBytes cover everything stored.
+type serviceStats struct {
+	Service   string // Empty for logs without a service
+	Volume    int
+	Errors    int
+	Total     int
+	SizeBytes int64 // Estimated from the stored text, not SQLite's page usage
+	LastSeen  time.Time
+	Sparkline template.HTML
+}
+
+// ErrorRate is the percentage of the range's logs at error level
+func (st serviceStats) ErrorRate() float64 {
+	if st.Volume == 0 {
+		return 0
+	}
+	return float64(st.Errors) * 100 / float64(st.Volume)
+}
+
+// SinceLastSeen is how long ago the service last logged
+func (st serviceStats) SinceLastSeen() time.Duration {
+	return time.Since(st.LastSeen)
+}
+
+// handleServices lists every service with its log volume and error rate
+func (s *Server) handleServices(w http.ResponseWriter, r *http.Request) {
+	rangeKey := r.URL.Query().Get("range")
+	window, ok := timeRangePresets[rangeKey]
+	if !ok {
+		rangeKey, window = "24h", timeRangePresets["24h"]
+	}
+
+	services, err := s.getServiceStats(r.Context(), time.Now().Add(-window), window)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Services []serviceStats
+		Range    string
+	}{
+		Services: services,
+		Range:    rangeKey,
+	}
+
+	s.renderPage(w, r, "services", PageData{Title: "Services - Peep", Active: "services", Content: data})
+}
+
+// getServiceStats aggregates logs per service, busiest in the window first
+func (s *Server) getServiceStats(ctx context.Context, since time.Time, window time.Duration) ([]serviceStats, error) {
+	db := s.storage.GetDB()
+	ctx, cancel := s.storage.WithTimeout(ctx)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, `SELECT COALESCE(service, ''),
+			SUM(timestamp >= ?),
+			SUM(timestamp >= ? AND level = 'error'),
+			COUNT(*),
+			SUM(LENGTH(COALESCE(message, '')) + LENGTH(COALESCE(raw_log, '')) + LENGTH(COALESCE(context, ''))),
+			MAX(timestamp)
+		FROM logs GROUP BY 1 ORDER BY 2 DESC, 4 DESC`, since.Local(), since.Local())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var services []serviceStats
+	index := make(map[string]int)
+	for rows.Next() {
+		var st serviceStats
+		var lastSeen sql.NullString
+		if err := rows.Scan(&st.Service, &st.Volume, &st.Errors, &st.Total, &st.SizeBytes, &lastSeen); err != nil {
+			return nil, err
+		}
+		if lastSeen.Valid {
+			st.LastSeen, _ = parseTimeValue(lastSeen.String)
+		}
+		index[st.Service] = len(services)
+		services = append(services, st)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// Volume per service and bucket for the sparklines
+	bucket := int64(window/time.Second) / sparklineBuckets
+	counts := make(map[string][]int, len(services))
+	for service := range index {
+		counts[service] = make([]int, sparklineBuckets)
+	}
+
+	rows, err = db.QueryContext(ctx, `SELECT COALESCE(service, ''), (CAST(strftime('%s', timestamp) AS INTEGER) - ?) / ? AS bucket, COUNT(*)
+		FROM logs WHERE timestamp >= ? GROUP BY 1, 2`, since.Unix(), bucket, since.Local())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var service string
+		var i, count int
+		if err := rows.Scan(&service, &i, &count); err != nil {
+			return nil, err
+		}
+		if series, ok := counts[service]; ok && i >= 0 {
+			series[min(i, sparklineBuckets-1)] += count
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	for service, i := range index {
+		services[i].Sparkline = renderSparkline(counts[service])
+	}
+	return services, nil
+}
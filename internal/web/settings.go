@@ -0,0 +1,141 @@
+package web
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// agentRow is an agent on the settings page
+type agentRow struct {
+	storage.Agent
+	SinceLastSeen time.Duration
+}
+
+// agentStaleAfter is how long without a batch or heartbeat before an agent is shown as stale
+const agentStaleAfter = 5 * time.Minute
+
+// ShortID is enough of the agent's ID to tell apart agents with the same name
+func (a agentRow) ShortID() string {
+	if len(a.ID) > 8 {
+		return a.ID[:8]
+	}
+	return a.ID
+}
+
+// Stale reports whether the agent has gone quiet
+func (a agentRow) Stale() bool {
+	return a.SinceLastSeen > agentStaleAfter
+}
+
+// handleSettings shows the retention settings and saves changes to them. The
+// settings live in the database, where a running `peep daemon` picks them up.
+func (s *Server) handleSettings(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case "GET":
+		config, updatedAt, err := s.storage.LoadRetentionConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		data := struct {
+			Retention  storage.RetentionConfig
+			MaxAgeDays int
+			CheckMins  int
+			UpdatedAt  time.Time // Zero if the defaults have never been changed
+			LogCount   int
+			SizeMB     float64
+			Agents     []agentRow
+		}{
+			Retention:  config,
+			MaxAgeDays: int(config.MaxAge / (24 * time.Hour)),
+			CheckMins:  int(config.CheckInterval / time.Minute),
+			UpdatedAt:  updatedAt,
+		}
+
+		db := s.storage.GetDB()
+		ctx, cancel := s.storage.WithTimeout(r.Context())
+		defer cancel()
+		db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs").Scan(&data.LogCount)
+		var pages, pageSize int64
+		db.QueryRowContext(ctx, "PRAGMA page_count").Scan(&pages)
+		db.QueryRowContext(ctx, "PRAGMA page_size").Scan(&pageSize)
+		data.SizeMB = float64(pages*pageSize) / (1024 * 1024)
+
+		agents, err := s.storage.ListAgents()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		for _, agent := range agents {
+			data.Agents = append(data.Agents, agentRow{Agent: agent, SinceLastSeen: time.Since(agent.LastSeen)})
+		}
+
+		s.renderPage(w, r, "settings", PageData{Title: "Settings - Peep", Active: "settings", Content: data})
+
+	case "POST":
+		config, err := parseRetentionForm(r)
+		if err != nil {
+			writeFormError(w, err.Error())
+			return
+		}
+
+		if err := s.storage.SaveRetentionConfig(config); err != nil {
+			writeFormError(w, "Error saving settings: "+err.Error())
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte(`<div class="notice notice-success">
+			✅ Settings saved! A running <code>peep daemon</code> applies them within 30 seconds.
+		</div>`))
+
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// parseRetentionForm reads the retention form; empty numbers mean no limit
+func parseRetentionForm(r *http.Request) (storage.RetentionConfig, error) {
+	number := func(name, label string) (float64, error) {
+		value := strings.TrimSpace(r.FormValue(name))
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s must be a number", label)
+		}
+		return n, nil
+	}
+
+	maxLogs, err := number("max_logs", "Max logs")
+	if err != nil {
+		return storage.RetentionConfig{}, err
+	}
+	maxAgeDays, err := number("max_age_days", "Max age")
+	if err != nil {
+		return storage.RetentionConfig{}, err
+	}
+	maxSizeMB, err := number("max_size_mb", "Max size")
+	if err != nil {
+		return storage.RetentionConfig{}, err
+	}
+	checkMins, err := number("check_mins", "Check interval")
+	if err != nil {
+		return storage.RetentionConfig{}, err
+	}
+
+	return storage.RetentionConfig{
+		MaxLogs:       int(maxLogs),
+		MaxAge:        time.Duration(maxAgeDays * float64(24*time.Hour)),
+		MaxSizeMB:     maxSizeMB,
+		CheckInterval: time.Duration(checkMins * float64(time.Minute)),
+		Enabled:       r.FormValue("enabled") == "on",
+	}, nil
+}
@@ -0,0 +1,513 @@
+package web
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/notifications"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// The Slack slash command (/peep errors api 1h) posts to slackCommandPath,
+// and clicks on alert message buttons to slackActionsPath. Slack can't log
+// in, so instead of the usual auth the request must carry Slack's signature:
+// an HMAC of the body keyed with the app's signing secret. The commands only
+// run fixed read-only queries, so the channel sees answers, not a SQL
+// console; the only action that changes anything is acknowledging an alert.
+const (
+	slackCommandPath = "/slack/commands"
+	slackActionsPath = "/slack/actions"
+
+	// slackMaxSkew is how old a signed request may be, so a captured one can't be replayed later
+	slackMaxSkew = 5 * time.Minute
+
+	// slackTimeout keeps queries inside the 3 seconds Slack waits for a reply
+	slackTimeout = 2500 * time.Millisecond
+
+	slackDefaultWindow = time.Hour
+	slackMaxRows       = 10
+
+	// slackResponseHost is where Slack's response URLs point; others aren't followed
+	slackResponseHost = "hooks.slack.com"
+
+	// slackMaxBody caps a signed request. Button clicks carry the whole
+	// message they were clicked in, blocks and all, so they're well over the
+	// size of a slash command; larger ones are refused rather than cut short,
+	// which would only fail the signature check.
+	slackMaxBody = 1 << 20
+)
+
+// slackResponseClient posts replies to response URLs, inside the time Slack waits
+var slackResponseClient = &http.Client{Timeout: slackTimeout}
+
+// SetSlackSigningSecret enables the Slack slash command and button endpoints,
+// accepting requests signed with the Slack app's signing secret
+func (s *Server) SetSlackSigningSecret(secret string) {
+	s.slackSecret = secret
+}
+
+// slackReply is the JSON a slash command responds with
+type slackReply struct {
+	ResponseType string                     `json:"response_type"` // "in_channel" or "ephemeral" (only the caller sees it)
+	Text         string                     `json:"text"`
+	Blocks       []notifications.SlackBlock `json:"blocks,omitempty"`
+}
+
+func (s *Server) handleSlackCommand(w http.ResponseWriter, r *http.Request) {
+	form, ok := s.slackForm(w, r)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), slackTimeout)
+	defer cancel()
+	reply := s.runSlackCommand(ctx, form.Get("command"), strings.Fields(form.Get("text")), requestBaseURL(r))
+	slog.Info("slack command", "user", form.Get("user_name"), "channel", form.Get("channel_name"), "text", form.Get("text"))
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(reply)
+}
+
+// slackForm reads a signed request from Slack, replying with an error and
+// reporting false if it isn't one
+func (s *Server) slackForm(w http.ResponseWriter, r *http.Request) (url.Values, bool) {
+	if s.slackSecret == "" {
+		http.NotFound(w, r)
+		return nil, false
+	}
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, false
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, slackMaxBody))
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		http.Error(w, fmt.Sprintf("Request is over %d KB", slackMaxBody>>10), http.StatusRequestEntityTooLarge)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Failed to read request", http.StatusBadRequest)
+		return nil, false
+	}
+	if err := verifySlackSignature(s.slackSecret, r.Header, body, time.Now()); err != nil {
+		slog.Warn("rejected slack request", "path", r.URL.Path, "error", err, "remote", r.RemoteAddr)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return nil, false
+	}
+	return form, true
+}
+
+// slackInteraction is the part of a button click's payload peep uses
+type slackInteraction struct {
+	Type string `json:"type"`
+	User struct {
+		ID       string `json:"id"`
+		Username string `json:"username"`
+	} `json:"user"`
+	Actions []struct {
+		ActionID string `json:"action_id"`
+		Value    string `json:"value"`
+	} `json:"actions"`
+	ResponseURL string `json:"response_url"`
+}
+
+// handleSlackAction handles clicks on alert message buttons. Ack acknowledges
+// the alert as the Slack user and says so in the channel; View in Peep is a
+// link, which Slack reports too, so other actions are accepted and ignored.
+func (s *Server) handleSlackAction(w http.ResponseWriter, r *http.Request) {
+	form, ok := s.slackForm(w, r)
+	if !ok {
+		return
+	}
+	var interaction slackInteraction
+	if err := json.Unmarshal([]byte(form.Get("payload")), &interaction); err != nil {
+		http.Error(w, "Invalid payload", http.StatusBadRequest)
+		return
+	}
+
+	for _, action := range interaction.Actions {
+		if action.ActionID != notifications.SlackAckAction {
+			continue
+		}
+		reply := s.slackAck(action.Value, interaction.User.ID, interaction.User.Username)
+		slog.Info("slack action", "action", action.ActionID, "alert", action.Value, "user", interaction.User.Username)
+		if err := postSlackResponse(r.Context(), interaction.ResponseURL, reply); err != nil {
+			slog.Warn("failed to reply to slack action", "error", err)
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// slackAck acknowledges the alert a button was for, returning the reply to post
+func (s *Server) slackAck(value, userID, username string) slackReply {
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err == nil {
+		err = s.engine.AcknowledgeAlert(id, "slack:"+username)
+	}
+	if err != nil {
+		return slackReply{ResponseType: "ephemeral", Text: "⚠️ Couldn't acknowledge the alert: " + err.Error()}
+	}
+
+	text := fmt.Sprintf("👀 <@%s> acknowledged this alert", userID)
+	if instance, err := s.engine.GetAlertInstance(id); err == nil {
+		text = fmt.Sprintf("👀 <@%s> acknowledged %s", userID, slackEscape(instance.RuleName))
+	}
+	return slackReply{ResponseType: "in_channel", Text: text}
+}
+
+// postSlackResponse posts reply to a Slack response URL, as a new message
+// rather than in place of the alert
+func postSlackResponse(ctx context.Context, responseURL string, reply slackReply) error {
+	if responseURL == "" {
+		return nil
+	}
+	target, err := url.Parse(responseURL)
+	if err != nil || target.Scheme != "https" || target.Host != slackResponseHost {
+		return fmt.Errorf("response URL %q isn't Slack's", responseURL)
+	}
+
+	body, err := json.Marshal(struct {
+		slackReply
+		ReplaceOriginal bool `json:"replace_original"`
+	}{slackReply: reply})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, responseURL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := slackResponseClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("slack returned %s", resp.Status)
+	}
+	return nil
+}
+
+// verifySlackSignature checks the X-Slack-Signature header, which is
+// v0=HMAC-SHA256(secret, "v0:" + timestamp + ":" + body) in hex
+func verifySlackSignature(secret string, header http.Header, body []byte, now time.Time) error {
+	timestamp := header.Get("X-Slack-Request-Timestamp")
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("missing or invalid request timestamp")
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > slackMaxSkew || skew < -slackMaxSkew {
+		return fmt.Errorf("request timestamp is %s off", skew.Round(time.Second))
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:", timestamp)
+	mac.Write(body)
+	expected := "v0=" + hex.EncodeToString(mac.Sum(nil))
+	if !hmac.Equal([]byte(expected), []byte(header.Get("X-Slack-Signature"))) {
+		return fmt.Errorf("signature doesn't match")
+	}
+	return nil
+}
+
+// requestBaseURL is the scheme and host the request was sent to, behind a
+// TLS-terminating proxy too, for links back to the UI
+func requestBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// runSlackCommand answers the words after the slash command
+func (s *Server) runSlackCommand(ctx context.Context, command string, args []string, baseURL string) slackReply {
+	if command == "" {
+		command = "/peep"
+	}
+	if len(args) == 0 {
+		return slackHelp(command)
+	}
+
+	var (
+		reply slackReply
+		err   error
+	)
+	switch strings.ToLower(args[0]) {
+	case "errors":
+		reply, err = s.slackErrors(ctx, args[1:], baseURL)
+	case "services":
+		reply, err = s.slackServices(ctx, args[1:])
+	case "search":
+		reply, err = s.slackSearch(ctx, args[1:], baseURL)
+	case "alerts":
+		reply, err = s.slackAlerts(baseURL)
+	case "help":
+		return slackHelp(command)
+	default:
+		reply := slackHelp(command)
+		reply.Text = fmt.Sprintf("Unknown command %q.\n\n%s", args[0], reply.Text)
+		return reply
+	}
+
+	if err != nil {
+		if ctx.Err() != nil {
+			err = fmt.Errorf("the query took too long; try a shorter window")
+		}
+		return slackReply{ResponseType: "ephemeral", Text: "⚠️ " + err.Error()}
+	}
+	return reply
+}
+
+// slackHelp lists the commands, shown only to the caller
+func slackHelp(command string) slackReply {
+	text := strings.Join([]string{
+		"*Peep commands*",
+		fmt.Sprintf("`%s errors [SERVICE] [WINDOW]` — error count and the most frequent errors", command),
+		fmt.Sprintf("`%s services [WINDOW]` — logs and errors per service", command),
+		fmt.Sprintf("`%s search TEXT [WINDOW]` — the latest logs containing TEXT", command),
+		fmt.Sprintf("`%s alerts` — alerts that are firing", command),
+		"WINDOW is how far back to look, like `30m`, `6h`, or `2d` (default `1h`).",
+	}, "\n")
+	return slackReply{ResponseType: "ephemeral", Text: text}
+}
+
+// slackWindow takes a trailing window like 1h or 2d off args. A last word
+// that isn't one stays in args, e.g. as part of a search.
+func slackWindow(args []string) ([]string, time.Duration) {
+	if len(args) == 0 {
+		return args, slackDefaultWindow
+	}
+	last := args[len(args)-1]
+	var window time.Duration
+	var err error
+	if days, ok := strings.CutSuffix(last, "d"); ok {
+		var n int
+		n, err = strconv.Atoi(days)
+		window = time.Duration(n) * 24 * time.Hour
+	} else {
+		window, err = time.ParseDuration(last)
+	}
+	if err != nil || window <= 0 {
+		return args, slackDefaultWindow
+	}
+	return args[:len(args)-1], window
+}
+
+// formatWindow prints a window the way it's typed
+func formatWindow(d time.Duration) string {
+	switch {
+	case d%(24*time.Hour) == 0:
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	case d%time.Hour == 0:
+		return fmt.Sprintf("%dh", d/time.Hour)
+	case d%time.Minute == 0:
+		return fmt.Sprintf("%dm", d/time.Minute)
+	}
+	return d.String()
+}
+
+// slackErrors counts errors in the window and lists the most frequent messages
+func (s *Server) slackErrors(ctx context.Context, args []string, baseURL string) (slackReply, error) {
+	args, window := slackWindow(args)
+	if len(args) > 1 {
+		return slackReply{}, fmt.Errorf("usage: errors [SERVICE] [WINDOW]")
+	}
+	service := ""
+	if len(args) == 1 {
+		service = args[0]
+	}
+	since := time.Now().Add(-window)
+
+	where := "level IN ('error', 'fatal') AND timestamp >= ?"
+	params := []interface{}{since.Local()}
+	if service != "" {
+		where += " AND service = ?"
+		params = append(params, service)
+	}
+
+	db := s.storage.GetDB()
+	var total int
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs WHERE "+where, params...).Scan(&total); err != nil {
+		return slackReply{}, err
+	}
+	rows, err := db.QueryContext(ctx, `
+		SELECT COUNT(*), COALESCE(service, ''), COALESCE(message, '') FROM logs WHERE `+where+`
+		GROUP BY service, message ORDER BY COUNT(*) DESC LIMIT ?`, append(params, slackMaxRows)...)
+	if err != nil {
+		return slackReply{}, err
+	}
+	defer rows.Close()
+	var table [][]string
+	for rows.Next() {
+		var count int
+		var svc, message string
+		if err := rows.Scan(&count, &svc, &message); err != nil {
+			return slackReply{}, err
+		}
+		table = append(table, []string{strconv.Itoa(count), svc, truncateText(message, 80)})
+	}
+	if err := rows.Err(); err != nil {
+		return slackReply{}, err
+	}
+
+	scope := "all services"
+	if service != "" {
+		scope = service
+	}
+	title := fmt.Sprintf("%d errors in %s, last %s", total, scope, formatWindow(window))
+	if total == 0 {
+		return slackReply{ResponseType: "in_channel", Text: "✅ No " + strings.TrimPrefix(title, "0 ")}, nil
+	}
+
+	link := url.Values{"level": {"error"}, "from": {since.Format(datetimeLocalLayout)}}
+	if service != "" {
+		link.Set("service", service)
+	}
+	return slackTableReply("🔴 "+title, []string{"COUNT", "SERVICE", "MESSAGE"}, table, baseURL+"/logs?"+link.Encode()), nil
+}
+
+// slackServices lists log and error counts per service in the window
+func (s *Server) slackServices(ctx context.Context, args []string) (slackReply, error) {
+	args, window := slackWindow(args)
+	if len(args) > 0 {
+		return slackReply{}, fmt.Errorf("usage: services [WINDOW]")
+	}
+
+	rows, err := s.storage.GetDB().QueryContext(ctx, `
+		SELECT COALESCE(service, ''), COUNT(*), SUM(level IN ('error', 'fatal')), SUM(level IN ('warn', 'warning'))
+		FROM logs WHERE timestamp >= ? GROUP BY service ORDER BY COUNT(*) DESC LIMIT ?`,
+		time.Now().Add(-window).Local(), slackMaxRows)
+	if err != nil {
+		return slackReply{}, err
+	}
+	defer rows.Close()
+	var table [][]string
+	for rows.Next() {
+		var service string
+		var logs, errors, warnings int
+		if err := rows.Scan(&service, &logs, &errors, &warnings); err != nil {
+			return slackReply{}, err
+		}
+		table = append(table, []string{service, strconv.Itoa(logs), strconv.Itoa(errors), strconv.Itoa(warnings)})
+	}
+	if err := rows.Err(); err != nil {
+		return slackReply{}, err
+	}
+
+	if len(table) == 0 {
+		return slackReply{ResponseType: "in_channel", Text: fmt.Sprintf("No logs in the last %s", formatWindow(window))}, nil
+	}
+	return slackTableReply(fmt.Sprintf("📊 Services, last %s", formatWindow(window)),
+		[]string{"SERVICE", "LOGS", "ERRORS", "WARNINGS"}, table, ""), nil
+}
+
+// slackSearch lists the latest logs containing some text
+func (s *Server) slackSearch(ctx context.Context, args []string, baseURL string) (slackReply, error) {
+	args, window := slackWindow(args)
+	if len(args) == 0 {
+		return slackReply{}, fmt.Errorf("usage: search TEXT [WINDOW]")
+	}
+	text := strings.Join(args, " ")
+	since := time.Now().Add(-window)
+
+	logs, err := s.storage.GetFilteredLogs(ctx, storage.LogFilter{Search: text, Since: since}, slackMaxRows)
+	if err != nil {
+		return slackReply{}, err
+	}
+	if len(logs) == 0 {
+		return slackReply{ResponseType: "in_channel", Text: fmt.Sprintf("No logs containing %q in the last %s", text, formatWindow(window))}, nil
+	}
+
+	table := make([][]string, len(logs))
+	for i, entry := range logs {
+		table[i] = []string{entry.Timestamp.Local().Format("15:04:05"), entry.Level, entry.Service, truncateText(entry.Message, 80)}
+	}
+	link := url.Values{"search": {text}, "from": {since.Format(datetimeLocalLayout)}}
+	return slackTableReply(fmt.Sprintf("🔎 Latest logs containing %q, last %s", text, formatWindow(window)),
+		[]string{"TIME", "LEVEL", "SERVICE", "MESSAGE"}, table, baseURL+"/logs?"+link.Encode()), nil
+}
+
+// slackAlerts lists the alerts that haven't resolved
+func (s *Server) slackAlerts(baseURL string) (slackReply, error) {
+	instances, err := s.engine.ListAlertInstances(alerts.InstanceFilter{Unresolved: true, Limit: slackMaxRows})
+	if err != nil {
+		return slackReply{}, err
+	}
+	if len(instances) == 0 {
+		return slackReply{ResponseType: "in_channel", Text: "✅ No alerts firing"}, nil
+	}
+
+	table := make([][]string, len(instances))
+	for i, instance := range instances {
+		table[i] = []string{instance.FiredAt.Local().Format("Jan 2 15:04"), instance.State(), instance.RuleName,
+			fmt.Sprintf("%d/%d", instance.Count, instance.Threshold)}
+	}
+	return slackTableReply(fmt.Sprintf("🚨 %d alerts firing", len(instances)),
+		[]string{"FIRED", "STATE", "RULE", "COUNT"}, table, baseURL+"/alerts/history"), nil
+}
+
+// slackTableReply posts rows as an aligned table, with a button to see more in Peep
+func slackTableReply(title string, columns []string, rows [][]string, link string) slackReply {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(columns, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	w.Flush()
+	// Section text is capped at 3000 characters
+	table := truncateText(slackEscape(strings.TrimRight(buf.String(), "\n")), 2900)
+
+	blocks := []notifications.SlackBlock{
+		{Type: "section", Text: &notifications.SlackText{Type: "mrkdwn", Text: "*" + slackEscape(title) + "*"}},
+		{Type: "section", Text: &notifications.SlackText{Type: "mrkdwn", Text: "```" + table + "```"}},
+	}
+	if link != "" {
+		blocks = append(blocks, notifications.SlackBlock{Type: "actions", Elements: []interface{}{
+			notifications.SlackButton{
+				Type:     "button",
+				Text:     &notifications.SlackText{Type: "plain_text", Text: "View in Peep"},
+				ActionID: "peep_view",
+				URL:      link,
+			},
+		}})
+	}
+	return slackReply{ResponseType: "in_channel", Text: title, Blocks: blocks}
+}
+
+// slackEscape escapes the characters Slack treats as markup
+func slackEscape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
+
+// truncateText shortens s to at most n runes, marking the cut with an ellipsis
+func truncateText(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n-1]) + "…"
+}
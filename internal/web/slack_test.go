@@ -0,0 +1,142 @@
+package web
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+const testSlackSecret = "8f742231b10e8888abcd99yyyzzz85a5"
+
+// newTestServer returns a server on a fresh database with the Slack endpoints on
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	store, err := storage.NewStorage(filepath.Join(t.TempDir(), "logs.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Alerts fired by the tests go nowhere instead of to the desktop
+	for _, channel := range engine.GetChannels() {
+		if err := engine.DeleteChannel(channel.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	server := NewServer(store, engine)
+	server.SetSlackSigningSecret(testSlackSecret)
+	return server
+}
+
+// firingAlert fires a rule and returns its alert
+func firingAlert(t *testing.T, server *Server) *alerts.AlertInstance {
+	t.Helper()
+	rule := &alerts.AlertRule{Name: "API errors", Query: "SELECT COUNT(*) FROM logs WHERE level = 'error'", Threshold: 1, Window: "5m", Enabled: true}
+	if err := server.engine.AddRule(rule); err != nil {
+		t.Fatal(err)
+	}
+	if err := server.storage.InsertLog(storage.LogEntry{Timestamp: time.Now(), Level: "error", Message: "boom"}); err != nil {
+		t.Fatal(err)
+	}
+	server.engine.CheckRules(context.Background(), true)
+
+	instances, err := server.engine.ListAlertInstances(alerts.InstanceFilter{Unresolved: true})
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("got %d alerts, %v, want 1", len(instances), err)
+	}
+	return instances[0]
+}
+
+// slackRequest builds a request to path signed the way Slack signs them
+func slackRequest(path, body, secret string) *http.Request {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "v0:%s:%s", timestamp, body)
+
+	r := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	r.Header.Set("X-Slack-Request-Timestamp", timestamp)
+	r.Header.Set("X-Slack-Signature", "v0="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func TestSlackAckButton(t *testing.T) {
+	server := newTestServer(t)
+	handler := server.routes()
+	alert := firingAlert(t, server)
+
+	payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U123","username":"alice"},
+		"actions":[{"action_id":"peep_ack","value":"%d"}]}`, alert.ID)
+	body := url.Values{"payload": {payload}}.Encode()
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, slackRequest(slackActionsPath, body, "wrong secret"))
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("unsigned click got %d, want 401", w.Code)
+	}
+	if got, _ := server.engine.GetAlertInstance(alert.ID); got.State() == alerts.StateAcknowledged {
+		t.Fatal("an unsigned click acknowledged the alert")
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, slackRequest(slackActionsPath, body, testSlackSecret))
+	if w.Code != http.StatusOK {
+		t.Fatalf("click got %d: %s", w.Code, w.Body)
+	}
+	got, err := server.engine.GetAlertInstance(alert.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.State() != alerts.StateAcknowledged || got.AcknowledgedBy != "slack:alice" {
+		t.Errorf("alert is %s by %q, want acknowledged by slack:alice", got.State(), got.AcknowledgedBy)
+	}
+}
+
+// Clicks repeat the whole message, so they're read in full up to slackMaxBody
+// and refused beyond it
+func TestSlackActionBodyLimit(t *testing.T) {
+	server := newTestServer(t)
+	handler := server.routes()
+	alert := firingAlert(t, server)
+
+	click := func(messageSize int) *httptest.ResponseRecorder {
+		payload := fmt.Sprintf(`{"type":"block_actions","user":{"id":"U123","username":"alice"},
+			"message":{"text":%q},"actions":[{"action_id":"peep_ack","value":"%d"}]}`, strings.Repeat("x", messageSize), alert.ID)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, slackRequest(slackActionsPath, url.Values{"payload": {payload}}.Encode(), testSlackSecret))
+		return w
+	}
+
+	if w := click(slackMaxBody); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("oversized click got %d, want 413", w.Code)
+	}
+	if w := click(100 << 10); w.Code != http.StatusOK {
+		t.Errorf("100 KB click got %d: %s", w.Code, w.Body)
+	}
+}
+
+// Replies only go to Slack, whatever URL a payload names
+func TestPostSlackResponseOnlyToSlack(t *testing.T) {
+	for _, target := range []string{"http://hooks.slack.com/actions/1", "https://example.com/actions/1", "https://hooks.slack.com.example.com/x"} {
+		if err := postSlackResponse(context.Background(), target, slackReply{Text: "hi"}); err == nil {
+			t.Errorf("posted to %s", target)
+		}
+	}
+}
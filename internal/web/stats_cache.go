@@ -0,0 +1,73 @@
+package web
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// defaultStatsCacheTTL is how long the dashboard reuses its log counts
+const defaultStatsCacheTTL = 10 * time.Second
+
+// SetStatsCacheTTL sets how long the dashboard's log counts are reused before
+// they're counted again. Zero keeps the default; a negative TTL counts on
+// every request.
+func (s *Server) SetStatsCacheTTL(ttl time.Duration) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	if ttl == 0 {
+		ttl = defaultStatsCacheTTL
+	}
+	s.stats.ttl = ttl
+	s.stats.countedAt = time.Time{}
+}
+
+// statsCache holds the dashboard's log counts. Each count scans the logs
+// table, so on a large database page views, stats polls, and every open
+// dashboard stream share one count per TTL instead of each running their own.
+type statsCache struct {
+	mu        sync.Mutex // Held while counting, so concurrent requests wait for one count
+	ttl       time.Duration
+	counts    logCounts
+	countedAt time.Time
+}
+
+// logCounts are the dashboard's stat cards
+type logCounts struct {
+	Total    int64
+	Errors   int64 // Last 24 hours
+	Warnings int64 // Last 24 hours
+}
+
+// getLogCounts returns the dashboard's log counts, from the cache while they're fresh
+func (s *Server) getLogCounts(ctx context.Context) (logCounts, error) {
+	s.stats.mu.Lock()
+	defer s.stats.mu.Unlock()
+	if s.stats.ttl > 0 && time.Since(s.stats.countedAt) < s.stats.ttl {
+		return s.stats.counts, nil
+	}
+
+	counts, err := s.countLogs(ctx)
+	if err != nil {
+		return counts, err
+	}
+	s.stats.counts, s.stats.countedAt = counts, time.Now()
+	return counts, nil
+}
+
+// countLogs counts the logs for the dashboard's stat cards
+func (s *Server) countLogs(ctx context.Context) (logCounts, error) {
+	db := s.storage.GetDB()
+	ctx, cancel := s.storage.WithTimeout(ctx)
+	defer cancel()
+
+	var counts logCounts
+	if err := db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs").Scan(&counts.Total); err != nil {
+		return counts, err
+	}
+
+	// The level counts are best-effort; a failure shows as zero
+	db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs WHERE level = 'error' AND timestamp >= datetime('now', '-24 hours')").Scan(&counts.Errors)
+	db.QueryRowContext(ctx, "SELECT COUNT(*) FROM logs WHERE level = 'warning' AND timestamp >= datetime('now', '-24 hours')").Scan(&counts.Warnings)
+	return counts, nil
+}
@@ -0,0 +1,112 @@
+package web
+
+import (
+	"bytes"
+	"embed"
+	"html/template"
+	"io/fs"
+	"math"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/format"
+)
+
+// assets holds the HTML templates and static files, compiled into the binary
+//
+//go:embed templates static
+var assets embed.FS
+
+// templateFuncs are available to every page and partial
+var templateFuncs = template.FuncMap{
+	"mul": func(a, b int) int {
+		return a * b
+	},
+	"roundDuration": func(d time.Duration) string {
+		return d.Round(time.Second).String()
+	},
+	"formatCount": func(n int) string {
+		return format.Count(int64(n))
+	},
+	"formatBytes": format.Bytes,
+	"formatValue": alerts.FormatMetricValue,
+	"budgetWidth": budgetWidth,
+}
+
+// budgetWidth is how much of an SLO's budget bar is filled: the percent left,
+// kept between 0 and 100
+func budgetWidth(remaining float64) float64 {
+	return math.Max(0, math.Min(100, remaining))
+}
+
+// templateSet holds the parsed templates. Partials are HTMX fragments that can be
+// rendered on their own or included from pages; each page is the layout plus the
+// partials plus the page's own "content" (and optional "head" and "scripts") blocks.
+type templateSet struct {
+	partials *template.Template
+	pages    map[string]*template.Template
+}
+
+// mustLoadTemplates parses the embedded templates. They're part of the binary,
+// so a parse error is a programming mistake rather than a runtime condition.
+func mustLoadTemplates() *templateSet {
+	base := template.Must(template.New("base").Funcs(templateFuncs).ParseFS(assets,
+		"templates/layout.html",
+		"templates/partials/*.html",
+	))
+
+	pageFiles, err := fs.Glob(assets, "templates/pages/*.html")
+	if err != nil {
+		panic(err)
+	}
+
+	set := &templateSet{
+		partials: base,
+		pages:    make(map[string]*template.Template),
+	}
+	for _, file := range pageFiles {
+		name := strings.TrimSuffix(path.Base(file), ".html")
+		set.pages[name] = template.Must(template.Must(base.Clone()).ParseFS(assets, file))
+	}
+
+	return set
+}
+
+// staticHandler serves the embedded files under /static/
+func staticHandler() http.Handler {
+	return http.FileServer(http.FS(assets))
+}
+
+// renderPage renders a full page inside the layout
+func (s *Server) renderPage(w http.ResponseWriter, r *http.Request, page string, data PageData) {
+	t, ok := s.templates.pages[page]
+	if !ok {
+		http.Error(w, "unknown page: "+page, http.StatusInternalServerError)
+		return
+	}
+	data.Role = currentRole(r)
+	data.ReadOnly = s.readOnly || data.Role != RoleAdmin
+	data.CSRF = csrfToken(r)
+	data.Theme = pageTheme(r)
+	s.execute(w, t, "layout", data)
+}
+
+// renderPartial renders a single HTMX fragment
+func (s *Server) renderPartial(w http.ResponseWriter, name string, data interface{}) {
+	s.execute(w, s.templates.partials, name, data)
+}
+
+// execute renders into a buffer first so a template error doesn't leave a half-written page
+func (s *Server) execute(w http.ResponseWriter, t *template.Template, name string, data interface{}) {
+	var buf bytes.Buffer
+	if err := t.ExecuteTemplate(&buf, name, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write(buf.Bytes())
+}
@@ -0,0 +1,22 @@
+package web
+
+import "net/http"
+
+// The color theme is chosen in the browser (see toggleTheme in layout.html) and
+// kept in a cookie, so pages render with it from the first byte instead of
+// flashing the light theme while scripts load.
+const themeCookieName = "peep_theme"
+
+// pageTheme returns the theme the browser picked, or "" to follow the system setting
+func pageTheme(r *http.Request) string {
+	cookie, err := r.Cookie(themeCookieName)
+	if err != nil {
+		return ""
+	}
+	switch cookie.Value {
+	case "light", "dark":
+		return cookie.Value
+	default:
+		return ""
+	}
+}
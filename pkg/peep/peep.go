@@ -0,0 +1,401 @@
+// Package peep embeds Peep's log storage and alerting in a Go program, for
+// applications that want to keep and alert on their own logs without running
+// the peep binary alongside them:
+//
+//	db, err := peep.Open("app.db")
+//	if err != nil {
+//		return err
+//	}
+//	defer db.Close()
+//
+//	db.Ingest(peep.Entry{Level: "error", Service: "api", Message: "payment failed",
+//		Fields: map[string]interface{}{"order_id": 1234}})
+//
+//	errors, err := db.Query(peep.Filter{Levels: []string{"error"}, Since: time.Now().Add(-time.Hour)})
+//
+//	alerts := db.Alerts()
+//	alerts.AddRule(&peep.Rule{Name: "Errors", Query: "SELECT COUNT(*) FROM logs WHERE level = 'error'",
+//		Threshold: 5, Window: "5m", Enabled: true})
+//	alerts.Start(time.Minute)
+//
+// The database is the one the CLI uses, so peep query, peep web, and peep
+// alerts work on it too. This package's API is kept stable across releases;
+// everything under internal/ may change.
+package peep
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"maps"
+	"time"
+
+	"github.com/kylereynolds/peep/internal/alerts"
+	"github.com/kylereynolds/peep/internal/storage"
+)
+
+// DB is an open Peep database
+type DB struct {
+	store  *storage.Storage
+	alerts *Alerts
+}
+
+// Open opens the database at path, creating it if it doesn't exist
+func Open(path string) (*DB, error) {
+	store, err := storage.NewStorage(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize storage: %w", err)
+	}
+	engine, err := alerts.NewEngine(store)
+	if err != nil {
+		store.Close()
+		return nil, fmt.Errorf("failed to initialize alert engine: %w", err)
+	}
+	return &DB{store: store, alerts: &Alerts{engine: engine}}, nil
+}
+
+// Close stops the alert engine and closes the database
+func (db *DB) Close() error {
+	db.alerts.Stop()
+	return db.store.Close()
+}
+
+// Entry is a log. Zero fields get the defaults peep ingest uses: the current
+// time, level info, and service unknown.
+type Entry struct {
+	ID        int64 // Set on the logs Query returns; ignored by Ingest
+	Timestamp time.Time
+	Level     string
+	Service   string
+	Message   string
+
+	// Fields are stored as the log's JSON context, which queries reach with
+	// json_extract(context, '$.field')
+	Fields map[string]interface{}
+
+	Raw string // The log line as it arrived; default: the message
+}
+
+// Ingest stores a log
+func (db *DB) Ingest(entry Entry) error {
+	stored := storage.LogEntry{
+		Timestamp: entry.Timestamp,
+		Level:     entry.Level,
+		Message:   entry.Message,
+		Service:   entry.Service,
+		Context:   "{}",
+		RawLog:    entry.Raw,
+	}
+	if stored.Timestamp.IsZero() {
+		stored.Timestamp = time.Now()
+	}
+	if stored.Level == "" {
+		stored.Level = "info"
+	}
+	if stored.Service == "" {
+		stored.Service = "unknown"
+	}
+	if stored.RawLog == "" {
+		stored.RawLog = stored.Message
+	}
+	if len(entry.Fields) > 0 {
+		context, err := json.Marshal(entry.Fields)
+		if err != nil {
+			return fmt.Errorf("fields aren't JSON: %w", err)
+		}
+		stored.Context = string(context)
+	}
+
+	if err := db.store.InsertLog(stored); err != nil {
+		return err
+	}
+	db.store.TriggerRetentionCheck()
+	return nil
+}
+
+// Filter narrows Query. Empty fields match every log.
+type Filter struct {
+	Levels  []string // Any of these levels
+	Service string
+	Search  string // Only messages containing this text
+	Trace   string // Only logs whose fields carry this trace ID
+
+	Since  time.Time // Only logs at or after this time
+	Before time.Time // Only logs before this time
+
+	Limit int // Default: 100 for Query, every match for Each
+}
+
+// Query returns the most recent logs matching filter, newest first
+func (db *DB) Query(filter Filter) ([]Entry, error) {
+	return db.QueryContext(context.Background(), filter)
+}
+
+// QueryContext is Query, stopping when ctx is cancelled. Either way a query
+// gives up after storage's query timeout.
+func (db *DB) QueryContext(ctx context.Context, filter Filter) ([]Entry, error) {
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = 100
+	}
+	logs, err := db.store.GetFilteredLogs(ctx, filter.storageFilter(), limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]Entry, len(logs))
+	for i, stored := range logs {
+		entries[i] = entryFromStorage(stored)
+	}
+	return entries, nil
+}
+
+// Each calls fn with each log matching filter, newest first, reading them as
+// it goes so exporting millions of logs doesn't hold them all in memory. A
+// zero Limit here means every match. An error from fn stops it and is
+// returned; so does cancelling ctx. Storage's query timeout doesn't apply.
+func (db *DB) Each(ctx context.Context, filter Filter, fn func(Entry) error) error {
+	return db.store.EachFilteredLog(ctx, filter.storageFilter(), filter.Limit, func(stored storage.LogEntry) error {
+		return fn(entryFromStorage(stored))
+	})
+}
+
+func (f Filter) storageFilter() storage.LogFilter {
+	return storage.LogFilter{
+		Levels:  f.Levels,
+		Service: f.Service,
+		Search:  f.Search,
+		Trace:   f.Trace,
+		Since:   f.Since,
+		Before:  f.Before,
+	}
+}
+
+func entryFromStorage(stored storage.LogEntry) Entry {
+	entry := Entry{
+		ID:        stored.ID,
+		Timestamp: stored.Timestamp,
+		Level:     stored.Level,
+		Service:   stored.Service,
+		Message:   stored.Message,
+		Raw:       stored.RawLog,
+	}
+	if stored.Context != "" && stored.Context != "{}" {
+		json.Unmarshal([]byte(stored.Context), &entry.Fields)
+	}
+	return entry
+}
+
+// Alerts returns the database's alert engine
+func (db *DB) Alerts() *Alerts {
+	return db.alerts
+}
+
+// Rule is an alert rule: it fires when Query's count reaches Threshold over
+// the last Window. Rules made with peep alerts add --metric show their
+// condition as Query.
+type Rule struct {
+	ID          int64 // Set by AddRule
+	Name        string
+	Description string
+	Query       string // SQL returning a count, e.g. SELECT COUNT(*) FROM logs WHERE level = 'error'
+	Threshold   int
+	Window      string // How far back Query looks, e.g. "5m" or "1h"; default 5m
+	Enabled     bool
+	Labels      map[string]string // Free-form, e.g. team or priority
+
+	CreatedAt time.Time // Set on the rules Rules returns
+	LastCheck time.Time
+	LastAlert time.Time
+}
+
+// Channel is where alerts are sent
+type Channel struct {
+	ID      int64 // Set by AddChannel
+	Name    string
+	Type    string            // desktop, slack, email, or shell
+	Config  map[string]string // e.g. webhook_url for slack
+	Enabled bool
+}
+
+// Alert is a time a rule fired
+type Alert struct {
+	ID        int64
+	RuleID    int64
+	RuleName  string
+	Count     int
+	Threshold int
+	Value     float64 // A metric rule's value; Count is it rounded
+	Query     string
+	FiredAt   time.Time
+
+	Resolved       bool
+	ResolvedAt     time.Time
+	AcknowledgedAt time.Time
+	AcknowledgedBy string
+}
+
+// RuleResult is the outcome of checking one rule
+type RuleResult struct {
+	Rule   *Rule
+	Count  int
+	Value  float64 // A metric rule's value; Count for log rules
+	Firing bool
+	Err    error // The query failed
+}
+
+// Alerts evaluates the database's alert rules and sends notifications. Rules
+// and channels are shared with the CLI and web UI. Its methods can be called
+// from any goroutine, including while Start's checks run.
+type Alerts struct {
+	engine *alerts.Engine
+}
+
+// AddRule saves a rule; its ID is set on success
+func (a *Alerts) AddRule(rule *Rule) error {
+	saved := &alerts.AlertRule{
+		Name:        rule.Name,
+		Description: rule.Description,
+		Query:       rule.Query,
+		Threshold:   rule.Threshold,
+		Window:      rule.Window,
+		Enabled:     rule.Enabled,
+		Labels:      maps.Clone(rule.Labels),
+	}
+	if err := a.engine.AddRule(saved); err != nil {
+		return err
+	}
+	rule.ID = saved.ID
+	rule.CreatedAt = saved.CreatedAt
+	return nil
+}
+
+// DeleteRule removes a rule
+func (a *Alerts) DeleteRule(id int64) error {
+	return a.engine.DeleteRule(id)
+}
+
+// Rules returns every rule
+func (a *Alerts) Rules() []*Rule {
+	saved := a.engine.GetRules()
+	rules := make([]*Rule, len(saved))
+	for i, rule := range saved {
+		rules[i] = ruleFromEngine(rule)
+	}
+	return rules
+}
+
+func ruleFromEngine(rule *alerts.AlertRule) *Rule {
+	return &Rule{
+		ID:          rule.ID,
+		Name:        rule.Name,
+		Description: rule.Description,
+		Query:       rule.Query,
+		Threshold:   rule.Threshold,
+		Window:      rule.Window,
+		Enabled:     rule.Enabled,
+		Labels:      maps.Clone(rule.Labels),
+		CreatedAt:   rule.CreatedAt,
+		LastCheck:   rule.LastCheck,
+		LastAlert:   rule.LastAlert,
+	}
+}
+
+// AddChannel saves a notification channel: desktop, slack (config key
+// webhook_url), email, or shell
+func (a *Alerts) AddChannel(channel *Channel) error {
+	saved := &alerts.NotificationChannel{
+		Name:    channel.Name,
+		Type:    channel.Type,
+		Config:  maps.Clone(channel.Config),
+		Enabled: channel.Enabled,
+	}
+	if err := a.engine.AddNotificationChannel(saved); err != nil {
+		return err
+	}
+	channel.ID = saved.ID
+	return nil
+}
+
+// DeleteChannel removes a notification channel, such as the desktop channel
+// a new database starts with
+func (a *Alerts) DeleteChannel(id int64) error {
+	return a.engine.DeleteChannel(id)
+}
+
+// Channels returns every notification channel
+func (a *Alerts) Channels() []*Channel {
+	saved := a.engine.GetChannels()
+	channels := make([]*Channel, len(saved))
+	for i, channel := range saved {
+		channels[i] = &Channel{
+			ID:      channel.ID,
+			Name:    channel.Name,
+			Type:    channel.Type,
+			Config:  maps.Clone(channel.Config),
+			Enabled: channel.Enabled,
+		}
+	}
+	return channels
+}
+
+// Start checks the rules in the background every interval (zero for the
+// default, 30s), firing and resolving alerts, until Stop or Close
+func (a *Alerts) Start(interval time.Duration) {
+	a.engine.SetCheckInterval(interval)
+	a.engine.Start()
+}
+
+// Stop ends background checking
+func (a *Alerts) Stop() {
+	a.engine.Stop()
+}
+
+// Check evaluates every enabled rule once. With notify, firing rules record
+// an alert and notify their channels as the background check would;
+// without it nothing is recorded.
+func (a *Alerts) Check(notify bool) []RuleResult {
+	return a.CheckContext(context.Background(), notify)
+}
+
+// CheckContext is Check, stopping when ctx is cancelled
+func (a *Alerts) CheckContext(ctx context.Context, notify bool) []RuleResult {
+	checked := a.engine.CheckRules(ctx, notify)
+	results := make([]RuleResult, len(checked))
+	for i, result := range checked {
+		results[i] = RuleResult{
+			Rule:   ruleFromEngine(result.Rule),
+			Count:  result.Count,
+			Value:  result.Value,
+			Firing: result.Firing,
+			Err:    result.Err,
+		}
+	}
+	return results
+}
+
+// Firing returns the alerts that haven't resolved, newest first
+func (a *Alerts) Firing() ([]*Alert, error) {
+	instances, err := a.engine.ListAlertInstances(alerts.InstanceFilter{Unresolved: true})
+	if err != nil {
+		return nil, err
+	}
+	firing := make([]*Alert, len(instances))
+	for i, instance := range instances {
+		firing[i] = &Alert{
+			ID:             instance.ID,
+			RuleID:         instance.RuleID,
+			RuleName:       instance.RuleName,
+			Count:          instance.Count,
+			Threshold:      instance.Threshold,
+			Value:          instance.Value,
+			Query:          instance.Query,
+			FiredAt:        instance.FiredAt,
+			Resolved:       instance.Resolved,
+			ResolvedAt:     instance.ResolvedAt,
+			AcknowledgedAt: instance.AcknowledgedAt,
+			AcknowledgedBy: instance.AcknowledgedBy,
+		}
+	}
+	return firing, nil
+}
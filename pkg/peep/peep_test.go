@@ -0,0 +1,103 @@
+package peep
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func openTestDB(t *testing.T) *DB {
+	t.Helper()
+	db, err := Open(filepath.Join(t.TempDir(), "app.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+// scriptChannel replaces the desktop channel a new database starts with by a
+// shell channel that writes each alert's JSON payload to the returned path,
+// so tests don't pop up notifications
+func scriptChannel(t *testing.T, alerts *Alerts) string {
+	t.Helper()
+	for _, channel := range alerts.Channels() {
+		if err := alerts.DeleteChannel(channel.ID); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := t.TempDir()
+	payload := filepath.Join(dir, "alert.json")
+	script := filepath.Join(dir, "notify.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ncat > "+payload+"\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := alerts.AddChannel(&Channel{Name: "test", Type: "shell", Config: map[string]string{"script_path": script}, Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	return payload
+}
+
+// Rules can be added and read while the background checks run
+func TestAlertsWhileRunning(t *testing.T) {
+	db := openTestDB(t)
+	alerts := db.Alerts()
+	alerts.Start(10 * time.Millisecond)
+
+	for i := 0; i < 20; i++ {
+		rule := &Rule{Name: fmt.Sprintf("errors %d", i), Query: "SELECT COUNT(*) FROM logs WHERE level = 'error'",
+			Threshold: 1, Window: "5m", Enabled: true, Labels: map[string]string{"team": "api"}}
+		if err := alerts.AddRule(rule); err != nil {
+			t.Fatal(err)
+		}
+		if rule.ID == 0 {
+			t.Fatal("AddRule didn't set the rule's ID")
+		}
+		rule.Labels["team"] = "changed" // The saved rule keeps its own labels
+		alerts.Rules()
+		time.Sleep(time.Millisecond)
+	}
+	alerts.Stop()
+
+	rules := alerts.Rules()
+	if len(rules) != 20 || rules[0].Labels["team"] != "api" {
+		t.Errorf("got %d rules labelled %v, want 20 labelled team=api", len(rules), rules[0].Labels)
+	}
+}
+
+func TestCheckFiresAlerts(t *testing.T) {
+	db := openTestDB(t)
+	alerts := db.Alerts()
+	payload := scriptChannel(t, alerts)
+	if err := alerts.AddRule(&Rule{Name: "errors", Query: "SELECT COUNT(*) FROM logs WHERE level = 'error'",
+		Threshold: 2, Window: "5m", Enabled: true}); err != nil {
+		t.Fatal(err)
+	}
+	if err := alerts.AddRule(&Rule{Name: "bad", Query: "DELETE FROM logs", Threshold: 1, Enabled: true}); err == nil {
+		t.Error("AddRule accepted a DELETE")
+	}
+	for i := 0; i < 3; i++ {
+		if err := db.Ingest(Entry{Level: "error", Message: "payment failed"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	results := alerts.Check(true)
+	if len(results) != 1 || !results[0].Firing || results[0].Count != 3 || results[0].Rule.Name != "errors" {
+		t.Fatalf("got %+v, want errors firing with 3", results)
+	}
+	firing, err := alerts.Firing()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(firing) != 1 || firing[0].RuleName != "errors" || firing[0].Count != 3 {
+		t.Errorf("got %+v, want the errors alert", firing)
+	}
+	if sent, err := os.ReadFile(payload); err != nil || !strings.Contains(string(sent), "payment failed") {
+		t.Errorf("the channel got %q (%v), want the alert with its sample logs", sent, err)
+	}
+}